@@ -0,0 +1,232 @@
+// Package apierror has the error responses of the http apis, so that clients see the same error envelope
+// from all the services
+//
+//	{"code": "LEAD_NOT_FOUND", "message": "lead 42 does not exist", "trace_id": "4bf92f35...", "details": {...}}
+//
+// Handlers return or build an *Error and write it with WriteError, which also logs it and adds the trace id
+//
+//	lead, err := store.Lead(ctx, id)
+//	if err != nil {
+//		apierror.WriteError(w, r, err)
+//		return
+//	}
+package apierror
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"sync"
+
+	"github.com/carwale/golibraries/ctxutil"
+	"github.com/carwale/golibraries/gologger"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Codes of the errors built by the constructors of this package
+const (
+	CodeBadRequest      = "BAD_REQUEST"
+	CodeUnauthorized    = "UNAUTHORIZED"
+	CodeForbidden       = "FORBIDDEN"
+	CodeNotFound        = "NOT_FOUND"
+	CodeConflict        = "CONFLICT"
+	CodeTooManyRequests = "TOO_MANY_REQUESTS"
+	CodeInternal        = "INTERNAL_ERROR"
+	CodeUnavailable     = "SERVICE_UNAVAILABLE"
+	CodeTimeout         = "TIMEOUT"
+	CodeCanceled        = "CANCELED"
+)
+
+// StatusClientClosedRequest is the status of requests canceled by the client. The client does not see it,
+// but it shows up in the access logs
+const StatusClientClosedRequest = 499
+
+// Error is an error response of an api. The cause is logged but not sent to the client
+type Error struct {
+	Status  int         `json:"-"`
+	Code    string      `json:"code"`
+	Message string      `json:"message"`
+	TraceID string      `json:"trace_id,omitempty"`
+	Details interface{} `json:"details,omitempty"`
+	cause   error
+}
+
+// IHTTPError is implemented by errors of other packages that know their response status, like the errors of
+// the validation package. WriteError responds to them with their status, code and message
+type IHTTPError interface {
+	gologger.ICodedError
+	HTTPStatus() int
+}
+
+// New returns an error response with the status, code and message
+func New(status int, code, message string) *Error {
+	return &Error{Status: status, Code: code, Message: message}
+}
+
+// Wrap returns an error response for err. err is logged by WriteError and returned by Unwrap
+func Wrap(err error, status int, code, message string) *Error {
+	return &Error{Status: status, Code: code, Message: message, cause: err}
+}
+
+// BadRequest returns a 400 error response
+func BadRequest(message string) *Error { return New(http.StatusBadRequest, CodeBadRequest, message) }
+
+// Unauthorized returns a 401 error response
+func Unauthorized(message string) *Error {
+	return New(http.StatusUnauthorized, CodeUnauthorized, message)
+}
+
+// Forbidden returns a 403 error response
+func Forbidden(message string) *Error { return New(http.StatusForbidden, CodeForbidden, message) }
+
+// NotFound returns a 404 error response
+func NotFound(message string) *Error { return New(http.StatusNotFound, CodeNotFound, message) }
+
+// Conflict returns a 409 error response
+func Conflict(message string) *Error { return New(http.StatusConflict, CodeConflict, message) }
+
+// TooManyRequests returns a 429 error response
+func TooManyRequests(message string) *Error {
+	return New(http.StatusTooManyRequests, CodeTooManyRequests, message)
+}
+
+// Internal returns a 500 error response for err. The message sent to the client does not include err
+func Internal(err error) *Error {
+	return Wrap(err, http.StatusInternalServerError, CodeInternal, "internal server error")
+}
+
+// Unavailable returns a 503 error response
+func Unavailable(message string) *Error {
+	return New(http.StatusServiceUnavailable, CodeUnavailable, message)
+}
+
+// WithDetails returns a copy of the error with the details
+func (e *Error) WithDetails(details interface{}) *Error {
+	clone := *e
+	clone.Details = details
+	return &clone
+}
+
+// Error returns the code and message, and the cause if there is one
+func (e *Error) Error() string {
+	if e.cause != nil {
+		return e.Code + ": " + e.Message + ": " + e.cause.Error()
+	}
+	return e.Code + ": " + e.Message
+}
+
+// Unwrap returns the cause of the error
+func (e *Error) Unwrap() error {
+	return e.cause
+}
+
+// ErrorCode returns the code, so that it is used as the error code of gologger
+func (e *Error) ErrorCode() string {
+	return e.Code
+}
+
+// HTTPStatus returns the status of the response
+func (e *Error) HTTPStatus() int {
+	if e.Status == 0 {
+		return http.StatusInternalServerError
+	}
+	return e.Status
+}
+
+// From returns the error response for err. *Error and IHTTPError are kept, context errors are mapped to
+// 504 and 499, and other errors are internal errors
+func From(err error) *Error {
+	var apiErr *Error
+	if errors.As(err, &apiErr) {
+		return apiErr
+	}
+	var httpErr IHTTPError
+	if errors.As(err, &httpErr) {
+		e := Wrap(err, httpErr.HTTPStatus(), httpErr.ErrorCode(), httpErr.Error())
+		if detailed, ok := httpErr.(interface{ ErrorDetails() interface{} }); ok {
+			e.Details = detailed.ErrorDetails()
+		}
+		return e
+	}
+	switch {
+	case errors.Is(err, context.DeadlineExceeded):
+		return Wrap(err, http.StatusGatewayTimeout, CodeTimeout, "request timed out")
+	case errors.Is(err, context.Canceled):
+		return Wrap(err, StatusClientClosedRequest, CodeCanceled, "request canceled")
+	}
+	return Internal(err)
+}
+
+// StatusFor returns the response status for err
+func StatusFor(err error) int {
+	return From(err).HTTPStatus()
+}
+
+var (
+	loggerLock sync.RWMutex
+	logger     gologger.ILogger
+)
+
+// SetLogger sets the logger WriteError logs to. Server errors are logged as errors and client errors as warnings.
+// Default is a logger at ERROR level
+func SetLogger(customLogger gologger.ILogger) {
+	loggerLock.Lock()
+	defer loggerLock.Unlock()
+	logger = customLogger
+}
+
+func getLogger() gologger.ILogger {
+	loggerLock.RLock()
+	l := logger
+	loggerLock.RUnlock()
+	if l != nil {
+		return l
+	}
+	loggerLock.Lock()
+	defer loggerLock.Unlock()
+	if logger == nil {
+		logger = gologger.NewLogger(gologger.SetLogLevel("ERROR"))
+	}
+	return logger
+}
+
+// response is the payload written by WriteError
+type response struct {
+	*Error
+	RequestID string `json:"request_id,omitempty"`
+}
+
+// WriteError writes the error response for err with the trace id of the request and logs it
+func WriteError(w http.ResponseWriter, r *http.Request, err error) {
+	e := From(err)
+	if spanContext := trace.SpanContextFromContext(r.Context()); spanContext.HasTraceID() && e.TraceID == "" {
+		traced := *e
+		traced.TraceID = spanContext.TraceID().String()
+		e = &traced
+	}
+	status := e.HTTPStatus()
+	logError(r, e, status)
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(response{Error: e, RequestID: ctxutil.RequestIDFrom(r.Context())})
+}
+
+func logError(r *http.Request, e *Error, status int) {
+	pairs := []gologger.Pair{
+		{Key: gologger.ErrorCodeKey, Value: e.Code},
+		{Key: "method", Value: r.Method},
+		{Key: "path", Value: r.URL.Path},
+	}
+	if e.TraceID != "" {
+		pairs = append(pairs, gologger.Pair{Key: "trace_id", Value: e.TraceID})
+	}
+	if requestID := ctxutil.RequestIDFrom(r.Context()); requestID != "" {
+		pairs = append(pairs, gologger.Pair{Key: ctxutil.RequestIDLogKey, Value: requestID})
+	}
+	if status >= http.StatusInternalServerError {
+		getLogger().LogErrorMessage("API request failed: "+e.Message, e, pairs...)
+		return
+	}
+	getLogger().LogWarningMessage("API request rejected: "+e.Error(), pairs...)
+}
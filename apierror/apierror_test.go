@@ -0,0 +1,95 @@
+package apierror
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/carwale/golibraries/ctxutil"
+	"go.opentelemetry.io/otel/trace"
+)
+
+type statusError struct{}
+
+func (statusError) Error() string             { return "quota exceeded" }
+func (statusError) ErrorCode() string         { return "QUOTA_EXCEEDED" }
+func (statusError) HTTPStatus() int           { return http.StatusTooManyRequests }
+func (statusError) ErrorDetails() interface{} { return map[string]int{"limit": 10} }
+
+func TestFrom(t *testing.T) {
+	tests := []struct {
+		name   string
+		err    error
+		status int
+		code   string
+	}{
+		{"api error", NotFound("lead 42 does not exist"), http.StatusNotFound, CodeNotFound},
+		{"wrapped api error", fmt.Errorf("loading lead: %w", Conflict("duplicate lead")), http.StatusConflict, CodeConflict},
+		{"http error", statusError{}, http.StatusTooManyRequests, "QUOTA_EXCEEDED"},
+		{"deadline", fmt.Errorf("query: %w", context.DeadlineExceeded), http.StatusGatewayTimeout, CodeTimeout},
+		{"canceled", context.Canceled, StatusClientClosedRequest, CodeCanceled},
+		{"other", errors.New("connection refused"), http.StatusInternalServerError, CodeInternal},
+		{"no status", &Error{Code: "X"}, http.StatusInternalServerError, "X"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			e := From(test.err)
+			if e.HTTPStatus() != test.status || e.Code != test.code {
+				t.Fatalf("expected %d %s, got %d %s", test.status, test.code, e.HTTPStatus(), e.Code)
+			}
+			if StatusFor(test.err) != test.status {
+				t.Fatalf("expected StatusFor to return %d", test.status)
+			}
+		})
+	}
+}
+
+func TestWriteError(t *testing.T) {
+	traceID, _ := trace.TraceIDFromHex("4bf92f3577b34da6a3ce929d0e0e4736")
+	spanID, _ := trace.SpanIDFromHex("00f067aa0ba902b7")
+	ctx := trace.ContextWithSpanContext(context.Background(), trace.NewSpanContext(trace.SpanContextConfig{TraceID: traceID, SpanID: spanID}))
+	ctx = ctxutil.WithRequestID(ctx, "req-1")
+	r := httptest.NewRequest(http.MethodGet, "/leads/42", nil).WithContext(ctx)
+
+	tests := []struct {
+		name     string
+		err      error
+		status   int
+		expected map[string]interface{}
+	}{
+		{"client error", NotFound("lead 42 does not exist").WithDetails(map[string]int{"id": 42}), http.StatusNotFound, map[string]interface{}{
+			"code": CodeNotFound, "message": "lead 42 does not exist", "trace_id": traceID.String(),
+			"request_id": "req-1", "details": map[string]interface{}{"id": float64(42)},
+		}},
+		{"internal error", errors.New("dial tcp: connection refused"), http.StatusInternalServerError, map[string]interface{}{
+			"code": CodeInternal, "message": "internal server error", "trace_id": traceID.String(), "request_id": "req-1",
+		}},
+		{"http error", statusError{}, http.StatusTooManyRequests, map[string]interface{}{
+			"code": "QUOTA_EXCEEDED", "message": "quota exceeded", "trace_id": traceID.String(),
+			"request_id": "req-1", "details": map[string]interface{}{"limit": float64(10)},
+		}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			WriteError(w, r, test.err)
+			if w.Code != test.status {
+				t.Fatalf("expected status %d, got %d", test.status, w.Code)
+			}
+			if contentType := w.Header().Get("Content-Type"); contentType != "application/json" {
+				t.Fatalf("expected a json response, got %s", contentType)
+			}
+			var body map[string]interface{}
+			if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+				t.Fatal(err)
+			}
+			if fmt.Sprint(body) != fmt.Sprint(test.expected) {
+				t.Fatalf("expected %v, got %v", test.expected, body)
+			}
+		})
+	}
+}
@@ -0,0 +1,217 @@
+package bridge
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/carwale/golibraries/gologger"
+	"github.com/carwale/golibraries/kafka"
+	"github.com/carwale/golibraries/rabbitmq/channelprovider"
+	"github.com/streadway/amqp"
+)
+
+// Headers added to the messages published by the KafkaToRabbitMQ bridge
+const (
+	HeaderKafkaTopic     = "kafka-topic"
+	HeaderKafkaPartition = "kafka-partition"
+	HeaderKafkaOffset    = "kafka-offset"
+	HeaderKafkaKey       = "kafka-key"
+)
+
+// RabbitMQRoute is the exchange and the routing key a message is published with
+type RabbitMQRoute struct {
+	Exchange   string
+	RoutingKey string
+}
+
+// RouteFunc returns the route of a kafka message. Messages for which it returns false are skipped
+type RouteFunc func(msg *kafka.Message) (RabbitMQRoute, bool)
+
+// StaticRoute routes all the messages to the exchange with the routing key
+func StaticRoute(exchange string, routingKey string) RouteFunc {
+	route := RabbitMQRoute{Exchange: exchange, RoutingKey: routingKey}
+	return func(msg *kafka.Message) (RabbitMQRoute, bool) { return route, true }
+}
+
+// TopicRoutes routes the messages of every topic to its route. Messages of other topics are skipped
+func TopicRoutes(routes map[string]RabbitMQRoute) RouteFunc {
+	return func(msg *kafka.Message) (RabbitMQRoute, bool) {
+		if msg.TopicPartition.Topic == nil {
+			return RabbitMQRoute{}, false
+		}
+		route, ok := routes[*msg.TopicPartition.Topic]
+		return route, ok
+	}
+}
+
+// QueueRoute returns the route of the queue of a rabbitmq.OperationManager, which declares the
+// exchange <QUEUE>-Exchange bound with the routing key <QUEUE>-Key
+func QueueRoute(queueName string) RabbitMQRoute {
+	queueName = strings.ToUpper(queueName)
+	return RabbitMQRoute{Exchange: queueName + "-Exchange", RoutingKey: queueName + "-Key"}
+}
+
+// KafkaToRabbitMQ consumes kafka topics and publishes the messages to rabbitmq exchanges, for consumers
+// which cannot read kafka yet. The messages are published on a channel in confirm mode and the processor
+// returns once the broker has confirmed them, so a message is not lost if the bridge crashes before its offset
+// is committed. The payload is published as it is with the kafka topic, partition, offset and key as headers.
+//
+// The messages are published one at a time. Use it with a kafka.WorkerPoolBridge to publish in parallel
+type KafkaToRabbitMQ struct {
+	name            string
+	consumer        *kafka.Consumer
+	channelProvider *channelprovider.ChannelProvider
+	route           RouteFunc
+	maxRetries      int
+	retryBackoff    time.Duration
+	publishTimeout  time.Duration
+	channel         *channelprovider.ConfirmedChannel
+	channelLock     sync.Mutex
+	logger          gologger.ILogger
+	latencyLogger   gologger.IMultiLogger
+}
+
+// KafkaToRabbitMQOption sets a parameter for the KafkaToRabbitMQ bridge
+type KafkaToRabbitMQOption func(b *KafkaToRabbitMQ)
+
+// SetRabbitMQRetry sets the number of times a message is published again after a failure and the
+// backoff, which grows linearly with the attempts. Defaults to 3 retries and 1 second
+func SetRabbitMQRetry(maxRetries int, backoff time.Duration) KafkaToRabbitMQOption {
+	return func(b *KafkaToRabbitMQ) {
+		b.maxRetries = maxRetries
+		b.retryBackoff = backoff
+	}
+}
+
+// SetPublishTimeout sets the time to wait for the confirmation of a message. Defaults to 10 seconds
+func SetPublishTimeout(timeout time.Duration) KafkaToRabbitMQOption {
+	return func(b *KafkaToRabbitMQ) { b.publishTimeout = timeout }
+}
+
+// SetKafkaToRabbitMQLogger sets the logger of the bridge. Defaults to gologger.NewLogger()
+func SetKafkaToRabbitMQLogger(logger gologger.ILogger) KafkaToRabbitMQOption {
+	return func(b *KafkaToRabbitMQ) { b.logger = logger }
+}
+
+// SetKafkaToRabbitMQLatencyLogger sets the latency logger used for the bridge metrics.
+// Defaults to the rate latency logger
+func SetKafkaToRabbitMQLatencyLogger(latencyLogger gologger.IMultiLogger) KafkaToRabbitMQOption {
+	return func(b *KafkaToRabbitMQ) { b.latencyLogger = latencyLogger }
+}
+
+// NewKafkaToRabbitMQ returns a bridge from the topics of the consumer to the routes returned by route.
+// The name is used in logs and metrics
+func NewKafkaToRabbitMQ(name string, consumer *kafka.Consumer, channelProvider *channelprovider.ChannelProvider, route RouteFunc, options ...KafkaToRabbitMQOption) *KafkaToRabbitMQ {
+	b := &KafkaToRabbitMQ{
+		name:            name,
+		consumer:        consumer,
+		channelProvider: channelProvider,
+		route:           route,
+		maxRetries:      3,
+		retryBackoff:    time.Second,
+		publishTimeout:  10 * time.Second,
+	}
+	for _, option := range options {
+		option(b)
+	}
+	if b.logger == nil {
+		b.logger = gologger.NewLogger()
+	}
+	if b.latencyLogger == nil {
+		b.latencyLogger = gologger.NewRateLatencyLogger(gologger.SetLogger(b.logger))
+	}
+	registerMetrics(b.latencyLogger, b.logger)
+	return b
+}
+
+// Start starts the consumer and blocks till it is closed
+func (b *KafkaToRabbitMQ) Start() {
+	b.logger.LogWarningf("Starting bridge %s from kafka topics %v", b.name, b.consumer.Topics)
+	b.consumer.Start(b)
+	b.channelLock.Lock()
+	if b.channel != nil {
+		b.channel.Close()
+		b.channel = nil
+	}
+	b.channelLock.Unlock()
+}
+
+// ProcessMessage publishes the message to its route and waits for the confirmation of the broker
+func (b *KafkaToRabbitMQ) ProcessMessage(msg *kafka.Message) bool {
+	route, ok := b.route(msg)
+	if !ok {
+		return true
+	}
+	publishing := rabbitMQPublishing(msg)
+	start := time.Now()
+	var err error
+	for attempt := 0; attempt <= b.maxRetries; attempt++ {
+		if attempt > 0 {
+			b.latencyLogger.IncVal(1, messagesMetricID, b.name, statusRetried)
+			time.Sleep(b.retryBackoff * time.Duration(attempt))
+		}
+		if err = b.publish(route, publishing); err == nil {
+			b.latencyLogger.Toc(start, latencyMetricID, b.name)
+			b.latencyLogger.IncVal(1, messagesMetricID, b.name, statusForwarded)
+			return true
+		}
+	}
+	b.logger.LogError("bridge "+b.name+" could not publish a message to rabbitmq exchange "+route.Exchange, err)
+	b.latencyLogger.IncVal(1, messagesMetricID, b.name, statusFailed)
+	return false
+}
+
+// publish publishes the message on the shared confirmed channel, which is replaced if the publish fails
+func (b *KafkaToRabbitMQ) publish(route RabbitMQRoute, publishing amqp.Publishing) error {
+	channel, err := b.confirmedChannel()
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), b.publishTimeout)
+	defer cancel()
+	if err = channel.Publish(ctx, route.Exchange, route.RoutingKey, false, publishing); err != nil {
+		b.channelLock.Lock()
+		if b.channel == channel {
+			b.channel.Close()
+			b.channel = nil
+		}
+		b.channelLock.Unlock()
+	}
+	return err
+}
+
+func (b *KafkaToRabbitMQ) confirmedChannel() (*channelprovider.ConfirmedChannel, error) {
+	b.channelLock.Lock()
+	defer b.channelLock.Unlock()
+	if b.channel == nil {
+		channel, err := b.channelProvider.GetConfirmedChannel(nil)
+		if err != nil {
+			return nil, err
+		}
+		b.channel = channel
+	}
+	return b.channel, nil
+}
+
+// rabbitMQPublishing returns a persistent publishing of the kafka message
+func rabbitMQPublishing(msg *kafka.Message) amqp.Publishing {
+	headers := amqp.Table{
+		HeaderKafkaPartition: int64(msg.TopicPartition.Partition),
+		HeaderKafkaOffset:    int64(msg.TopicPartition.Offset),
+	}
+	if msg.TopicPartition.Topic != nil {
+		headers[HeaderKafkaTopic] = *msg.TopicPartition.Topic
+	}
+	if msg.Key != nil {
+		headers[HeaderKafkaKey] = string(msg.Key)
+	}
+	return amqp.Publishing{
+		ContentType:  "application/octet-stream",
+		DeliveryMode: amqp.Persistent,
+		Timestamp:    msg.Timestamp,
+		Headers:      headers,
+		Body:         msg.Data,
+	}
+}
@@ -0,0 +1,35 @@
+package bridge
+
+import (
+	"testing"
+
+	"github.com/carwale/golibraries/kafka"
+	confluent "github.com/confluentinc/confluent-kafka-go/kafka"
+)
+
+func TestTopicRoutes(t *testing.T) {
+	leads, stock := "leads", "stock"
+	route := TopicRoutes(map[string]RabbitMQRoute{leads: QueueRoute("lead-sync")})
+
+	r, ok := route(&kafka.Message{TopicPartition: confluent.TopicPartition{Topic: &leads}})
+	if !ok || r.Exchange != "LEAD-SYNC-Exchange" || r.RoutingKey != "LEAD-SYNC-Key" {
+		t.Errorf("route of leads = %v, %v, want the route of the LEAD-SYNC queue", r, ok)
+	}
+	if _, ok := route(&kafka.Message{TopicPartition: confluent.TopicPartition{Topic: &stock}}); ok {
+		t.Error("message of an unmapped topic was routed")
+	}
+}
+
+func TestRabbitMQPublishing(t *testing.T) {
+	topic := "leads"
+	msg := &kafka.Message{
+		Data:           kafka.RawEvent(`{"id":1}`),
+		Key:            []byte("lead-1"),
+		TopicPartition: confluent.TopicPartition{Topic: &topic, Partition: 3, Offset: 42},
+	}
+	publishing := rabbitMQPublishing(msg)
+	if string(publishing.Body) != `{"id":1}` || publishing.Headers[HeaderKafkaKey] != "lead-1" ||
+		publishing.Headers[HeaderKafkaTopic] != topic || publishing.Headers[HeaderKafkaOffset] != int64(42) {
+		t.Errorf("publishing = %+v, want the payload with the kafka metadata in the headers", publishing)
+	}
+}
@@ -0,0 +1,41 @@
+package bridge
+
+import (
+	"github.com/carwale/golibraries/gologger"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const (
+	messagesMetricID = "BRIDGE-MESSAGES"
+	latencyMetricID  = "BRIDGE-LATENCY"
+)
+
+// Statuses of the messages metric
+const (
+	statusForwarded = "forwarded"
+	statusRetried   = "retried"
+	statusFailed    = "failed"
+)
+
+var bridgeMetrics = gologger.NewMetricSet(func(logger gologger.ILogger) map[string]gologger.IMetricVec {
+	return map[string]gologger.IMetricVec{
+		messagesMetricID: gologger.NewCounterMetric(prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "bridge_messages_total",
+				Help: "Messages handled by a bridge per status (forwarded, retried or failed)",
+			},
+			[]string{"Bridge", "Status"},
+		), logger),
+		latencyMetricID: gologger.NewHistogramMetric(prometheus.NewHistogramVec(
+			prometheus.HistogramOpts{
+				Name: "bridge_forward_milliseconds",
+				Help: "Time taken by a bridge to publish a message to the destination, including retries",
+			},
+			[]string{"Bridge"},
+		), logger),
+	}
+})
+
+func registerMetrics(latencyLogger gologger.IMultiLogger, logger gologger.ILogger) {
+	bridgeMetrics.AddTo(latencyLogger, logger)
+}
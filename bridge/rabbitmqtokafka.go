@@ -0,0 +1,166 @@
+package bridge
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/carwale/golibraries/gologger"
+	"github.com/carwale/golibraries/kafka"
+	"github.com/carwale/golibraries/rabbitmq"
+)
+
+// RabbitMQToKafka consumes the messages of a rabbitmq queue and publishes them to a kafka topic.
+//
+// The operation manager should be created with rabbitmq.SetAckMode(rabbitmq.AckManual). The messages are then
+// published asynchronously and every message is acked once kafka has delivered it, so the prefetch count of the
+// manager (rabbitmq.SetPrefetchCount) is the number of messages in flight and the producer batches them as usual.
+// With the other ack modes every message is published synchronously, which is a lot slower.
+//
+// The consumer parses the messages as json, so the payload published to kafka is the json of the message,
+// re-encoded. A message that kafka does not accept after the retries is nacked with requeue, so the queue
+// delivers it again and no message is dropped while kafka is down. With the other ack modes the consumer
+// stops consuming until kafka accepts the message
+type RabbitMQToKafka struct {
+	name          string
+	consumer      *rabbitmq.OperationManager
+	producer      *kafka.Producer
+	topic         string
+	keyFunc       func(data map[string]interface{}) []byte
+	maxRetries    int
+	retryBackoff  time.Duration
+	logger        gologger.ILogger
+	latencyLogger gologger.IMultiLogger
+}
+
+// RabbitMQToKafkaOption sets a parameter for the RabbitMQToKafka bridge
+type RabbitMQToKafkaOption func(b *RabbitMQToKafka)
+
+// SetKafkaKey sets the function returning the kafka key of a message. A nil key publishes the message
+// without a key. By default messages have no key
+func SetKafkaKey(keyFunc func(data map[string]interface{}) []byte) RabbitMQToKafkaOption {
+	return func(b *RabbitMQToKafka) { b.keyFunc = keyFunc }
+}
+
+// SetKafkaRetry sets the number of times a message is published again after a failed delivery and the
+// backoff, which grows linearly with the attempts. Defaults to 3 retries and 1 second
+func SetKafkaRetry(maxRetries int, backoff time.Duration) RabbitMQToKafkaOption {
+	return func(b *RabbitMQToKafka) {
+		b.maxRetries = maxRetries
+		b.retryBackoff = backoff
+	}
+}
+
+// SetRabbitMQToKafkaLogger sets the logger of the bridge. Defaults to gologger.NewLogger()
+func SetRabbitMQToKafkaLogger(logger gologger.ILogger) RabbitMQToKafkaOption {
+	return func(b *RabbitMQToKafka) { b.logger = logger }
+}
+
+// SetRabbitMQToKafkaLatencyLogger sets the latency logger used for the bridge metrics.
+// Defaults to the rate latency logger
+func SetRabbitMQToKafkaLatencyLogger(latencyLogger gologger.IMultiLogger) RabbitMQToKafkaOption {
+	return func(b *RabbitMQToKafka) { b.latencyLogger = latencyLogger }
+}
+
+// NewRabbitMQToKafka returns a bridge from the queue of the consumer to the topic. The name is used in logs and metrics
+func NewRabbitMQToKafka(name string, consumer *rabbitmq.OperationManager, producer *kafka.Producer, topic string, options ...RabbitMQToKafkaOption) *RabbitMQToKafka {
+	b := &RabbitMQToKafka{
+		name:         name,
+		consumer:     consumer,
+		producer:     producer,
+		topic:        topic,
+		maxRetries:   3,
+		retryBackoff: time.Second,
+	}
+	for _, option := range options {
+		option(b)
+	}
+	if b.logger == nil {
+		b.logger = gologger.NewLogger()
+	}
+	if b.latencyLogger == nil {
+		b.latencyLogger = gologger.NewRateLatencyLogger(gologger.SetLogger(b.logger))
+	}
+	registerMetrics(b.latencyLogger, b.logger)
+	return b
+}
+
+// Start starts consuming the queue. It blocks forever, like rabbitmq.OperationManager.StartConsumer
+func (b *RabbitMQToKafka) Start() {
+	b.logger.LogWarningf("Starting bridge %s to kafka topic %s", b.name, b.topic)
+	b.consumer.StartConsumer(b)
+}
+
+// ProcessMessageWithAck publishes the message to kafka and acks it once it is delivered
+func (b *RabbitMQToKafka) ProcessMessageWithAck(ctx context.Context, data map[string]interface{}, handle *rabbitmq.AckHandle) {
+	payload, key, ok := b.encode(data)
+	if !ok {
+		handle.Nack(false)
+		return
+	}
+	b.forward(payload, key, time.Now(), 0, func(err error) {
+		if err != nil {
+			if err := handle.Nack(true); err != nil {
+				b.logger.LogError("bridge "+b.name+" could not requeue a message", err)
+			}
+			return
+		}
+		if err := handle.Ack(); err != nil {
+			b.logger.LogError("bridge "+b.name+" could not ack a forwarded message, it will be forwarded again", err)
+		}
+	})
+}
+
+// ProcessMessage publishes the message to kafka and waits for it to be delivered. Once the retries run out it
+// waits for the retry backoff and publishes the message again, until kafka accepts it
+func (b *RabbitMQToKafka) ProcessMessage(data map[string]interface{}) bool {
+	payload, key, ok := b.encode(data)
+	if !ok {
+		return false
+	}
+	start := time.Now()
+	for {
+		result := make(chan error, 1)
+		b.forward(payload, key, start, 0, func(err error) { result <- err })
+		if <-result == nil {
+			return true
+		}
+		time.Sleep(b.retryBackoff * time.Duration(b.maxRetries+1))
+	}
+}
+
+func (b *RabbitMQToKafka) encode(data map[string]interface{}) ([]byte, []byte, bool) {
+	payload, err := json.Marshal(data)
+	if err != nil {
+		b.logger.LogError("bridge "+b.name+" could not marshal a message", err)
+		b.latencyLogger.IncVal(1, messagesMetricID, b.name, statusFailed)
+		return nil, nil, false
+	}
+	var key []byte
+	if b.keyFunc != nil {
+		key = b.keyFunc(data)
+	}
+	return payload, key, true
+}
+
+// forward publishes the payload and retries failed deliveries. done is called once with the final result
+func (b *RabbitMQToKafka) forward(payload []byte, key []byte, start time.Time, attempt int, done func(err error)) {
+	b.producer.PublishMessageWithCallback(payload, b.topic, key, func(err error) {
+		if err == nil {
+			b.latencyLogger.Toc(start, latencyMetricID, b.name)
+			b.latencyLogger.IncVal(1, messagesMetricID, b.name, statusForwarded)
+			done(nil)
+			return
+		}
+		if attempt >= b.maxRetries {
+			b.logger.LogError("bridge "+b.name+" could not publish a message to kafka topic "+b.topic, err)
+			b.latencyLogger.IncVal(1, messagesMetricID, b.name, statusFailed)
+			done(err)
+			return
+		}
+		b.latencyLogger.IncVal(1, messagesMetricID, b.name, statusRetried)
+		time.AfterFunc(b.retryBackoff*time.Duration(attempt+1), func() {
+			b.forward(payload, key, start, attempt+1, done)
+		})
+	})
+}
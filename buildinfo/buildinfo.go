@@ -0,0 +1,121 @@
+// Package buildinfo holds the version, commit and build date of a service, set at build time with ldflags:
+//
+//	go build -ldflags "-X github.com/carwale/golibraries/buildinfo.Version=1.4.2 \
+//		-X github.com/carwale/golibraries/buildinfo.Commit=$(git rev-parse HEAD) \
+//		-X github.com/carwale/golibraries/buildinfo.BuildDate=$(date -u +%Y-%m-%dT%H:%M:%SZ)"
+//
+// The commit and build date default to the vcs information stamped by the go tool when the service is built
+// from a git checkout. The build info is exported as the build_info metric, served as json by Handler, logged
+// by LogStartup and added to the traces by the gotracer package
+package buildinfo
+
+import (
+	"encoding/json"
+	"net/http"
+	"runtime"
+	"runtime/debug"
+	"sync"
+
+	"github.com/carwale/golibraries/gologger"
+	"github.com/prometheus/client_golang/prometheus"
+	"go.opentelemetry.io/otel/attribute"
+	semconv "go.opentelemetry.io/otel/semconv/v1.24.0"
+)
+
+// Set with -ldflags "-X github.com/carwale/golibraries/buildinfo.Version=..."
+var (
+	// Version is the version of the service
+	Version = "dev"
+	// Commit is the git commit the service is built from
+	Commit = ""
+	// BuildDate is the time the service is built at, in RFC 3339
+	BuildDate = ""
+)
+
+// Info is the build information of the service
+type Info struct {
+	Version   string `json:"version"`
+	Commit    string `json:"commit"`
+	BuildDate string `json:"build_date"`
+	GoVersion string `json:"go_version"`
+}
+
+var (
+	infoOnce sync.Once
+	info     Info
+)
+
+// Get returns the build information. Unknown values are "unknown"
+func Get() Info {
+	infoOnce.Do(func() {
+		info = Info{Version: Version, Commit: Commit, BuildDate: BuildDate, GoVersion: runtime.Version()}
+		if buildInfo, ok := debug.ReadBuildInfo(); ok {
+			for _, setting := range buildInfo.Settings {
+				switch {
+				case setting.Key == "vcs.revision" && info.Commit == "":
+					info.Commit = setting.Value
+				case setting.Key == "vcs.time" && info.BuildDate == "":
+					info.BuildDate = setting.Value
+				}
+			}
+		}
+		for _, value := range []*string{&info.Version, &info.Commit, &info.BuildDate} {
+			if *value == "" {
+				*value = "unknown"
+			}
+		}
+	})
+	return info
+}
+
+// Pairs returns the build information as log fields
+func (i Info) Pairs() []gologger.Pair {
+	return []gologger.Pair{
+		{Key: "build_version", Value: i.Version},
+		{Key: "build_commit", Value: i.Commit},
+		{Key: "build_date", Value: i.BuildDate},
+		{Key: "build_go_version", Value: i.GoVersion},
+	}
+}
+
+// Attributes returns the build information as OpenTelemetry resource attributes
+func (i Info) Attributes() []attribute.KeyValue {
+	return []attribute.KeyValue{
+		semconv.ServiceVersion(i.Version),
+		attribute.String("service.commit", i.Commit),
+		attribute.String("service.build_date", i.BuildDate),
+	}
+}
+
+const buildInfoMetricID = "BUILD-INFO"
+
+var metricOnce sync.Once
+
+// RegisterMetric adds the build_info gauge to the multi logger. It is always 1, the build information is in
+// its labels. Calling it more than once has no effect
+func RegisterMetric(multiLogger gologger.IMultiLogger, logger gologger.ILogger) {
+	metricOnce.Do(func() {
+		multiLogger.AddNewMetric(buildInfoMetricID, gologger.NewGaugeMetric(prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "build_info",
+				Help: "Build information of the service, the value is always 1",
+			},
+			[]string{"version", "commit", "build_date", "go_version"},
+		), logger))
+		i := Get()
+		multiLogger.SetVal(1, buildInfoMetricID, i.Version, i.Commit, i.BuildDate, i.GoVersion)
+	})
+}
+
+// LogStartup logs the build information, to be called when the service starts
+func LogStartup(logger gologger.ILogger) {
+	logger.LogInfoMessage("Starting "+Get().Version, Get().Pairs()...)
+}
+
+// Handler serves the build information as json, e.g. on /version
+func Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(Get())
+	})
+}
@@ -0,0 +1,20 @@
+package buildinfo
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestHandler(t *testing.T) {
+	recorder := httptest.NewRecorder()
+	Handler().ServeHTTP(recorder, httptest.NewRequest("GET", "/version", nil))
+	var got Info
+	if err := json.Unmarshal(recorder.Body.Bytes(), &got); err != nil {
+		t.Fatal(err)
+	}
+	// the test binary has no ldflags and no vcs stamp
+	if got.Version != "dev" || got.Commit != "unknown" || got.GoVersion == "" {
+		t.Errorf("version = %+v", got)
+	}
+}
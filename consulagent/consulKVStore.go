@@ -14,7 +14,7 @@ type ConsulAgent struct {
 	consulHostName   string
 	consulPortNumber int
 	consulAgent      *api.Client
-	logger           *gologger.CustomLogger
+	logger           gologger.ILogger
 }
 
 // Options sets a parameter for consul agent
@@ -40,7 +40,7 @@ func ConsulPort(portNumber int) Options {
 
 //Logger sets the logger for consul
 //Defaults to consul logger
-func Logger(customLogger *gologger.CustomLogger) Options {
+func Logger(customLogger gologger.ILogger) Options {
 	return func(c *ConsulAgent) { c.logger = customLogger }
 }
 
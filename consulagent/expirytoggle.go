@@ -0,0 +1,80 @@
+package consulagent
+
+import (
+	"context"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/carwale/golibraries/goutilities"
+)
+
+// ExpiryTimeLayout is the layout of the end time stored in toggle keys, e.g. 01/31/2024 18:30:00
+const ExpiryTimeLayout = "01/02/2006 15:04:05"
+
+// ExpiryToggle is enabled while the consul key holds an end time in the future. It is the convention used
+// to turn on verbose logging for a while: the key is set to the time at which the logs should stop.
+// The key is read every interval. Enabled is safe for concurrent use
+type ExpiryToggle struct {
+	key      string
+	interval time.Duration
+	getValue func(key string) []byte
+	now      func() time.Time
+	enabled  atomic.Bool
+	cancel   context.CancelFunc
+}
+
+// NewExpiryToggle reads the key and starts refreshing the toggle every interval until Stop is called
+func NewExpiryToggle(ca *ConsulAgent, key string, interval time.Duration) *ExpiryToggle {
+	t := newExpiryToggle(key, interval, ca.GetValue)
+	t.Refresh()
+	ctx, cancel := context.WithCancel(context.Background())
+	t.cancel = cancel
+	goutilities.GoCtx(ctx, "expiry-toggle "+key, t.run)
+	return t
+}
+
+func newExpiryToggle(key string, interval time.Duration, getValue func(key string) []byte) *ExpiryToggle {
+	return &ExpiryToggle{key: key, interval: interval, getValue: getValue, now: time.Now, cancel: func() {}}
+}
+
+// Enabled returns true if the end time was in the future when the key was last read
+func (t *ExpiryToggle) Enabled() bool {
+	return t.enabled.Load()
+}
+
+// Refresh reads the key. A missing key or a value that is not a valid time disables the toggle
+func (t *ExpiryToggle) Refresh() {
+	t.enabled.Store(isBeforeExpiry(string(t.getValue(t.key)), t.now()))
+}
+
+// Stop stops refreshing the toggle
+func (t *ExpiryToggle) Stop() {
+	t.cancel()
+}
+
+func (t *ExpiryToggle) run(ctx context.Context) {
+	ticker := time.NewTicker(t.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			t.Refresh()
+		}
+	}
+}
+
+// isBeforeExpiry returns true if value is an end time after now
+func isBeforeExpiry(value string, now time.Time) bool {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return false
+	}
+	expiry, err := time.Parse(ExpiryTimeLayout, value)
+	if err != nil {
+		return false
+	}
+	return expiry.After(now)
+}
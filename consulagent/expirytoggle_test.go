@@ -0,0 +1,76 @@
+package consulagent
+
+import (
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestIsBeforeExpiry(t *testing.T) {
+	now := time.Date(2024, 1, 31, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		value string
+		want  bool
+	}{
+		{"", false},
+		{"not a time", false},
+		{"2024-01-31 18:00:00", false},
+		{"01/31/2024 11:59:59", false},
+		{"01/31/2024 12:00:00", false},
+		{"01/31/2024 12:00:01", true},
+		{" 02/01/2024 00:00:00\n", true},
+	}
+	for _, tt := range tests {
+		if got := isBeforeExpiry(tt.value, now); got != tt.want {
+			t.Errorf("isBeforeExpiry(%q) = %v, want %v", tt.value, got, tt.want)
+		}
+	}
+}
+
+func TestExpiryToggleRefresh(t *testing.T) {
+	var value atomic.Value
+	value.Store("")
+	toggle := newExpiryToggle("Monitoring/service/access_logs", time.Minute, func(string) []byte {
+		return []byte(value.Load().(string))
+	})
+	toggle.Refresh()
+	if toggle.Enabled() {
+		t.Fatal("Enabled() = true for a missing key")
+	}
+	value.Store(time.Now().Add(time.Hour).UTC().Format(ExpiryTimeLayout))
+	toggle.Refresh()
+	if !toggle.Enabled() {
+		t.Fatal("Enabled() = false for an end time in the future")
+	}
+	value.Store("invalid")
+	toggle.Refresh()
+	if toggle.Enabled() {
+		t.Fatal("Enabled() = true after the value became invalid")
+	}
+}
+
+func TestExpiryToggleConcurrentUse(t *testing.T) {
+	future := time.Now().Add(time.Hour).UTC().Format(ExpiryTimeLayout)
+	toggle := newExpiryToggle("key", time.Millisecond, func(string) []byte { return []byte(future) })
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 1000; j++ {
+				toggle.Refresh()
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 1000; j++ {
+				toggle.Enabled()
+			}
+		}()
+	}
+	wg.Wait()
+	if !toggle.Enabled() {
+		t.Error("Enabled() = false, want true")
+	}
+}
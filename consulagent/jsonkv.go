@@ -0,0 +1,171 @@
+package consulagent
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/consul/api"
+)
+
+// ErrKeyNotFound is returned when a key does not exist in consul
+var ErrKeyNotFound = errors.New("key not found in consul")
+
+// PutJSON stores the json encoding of v at the key, so that the value stays readable in the consul ui
+func (ca *ConsulAgent) PutJSON(key string, v interface{}) error {
+	value, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Errorf("could not encode value of key %s: %w", key, err)
+	}
+	if _, err = ca.consulAgent.KV().Put(&api.KVPair{Key: key, Value: value}, nil); err != nil {
+		ca.logger.LogError("Error creating kv pair with key "+key, err)
+		return err
+	}
+	return nil
+}
+
+// GetJSON decodes the json value of the key into v. It returns ErrKeyNotFound if the key does not exist
+func (ca *ConsulAgent) GetJSON(key string, v interface{}) error {
+	pair, _, err := ca.consulAgent.KV().Get(key, nil)
+	if err != nil {
+		ca.logger.LogError("Error getting value for key "+key, err)
+		return err
+	}
+	if pair == nil {
+		return fmt.Errorf("%w: %s", ErrKeyNotFound, key)
+	}
+	if err = json.Unmarshal(pair.Value, v); err != nil {
+		return fmt.Errorf("could not decode value of key %s: %w", key, err)
+	}
+	return nil
+}
+
+// GetStruct maps the keys under the prefix to the fields of the struct pointed to by v.
+// The key prefix/db/host is set on the field Host of the struct field DB. The name of a field is taken
+// from its consul tag, or matched case insensitively if there is no tag. A tag of "-" skips the field.
+// Strings, bools, numbers and durations are parsed from their text, other fields are decoded from json.
+// Keys without a matching field are ignored
+func (ca *ConsulAgent) GetStruct(prefix string, v interface{}) error {
+	pairs, _, err := ca.consulAgent.KV().List(prefix, nil)
+	if err != nil {
+		ca.logger.LogError("Error getting keys for prefix "+prefix, err)
+		return err
+	}
+	values := make(map[string][]byte, len(pairs))
+	for _, pair := range pairs {
+		values[strings.TrimPrefix(strings.TrimPrefix(pair.Key, prefix), "/")] = pair.Value
+	}
+	return decodeKVPairs(values, v)
+}
+
+// decodeKVPairs sets the fields of the struct pointed to by v from the values keyed by their relative path
+func decodeKVPairs(values map[string][]byte, v interface{}) error {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() || rv.Elem().Kind() != reflect.Struct {
+		return errors.New("value must be a non nil pointer to a struct")
+	}
+	for key, value := range values {
+		if key == "" || strings.HasSuffix(key, "/") {
+			continue
+		}
+		field, ok := lookupField(rv.Elem(), strings.Split(key, "/"))
+		if !ok {
+			continue
+		}
+		if err := setField(field, value); err != nil {
+			return fmt.Errorf("could not set key %s: %w", key, err)
+		}
+	}
+	return nil
+}
+
+// lookupField returns the field at the path, allocating nil struct pointers on the way
+func lookupField(rv reflect.Value, path []string) (reflect.Value, bool) {
+	for i, name := range path {
+		if rv.Kind() == reflect.Ptr {
+			if rv.IsNil() {
+				rv.Set(reflect.New(rv.Type().Elem()))
+			}
+			rv = rv.Elem()
+		}
+		if rv.Kind() != reflect.Struct {
+			return rv, false
+		}
+		field, ok := structField(rv, name)
+		if !ok {
+			return rv, false
+		}
+		rv = field
+		if i == len(path)-1 {
+			return rv, true
+		}
+	}
+	return rv, false
+}
+
+func structField(rv reflect.Value, name string) (reflect.Value, bool) {
+	t := rv.Type()
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if field.PkgPath != "" {
+			continue
+		}
+		tag := field.Tag.Get("consul")
+		if tag == "-" {
+			continue
+		}
+		if tag == name || (tag == "" && strings.EqualFold(field.Name, name)) {
+			return rv.Field(i), true
+		}
+	}
+	return rv, false
+}
+
+var durationType = reflect.TypeOf(time.Duration(0))
+
+func setField(field reflect.Value, value []byte) error {
+	text := strings.TrimSpace(string(value))
+	if field.Type() == durationType {
+		d, err := time.ParseDuration(text)
+		if err != nil {
+			return err
+		}
+		field.SetInt(int64(d))
+		return nil
+	}
+	switch field.Kind() {
+	case reflect.String:
+		field.SetString(string(value))
+	case reflect.Bool:
+		b, err := strconv.ParseBool(text)
+		if err != nil {
+			return err
+		}
+		field.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		i, err := strconv.ParseInt(text, 10, field.Type().Bits())
+		if err != nil {
+			return err
+		}
+		field.SetInt(i)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		u, err := strconv.ParseUint(text, 10, field.Type().Bits())
+		if err != nil {
+			return err
+		}
+		field.SetUint(u)
+	case reflect.Float32, reflect.Float64:
+		f, err := strconv.ParseFloat(text, field.Type().Bits())
+		if err != nil {
+			return err
+		}
+		field.SetFloat(f)
+	default:
+		return json.Unmarshal(value, field.Addr().Interface())
+	}
+	return nil
+}
@@ -0,0 +1,65 @@
+package consulagent
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+type testDBConfig struct {
+	Host    string
+	Port    int
+	Timeout time.Duration
+}
+
+type testConfig struct {
+	Name     string        `consul:"name"`
+	Enabled  bool          `consul:"enabled"`
+	Ratio    float64       `consul:"ratio"`
+	Servers  []string      `consul:"servers"`
+	DB       testDBConfig  `consul:"db"`
+	Cache    *testDBConfig `consul:"cache"`
+	Secret   string        `consul:"-"`
+	internal string
+}
+
+func TestDecodeKVPairs(t *testing.T) {
+	values := map[string][]byte{
+		"name":        []byte("stock"),
+		"enabled":     []byte("true"),
+		"ratio":       []byte("0.25"),
+		"servers":     []byte(`["a","b"]`),
+		"db/":         nil,
+		"db/host":     []byte("db.local"),
+		"db/port":     []byte("5432"),
+		"db/timeout":  []byte("2s"),
+		"cache/host":  []byte("cache.local"),
+		"unknown/key": []byte("ignored"),
+		"Secret":      []byte("ignored"),
+	}
+	var config testConfig
+	if err := decodeKVPairs(values, &config); err != nil {
+		t.Fatal(err)
+	}
+	want := testConfig{
+		Name:    "stock",
+		Enabled: true,
+		Ratio:   0.25,
+		Servers: []string{"a", "b"},
+		DB:      testDBConfig{Host: "db.local", Port: 5432, Timeout: 2 * time.Second},
+		Cache:   &testDBConfig{Host: "cache.local"},
+	}
+	if !reflect.DeepEqual(config, want) {
+		t.Errorf("decodeKVPairs() = %+v, want %+v", config, want)
+	}
+}
+
+func TestDecodeKVPairsErrors(t *testing.T) {
+	var config testConfig
+	if err := decodeKVPairs(map[string][]byte{"db/port": []byte("abc")}, &config); err == nil {
+		t.Error("decodeKVPairs() should return an error for an invalid int")
+	}
+	if err := decodeKVPairs(nil, config); err == nil {
+		t.Error("decodeKVPairs() should return an error for a non pointer")
+	}
+}
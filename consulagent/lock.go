@@ -0,0 +1,54 @@
+package consulagent
+
+import (
+	"context"
+	"sync"
+
+	"github.com/carwale/golibraries/goutilities"
+	"github.com/hashicorp/consul/api"
+)
+
+// lockSessionTTL is the TTL of the sessions holding the locks. The session is renewed while the lock is held,
+// so a lock is released within the TTL after its holder dies
+const lockSessionTTL = "15s"
+
+// TryLock takes the lock of the key if no other session holds it, without waiting. The lock is held by a
+// consul session renewed in the background until unlock is called; if the holder dies consul deletes the
+// key when the session expires. lockCtx is derived from ctx and is cancelled when the lock is released or
+// lost because the session could not be renewed, so the work done under the lock should use it.
+// It implements the locker of the scheduler package
+func (ca *ConsulAgent) TryLock(ctx context.Context, key string) (lockCtx context.Context, unlock func(), acquired bool, err error) {
+	session := ca.consulAgent.Session()
+	writeOptions := (&api.WriteOptions{}).WithContext(ctx)
+	sessionID, _, err := session.Create(&api.SessionEntry{
+		Name:     "lock " + key,
+		TTL:      lockSessionTTL,
+		Behavior: api.SessionBehaviorDelete,
+	}, writeOptions)
+	if err != nil {
+		return nil, nil, false, err
+	}
+	acquired, _, err = ca.consulAgent.KV().Acquire(&api.KVPair{Key: key, Session: sessionID}, writeOptions)
+	if err != nil || !acquired {
+		session.Destroy(sessionID, nil)
+		return nil, nil, false, err
+	}
+
+	lockCtx, cancel := context.WithCancel(ctx)
+	done := make(chan struct{})
+	goutilities.Go("consulagent-lock", func() {
+		defer cancel()
+		if err := session.RenewPeriodic(lockSessionTTL, sessionID, nil, done); err != nil {
+			ca.logger.LogError("Lost the consul session of the lock "+key, err)
+		}
+	})
+	var once sync.Once
+	unlock = func() {
+		// RenewPeriodic destroys the session when done is closed, which deletes the key
+		once.Do(func() {
+			cancel()
+			close(done)
+		})
+	}
+	return lockCtx, unlock, true, nil
+}
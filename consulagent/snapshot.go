@@ -0,0 +1,202 @@
+package consulagent
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/hashicorp/consul/api"
+)
+
+// Snapshot holds the key value pairs under a prefix. Keys are relative to the prefix and sorted,
+// so that the snapshot of the same values is always encoded to the same json and can be
+// imported under another prefix (e.g. to promote config between namespaces)
+type Snapshot struct {
+	Prefix  string          `json:"prefix"`
+	Entries []SnapshotEntry `json:"entries"`
+}
+
+// SnapshotEntry is a key value pair of a snapshot. Values that are valid utf-8 are kept as text to
+// make snapshots reviewable, other values are base64 encoded
+type SnapshotEntry struct {
+	Key         string `json:"key"`
+	Value       string `json:"value,omitempty"`
+	ValueBase64 string `json:"value_base64,omitempty"`
+	Flags       uint64 `json:"flags,omitempty"`
+}
+
+// ImportOptions controls ImportSnapshot
+type ImportOptions struct {
+	// DryRun only computes the diff without changing consul
+	DryRun bool
+	// DeleteMissing deletes keys under the prefix which are not in the snapshot
+	DeleteMissing bool
+}
+
+// SnapshotDiff lists the keys, relative to the prefix, that are changed by an import
+type SnapshotDiff struct {
+	Added     []string `json:"added"`
+	Changed   []string `json:"changed"`
+	Deleted   []string `json:"deleted"`
+	Unchanged int      `json:"unchanged"`
+}
+
+// IsEmpty returns true if the import does not change anything
+func (d *SnapshotDiff) IsEmpty() bool {
+	return len(d.Added) == 0 && len(d.Changed) == 0 && len(d.Deleted) == 0
+}
+
+// String returns the diff in a format suitable for review
+func (d *SnapshotDiff) String() string {
+	var b strings.Builder
+	for _, key := range d.Added {
+		b.WriteString("+ " + key + "\n")
+	}
+	for _, key := range d.Changed {
+		b.WriteString("~ " + key + "\n")
+	}
+	for _, key := range d.Deleted {
+		b.WriteString("- " + key + "\n")
+	}
+	fmt.Fprintf(&b, "%d added, %d changed, %d deleted, %d unchanged", len(d.Added), len(d.Changed), len(d.Deleted), d.Unchanged)
+	return b.String()
+}
+
+// normalizePrefix adds the trailing slash to a prefix which has none, so that the prefix "ns/app" does not
+// also match the keys of "ns/app2"
+func normalizePrefix(prefix string) string {
+	if prefix == "" || strings.HasSuffix(prefix, "/") {
+		return prefix
+	}
+	return prefix + "/"
+}
+
+// SnapshotPrefix returns a snapshot of the key value pairs under the prefix. The prefix is a folder,
+// a trailing slash is added if it has none
+func (ca *ConsulAgent) SnapshotPrefix(prefix string) (*Snapshot, error) {
+	prefix = normalizePrefix(prefix)
+	pairs, _, err := ca.consulAgent.KV().List(prefix, nil)
+	if err != nil {
+		ca.logger.LogError("Error getting keys for prefix "+prefix, err)
+		return nil, err
+	}
+	snapshot := &Snapshot{Prefix: prefix, Entries: make([]SnapshotEntry, 0, len(pairs))}
+	for _, pair := range pairs {
+		// Folders have no value and are created implicitly by their keys
+		if strings.HasSuffix(pair.Key, "/") && len(pair.Value) == 0 {
+			continue
+		}
+		entry := SnapshotEntry{Key: strings.TrimPrefix(pair.Key, prefix), Flags: pair.Flags}
+		entry.setValue(pair.Value)
+		snapshot.Entries = append(snapshot.Entries, entry)
+	}
+	sort.Slice(snapshot.Entries, func(i, j int) bool { return snapshot.Entries[i].Key < snapshot.Entries[j].Key })
+	return snapshot, nil
+}
+
+// ExportPrefix returns the snapshot of the prefix encoded as indented json
+func (ca *ConsulAgent) ExportPrefix(prefix string) ([]byte, error) {
+	snapshot, err := ca.SnapshotPrefix(prefix)
+	if err != nil {
+		return nil, err
+	}
+	return json.MarshalIndent(snapshot, "", "  ")
+}
+
+// ImportSnapshot writes the entries of the snapshot under the prefix, which may be different from the
+// prefix the snapshot was taken from. It returns the diff between consul and the snapshot.
+// With DryRun nothing is written. The import stops at the first error. Like in SnapshotPrefix, the prefix
+// is a folder, so DeleteMissing never deletes the keys of a sibling prefix
+func (ca *ConsulAgent) ImportSnapshot(snapshot *Snapshot, prefix string, options ImportOptions) (*SnapshotDiff, error) {
+	prefix = normalizePrefix(prefix)
+	current, err := ca.SnapshotPrefix(prefix)
+	if err != nil {
+		return nil, err
+	}
+	diff, err := diffSnapshots(current, snapshot, options.DeleteMissing)
+	if err != nil || options.DryRun {
+		return diff, err
+	}
+	entries := make(map[string]SnapshotEntry, len(snapshot.Entries))
+	for _, entry := range snapshot.Entries {
+		entries[entry.Key] = entry
+	}
+	for _, key := range append(append([]string{}, diff.Added...), diff.Changed...) {
+		entry := entries[key]
+		value, _ := entry.value()
+		if _, err := ca.consulAgent.KV().Put(&api.KVPair{Key: prefix + key, Value: value, Flags: entry.Flags}, nil); err != nil {
+			ca.logger.LogError("Error creating kv pair with key "+prefix+key, err)
+			return diff, err
+		}
+	}
+	for _, key := range diff.Deleted {
+		if _, err := ca.consulAgent.KV().Delete(prefix+key, nil); err != nil {
+			ca.logger.LogError("Error deleting key "+prefix+key, err)
+			return diff, err
+		}
+	}
+	return diff, nil
+}
+
+// ParseSnapshot decodes a snapshot returned by ExportPrefix
+func ParseSnapshot(data []byte) (*Snapshot, error) {
+	snapshot := &Snapshot{}
+	if err := json.Unmarshal(data, snapshot); err != nil {
+		return nil, err
+	}
+	return snapshot, nil
+}
+
+func (e *SnapshotEntry) setValue(value []byte) {
+	if utf8.Valid(value) {
+		e.Value = string(value)
+		return
+	}
+	e.ValueBase64 = base64.StdEncoding.EncodeToString(value)
+}
+
+func (e *SnapshotEntry) value() ([]byte, error) {
+	if e.ValueBase64 != "" {
+		return base64.StdEncoding.DecodeString(e.ValueBase64)
+	}
+	return []byte(e.Value), nil
+}
+
+// diffSnapshots returns the changes needed to make current equal to desired
+func diffSnapshots(current, desired *Snapshot, deleteMissing bool) (*SnapshotDiff, error) {
+	existing := make(map[string]SnapshotEntry, len(current.Entries))
+	for _, entry := range current.Entries {
+		existing[entry.Key] = entry
+	}
+	diff := &SnapshotDiff{}
+	seen := make(map[string]bool, len(desired.Entries))
+	for _, entry := range desired.Entries {
+		if _, err := entry.value(); err != nil {
+			return nil, fmt.Errorf("invalid value of key %s: %w", entry.Key, err)
+		}
+		seen[entry.Key] = true
+		old, ok := existing[entry.Key]
+		switch {
+		case !ok:
+			diff.Added = append(diff.Added, entry.Key)
+		case old != entry:
+			diff.Changed = append(diff.Changed, entry.Key)
+		default:
+			diff.Unchanged++
+		}
+	}
+	if deleteMissing {
+		for _, entry := range current.Entries {
+			if !seen[entry.Key] {
+				diff.Deleted = append(diff.Deleted, entry.Key)
+			}
+		}
+	}
+	sort.Strings(diff.Added)
+	sort.Strings(diff.Changed)
+	sort.Strings(diff.Deleted)
+	return diff, nil
+}
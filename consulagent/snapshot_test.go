@@ -0,0 +1,64 @@
+package consulagent
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestDiffSnapshots(t *testing.T) {
+	current := &Snapshot{Entries: []SnapshotEntry{
+		{Key: "a", Value: "1"},
+		{Key: "b", Value: "2"},
+		{Key: "c", Value: "3"},
+	}}
+	desired := &Snapshot{Entries: []SnapshotEntry{
+		{Key: "a", Value: "1"},
+		{Key: "b", Value: "20"},
+		{Key: "d", Value: "4"},
+	}}
+
+	diff, err := diffSnapshots(current, desired, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := &SnapshotDiff{Added: []string{"d"}, Changed: []string{"b"}, Unchanged: 1}
+	if !reflect.DeepEqual(diff, want) {
+		t.Errorf("diffSnapshots() = %+v, want %+v", diff, want)
+	}
+
+	diff, _ = diffSnapshots(current, desired, true)
+	if !reflect.DeepEqual(diff.Deleted, []string{"c"}) {
+		t.Errorf("diffSnapshots() deleted = %v, want [c]", diff.Deleted)
+	}
+}
+
+func TestSnapshotEntryValue(t *testing.T) {
+	for _, value := range [][]byte{[]byte("text"), {0xff, 0x00, 0xfe}} {
+		entry := SnapshotEntry{}
+		entry.setValue(value)
+		decoded, err := entry.value()
+		if err != nil || !reflect.DeepEqual(decoded, value) {
+			t.Errorf("value() = %v, %v, want %v", decoded, err, value)
+		}
+	}
+	entry := SnapshotEntry{}
+	entry.setValue([]byte{0xff})
+	if entry.Value != "" || entry.ValueBase64 == "" {
+		t.Errorf("binary value is not base64 encoded: %+v", entry)
+	}
+}
+
+func TestDiffSnapshotsInvalidValue(t *testing.T) {
+	desired := &Snapshot{Entries: []SnapshotEntry{{Key: "a", ValueBase64: "not base64!"}}}
+	if _, err := diffSnapshots(&Snapshot{}, desired, false); err == nil {
+		t.Error("diffSnapshots() should return an error for an invalid base64 value")
+	}
+}
+
+func TestNormalizePrefix(t *testing.T) {
+	for prefix, want := range map[string]string{"ns/app": "ns/app/", "ns/app/": "ns/app/", "": ""} {
+		if got := normalizePrefix(prefix); got != want {
+			t.Errorf("normalizePrefix(%q) = %q, want %q", prefix, got, want)
+		}
+	}
+}
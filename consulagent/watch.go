@@ -0,0 +1,45 @@
+package consulagent
+
+import (
+	"context"
+	"time"
+
+	"github.com/hashicorp/consul/api"
+)
+
+// WatchPrefix calls handler with the key value pairs under the prefix, first with the current pairs and then
+// every time they change. It uses consul blocking queries, so changes are seen within a second instead of
+// waiting for the next poll. It blocks until ctx is done. Errors are logged and the query is retried after retryWait
+func (ca *ConsulAgent) WatchPrefix(ctx context.Context, prefix string, retryWait time.Duration, handler func(map[string][]byte)) {
+	var waitIndex uint64
+	for {
+		opts := (&api.QueryOptions{WaitIndex: waitIndex, WaitTime: 5 * time.Minute}).WithContext(ctx)
+		pairs, meta, err := ca.consulAgent.KV().List(prefix, opts)
+		if ctx.Err() != nil {
+			return
+		}
+		if err != nil {
+			ca.logger.LogError("Error watching keys for prefix "+prefix, err)
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(retryWait):
+			}
+			continue
+		}
+		// The index can go backwards when consul restores a snapshot, in which case the watch starts again
+		if meta.LastIndex < waitIndex {
+			waitIndex = 0
+			continue
+		}
+		if meta.LastIndex == waitIndex {
+			continue
+		}
+		waitIndex = meta.LastIndex
+		values := make(map[string][]byte, len(pairs))
+		for _, pair := range pairs {
+			values[pair.Key] = pair.Value
+		}
+		handler(values)
+	}
+}
@@ -0,0 +1,168 @@
+// Package crashreport reports panics with a structured crash report: the stack of the panicking goroutine,
+// a dump of all the goroutines and the last lines logged before the panic. The buffers of the async
+// log and trace writers are flushed after the report, so that they are not lost when the process dies.
+//
+//	reporter := crashreport.NewReporter(crashreport.SetLogger(logger),
+//		crashreport.AddFlusher("loki", func(ctx context.Context) error { lokiClient.Close(); return nil }))
+//	defer reporter.Recover("main")
+package crashreport
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"runtime"
+	"runtime/debug"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/carwale/golibraries/gologger"
+)
+
+// maxGoroutineDump is the size at which the goroutine dump is truncated
+const maxGoroutineDump = 1 << 20
+
+type flusher struct {
+	name  string
+	flush func(context.Context) error
+}
+
+// Reporter logs crash reports and flushes the registered buffers. It is safe for concurrent use
+type Reporter struct {
+	logger        gologger.ILogger
+	recentLogs    func() []string
+	flushers      []flusher
+	flushTimeout  time.Duration
+	goroutineDump bool
+	exit          func(code int)
+	flushLock     sync.Mutex
+}
+
+// Option sets the options of the reporter
+type Option func(r *Reporter)
+
+// SetLogger sets the logger the crash reports are written to
+func SetLogger(logger gologger.ILogger) Option {
+	return func(r *Reporter) { r.logger = logger }
+}
+
+// SetRecentLogs sets the function returning the last log lines to include in the crash report,
+// e.g. the RecentLogs method of a logger created with gologger.WithRingBuffer
+func SetRecentLogs(recentLogs func() []string) Option {
+	return func(r *Reporter) { r.recentLogs = recentLogs }
+}
+
+// AddFlusher adds a function flushing an async buffer after a crash is reported, e.g. the Close of a loki
+// client or the ForceFlush of a tracer provider. Flushers are called in the order they were added
+func AddFlusher(name string, flush func(context.Context) error) Option {
+	return func(r *Reporter) { r.flushers = append(r.flushers, flusher{name: name, flush: flush}) }
+}
+
+// SetFlushTimeout sets the time the flushers are given in total. Defaults to 5 seconds
+func SetFlushTimeout(timeout time.Duration) Option {
+	return func(r *Reporter) {
+		if timeout > 0 {
+			r.flushTimeout = timeout
+		}
+	}
+}
+
+// SetGoroutineDump sets whether the stacks of all the goroutines are included in the report. Defaults to true
+func SetGoroutineDump(flag bool) Option {
+	return func(r *Reporter) { r.goroutineDump = flag }
+}
+
+// NewReporter returns a reporter
+func NewReporter(options ...Option) *Reporter {
+	r := &Reporter{
+		flushTimeout:  5 * time.Second,
+		goroutineDump: true,
+		exit:          os.Exit,
+	}
+	for _, option := range options {
+		option(r)
+	}
+	if r.logger == nil {
+		r.logger = gologger.NewLogger()
+	}
+	return r
+}
+
+// Report logs the crash report of the panic value recovered in component and flushes the buffers.
+// It does not recover the panic, so it can be called from any recover block
+func (r *Reporter) Report(component string, value interface{}) {
+	r.logger.LogErrorMessage("Panic in "+component, fmt.Errorf("%v", value), r.reportPairs(component)...)
+	r.Flush()
+}
+
+// reportPairs returns the fields of the crash report
+func (r *Reporter) reportPairs(component string) []gologger.Pair {
+	pairs := []gologger.Pair{
+		{Key: "component", Value: component},
+		{Key: "stack_trace", Value: string(debug.Stack())},
+	}
+	if r.goroutineDump {
+		pairs = append(pairs, gologger.Pair{Key: "goroutines", Value: goroutineDump()})
+	}
+	if r.recentLogs != nil {
+		pairs = append(pairs, gologger.Pair{Key: "recent_logs", Value: strings.Join(r.recentLogs(), "\n")})
+	}
+	return pairs
+}
+
+// Flush calls the flushers. Concurrent crashes flush one after the other
+func (r *Reporter) Flush() {
+	r.flushLock.Lock()
+	defer r.flushLock.Unlock()
+	ctx, cancel := context.WithTimeout(context.Background(), r.flushTimeout)
+	defer cancel()
+	for _, f := range r.flushers {
+		if err := f.flush(ctx); err != nil {
+			r.logger.LogError("could not flush "+f.name+" after panic", err)
+		}
+	}
+}
+
+// Recover reports a panic and panics again with the same value, so that the process still crashes.
+// It must be deferred directly
+//
+//	defer reporter.Recover("consumer")
+func (r *Reporter) Recover(component string) {
+	if value := recover(); value != nil {
+		r.Report(component, value)
+		panic(value)
+	}
+}
+
+// RecoverAndExit reports a panic and exits the process with status 2, the status of an unrecovered panic.
+// It must be deferred directly
+func (r *Reporter) RecoverAndExit(component string) {
+	if value := recover(); value != nil {
+		r.Report(component, value)
+		r.exit(2)
+	}
+}
+
+// Go runs f in a new goroutine. A panic in f is reported and the process exits
+func (r *Reporter) Go(component string, f func()) {
+	go func() {
+		defer r.RecoverAndExit(component)
+		f()
+	}()
+}
+
+// goroutineDump returns the stacks of all the goroutines, truncated to maxGoroutineDump bytes
+func goroutineDump() string {
+	buf := make([]byte, 64<<10)
+	for {
+		n := runtime.Stack(buf, true)
+		if n < len(buf) {
+			return string(buf[:n])
+		}
+		if len(buf) >= maxGoroutineDump {
+			return string(buf[:n]) + "\n... truncated"
+		}
+		buf = make([]byte, 2*len(buf))
+	}
+}
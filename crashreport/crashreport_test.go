@@ -0,0 +1,82 @@
+package crashreport
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func newTestReporter(flushed *[]string) *Reporter {
+	return NewReporter(
+		SetRecentLogs(func() []string { return []string{"line one", "line two"} }),
+		AddFlusher("first", func(context.Context) error { *flushed = append(*flushed, "first"); return nil }),
+		AddFlusher("second", func(context.Context) error { *flushed = append(*flushed, "second"); return nil }),
+	)
+}
+
+func TestReportPairs(t *testing.T) {
+	var flushed []string
+	r := newTestReporter(&flushed)
+	fields := map[string]string{}
+	for _, pair := range r.reportPairs("worker") {
+		fields[pair.Key] = pair.Value
+	}
+	if fields["component"] != "worker" || fields["recent_logs"] != "line one\nline two" {
+		t.Errorf("fields = %v", fields)
+	}
+	if !strings.Contains(fields["stack_trace"], "TestReportPairs") {
+		t.Errorf("stack trace does not contain the caller: %s", fields["stack_trace"])
+	}
+	if !strings.Contains(fields["goroutines"], "goroutine ") {
+		t.Errorf("goroutine dump is missing: %s", fields["goroutines"])
+	}
+}
+
+func TestRecoverReportsAndPanicsAgain(t *testing.T) {
+	var flushed []string
+	r := newTestReporter(&flushed)
+	defer func() {
+		if value := recover(); value != "boom" {
+			t.Errorf("recovered %v, want boom", value)
+		}
+		if len(flushed) != 2 || flushed[0] != "first" || flushed[1] != "second" {
+			t.Errorf("flushed = %v, want [first second]", flushed)
+		}
+	}()
+	func() {
+		defer r.Recover("worker")
+		panic("boom")
+	}()
+}
+
+func TestRecoverAndExit(t *testing.T) {
+	var flushed []string
+	r := newTestReporter(&flushed)
+	code := -1
+	r.exit = func(c int) { code = c }
+	func() {
+		defer r.RecoverAndExit("main")
+		panic("boom")
+	}()
+	if code != 2 {
+		t.Errorf("exit code = %d, want 2", code)
+	}
+}
+
+func TestHTTPMiddleware(t *testing.T) {
+	var flushed []string
+	r := newTestReporter(&flushed)
+	handler := r.HTTPMiddleware(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		panic("boom")
+	}))
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/stocks", nil))
+	if rec.Code != http.StatusInternalServerError {
+		t.Errorf("status = %d, want 500", rec.Code)
+	}
+	if len(flushed) != 2 {
+		t.Errorf("flushed = %v, want both flushers", flushed)
+	}
+}
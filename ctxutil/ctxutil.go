@@ -0,0 +1,98 @@
+// Package ctxutil has typed helpers to keep request scoped values in a context.
+// Every value has its own unexported key type so that the values cannot collide with the keys of other packages
+package ctxutil
+
+import "context"
+
+// Header and gRPC metadata names used to propagate the values between services
+const (
+	RequestIDHeader = "X-Request-ID"
+	UserIDHeader    = "X-User-ID"
+	TenantIDHeader  = "X-Tenant-ID"
+)
+
+// Log keys of the values. These are the keys used by gologger and httplogs
+const (
+	RequestIDLogKey = "requestuid"
+	UserIDLogKey    = "userid"
+	TenantIDLogKey  = "tenantid"
+)
+
+type requestIDKey struct{}
+
+type userIDKey struct{}
+
+type tenantIDKey struct{}
+
+// WithRequestID returns a copy of ctx with the request ID
+func WithRequestID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, requestIDKey{}, id)
+}
+
+// RequestIDFrom returns the request ID of the context or an empty string
+func RequestIDFrom(ctx context.Context) string {
+	return stringFrom(ctx, requestIDKey{})
+}
+
+// WithUserID returns a copy of ctx with the ID of the user making the request
+func WithUserID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, userIDKey{}, id)
+}
+
+// UserIDFrom returns the user ID of the context or an empty string
+func UserIDFrom(ctx context.Context) string {
+	return stringFrom(ctx, userIDKey{})
+}
+
+// WithTenantID returns a copy of ctx with the tenant of the request
+func WithTenantID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, tenantIDKey{}, id)
+}
+
+// TenantIDFrom returns the tenant ID of the context or an empty string
+func TenantIDFrom(ctx context.Context) string {
+	return stringFrom(ctx, tenantIDKey{})
+}
+
+// Fields returns the non empty values of the context keyed by their log keys.
+// It returns nil if the context has none of the values
+func Fields(ctx context.Context) map[string]string {
+	var fields map[string]string
+	add := func(key, value string) {
+		if value == "" {
+			return
+		}
+		if fields == nil {
+			fields = make(map[string]string, 3)
+		}
+		fields[key] = value
+	}
+	add(RequestIDLogKey, RequestIDFrom(ctx))
+	add(UserIDLogKey, UserIDFrom(ctx))
+	add(TenantIDLogKey, TenantIDFrom(ctx))
+	return fields
+}
+
+// Detach returns a new background context with the values of ctx. It is used to hand the values
+// to background work that should not be cancelled with the request
+func Detach(ctx context.Context) context.Context {
+	detached := context.Background()
+	if id := RequestIDFrom(ctx); id != "" {
+		detached = WithRequestID(detached, id)
+	}
+	if id := UserIDFrom(ctx); id != "" {
+		detached = WithUserID(detached, id)
+	}
+	if id := TenantIDFrom(ctx); id != "" {
+		detached = WithTenantID(detached, id)
+	}
+	return detached
+}
+
+func stringFrom(ctx context.Context, key interface{}) string {
+	if ctx == nil {
+		return ""
+	}
+	value, _ := ctx.Value(key).(string)
+	return value
+}
@@ -0,0 +1,47 @@
+package ctxutil
+
+import (
+	"context"
+	"reflect"
+	"testing"
+)
+
+type collidingKey string
+
+func TestValuesDoNotCollide(t *testing.T) {
+	ctx := context.WithValue(context.Background(), collidingKey("requestuid"), "other")
+	ctx = WithRequestID(ctx, "req-1")
+	ctx = WithUserID(ctx, "user-1")
+
+	if got := RequestIDFrom(ctx); got != "req-1" {
+		t.Errorf("RequestIDFrom() = %q, want req-1", got)
+	}
+	if got := UserIDFrom(ctx); got != "user-1" {
+		t.Errorf("UserIDFrom() = %q, want user-1", got)
+	}
+	if got := TenantIDFrom(ctx); got != "" {
+		t.Errorf("TenantIDFrom() = %q, want empty", got)
+	}
+	if got := RequestIDFrom(nil); got != "" {
+		t.Errorf("RequestIDFrom(nil) = %q, want empty", got)
+	}
+}
+
+func TestFieldsAndDetach(t *testing.T) {
+	if got := Fields(context.Background()); got != nil {
+		t.Errorf("Fields() = %v, want nil", got)
+	}
+	ctx, cancel := context.WithCancel(WithTenantID(WithRequestID(context.Background(), "req-1"), "tenant-1"))
+	cancel()
+	want := map[string]string{RequestIDLogKey: "req-1", TenantIDLogKey: "tenant-1"}
+	if got := Fields(ctx); !reflect.DeepEqual(got, want) {
+		t.Errorf("Fields() = %v, want %v", got, want)
+	}
+	detached := Detach(ctx)
+	if detached.Err() != nil {
+		t.Errorf("Detach() context is cancelled")
+	}
+	if got := Fields(detached); !reflect.DeepEqual(got, want) {
+		t.Errorf("Fields(Detach()) = %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,253 @@
+// Package dbutil instruments database/sql with the metrics, traces and logs we have for kafka and rabbitmq.
+// The driver of the database is wrapped so that every query records its latency and errors, and a span with the
+// sanitized statement when a tracer is set
+//
+//	db, err := dbutil.Open("mysql", dsn, dbutil.SetName("stock"), dbutil.SetSystem("mysql"),
+//		dbutil.SetTracer(tracer), dbutil.SetLogger(logger))
+//	stop := dbutil.WatchPool(db, "stock", 15*time.Second, dbutil.SetLogger(logger))
+//	defer stop()
+package dbutil
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/carwale/golibraries/gologger"
+	"github.com/carwale/golibraries/gotracer"
+	"github.com/carwale/golibraries/goutilities"
+	"github.com/prometheus/client_golang/prometheus"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const tracerName = "github.com/carwale/golibraries/dbutil"
+
+const (
+	queryLatencyMetricID    = "DB-QUERY-LATENCY"
+	queryErrorsMetricID     = "DB-QUERY-ERRORS"
+	poolConnectionsMetricID = "DB-POOL-CONNECTIONS"
+	poolWaitsMetricID       = "DB-POOL-WAITS"
+	poolWaitTimeMetricID    = "DB-POOL-WAIT-DURATION"
+)
+
+var dbutilMetrics = gologger.NewMetricSet(func(logger gologger.ILogger) map[string]gologger.IMetricVec {
+	return map[string]gologger.IMetricVec{
+		queryLatencyMetricID: gologger.NewHistogramMetric(prometheus.NewHistogramVec(
+			prometheus.HistogramOpts{
+				Name:    "db_query_latency_milliseconds",
+				Help:    "Latency of the database queries by operation (select, insert, commit...)",
+				Buckets: prometheus.ExponentialBuckets(0.5, 2, 15),
+			},
+			[]string{"DB", "Operation"},
+		), logger),
+		queryErrorsMetricID: gologger.NewCounterMetric(prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "db_query_errors_total",
+				Help: "Number of failed database queries by operation",
+			},
+			[]string{"DB", "Operation"},
+		), logger),
+		poolConnectionsMetricID: gologger.NewGaugeMetric(prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "db_pool_connections",
+				Help: "Number of connections of the database pool by state (open, in_use, idle)",
+			},
+			[]string{"DB", "State"},
+		), logger),
+		poolWaitsMetricID: gologger.NewGaugeMetric(prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "db_pool_waits",
+				Help: "Number of times a query waited for a connection of the database pool since the pool was opened",
+			},
+			[]string{"DB"},
+		), logger),
+		poolWaitTimeMetricID: gologger.NewGaugeMetric(prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "db_pool_wait_duration_milliseconds",
+				Help: "Time spent waiting for a connection of the database pool since the pool was opened",
+			},
+			[]string{"DB"},
+		), logger),
+	}
+})
+
+// Option sets a parameter of the instrumentation
+type Option func(o *observer)
+
+// SetName sets the name of the database, used as the DB label of the metrics and in the spans. Default is db
+func SetName(name string) Option {
+	return func(o *observer) { o.name = name }
+}
+
+// SetSystem sets the db.system attribute of the spans, e.g. mysql or postgresql. Default is other_sql
+func SetSystem(system string) Option {
+	return func(o *observer) { o.system = system }
+}
+
+// SetTracer enables a client span for every query, with the sanitized statement as the db.statement attribute
+func SetTracer(tracer *gotracer.CustomTracer) Option {
+	return func(o *observer) {
+		if tracer == nil {
+			return
+		}
+		var provider trace.TracerProvider = otel.GetTracerProvider()
+		if tracer.GetTracerProvider() != nil {
+			provider = tracer.GetTracerProvider()
+		}
+		o.tracer = provider.Tracer(tracerName)
+	}
+}
+
+// SetSlowQueryThreshold logs the queries taking longer than threshold as warnings with their sanitized statement.
+// Default is 0, slow queries are not logged
+func SetSlowQueryThreshold(threshold time.Duration) Option {
+	return func(o *observer) { o.slowQuery = threshold }
+}
+
+// SetLogger sets the logger of the instrumentation
+func SetLogger(logger gologger.ILogger) Option {
+	return func(o *observer) { o.logger = logger }
+}
+
+// SetLatencyLogger sets the latency logger used for the query and pool metrics
+func SetLatencyLogger(latencyLogger gologger.IMultiLogger) Option {
+	return func(o *observer) { o.latencyLogger = latencyLogger }
+}
+
+// observer records the metrics, spans and logs of the queries
+type observer struct {
+	name          string
+	system        string
+	tracer        trace.Tracer
+	slowQuery     time.Duration
+	logger        gologger.ILogger
+	latencyLogger gologger.IMultiLogger
+}
+
+func newObserver(options []Option) *observer {
+	o := &observer{name: "db"}
+	for _, option := range options {
+		option(o)
+	}
+	if o.logger == nil {
+		o.logger = gologger.NewLogger(gologger.SetLogLevel("ERROR"))
+	}
+	if o.latencyLogger == nil {
+		o.latencyLogger = gologger.NewRateLatencyLogger(gologger.SetLogger(o.logger))
+	}
+	dbutilMetrics.AddTo(o.latencyLogger, o.logger)
+	return o
+}
+
+// start starts the span of a query if tracing is enabled and returns the function which ends it and records
+// the metrics. operation is the Operation label and query the statement, if there is one
+func (o *observer) start(ctx context.Context, operation string, query string) (context.Context, func(error)) {
+	start := time.Now()
+	var span trace.Span
+	if o.tracer != nil {
+		spanName := operation + " " + o.name
+		ctx, span = o.tracer.Start(ctx, spanName, trace.WithSpanKind(trace.SpanKindClient),
+			trace.WithAttributes(gotracer.DBAttributes(o.system, o.name, operation, sanitizeIf(query))...))
+	}
+	return ctx, func(err error) {
+		elapsed := time.Since(start)
+		o.latencyLogger.Toc(start, queryLatencyMetricID, o.name, operation)
+		failed := err != nil && err != driver.ErrSkip && err != sql.ErrNoRows
+		if failed {
+			o.latencyLogger.IncVal(1, queryErrorsMetricID, o.name, operation)
+		}
+		if span != nil {
+			if failed {
+				span.RecordError(err)
+				span.SetStatus(codes.Error, err.Error())
+			}
+			span.End()
+		}
+		if o.slowQuery > 0 && elapsed >= o.slowQuery && query != "" {
+			o.logger.LogWarningMessage("Slow database query",
+				gologger.Pair{Key: "db", Value: o.name},
+				gologger.Pair{Key: "db_operation", Value: operation},
+				gologger.Pair{Key: "db_statement", Value: SanitizeStatement(query)},
+				gologger.Pair{Key: "elapsed_ms", Value: strconv.FormatInt(elapsed.Milliseconds(), 10)})
+		}
+	}
+}
+
+func sanitizeIf(query string) string {
+	if query == "" {
+		return ""
+	}
+	return SanitizeStatement(query)
+}
+
+// NewConnector returns a connector which instruments the connections of connector. Use it with sql.OpenDB
+// for drivers which provide a connector
+func NewConnector(connector driver.Connector, options ...Option) driver.Connector {
+	return &instrumentedConnector{connector: connector, observer: newObserver(options)}
+}
+
+// Open opens a database like sql.Open with the driver instrumented. The driver must be registered
+func Open(driverName, dataSourceName string, options ...Option) (*sql.DB, error) {
+	db, err := sql.Open(driverName, dataSourceName)
+	if err != nil {
+		return nil, err
+	}
+	d := db.Driver()
+	db.Close()
+	if driverContext, ok := d.(driver.DriverContext); ok {
+		connector, err := driverContext.OpenConnector(dataSourceName)
+		if err != nil {
+			return nil, err
+		}
+		return sql.OpenDB(NewConnector(connector, options...)), nil
+	}
+	return sql.OpenDB(NewConnector(dsnConnector{dsn: dataSourceName, driver: d}, options...)), nil
+}
+
+// dsnConnector is the connector of drivers which do not implement driver.DriverContext
+type dsnConnector struct {
+	dsn    string
+	driver driver.Driver
+}
+
+func (c dsnConnector) Connect(ctx context.Context) (driver.Conn, error) {
+	return c.driver.Open(c.dsn)
+}
+
+func (c dsnConnector) Driver() driver.Driver {
+	return c.driver
+}
+
+// WatchPool sets the pool gauges from the stats of db every interval until the returned function is called.
+// name is the DB label of the gauges
+func WatchPool(db *sql.DB, name string, interval time.Duration, options ...Option) (stop func()) {
+	o := newObserver(append([]Option{SetName(name)}, options...))
+	done := make(chan struct{})
+	var once sync.Once
+	goutilities.Go("dbutil-pool-stats", func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			o.recordPoolStats(db.Stats())
+			select {
+			case <-ticker.C:
+			case <-done:
+				return
+			}
+		}
+	})
+	return func() { once.Do(func() { close(done) }) }
+}
+
+func (o *observer) recordPoolStats(stats sql.DBStats) {
+	o.latencyLogger.SetVal(int64(stats.OpenConnections), poolConnectionsMetricID, o.name, "open")
+	o.latencyLogger.SetVal(int64(stats.InUse), poolConnectionsMetricID, o.name, "in_use")
+	o.latencyLogger.SetVal(int64(stats.Idle), poolConnectionsMetricID, o.name, "idle")
+	o.latencyLogger.SetVal(stats.WaitCount, poolWaitsMetricID, o.name)
+	o.latencyLogger.SetVal(stats.WaitDuration.Milliseconds(), poolWaitTimeMetricID, o.name)
+}
@@ -0,0 +1,191 @@
+package dbutil
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"io"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/carwale/golibraries/gologger"
+)
+
+func TestSanitizeStatement(t *testing.T) {
+	tests := []struct {
+		query    string
+		expected string
+	}{
+		{"SELECT * FROM stocks WHERE id = 42", "SELECT * FROM stocks WHERE id = ?"},
+		{"select name from users where email = 'a@b.com' and pin = \"1234\"", "select name from users where email = ? and pin = ?"},
+		{"UPDATE t SET name = 'O''Brien', price = 12.5 WHERE id IN (1, 2)", "UPDATE t SET name = ?, price = ? WHERE id IN (?, ?)"},
+		{"insert into t2 (col1)\n\tvalues ('it\\'s')", "insert into t2 (col1) values (?)"},
+		{"SELECT * FROM t WHERE a = ?", "SELECT * FROM t WHERE a = ?"},
+	}
+	for _, test := range tests {
+		if actual := SanitizeStatement(test.query); actual != test.expected {
+			t.Errorf("SanitizeStatement(%q) = %q, expected %q", test.query, actual, test.expected)
+		}
+	}
+	if long := SanitizeStatement("SELECT " + strings.Repeat("col, ", 1000) + "x FROM t"); len(long) != MaxStatementLength {
+		t.Errorf("expected long statements to be truncated to %d, got %d", MaxStatementLength, len(long))
+	}
+}
+
+func TestOperationOf(t *testing.T) {
+	tests := map[string]string{
+		"SELECT 1":                                 "select",
+		"  (select 1) union (select 2)":            "select",
+		"/* stock-api */ INSERT INTO t VALUES (1)": "insert",
+		"with x as (select 1) select * from x":     "with",
+		"LOCK TABLES t READ":                       "other",
+		"":                                         "other",
+	}
+	for query, expected := range tests {
+		if actual := operationOf(query); actual != expected {
+			t.Errorf("operationOf(%q) = %q, expected %q", query, actual, expected)
+		}
+	}
+}
+
+type recordingLatencyLogger struct {
+	lock    sync.Mutex
+	latency map[string]int
+	values  map[string]int64
+}
+
+func newRecordingLatencyLogger() *recordingLatencyLogger {
+	return &recordingLatencyLogger{latency: map[string]int{}, values: map[string]int64{}}
+}
+
+func (l *recordingLatencyLogger) Tic() time.Time { return time.Now() }
+func (l *recordingLatencyLogger) Toc(start time.Time, id string, labels ...string) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	l.latency[id+"/"+strings.Join(labels, "/")]++
+}
+func (l *recordingLatencyLogger) IncVal(value int64, id string, labels ...string) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	l.values[id+"/"+strings.Join(labels, "/")] += value
+}
+func (l *recordingLatencyLogger) SubVal(value int64, id string, labels ...string) {
+	l.IncVal(-value, id, labels...)
+}
+func (l *recordingLatencyLogger) SetVal(value int64, id string, labels ...string) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	l.values[id+"/"+strings.Join(labels, "/")] = value
+}
+func (l *recordingLatencyLogger) AddNewMetric(string, gologger.IMetricVec) {}
+
+var errFakeQuery = errors.New("table does not exist")
+
+// fakeConn is a connection which succeeds for every statement except those on the missing table
+type fakeConn struct{}
+
+type fakeConnector struct{}
+
+func (fakeConnector) Connect(context.Context) (driver.Conn, error) { return fakeConn{}, nil }
+func (fakeConnector) Driver() driver.Driver                        { return nil }
+
+func (fakeConn) Prepare(query string) (driver.Stmt, error) { return nil, errors.New("not supported") }
+func (fakeConn) Close() error                              { return nil }
+func (fakeConn) Begin() (driver.Tx, error)                 { return fakeTx{}, nil }
+func (fakeConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	if strings.Contains(query, "missing") {
+		return nil, errFakeQuery
+	}
+	return driver.RowsAffected(1), nil
+}
+func (fakeConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	return &fakeRows{}, nil
+}
+
+type fakeTx struct{}
+
+func (fakeTx) Commit() error   { return nil }
+func (fakeTx) Rollback() error { return nil }
+
+type fakeRows struct{ done bool }
+
+func (r *fakeRows) Columns() []string { return []string{"id"} }
+func (r *fakeRows) Close() error      { return nil }
+func (r *fakeRows) Next(dest []driver.Value) error {
+	if r.done {
+		return io.EOF
+	}
+	r.done = true
+	dest[0] = int64(7)
+	return nil
+}
+
+func TestInstrumentedDB(t *testing.T) {
+	latencyLogger := newRecordingLatencyLogger()
+	db := sql.OpenDB(NewConnector(fakeConnector{}, SetName("stock"), SetLatencyLogger(latencyLogger)))
+	defer db.Close()
+	ctx := context.Background()
+
+	var id int64
+	if err := db.QueryRowContext(ctx, "SELECT id FROM stocks WHERE id = ?", 7).Scan(&id); err != nil || id != 7 {
+		t.Fatalf("expected the query to return 7, got %d %v", id, err)
+	}
+	if _, err := db.ExecContext(ctx, "DELETE FROM missing"); err != errFakeQuery {
+		t.Fatalf("expected the error of the driver, got %v", err)
+	}
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := tx.ExecContext(ctx, "UPDATE stocks SET price = 10"); err != nil {
+		t.Fatal(err)
+	}
+	if err := tx.Commit(); err != nil {
+		t.Fatal(err)
+	}
+
+	latencyLogger.lock.Lock()
+	defer latencyLogger.lock.Unlock()
+	for _, key := range []string{"select", "delete", "begin", "update", "commit", "connect"} {
+		if latencyLogger.latency[queryLatencyMetricID+"/stock/"+key] == 0 {
+			t.Errorf("expected the latency of %s to be recorded, got %v", key, latencyLogger.latency)
+		}
+	}
+	if errors := latencyLogger.values[queryErrorsMetricID+"/stock/delete"]; errors != 1 {
+		t.Errorf("expected 1 error for delete, got %d", errors)
+	}
+	if errors := latencyLogger.values[queryErrorsMetricID+"/stock/select"]; errors != 0 {
+		t.Errorf("expected no error for select, got %d", errors)
+	}
+}
+
+func TestWatchPool(t *testing.T) {
+	latencyLogger := newRecordingLatencyLogger()
+	db := sql.OpenDB(NewConnector(fakeConnector{}))
+	defer db.Close()
+	db.SetMaxIdleConns(2)
+	if err := db.Ping(); err != nil {
+		t.Fatal(err)
+	}
+	stop := WatchPool(db, "stock", time.Hour, SetLatencyLogger(latencyLogger))
+	defer stop()
+	deadline := time.Now().Add(time.Second)
+	for {
+		latencyLogger.lock.Lock()
+		open, ok := latencyLogger.values[poolConnectionsMetricID+"/stock/open"]
+		latencyLogger.lock.Unlock()
+		if ok {
+			if open != 1 {
+				t.Fatalf("expected 1 open connection, got %d", open)
+			}
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("expected the pool gauges to be set")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
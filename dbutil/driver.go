@@ -0,0 +1,221 @@
+package dbutil
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+)
+
+var errNamedArgs = errors.New("dbutil: the driver does not support named arguments")
+
+// instrumentedConnector wraps the connections of a connector
+type instrumentedConnector struct {
+	connector driver.Connector
+	observer  *observer
+}
+
+func (c *instrumentedConnector) Connect(ctx context.Context) (driver.Conn, error) {
+	ctx, end := c.observer.start(ctx, "connect", "")
+	conn, err := c.connector.Connect(ctx)
+	end(err)
+	if err != nil {
+		return nil, err
+	}
+	return &instrumentedConn{Conn: conn, observer: c.observer}, nil
+}
+
+func (c *instrumentedConnector) Driver() driver.Driver {
+	return c.connector.Driver()
+}
+
+// instrumentedConn records the queries of a connection. The optional interfaces of the driver connection are
+// passed through; a connection without ExecerContext or QueryerContext gets driver.ErrSkip, so that database/sql
+// prepares the statement instead
+type instrumentedConn struct {
+	driver.Conn
+	observer *observer
+}
+
+var (
+	_ driver.ConnPrepareContext = (*instrumentedConn)(nil)
+	_ driver.ConnBeginTx        = (*instrumentedConn)(nil)
+	_ driver.ExecerContext      = (*instrumentedConn)(nil)
+	_ driver.QueryerContext     = (*instrumentedConn)(nil)
+	_ driver.Pinger             = (*instrumentedConn)(nil)
+	_ driver.SessionResetter    = (*instrumentedConn)(nil)
+	_ driver.Validator          = (*instrumentedConn)(nil)
+	_ driver.NamedValueChecker  = (*instrumentedConn)(nil)
+)
+
+func (c *instrumentedConn) Prepare(query string) (driver.Stmt, error) {
+	return c.PrepareContext(context.Background(), query)
+}
+
+func (c *instrumentedConn) PrepareContext(ctx context.Context, query string) (driver.Stmt, error) {
+	var stmt driver.Stmt
+	var err error
+	if preparer, ok := c.Conn.(driver.ConnPrepareContext); ok {
+		stmt, err = preparer.PrepareContext(ctx, query)
+	} else {
+		stmt, err = c.Conn.Prepare(query)
+	}
+	if err != nil {
+		c.observer.latencyLogger.IncVal(1, queryErrorsMetricID, c.observer.name, "prepare")
+		return nil, err
+	}
+	return &instrumentedStmt{Stmt: stmt, query: query, observer: c.observer}, nil
+}
+
+func (c *instrumentedConn) Begin() (driver.Tx, error) {
+	return c.BeginTx(context.Background(), driver.TxOptions{})
+}
+
+func (c *instrumentedConn) BeginTx(ctx context.Context, opts driver.TxOptions) (driver.Tx, error) {
+	ctx, end := c.observer.start(ctx, "begin", "")
+	var tx driver.Tx
+	var err error
+	if beginner, ok := c.Conn.(driver.ConnBeginTx); ok {
+		tx, err = beginner.BeginTx(ctx, opts)
+	} else {
+		tx, err = c.Conn.Begin()
+	}
+	end(err)
+	if err != nil {
+		return nil, err
+	}
+	return &instrumentedTx{Tx: tx, ctx: ctx, observer: c.observer}, nil
+}
+
+func (c *instrumentedConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	execer, ok := c.Conn.(driver.ExecerContext)
+	if !ok {
+		return nil, driver.ErrSkip
+	}
+	ctx, end := c.observer.start(ctx, operationOf(query), query)
+	result, err := execer.ExecContext(ctx, query, args)
+	end(err)
+	return result, err
+}
+
+func (c *instrumentedConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	queryer, ok := c.Conn.(driver.QueryerContext)
+	if !ok {
+		return nil, driver.ErrSkip
+	}
+	ctx, end := c.observer.start(ctx, operationOf(query), query)
+	rows, err := queryer.QueryContext(ctx, query, args)
+	end(err)
+	return rows, err
+}
+
+func (c *instrumentedConn) Ping(ctx context.Context) error {
+	if pinger, ok := c.Conn.(driver.Pinger); ok {
+		return pinger.Ping(ctx)
+	}
+	return nil
+}
+
+func (c *instrumentedConn) ResetSession(ctx context.Context) error {
+	if resetter, ok := c.Conn.(driver.SessionResetter); ok {
+		return resetter.ResetSession(ctx)
+	}
+	return nil
+}
+
+func (c *instrumentedConn) IsValid() bool {
+	if validator, ok := c.Conn.(driver.Validator); ok {
+		return validator.IsValid()
+	}
+	return true
+}
+
+func (c *instrumentedConn) CheckNamedValue(value *driver.NamedValue) error {
+	if checker, ok := c.Conn.(driver.NamedValueChecker); ok {
+		return checker.CheckNamedValue(value)
+	}
+	return driver.ErrSkip
+}
+
+// instrumentedStmt records the executions of a prepared statement
+type instrumentedStmt struct {
+	driver.Stmt
+	query    string
+	observer *observer
+}
+
+var (
+	_ driver.StmtExecContext   = (*instrumentedStmt)(nil)
+	_ driver.StmtQueryContext  = (*instrumentedStmt)(nil)
+	_ driver.NamedValueChecker = (*instrumentedStmt)(nil)
+)
+
+func (s *instrumentedStmt) ExecContext(ctx context.Context, args []driver.NamedValue) (driver.Result, error) {
+	ctx, end := s.observer.start(ctx, operationOf(s.query), s.query)
+	var result driver.Result
+	var err error
+	if execer, ok := s.Stmt.(driver.StmtExecContext); ok {
+		result, err = execer.ExecContext(ctx, args)
+	} else if values, convertErr := namedValuesToValues(args); convertErr != nil {
+		err = convertErr
+	} else if err = ctx.Err(); err == nil {
+		result, err = s.Stmt.Exec(values)
+	}
+	end(err)
+	return result, err
+}
+
+func (s *instrumentedStmt) QueryContext(ctx context.Context, args []driver.NamedValue) (driver.Rows, error) {
+	ctx, end := s.observer.start(ctx, operationOf(s.query), s.query)
+	var rows driver.Rows
+	var err error
+	if queryer, ok := s.Stmt.(driver.StmtQueryContext); ok {
+		rows, err = queryer.QueryContext(ctx, args)
+	} else if values, convertErr := namedValuesToValues(args); convertErr != nil {
+		err = convertErr
+	} else if err = ctx.Err(); err == nil {
+		rows, err = s.Stmt.Query(values)
+	}
+	end(err)
+	return rows, err
+}
+
+func (s *instrumentedStmt) CheckNamedValue(value *driver.NamedValue) error {
+	if checker, ok := s.Stmt.(driver.NamedValueChecker); ok {
+		return checker.CheckNamedValue(value)
+	}
+	return driver.ErrSkip
+}
+
+// namedValuesToValues converts the arguments for statements without context support, which do not
+// support named arguments
+func namedValuesToValues(args []driver.NamedValue) ([]driver.Value, error) {
+	values := make([]driver.Value, len(args))
+	for i, arg := range args {
+		if arg.Name != "" {
+			return nil, errNamedArgs
+		}
+		values[i] = arg.Value
+	}
+	return values, nil
+}
+
+// instrumentedTx records the commit and rollback of a transaction
+type instrumentedTx struct {
+	driver.Tx
+	ctx      context.Context
+	observer *observer
+}
+
+func (t *instrumentedTx) Commit() error {
+	_, end := t.observer.start(t.ctx, "commit", "")
+	err := t.Tx.Commit()
+	end(err)
+	return err
+}
+
+func (t *instrumentedTx) Rollback() error {
+	_, end := t.observer.start(t.ctx, "rollback", "")
+	err := t.Tx.Rollback()
+	end(err)
+	return err
+}
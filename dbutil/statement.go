@@ -0,0 +1,103 @@
+package dbutil
+
+import (
+	"strings"
+	"unicode"
+)
+
+// MaxStatementLength is the length at which sanitized statements are truncated
+const MaxStatementLength = 2000
+
+// SanitizeStatement replaces the string and number literals of the sql statement with ? and collapses
+// the whitespace, so that statements can be logged and added to spans without the values they were built with.
+// Double quoted values are replaced too, as they are strings in mysql.
+// Statements longer than MaxStatementLength are truncated
+func SanitizeStatement(query string) string {
+	var b strings.Builder
+	b.Grow(len(query))
+	space := false
+	for i := 0; i < len(query); i++ {
+		c := query[i]
+		switch {
+		case c == '\'' || c == '"':
+			// Skip the literal, a doubled quote is an escaped quote and a backslash escapes the next character
+			i++
+			for ; i < len(query); i++ {
+				if query[i] == '\\' {
+					i++
+				} else if query[i] == c {
+					if i+1 < len(query) && query[i+1] == c {
+						i++
+						continue
+					}
+					break
+				}
+			}
+			writeSanitized(&b, '?', &space)
+		case isDigit(c) && !isIdentifierByte(previousByte(query, i)):
+			for i+1 < len(query) && (isDigit(query[i+1]) || query[i+1] == '.') {
+				i++
+			}
+			writeSanitized(&b, '?', &space)
+		case unicode.IsSpace(rune(c)):
+			space = b.Len() > 0
+		default:
+			writeSanitized(&b, c, &space)
+		}
+		if b.Len() >= MaxStatementLength {
+			return b.String()[:MaxStatementLength]
+		}
+	}
+	return b.String()
+}
+
+func writeSanitized(b *strings.Builder, c byte, space *bool) {
+	if *space {
+		b.WriteByte(' ')
+		*space = false
+	}
+	b.WriteByte(c)
+}
+
+func previousByte(query string, i int) byte {
+	if i == 0 {
+		return ' '
+	}
+	return query[i-1]
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+func isIdentifierByte(c byte) bool {
+	return c == '_' || c == '$' || c == '@' || isDigit(c) || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
+
+// operations are the statement keywords used as the Operation label. Other statements are labelled other
+var operations = map[string]bool{
+	"select": true, "insert": true, "update": true, "delete": true, "replace": true, "upsert": true, "merge": true,
+	"with": true, "call": true, "create": true, "alter": true, "drop": true, "truncate": true, "set": true, "show": true,
+}
+
+// operationOf returns the first keyword of the statement in lower case, e.g. select or insert, or other
+// for statements that are not in operations. Statements starting with a comment or a parenthesis are handled
+func operationOf(query string) string {
+	query = strings.TrimLeft(query, " \t\r\n(")
+	for strings.HasPrefix(query, "/*") {
+		end := strings.Index(query, "*/")
+		if end < 0 {
+			return "other"
+		}
+		query = strings.TrimLeft(query[end+2:], " \t\r\n(")
+	}
+	end := strings.IndexFunc(query, func(r rune) bool { return !unicode.IsLetter(r) })
+	if end < 0 {
+		end = len(query)
+	}
+	operation := strings.ToLower(query[:end])
+	if !operations[operation] {
+		return "other"
+	}
+	return operation
+}
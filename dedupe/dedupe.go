@@ -0,0 +1,179 @@
+// Package dedupe suppresses duplicate messages across redeliveries and replays. Consumers are at-least-once,
+// so a message can be delivered more than once. The deduper remembers the business id of every message
+// it has seen in a shared store (memcached, redis) for a ttl, and reports the later deliveries as duplicates
+package dedupe
+
+import (
+	"time"
+
+	"github.com/carwale/golibraries/gologger"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// IStore is a shared store with an atomic set if absent (SETNX) operation
+type IStore interface {
+	// SetIfAbsent stores the key for ttl. It returns false if the key is already stored
+	SetIfAbsent(key string, ttl time.Duration) (bool, error)
+	// Touch sets the ttl of a stored key
+	Touch(key string, ttl time.Duration) error
+	// Delete removes the key
+	Delete(key string) error
+}
+
+// Option sets a parameter for the Deduper
+type Option func(d *Deduper)
+
+// SetTTL sets how long a key is remembered. It should be longer than the time in which messages
+// can be redelivered or replayed. Default is 24 hours
+func SetTTL(ttl time.Duration) Option {
+	return func(d *Deduper) {
+		if ttl > 0 {
+			d.ttl = ttl
+		}
+	}
+}
+
+// SetProcessingTTL sets how long Process remembers a key while the message is being processed. If the instance
+// dies while processing, the redelivery is processed once it expires. It should be longer than the processing
+// of a message. Default is 5 minutes
+func SetProcessingTTL(ttl time.Duration) Option {
+	return func(d *Deduper) {
+		if ttl > 0 {
+			d.processingTTL = ttl
+		}
+	}
+}
+
+// SetKeyPrefix sets the prefix added to the keys in the store. Default is the name of the deduper followed by a colon
+func SetKeyPrefix(prefix string) Option {
+	return func(d *Deduper) { d.keyPrefix = prefix }
+}
+
+// SetLogger sets the logger of the deduper
+func SetLogger(logger gologger.ILogger) Option {
+	return func(d *Deduper) { d.logger = logger }
+}
+
+// SetLatencyLogger sets the latency logger used for the metrics of the deduper
+func SetLatencyLogger(latencyLogger gologger.IMultiLogger) Option {
+	return func(d *Deduper) { d.latencyLogger = latencyLogger }
+}
+
+const (
+	duplicatesMetricID  = "DEDUPE-DUPLICATES"
+	storeErrorsMetricID = "DEDUPE-STORE-ERRORS"
+)
+
+var dedupeMetrics = gologger.NewMetricSet(func(logger gologger.ILogger) map[string]gologger.IMetricVec {
+	return map[string]gologger.IMetricVec{
+		duplicatesMetricID: gologger.NewCounterMetric(prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "dedupe_duplicates_suppressed_total",
+				Help: "Number of duplicate messages suppressed by the deduper",
+			},
+			[]string{"Name"},
+		), logger),
+		storeErrorsMetricID: gologger.NewCounterMetric(prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "dedupe_store_errors_total",
+				Help: "Number of errors from the store of the deduper",
+			},
+			[]string{"Name"},
+		), logger),
+	}
+})
+
+// Deduper reports whether a message with a business id has already been seen
+type Deduper struct {
+	name          string
+	store         IStore
+	ttl           time.Duration
+	processingTTL time.Duration
+	keyPrefix     string
+	logger        gologger.ILogger
+	latencyLogger gologger.IMultiLogger
+}
+
+// NewDeduper returns a deduper with the name used in metrics and as the default key prefix.
+// It panics if store is nil
+func NewDeduper(name string, store IStore, options ...Option) *Deduper {
+	if store == nil {
+		panic("store of deduper " + name + " is nil")
+	}
+	d := &Deduper{
+		name:          name,
+		store:         store,
+		ttl:           24 * time.Hour,
+		processingTTL: 5 * time.Minute,
+		keyPrefix:     name + ":",
+	}
+	for _, option := range options {
+		option(d)
+	}
+	if d.logger == nil {
+		d.logger = gologger.NewLogger()
+	}
+	if d.latencyLogger == nil {
+		d.latencyLogger = gologger.NewRateLatencyLogger(gologger.SetLogger(d.logger))
+	}
+	dedupeMetrics.AddTo(d.latencyLogger, d.logger)
+	return d
+}
+
+// IsDuplicate marks the key as seen and returns true if it had already been seen.
+// If the store fails the message is not treated as a duplicate, so that it is processed at least once
+func (d *Deduper) IsDuplicate(key string) bool {
+	return d.isDuplicate(key, d.ttl)
+}
+
+// isDuplicate marks the key as seen for ttl and returns true if it had already been seen
+func (d *Deduper) isDuplicate(key string, ttl time.Duration) bool {
+	stored, err := d.store.SetIfAbsent(d.keyPrefix+key, ttl)
+	if err != nil {
+		d.latencyLogger.IncVal(1, storeErrorsMetricID, d.name)
+		d.logger.LogErrorMessage("Could not check for duplicate message", err,
+			gologger.Pair{Key: "deduper", Value: d.name}, gologger.Pair{Key: "key", Value: key})
+		return false
+	}
+	if !stored {
+		d.latencyLogger.IncVal(1, duplicatesMetricID, d.name)
+		d.logger.LogDebugf("Suppressed duplicate message %s in %s", key, d.name)
+		return true
+	}
+	return false
+}
+
+// Forget removes the key from the store. Call it when processing of the message failed,
+// so that the redelivery of the message is processed
+func (d *Deduper) Forget(key string) {
+	if err := d.store.Delete(d.keyPrefix + key); err != nil {
+		d.latencyLogger.IncVal(1, storeErrorsMetricID, d.name)
+		d.logger.LogErrorMessage("Could not forget message key", err,
+			gologger.Pair{Key: "deduper", Value: d.name}, gologger.Pair{Key: "key", Value: key})
+	}
+}
+
+// Process calls process unless the key is a duplicate. The key is remembered for the processing ttl while
+// process runs and for the ttl once it returns true. It is forgotten if process returns false or panics.
+// It returns true if the message was a duplicate or was processed
+func (d *Deduper) Process(key string, process func() bool) bool {
+	if d.isDuplicate(key, d.processingTTL) {
+		return true
+	}
+	defer func() {
+		if r := recover(); r != nil {
+			d.Forget(key)
+			panic(r)
+		}
+	}()
+	if !process() {
+		d.Forget(key)
+		return false
+	}
+	if err := d.store.Touch(d.keyPrefix+key, d.ttl); err != nil {
+		d.latencyLogger.IncVal(1, storeErrorsMetricID, d.name)
+		d.logger.LogErrorMessage("Could not remember processed message key", err,
+			gologger.Pair{Key: "deduper", Value: d.name}, gologger.Pair{Key: "key", Value: key})
+	}
+	return true
+}
@@ -0,0 +1,106 @@
+package dedupe
+
+import (
+	"errors"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/carwale/golibraries/gologger"
+)
+
+type nopMultiLogger struct{}
+
+func (nopMultiLogger) AddNewMetric(string, gologger.IMetricVec) {}
+func (nopMultiLogger) Tic() time.Time                           { return time.Now() }
+func (nopMultiLogger) Toc(time.Time, string, ...string)         {}
+func (nopMultiLogger) IncVal(int64, string, ...string)          {}
+func (nopMultiLogger) SubVal(int64, string, ...string)          {}
+func (nopMultiLogger) SetVal(int64, string, ...string)          {}
+
+type failingStore struct{}
+
+func (failingStore) SetIfAbsent(string, time.Duration) (bool, error) {
+	return false, errors.New("down")
+}
+func (failingStore) Touch(string, time.Duration) error { return errors.New("down") }
+func (failingStore) Delete(string) error               { return errors.New("down") }
+
+func TestDeduperSuppressesDuplicates(t *testing.T) {
+	d := NewDeduper("test", NewMemoryStore(), SetLatencyLogger(nopMultiLogger{}))
+	if d.IsDuplicate("order-1") {
+		t.Fatal("IsDuplicate() = true for the first delivery, want false")
+	}
+	if !d.IsDuplicate("order-1") {
+		t.Fatal("IsDuplicate() = false for the redelivery, want true")
+	}
+	if d.IsDuplicate("order-2") {
+		t.Fatal("IsDuplicate() = true for another key, want false")
+	}
+}
+
+func TestDeduperProcessForgetsFailures(t *testing.T) {
+	d := NewDeduper("test", NewMemoryStore(), SetLatencyLogger(nopMultiLogger{}))
+	calls := 0
+	d.Process("order-1", func() bool { calls++; return false })
+	d.Process("order-1", func() bool { calls++; return true })
+	d.Process("order-1", func() bool { calls++; return true })
+	if calls != 2 {
+		t.Errorf("process called %d times, want 2", calls)
+	}
+}
+
+func TestDeduperFailsOpen(t *testing.T) {
+	d := NewDeduper("test", failingStore{}, SetLatencyLogger(nopMultiLogger{}))
+	if d.IsDuplicate("order-1") || d.IsDuplicate("order-1") {
+		t.Error("IsDuplicate() = true when the store fails, want false")
+	}
+}
+
+func TestMemoryStoreExpiry(t *testing.T) {
+	s := NewMemoryStore()
+	s.SetIfAbsent("key", time.Millisecond)
+	time.Sleep(2 * time.Millisecond)
+	if stored, _ := s.SetIfAbsent("key", time.Minute); !stored {
+		t.Error("SetIfAbsent() = false for an expired key, want true")
+	}
+}
+
+func TestMemoryStoreSweepsExpiredKeys(t *testing.T) {
+	s := NewMemoryStore()
+	for i := 0; i < memorySweepInterval-1; i++ {
+		s.SetIfAbsent(strconv.Itoa(i), time.Millisecond)
+	}
+	time.Sleep(2 * time.Millisecond)
+	s.SetIfAbsent("live", time.Minute)
+	if len(s.keys) != 1 {
+		t.Errorf("len(keys) = %d after a sweep, want only the live key", len(s.keys))
+	}
+}
+
+func TestDeduperProcessForgetsPanics(t *testing.T) {
+	d := NewDeduper("test", NewMemoryStore(), SetLatencyLogger(nopMultiLogger{}))
+	func() {
+		defer func() { recover() }()
+		d.Process("order-1", func() bool { panic("boom") })
+	}()
+	calls := 0
+	d.Process("order-1", func() bool { calls++; return true })
+	if calls != 1 {
+		t.Errorf("process called %d times after a panic, want 1", calls)
+	}
+}
+
+func TestDeduperProcessRemembersKeyForProcessingTTL(t *testing.T) {
+	store := NewMemoryStore()
+	d := NewDeduper("test", store, SetLatencyLogger(nopMultiLogger{}), SetProcessingTTL(time.Minute))
+	d.Process("order-1", func() bool {
+		if expiry := store.keys["test:order-1"]; time.Until(expiry) > time.Minute {
+			t.Errorf("key expires in %s while processing, want the processing ttl", time.Until(expiry))
+		}
+		return true
+	})
+	if expiry := store.keys["test:order-1"]; time.Until(expiry) < time.Hour {
+		t.Errorf("key expires in %s after processing, want the ttl", time.Until(expiry))
+	}
+}
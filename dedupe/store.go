@@ -0,0 +1,115 @@
+package dedupe
+
+import (
+	"sync"
+	"time"
+
+	"github.com/carwale/golibraries/memcached"
+	"github.com/carwale/gomemcache/memcache"
+)
+
+// memorySweepInterval is the number of writes to the memory store between sweeps of the expired keys
+const memorySweepInterval = 1024
+
+// memcachedKeys builds the keys of the memcached store, hashing the keys memcached does not accept
+var memcachedKeys = memcached.NewKeyBuilder("dedupe", 1)
+
+// MemcachedStore stores the keys in memcached using the add command, which only stores absent keys
+type MemcachedStore struct {
+	client *memcached.CacheClient
+}
+
+// NewMemcachedStore returns a store backed by the memcached client
+func NewMemcachedStore(client *memcached.CacheClient) *MemcachedStore {
+	return &MemcachedStore{client: client}
+}
+
+// SetIfAbsent adds the key to memcached. The ttl is rounded up to seconds
+func (s *MemcachedStore) SetIfAbsent(key string, ttl time.Duration) (bool, error) {
+	_, err := s.client.AddItem(memcachedKeys.Key(key), true, expirationOf(ttl))
+	if err == memcache.ErrNotStored {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// Touch sets the ttl of the key in memcached. The ttl is rounded up to seconds
+func (s *MemcachedStore) Touch(key string, ttl time.Duration) error {
+	_, err := s.client.Touch(memcachedKeys.Key(key), expirationOf(ttl))
+	return err
+}
+
+// Delete deletes the key from memcached
+func (s *MemcachedStore) Delete(key string) error {
+	_, err := s.client.DeleteNow(memcachedKeys.Key(key))
+	if err == memcache.ErrCacheMiss {
+		return nil
+	}
+	return err
+}
+
+// expirationOf returns the ttl in seconds, rounded up
+func expirationOf(ttl time.Duration) int32 {
+	return int32((ttl + time.Second - 1) / time.Second)
+}
+
+// MemoryStore keeps the keys in memory. It only suppresses duplicates within a single instance
+// and is meant for tests and services that run one consumer
+type MemoryStore struct {
+	lock   sync.Mutex
+	keys   map[string]time.Time
+	writes int
+}
+
+// NewMemoryStore returns an empty in memory store
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{keys: make(map[string]time.Time)}
+}
+
+// SetIfAbsent stores the key if it is absent or expired. The expired keys are swept every
+// memorySweepInterval writes so that keys which are never seen again do not pile up
+func (s *MemoryStore) SetIfAbsent(key string, ttl time.Duration) (bool, error) {
+	now := time.Now()
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if expiry, ok := s.keys[key]; ok && now.Before(expiry) {
+		return false, nil
+	}
+	s.keys[key] = now.Add(ttl)
+	s.writes++
+	if s.writes >= memorySweepInterval {
+		s.writes = 0
+		s.sweep(now)
+	}
+	return true, nil
+}
+
+// sweep removes the keys expired at now. The lock must be held
+func (s *MemoryStore) sweep(now time.Time) {
+	for key, expiry := range s.keys {
+		if !now.Before(expiry) {
+			delete(s.keys, key)
+		}
+	}
+}
+
+// Touch sets the ttl of the key if it is stored
+func (s *MemoryStore) Touch(key string, ttl time.Duration) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if _, ok := s.keys[key]; ok {
+		s.keys[key] = time.Now().Add(ttl)
+	}
+	return nil
+}
+
+// Delete removes the key
+func (s *MemoryStore) Delete(key string) error {
+	s.lock.Lock()
+	delete(s.keys, key)
+	s.lock.Unlock()
+	return nil
+}
@@ -0,0 +1,155 @@
+// Package diagnostics exposes pprof handlers on an internal port and can take periodic profile snapshots,
+// so that production services can be profiled without a special build.
+//
+// The pprof endpoints can be scraped by pull based profilers like Parca or the grafana agent for Pyroscope.
+// Snapshots can be pushed to Pyroscope with the PyroscopeSink or written to disk with the DirectorySink.
+// Both are turned on and off at runtime with SetEnabledFunc, e.g. with a consul ExpiryToggle
+package diagnostics
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/pprof"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/carwale/golibraries/gologger"
+	"github.com/carwale/golibraries/goutilities"
+)
+
+// EnvEnabled is the environment variable read by EnabledFromEnv
+const EnvEnabled = "DIAGNOSTICS_ENABLED"
+
+// EnvAddress is the environment variable which overrides the address of the server
+const EnvAddress = "DIAGNOSTICS_ADDRESS"
+
+// Server serves the pprof handlers and takes the profile snapshots
+type Server struct {
+	address          string
+	enabled          func() bool
+	sinks            []ISnapshotSink
+	snapshotInterval time.Duration
+	profiles         []string
+	logger           gologger.ILogger
+	httpServer       *http.Server
+	cancel           context.CancelFunc
+	wg               sync.WaitGroup
+}
+
+// Option sets the options of the diagnostics server
+type Option func(s *Server)
+
+// SetAddress sets the address the pprof handlers are served on. Defaults to localhost:6060 so that
+// they are not reachable from outside the pod. It is overridden by the DIAGNOSTICS_ADDRESS environment variable
+func SetAddress(address string) Option {
+	return func(s *Server) { s.address = address }
+}
+
+// SetEnabledFunc sets the function which decides whether the handlers respond and snapshots are taken.
+// It is called on every request and snapshot, so it should be cheap. Defaults to EnabledFromEnv
+func SetEnabledFunc(enabled func() bool) Option {
+	return func(s *Server) { s.enabled = enabled }
+}
+
+// AddSnapshotSink adds a sink for the periodic snapshots. Snapshots are only taken if there is a sink
+func AddSnapshotSink(sink ISnapshotSink) Option {
+	return func(s *Server) { s.sinks = append(s.sinks, sink) }
+}
+
+// SetSnapshotInterval sets the interval between snapshots. Defaults to 1 minute
+func SetSnapshotInterval(interval time.Duration) Option {
+	return func(s *Server) {
+		if interval <= 0 {
+			s.logger.LogErrorWithoutError("snapshot interval must be positive")
+			return
+		}
+		s.snapshotInterval = interval
+	}
+}
+
+// SetProfiles sets the runtime/pprof profiles included in a snapshot. Defaults to heap and goroutine
+func SetProfiles(profiles ...string) Option {
+	return func(s *Server) { s.profiles = profiles }
+}
+
+// SetLogger sets the logger of the server
+func SetLogger(logger gologger.ILogger) Option {
+	return func(s *Server) { s.logger = logger }
+}
+
+// EnabledFromEnv returns true if the DIAGNOSTICS_ENABLED environment variable is set to a true value
+func EnabledFromEnv() bool {
+	enabled, _ := strconv.ParseBool(os.Getenv(EnvEnabled))
+	return enabled
+}
+
+// NewServer starts serving the pprof handlers and taking snapshots in the background until Close is called
+func NewServer(options ...Option) *Server {
+	s := &Server{
+		address:          "localhost:6060",
+		enabled:          EnabledFromEnv,
+		snapshotInterval: time.Minute,
+		profiles:         []string{"heap", "goroutine"},
+		logger:           gologger.NewLogger(),
+	}
+	for _, option := range options {
+		option(s)
+	}
+	if address := os.Getenv(EnvAddress); address != "" {
+		s.address = address
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	s.cancel = cancel
+	s.httpServer = &http.Server{Handler: s.Handler(), ReadHeaderTimeout: 10 * time.Second}
+	listener, err := net.Listen("tcp", s.address)
+	if err != nil {
+		s.logger.LogError("could not start diagnostics server on "+s.address, err)
+	} else {
+		s.wg.Add(1)
+		goutilities.Go("diagnostics-server", func() {
+			defer s.wg.Done()
+			if err := s.httpServer.Serve(listener); err != nil && err != http.ErrServerClosed {
+				s.logger.LogError("diagnostics server stopped", err)
+			}
+		})
+	}
+	if len(s.sinks) > 0 {
+		s.wg.Add(1)
+		goutilities.GoCtx(ctx, "diagnostics-snapshots", func(ctx context.Context) {
+			defer s.wg.Done()
+			s.runSnapshots(ctx)
+		})
+	}
+	return s
+}
+
+// Handler returns the pprof handlers. They respond with 404 while diagnostics are disabled
+func (s *Server) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/debug/pprof/", pprof.Index)
+	mux.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+	mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
+	mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+	mux.HandleFunc("/debug/pprof/trace", pprof.Trace)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !s.enabled() {
+			http.NotFound(w, r)
+			return
+		}
+		mux.ServeHTTP(w, r)
+	})
+}
+
+// Close stops the server and the snapshots
+func (s *Server) Close() error {
+	s.cancel()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	err := s.httpServer.Shutdown(ctx)
+	s.wg.Wait()
+	return err
+}
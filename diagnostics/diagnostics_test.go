@@ -0,0 +1,67 @@
+package diagnostics
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/carwale/golibraries/gologger"
+)
+
+func TestHandlerRespectsEnabledFunc(t *testing.T) {
+	var enabled atomic.Bool
+	s := &Server{enabled: enabled.Load}
+	handler := s.Handler()
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/debug/pprof/goroutine", nil))
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("disabled status = %d, want 404", rec.Code)
+	}
+
+	enabled.Store(true)
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/debug/pprof/goroutine", nil))
+	if rec.Code != http.StatusOK {
+		t.Errorf("enabled status = %d, want 200", rec.Code)
+	}
+}
+
+func TestSnapshotWritesProfilesToSinks(t *testing.T) {
+	var profiles []string
+	sink := SinkFunc(func(ctx context.Context, snapshot Snapshot) error {
+		if len(snapshot.Data) == 0 {
+			t.Errorf("snapshot of %s is empty", snapshot.Profile)
+		}
+		profiles = append(profiles, snapshot.Profile)
+		return nil
+	})
+	s := &Server{profiles: []string{"heap", "goroutine", "unknown"}, sinks: []ISnapshotSink{sink}, logger: gologger.NewLogger()}
+	s.snapshot(context.Background())
+	if len(profiles) != 2 || profiles[0] != "heap" || profiles[1] != "goroutine" {
+		t.Errorf("profiles = %v, want [heap goroutine]", profiles)
+	}
+}
+
+func TestPyroscopeSink(t *testing.T) {
+	var name string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		name = r.URL.Query().Get("name")
+		if _, _, err := r.FormFile("profile"); err != nil {
+			t.Errorf("FormFile() error = %v", err)
+		}
+	}))
+	defer server.Close()
+
+	sink := NewPyroscopeSink(server.URL, "stock", map[string]string{"pod": "stock-1", "env": "prod"})
+	err := sink.Write(context.Background(), Snapshot{Profile: "heap", Time: time.Now(), Data: []byte("profile")})
+	if err != nil {
+		t.Fatalf("Write() error = %v", err)
+	}
+	if name != "stock.heap{env=prod,pod=stock-1}" {
+		t.Errorf("name = %q", name)
+	}
+}
@@ -0,0 +1,86 @@
+package diagnostics
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// PyroscopeSink pushes snapshots to the ingest api of a Pyroscope server.
+// The profile name is appended to the application name, e.g. stock.heap, and the labels are sent as tags
+type PyroscopeSink struct {
+	url             string
+	applicationName string
+	labels          map[string]string
+	client          *http.Client
+}
+
+// NewPyroscopeSink returns a sink pushing to the Pyroscope server at serverURL, e.g. http://pyroscope:4040
+func NewPyroscopeSink(serverURL string, applicationName string, labels map[string]string) *PyroscopeSink {
+	return &PyroscopeSink{
+		url:             strings.TrimRight(serverURL, "/") + "/ingest",
+		applicationName: applicationName,
+		labels:          labels,
+		client:          &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+// Write pushes the snapshot
+func (p *PyroscopeSink) Write(ctx context.Context, snapshot Snapshot) error {
+	var body bytes.Buffer
+	form := multipart.NewWriter(&body)
+	part, err := form.CreateFormFile("profile", "profile.pprof")
+	if err != nil {
+		return err
+	}
+	if _, err = part.Write(snapshot.Data); err != nil {
+		return err
+	}
+	if err = form.Close(); err != nil {
+		return err
+	}
+
+	query := url.Values{}
+	query.Set("name", p.name(snapshot.Profile))
+	query.Set("from", strconv.FormatInt(snapshot.Time.Unix(), 10))
+	query.Set("until", strconv.FormatInt(snapshot.Time.Unix(), 10))
+	query.Set("format", "pprof")
+	query.Set("spyName", "gospy")
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.url+"?"+query.Encode(), &body)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", form.FormDataContentType())
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, resp.Body)
+	if resp.StatusCode >= 300 {
+		return errors.New("pyroscope ingest returned status " + resp.Status)
+	}
+	return nil
+}
+
+// name returns the application name with the labels in the format app.profile{key=value,...}
+func (p *PyroscopeSink) name(profile string) string {
+	keys := make([]string, 0, len(p.labels))
+	for key := range p.labels {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	tags := make([]string, 0, len(keys))
+	for _, key := range keys {
+		tags = append(tags, key+"="+p.labels[key])
+	}
+	return p.applicationName + "." + profile + "{" + strings.Join(tags, ",") + "}"
+}
@@ -0,0 +1,98 @@
+package diagnostics
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"runtime/pprof"
+	"strconv"
+	"time"
+)
+
+// Snapshot is a profile taken by the server, in the pprof format
+type Snapshot struct {
+	Profile string
+	Time    time.Time
+	Data    []byte
+}
+
+// ISnapshotSink receives the snapshots taken by the server
+type ISnapshotSink interface {
+	Write(ctx context.Context, snapshot Snapshot) error
+}
+
+// SinkFunc is an ISnapshotSink backed by a function
+type SinkFunc func(ctx context.Context, snapshot Snapshot) error
+
+// Write calls the function
+func (f SinkFunc) Write(ctx context.Context, snapshot Snapshot) error {
+	return f(ctx, snapshot)
+}
+
+// DirectorySink writes snapshots to files named <profile>-<unix time>.pb.gz in a directory,
+// e.g. a volume which is collected when debugging a pod
+type DirectorySink struct {
+	Dir string
+}
+
+// Write writes the snapshot to a file in the directory
+func (d DirectorySink) Write(ctx context.Context, snapshot Snapshot) error {
+	if err := os.MkdirAll(d.Dir, 0o755); err != nil {
+		return err
+	}
+	name := snapshot.Profile + "-" + strconv.FormatInt(snapshot.Time.Unix(), 10) + ".pb.gz"
+	return os.WriteFile(filepath.Join(d.Dir, name), snapshot.Data, 0o644)
+}
+
+// TakeSnapshot writes the runtime/pprof profile in the pprof format
+func TakeSnapshot(profile string) (Snapshot, error) {
+	p := pprof.Lookup(profile)
+	if p == nil {
+		return Snapshot{}, &unknownProfileError{profile}
+	}
+	var buf bytes.Buffer
+	if err := p.WriteTo(&buf, 0); err != nil {
+		return Snapshot{}, err
+	}
+	return Snapshot{Profile: profile, Time: time.Now(), Data: buf.Bytes()}, nil
+}
+
+type unknownProfileError struct {
+	profile string
+}
+
+func (e *unknownProfileError) Error() string {
+	return "unknown profile " + e.profile
+}
+
+func (s *Server) runSnapshots(ctx context.Context) {
+	ticker := time.NewTicker(s.snapshotInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if s.enabled() {
+				s.snapshot(ctx)
+			}
+		}
+	}
+}
+
+// snapshot takes every profile and writes it to every sink
+func (s *Server) snapshot(ctx context.Context) {
+	for _, profile := range s.profiles {
+		snapshot, err := TakeSnapshot(profile)
+		if err != nil {
+			s.logger.LogError("could not take snapshot of profile "+profile, err)
+			continue
+		}
+		for _, sink := range s.sinks {
+			if err := sink.Write(ctx, snapshot); err != nil {
+				s.logger.LogError("could not write snapshot of profile "+profile, err)
+			}
+		}
+	}
+}
@@ -0,0 +1,117 @@
+// Package envelope defines the standard envelope of events published to kafka and rabbitmq.
+// The envelope carries the metadata needed to route and evolve events (type, version, producer,
+// timestamp and trace context) next to the payload, so consumers do not have to inspect the payload
+package envelope
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+)
+
+// ContentType is the content type of encoded envelopes
+const ContentType = "application/vnd.envelope+json"
+
+// Envelope wraps the payload of an event
+type Envelope struct {
+	// Type identifies the event, e.g. "stock.updated"
+	Type string `json:"type"`
+	// Version is the schema version of the payload. It starts at 1
+	Version int `json:"version"`
+	// Producer is the name of the service that published the event
+	Producer string `json:"producer"`
+	// Timestamp is the time at which the event was created
+	Timestamp time.Time `json:"timestamp"`
+	// Trace holds the propagated trace context (traceparent, tracestate)
+	Trace map[string]string `json:"trace,omitempty"`
+	// Payload is the encoded event
+	Payload []byte `json:"payload"`
+}
+
+// ErrInvalidEnvelope is returned when decoded data is not an envelope
+var ErrInvalidEnvelope = errors.New("invalid envelope")
+
+// New returns an envelope for the payload. The trace context of ctx is injected with the global propagator
+func New(ctx context.Context, eventType string, version int, producer string, payload []byte) *Envelope {
+	env := &Envelope{
+		Type:      eventType,
+		Version:   version,
+		Producer:  producer,
+		Timestamp: time.Now().UTC(),
+		Payload:   payload,
+	}
+	carrier := propagation.MapCarrier{}
+	otel.GetTextMapPropagator().Inject(ctx, carrier)
+	if len(carrier) > 0 {
+		env.Trace = carrier
+	}
+	return env
+}
+
+// NewJSON returns an envelope with the json encoding of v as payload
+func NewJSON(ctx context.Context, eventType string, version int, producer string, v interface{}) (*Envelope, error) {
+	payload, err := json.Marshal(v)
+	if err != nil {
+		return nil, fmt.Errorf("could not encode payload of %s: %w", eventType, err)
+	}
+	return New(ctx, eventType, version, producer, payload), nil
+}
+
+// Encode returns the json encoding of the envelope
+func Encode(env *Envelope) ([]byte, error) {
+	if err := env.validate(); err != nil {
+		return nil, err
+	}
+	return json.Marshal(env)
+}
+
+// Decode decodes and validates an envelope
+func Decode(data []byte) (*Envelope, error) {
+	env := &Envelope{}
+	if err := json.Unmarshal(data, env); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidEnvelope, err)
+	}
+	if err := env.validate(); err != nil {
+		return nil, err
+	}
+	return env, nil
+}
+
+// DecodeMap decodes an envelope from a message that has already been decoded to a map,
+// like the messages passed to rabbitmq processors
+func DecodeMap(data map[string]interface{}) (*Envelope, error) {
+	encoded, err := json.Marshal(data)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidEnvelope, err)
+	}
+	return Decode(encoded)
+}
+
+// Context returns ctx with the trace context of the envelope, so that spans of the consumer
+// are children of the span of the producer
+func (env *Envelope) Context(ctx context.Context) context.Context {
+	if len(env.Trace) == 0 {
+		return ctx
+	}
+	return otel.GetTextMapPropagator().Extract(ctx, propagation.MapCarrier(env.Trace))
+}
+
+// UnmarshalPayload decodes the json payload into v
+func (env *Envelope) UnmarshalPayload(v interface{}) error {
+	return json.Unmarshal(env.Payload, v)
+}
+
+func (env *Envelope) validate() error {
+	if env.Type == "" {
+		return fmt.Errorf("%w: type is empty", ErrInvalidEnvelope)
+	}
+	if env.Version < 1 {
+		return fmt.Errorf("%w: version %d of %s is less than 1", ErrInvalidEnvelope, env.Version, env.Type)
+	}
+	return nil
+}
@@ -0,0 +1,63 @@
+package envelope
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestEncodeDecode(t *testing.T) {
+	env, err := NewJSON(context.Background(), "stock.updated", 2, "stock-service", map[string]int{"id": 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := Encode(env)
+	if err != nil {
+		t.Fatal(err)
+	}
+	decoded, err := Decode(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if decoded.Type != env.Type || decoded.Version != 2 || decoded.Producer != "stock-service" || !decoded.Timestamp.Equal(env.Timestamp) {
+		t.Errorf("Decode() = %+v, want %+v", decoded, env)
+	}
+	var payload map[string]int
+	if err := decoded.UnmarshalPayload(&payload); err != nil || payload["id"] != 1 {
+		t.Errorf("UnmarshalPayload() = %v, %v, want id 1", payload, err)
+	}
+}
+
+func TestDecodeInvalid(t *testing.T) {
+	for _, data := range []string{`not json`, `{"version":1}`, `{"type":"a","version":0}`} {
+		if _, err := Decode([]byte(data)); !errors.Is(err, ErrInvalidEnvelope) {
+			t.Errorf("Decode(%s) error = %v, want ErrInvalidEnvelope", data, err)
+		}
+	}
+}
+
+func TestNegotiate(t *testing.T) {
+	n := NewNegotiator()
+	n.Register("stock.updated", 3)
+	n.RegisterUpgrade("stock.updated", 1, func(p []byte) ([]byte, error) { return append(p, '2'), nil })
+	n.RegisterUpgrade("stock.updated", 2, func(p []byte) ([]byte, error) { return append(p, '3'), nil })
+
+	env := &Envelope{Type: "stock.updated", Version: 1, Payload: []byte("1")}
+	upgraded, err := n.Negotiate(env)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if upgraded.Version != 3 || string(upgraded.Payload) != "123" {
+		t.Errorf("Negotiate() = version %d payload %s, want version 3 payload 123", upgraded.Version, upgraded.Payload)
+	}
+	if env.Version != 1 {
+		t.Error("Negotiate() modified the given envelope")
+	}
+
+	if _, err := n.Negotiate(&Envelope{Type: "stock.updated", Version: 4}); !errors.Is(err, ErrUnsupportedVersion) {
+		t.Errorf("Negotiate() error = %v for a newer version, want ErrUnsupportedVersion", err)
+	}
+	if _, err := n.Negotiate(&Envelope{Type: "stock.deleted", Version: 1}); !errors.Is(err, ErrUnknownType) {
+		t.Errorf("Negotiate() error = %v for an unknown type, want ErrUnknownType", err)
+	}
+}
@@ -0,0 +1,87 @@
+package envelope
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+)
+
+// ErrUnsupportedVersion is returned when an event has a version which the consumer cannot read
+var ErrUnsupportedVersion = errors.New("unsupported event version")
+
+// ErrUnknownType is returned when an event type is not registered
+var ErrUnknownType = errors.New("unknown event type")
+
+// UpgradeFunc converts the payload of an event from one version to the next
+type UpgradeFunc func(payload []byte) ([]byte, error)
+
+type eventVersions struct {
+	current  int
+	upgrades map[int]UpgradeFunc
+}
+
+// Negotiator knows the current version of every event type a consumer reads, and upgrades the payload
+// of events published with older versions. Producers can roll out new versions before all consumers
+// are upgraded, as long as the consumers register the upgrades from the older versions
+type Negotiator struct {
+	lock   sync.RWMutex
+	events map[string]*eventVersions
+}
+
+// NewNegotiator returns a negotiator without event types
+func NewNegotiator() *Negotiator {
+	return &Negotiator{events: make(map[string]*eventVersions)}
+}
+
+// Register sets the version of the event type that the consumer reads
+func (n *Negotiator) Register(eventType string, currentVersion int) {
+	n.lock.Lock()
+	defer n.lock.Unlock()
+	n.versions(eventType).current = currentVersion
+}
+
+// RegisterUpgrade registers the conversion of the payload of the event type from fromVersion to fromVersion+1
+func (n *Negotiator) RegisterUpgrade(eventType string, fromVersion int, upgrade UpgradeFunc) {
+	n.lock.Lock()
+	defer n.lock.Unlock()
+	n.versions(eventType).upgrades[fromVersion] = upgrade
+}
+
+// versions returns the versions of the event type, creating them if needed. It is called with the lock held
+func (n *Negotiator) versions(eventType string) *eventVersions {
+	versions, ok := n.events[eventType]
+	if !ok {
+		versions = &eventVersions{upgrades: make(map[int]UpgradeFunc)}
+		n.events[eventType] = versions
+	}
+	return versions
+}
+
+// Negotiate returns the envelope with the payload upgraded to the registered version of its type.
+// It returns ErrUnknownType if the type is not registered, and ErrUnsupportedVersion if the version
+// is newer than the registered one or an upgrade is missing. The given envelope is not modified
+func (n *Negotiator) Negotiate(env *Envelope) (*Envelope, error) {
+	n.lock.RLock()
+	defer n.lock.RUnlock()
+	versions, ok := n.events[env.Type]
+	if !ok || versions.current == 0 {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownType, env.Type)
+	}
+	if env.Version > versions.current {
+		return nil, fmt.Errorf("%w: %s version %d is newer than %d", ErrUnsupportedVersion, env.Type, env.Version, versions.current)
+	}
+	upgraded := *env
+	for upgraded.Version < versions.current {
+		upgrade, ok := versions.upgrades[upgraded.Version]
+		if !ok {
+			return nil, fmt.Errorf("%w: no upgrade of %s from version %d", ErrUnsupportedVersion, env.Type, upgraded.Version)
+		}
+		payload, err := upgrade(upgraded.Payload)
+		if err != nil {
+			return nil, fmt.Errorf("could not upgrade %s from version %d: %w", env.Type, upgraded.Version, err)
+		}
+		upgraded.Payload = payload
+		upgraded.Version++
+	}
+	return &upgraded, nil
+}
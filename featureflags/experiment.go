@@ -0,0 +1,99 @@
+package featureflags
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+
+	"github.com/carwale/golibraries/gologger"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Bucket is a bucket of an experiment. Entities are assigned to buckets in proportion to their weights
+type Bucket struct {
+	Name   string  `json:"name"`
+	Weight float64 `json:"weight"`
+}
+
+func validateBuckets(buckets []Bucket) error {
+	if len(buckets) == 0 {
+		return errors.New("experiment has no buckets")
+	}
+	total := 0.0
+	names := make(map[string]bool, len(buckets))
+	for _, bucket := range buckets {
+		if bucket.Name == "" || bucket.Weight < 0 {
+			return fmt.Errorf("bucket %q has an empty name or a negative weight", bucket.Name)
+		}
+		if names[bucket.Name] {
+			return fmt.Errorf("bucket %q is defined twice", bucket.Name)
+		}
+		names[bucket.Name] = true
+		total += bucket.Weight
+	}
+	if total <= 0 {
+		return errors.New("weights of the buckets add up to 0")
+	}
+	return nil
+}
+
+// assign returns the bucket of the entity. The hash of the entity is mapped to the cumulative weights
+// of the buckets in their configured order, so an entity stays in its bucket while the splits are unchanged
+func (f *Flag) assign(name string, entity string) string {
+	total := 0.0
+	for _, bucket := range f.Buckets {
+		total += bucket.Weight
+	}
+	point := float64(entityHash(name, entity)%10000) / 10000 * total
+	cumulative := 0.0
+	for _, bucket := range f.Buckets {
+		cumulative += bucket.Weight
+		if point < cumulative {
+			return bucket.Name
+		}
+	}
+	return f.Buckets[len(f.Buckets)-1].Name
+}
+
+type assignmentsKey struct{}
+
+// Assign returns the bucket of the entity in the experiment, or defaultBucket if the experiment does not
+// exist or has expired. The assignment is added as the experiment.<name> attribute to the span of ctx and
+// stored in the returned context, so that it can be added to logs with AssignmentPairs
+func (c *Client) Assign(ctx context.Context, experiment string, entity string, defaultBucket string) (context.Context, string) {
+	bucket := defaultBucket
+	if flag := c.flag(experiment, ExperimentFlag); flag != nil {
+		bucket = flag.assign(experiment, entity)
+		c.record(experiment, bucket)
+	} else {
+		c.record(experiment, "default")
+	}
+	trace.SpanFromContext(ctx).SetAttributes(attribute.String("experiment."+experiment, bucket))
+
+	previous := Assignments(ctx)
+	assignments := make(map[string]string, len(previous)+1)
+	for k, v := range previous {
+		assignments[k] = v
+	}
+	assignments[experiment] = bucket
+	return context.WithValue(ctx, assignmentsKey{}, assignments), bucket
+}
+
+// Assignments returns the buckets assigned in ctx by experiment
+func Assignments(ctx context.Context) map[string]string {
+	assignments, _ := ctx.Value(assignmentsKey{}).(map[string]string)
+	return assignments
+}
+
+// AssignmentPairs returns the buckets assigned in ctx as log pairs with the keys experiment.<name>, sorted by key
+func AssignmentPairs(ctx context.Context) []gologger.Pair {
+	assignments := Assignments(ctx)
+	pairs := make([]gologger.Pair, 0, len(assignments))
+	for experiment, bucket := range assignments {
+		pairs = append(pairs, gologger.Pair{Key: "experiment." + experiment, Value: bucket})
+	}
+	sort.Slice(pairs, func(i, j int) bool { return pairs[i].Key < pairs[j].Key })
+	return pairs
+}
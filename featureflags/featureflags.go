@@ -0,0 +1,208 @@
+// Package featureflags evaluates feature flags stored as json under a consul KV prefix.
+// Flags are cached in memory and updated by a consul watch, so evaluation does not call consul
+package featureflags
+
+import (
+	"context"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/carwale/golibraries/consulagent"
+	"github.com/carwale/golibraries/gologger"
+	"github.com/carwale/golibraries/goutilities"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Option sets a parameter for the Client
+type Option func(c *Client)
+
+// SetNamespace sets the namespace whose overrides are applied to the flags, e.g. the kubernetes namespace
+func SetNamespace(namespace string) Option {
+	return func(c *Client) { c.namespace = namespace }
+}
+
+// SetLogger sets the logger of the client
+func SetLogger(logger gologger.ILogger) Option {
+	return func(c *Client) { c.logger = logger }
+}
+
+// SetLatencyLogger sets the latency logger used for the evaluation metrics
+func SetLatencyLogger(latencyLogger gologger.IMultiLogger) Option {
+	return func(c *Client) { c.latencyLogger = latencyLogger }
+}
+
+const evaluationsMetricID = "FEATUREFLAG-EVALUATIONS"
+
+var featureflagsMetrics = gologger.NewMetricSet(func(logger gologger.ILogger) map[string]gologger.IMetricVec {
+	return map[string]gologger.IMetricVec{
+		evaluationsMetricID: gologger.NewCounterMetric(prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "featureflag_evaluations_total",
+				Help: "Number of feature flag evaluations by result",
+			},
+			[]string{"Flag", "Result"},
+		), logger),
+	}
+})
+
+// Client evaluates the flags under a consul prefix
+type Client struct {
+	consulAgent   *consulagent.ConsulAgent
+	prefix        string
+	namespace     string
+	logger        gologger.ILogger
+	latencyLogger gologger.IMultiLogger
+	lock          sync.RWMutex
+	flags         map[string]*Flag
+	values        map[string]string // the json of the flags, an unchanged json keeps its parsed flag
+	cancel        context.CancelFunc
+	loaded        chan struct{}
+}
+
+// NewClient returns a client for the flags under the prefix and starts watching them.
+// Flags are named by their key relative to the prefix
+func NewClient(consulAgent *consulagent.ConsulAgent, prefix string, options ...Option) *Client {
+	if !strings.HasSuffix(prefix, "/") {
+		prefix += "/"
+	}
+	c := &Client{
+		consulAgent: consulAgent,
+		prefix:      prefix,
+		flags:       make(map[string]*Flag),
+		loaded:      make(chan struct{}),
+	}
+	for _, option := range options {
+		option(c)
+	}
+	if c.logger == nil {
+		c.logger = gologger.NewLogger()
+	}
+	if c.latencyLogger == nil {
+		c.latencyLogger = gologger.NewRateLatencyLogger(gologger.SetLogger(c.logger))
+	}
+	featureflagsMetrics.AddTo(c.latencyLogger, c.logger)
+	ctx, cancel := context.WithCancel(context.Background())
+	c.cancel = cancel
+	if consulAgent != nil {
+		goutilities.GoCtx(ctx, "featureflags "+prefix, func(ctx context.Context) {
+			consulAgent.WatchPrefix(ctx, prefix, 10*time.Second, c.update)
+		})
+	}
+	return c
+}
+
+// WaitLoaded waits until the flags have been loaded from consul or the timeout expires.
+// It returns false on timeout, in which case flags evaluate to their defaults
+func (c *Client) WaitLoaded(timeout time.Duration) bool {
+	select {
+	case <-c.loaded:
+		return true
+	case <-time.After(timeout):
+		return false
+	}
+}
+
+// Close stops watching the flags
+func (c *Client) Close() {
+	c.cancel()
+}
+
+// update replaces the cached flags. Invalid flags are logged and keep their previous definition
+func (c *Client) update(values map[string][]byte) {
+	c.lock.RLock()
+	previous, previousValues := c.flags, c.values
+	c.lock.RUnlock()
+
+	flags := make(map[string]*Flag, len(values))
+	flagValues := make(map[string]string, len(values))
+	for key, value := range values {
+		name := strings.TrimPrefix(key, c.prefix)
+		if name == "" || strings.HasSuffix(name, "/") {
+			continue
+		}
+		flagValues[name] = string(value)
+		if old, ok := previous[name]; ok && previousValues[name] == string(value) {
+			flags[name] = old
+			continue
+		}
+		flag, err := parseFlag(name, value, c.namespace)
+		if err != nil {
+			c.logger.LogError("Could not parse feature flag", err)
+			if old, ok := previous[name]; ok {
+				flags[name] = old
+			}
+			continue
+		}
+		flags[name] = flag
+	}
+	c.lock.Lock()
+	c.flags = flags
+	c.values = flagValues
+	c.lock.Unlock()
+	select {
+	case <-c.loaded:
+	default:
+		close(c.loaded)
+	}
+}
+
+func (c *Client) flag(name string, flagType FlagType) *Flag {
+	c.lock.RLock()
+	flag, ok := c.flags[name]
+	c.lock.RUnlock()
+	if !ok {
+		return nil
+	}
+	if flag.Type != flagType {
+		// Logged once per version of the flag, as flags are evaluated on every request
+		if !flag.mismatchLogged.Swap(true) {
+			c.logger.LogWarningf("Feature flag %s is a %s flag and not a %s flag", name, flag.Type, flagType)
+		}
+		return nil
+	}
+	if flag.isExpired(time.Now()) {
+		return nil
+	}
+	return flag
+}
+
+// Bool returns the value of the bool flag, or defaultValue if the flag does not exist or has expired
+func (c *Client) Bool(name string, defaultValue bool) bool {
+	flag := c.flag(name, BoolFlag)
+	if flag == nil {
+		c.record(name, "default")
+		return defaultValue
+	}
+	c.record(name, strconv.FormatBool(flag.Enabled))
+	return flag.Enabled
+}
+
+// Percentage returns true if the entity is in the rollout percentage of the flag.
+// The same entity always gets the same result for a percentage. It returns false if the flag does not exist or has expired
+func (c *Client) Percentage(name string, entity string) bool {
+	flag := c.flag(name, PercentageFlag)
+	if flag == nil {
+		c.record(name, "default")
+		return false
+	}
+	enabled := flag.isEnabledFor(name, entity)
+	c.record(name, strconv.FormatBool(enabled))
+	return enabled
+}
+
+// String returns the value of the string flag, or defaultValue if the flag does not exist or has expired
+func (c *Client) String(name string, defaultValue string) string {
+	flag := c.flag(name, StringFlag)
+	if flag == nil {
+		c.record(name, "default")
+		return defaultValue
+	}
+	c.record(name, "value")
+	return flag.Value
+}
+
+func (c *Client) record(name string, result string) {
+	c.latencyLogger.IncVal(1, evaluationsMetricID, name, result)
+}
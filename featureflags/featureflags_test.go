@@ -0,0 +1,143 @@
+package featureflags
+
+import (
+	"bytes"
+	"context"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/carwale/golibraries/gologger"
+)
+
+type nopMultiLogger struct{}
+
+func (nopMultiLogger) AddNewMetric(string, gologger.IMetricVec) {}
+func (nopMultiLogger) Tic() time.Time                           { return time.Now() }
+func (nopMultiLogger) Toc(time.Time, string, ...string)         {}
+func (nopMultiLogger) IncVal(int64, string, ...string)          {}
+func (nopMultiLogger) SubVal(int64, string, ...string)          {}
+func (nopMultiLogger) SetVal(int64, string, ...string)          {}
+
+func newTestClient(namespace string, values map[string]string) *Client {
+	c := NewClient(nil, "flags", SetNamespace(namespace), SetLatencyLogger(nopMultiLogger{}))
+	pairs := make(map[string][]byte, len(values))
+	for key, value := range values {
+		pairs["flags/"+key] = []byte(value)
+	}
+	c.update(pairs)
+	return c
+}
+
+func TestBoolFlag(t *testing.T) {
+	c := newTestClient("dev", map[string]string{
+		"access_logs": `{"type":"bool","enabled":false,"overrides":{"dev":{"enabled":true}}}`,
+		"expired":     `{"type":"bool","enabled":true,"expires":"2000-01-01T00:00:00Z"}`,
+		"invalid":     `{"type":"bool"`,
+	})
+	if !c.WaitLoaded(time.Second) {
+		t.Fatal("WaitLoaded() = false after update")
+	}
+	if !c.Bool("access_logs", false) {
+		t.Error("Bool() = false, want the override of the namespace")
+	}
+	if c.Bool("expired", false) {
+		t.Error("Bool() = true for an expired flag, want the default")
+	}
+	if !c.Bool("missing", true) || !c.Bool("invalid", true) {
+		t.Error("Bool() should return the default for missing and invalid flags")
+	}
+}
+
+func TestPercentageFlag(t *testing.T) {
+	c := newTestClient("", map[string]string{"rollout": `{"type":"percentage","percentage":30}`})
+	enabled := 0
+	for i := 0; i < 10000; i++ {
+		entity := strconv.Itoa(i)
+		result := c.Percentage("rollout", entity)
+		if result != c.Percentage("rollout", entity) {
+			t.Fatalf("Percentage() is not stable for entity %s", entity)
+		}
+		if result {
+			enabled++
+		}
+	}
+	if enabled < 2700 || enabled > 3300 {
+		t.Errorf("Percentage() enabled %d of 10000 entities, want about 3000", enabled)
+	}
+}
+
+func TestStringFlagTypeMismatch(t *testing.T) {
+	var logs bytes.Buffer
+	c := NewClient(nil, "flags", SetLatencyLogger(nopMultiLogger{}),
+		SetLogger(gologger.NewLogger(gologger.SetOutput(&logs), gologger.DisableGraylog(true), gologger.SetLogLevel("WARN"))))
+	c.update(map[string][]byte{"flags/variant": []byte(`{"type":"string","value":"b"}`)})
+	if v := c.String("variant", "a"); v != "b" {
+		t.Errorf("String() = %s, want b", v)
+	}
+	for i := 0; i < 3; i++ {
+		if c.Bool("variant", false) {
+			t.Error("Bool() of a string flag should return the default")
+		}
+	}
+	if n := strings.Count(logs.String(), "is a string flag"); n != 1 {
+		t.Errorf("type mismatch logged %d times, want once", n)
+	}
+
+	// An update with the same value keeps the version of the flag, a new value is a new version
+	c.update(map[string][]byte{"flags/variant": []byte(`{"type":"string","value":"b"}`)})
+	c.Bool("variant", false)
+	c.update(map[string][]byte{"flags/variant": []byte(`{"type":"string","value":"c"}`)})
+	c.Bool("variant", false)
+	if n := strings.Count(logs.String(), "is a string flag"); n != 2 {
+		t.Errorf("type mismatch logged %d times, want once per version", n)
+	}
+}
+
+func TestParseFlagInvalid(t *testing.T) {
+	for _, data := range []string{`{"type":"unknown"}`, `{"type":"percentage","percentage":120}`} {
+		if _, err := parseFlag("flag", []byte(data), ""); err == nil {
+			t.Errorf("parseFlag(%s) should return an error", data)
+		}
+	}
+}
+
+func TestAssign(t *testing.T) {
+	c := newTestClient("", map[string]string{
+		"checkout": `{"type":"experiment","buckets":[{"name":"control","weight":50},{"name":"new","weight":50}]}`,
+	})
+	counts := map[string]int{}
+	for i := 0; i < 1000; i++ {
+		_, bucket := c.Assign(context.Background(), "checkout", strconv.Itoa(i), "control")
+		if _, again := c.Assign(context.Background(), "checkout", strconv.Itoa(i), "control"); again != bucket {
+			t.Fatalf("Assign() is not sticky for entity %d", i)
+		}
+		counts[bucket]++
+	}
+	if counts["control"] < 400 || counts["new"] < 400 {
+		t.Errorf("Assign() counts = %v, want about 500 each", counts)
+	}
+
+	ctx, _ := c.Assign(context.Background(), "checkout", "user-1", "control")
+	ctx, bucket := c.Assign(ctx, "missing", "user-1", "off")
+	if bucket != "off" {
+		t.Errorf("Assign() = %s for a missing experiment, want the default", bucket)
+	}
+	pairs := AssignmentPairs(ctx)
+	if len(pairs) != 2 || pairs[0].Key != "experiment.checkout" || pairs[1].Value != "off" {
+		t.Errorf("AssignmentPairs() = %v", pairs)
+	}
+}
+
+func TestValidateBuckets(t *testing.T) {
+	for _, data := range []string{
+		`{"type":"experiment"}`,
+		`{"type":"experiment","buckets":[{"name":"a","weight":0}]}`,
+		`{"type":"experiment","buckets":[{"name":"a","weight":1},{"name":"a","weight":1}]}`,
+	} {
+		if _, err := parseFlag("experiment", []byte(data), ""); err == nil {
+			t.Errorf("parseFlag(%s) should return an error", data)
+		}
+	}
+}
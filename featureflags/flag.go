@@ -0,0 +1,98 @@
+package featureflags
+
+import (
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"sync/atomic"
+	"time"
+)
+
+// FlagType is the type of a flag
+type FlagType string
+
+// The types of flags
+const (
+	// BoolFlag is on or off
+	BoolFlag FlagType = "bool"
+	// PercentageFlag is on for a stable percentage of entities (users, dealers, requests)
+	PercentageFlag FlagType = "percentage"
+	// StringFlag holds a string value
+	StringFlag FlagType = "string"
+	// ExperimentFlag assigns entities to weighted buckets
+	ExperimentFlag FlagType = "experiment"
+)
+
+// Flag is the definition of a flag stored as json in consul, e.g.
+//
+//	{"type": "percentage", "percentage": 10, "expires": "2024-05-01T00:00:00Z", "overrides": {"dev": {"percentage": 100}}}
+//
+// An expired flag evaluates to the default value of the caller
+type Flag struct {
+	Type       FlagType `json:"type"`
+	Enabled    bool     `json:"enabled,omitempty"`
+	Percentage float64  `json:"percentage,omitempty"`
+	Value      string   `json:"value,omitempty"`
+	// Buckets are the weighted buckets of an experiment flag
+	Buckets []Bucket `json:"buckets,omitempty"`
+	// Expires turns the flag off after the time. It replaces the convention of storing an end time as the value of a key
+	Expires *time.Time `json:"expires,omitempty"`
+	// Overrides replace the flag in a namespace. Only the fields set in the override are replaced
+	Overrides map[string]json.RawMessage `json:"overrides,omitempty"`
+	// mismatchLogged is set once an evaluation with another type has been logged for this version of the flag
+	mismatchLogged atomic.Bool
+}
+
+// parseFlag decodes the flag and applies the override of the namespace
+func parseFlag(name string, data []byte, namespace string) (*Flag, error) {
+	flag := &Flag{}
+	if err := json.Unmarshal(data, flag); err != nil {
+		return nil, fmt.Errorf("invalid flag %s: %w", name, err)
+	}
+	if override, ok := flag.Overrides[namespace]; ok && namespace != "" {
+		// Fields missing from the override keep their values
+		if err := json.Unmarshal(override, flag); err != nil {
+			return nil, fmt.Errorf("invalid override %s of flag %s: %w", namespace, name, err)
+		}
+	}
+	flag.Overrides = nil
+	switch flag.Type {
+	case BoolFlag, StringFlag:
+	case PercentageFlag:
+		if flag.Percentage < 0 || flag.Percentage > 100 {
+			return nil, fmt.Errorf("invalid flag %s: percentage %v is not between 0 and 100", name, flag.Percentage)
+		}
+	case ExperimentFlag:
+		if err := validateBuckets(flag.Buckets); err != nil {
+			return nil, fmt.Errorf("invalid flag %s: %w", name, err)
+		}
+	default:
+		return nil, fmt.Errorf("invalid flag %s: unknown type %q", name, flag.Type)
+	}
+	return flag, nil
+}
+
+func (f *Flag) isExpired(now time.Time) bool {
+	return f.Expires != nil && now.After(*f.Expires)
+}
+
+// isEnabledFor returns true if the entity is in the percentage of the flag.
+// The bucket of an entity depends on the flag name, so that flags are not enabled for the same entities
+func (f *Flag) isEnabledFor(name string, entity string) bool {
+	if f.Percentage <= 0 {
+		return false
+	}
+	if f.Percentage >= 100 {
+		return true
+	}
+	return float64(entityHash(name, entity)%10000) < f.Percentage*100
+}
+
+// entityHash returns the hash of the entity for the flag
+func entityHash(name string, entity string) uint32 {
+	h := fnv.New32a()
+	h.Write([]byte(name))
+	h.Write([]byte{0})
+	h.Write([]byte(entity))
+	return h.Sum32()
+}
@@ -0,0 +1,176 @@
+package gologger
+
+import (
+	"context"
+	"io"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// OverflowPolicy is what the async graylog writer does with a message when its queue is full
+type OverflowPolicy int
+
+const (
+	// OverflowDropNewest drops the message being logged
+	OverflowDropNewest OverflowPolicy = iota
+	// OverflowDropOldest drops the oldest queued message to make room for the message being logged
+	OverflowDropOldest
+	// OverflowBlock blocks the logging call until there is room in the queue
+	OverflowBlock
+)
+
+func (p OverflowPolicy) String() string {
+	switch p {
+	case OverflowDropNewest:
+		return "drop_newest"
+	case OverflowDropOldest:
+		return "drop_oldest"
+	case OverflowBlock:
+		return "block"
+	}
+	return "unknown"
+}
+
+// SetAsyncGraylog sends the messages to graylog from a background goroutine, so that the logging calls do not
+// wait for the network. Up to queueSize messages are queued; when the queue is full the message is handled
+// as per the policy, and the dropped messages are counted in the gologger_graylog_dropped_total metric of
+// the latency logger. Call Flush before the service exits to send the queued messages. Default is to send
+// the messages synchronously
+func SetAsyncGraylog(queueSize int, policy OverflowPolicy) Option {
+	return func(l *CustomLogger) {
+		if queueSize <= 0 {
+			panic("gologger: async graylog queue size must be positive")
+		}
+		l.asyncQueueSize = queueSize
+		l.asyncPolicy = policy
+	}
+}
+
+// SetAsyncLatencyLogger sets the latency logger used for the metrics of the async graylog writer.
+// Default is the RateLatencyLogger
+func SetAsyncLatencyLogger(latencyLogger IMultiLogger) Option {
+	return func(l *CustomLogger) { l.asyncLatencyLogger = latencyLogger }
+}
+
+// Flush waits until the messages queued by the async graylog writer are sent, or ctx is done. It does nothing
+// if the writer is synchronous. It can be given to crashreport.AddFlusher
+func (l *CustomLogger) Flush(ctx context.Context) error {
+	if l.async == nil {
+		return nil
+	}
+	return l.async.Flush(ctx)
+}
+
+const graylogDroppedMetricID = "GOLOGGER-GRAYLOG-DROPPED"
+
+var asyncMetrics = NewMetricSet(func(logger ILogger) map[string]IMetricVec {
+	return map[string]IMetricVec{
+		graylogDroppedMetricID: NewCounterMetric(prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "gologger_graylog_dropped_total",
+				Help: "Number of log messages dropped as the async graylog queue was full",
+			},
+			[]string{},
+		), logger),
+	}
+})
+
+// asyncWriter writes to w from a background goroutine through a bounded queue
+type asyncWriter struct {
+	w             io.Writer
+	queue         chan []byte
+	flush         chan chan struct{}
+	policy        OverflowPolicy
+	latencyLogger IMultiLogger
+	// writeErrors counts the failed writes to w, it is nil when the self metrics are disabled
+	writeErrors prometheus.Counter
+}
+
+func newAsyncWriter(w io.Writer, queueSize int, policy OverflowPolicy) *asyncWriter {
+	aw := &asyncWriter{
+		w:      w,
+		queue:  make(chan []byte, queueSize),
+		flush:  make(chan chan struct{}),
+		policy: policy,
+	}
+	go aw.run()
+	return aw
+}
+
+// setLatencyLogger registers the metrics of the writer with the latency logger. It must be called before the
+// first write
+func (aw *asyncWriter) setLatencyLogger(logger ILogger, latencyLogger IMultiLogger) {
+	asyncMetrics.AddTo(latencyLogger, logger)
+	aw.latencyLogger = latencyLogger
+}
+
+// Write queues a copy of p, as the log package reuses its buffer. It never fails
+func (aw *asyncWriter) Write(p []byte) (int, error) {
+	message := append([]byte(nil), p...)
+	switch aw.policy {
+	case OverflowBlock:
+		aw.queue <- message
+	case OverflowDropOldest:
+		for {
+			select {
+			case aw.queue <- message:
+				return len(p), nil
+			default:
+			}
+			select {
+			case <-aw.queue:
+				aw.latencyLogger.IncVal(1, graylogDroppedMetricID)
+			default:
+			}
+		}
+	default:
+		select {
+		case aw.queue <- message:
+		default:
+			aw.latencyLogger.IncVal(1, graylogDroppedMetricID)
+		}
+	}
+	return len(p), nil
+}
+
+// Flush waits until the messages queued before the call are written, or ctx is done
+func (aw *asyncWriter) Flush(ctx context.Context) error {
+	flushed := make(chan struct{})
+	select {
+	case aw.flush <- flushed:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	select {
+	case <-flushed:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (aw *asyncWriter) run() {
+	for {
+		select {
+		case message := <-aw.queue:
+			aw.write(message)
+		case flushed := <-aw.flush:
+			// Drain the messages queued before the flush
+			for n := len(aw.queue); n > 0; n-- {
+				select {
+				case message := <-aw.queue:
+					aw.write(message)
+				default:
+					n = 0
+				}
+			}
+			close(flushed)
+		}
+	}
+}
+
+func (aw *asyncWriter) write(message []byte) {
+	if _, err := aw.w.Write(message); err != nil && aw.writeErrors != nil {
+		aw.writeErrors.Inc()
+	}
+}
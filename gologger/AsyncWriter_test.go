@@ -0,0 +1,78 @@
+package gologger
+
+import (
+	"bytes"
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// droppedCounter is a latency logger counting the dropped messages
+type droppedCounter struct {
+	IMultiLogger
+	dropped int64
+}
+
+func (c *droppedCounter) IncVal(value int64, identifier string, labels ...string) {
+	if identifier == graylogDroppedMetricID {
+		atomic.AddInt64(&c.dropped, value)
+	}
+}
+
+// slowWriter blocks every write until release is closed
+type slowWriter struct {
+	release chan struct{}
+	mu      sync.Mutex
+	buffer  bytes.Buffer
+}
+
+func (w *slowWriter) Write(p []byte) (int, error) {
+	<-w.release
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.buffer.Write(p)
+}
+
+func TestAsyncWriterDropsWhenFull(t *testing.T) {
+	for _, test := range []struct {
+		policy OverflowPolicy
+		want   string
+	}{
+		{OverflowDropNewest, "12"},
+		{OverflowDropOldest, "14"},
+	} {
+		w := &slowWriter{release: make(chan struct{})}
+		counter := &droppedCounter{}
+		aw := newAsyncWriter(w, 1, test.policy)
+		aw.latencyLogger = counter
+
+		aw.Write([]byte("1"))
+		// wait for the flusher to block on the first message, leaving the queue empty
+		for len(aw.queue) > 0 {
+			time.Sleep(time.Millisecond)
+		}
+		start := time.Now()
+		for _, message := range []string{"2", "3", "4"} {
+			aw.Write([]byte(message))
+		}
+		if time.Since(start) > 100*time.Millisecond {
+			t.Errorf("%v: Write() blocked", test.policy)
+		}
+		close(w.release)
+		if err := aw.Flush(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+		if got := w.buffer.String(); got != test.want || atomic.LoadInt64(&counter.dropped) != 2 {
+			t.Errorf("%v: wrote %q and dropped %d, want %q and 2", test.policy, got, counter.dropped, test.want)
+		}
+	}
+}
+
+func TestFlushWithoutAsyncWriter(t *testing.T) {
+	logger := NewLogger(DisableGraylog(true), SetAsyncGraylog(10, OverflowBlock))
+	if err := logger.Flush(context.Background()); err != nil {
+		t.Errorf("Flush() = %v", err)
+	}
+}
@@ -0,0 +1,30 @@
+package gologger
+
+import "context"
+
+type logLevelKey struct{}
+
+// WithLogLevel returns a copy of ctx with a log level override. The *WithContext methods of the logger
+// log messages up to this level for the context even if the level of the logger is lower.
+// It is used to get debug logs for a single request without changing the level of the logger
+func WithLogLevel(ctx context.Context, level LogLevels) context.Context {
+	return context.WithValue(ctx, logLevelKey{}, level)
+}
+
+// LogLevelFromContext returns the log level override of the context
+func LogLevelFromContext(ctx context.Context) (LogLevels, bool) {
+	if ctx == nil {
+		return ERROR, false
+	}
+	level, ok := ctx.Value(logLevelKey{}).(LogLevels)
+	return level, ok
+}
+
+// isEnabledForContext returns true if the level is enabled in the logger or by the override of the context
+func (l *CustomLogger) isEnabledForContext(ctx context.Context, level LogLevels) bool {
+	if l.GetLogLevel() >= level {
+		return true
+	}
+	override, ok := LogLevelFromContext(ctx)
+	return ok && override >= level
+}
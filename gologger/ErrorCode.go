@@ -0,0 +1,48 @@
+package gologger
+
+import "errors"
+
+// ErrorCodeKey is the key of the pair holding the error code of an error log, e.g. Pair{ErrorCodeKey, "DB_TIMEOUT"}
+const ErrorCodeKey = "error_code"
+
+// UnknownErrorCode is the error code of errors logged without a code
+const UnknownErrorCode = "unknown"
+
+// ICodedError is implemented by errors carrying an error code. The code is used as the label of the error metrics,
+// so it should come from a small fixed set
+type ICodedError interface {
+	error
+	ErrorCode() string
+}
+
+type codedError struct {
+	err  error
+	code string
+}
+
+func (e *codedError) Error() string     { return e.err.Error() }
+func (e *codedError) Unwrap() error     { return e.err }
+func (e *codedError) ErrorCode() string { return e.code }
+
+// WithErrorCode wraps the error with an error code
+func WithErrorCode(err error, code string) error {
+	if err == nil {
+		return nil
+	}
+	return &codedError{err: err, code: code}
+}
+
+// errorCode returns the code of an error log: the value of the ErrorCodeKey pair, else the code of
+// the first ICodedError in the chain of err, else UnknownErrorCode
+func errorCode(err error, pairs []Pair) string {
+	for _, pair := range pairs {
+		if pair.Key == ErrorCodeKey && pair.Value != "" {
+			return pair.Value
+		}
+	}
+	var coded ICodedError
+	if err != nil && errors.As(err, &coded) && coded.ErrorCode() != "" {
+		return coded.ErrorCode()
+	}
+	return UnknownErrorCode
+}
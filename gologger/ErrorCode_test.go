@@ -0,0 +1,37 @@
+package gologger
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+)
+
+func TestErrorCode(t *testing.T) {
+	coded := WithErrorCode(errors.New("timeout"), "DB_TIMEOUT")
+	tests := []struct {
+		name  string
+		err   error
+		pairs []Pair
+		want  string
+	}{
+		{"no code", errors.New("failed"), nil, UnknownErrorCode},
+		{"nil error", nil, nil, UnknownErrorCode},
+		{"coded error", coded, nil, "DB_TIMEOUT"},
+		{"wrapped coded error", fmt.Errorf("get stock: %w", coded), nil, "DB_TIMEOUT"},
+		{"pair overrides error", coded, []Pair{{ErrorCodeKey, "STOCK_NOT_FOUND"}}, "STOCK_NOT_FOUND"},
+		{"empty pair is ignored", coded, []Pair{{ErrorCodeKey, ""}}, "DB_TIMEOUT"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := errorCode(tt.err, tt.pairs); got != tt.want {
+				t.Errorf("errorCode() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+	if !errors.Is(coded, errors.Unwrap(coded)) || coded.Error() != "timeout" {
+		t.Errorf("coded error does not wrap the error: %v", coded)
+	}
+	if WithErrorCode(nil, "DB_TIMEOUT") != nil {
+		t.Error("WithErrorCode(nil) is not nil")
+	}
+}
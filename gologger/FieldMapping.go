@@ -0,0 +1,128 @@
+package gologger
+
+import (
+	"os"
+	"strconv"
+	"time"
+)
+
+// FieldMapping decides the names and the formats of the standard fields of the log messages
+type FieldMapping struct {
+	// Message is the name of the message field
+	Message string
+	// Level is the name of the level field
+	Level string
+	// NumericLevel logs the level as the numeric syslog severity (3 for ERROR, 4 for WARN, 6 for INFO, 7 for DEBUG)
+	NumericLevel bool
+	// Timestamp is the name of the timestamp field
+	Timestamp string
+	// EpochTimestamp logs the timestamp as seconds since the epoch with millisecond precision
+	EpochTimestamp bool
+	// Facility is the name of the facility field
+	Facility string
+	// Namespace is the name of the k8s namespace field
+	Namespace string
+	// Host is the name of the field with the hostname. The hostname is not logged if it is empty
+	Host string
+	// Version is the value of the version field. The version is not logged if it is empty
+	Version string
+	// ExtraFieldPrefix is added to the names of all the other fields (pairs, errors and extension fields)
+	ExtraFieldPrefix string
+}
+
+// DefaultFieldMapping is the mapping used by the logger if no mapping is set
+var DefaultFieldMapping = FieldMapping{
+	Message:   "log_message",
+	Level:     "log_level",
+	Timestamp: "log_timestamp",
+	Facility:  "log_facility",
+	Namespace: "K8sNamespace",
+}
+
+// GELFFieldMapping maps the fields to the GELF 1.1 spec. Additional fields are prefixed with an underscore.
+// The lines are sent to graylog as they are, instead of as the short message of a GELF message
+var GELFFieldMapping = FieldMapping{
+	Message:          "short_message",
+	Level:            "level",
+	NumericLevel:     true,
+	Timestamp:        "timestamp",
+	EpochTimestamp:   true,
+	Facility:         "_facility",
+	Namespace:        "_K8sNamespace",
+	Host:             "host",
+	Version:          "1.1",
+	ExtraFieldPrefix: "_",
+}
+
+// SetFieldMapping sets the mapping of the field names of the log messages.
+// Use GELFFieldMapping to log messages that conform to the GELF spec. Default is DefaultFieldMapping.
+// The mapping is not used for the time logs of Toc and TicCtx
+func SetFieldMapping(mapping FieldMapping) Option {
+	return func(l *CustomLogger) {
+		l.fieldMapping = &mapping
+		if mapping.Host != "" {
+			l.hostname, _ = os.Hostname()
+		}
+	}
+}
+
+// isGELF returns true if the entries of the mapping are GELF payloads, with the version, host and short_message
+func (m *FieldMapping) isGELF() bool {
+	return m.Version != "" && m.Host == "host" && m.Message == "short_message"
+}
+
+// mapping returns the field mapping of the logger
+func (l *CustomLogger) mapping() *FieldMapping {
+	if l.fieldMapping == nil {
+		return &DefaultFieldMapping
+	}
+	return l.fieldMapping
+}
+
+// syslogSeverity returns the syslog severity of the level
+func syslogSeverity(level LogLevels) int64 {
+	switch level {
+	case ERROR:
+		return 3
+	case WARN:
+		return 4
+	case INFO:
+		return 6
+	default:
+		return 7
+	}
+}
+
+// appendStandardFields appends the standard fields of every message using the mapping and closes the object
+func (m *FieldMapping) appendStandardFields(buf []byte, l *CustomLogger, message string, level LogLevels) []byte {
+	if m.Version != "" {
+		buf = appendPair(buf, "version", m.Version)
+	}
+	if m.Host != "" {
+		buf = appendPair(buf, m.Host, l.hostname)
+	}
+	buf = appendKey(buf, m.Level)
+	if m.NumericLevel {
+		buf = strconv.AppendInt(buf, syslogSeverity(level), 10)
+		buf = append(buf, ',')
+	} else {
+		buf = appendJSONString(buf, level.String())
+		buf = append(buf, ',')
+	}
+	now := time.Now()
+	buf = appendKey(buf, m.Timestamp)
+	if m.EpochTimestamp {
+		buf = strconv.AppendFloat(buf, float64(now.UnixMilli())/1000, 'f', 3, 64)
+		buf = append(buf, ',')
+	} else {
+		buf = append(buf, '"')
+		buf = now.AppendFormat(buf, timestampLayout)
+		buf = append(buf, '"', ',')
+	}
+	buf = appendPair(buf, m.Facility, l.graylogFacility)
+	buf = appendPair(buf, m.Message, message)
+	buf = appendPair(buf, m.Namespace, l.k8sNamespace)
+	// Replace the trailing comma
+	buf[len(buf)-1] = '}'
+	return buf
+}
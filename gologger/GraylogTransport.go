@@ -0,0 +1,267 @@
+package gologger
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"crypto/rand"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// The transports of GraylogTransport
+const (
+	GraylogUDP = "udp"
+	GraylogTCP = "tcp"
+	GraylogTLS = "tls"
+)
+
+const (
+	graylogDialTimeout  = 5 * time.Second
+	graylogWriteTimeout = 5 * time.Second
+	graylogMinBackoff   = 100 * time.Millisecond
+	graylogMaxBackoff   = 30 * time.Second
+	// gelfChunkSize is the size of the udp datagrams, below the MTU of the network
+	gelfChunkSize = 1420
+	// gelfChunkHeaderSize is the size of the magic bytes, message id, sequence number and count of a chunk
+	gelfChunkHeaderSize = 12
+	gelfMaxChunks       = 128
+)
+
+// errGraylogBackoff is returned by the writes made while the writer waits to reconnect
+var errGraylogBackoff = errors.New("graylog is unreachable, waiting to reconnect")
+
+// GraylogTransport sets the protocol used to send the messages to graylog: udp, tcp or tls. UDP drops the
+// messages when graylog or the network is overloaded; tcp and tls do not, but a logging call waits for the
+// connection, so they are best used with SetAsyncGraylog. The connection is opened on the first message and
+// opened again when it fails, waiting up to 30 seconds between attempts; the messages logged while waiting
+// are dropped and counted as write errors. Default is udp
+func GraylogTransport(transport string) Option {
+	return func(l *CustomLogger) {
+		switch transport {
+		case GraylogUDP, GraylogTCP, GraylogTLS:
+			l.graylogTransport = transport
+		default:
+			panic(fmt.Sprintf("gologger: unknown graylog transport %q, want udp, tcp or tls", transport))
+		}
+	}
+}
+
+// SetGraylogTLSConfig sets the tls configuration of the tls transport, e.g. to trust a private CA or to send a
+// client certificate. Default verifies the certificate of graylog with the system roots
+func SetGraylogTLSConfig(config *tls.Config) Option {
+	return func(l *CustomLogger) { l.graylogTLSConfig = config }
+}
+
+// graylogOutput sends the lines of a logger to graylog as GELF messages. The logger gives the level of every line,
+// so the lines are not parsed again. The messages do not have the _file and _line fields that the go-gelf writer
+// used to add, as they were the frame of gologger which wrote the line and not the caller of the logger
+type graylogOutput struct {
+	// w sends the GELF payloads, it is a transport writer, possibly behind the async writer
+	w        io.Writer
+	hostname string
+	facility string
+	// gelfEntries is true when the entries are logged with a GELF field mapping, and so are sent as they are
+	gelfEntries bool
+}
+
+// write sends a line of the level. An entry is a line formatted with the field mapping of the logger; other lines,
+// like the time logs of Toc or plain text, and the entries of other mappings are sent as the short message
+func (g *graylogOutput) write(line string, level LogLevels, entry bool) {
+	buf := getBuffer()
+	if entry && g.gelfEntries {
+		*buf = append(*buf, line...)
+	} else {
+		*buf = g.appendMessage(*buf, line, level)
+	}
+	// the write errors are counted by the writer
+	g.w.Write(*buf)
+	putBuffer(buf)
+}
+
+// appendMessage appends the GELF message with the line as the short message
+func (g *graylogOutput) appendMessage(buf []byte, line string, level LogLevels) []byte {
+	buf = append(buf, `{"version":"1.1",`...)
+	buf = appendPair(buf, "host", g.hostname)
+	buf = appendPair(buf, "short_message", line)
+	buf = append(buf, `"timestamp":`...)
+	buf = strconv.AppendFloat(buf, float64(time.Now().UnixNano())/float64(time.Second), 'f', 6, 64)
+	buf = append(buf, `,"level":`...)
+	buf = strconv.AppendInt(buf, syslogSeverity(level), 10)
+	buf = append(buf, ',')
+	buf = appendPair(buf, "facility", g.facility)
+	// Replace the trailing comma
+	buf[len(buf)-1] = '}'
+	return buf
+}
+
+// gelfStreamWriter sends GELF payloads over tcp or tls, separated by null bytes
+type gelfStreamWriter struct {
+	addr      string
+	tlsConfig *tls.Config
+
+	mu      sync.Mutex
+	conn    net.Conn
+	backoff time.Duration
+	retryAt time.Time
+	message []byte
+}
+
+func newGelfStreamWriter(addr string, tlsConfig *tls.Config) *gelfStreamWriter {
+	return &gelfStreamWriter{addr: addr, tlsConfig: tlsConfig}
+}
+
+// Write sends the GELF payload p. A broken connection is replaced once before the write fails
+func (w *gelfStreamWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.message = append(append(w.message[:0], p...), 0)
+	for attempt := 0; ; attempt++ {
+		if err := w.connect(); err != nil {
+			return 0, err
+		}
+		w.conn.SetWriteDeadline(time.Now().Add(graylogWriteTimeout))
+		_, err := w.conn.Write(w.message)
+		if err == nil {
+			return len(p), nil
+		}
+		w.conn.Close()
+		w.conn = nil
+		if attempt > 0 {
+			return 0, err
+		}
+	}
+}
+
+// Close closes the connection
+func (w *gelfStreamWriter) Close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.conn == nil {
+		return nil
+	}
+	err := w.conn.Close()
+	w.conn = nil
+	return err
+}
+
+// connect opens the connection if it is closed. After a failure it does not dial again before the backoff
+func (w *gelfStreamWriter) connect() error {
+	if w.conn != nil {
+		return nil
+	}
+	if time.Now().Before(w.retryAt) {
+		return errGraylogBackoff
+	}
+	dialer := &net.Dialer{Timeout: graylogDialTimeout}
+	var conn net.Conn
+	var err error
+	if w.tlsConfig != nil {
+		conn, err = tls.DialWithDialer(dialer, "tcp", w.addr, w.tlsConfig)
+	} else {
+		conn, err = dialer.Dial("tcp", w.addr)
+	}
+	if err != nil {
+		w.backoff *= 2
+		if w.backoff < graylogMinBackoff {
+			w.backoff = graylogMinBackoff
+		} else if w.backoff > graylogMaxBackoff {
+			w.backoff = graylogMaxBackoff
+		}
+		w.retryAt = time.Now().Add(w.backoff)
+		return err
+	}
+	w.conn = conn
+	w.backoff = 0
+	return nil
+}
+
+// gelfCompressor is a gzip writer with the buffer it compresses to
+type gelfCompressor struct {
+	buf bytes.Buffer
+	zw  *gzip.Writer
+}
+
+var gelfCompressors = sync.Pool{
+	New: func() interface{} {
+		c := &gelfCompressor{}
+		c.zw, _ = gzip.NewWriterLevel(&c.buf, flate.BestSpeed)
+		return c
+	},
+}
+
+// gelfUDPWriter sends GELF payloads over udp, gzip compressed and split in chunks like the go-gelf writer did
+type gelfUDPWriter struct {
+	conn net.Conn
+}
+
+func newGelfUDPWriter(addr string) (*gelfUDPWriter, error) {
+	conn, err := net.Dial("udp", addr)
+	if err != nil {
+		return nil, err
+	}
+	return &gelfUDPWriter{conn: conn}, nil
+}
+
+// Write sends the GELF payload p
+func (w *gelfUDPWriter) Write(p []byte) (int, error) {
+	c := gelfCompressors.Get().(*gelfCompressor)
+	defer gelfCompressors.Put(c)
+	c.buf.Reset()
+	c.zw.Reset(&c.buf)
+	if _, err := c.zw.Write(p); err != nil {
+		return 0, err
+	}
+	if err := c.zw.Close(); err != nil {
+		return 0, err
+	}
+	message := c.buf.Bytes()
+	if len(message) <= gelfChunkSize {
+		if _, err := w.conn.Write(message); err != nil {
+			return 0, err
+		}
+		return len(p), nil
+	}
+	if err := w.writeChunked(message); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// writeChunked sends a message larger than a datagram as chunks with the header of the GELF spec: the magic
+// bytes 0x1e 0x0f, the message id, the sequence number and the count of the chunks
+func (w *gelfUDPWriter) writeChunked(message []byte) error {
+	dataSize := gelfChunkSize - gelfChunkHeaderSize
+	count := (len(message) + dataSize - 1) / dataSize
+	if count > gelfMaxChunks {
+		return fmt.Errorf("gelf message of %d bytes is too large, it needs %d chunks", len(message), count)
+	}
+	chunk := make([]byte, gelfChunkHeaderSize, gelfChunkSize)
+	chunk[0], chunk[1] = 0x1e, 0x0f
+	if _, err := rand.Read(chunk[2:10]); err != nil {
+		return err
+	}
+	chunk[11] = byte(count)
+	for i := 0; i < count; i++ {
+		chunk[10] = byte(i)
+		end := (i + 1) * dataSize
+		if end > len(message) {
+			end = len(message)
+		}
+		if _, err := w.conn.Write(append(chunk[:gelfChunkHeaderSize], message[i*dataSize:end]...)); err != nil {
+			return fmt.Errorf("chunk %d/%d: %w", i+1, count, err)
+		}
+	}
+	return nil
+}
+
+// Close closes the udp connection
+func (w *gelfUDPWriter) Close() error {
+	return w.conn.Close()
+}
@@ -0,0 +1,160 @@
+package gologger
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"crypto/rand"
+	"encoding/json"
+	"io"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestGelfStreamWriterReconnectsAfterClose(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+	messages := make(chan string, 2)
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			// read one message per connection, then drop the connection
+			message, _ := bufio.NewReader(conn).ReadString(0)
+			conn.Close()
+			messages <- message
+		}
+	}()
+
+	w := newGelfStreamWriter(listener.Addr().String(), nil)
+	defer w.Close()
+	for _, payload := range []string{`{"short_message":"first"}`, `{"short_message":"second"}`} {
+		if _, err := w.Write([]byte(payload)); err != nil {
+			t.Fatal(err)
+		}
+		if message := <-messages; message != payload+"\x00" {
+			t.Errorf("received %q, want the null terminated payload %s", message, payload)
+		}
+		// the first write to a connection closed by the peer may not fail, so it is closed here too
+		w.Close()
+	}
+}
+
+func TestGelfStreamWriterBacksOff(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := listener.Addr().String()
+	listener.Close()
+
+	w := newGelfStreamWriter(addr, nil)
+	if _, err := w.Write([]byte("lost")); err == nil || err == errGraylogBackoff {
+		t.Fatalf("first Write() error = %v, want a dial error", err)
+	}
+	if _, err := w.Write([]byte("lost")); err != errGraylogBackoff {
+		t.Errorf("second Write() error = %v, want %v", err, errGraylogBackoff)
+	}
+}
+
+func TestGraylogTransportRejectsUnknown(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Error("GraylogTransport() accepted an unknown transport")
+		}
+	}()
+	GraylogTransport("http")(&CustomLogger{})
+}
+
+func TestGraylogOutputSendsGELFEntriesAsTheyAre(t *testing.T) {
+	var buffer bytes.Buffer
+	g := &graylogOutput{w: &buffer, hostname: "web-1", facility: "app", gelfEntries: true}
+	entry := `{"version":"1.1","host":"web-1","short_message":"saved","timestamp":1700000000.5,"level":3,"_order_id":"42"}`
+	g.write(entry, ERROR, true)
+	if buffer.String() != entry {
+		t.Errorf("sent %s, want the entry as it is", buffer.String())
+	}
+}
+
+func TestGraylogOutputSendsLinesAsTheShortMessage(t *testing.T) {
+	for _, test := range []struct {
+		line        string
+		level       LogLevels
+		entry       bool
+		gelfEntries bool
+		want        int
+	}{
+		{`{"log_level":"ERROR","log_message":"failed"}`, ERROR, true, false, 3},
+		{`{"log_level":"WARN","log_message":"slow"}`, WARN, true, false, 4},
+		{`{"log_level":"DEBUG","log_message":"trace"}`, DEBUG, true, false, 7},
+		{`{"log_timetaken":"12","log_message":"Toc"}`, INFO, false, true, 6},
+		{`plain "text"`, INFO, false, true, 6},
+	} {
+		var buffer bytes.Buffer
+		g := &graylogOutput{w: &buffer, hostname: "web-1", facility: "app", gelfEntries: test.gelfEntries}
+		g.write(test.line, test.level, test.entry)
+		var message struct {
+			Version  string  `json:"version"`
+			Host     string  `json:"host"`
+			Short    string  `json:"short_message"`
+			Time     float64 `json:"timestamp"`
+			Level    int     `json:"level"`
+			Facility string  `json:"facility"`
+		}
+		if err := json.Unmarshal(buffer.Bytes(), &message); err != nil {
+			t.Fatalf("sent %s: %v", buffer.String(), err)
+		}
+		if message.Version != "1.1" || message.Host != "web-1" || message.Short != test.line || message.Time == 0 ||
+			message.Level != test.want || message.Facility != "app" {
+			t.Errorf("sent %+v for %s, want it as the short message at level %d", message, test.line, test.want)
+		}
+	}
+}
+
+func TestGelfUDPWriterChunksLargeMessages(t *testing.T) {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	w, err := newGelfUDPWriter(conn.LocalAddr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+	// random bytes do not compress, so the message needs several chunks
+	payload := make([]byte, 3*gelfChunkSize)
+	rand.Read(payload)
+	if _, err := w.Write(payload); err != nil {
+		t.Fatal(err)
+	}
+
+	var compressed []byte
+	datagram := make([]byte, 2*gelfChunkSize)
+	for i, count := 0, 1; i < count; i++ {
+		conn.SetReadDeadline(time.Now().Add(time.Second))
+		n, _, err := conn.ReadFrom(datagram)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if n > gelfChunkSize || datagram[0] != 0x1e || datagram[1] != 0x0f || int(datagram[10]) != i {
+			t.Fatalf("chunk %d has %d bytes and the header % x", i, n, datagram[:gelfChunkHeaderSize])
+		}
+		count = int(datagram[11])
+		compressed = append(compressed, datagram[gelfChunkHeaderSize:n]...)
+	}
+	zr, err := gzip.NewReader(bytes.NewReader(compressed))
+	if err != nil {
+		t.Fatal(err)
+	}
+	received, err := io.ReadAll(zr)
+	if err != nil || !bytes.Equal(received, payload) {
+		t.Errorf("received %d bytes (%v), want the %d bytes of the payload", len(received), err, len(payload))
+	}
+}
@@ -1,46 +1,23 @@
 package gologger
 
 import (
+	"github.com/carwale/golibraries/gologger/metrics"
 	"github.com/prometheus/client_golang/prometheus"
 )
 
-// HistogramMetric : Default histogram message type implementing IMetricVec
-type HistogramMetric struct {
-	histogram *prometheus.HistogramVec
-	logger    *CustomLogger
-}
-
-// UpdateTime the message with calculated latency
-func (msg *HistogramMetric) UpdateTime(elapsed int64, labels ...string) {
-	msg.histogram.WithLabelValues(labels...).Observe(float64(elapsed) / 1000)
-}
-
-// AddValue is a do nothing function for histogram
-func (msg *HistogramMetric) AddValue(count int64, labels ...string) {
-	msg.logger.LogWarning("Cannot use IncValue for histogram metric")
-}
-
-// SubValue is a do nothing function for histogram
-func (msg *HistogramMetric) SubValue(count int64, labels ...string) {
-	msg.logger.LogWarning("Cannot use SubValue for histogram metric")
-}
+// HistogramMetric : Default histogram message type implementing IMetricVec. It is defined in the metrics package
+type HistogramMetric = metrics.HistogramMetric
 
-// SetValue is a do nothing function for histogram
-func (msg *HistogramMetric) SetValue(count int64, labels ...string) {
-	msg.logger.LogWarning("Cannot use SetValue for histogram metric")
+//NewHistogramMetric creates a new histogram message and registers it to prometheus
+func NewHistogramMetric(hist *prometheus.HistogramVec, logger ILogger) *HistogramMetric {
+	return metrics.NewHistogramMetric(hist, logger)
 }
 
-//RemoveLogging will stop logging for specific labels
-func (msg *HistogramMetric) RemoveLogging(labels ...string) {
-	ok := msg.histogram.DeleteLabelValues(labels...)
-	if !ok {
-		msg.logger.LogErrorWithoutErrorf("Could not delete metric with labels ", labels)
-	}
-}
+// ValueHistogramMetric : histogram of values which are not latencies, e.g. sizes. It is defined in the metrics package
+type ValueHistogramMetric = metrics.ValueHistogramMetric
 
-//NewHistogramMetric creates a new histrogram message and registers it to prometheus
-func NewHistogramMetric(hist *prometheus.HistogramVec, logger *CustomLogger) *HistogramMetric {
-	msg := &HistogramMetric{hist, logger}
-	prometheus.MustRegister(hist)
-	return msg
+// NewValueHistogramMetric creates a new value histogram message and registers it to prometheus.
+// The values are observed with IncVal
+func NewValueHistogramMetric(hist *prometheus.HistogramVec, logger ILogger) *ValueHistogramMetric {
+	return metrics.NewValueHistogramMetric(hist, logger)
 }
@@ -0,0 +1,35 @@
+package gologger
+
+// ILogger is the logger accepted by the golibraries packages. It is implemented by *CustomLogger,
+// implement it to use another logger with the packages.
+// SetLogLevel changes the level while the logger is in use and returns an error for an unknown level
+type ILogger interface {
+	LogError(str string, err error)
+	LogErrorWithoutError(str string)
+	LogErrorWithoutErrorf(str string, args ...interface{})
+	LogErrorMessage(str string, err error, pairs ...Pair)
+	LogWarning(str string)
+	LogWarningf(str string, args ...interface{})
+	LogWarningMessage(str string, pairs ...Pair)
+	LogInfo(str string)
+	LogInfof(str string, args ...interface{})
+	LogInfoMessage(str string, pairs ...Pair)
+	LogDebug(str string)
+	LogDebugf(str string, args ...interface{})
+	SetLogLevel(level string) error
+}
+
+var _ ILogger = (*CustomLogger)(nil)
+
+// LogConfig logs the configuration of the component with the secret values masked. It uses the LogConfig
+// method of the logger if it has one, like *CustomLogger, and logs an info message otherwise
+func LogConfig(logger ILogger, component string, config map[string]interface{}) {
+	if configLogger, ok := logger.(interface {
+		LogConfig(component string, config map[string]interface{})
+	}); ok {
+		configLogger.LogConfig(component, config)
+		return
+	}
+	logger.LogInfoMessage("Configuration of "+component, Pair{Key: "config_component", Value: component},
+		Pair{Key: "config", Value: maskedConfigJSON(config)})
+}
@@ -0,0 +1,160 @@
+package gologger
+
+import (
+	"strconv"
+	"sync"
+	"time"
+)
+
+// ILoggerBuilder builds a structured log entry field by field. It is returned by the Debug, Info, Warn and
+// Error methods of the CustomLogger and the entry is logged when Send is called.
+// If the level is not enabled all the methods are no-ops and nothing is allocated,
+// so it can be used in hot paths instead of LogInfoMessage
+//
+//	logger.Info().Str("order_id", id).Int("items", len(items)).Send("Order placed")
+//
+// A builder must not be used after Send is called
+type ILoggerBuilder interface {
+	// Str adds a string field
+	Str(key, value string) ILoggerBuilder
+	// Int adds an integer field
+	Int(key string, value int64) ILoggerBuilder
+	// Bool adds a boolean field
+	Bool(key string, value bool) ILoggerBuilder
+	// Dur adds a duration field in nanoseconds
+	Dur(key string, value time.Duration) ILoggerBuilder
+	// Err adds the error as the log_error field. Nil errors are ignored
+	Err(err error) ILoggerBuilder
+	// Send logs the entry with the message and releases the builder
+	Send(message string)
+}
+
+// LogEvent is the pooled implementation of ILoggerBuilder. A nil LogEvent discards everything
+type LogEvent struct {
+	logger *CustomLogger
+	level  LogLevels
+	buf    []byte
+	// keepOnly is set when the level is disabled and the entry is only kept in the ring buffer
+	keepOnly bool
+	// err and code are kept to count the error metrics of error entries
+	err  error
+	code string
+}
+
+var eventPool = sync.Pool{
+	New: func() interface{} { return &LogEvent{buf: make([]byte, 0, 512)} },
+}
+
+// Debug returns a builder for a debug entry
+func (l *CustomLogger) Debug() ILoggerBuilder {
+	return l.newEvent(DEBUG)
+}
+
+// Info returns a builder for an info entry
+func (l *CustomLogger) Info() ILoggerBuilder {
+	return l.newEvent(INFO)
+}
+
+// Warn returns a builder for a warning entry
+func (l *CustomLogger) Warn() ILoggerBuilder {
+	return l.newEvent(WARN)
+}
+
+// Error returns a builder for an error entry. Errors are always logged
+func (l *CustomLogger) Error() ILoggerBuilder {
+	return l.newEvent(ERROR)
+}
+
+func (l *CustomLogger) newEvent(level LogLevels) *LogEvent {
+	keepOnly := l.GetLogLevel() < level && level != ERROR
+	if keepOnly && l.ring == nil {
+		return nil
+	}
+	e := eventPool.Get().(*LogEvent)
+	e.logger = l
+	e.level = level
+	e.keepOnly = keepOnly
+	e.err = nil
+	e.code = ""
+	e.buf = append(e.buf[:0], '{')
+	return e
+}
+
+// Str adds a string field
+func (e *LogEvent) Str(key, value string) ILoggerBuilder {
+	if e == nil {
+		return e
+	}
+	if key == ErrorCodeKey {
+		e.code = value
+	}
+	e.buf = e.logger.appendFieldPair(e.buf, key, value)
+	return e
+}
+
+// Int adds an integer field
+func (e *LogEvent) Int(key string, value int64) ILoggerBuilder {
+	if e == nil {
+		return e
+	}
+	e.buf = e.logger.appendFieldKey(e.buf, key)
+	e.buf = append(e.buf, '"')
+	e.buf = strconv.AppendInt(e.buf, value, 10)
+	e.buf = append(e.buf, '"', ',')
+	return e
+}
+
+// Bool adds a boolean field
+func (e *LogEvent) Bool(key string, value bool) ILoggerBuilder {
+	if e == nil {
+		return e
+	}
+	e.buf = e.logger.appendFieldKey(e.buf, key)
+	e.buf = append(e.buf, '"')
+	e.buf = strconv.AppendBool(e.buf, value)
+	e.buf = append(e.buf, '"', ',')
+	return e
+}
+
+// Dur adds a duration field in nanoseconds
+func (e *LogEvent) Dur(key string, value time.Duration) ILoggerBuilder {
+	if e == nil {
+		return e
+	}
+	return e.Int(key, value.Nanoseconds())
+}
+
+// Err adds the error as the log_error field. Nil errors are ignored
+func (e *LogEvent) Err(err error) ILoggerBuilder {
+	if e == nil || err == nil {
+		return e
+	}
+	e.err = err
+	e.buf = e.logger.appendFieldPair(e.buf, "log_error", err.Error())
+	return e
+}
+
+// Send logs the entry with the same standard fields as LogInfoMessage and releases the builder
+func (e *LogEvent) Send(message string) {
+	if e == nil {
+		return
+	}
+	l := e.logger
+	if e.level == ERROR {
+		l.countError(e.err, []Pair{{ErrorCodeKey, e.code}})
+	}
+	e.buf = l.appendEntryEnd(e.buf, message, e.level)
+	if !e.keepOnly {
+		l.writeEntry(string(e.buf), e.level)
+		l.countLine(e.level)
+	}
+	if l.ring != nil {
+		l.ring.add(e.level, string(e.buf))
+	}
+
+	e.logger = nil
+	e.err = nil
+	if cap(e.buf) <= maxPooledBufferSize {
+		eventPool.Put(e)
+	}
+}
@@ -0,0 +1,154 @@
+package gologger
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestLogBuilderEntry(t *testing.T) {
+	var buffer bytes.Buffer
+	logger := &CustomLogger{logLevel: INFO, graylogFacility: "test", logger: log.New(&buffer, "", 0)}
+
+	logger.Info().Str("order_id", "o-1").Int("items", 3).Bool("paid", true).Err(errors.New("boom")).Send("Order placed")
+
+	var entry map[string]string
+	if err := json.Unmarshal(buffer.Bytes(), &entry); err != nil {
+		t.Fatalf("could not parse the entry %q: %v", buffer.String(), err)
+	}
+	want := map[string]string{"order_id": "o-1", "items": "3", "paid": "true", "log_error": "boom",
+		"log_level": INFO.String(), "log_facility": "test", "log_message": "Order placed"}
+	for k, v := range want {
+		if entry[k] != v {
+			t.Errorf("entry[%q] = %q, want %q", k, entry[k], v)
+		}
+	}
+}
+
+func TestLogBuilderDiscardDoesNotAllocate(t *testing.T) {
+	logger := &CustomLogger{logLevel: ERROR, logger: log.New(io.Discard, "", 0)}
+	allocs := testing.AllocsPerRun(100, func() {
+		logger.Info().Str("key", "value").Int("count", 1).Send("message")
+	})
+	if allocs != 0 {
+		t.Errorf("discarded entry allocated %v times, want 0", allocs)
+	}
+}
+
+func BenchmarkLogInfoMessageDiscard(b *testing.B) {
+	logger := &CustomLogger{logLevel: ERROR, logger: log.New(io.Discard, "", 0)}
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		logger.LogInfoMessage("message", Pair{"key", "value"}, Pair{"count", "1"})
+	}
+}
+
+func BenchmarkLogBuilderDiscard(b *testing.B) {
+	logger := &CustomLogger{logLevel: ERROR, logger: log.New(io.Discard, "", 0)}
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		logger.Info().Str("key", "value").Int("count", 1).Send("message")
+	}
+}
+
+func BenchmarkLogInfoMessage(b *testing.B) {
+	logger := &CustomLogger{logLevel: INFO, logger: log.New(io.Discard, "", 0)}
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		logger.LogInfoMessage("message", Pair{"key", "value"}, Pair{"count", "1"})
+	}
+}
+
+func BenchmarkLogBuilder(b *testing.B) {
+	logger := &CustomLogger{logLevel: INFO, logger: log.New(io.Discard, "", 0)}
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		logger.Info().Str("key", "value").Int("count", 1).Send("message")
+	}
+}
+
+func TestLogEntryIsValidJSON(t *testing.T) {
+	var buffer bytes.Buffer
+	logger := &CustomLogger{logLevel: INFO, logger: log.New(&buffer, "", 0)}
+	message := "quote \" backslash \\ newline \n bell \a invalid \xff unicode é"
+
+	logger.LogInfoMessage(message, Pair{"control", "\x00\x1f"})
+
+	var entry map[string]string
+	if err := json.Unmarshal(buffer.Bytes(), &entry); err != nil {
+		t.Fatalf("entry %q is not valid json: %v", buffer.String(), err)
+	}
+	if want := "quote \" backslash \\ newline \n bell \a invalid \ufffd unicode é"; entry["log_message"] != want {
+		t.Errorf("log_message = %q, want %q", entry["log_message"], want)
+	}
+	if entry["control"] != "\x00\x1f" {
+		t.Errorf("control = %q", entry["control"])
+	}
+}
+
+func BenchmarkLogInfoMessageManyPairs(b *testing.B) {
+	logger := &CustomLogger{logLevel: INFO, logger: log.New(io.Discard, "", 0)}
+	pairs := []Pair{{"a", "1"}, {"b", "2"}, {"c", "3"}, {"d", "4"}, {"e", "5"}, {"f", "6"}, {"g", "7"}, {"h", "8"}}
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		logger.LogInfoMessage("message with \"quotes\"", pairs...)
+	}
+}
+
+// nopWriter discards the entries. Unlike io.Discard it is not skipped by log.Printf, so the entries are formatted
+type nopWriter struct{}
+
+func (nopWriter) Write(p []byte) (int, error) { return len(p), nil }
+
+// BenchmarkLogInfoPrintf is the encoding of the entries with fmt before the JSON encoder, to compare with
+// BenchmarkLogInfo
+func BenchmarkLogInfoPrintf(b *testing.B) {
+	logger := log.New(nopWriter{}, "", 0)
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		logger.Printf(`{"log_level": %q, "log_timestamp": %q, "log_facility": %q,"log_message": %q,"K8sNamespace": %q}`,
+			INFO.String(), time.Now().String(), "facility", "message", "namespace")
+	}
+}
+
+func BenchmarkLogInfo(b *testing.B) {
+	logger := &CustomLogger{logLevel: INFO, graylogFacility: "facility", k8sNamespace: "namespace", logger: log.New(nopWriter{}, "", 0)}
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		logger.LogInfo("message")
+	}
+}
+
+// benchmarkGraylog logs through the udp graylog writer to a socket which is not read
+func benchmarkGraylog(b *testing.B, mapping FieldMapping) {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer conn.Close()
+	w, err := newGelfUDPWriter(conn.LocalAddr().String())
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer w.Close()
+	logger := &CustomLogger{logLevel: INFO, graylogFacility: "facility", k8sNamespace: "namespace", fieldMapping: &mapping,
+		hostname: "web-1", logger: log.New(io.Discard, "", 0)}
+	logger.graylog = &graylogOutput{w: w, hostname: "web-1", facility: "app", gelfEntries: mapping.isGELF()}
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		logger.LogInfo("message")
+	}
+}
+
+func BenchmarkLogInfoGraylog(b *testing.B) {
+	benchmarkGraylog(b, DefaultFieldMapping)
+}
+
+func BenchmarkLogInfoGraylogGELF(b *testing.B) {
+	benchmarkGraylog(b, GELFFieldMapping)
+}
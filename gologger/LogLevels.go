@@ -1,5 +1,7 @@
 package gologger
 
+import "fmt"
+
 // LogLevels are the log levels for logging
 type LogLevels uint32
 
@@ -18,3 +20,12 @@ const (
 	// DEBUG : This is for debug purposes only. Never use it on staging and production
 	DEBUG LogLevels = 3
 )
+
+// ParseLogLevel returns the level with the name. Possible values are ERROR, WARN, INFO and DEBUG in any case
+func ParseLogLevel(level string) (LogLevels, error) {
+	logLevel, ok := parseLogLevel(level)
+	if !ok {
+		return ERROR, fmt.Errorf("unknown log level %q, want ERROR, WARN, INFO or DEBUG", level)
+	}
+	return logLevel, nil
+}
@@ -0,0 +1,102 @@
+package gologger
+
+import (
+	"context"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/carwale/golibraries/ctxutil"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// DefaultLogLinkWindow is the time range of the log links around the time of the error
+const DefaultLogLinkWindow = 15 * time.Minute
+
+// LogQuery selects the log lines of a trace or a request for a link to the logs
+type LogQuery struct {
+	TraceID   string
+	RequestID string
+	// Facility limits the search to the logs of one service. It is ignored if empty
+	Facility string
+	From     time.Time
+	To       time.Time
+}
+
+// NewLogQuery returns a query for the trace ID and the request ID of ctx in the window around at.
+// The window is DefaultLogLinkWindow if it is not positive
+func NewLogQuery(ctx context.Context, at time.Time, window time.Duration) LogQuery {
+	if window <= 0 {
+		window = DefaultLogLinkWindow
+	}
+	query := LogQuery{
+		RequestID: ctxutil.RequestIDFrom(ctx),
+		From:      at.Add(-window / 2),
+		To:        at.Add(window / 2),
+	}
+	if ctx != nil {
+		if spanContext := trace.SpanContextFromContext(ctx); spanContext.IsValid() {
+			query.TraceID = spanContext.TraceID().String()
+		}
+	}
+	return query
+}
+
+// GraylogSearchURL returns a link to the graylog search page for the query. baseURL is the address of the
+// graylog web interface, e.g. https://graylog.example.com. The field names are the ones of DefaultFieldMapping
+//
+//	link := gologger.GraylogSearchURL(graylogURL, gologger.NewLogQuery(r.Context(), time.Now(), 0))
+func GraylogSearchURL(baseURL string, query LogQuery) string {
+	return graylogSearchURL(baseURL, query, DefaultFieldMapping.Facility)
+}
+
+// GraylogSearchURL returns a link to the graylog search page for the query with the facility and the
+// field mapping of the logger
+func (l *CustomLogger) GraylogSearchURL(baseURL string, query LogQuery) string {
+	if query.Facility == "" {
+		query.Facility = l.graylogFacility
+	}
+	return graylogSearchURL(baseURL, query, l.mapping().Facility)
+}
+
+func graylogSearchURL(baseURL string, query LogQuery, facilityField string) string {
+	values := url.Values{}
+	values.Set("q", query.graylogQuery(facilityField))
+	if !query.From.IsZero() && !query.To.IsZero() {
+		values.Set("rangetype", "absolute")
+		values.Set("from", query.From.UTC().Format(time.RFC3339))
+		values.Set("to", query.To.UTC().Format(time.RFC3339))
+	}
+	return strings.TrimRight(baseURL, "/") + "/search?" + values.Encode()
+}
+
+// graylogQuery returns the search query of the graylog search page. Graylog stores the additional GELF
+// fields without the leading underscore, so it is removed from the field names
+func (q LogQuery) graylogQuery(facilityField string) string {
+	var ids []string
+	if q.TraceID != "" {
+		ids = append(ids, "trace_id:"+graylogQuote(q.TraceID))
+	}
+	if q.RequestID != "" {
+		ids = append(ids, ctxutil.RequestIDLogKey+":"+graylogQuote(q.RequestID))
+	}
+	search := strings.Join(ids, " OR ")
+	if len(ids) > 1 {
+		search = "(" + search + ")"
+	}
+	if q.Facility != "" {
+		facility := strings.TrimPrefix(facilityField, "_") + ":" + graylogQuote(q.Facility)
+		if search == "" {
+			return facility
+		}
+		search += " AND " + facility
+	}
+	if search == "" {
+		return "*"
+	}
+	return search
+}
+
+func graylogQuote(value string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(value) + `"`
+}
@@ -0,0 +1,42 @@
+package gologger
+
+import (
+	"context"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/carwale/golibraries/ctxutil"
+)
+
+func TestGraylogSearchURL(t *testing.T) {
+	at := time.Date(2024, 3, 1, 10, 0, 0, 0, time.UTC)
+	query := NewLogQuery(ctxutil.WithRequestID(context.Background(), "req-1"), at, 10*time.Minute)
+	query.TraceID = "abc"
+
+	logger := &CustomLogger{graylogFacility: "stock"}
+	SetFieldMapping(GELFFieldMapping)(logger)
+	link, err := url.Parse(logger.GraylogSearchURL("https://graylog.example.com/", query))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if link.Path != "/search" {
+		t.Errorf("path = %q, want /search", link.Path)
+	}
+	values := link.Query()
+	if got, want := values.Get("q"), `(trace_id:"abc" OR requestuid:"req-1") AND facility:"stock"`; got != want {
+		t.Errorf("q = %q, want %q", got, want)
+	}
+	if values.Get("from") != "2024-03-01T09:55:00Z" || values.Get("to") != "2024-03-01T10:05:00Z" {
+		t.Errorf("range = %s - %s", values.Get("from"), values.Get("to"))
+	}
+}
+
+func TestGraylogQueryWithoutIDs(t *testing.T) {
+	if got := (LogQuery{}).graylogQuery("log_facility"); got != "*" {
+		t.Errorf("graylogQuery() = %q, want *", got)
+	}
+	if got := (LogQuery{Facility: "stock"}).graylogQuery("log_facility"); got != `log_facility:"stock"` {
+		t.Errorf("graylogQuery() = %q", got)
+	}
+}
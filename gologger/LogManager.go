@@ -1,17 +1,19 @@
 package gologger
 
 import (
-	"bytes"
 	"context"
+	"crypto/tls"
 	"fmt"
 	"io"
 	"log"
 	"os"
+	"path"
 	"strconv"
+	"sync/atomic"
 	"time"
 
+	"github.com/carwale/golibraries/ctxutil"
 	"go.opentelemetry.io/otel/trace"
-	"gopkg.in/Graylog2/go-gelf.v2/gelf"
 )
 
 // CustomLogger is a graylog logger for golang
@@ -19,12 +21,27 @@ type CustomLogger struct {
 	graylogHostName       string
 	graylogPort           int
 	graylogFacility       string
+	graylogTransport      string
+	graylogTLSConfig      *tls.Config
 	k8sNamespace          string
 	logLevel              LogLevels
 	isConsolePrintEnabled bool
 	isTimeLoggingEnabled  bool
 	disableGraylog        bool
+	output                io.Writer
 	logger                *log.Logger
+	extensions            []ILoggerExtension
+	fieldMapping          *FieldMapping
+	hostname              string
+	disableSelfMetrics    bool
+	selfMetrics           *loggerSelfMetrics
+	ring                  *ringBuffer
+	errorMetrics          bool
+	asyncQueueSize        int
+	asyncPolicy           OverflowPolicy
+	asyncLatencyLogger    IMultiLogger
+	async                 *asyncWriter
+	graylog               *graylogOutput
 }
 
 // Pair is a tuple of strings
@@ -78,6 +95,12 @@ func DisableGraylog(flag bool) Option {
 	return func(l *CustomLogger) { l.disableGraylog = flag }
 }
 
+// SetOutput writes the logs to w instead of stderr, e.g. to a file or to a buffer in tests.
+// Logs are still sent to graylog unless it is disabled
+func SetOutput(w io.Writer) Option {
+	return func(l *CustomLogger) { l.output = w }
+}
+
 // SetLogLevel sets the logger level Possible values are ERROR, WARN, INFO, DEBUG.
 // Default is ERROR
 func SetLogLevel(level string) Option {
@@ -111,20 +134,18 @@ func TimeLoggingEnabled(flag bool) Option {
 	return func(l *CustomLogger) { l.isTimeLoggingEnabled = flag }
 }
 
-// NewLogger : returns a new logger. When no options are given, it returns an error logger
-// With graylog logging as default to a port 11100 which is not in use. So it is prety much
-// useless. Please provide graylog host and port at the very least.
-func NewLogger(LoggerOptions ...Option) *CustomLogger {
-
+// configuredLogger returns a logger with the defaults and the options applied, which does not log yet
+func configuredLogger(options []Option) *CustomLogger {
 	l := &CustomLogger{
-		graylogHostName: "127.0.0.1",
-		graylogPort:     11100,
-		graylogFacility: "ErrorLogger",
-		logLevel:        ERROR,
-		k8sNamespace:    "dev",
+		graylogHostName:  "127.0.0.1",
+		graylogPort:      11100,
+		graylogFacility:  "ErrorLogger",
+		graylogTransport: GraylogUDP,
+		logLevel:         ERROR,
+		k8sNamespace:     "dev",
 	}
 
-	for _, option := range LoggerOptions {
+	for _, option := range options {
 		option(l)
 	}
 
@@ -132,38 +153,144 @@ func NewLogger(LoggerOptions ...Option) *CustomLogger {
 	if ok && k8sNamespace != "" {
 		l.k8sNamespace = k8sNamespace
 	}
+	return l
+}
+
+// Settings are the settings of the options which do not depend on graylog
+type Settings struct {
+	Level        LogLevels
+	Facility     string
+	K8sNamespace string
+}
 
+// SettingsOf returns the settings of the options with the defaults of NewLogger. It is used by the ILogger
+// implementations with another backend, like zaplogger, to keep the options of gologger
+func SettingsOf(options ...Option) Settings {
+	l := configuredLogger(options)
+	return Settings{Level: l.logLevel, Facility: l.graylogFacility, K8sNamespace: l.k8sNamespace}
+}
+
+// NewLogger : returns a new logger. When no options are given, it returns an error logger
+// With graylog logging as default to a port 11100 which is not in use. So it is prety much
+// useless. Please provide graylog host and port at the very least.
+func NewLogger(LoggerOptions ...Option) *CustomLogger {
+	l := configuredLogger(LoggerOptions)
+
+	if !l.disableSelfMetrics {
+		l.selfMetrics = newLoggerSelfMetrics(l.graylogFacility)
+	}
 	graylogAddr := l.graylogHostName + ":" + strconv.Itoa(l.graylogPort)
-	gelfWriter, err := gelf.NewUDPWriter(graylogAddr)
-	if err != nil {
-		log.Fatalf("gelf.NewWriter: %s", err)
+	if !l.disableGraylog {
+		var graylogWriter io.Writer
+		switch l.graylogTransport {
+		case GraylogTCP:
+			graylogWriter = newGelfStreamWriter(graylogAddr, nil)
+		case GraylogTLS:
+			if l.graylogTLSConfig == nil {
+				l.graylogTLSConfig = &tls.Config{MinVersion: tls.VersionTLS12}
+			}
+			graylogWriter = newGelfStreamWriter(graylogAddr, l.graylogTLSConfig)
+		default:
+			udpWriter, err := newGelfUDPWriter(graylogAddr)
+			if err != nil {
+				log.Fatalf("gelf.NewWriter: %s", err)
+			}
+			graylogWriter = udpWriter
+		}
+		if l.asyncQueueSize > 0 {
+			l.async = newAsyncWriter(graylogWriter, l.asyncQueueSize, l.asyncPolicy)
+			if l.selfMetrics != nil {
+				l.async.writeErrors = l.selfMetrics.writeErrors
+			}
+			graylogWriter = l.async
+		}
+		hostname, _ := os.Hostname()
+		l.graylog = &graylogOutput{
+			w:           l.outputWriter(graylogWriter),
+			hostname:    hostname,
+			facility:    path.Base(os.Args[0]),
+			gelfEntries: l.mapping().isGELF(),
+		}
 	}
-	// log to both stderr and graylog2
-	if l.disableGraylog {
-		l.logger = log.New(io.MultiWriter(os.Stderr), "", 0)
-		l.logger.Printf("Logging to Stderr")
+	// log to stderr or the output, and to graylog2
+	console := l.output
+	var destination string
+	if l.output != nil && l.disableGraylog {
+		destination = "Logging to the output"
+	} else if l.output != nil {
+		destination = fmt.Sprintf("Logging to the output & Graylog @ %q", graylogAddr)
+	} else if l.disableGraylog {
+		console = os.Stderr
+		destination = "Logging to Stderr"
 	} else if l.isConsolePrintEnabled {
-		l.logger = log.New(io.MultiWriter(os.Stderr, gelfWriter), "", 0)
-		l.logger.Printf("Logging to Stderr & Graylog @ %q", graylogAddr)
+		console = os.Stderr
+		destination = fmt.Sprintf("Logging to Stderr & Graylog @ %q", graylogAddr)
 	} else {
-		l.logger = log.New(io.MultiWriter(gelfWriter), "", 0)
-		l.logger.Printf("Logging to Graylog @ %q", graylogAddr)
+		destination = fmt.Sprintf("Logging to Graylog @ %q", graylogAddr)
 	}
+	if console != nil {
+		l.logger = log.New(l.outputWriter(console), "", 0)
+	} else {
+		l.logger = log.New(io.Discard, "", 0)
+	}
+	if l.async != nil {
+		// the default latency logger logs with l, so it is created once l can log
+		if l.asyncLatencyLogger == nil {
+			l.asyncLatencyLogger = NewRateLatencyLogger(SetLogger(l))
+		}
+		l.async.setLatencyLogger(l, l.asyncLatencyLogger)
+	}
+	l.writeLine(destination, INFO)
+	l.LogConfig("logger", map[string]interface{}{
+		"graylog_host":      l.graylogHostName,
+		"graylog_port":      l.graylogPort,
+		"graylog_facility":  l.graylogFacility,
+		"graylog_transport": l.graylogTransport,
+		"k8s_namespace":     l.k8sNamespace,
+		"log_level":         l.logLevel.String(),
+		"graylog_disabled":  l.disableGraylog,
+		"console_print":     l.isConsolePrintEnabled,
+		"custom_output":     l.output != nil,
+		"async_queue_size":  l.asyncQueueSize,
+		"async_overflow":    l.asyncPolicy.String(),
+		"time_logging":      l.isTimeLoggingEnabled,
+	})
 	return l
 }
 
 // GetLogLevel is used to get the current Log level
 func (l *CustomLogger) GetLogLevel() LogLevels {
-	return l.logLevel
+	return LogLevels(atomic.LoadUint32((*uint32)(&l.logLevel)))
+}
+
+// SetLogLevel changes the level of the logger while it is in use, e.g. to DEBUG during an incident. Possible
+// values are ERROR, WARN, INFO and DEBUG in any case; the level is unchanged if the value is unknown. The change is logged
+func (l *CustomLogger) SetLogLevel(level string) error {
+	logLevel, err := ParseLogLevel(level)
+	if err != nil {
+		return err
+	}
+	previous := LogLevels(atomic.SwapUint32((*uint32)(&l.logLevel), uint32(logLevel)))
+	if previous != logLevel {
+		l.logMessageWithExtras("Log level changed from "+previous.String()+" to "+logLevel.String(), INFO, nil)
+	}
+	return nil
+}
+
+// ChangeLogLevel changes the level of the logger. It is the same as logger.SetLogLevel(level)
+func ChangeLogLevel(logger ILogger, level string) error {
+	return logger.SetLogLevel(level)
 }
 
 // LogErrorInterface is used to log errors
 func (l *CustomLogger) LogErrorInterface(v ...interface{}) {
-	l.logger.Output(2, fmt.Sprint(v...))
+	l.countError(nil, nil)
+	l.writeLine(fmt.Sprint(v...), ERROR)
 }
 
 // LogError is used to log errors and a message along with the error
 func (l *CustomLogger) LogError(str string, err error) {
+	l.countError(err, nil)
 	pairs := []Pair{
 		{"log_error", err.Error()},
 	}
@@ -172,6 +299,7 @@ func (l *CustomLogger) LogError(str string, err error) {
 
 // LogErrorWithoutError is used to log only a message and not an error
 func (l *CustomLogger) LogErrorWithoutError(str string) {
+	l.countError(nil, nil)
 	l.logMessageWithExtras(str, ERROR, nil)
 }
 
@@ -182,6 +310,7 @@ func (l *CustomLogger) LogErrorWithoutErrorf(str string, args ...interface{}) {
 
 // LogErrorMessage is used to log extra fields to graylog along with the error
 func (l *CustomLogger) LogErrorMessage(str string, err error, pairs ...Pair) {
+	l.countError(err, pairs)
 	if err != nil {
 		pairs = append(pairs, Pair{"log_error", err.Error()})
 	}
@@ -190,8 +319,10 @@ func (l *CustomLogger) LogErrorMessage(str string, err error, pairs ...Pair) {
 
 // LogWarning is used to log warning messages
 func (l *CustomLogger) LogWarning(str string) {
-	if l.logLevel >= WARN {
+	if l.GetLogLevel() >= WARN {
 		l.logMessageWithExtras(str, WARN, nil)
+	} else if l.ring != nil {
+		l.keepEntry(str, WARN, nil)
 	}
 }
 
@@ -202,22 +333,28 @@ func (l *CustomLogger) LogWarningf(str string, args ...interface{}) {
 
 // LogWarningMessage is used to log warning messages along with extra fields to GrayLog
 func (l *CustomLogger) LogWarningMessage(str string, pairs ...Pair) {
-	if l.logLevel >= WARN {
+	if l.GetLogLevel() >= WARN {
 		l.logMessageWithExtras(str, WARN, pairs)
+	} else if l.ring != nil {
+		l.keepEntry(str, WARN, pairs)
 	}
 }
 
 // LogInfoMessage is used to log extra fields to graylog
 func (l *CustomLogger) LogInfoMessage(str string, pairs ...Pair) {
-	if l.logLevel >= INFO {
+	if l.GetLogLevel() >= INFO {
 		l.logMessageWithExtras(str, INFO, pairs)
+	} else if l.ring != nil {
+		l.keepEntry(str, INFO, pairs)
 	}
 }
 
 // LogInfo is used to log info messages
 func (l *CustomLogger) LogInfo(str string) {
-	if l.logLevel >= INFO {
+	if l.GetLogLevel() >= INFO {
 		l.logMessageWithExtras(str, INFO, nil)
+	} else if l.ring != nil {
+		l.keepEntry(str, INFO, nil)
 	}
 }
 
@@ -228,8 +365,10 @@ func (l *CustomLogger) LogInfof(str string, args ...interface{}) {
 
 // LogDebug is used to log debug messages
 func (l *CustomLogger) LogDebug(str string) {
-	if l.logLevel >= DEBUG {
+	if l.GetLogLevel() >= DEBUG {
 		l.logMessageWithExtras(str, DEBUG, nil)
+	} else if l.ring != nil {
+		l.keepEntry(str, DEBUG, nil)
 	}
 }
 
@@ -240,13 +379,7 @@ func (l *CustomLogger) LogDebugf(str string, args ...interface{}) {
 
 // LogMessage is used to log plain message
 func (l *CustomLogger) LogMessage(message string) {
-	l.logger.Printf(message)
-}
-
-// logMessage is used to log message with any log level
-func (l *CustomLogger) logMessage(message string, level LogLevels) {
-	l.logger.Printf(`{"log_level": %q, "log_timestamp": %q, "log_facility": %q,"log_message": %q,"K8sNamespace": %q}`,
-		level.String(), time.Now().String(), l.graylogFacility, message, l.k8sNamespace)
+	l.writeLine(fmt.Sprintf(message), INFO)
 }
 
 // LogMessagef is used to log plain message
@@ -255,33 +388,54 @@ func (l *CustomLogger) LogMessagef(message string, args ...interface{}) {
 }
 
 func (l *CustomLogger) LogMessageWithExtras(message string, level LogLevels, pairs ...Pair) {
-	if l.logLevel >= level {
+	if l.GetLogLevel() >= level {
 		l.logMessageWithExtras(message, level, pairs)
+	} else if l.ring != nil {
+		l.keepEntry(message, level, pairs)
 	}
 }
 
 // logMessageWithExtras is a generic function to format and log every type of messages
 func (l *CustomLogger) logMessageWithExtras(message string, level LogLevels, pairs []Pair) {
-	if len(pairs) == 0 {
-		pairs = make([]Pair, 0)
-	}
-	pairs = append(pairs, Pair{"log_level", level.String()})
-	pairs = append(pairs, Pair{"log_timestamp", time.Now().String()})
-	pairs = append(pairs, Pair{"log_facility", l.graylogFacility})
-	pairs = append(pairs, Pair{"log_message", message})
-	pairs = append(pairs, Pair{"K8sNamespace", l.k8sNamespace})
-	var buffer bytes.Buffer
-	buffer.WriteString("{")
-	for index, pair := range pairs {
-		buffer.WriteString(fmt.Sprintf("%q:%q", pair.Key, pair.Value))
-		if index < len(pairs)-1 {
-			buffer.WriteString(",")
-		}
+	buf := getBuffer()
+	*buf = append(*buf, '{')
+	for _, pair := range pairs {
+		*buf = l.appendFieldPair(*buf, pair.Key, pair.Value)
+	}
+	*buf = l.appendEntryEnd(*buf, message, level)
+	line := string(*buf)
+	l.writeEntry(line, level)
+	l.countLine(level)
+	if l.ring != nil {
+		l.ring.add(level, line)
+	}
+	putBuffer(buf)
+}
+
+// writeEntry writes a line formatted with the field mapping to the output and to graylog
+func (l *CustomLogger) writeEntry(line string, level LogLevels) {
+	l.logger.Output(3, line)
+	if l.graylog != nil {
+		l.graylog.write(line, level, true)
 	}
-	buffer.WriteString("}")
+}
 
-	l.logger.Print(buffer.String())
+// writeLine writes a line which is not formatted with the field mapping, like the time logs of Toc
+func (l *CustomLogger) writeLine(line string, level LogLevels) {
+	l.logger.Output(3, line)
+	if l.graylog != nil {
+		l.graylog.write(line, level, false)
+	}
+}
 
+// outputWriter returns w with the secret values masked and the writes counted in the self metrics. The bytes of
+// a line are counted for each output it is written to
+func (l *CustomLogger) outputWriter(w io.Writer) io.Writer {
+	w = &maskingWriter{w: w}
+	if l.selfMetrics != nil {
+		w = &countingWriter{w: w, metrics: l.selfMetrics}
+	}
+	return w
 }
 
 // Tic is used to log time taken by a function. It should be used along with Toc function
@@ -300,30 +454,60 @@ func (l *CustomLogger) Tic(s string) (string, time.Time) {
 func (l *CustomLogger) Toc(message string, startTime time.Time) {
 	if l.isTimeLoggingEnabled {
 		endTime := time.Now()
-		l.logger.Printf(`{"log_timestamp": %q, "log_timetaken": %q, "log_facility": %q,"log_message": %q,"K8sNamespace": %q}`,
-			time.Now().String(), strconv.FormatInt(endTime.Sub(startTime).Nanoseconds(), 10), l.graylogFacility, message, l.k8sNamespace)
+		buf := getBuffer()
+		*buf = append(*buf, `{"log_timestamp":"`...)
+		*buf = endTime.AppendFormat(*buf, timestampLayout)
+		*buf = append(*buf, `","log_timetaken":"`...)
+		*buf = strconv.AppendInt(*buf, endTime.Sub(startTime).Nanoseconds(), 10)
+		*buf = append(*buf, '"', ',')
+		*buf = appendPair(*buf, "log_facility", l.graylogFacility)
+		*buf = appendPair(*buf, "log_message", message)
+		*buf = appendPair(*buf, "K8sNamespace", l.k8sNamespace)
+		// Replace the trailing comma
+		(*buf)[len(*buf)-1] = '}'
+		l.writeLine(string(*buf), INFO)
+		putBuffer(buf)
 	}
 }
 
+// contextLogKeys fixes the order in which the ctxutil values are added to the logs
+var contextLogKeys = []string{ctxutil.RequestIDLogKey, ctxutil.UserIDLogKey, ctxutil.TenantIDLogKey}
+
 // logMessageWithContext is a generic function to format and log every type of messages
 // It will also add trace_id and span_id in the log if it exists in the context
+// along with the request, user and tenant IDs kept in the context by ctxutil
 func (l *CustomLogger) logMessageWithContext(ctx context.Context, message string, level LogLevels, pairs []Pair) {
 	if ctx != nil {
-		var span = trace.SpanFromContext(ctx)
-		if span.SpanContext().IsValid() {
-			pairs = append(pairs, Pair{"trace_id", span.SpanContext().TraceID().String()})
-			pairs = append(pairs, Pair{"span_id", span.SpanContext().SpanID().String()})
-		}
-		defer span.End()
+		pairs = appendContextPairs(ctx, pairs)
+		defer trace.SpanFromContext(ctx).End()
 	}
 	l.logMessageWithExtras(message, level, pairs)
 }
 
+// appendContextPairs appends the ctxutil values and the trace_id and span_id of the context to pairs
+func appendContextPairs(ctx context.Context, pairs []Pair) []Pair {
+	if fields := ctxutil.Fields(ctx); fields != nil {
+		for _, key := range contextLogKeys {
+			if value, ok := fields[key]; ok {
+				pairs = append(pairs, Pair{key, value})
+			}
+		}
+	}
+	spanContext := trace.SpanContextFromContext(ctx)
+	if spanContext.IsValid() {
+		pairs = append(pairs, Pair{"trace_id", spanContext.TraceID().String()})
+		pairs = append(pairs, Pair{"span_id", spanContext.SpanID().String()})
+	}
+	return pairs
+}
+
 // LogDebugWithContext is used to log debug messages.
 // It will also add trace_id and span_id in the log if it exists in the context
 func (l *CustomLogger) LogDebugWithContext(ctx context.Context, str string) {
-	if l.logLevel >= DEBUG {
+	if l.isEnabledForContext(ctx, DEBUG) {
 		l.logMessageWithContext(ctx, str, DEBUG, nil)
+	} else if l.ring != nil {
+		l.keepEntry(str, DEBUG, nil)
 	}
 }
 
@@ -336,8 +520,10 @@ func (l *CustomLogger) LogDebugfWithContext(ctx context.Context, str string, arg
 // LogInfoWithContext is used to log info messages.
 // It will also add trace_id and span_id in the log if it exists in the context.
 func (l *CustomLogger) LogInfoWithContext(ctx context.Context, str string) {
-	if l.logLevel >= INFO {
+	if l.isEnabledForContext(ctx, INFO) {
 		l.logMessageWithContext(ctx, str, INFO, nil)
+	} else if l.ring != nil {
+		l.keepEntry(str, INFO, nil)
 	}
 }
 
@@ -350,8 +536,10 @@ func (l *CustomLogger) LogInfofWithContext(ctx context.Context, str string, args
 // LogWarningWithContext is used to log warning messages.
 // It will also add trace_id and span_id in the log if it exists in the context.
 func (l *CustomLogger) LogWarningWithContext(ctx context.Context, str string) {
-	if l.logLevel >= WARN {
+	if l.isEnabledForContext(ctx, WARN) {
 		l.logMessageWithContext(ctx, str, WARN, nil)
+	} else if l.ring != nil {
+		l.keepEntry(str, WARN, nil)
 	}
 }
 
@@ -364,6 +552,7 @@ func (l *CustomLogger) LogWarningfWithContext(ctx context.Context, str string, a
 // LogErrorWithContext is used to log errors and a message along with the error
 // It will also add trace_id and span_id in the log if it exists in the context.
 func (l *CustomLogger) LogErrorWithContext(ctx context.Context, str string, err error) {
+	l.countError(err, nil)
 	pairs := []Pair{
 		{"log_error", err.Error()},
 	}
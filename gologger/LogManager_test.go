@@ -0,0 +1,42 @@
+package gologger
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestSetOutput(t *testing.T) {
+	var buffer bytes.Buffer
+	logger := NewLogger(SetOutput(&buffer), DisableGraylog(true), SetLogLevel("INFO"))
+
+	logger.LogInfo("written to the buffer")
+
+	if !strings.Contains(buffer.String(), `"log_message":"written to the buffer"`) {
+		t.Errorf("output = %q", buffer.String())
+	}
+}
+
+func TestSetLogLevelAtRuntime(t *testing.T) {
+	var buffer bytes.Buffer
+	logger := NewLogger(SetOutput(&buffer), DisableGraylog(true), SetLogLevel("ERROR"))
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 100; i++ {
+			logger.LogDebug("racing with the level change")
+		}
+	}()
+	if err := ChangeLogLevel(logger, "DEBUG"); err != nil {
+		t.Fatal(err)
+	}
+	<-done
+	logger.LogDebug("logged after the change")
+
+	if logger.GetLogLevel() != DEBUG || !strings.Contains(buffer.String(), "logged after the change") {
+		t.Errorf("level = %v, output = %q", logger.GetLogLevel(), buffer.String())
+	}
+	if err := logger.SetLogLevel("VERBOSE"); err == nil || logger.GetLogLevel() != DEBUG {
+		t.Errorf("SetLogLevel() of an unknown level = %v and level = %v", err, logger.GetLogLevel())
+	}
+}
@@ -0,0 +1,41 @@
+package gologger
+
+// ILoggerExtension adds custom fields to every message logged by the CustomLogger.
+// It can be used to add fields like the build SHA or a hash of the feature flags to all the logs
+// without changing the code that logs. Fields is called for every message that passes the log level,
+// so it should be cheap
+type ILoggerExtension interface {
+	Fields(level LogLevels, message string) []Pair
+}
+
+// LoggerExtensionFunc is an adapter to use a function as an ILoggerExtension
+type LoggerExtensionFunc func(level LogLevels, message string) []Pair
+
+// Fields calls f(level, message)
+func (f LoggerExtensionFunc) Fields(level LogLevels, message string) []Pair {
+	return f(level, message)
+}
+
+// StaticFields returns an extension which adds the same fields to every message
+func StaticFields(pairs ...Pair) ILoggerExtension {
+	return LoggerExtensionFunc(func(LogLevels, string) []Pair { return pairs })
+}
+
+// WithHook adds an extension to the logger. Extensions are called in the order they are added
+// and their fields are added before the standard fields of the message
+func WithHook(extension ILoggerExtension) Option {
+	return func(l *CustomLogger) {
+		if extension != nil {
+			l.extensions = append(l.extensions, extension)
+		}
+	}
+}
+
+// extensionFields returns the fields of all the extensions of the logger
+func (l *CustomLogger) extensionFields(level LogLevels, message string) []Pair {
+	var pairs []Pair
+	for _, extension := range l.extensions {
+		pairs = append(pairs, extension.Fields(level, message)...)
+	}
+	return pairs
+}
@@ -0,0 +1,27 @@
+package gologger
+
+import (
+	"bytes"
+	"encoding/json"
+	"log"
+	"testing"
+)
+
+func TestLoggerExtensionFields(t *testing.T) {
+	var buffer bytes.Buffer
+	logger := &CustomLogger{logLevel: INFO, logger: log.New(&buffer, "", 0)}
+	WithHook(StaticFields(Pair{"build_sha", "abc123"}))(logger)
+	WithHook(LoggerExtensionFunc(func(level LogLevels, message string) []Pair {
+		return []Pair{{"level_seen", level.String()}}
+	}))(logger)
+
+	logger.LogInfo("hello")
+
+	var entry map[string]string
+	if err := json.Unmarshal(buffer.Bytes(), &entry); err != nil {
+		t.Fatalf("could not parse the entry %q: %v", buffer.String(), err)
+	}
+	if entry["build_sha"] != "abc123" || entry["level_seen"] != INFO.String() || entry["log_message"] != "hello" {
+		t.Errorf("entry = %v", entry)
+	}
+}
@@ -0,0 +1,141 @@
+package gologger
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/go-logr/logr"
+)
+
+// logrSink is a logr.LogSink writing to an ILogger
+type logrSink struct {
+	logger ILogger
+	name   string
+	pairs  []Pair
+}
+
+// NewLogr returns a logr.Logger writing to the logger, for the kubernetes client libraries and operators
+// which log with logr. klog, the logger of client-go, can be routed to graylog with
+//
+//	klog.SetLogger(gologger.NewLogr(logger))
+//
+// Info logs of verbosity 0 are logged as info and the more verbose ones as debug. Key value pairs are
+// logged as fields and the names of the logger as the logger field, joined by slashes
+func NewLogr(logger ILogger) logr.Logger {
+	return logr.New(NewLogrSink(logger))
+}
+
+// NewLogrSink returns a logr.LogSink writing to the logger
+func NewLogrSink(logger ILogger) logr.LogSink {
+	return &logrSink{logger: logger}
+}
+
+// Init does nothing, the call depth is not logged
+func (s *logrSink) Init(info logr.RuntimeInfo) {}
+
+// Enabled returns true if the logger logs the verbosity
+func (s *logrSink) Enabled(level int) bool {
+	return levelEnabled(context.Background(), s.logger, levelFromLogr(level))
+}
+
+// Info logs the message with the key value pairs
+func (s *logrSink) Info(level int, msg string, keysAndValues ...interface{}) {
+	logPairs(context.Background(), s.logger, levelFromLogr(level), msg, s.appendPairs(keysAndValues))
+}
+
+// Error logs the error and the message with the key value pairs
+func (s *logrSink) Error(err error, msg string, keysAndValues ...interface{}) {
+	pairs := s.appendPairs(keysAndValues)
+	if err != nil {
+		pairs = append(pairs, Pair{"log_error", err.Error()})
+	}
+	logPairs(context.Background(), s.logger, ERROR, msg, pairs)
+}
+
+// WithValues returns a sink adding the key value pairs to every message
+func (s *logrSink) WithValues(keysAndValues ...interface{}) logr.LogSink {
+	pairs := make([]Pair, len(s.pairs), len(s.pairs)+len(keysAndValues)/2)
+	copy(pairs, s.pairs)
+	return &logrSink{logger: s.logger, name: s.name, pairs: appendKeysAndValues(pairs, keysAndValues)}
+}
+
+// WithName returns a sink with the name appended to the name of the logger
+func (s *logrSink) WithName(name string) logr.LogSink {
+	if s.name != "" {
+		name = s.name + "/" + name
+	}
+	return &logrSink{logger: s.logger, name: name, pairs: s.pairs}
+}
+
+// appendPairs returns the pairs of the sink, its name and the key value pairs of a message
+func (s *logrSink) appendPairs(keysAndValues []interface{}) []Pair {
+	pairs := make([]Pair, len(s.pairs), len(s.pairs)+len(keysAndValues)/2+1)
+	copy(pairs, s.pairs)
+	if s.name != "" {
+		pairs = append(pairs, Pair{"logger", s.name})
+	}
+	return appendKeysAndValues(pairs, keysAndValues)
+}
+
+// appendKeysAndValues appends the logr key value pairs to pairs
+func appendKeysAndValues(pairs []Pair, keysAndValues []interface{}) []Pair {
+	for i := 0; i < len(keysAndValues); i += 2 {
+		key := fmt.Sprint(keysAndValues[i])
+		value := "(MISSING)"
+		if i+1 < len(keysAndValues) {
+			value = fmt.Sprint(keysAndValues[i+1])
+		}
+		pairs = append(pairs, Pair{key, value})
+	}
+	return pairs
+}
+
+func levelFromLogr(level int) LogLevels {
+	if level > 0 {
+		return DEBUG
+	}
+	return INFO
+}
+
+// levelEnabled returns true if the logger logs the level. The level override of the context is applied
+// for a *CustomLogger
+func levelEnabled(ctx context.Context, logger ILogger, level LogLevels) bool {
+	switch logger := logger.(type) {
+	case *CustomLogger:
+		return logger.isEnabledForContext(ctx, level)
+	case interface{ GetLogLevel() LogLevels }:
+		return logger.GetLogLevel() >= level
+	}
+	return true
+}
+
+// logPairs logs the message with the fields at the level. A *CustomLogger gets the ids of the context too;
+// other loggers get the fields of debug messages in the message, as ILogger has no debug method with fields
+func logPairs(ctx context.Context, logger ILogger, level LogLevels, message string, pairs []Pair) {
+	if customLogger, ok := logger.(*CustomLogger); ok {
+		if ctx != nil {
+			pairs = appendContextPairs(ctx, pairs)
+		}
+		if level == ERROR {
+			customLogger.countError(nil, pairs)
+		}
+		customLogger.logMessageWithExtras(message, level, pairs)
+		return
+	}
+	switch level {
+	case ERROR:
+		logger.LogErrorMessage(message, nil, pairs...)
+	case WARN:
+		logger.LogWarningMessage(message, pairs...)
+	case INFO:
+		logger.LogInfoMessage(message, pairs...)
+	default:
+		var b strings.Builder
+		b.WriteString(message)
+		for _, pair := range pairs {
+			b.WriteString(" " + pair.Key + "=" + pair.Value)
+		}
+		logger.LogDebug(b.String())
+	}
+}
@@ -0,0 +1,27 @@
+package gologger
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"log"
+	"testing"
+)
+
+func TestLogr(t *testing.T) {
+	var buffer bytes.Buffer
+	logger := NewLogr(&CustomLogger{logLevel: INFO, logger: log.New(&buffer, "", 0)}).
+		WithName("controller").WithName("stocks").WithValues("namespace", "prod")
+
+	logger.V(1).Info("skipped")
+	logger.Error(errors.New("conflict"), "could not update", "name", "swift", 3)
+
+	var entry map[string]string
+	if err := json.Unmarshal(buffer.Bytes(), &entry); err != nil {
+		t.Fatalf("could not parse the entry %q: %v", buffer.String(), err)
+	}
+	if entry["log_message"] != "could not update" || entry["logger"] != "controller/stocks" || entry["namespace"] != "prod" ||
+		entry["name"] != "swift" || entry["3"] != "(MISSING)" || entry["log_error"] != "conflict" {
+		t.Errorf("entry = %v", entry)
+	}
+}
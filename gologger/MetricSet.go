@@ -0,0 +1,40 @@
+package gologger
+
+import "sync"
+
+// MetricSet holds the metrics of a package which are registered with prometheus once, when they are first
+// added, and added to every latency logger the package is used with
+//
+//	var poolMetrics = gologger.NewMetricSet(func(logger gologger.ILogger) map[string]gologger.IMetricVec {
+//		return map[string]gologger.IMetricVec{"POOL-SIZE": gologger.NewGaugeMetric(..., logger)}
+//	})
+//
+//	poolMetrics.AddTo(latencyLogger, logger)
+type MetricSet struct {
+	lock    sync.Mutex
+	build   func(logger ILogger) map[string]IMetricVec
+	metrics map[string]IMetricVec
+	added   map[IMultiLogger]bool
+}
+
+// NewMetricSet returns a set of the metrics returned by build. build is called once, with the logger of the
+// first AddTo
+func NewMetricSet(build func(logger ILogger) map[string]IMetricVec) *MetricSet {
+	return &MetricSet{build: build, added: make(map[IMultiLogger]bool)}
+}
+
+// AddTo adds the metrics to the latency logger, unless they were added to it before
+func (s *MetricSet) AddTo(latencyLogger IMultiLogger, logger ILogger) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if s.metrics == nil {
+		s.metrics = s.build(logger)
+	}
+	if s.added[latencyLogger] {
+		return
+	}
+	for id, metric := range s.metrics {
+		latencyLogger.AddNewMetric(id, metric)
+	}
+	s.added[latencyLogger] = true
+}
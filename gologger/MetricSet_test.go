@@ -0,0 +1,34 @@
+package gologger
+
+import (
+	"testing"
+	"time"
+)
+
+type countingMultiLogger struct{ added map[string]int }
+
+func (l *countingMultiLogger) AddNewMetric(id string, _ IMetricVec) { l.added[id]++ }
+func (l *countingMultiLogger) Tic() time.Time                       { return time.Now() }
+func (l *countingMultiLogger) Toc(time.Time, string, ...string)     {}
+func (l *countingMultiLogger) IncVal(int64, string, ...string)      {}
+func (l *countingMultiLogger) SubVal(int64, string, ...string)      {}
+func (l *countingMultiLogger) SetVal(int64, string, ...string)      {}
+
+func TestMetricSetAddsToEveryLatencyLoggerOnce(t *testing.T) {
+	builds := 0
+	set := NewMetricSet(func(ILogger) map[string]IMetricVec {
+		builds++
+		return map[string]IMetricVec{"A": nil, "B": nil}
+	})
+	first := &countingMultiLogger{added: map[string]int{}}
+	second := &countingMultiLogger{added: map[string]int{}}
+	set.AddTo(first, nil)
+	set.AddTo(first, nil)
+	set.AddTo(second, nil)
+	if builds != 1 {
+		t.Errorf("metrics built %d times, want once", builds)
+	}
+	if first.added["A"] != 1 || first.added["B"] != 1 || second.added["A"] != 1 || second.added["B"] != 1 {
+		t.Errorf("metrics added %v and %v, want every metric once to every latency logger", first.added, second.added)
+	}
+}
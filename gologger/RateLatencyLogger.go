@@ -2,6 +2,9 @@ package gologger
 
 import (
 	"time"
+
+	"github.com/carwale/golibraries/gologger/metrics"
+	"github.com/prometheus/client_golang/prometheus"
 )
 
 // updatePacket : Struct that holds message updates
@@ -24,8 +27,10 @@ type RateLatencyLogger struct {
 	countSubTunnel chan updatePacket
 	countSetTunnel chan updatePacket
 	addMsgTunnel   chan messageAdder
-	logger         *CustomLogger
+	snapshotTunnel chan chan map[string]prometheus.Collector
+	logger         ILogger
 	isRan          bool
+	dropped        prometheus.Counter
 }
 
 var rateLatencyLogger *RateLatencyLogger
@@ -76,10 +81,19 @@ func (mgl *RateLatencyLogger) run() {
 				if !ok {
 					mgl.messages[addMessage.name] = addMessage.metric
 				}
+			case reply := <-mgl.snapshotTunnel:
+				collectors := make(map[string]prometheus.Collector, len(mgl.messages))
+				for id, msg := range mgl.messages {
+					if collector, ok := msg.(metrics.ICollector); ok {
+						collectors[id] = collector.Collector()
+					}
+				}
+				reply <- collectors
 			case packet := <-mgl.updateTunnel:
 				msg, ok := mgl.messages[packet.identifier]
 				if !ok {
 					mgl.logger.LogErrorWithoutError("wrong identifier passed. Could not find metric logger with identifier " + packet.identifier)
+					mgl.dropped.Inc()
 					continue
 				}
 				msg.UpdateTime(packet.value, packet.labels...)
@@ -87,6 +101,7 @@ func (mgl *RateLatencyLogger) run() {
 				msg, ok := mgl.messages[packet.identifier]
 				if !ok {
 					mgl.logger.LogErrorWithoutError("wrong identifier passed. Could not find metric logger with identifier " + packet.identifier)
+					mgl.dropped.Inc()
 					continue
 				}
 				msg.AddValue(packet.value, packet.labels...)
@@ -94,6 +109,7 @@ func (mgl *RateLatencyLogger) run() {
 				msg, ok := mgl.messages[packet.identifier]
 				if !ok {
 					mgl.logger.LogErrorWithoutError("wrong identifier passed. Could not find metric logger with identifier " + packet.identifier)
+					mgl.dropped.Inc()
 					continue
 				}
 				msg.SubValue(packet.value, packet.labels...)
@@ -101,6 +117,7 @@ func (mgl *RateLatencyLogger) run() {
 				msg, ok := mgl.messages[packet.identifier]
 				if !ok {
 					mgl.logger.LogErrorWithoutError("wrong identifier passed. Could not find metric logger with identifier " + packet.identifier)
+					mgl.dropped.Inc()
 					continue
 				}
 				msg.SetValue(packet.value, packet.labels...)
@@ -115,12 +132,20 @@ func (mgl *RateLatencyLogger) AddNewMetric(messageIdentifier string, newMessage
 	mgl.addMsgTunnel <- messageAdder{name: messageIdentifier, metric: newMessage}
 }
 
+// Snapshot returns the current values of all the metrics added to the logger.
+// Updates that are still queued in the logger may not be part of the snapshot
+func (mgl *RateLatencyLogger) Snapshot() ([]metrics.MetricSnapshot, error) {
+	reply := make(chan map[string]prometheus.Collector, 1)
+	mgl.snapshotTunnel <- reply
+	return metrics.SnapshotCollectors(<-reply)
+}
+
 // RateLatencyOption sets a parameter for the RateLatencyLogger
 type RateLatencyOption func(rl *RateLatencyLogger)
 
 // SetLogger sets the output logger.
 // Default is stderr
-func SetLogger(logger *CustomLogger) RateLatencyOption {
+func SetLogger(logger ILogger) RateLatencyOption {
 	return func(rl *RateLatencyLogger) {
 		rl.logger = logger
 	}
@@ -140,6 +165,7 @@ func NewRateLatencyLogger(options ...RateLatencyOption) IMultiLogger {
 		countSubTunnel: make(chan updatePacket, 10000),
 		countSetTunnel: make(chan updatePacket, 10000),
 		addMsgTunnel:   make(chan messageAdder, 10),
+		snapshotTunnel: make(chan chan map[string]prometheus.Collector),
 		logger:         nil,
 	}
 
@@ -150,6 +176,12 @@ func NewRateLatencyLogger(options ...RateLatencyOption) IMultiLogger {
 	if rateLatencyLogger.logger == nil {
 		rateLatencyLogger.logger = NewLogger()
 	}
+	metrics.RegisterLoggerMetrics()
+	facility := "unknown"
+	if customLogger, ok := rateLatencyLogger.logger.(*CustomLogger); ok {
+		facility = customLogger.graylogFacility
+	}
+	rateLatencyLogger.dropped = metrics.DroppedLines.WithLabelValues(facility, "unknown_metric")
 	rateLatencyLogger.run()
 	return rateLatencyLogger
 }
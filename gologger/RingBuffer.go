@@ -0,0 +1,141 @@
+package gologger
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// RingEntry is an entry kept in the ring buffer of the logger
+type RingEntry struct {
+	Level LogLevels
+	Time  time.Time
+	Line  string
+}
+
+// ringBuffer keeps the last entries of the logger. It is safe for concurrent use
+type ringBuffer struct {
+	lock    sync.Mutex
+	entries []RingEntry
+	next    int
+	full    bool
+}
+
+// WithRingBuffer keeps the last n entries in memory, at every level and whatever the level of the logger is,
+// so that the debug logs leading to an incident can be inspected with RingBufferHandler or RecentLogs.
+// Entries below the level of the logger are formatted but not written. Disabled by default
+func WithRingBuffer(n int) Option {
+	return func(l *CustomLogger) {
+		if n > 0 {
+			l.ring = &ringBuffer{entries: make([]RingEntry, n)}
+		}
+	}
+}
+
+func (r *ringBuffer) add(level LogLevels, line string) {
+	r.lock.Lock()
+	r.entries[r.next] = RingEntry{Level: level, Time: time.Now(), Line: line}
+	r.next = (r.next + 1) % len(r.entries)
+	if r.next == 0 {
+		r.full = true
+	}
+	r.lock.Unlock()
+}
+
+// snapshot returns the entries at the level or more severe, oldest first
+func (r *ringBuffer) snapshot(level LogLevels) []RingEntry {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	var ordered []RingEntry
+	if r.full {
+		ordered = append(ordered, r.entries[r.next:]...)
+	}
+	ordered = append(ordered, r.entries[:r.next]...)
+	filtered := ordered[:0]
+	for _, entry := range ordered {
+		if entry.Level <= level {
+			filtered = append(filtered, entry)
+		}
+	}
+	return filtered
+}
+
+// keepEntry formats an entry of a disabled level and keeps it in the ring buffer without writing it
+func (l *CustomLogger) keepEntry(message string, level LogLevels, pairs []Pair) {
+	buf := getBuffer()
+	*buf = append(*buf, '{')
+	for _, pair := range pairs {
+		*buf = l.appendFieldPair(*buf, pair.Key, pair.Value)
+	}
+	*buf = l.appendEntryEnd(*buf, message, level)
+	l.ring.add(level, string(*buf))
+	putBuffer(buf)
+}
+
+// RecentEntries returns the entries in the ring buffer at the level or more severe, oldest first.
+// It returns nil if the logger has no ring buffer
+func (l *CustomLogger) RecentEntries(level LogLevels) []RingEntry {
+	if l.ring == nil {
+		return nil
+	}
+	return l.ring.snapshot(level)
+}
+
+// RecentLogs returns the lines in the ring buffer, oldest first. It can be used as the recent logs of a crash report
+func (l *CustomLogger) RecentLogs() []string {
+	entries := l.RecentEntries(DEBUG)
+	lines := make([]string, len(entries))
+	for i, entry := range entries {
+		lines[i] = entry.Line
+	}
+	return lines
+}
+
+// RingBufferHandler returns a handler which dumps the ring buffer as newline delimited json, oldest first.
+// The level query parameter (ERROR, WARN, INFO or DEBUG, default DEBUG) filters the entries and
+// the limit query parameter returns only the last entries. It should only be served on an internal port
+func (l *CustomLogger) RingBufferHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if l.ring == nil {
+			http.Error(w, "ring buffer is not enabled", http.StatusNotFound)
+			return
+		}
+		level := DEBUG
+		if value := r.URL.Query().Get("level"); value != "" {
+			parsed, ok := parseLogLevel(value)
+			if !ok {
+				http.Error(w, "invalid level "+value, http.StatusBadRequest)
+				return
+			}
+			level = parsed
+		}
+		entries := l.ring.snapshot(level)
+		if value := r.URL.Query().Get("limit"); value != "" {
+			limit, err := strconv.Atoi(value)
+			if err != nil || limit < 0 {
+				http.Error(w, "invalid limit "+value, http.StatusBadRequest)
+				return
+			}
+			if limit < len(entries) {
+				entries = entries[len(entries)-limit:]
+			}
+		}
+		w.Header().Set("Content-Type", "application/x-ndjson")
+		for _, entry := range entries {
+			w.Write([]byte(entry.Line))
+			w.Write([]byte{'\n'})
+		}
+	})
+}
+
+// parseLogLevel parses the name of a level, case insensitively
+func parseLogLevel(value string) (LogLevels, bool) {
+	for level := ERROR; level <= DEBUG; level++ {
+		if strings.EqualFold(level.String(), value) {
+			return level, true
+		}
+	}
+	return ERROR, false
+}
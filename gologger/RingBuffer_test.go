@@ -0,0 +1,53 @@
+package gologger
+
+import (
+	"bytes"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestRingBufferKeepsDisabledLevels(t *testing.T) {
+	var buffer bytes.Buffer
+	logger := &CustomLogger{logLevel: ERROR, logger: log.New(&buffer, "", 0)}
+	WithRingBuffer(3)(logger)
+
+	logger.LogDebug("first")
+	logger.LogInfo("second")
+	logger.Debug().Str("id", "42").Send("third")
+	logger.LogErrorWithoutError("fourth")
+
+	if strings.Contains(buffer.String(), "third") || !strings.Contains(buffer.String(), "fourth") {
+		t.Errorf("written = %q, want only the error", buffer.String())
+	}
+	lines := logger.RecentLogs()
+	if len(lines) != 3 || !strings.Contains(lines[0], "second") || !strings.Contains(lines[1], `"id"`) || !strings.Contains(lines[2], "fourth") {
+		t.Errorf("recent logs = %v, want second, third and fourth", lines)
+	}
+	if entries := logger.RecentEntries(INFO); len(entries) != 2 {
+		t.Errorf("got %d entries at INFO, want 2", len(entries))
+	}
+}
+
+func TestRingBufferHandler(t *testing.T) {
+	logger := &CustomLogger{logLevel: ERROR, logger: log.New(&bytes.Buffer{}, "", 0)}
+	WithRingBuffer(10)(logger)
+	logger.LogDebug("debug")
+	logger.LogWarning("warning")
+	logger.LogErrorWithoutError("error")
+
+	rec := httptest.NewRecorder()
+	logger.RingBufferHandler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/logs?level=warn&limit=1", nil))
+	body := strings.TrimSpace(rec.Body.String())
+	if rec.Code != http.StatusOK || strings.Count(body, "\n") != 0 || !strings.Contains(body, `"error"`) {
+		t.Errorf("status = %d, body = %q, want only the error", rec.Code, body)
+	}
+
+	rec = httptest.NewRecorder()
+	logger.RingBufferHandler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/logs?level=verbose", nil))
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("status = %d for invalid level, want 400", rec.Code)
+	}
+}
@@ -0,0 +1,119 @@
+package gologger
+
+import (
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const (
+	runtimeGoroutinesMetricID = "RUNTIME-GOROUTINES"
+	runtimeGCPauseMetricID    = "RUNTIME-GC-PAUSE"
+)
+
+// trackedGoroutines holds the number of running goroutines per label started with TrackGoroutine
+var trackedGoroutines sync.Map
+
+var runtimeMetricsOnce sync.Once
+
+// TrackGoroutine counts a running goroutine under the label until the returned function is called.
+// The counts are exported by EnableRuntimeMetrics in the runtime_goroutines gauge
+//
+//	go func() {
+//		defer gologger.TrackGoroutine("consumer")()
+//		...
+//	}()
+func TrackGoroutine(label string) func() {
+	value, _ := trackedGoroutines.LoadOrStore(label, new(int64))
+	count := value.(*int64)
+	atomic.AddInt64(count, 1)
+	var once sync.Once
+	return func() { once.Do(func() { atomic.AddInt64(count, -1) }) }
+}
+
+// EnableRuntimeMetrics registers the go runtime and process collectors with the default registry,
+// and adds a gc pause histogram and a gauge of the goroutines started with TrackGoroutine to the multi logger.
+// The runtime is read every interval, 15 seconds if interval is 0. Calling it more than once has no effect
+func EnableRuntimeMetrics(multiLogger IMultiLogger, logger ILogger, interval time.Duration) {
+	runtimeMetricsOnce.Do(func() {
+		if interval <= 0 {
+			interval = 15 * time.Second
+		}
+		// The default registry usually registers these collectors already
+		for _, collector := range []prometheus.Collector{
+			prometheus.NewGoCollector(),
+			prometheus.NewProcessCollector(prometheus.ProcessCollectorOpts{}),
+		} {
+			if err := prometheus.Register(collector); err != nil {
+				if _, ok := err.(prometheus.AlreadyRegisteredError); !ok {
+					logger.LogError("could not register runtime collector", err)
+				}
+			}
+		}
+		multiLogger.AddNewMetric(runtimeGoroutinesMetricID, NewGaugeMetric(prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "runtime_goroutines",
+				Help: "Number of running goroutines started with TrackGoroutine per label",
+			},
+			[]string{"Label"},
+		), logger))
+		gcPause := NewHistogramMetric(prometheus.NewHistogramVec(
+			prometheus.HistogramOpts{
+				Name:    "runtime_gc_pause_milliseconds",
+				Help:    "Stop the world pause of the garbage collections in milliseconds",
+				Buckets: []float64{0.01, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 25, 50, 100},
+			},
+			[]string{},
+		), logger)
+		multiLogger.AddNewMetric(runtimeGCPauseMetricID, gcPause)
+
+		go func() {
+			var stats runtime.MemStats
+			runtime.ReadMemStats(&stats)
+			lastNumGC := stats.NumGC
+			ticker := time.NewTicker(interval)
+			defer ticker.Stop()
+			for range ticker.C {
+				runtime.ReadMemStats(&stats)
+				// The pauses are observed directly as the multi logger can only time from a start time
+				for _, pause := range gcPausesSince(&stats, lastNumGC) {
+					gcPause.UpdateTime(int64(pause / time.Microsecond))
+				}
+				lastNumGC = stats.NumGC
+				for label, count := range trackedGoroutineCounts() {
+					multiLogger.SetVal(count, runtimeGoroutinesMetricID, label)
+				}
+			}
+		}()
+	})
+}
+
+// gcPausesSince returns the pauses of the garbage collections after lastNumGC, oldest first.
+// Only the last 256 pauses are kept by the runtime, older ones are lost
+func gcPausesSince(stats *runtime.MemStats, lastNumGC uint32) []time.Duration {
+	n := stats.NumGC - lastNumGC
+	if n == 0 {
+		return nil
+	}
+	if n > uint32(len(stats.PauseNs)) {
+		n = uint32(len(stats.PauseNs))
+	}
+	pauses := make([]time.Duration, 0, n)
+	for i := stats.NumGC - n; i < stats.NumGC; i++ {
+		pauses = append(pauses, time.Duration(stats.PauseNs[i%uint32(len(stats.PauseNs))]))
+	}
+	return pauses
+}
+
+// trackedGoroutineCounts returns the number of running goroutines per label
+func trackedGoroutineCounts() map[string]int64 {
+	counts := map[string]int64{}
+	trackedGoroutines.Range(func(key, value interface{}) bool {
+		counts[key.(string)] = atomic.LoadInt64(value.(*int64))
+		return true
+	})
+	return counts
+}
@@ -0,0 +1,38 @@
+package gologger
+
+import (
+	"runtime"
+	"testing"
+)
+
+func TestGCPausesSince(t *testing.T) {
+	var stats runtime.MemStats
+	stats.NumGC = 300
+	for i := range stats.PauseNs {
+		stats.PauseNs[i] = uint64(i)
+	}
+	pauses := gcPausesSince(&stats, 297)
+	// The gcs 297, 298 and 299 (0 based) are at 41, 42 and 43
+	if len(pauses) != 3 || pauses[0] != 41 || pauses[2] != 43 {
+		t.Errorf("pauses = %v, want [41 42 43]", pauses)
+	}
+	if pauses := gcPausesSince(&stats, 0); len(pauses) != len(stats.PauseNs) {
+		t.Errorf("got %d pauses, want the %d kept by the runtime", len(pauses), len(stats.PauseNs))
+	}
+	if pauses := gcPausesSince(&stats, 300); pauses != nil {
+		t.Errorf("pauses = %v, want none", pauses)
+	}
+}
+
+func TestTrackGoroutine(t *testing.T) {
+	done := TrackGoroutine("test-worker")
+	TrackGoroutine("test-worker")()
+	if count := trackedGoroutineCounts()["test-worker"]; count != 1 {
+		t.Errorf("count = %d, want 1", count)
+	}
+	done()
+	done()
+	if count := trackedGoroutineCounts()["test-worker"]; count != 0 {
+		t.Errorf("count after done = %d, want 0", count)
+	}
+}
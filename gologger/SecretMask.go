@@ -0,0 +1,68 @@
+package gologger
+
+import (
+	"encoding/json"
+	"io"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// minSecretLength is the length under which AddSecretValue ignores a value, as short values would mask
+// too many unrelated parts of the logs
+const minSecretLength = 4
+
+var (
+	secretValuesLock sync.Mutex
+	secretValues     = map[string]bool{}
+	// secretReplacer holds the *strings.Replacer masking the secret values, nil until a value is added
+	secretReplacer atomic.Value
+)
+
+// AddSecretValue masks the value in every line written by the CustomLoggers, whatever the field or message it
+// is logged in. It is called by the secrets package for every secret it reads, so that a credential logged by
+// mistake, e.g. in a connection error, does not reach graylog. Values shorter than 4 characters are ignored
+func AddSecretValue(value string) {
+	if len(value) < minSecretLength {
+		return
+	}
+	secretValuesLock.Lock()
+	defer secretValuesLock.Unlock()
+	if secretValues[value] {
+		return
+	}
+	secretValues[value] = true
+	var oldnew []string
+	for secret := range secretValues {
+		oldnew = append(oldnew, secret, MaskedValue)
+		// the value is json escaped in the log lines if it has quotes, backslashes or control characters
+		if escaped, _ := json.Marshal(secret); string(escaped[1:len(escaped)-1]) != secret {
+			oldnew = append(oldnew, string(escaped[1:len(escaped)-1]), MaskedValue)
+		}
+	}
+	secretReplacer.Store(strings.NewReplacer(oldnew...))
+}
+
+// MaskSecretValues returns s with the values added with AddSecretValue masked
+func MaskSecretValues(s string) string {
+	if replacer, ok := secretReplacer.Load().(*strings.Replacer); ok {
+		return replacer.Replace(s)
+	}
+	return s
+}
+
+// maskingWriter masks the secret values in the lines written to w
+type maskingWriter struct {
+	w io.Writer
+}
+
+func (mw *maskingWriter) Write(p []byte) (int, error) {
+	replacer, ok := secretReplacer.Load().(*strings.Replacer)
+	if !ok {
+		return mw.w.Write(p)
+	}
+	if _, err := io.WriteString(mw.w, replacer.Replace(string(p))); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
@@ -0,0 +1,70 @@
+package gologger
+
+import (
+	"io"
+
+	"github.com/carwale/golibraries/gologger/metrics"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// loggerSelfMetrics holds the counters of a logger resolved for its facility
+type loggerSelfMetrics struct {
+	lines       [DEBUG + 1]prometheus.Counter
+	bytes       prometheus.Counter
+	writeErrors prometheus.Counter
+}
+
+// DisableSelfMetrics disables the internal metrics of the logger (log lines per level, bytes written and write errors).
+// The metrics are exported with the prometheus default registry, see the metrics package. Default is false
+func DisableSelfMetrics(flag bool) Option {
+	return func(l *CustomLogger) { l.disableSelfMetrics = flag }
+}
+
+// EnableErrorMetrics counts every error logged with the LogError methods or the Error builder in the
+// gologger_errors_total counter, labeled by facility and error code, so that error budgets can be computed
+// without instrumenting every error site. See ICodedError and ErrorCodeKey for how the code is found. Default is false
+func EnableErrorMetrics(flag bool) Option {
+	return func(l *CustomLogger) { l.errorMetrics = flag }
+}
+
+// countError counts an error log if error metrics are enabled
+func (l *CustomLogger) countError(err error, pairs []Pair) {
+	if l.errorMetrics {
+		metrics.RegisterLoggerMetrics()
+		metrics.LogErrors.WithLabelValues(l.graylogFacility, errorCode(err, pairs)).Inc()
+	}
+}
+
+func newLoggerSelfMetrics(facility string) *loggerSelfMetrics {
+	metrics.RegisterLoggerMetrics()
+	m := &loggerSelfMetrics{
+		bytes:       metrics.LogBytes.WithLabelValues(facility),
+		writeErrors: metrics.LogWriteErrors.WithLabelValues(facility),
+	}
+	for level := ERROR; level <= DEBUG; level++ {
+		m.lines[level] = metrics.LogLines.WithLabelValues(facility, level.String())
+	}
+	return m
+}
+
+// countLine counts a line logged with the level
+func (l *CustomLogger) countLine(level LogLevels) {
+	if l.selfMetrics != nil && level <= DEBUG {
+		l.selfMetrics.lines[level].Inc()
+	}
+}
+
+// countingWriter counts the bytes written and the failed writes
+type countingWriter struct {
+	w       io.Writer
+	metrics *loggerSelfMetrics
+}
+
+func (cw *countingWriter) Write(p []byte) (int, error) {
+	n, err := cw.w.Write(p)
+	cw.metrics.bytes.Add(float64(n))
+	if err != nil {
+		cw.metrics.writeErrors.Inc()
+	}
+	return n, err
+}
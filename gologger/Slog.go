@@ -0,0 +1,219 @@
+//go:build go1.21
+
+package gologger
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sync/atomic"
+)
+
+// slogHandler is an slog.Handler writing the records to an ILogger
+type slogHandler struct {
+	logger ILogger
+	pairs  []Pair
+	prefix string
+}
+
+// NewSlogHandler returns an slog.Handler which writes the records to the logger, so that code written
+// against log/slog uses the graylog configuration of the service
+//
+//	slog.SetDefault(slog.New(gologger.NewSlogHandler(logger)))
+//
+// Attributes are logged as fields, with the keys of groups prefixed by the group name and a dot.
+// With a *CustomLogger the level override and the ids of the context are applied as in the
+// WithContext methods. A logger returned by NewSlogLogger is unwrapped to its handler, so records
+// are not passed back and forth between the two
+func NewSlogHandler(logger ILogger) slog.Handler {
+	if slogLogger, ok := logger.(*SlogLogger); ok {
+		return slogLogger.logger.Handler()
+	}
+	return &slogHandler{logger: logger}
+}
+
+// Enabled returns true if the logger logs the level
+func (h *slogHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	return levelEnabled(ctx, h.logger, levelFromSlog(level))
+}
+
+// Handle logs the record with the fields of its attributes
+func (h *slogHandler) Handle(ctx context.Context, record slog.Record) error {
+	pairs := make([]Pair, len(h.pairs), len(h.pairs)+record.NumAttrs())
+	copy(pairs, h.pairs)
+	record.Attrs(func(attr slog.Attr) bool {
+		pairs = appendAttrPairs(pairs, h.prefix, attr)
+		return true
+	})
+	logPairs(ctx, h.logger, levelFromSlog(record.Level), record.Message, pairs)
+	return nil
+}
+
+// WithAttrs returns a handler which adds the attributes to every record
+func (h *slogHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	pairs := make([]Pair, len(h.pairs), len(h.pairs)+len(attrs))
+	copy(pairs, h.pairs)
+	for _, attr := range attrs {
+		pairs = appendAttrPairs(pairs, h.prefix, attr)
+	}
+	return &slogHandler{logger: h.logger, pairs: pairs, prefix: h.prefix}
+}
+
+// WithGroup returns a handler which prefixes the keys of the attributes with the group name
+func (h *slogHandler) WithGroup(name string) slog.Handler {
+	if name == "" {
+		return h
+	}
+	return &slogHandler{logger: h.logger, pairs: h.pairs, prefix: h.prefix + name + "."}
+}
+
+// appendAttrPairs appends the attribute to pairs, flattening the groups
+func appendAttrPairs(pairs []Pair, prefix string, attr slog.Attr) []Pair {
+	value := attr.Value.Resolve()
+	if value.Kind() == slog.KindGroup {
+		if attr.Key != "" {
+			prefix += attr.Key + "."
+		}
+		for _, groupAttr := range value.Group() {
+			pairs = appendAttrPairs(pairs, prefix, groupAttr)
+		}
+		return pairs
+	}
+	if attr.Key == "" {
+		return pairs
+	}
+	return append(pairs, Pair{Key: prefix + attr.Key, Value: value.String()})
+}
+
+func levelFromSlog(level slog.Level) LogLevels {
+	switch {
+	case level >= slog.LevelError:
+		return ERROR
+	case level >= slog.LevelWarn:
+		return WARN
+	case level >= slog.LevelInfo:
+		return INFO
+	}
+	return DEBUG
+}
+
+// SlogLogger is an ILogger writing to an slog.Logger, so that the golibraries packages can log to the
+// slog configuration of the service. The records are filtered by the level of the handler and by the
+// level set with SetLogLevel, which is DEBUG by default
+type SlogLogger struct {
+	logger *slog.Logger
+	level  uint32
+}
+
+var _ ILogger = (*SlogLogger)(nil)
+
+// NewSlogLogger returns an ILogger which writes to the handler. A handler returned by NewSlogHandler
+// without attributes or groups is unwrapped to its logger
+func NewSlogLogger(handler slog.Handler) ILogger {
+	if h, ok := handler.(*slogHandler); ok && len(h.pairs) == 0 && h.prefix == "" {
+		return h.logger
+	}
+	return &SlogLogger{logger: slog.New(handler), level: uint32(DEBUG)}
+}
+
+// GetLogLevel returns the level set with SetLogLevel
+func (l *SlogLogger) GetLogLevel() LogLevels {
+	return LogLevels(atomic.LoadUint32(&l.level))
+}
+
+// SetLogLevel changes the level of the logger while it is in use. Records of a level the handler does not
+// log are dropped at any level
+func (l *SlogLogger) SetLogLevel(level string) error {
+	logLevel, err := ParseLogLevel(level)
+	if err != nil {
+		return err
+	}
+	atomic.StoreUint32(&l.level, uint32(logLevel))
+	return nil
+}
+
+// enabled returns true if the level is logged by the logger and by the handler
+func (l *SlogLogger) enabled(level slog.Level) bool {
+	return l.GetLogLevel() >= levelFromSlog(level) && l.logger.Enabled(context.Background(), level)
+}
+
+// LogError is used to log errors and a message along with the error
+func (l *SlogLogger) LogError(str string, err error) {
+	l.LogErrorMessage(str, err)
+}
+
+// LogErrorWithoutError is used to log only a message and not an error
+func (l *SlogLogger) LogErrorWithoutError(str string) {
+	l.log(slog.LevelError, str, nil)
+}
+
+// LogErrorWithoutErrorf is used to log only a message and not an error
+func (l *SlogLogger) LogErrorWithoutErrorf(str string, args ...interface{}) {
+	l.logFormatted(slog.LevelError, str, args)
+}
+
+// LogErrorMessage is used to log extra fields along with the error
+func (l *SlogLogger) LogErrorMessage(str string, err error, pairs ...Pair) {
+	if err != nil {
+		pairs = append(pairs, Pair{"log_error", err.Error()})
+	}
+	l.log(slog.LevelError, str, pairs)
+}
+
+// LogWarning is used to log warning messages
+func (l *SlogLogger) LogWarning(str string) {
+	l.log(slog.LevelWarn, str, nil)
+}
+
+// LogWarningf is used to log warning messages
+func (l *SlogLogger) LogWarningf(str string, args ...interface{}) {
+	l.logFormatted(slog.LevelWarn, str, args)
+}
+
+// LogWarningMessage is used to log warning messages along with extra fields
+func (l *SlogLogger) LogWarningMessage(str string, pairs ...Pair) {
+	l.log(slog.LevelWarn, str, pairs)
+}
+
+// LogInfo is used to log info messages
+func (l *SlogLogger) LogInfo(str string) {
+	l.log(slog.LevelInfo, str, nil)
+}
+
+// LogInfof is used to log formatted info messages
+func (l *SlogLogger) LogInfof(str string, args ...interface{}) {
+	l.logFormatted(slog.LevelInfo, str, args)
+}
+
+// LogInfoMessage is used to log info messages along with extra fields
+func (l *SlogLogger) LogInfoMessage(str string, pairs ...Pair) {
+	l.log(slog.LevelInfo, str, pairs)
+}
+
+// LogDebug is used to log debug messages
+func (l *SlogLogger) LogDebug(str string) {
+	l.log(slog.LevelDebug, str, nil)
+}
+
+// LogDebugf is used to log debug messages
+func (l *SlogLogger) LogDebugf(str string, args ...interface{}) {
+	l.logFormatted(slog.LevelDebug, str, args)
+}
+
+func (l *SlogLogger) log(level slog.Level, str string, pairs []Pair) {
+	if !l.enabled(level) {
+		return
+	}
+	attrs := make([]slog.Attr, len(pairs))
+	for i, pair := range pairs {
+		attrs[i] = slog.String(pair.Key, pair.Value)
+	}
+	l.logger.LogAttrs(context.Background(), level, str, attrs...)
+}
+
+// logFormatted formats the message only if the level is enabled
+func (l *SlogLogger) logFormatted(level slog.Level, str string, args []interface{}) {
+	if l.enabled(level) {
+		l.logger.Log(context.Background(), level, fmt.Sprintf(str, args...))
+	}
+}
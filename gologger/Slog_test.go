@@ -0,0 +1,66 @@
+//go:build go1.21
+
+package gologger
+
+import (
+	"bytes"
+	"encoding/json"
+	"log"
+	"log/slog"
+	"testing"
+)
+
+func TestSlogHandler(t *testing.T) {
+	var buffer bytes.Buffer
+	logger := &CustomLogger{logLevel: INFO, logger: log.New(&buffer, "", 0)}
+	slogger := slog.New(NewSlogHandler(logger)).With("service", "stocks").WithGroup("request")
+
+	slogger.Debug("skipped")
+	slogger.Info("served", "status", 200)
+
+	var entry map[string]string
+	if err := json.Unmarshal(buffer.Bytes(), &entry); err != nil {
+		t.Fatalf("could not parse the entry %q: %v", buffer.String(), err)
+	}
+	if entry["log_message"] != "served" || entry["service"] != "stocks" || entry["request.status"] != "200" {
+		t.Errorf("entry = %v", entry)
+	}
+}
+
+func TestSlogLogger(t *testing.T) {
+	var buffer bytes.Buffer
+	logger := NewSlogLogger(slog.NewJSONHandler(&buffer, nil))
+
+	logger.LogWarningMessage("slow", Pair{"elapsed_ms", "1200"})
+
+	var entry map[string]string
+	if err := json.Unmarshal(buffer.Bytes(), &entry); err != nil {
+		t.Fatalf("could not parse the entry %q: %v", buffer.String(), err)
+	}
+	if entry["msg"] != "slow" || entry["level"] != "WARN" || entry["elapsed_ms"] != "1200" {
+		t.Errorf("entry = %v", entry)
+	}
+	if NewSlogHandler(logger) != logger.(*SlogLogger).logger.Handler() {
+		t.Error("NewSlogHandler() wrapped a SlogLogger")
+	}
+	custom := &CustomLogger{}
+	if NewSlogLogger(NewSlogHandler(custom)) != ILogger(custom) {
+		t.Error("NewSlogLogger() wrapped the handler of a CustomLogger")
+	}
+}
+
+func TestSlogLoggerSetLogLevel(t *testing.T) {
+	var buffer bytes.Buffer
+	logger := NewSlogLogger(slog.NewJSONHandler(&buffer, nil))
+	if err := logger.SetLogLevel("ERROR"); err != nil {
+		t.Fatal(err)
+	}
+	logger.LogWarning("skipped")
+	if buffer.Len() != 0 {
+		t.Errorf("logged %q below the level", buffer.String())
+	}
+	logger.LogErrorWithoutError("failed")
+	if buffer.Len() == 0 {
+		t.Error("the error was not logged")
+	}
+}
@@ -0,0 +1,144 @@
+package gologger
+
+import (
+	"context"
+	"encoding/json"
+	"strconv"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel/trace"
+)
+
+type timerContextKey struct{}
+
+// Timer measures the time taken by a step and its sub steps. It is created by TicCtx.
+// Sub timers are created by calling Tic on the timer or by calling TicCtx with the context returned by Context.
+// When the root timer is stopped a single entry with the time taken by the step and a breakdown
+// of the time taken by every sub step is logged
+type Timer struct {
+	logger   *CustomLogger
+	ctx      context.Context
+	name     string
+	start    time.Time
+	parent   *Timer
+	root     *Timer
+	lock     sync.Mutex // guards children and elapsed of all the timers of the tree
+	children []*Timer
+	elapsed  time.Duration
+	stopped  bool
+}
+
+// timerEntry is the structured log entry of a root timer
+type timerEntry struct {
+	Timestamp    string            `json:"log_timestamp"`
+	TimeTaken    string            `json:"log_timetaken"`
+	Facility     string            `json:"log_facility"`
+	Message      string            `json:"log_message"`
+	K8sNamespace string            `json:"K8sNamespace"`
+	TraceID      string            `json:"trace_id,omitempty"`
+	SpanID       string            `json:"span_id,omitempty"`
+	Breakdown    map[string]string `json:"log_breakdown,omitempty"`
+}
+
+// TicCtx starts a timer for the step name. If ctx already has a timer (see Timer.Context), the new timer
+// is a sub timer of it and its time is added to the breakdown of the parent. Otherwise it is a root timer.
+// Here is an example code block for using TicCtx
+//
+//	timer := logger.TicCtx(ctx, "GetStock")
+//	defer timer.Toc()
+//	ctx = timer.Context()
+//	...
+//	dbTimer := timer.Tic("ReadDB")
+//	// read from db
+//	dbTimer.Toc()
+//
+// The entry is logged only if time logging is enabled. The trace_id and span_id of the span in ctx are added to it
+func (l *CustomLogger) TicCtx(ctx context.Context, name string) *Timer {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	if parent, ok := ctx.Value(timerContextKey{}).(*Timer); ok {
+		return parent.Tic(name)
+	}
+	t := &Timer{logger: l, ctx: ctx, name: name, start: time.Now()}
+	t.root = t
+	return t
+}
+
+// Tic starts a sub timer of the timer
+func (t *Timer) Tic(name string) *Timer {
+	child := &Timer{logger: t.logger, ctx: t.ctx, name: name, start: time.Now(), parent: t, root: t.root}
+	t.root.lock.Lock()
+	t.children = append(t.children, child)
+	t.root.lock.Unlock()
+	return child
+}
+
+// Context returns a context carrying the timer. Calling TicCtx with it creates a sub timer
+func (t *Timer) Context() context.Context {
+	return context.WithValue(t.ctx, timerContextKey{}, t)
+}
+
+// Toc stops the timer and returns the time elapsed since it was started.
+// Stopping the root timer logs the entry. Calling Toc more than once has no effect
+func (t *Timer) Toc() time.Duration {
+	t.root.lock.Lock()
+	if t.stopped {
+		t.root.lock.Unlock()
+		return t.elapsed
+	}
+	t.elapsed = time.Since(t.start)
+	t.stopped = true
+	var breakdown map[string]string
+	if t.parent == nil && len(t.children) > 0 {
+		breakdown = make(map[string]string)
+		t.addBreakdown(breakdown, "")
+	}
+	t.root.lock.Unlock()
+
+	if t.parent == nil && t.logger.isTimeLoggingEnabled {
+		t.log(breakdown)
+	}
+	return t.elapsed
+}
+
+// addBreakdown adds the time taken by all the sub timers to the breakdown.
+// The key of a sub timer is the path of names from the root separated by dots.
+// Sub timers with the same path are summed up and the ones that were not stopped are counted till now
+func (t *Timer) addBreakdown(breakdown map[string]string, prefix string) {
+	for _, child := range t.children {
+		elapsed := child.elapsed
+		if !child.stopped {
+			elapsed = time.Since(child.start)
+		}
+		key := prefix + child.name
+		if previous, ok := breakdown[key]; ok {
+			ns, _ := strconv.ParseInt(previous, 10, 64)
+			elapsed += time.Duration(ns)
+		}
+		breakdown[key] = strconv.FormatInt(elapsed.Nanoseconds(), 10)
+		child.addBreakdown(breakdown, key+".")
+	}
+}
+
+func (t *Timer) log(breakdown map[string]string) {
+	entry := timerEntry{
+		Timestamp:    time.Now().String(),
+		TimeTaken:    strconv.FormatInt(t.elapsed.Nanoseconds(), 10),
+		Facility:     t.logger.graylogFacility,
+		Message:      t.name,
+		K8sNamespace: t.logger.k8sNamespace,
+		Breakdown:    breakdown,
+	}
+	if spanContext := trace.SpanContextFromContext(t.ctx); spanContext.IsValid() {
+		entry.TraceID = spanContext.TraceID().String()
+		entry.SpanID = spanContext.SpanID().String()
+	}
+	message, err := json.Marshal(entry)
+	if err != nil {
+		t.logger.LogError("Could not marshal the timer entry of "+t.name, err)
+		return
+	}
+	t.logger.writeLine(string(message), INFO)
+}
@@ -0,0 +1,66 @@
+package gologger
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log"
+	"testing"
+)
+
+func TestTimerBreakdown(t *testing.T) {
+	var buffer bytes.Buffer
+	logger := &CustomLogger{isTimeLoggingEnabled: true, logger: log.New(&buffer, "", 0)}
+
+	timer := logger.TicCtx(context.Background(), "request")
+	ctx := timer.Context()
+	db := logger.TicCtx(ctx, "db")
+	db.Tic("query").Toc()
+	db.Toc()
+	for i := 0; i < 2; i++ {
+		timer.Tic("cache").Toc()
+	}
+	timer.Toc()
+
+	var entry timerEntry
+	if err := json.Unmarshal(buffer.Bytes(), &entry); err != nil {
+		t.Fatalf("could not parse the entry %q: %v", buffer.String(), err)
+	}
+	if entry.Message != "request" {
+		t.Errorf("log_message = %q, want request", entry.Message)
+	}
+	for _, key := range []string{"db", "db.query", "cache"} {
+		if _, ok := entry.Breakdown[key]; !ok {
+			t.Errorf("breakdown %v does not have %q", entry.Breakdown, key)
+		}
+	}
+	if len(entry.Breakdown) != 3 {
+		t.Errorf("breakdown = %v, want 3 entries", entry.Breakdown)
+	}
+}
+
+func TestSubTimerDoesNotLog(t *testing.T) {
+	var buffer bytes.Buffer
+	logger := &CustomLogger{isTimeLoggingEnabled: true, logger: log.New(&buffer, "", 0)}
+
+	timer := logger.TicCtx(context.Background(), "request")
+	timer.Tic("step").Toc()
+	if buffer.Len() != 0 {
+		t.Errorf("sub timer logged %q", buffer.String())
+	}
+}
+
+func TestTocIsValidJSON(t *testing.T) {
+	var buffer bytes.Buffer
+	logger := &CustomLogger{isTimeLoggingEnabled: true, graylogFacility: "test", logger: log.New(&buffer, "", 0)}
+
+	logger.Toc(logger.Tic("query \"stocks\" \xff"))
+
+	var entry map[string]string
+	if err := json.Unmarshal(buffer.Bytes(), &entry); err != nil {
+		t.Fatalf("entry %q is not valid json: %v", buffer.String(), err)
+	}
+	if entry["log_message"] != "query \"stocks\" �" || entry["log_facility"] != "test" || entry["log_timetaken"] == "" {
+		t.Errorf("entry = %v", entry)
+	}
+}
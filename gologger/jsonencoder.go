@@ -0,0 +1,134 @@
+package gologger
+
+import (
+	"sync"
+	"unicode/utf8"
+)
+
+// The log entries are flat JSON objects with string values. They are encoded by appending to
+// pooled byte slices to avoid the garbage of fmt and bytes.Buffer for every entry
+
+const (
+	maxPooledBufferSize = 64 << 10
+	timestampLayout     = "2006-01-02 15:04:05.999999999 -0700 MST"
+	hexDigits           = "0123456789abcdef"
+)
+
+// safeASCII are the ASCII characters which are not escaped in JSON strings
+var safeASCII = func() (safe [utf8.RuneSelf]bool) {
+	for c := 0x20; c < utf8.RuneSelf; c++ {
+		safe[c] = c != '"' && c != '\\'
+	}
+	return safe
+}()
+
+var bufferPool = sync.Pool{
+	New: func() interface{} {
+		buf := make([]byte, 0, 512)
+		return &buf
+	},
+}
+
+func getBuffer() *[]byte {
+	return bufferPool.Get().(*[]byte)
+}
+
+func putBuffer(buf *[]byte) {
+	if cap(*buf) <= maxPooledBufferSize {
+		*buf = (*buf)[:0]
+		bufferPool.Put(buf)
+	}
+}
+
+// appendJSONString appends s as a quoted JSON string
+func appendJSONString(buf []byte, s string) []byte {
+	buf = append(buf, '"')
+	buf = appendEscaped(buf, s)
+	return append(buf, '"')
+}
+
+// appendEscaped appends s escaped for a JSON string. Control characters are escaped
+// and invalid UTF-8 is replaced by the replacement character
+func appendEscaped(buf []byte, s string) []byte {
+	// Most strings need no escaping and are appended at once
+	i := 0
+	for i < len(s) && s[i] < utf8.RuneSelf && safeASCII[s[i]] {
+		i++
+	}
+	if i == len(s) {
+		return append(buf, s...)
+	}
+	start := 0
+	for i < len(s) {
+		c := s[i]
+		if c < utf8.RuneSelf {
+			if safeASCII[c] {
+				i++
+				continue
+			}
+			buf = append(buf, s[start:i]...)
+			switch c {
+			case '"', '\\':
+				buf = append(buf, '\\', c)
+			case '\n':
+				buf = append(buf, '\\', 'n')
+			case '\r':
+				buf = append(buf, '\\', 'r')
+			case '\t':
+				buf = append(buf, '\\', 't')
+			default:
+				buf = append(buf, '\\', 'u', '0', '0', hexDigits[c>>4], hexDigits[c&0xf])
+			}
+			i++
+			start = i
+			continue
+		}
+		r, size := utf8.DecodeRuneInString(s[i:])
+		if r == utf8.RuneError && size == 1 {
+			buf = append(buf, s[start:i]...)
+			buf = append(buf, "\ufffd"...)
+			i += size
+			start = i
+			continue
+		}
+		i += size
+	}
+	return append(buf, s[start:]...)
+}
+
+func appendKey(buf []byte, key string) []byte {
+	buf = appendJSONString(buf, key)
+	return append(buf, ':')
+}
+
+// appendPair appends "key":"value", to buf
+func appendPair(buf []byte, key, value string) []byte {
+	buf = appendKey(buf, key)
+	buf = appendJSONString(buf, value)
+	return append(buf, ',')
+}
+
+// appendFieldPair appends an additional field with the prefix of the field mapping
+func (l *CustomLogger) appendFieldPair(buf []byte, key, value string) []byte {
+	buf = l.appendFieldKey(buf, key)
+	buf = appendJSONString(buf, value)
+	return append(buf, ',')
+}
+
+// appendFieldKey appends the key of an additional field with the prefix of the field mapping
+func (l *CustomLogger) appendFieldKey(buf []byte, key string) []byte {
+	buf = append(buf, '"')
+	if l.fieldMapping != nil {
+		buf = appendEscaped(buf, l.fieldMapping.ExtraFieldPrefix)
+	}
+	buf = appendEscaped(buf, key)
+	return append(buf, '"', ':')
+}
+
+// appendEntryEnd appends the extension fields and the standard fields of every entry and closes the object
+func (l *CustomLogger) appendEntryEnd(buf []byte, message string, level LogLevels) []byte {
+	for _, pair := range l.extensionFields(level, message) {
+		buf = l.appendFieldPair(buf, pair.Key, pair.Value)
+	}
+	return l.mapping().appendStandardFields(buf, l, message, level)
+}
@@ -0,0 +1,78 @@
+package metrics
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// CounterMetric : Default counter message type implementing IMetricVec
+type CounterMetric struct {
+	counter *prometheus.CounterVec
+	logger  Logger
+}
+
+// UpdateTime is a do nothing operation for counter metric
+func (msg *CounterMetric) UpdateTime(elapsed int64, labels ...string) {
+	msg.logger.LogWarning("Cannot use Update for counter metric")
+}
+
+// AddValue will increment the counter by value
+func (msg *CounterMetric) AddValue(count int64, labels ...string) {
+	msg.counter.WithLabelValues(labels...).Add(float64(count))
+}
+
+// SubValue will not do anything. It is not allowed in counters
+func (msg *CounterMetric) SubValue(count int64, labels ...string) {
+	msg.logger.LogWarning("Cannot subtract values from counters")
+}
+
+// SetValue will not do anything. It is not allowed in counters
+func (msg *CounterMetric) SetValue(count int64, labels ...string) {
+	msg.logger.LogWarning("Cannot reset counters")
+}
+
+// RemoveLogging will stop logging for specific labels
+func (msg *CounterMetric) RemoveLogging(labels ...string) {
+	ok := msg.counter.DeleteLabelValues(labels...)
+	if !ok {
+		msg.logger.LogErrorWithoutErrorf("Could not delete metric with labels ", labels)
+	}
+}
+
+// Collector returns the prometheus collector of the metric
+func (msg *CounterMetric) Collector() prometheus.Collector {
+	return msg.counter
+}
+
+// NewCounterMetric creates a new counter message and registers it to prometheus
+func NewCounterMetric(counter *prometheus.CounterVec, logger Logger) *CounterMetric {
+	msg := &CounterMetric{counter, logger}
+	prometheus.MustRegister(counter)
+	return msg
+}
+
+// Counter is a handle to a counter added to a multi logger
+type Counter struct {
+	id          string
+	multiLogger IMultiLogger
+}
+
+// NewCounter registers the counter and adds it to the multi logger with the given identifier
+func NewCounter(multiLogger IMultiLogger, id string, counter *prometheus.CounterVec, logger Logger) Counter {
+	multiLogger.AddNewMetric(id, NewCounterMetric(counter, logger))
+	return Counter{id: id, multiLogger: multiLogger}
+}
+
+// ID returns the identifier of the counter in the multi logger
+func (c Counter) ID() string {
+	return c.id
+}
+
+// Inc increments the counter by 1
+func (c Counter) Inc(labels ...string) {
+	c.multiLogger.IncVal(1, c.id, labels...)
+}
+
+// Add increments the counter by value
+func (c Counter) Add(value int64, labels ...string) {
+	c.multiLogger.IncVal(value, c.id, labels...)
+}
@@ -0,0 +1,50 @@
+package metrics_test
+
+import (
+	"github.com/carwale/golibraries/gologger"
+	"github.com/carwale/golibraries/gologger/metrics"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+func ExampleNewCounter() {
+	logger := gologger.NewLogger()
+	latencyLogger := gologger.NewRateLatencyLogger(gologger.SetLogger(logger))
+
+	requests := metrics.NewCounter(latencyLogger, "EXAMPLE-REQUESTS",
+		prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "example_requests_total",
+			Help: "Number of requests served",
+		}, []string{"Path"}), logger)
+
+	requests.Inc("/health")
+}
+
+func ExampleNewGauge() {
+	logger := gologger.NewLogger()
+	latencyLogger := gologger.NewRateLatencyLogger(gologger.SetLogger(logger))
+
+	inFlight := metrics.NewGauge(latencyLogger, "EXAMPLE-IN-FLIGHT",
+		prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: "example_in_flight_requests",
+			Help: "Number of requests being served",
+		}, []string{"Path"}), logger)
+
+	inFlight.Add(1, "/search")
+	defer inFlight.Sub(1, "/search")
+}
+
+func ExampleNewHistogram() {
+	logger := gologger.NewLogger()
+	latencyLogger := gologger.NewRateLatencyLogger(gologger.SetLogger(logger))
+
+	latency := metrics.NewHistogram(latencyLogger, "EXAMPLE-LATENCY",
+		prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "example_request_duration_milliseconds",
+			Help:    "Latency of requests in milliseconds",
+			Buckets: []float64{5, 10, 25, 50, 100, 250, 500, 1000},
+		}, []string{"Path"}), logger)
+
+	start := latency.Tic()
+	// serve the request
+	latency.Toc(start, "/search")
+}
@@ -0,0 +1,83 @@
+package metrics
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// GaugeMetric : Default Gauge message type implementing IMetricVec
+type GaugeMetric struct {
+	gauge  *prometheus.GaugeVec
+	logger Logger
+}
+
+// UpdateTime is a do nothing operation for gauge metric
+func (msg *GaugeMetric) UpdateTime(elapsed int64, labels ...string) {
+	msg.logger.LogWarning("Cannot use Update for gauge metric")
+}
+
+// AddValue will increment the gauge by value
+func (msg *GaugeMetric) AddValue(count int64, labels ...string) {
+	msg.gauge.WithLabelValues(labels...).Add(float64(count))
+}
+
+// SubValue will decrement the gauge by value
+func (msg *GaugeMetric) SubValue(count int64, labels ...string) {
+	msg.gauge.WithLabelValues(labels...).Sub(float64(count))
+}
+
+// SetValue will set the gauge to that value
+func (msg *GaugeMetric) SetValue(count int64, labels ...string) {
+	msg.gauge.WithLabelValues(labels...).Set(float64(count))
+}
+
+// RemoveLogging will stop logging for specific labels
+func (msg *GaugeMetric) RemoveLogging(labels ...string) {
+	ok := msg.gauge.DeleteLabelValues(labels...)
+	if !ok {
+		msg.logger.LogErrorWithoutErrorf("Could not delete metric with labels ", labels)
+	}
+}
+
+// Collector returns the prometheus collector of the metric
+func (msg *GaugeMetric) Collector() prometheus.Collector {
+	return msg.gauge
+}
+
+// NewGaugeMetric creates a new gauge message and registers it to prometheus
+func NewGaugeMetric(gauge *prometheus.GaugeVec, logger Logger) *GaugeMetric {
+	msg := &GaugeMetric{gauge, logger}
+	prometheus.MustRegister(gauge)
+	return msg
+}
+
+// Gauge is a handle to a gauge added to a multi logger
+type Gauge struct {
+	id          string
+	multiLogger IMultiLogger
+}
+
+// NewGauge registers the gauge and adds it to the multi logger with the given identifier
+func NewGauge(multiLogger IMultiLogger, id string, gauge *prometheus.GaugeVec, logger Logger) Gauge {
+	multiLogger.AddNewMetric(id, NewGaugeMetric(gauge, logger))
+	return Gauge{id: id, multiLogger: multiLogger}
+}
+
+// ID returns the identifier of the gauge in the multi logger
+func (g Gauge) ID() string {
+	return g.id
+}
+
+// Add increments the gauge by value
+func (g Gauge) Add(value int64, labels ...string) {
+	g.multiLogger.IncVal(value, g.id, labels...)
+}
+
+// Sub decrements the gauge by value
+func (g Gauge) Sub(value int64, labels ...string) {
+	g.multiLogger.SubVal(value, g.id, labels...)
+}
+
+// Set sets the gauge to value
+func (g Gauge) Set(value int64, labels ...string) {
+	g.multiLogger.SetVal(value, g.id, labels...)
+}
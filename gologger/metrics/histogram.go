@@ -0,0 +1,151 @@
+package metrics
+
+import (
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// HistogramMetric : Default histogram message type implementing IMetricVec.
+// The observed values are in milliseconds
+type HistogramMetric struct {
+	histogram *prometheus.HistogramVec
+	logger    Logger
+}
+
+// UpdateTime the message with calculated latency in microseconds
+func (msg *HistogramMetric) UpdateTime(elapsed int64, labels ...string) {
+	msg.histogram.WithLabelValues(labels...).Observe(float64(elapsed) / 1000)
+}
+
+// AddValue is a do nothing function for histogram
+func (msg *HistogramMetric) AddValue(count int64, labels ...string) {
+	msg.logger.LogWarning("Cannot use IncValue for histogram metric")
+}
+
+// SubValue is a do nothing function for histogram
+func (msg *HistogramMetric) SubValue(count int64, labels ...string) {
+	msg.logger.LogWarning("Cannot use SubValue for histogram metric")
+}
+
+// SetValue is a do nothing function for histogram
+func (msg *HistogramMetric) SetValue(count int64, labels ...string) {
+	msg.logger.LogWarning("Cannot use SetValue for histogram metric")
+}
+
+// RemoveLogging will stop logging for specific labels
+func (msg *HistogramMetric) RemoveLogging(labels ...string) {
+	ok := msg.histogram.DeleteLabelValues(labels...)
+	if !ok {
+		msg.logger.LogErrorWithoutErrorf("Could not delete metric with labels ", labels)
+	}
+}
+
+// Collector returns the prometheus collector of the metric
+func (msg *HistogramMetric) Collector() prometheus.Collector {
+	return msg.histogram
+}
+
+// NewHistogramMetric creates a new histogram message and registers it to prometheus
+func NewHistogramMetric(hist *prometheus.HistogramVec, logger Logger) *HistogramMetric {
+	msg := &HistogramMetric{hist, logger}
+	prometheus.MustRegister(hist)
+	return msg
+}
+
+// Histogram is a handle to a latency histogram added to a multi logger
+type Histogram struct {
+	id          string
+	multiLogger IMultiLogger
+}
+
+// NewHistogram registers the histogram and adds it to the multi logger with the given identifier.
+// The buckets of the histogram should be in milliseconds
+func NewHistogram(multiLogger IMultiLogger, id string, hist *prometheus.HistogramVec, logger Logger) Histogram {
+	multiLogger.AddNewMetric(id, NewHistogramMetric(hist, logger))
+	return Histogram{id: id, multiLogger: multiLogger}
+}
+
+// ID returns the identifier of the histogram in the multi logger
+func (h Histogram) ID() string {
+	return h.id
+}
+
+// Tic starts the timer
+func (h Histogram) Tic() time.Time {
+	return h.multiLogger.Tic()
+}
+
+// Toc observes the time elapsed since start
+func (h Histogram) Toc(start time.Time, labels ...string) {
+	h.multiLogger.Toc(start, h.id, labels...)
+}
+
+// ValueHistogramMetric is a histogram of values which are not latencies, e.g. payload sizes in bytes.
+// The values are observed with IncVal of the multi logger
+type ValueHistogramMetric struct {
+	histogram *prometheus.HistogramVec
+	logger    Logger
+}
+
+// UpdateTime is a do nothing function for value histogram
+func (msg *ValueHistogramMetric) UpdateTime(elapsed int64, labels ...string) {
+	msg.logger.LogWarning("Cannot use UpdateTime for value histogram metric")
+}
+
+// AddValue observes the value
+func (msg *ValueHistogramMetric) AddValue(value int64, labels ...string) {
+	msg.histogram.WithLabelValues(labels...).Observe(float64(value))
+}
+
+// SubValue is a do nothing function for value histogram
+func (msg *ValueHistogramMetric) SubValue(count int64, labels ...string) {
+	msg.logger.LogWarning("Cannot use SubValue for value histogram metric")
+}
+
+// SetValue is a do nothing function for value histogram
+func (msg *ValueHistogramMetric) SetValue(count int64, labels ...string) {
+	msg.logger.LogWarning("Cannot use SetValue for value histogram metric")
+}
+
+// RemoveLogging will stop logging for specific labels
+func (msg *ValueHistogramMetric) RemoveLogging(labels ...string) {
+	ok := msg.histogram.DeleteLabelValues(labels...)
+	if !ok {
+		msg.logger.LogErrorWithoutErrorf("Could not delete metric with labels ", labels)
+	}
+}
+
+// Collector returns the prometheus collector of the metric
+func (msg *ValueHistogramMetric) Collector() prometheus.Collector {
+	return msg.histogram
+}
+
+// NewValueHistogramMetric creates a new value histogram message and registers it to prometheus
+func NewValueHistogramMetric(hist *prometheus.HistogramVec, logger Logger) *ValueHistogramMetric {
+	msg := &ValueHistogramMetric{hist, logger}
+	prometheus.MustRegister(hist)
+	return msg
+}
+
+// ValueHistogram is a handle to a value histogram added to a multi logger
+type ValueHistogram struct {
+	id          string
+	multiLogger IMultiLogger
+}
+
+// NewValueHistogram registers the histogram and adds it to the multi logger with the given identifier
+func NewValueHistogram(multiLogger IMultiLogger, id string, hist *prometheus.HistogramVec, logger Logger) ValueHistogram {
+	multiLogger.AddNewMetric(id, NewValueHistogramMetric(hist, logger))
+	return ValueHistogram{id: id, multiLogger: multiLogger}
+}
+
+// ID returns the identifier of the histogram in the multi logger
+func (h ValueHistogram) ID() string {
+	return h.id
+}
+
+// Observe observes the value
+func (h ValueHistogram) Observe(value int64, labels ...string) {
+	h.multiLogger.IncVal(value, h.id, labels...)
+}
@@ -0,0 +1,54 @@
+package metrics
+
+import (
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Internal metrics of the loggers of gologger. They are registered by RegisterLoggerMetrics
+// which is called when a logger is created with self metrics enabled
+var (
+	// LogLines counts the lines logged by the CustomLogger per facility and level
+	LogLines = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "gologger_log_lines_total",
+		Help: "Number of log lines emitted per level",
+	}, []string{"Facility", "Level"})
+	// LogBytes counts the bytes written by the CustomLogger per facility
+	LogBytes = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "gologger_log_bytes_total",
+		Help: "Number of bytes written by the logger",
+	}, []string{"Facility"})
+	// LogWriteErrors counts the failed writes of the CustomLogger per facility, e.g. when the GELF writer fails
+	LogWriteErrors = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "gologger_log_write_errors_total",
+		Help: "Number of failed log writes",
+	}, []string{"Facility"})
+	// LogErrors counts the errors logged by the CustomLogger per facility and error code.
+	// It is only updated by loggers created with gologger.EnableErrorMetrics
+	LogErrors = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "gologger_errors_total",
+		Help: "Number of errors logged per error code",
+	}, []string{"Facility", "Code"})
+	// DroppedLines counts the lines or metric updates dropped by the loggers per facility and reason
+	DroppedLines = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "gologger_dropped_lines_total",
+		Help: "Number of log lines or metric updates dropped by the logger",
+	}, []string{"Facility", "Reason"})
+)
+
+var loggerMetricsOnce sync.Once
+
+// RegisterLoggerMetrics registers the internal metrics of the loggers with the default registry.
+// Calling it more than once has no effect
+func RegisterLoggerMetrics() {
+	loggerMetricsOnce.Do(func() {
+		for _, collector := range []prometheus.Collector{LogLines, LogBytes, LogWriteErrors, LogErrors, DroppedLines} {
+			if err := prometheus.Register(collector); err != nil {
+				if _, ok := err.(prometheus.AlreadyRegisteredError); !ok {
+					panic(err)
+				}
+			}
+		}
+	})
+}
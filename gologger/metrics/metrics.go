@@ -0,0 +1,51 @@
+// Package metrics defines the interfaces used by gologger to record prometheus metrics and
+// typed handles that make it impossible to use an identifier with the wrong kind of metric.
+//
+// Metrics are added to a multi logger (gologger.NewRateLatencyLogger) with an identifier and
+// updated through the multi logger using the same identifier. Instead of passing the identifier
+// around, create a handle once and use its methods:
+//
+//	requests := metrics.NewCounter(latencyLogger, "MYSERVICE-REQUESTS",
+//		prometheus.NewCounterVec(prometheus.CounterOpts{Name: "myservice_requests_total", Help: "Requests"}, []string{"Path"}),
+//		logger)
+//	requests.Inc("/health")
+//
+// The prometheus collectors are registered with the default registry, so the handles should be
+// created only once (e.g. in a sync.Once)
+package metrics
+
+import (
+	"time"
+)
+
+// IMultiLogger : Interface for Multi message logger
+type IMultiLogger interface {
+	// To measure time elapsed between any two points in the code,
+	// Start the time logger by Tic(MessageDesc) and end the time logger by calling Toc(MessageDesc,time)
+	Tic() time.Time
+	Toc(time.Time, string, ...string)
+	IncVal(int64, string, ...string)
+	SubVal(int64, string, ...string)
+	SetVal(int64, string, ...string)
+	AddNewMetric(string, IMetricVec)
+}
+
+// IMetricVec : Interface to implement for Message type
+type IMetricVec interface {
+	UpdateTime(int64, ...string)
+	//Method to count increments or gauges
+	AddValue(int64, ...string)
+	// Method to subtract from the counter
+	SubValue(int64, ...string)
+	// Method to set the counter
+	SetValue(int64, ...string)
+	// Method to Remove the label from the metric
+	RemoveLogging(...string)
+}
+
+// Logger is used by the metrics to report operations that are not supported by the metric.
+// It is implemented by gologger.ILogger
+type Logger interface {
+	LogWarning(str string)
+	LogErrorWithoutErrorf(str string, args ...interface{})
+}
@@ -0,0 +1,136 @@
+package metrics
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+// syncMultiLogger updates the metrics synchronously so that the values can be checked right away
+type syncMultiLogger struct {
+	metrics map[string]IMetricVec
+}
+
+func newSyncMultiLogger() *syncMultiLogger {
+	return &syncMultiLogger{metrics: map[string]IMetricVec{}}
+}
+
+func (s *syncMultiLogger) Tic() time.Time { return time.Now() }
+func (s *syncMultiLogger) Toc(start time.Time, id string, labels ...string) {
+	s.metrics[id].UpdateTime(int64(time.Since(start)/time.Microsecond), labels...)
+}
+func (s *syncMultiLogger) IncVal(v int64, id string, labels ...string) {
+	s.metrics[id].AddValue(v, labels...)
+}
+func (s *syncMultiLogger) SubVal(v int64, id string, labels ...string) {
+	s.metrics[id].SubValue(v, labels...)
+}
+func (s *syncMultiLogger) SetVal(v int64, id string, labels ...string) {
+	s.metrics[id].SetValue(v, labels...)
+}
+func (s *syncMultiLogger) AddNewMetric(id string, metric IMetricVec) { s.metrics[id] = metric }
+
+// testLogger counts the warnings for unsupported operations
+type testLogger struct {
+	warnings int
+}
+
+func (l *testLogger) LogWarning(str string) { l.warnings++ }
+func (l *testLogger) LogErrorWithoutErrorf(str string, args ...interface{}) {
+	l.warnings++
+}
+
+func TestCounter(t *testing.T) {
+	ml := newSyncMultiLogger()
+	vec := prometheus.NewCounterVec(prometheus.CounterOpts{Name: "metrics_test_counter", Help: "test"}, []string{"Path"})
+	counter := NewCounter(ml, "TEST-COUNTER", vec, &testLogger{})
+	counter.Inc("/a")
+	counter.Add(4, "/a")
+	if got := testutil.ToFloat64(vec.WithLabelValues("/a")); got != 5 {
+		t.Errorf("counter = %v, want 5", got)
+	}
+	if counter.ID() != "TEST-COUNTER" {
+		t.Errorf("ID() = %v, want TEST-COUNTER", counter.ID())
+	}
+}
+
+func TestGauge(t *testing.T) {
+	ml := newSyncMultiLogger()
+	vec := prometheus.NewGaugeVec(prometheus.GaugeOpts{Name: "metrics_test_gauge", Help: "test"}, []string{"Server"})
+	gauge := NewGauge(ml, "TEST-GAUGE", vec, &testLogger{})
+	gauge.Set(10, "s1")
+	gauge.Add(2, "s1")
+	gauge.Sub(5, "s1")
+	if got := testutil.ToFloat64(vec.WithLabelValues("s1")); got != 7 {
+		t.Errorf("gauge = %v, want 7", got)
+	}
+}
+
+func TestHistogram(t *testing.T) {
+	ml := newSyncMultiLogger()
+	vec := prometheus.NewHistogramVec(prometheus.HistogramOpts{Name: "metrics_test_histogram", Help: "test"}, []string{"Path"})
+	histogram := NewHistogram(ml, "TEST-HISTOGRAM", vec, &testLogger{})
+	histogram.Toc(histogram.Tic(), "/a")
+	if got := testutil.CollectAndCount(vec); got != 1 {
+		t.Errorf("histogram series = %v, want 1", got)
+	}
+}
+
+func TestValueHistogram(t *testing.T) {
+	ml := newSyncMultiLogger()
+	vec := prometheus.NewHistogramVec(prometheus.HistogramOpts{Name: "metrics_test_value_histogram", Help: "test", Buckets: []float64{100, 1000}}, []string{"Topic"})
+	histogram := NewValueHistogram(ml, "TEST-VALUE-HISTOGRAM", vec, &testLogger{})
+	histogram.Observe(500, "stocks")
+	histogram.Observe(5000, "stocks")
+	expected := `
+		# HELP metrics_test_value_histogram test
+		# TYPE metrics_test_value_histogram histogram
+		metrics_test_value_histogram_bucket{Topic="stocks",le="100"} 0
+		metrics_test_value_histogram_bucket{Topic="stocks",le="1000"} 1
+		metrics_test_value_histogram_bucket{Topic="stocks",le="+Inf"} 2
+		metrics_test_value_histogram_sum{Topic="stocks"} 5500
+		metrics_test_value_histogram_count{Topic="stocks"} 2
+	`
+	if err := testutil.CollectAndCompare(vec, strings.NewReader(expected)); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestUnsupportedOperationsWarn(t *testing.T) {
+	logger := &testLogger{}
+	counter := NewCounterMetric(prometheus.NewCounterVec(prometheus.CounterOpts{Name: "metrics_test_warn_counter", Help: "test"}, nil), logger)
+	counter.SubValue(1)
+	counter.SetValue(1)
+	counter.UpdateTime(1)
+	histogram := NewHistogramMetric(prometheus.NewHistogramVec(prometheus.HistogramOpts{Name: "metrics_test_warn_histogram", Help: "test"}, nil), logger)
+	histogram.AddValue(1)
+	if logger.warnings != 4 {
+		t.Errorf("warnings = %d, want 4", logger.warnings)
+	}
+}
+
+func TestSnapshotCollectors(t *testing.T) {
+	counter := prometheus.NewCounterVec(prometheus.CounterOpts{Name: "metrics_test_snapshot_counter", Help: "test"}, []string{"Path"})
+	counter.WithLabelValues("/a").Add(2)
+	histogram := prometheus.NewHistogramVec(prometheus.HistogramOpts{Name: "metrics_test_snapshot_histogram", Help: "test", Buckets: []float64{10}}, nil)
+	histogram.WithLabelValues().Observe(5)
+
+	snapshots, err := SnapshotCollectors(map[string]prometheus.Collector{"COUNTER": counter, "HISTOGRAM": histogram})
+	if err != nil {
+		t.Fatalf("SnapshotCollectors() error = %v", err)
+	}
+	if len(snapshots) != 2 || snapshots[0].ID != "COUNTER" || snapshots[1].ID != "HISTOGRAM" {
+		t.Fatalf("SnapshotCollectors() = %+v", snapshots)
+	}
+	series := snapshots[0].Series[0]
+	if *series.Value != 2 || series.Labels["Path"] != "/a" {
+		t.Errorf("counter series = %+v", series)
+	}
+	series = snapshots[1].Series[0]
+	if *series.Count != 1 || series.Buckets["10"] != 1 {
+		t.Errorf("histogram series = %+v", series)
+	}
+}
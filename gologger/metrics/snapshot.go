@@ -0,0 +1,157 @@
+package metrics
+
+import (
+	"encoding/json"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+)
+
+// ICollector is implemented by the metrics that expose their prometheus collector
+type ICollector interface {
+	Collector() prometheus.Collector
+}
+
+// MetricSnapshot is the current state of a metric family
+type MetricSnapshot struct {
+	// ID is the identifier of the metric in the multi logger. It is empty for metrics that were not added to a multi logger
+	ID     string           `json:"id,omitempty"`
+	Name   string           `json:"name"`
+	Help   string           `json:"help"`
+	Type   string           `json:"type"`
+	Series []SeriesSnapshot `json:"series"`
+}
+
+// SeriesSnapshot is the current value of a metric for a set of labels.
+// Counters and gauges have a value, histograms and summaries have a count, a sum and buckets or quantiles
+type SeriesSnapshot struct {
+	Labels    map[string]string  `json:"labels,omitempty"`
+	Value     *float64           `json:"value,omitempty"`
+	Count     *uint64            `json:"count,omitempty"`
+	Sum       *float64           `json:"sum,omitempty"`
+	Buckets   map[string]uint64  `json:"buckets,omitempty"`
+	Quantiles map[string]float64 `json:"quantiles,omitempty"`
+}
+
+// SnapshotGatherer returns the current state of all the metrics of the gatherer.
+// Use prometheus.DefaultGatherer to get all the registered metrics
+func SnapshotGatherer(gatherer prometheus.Gatherer) ([]MetricSnapshot, error) {
+	families, err := gatherer.Gather()
+	if err != nil {
+		return nil, err
+	}
+	snapshots := make([]MetricSnapshot, 0, len(families))
+	for _, family := range families {
+		snapshots = append(snapshots, newMetricSnapshot("", family))
+	}
+	return snapshots, nil
+}
+
+// SnapshotCollectors returns the current state of the collectors. The keys of the map are used as the IDs of the snapshots
+func SnapshotCollectors(collectors map[string]prometheus.Collector) ([]MetricSnapshot, error) {
+	ids := make([]string, 0, len(collectors))
+	for id := range collectors {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	snapshots := make([]MetricSnapshot, 0, len(collectors))
+	for _, id := range ids {
+		// The collectors are gathered with a new registry so that only these metrics are collected
+		registry := prometheus.NewRegistry()
+		if err := registry.Register(collectors[id]); err != nil {
+			return nil, err
+		}
+		families, err := registry.Gather()
+		if err != nil {
+			return nil, err
+		}
+		for _, family := range families {
+			snapshots = append(snapshots, newMetricSnapshot(id, family))
+		}
+	}
+	return snapshots, nil
+}
+
+// SnapshotHandler returns a handler which writes the snapshot as JSON. It can be added to an admin endpoint
+//
+//	http.Handle("/debug/metrics", metrics.SnapshotHandler(func() ([]metrics.MetricSnapshot, error) {
+//		return metrics.SnapshotGatherer(prometheus.DefaultGatherer)
+//	}))
+func SnapshotHandler(snapshot func() ([]MetricSnapshot, error)) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		snapshots, err := snapshot()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(snapshots)
+	})
+}
+
+func newMetricSnapshot(id string, family *dto.MetricFamily) MetricSnapshot {
+	snapshot := MetricSnapshot{
+		ID:     id,
+		Name:   family.GetName(),
+		Help:   family.GetHelp(),
+		Type:   family.GetType().String(),
+		Series: make([]SeriesSnapshot, 0, len(family.GetMetric())),
+	}
+	for _, metric := range family.GetMetric() {
+		series := SeriesSnapshot{}
+		if len(metric.GetLabel()) > 0 {
+			series.Labels = make(map[string]string, len(metric.GetLabel()))
+			for _, label := range metric.GetLabel() {
+				series.Labels[label.GetName()] = label.GetValue()
+			}
+		}
+		switch {
+		case metric.Counter != nil:
+			series.Value = float64Ptr(metric.GetCounter().GetValue())
+		case metric.Gauge != nil:
+			series.Value = float64Ptr(metric.GetGauge().GetValue())
+		case metric.Untyped != nil:
+			series.Value = float64Ptr(metric.GetUntyped().GetValue())
+		case metric.Histogram != nil:
+			histogram := metric.GetHistogram()
+			count := histogram.GetSampleCount()
+			series.Count = &count
+			series.Sum = float64Ptr(histogram.GetSampleSum())
+			series.Buckets = make(map[string]uint64, len(histogram.GetBucket()))
+			for _, bucket := range histogram.GetBucket() {
+				series.Buckets[formatBound(bucket.GetUpperBound())] = bucket.GetCumulativeCount()
+			}
+		case metric.Summary != nil:
+			summary := metric.GetSummary()
+			count := summary.GetSampleCount()
+			series.Count = &count
+			series.Sum = float64Ptr(summary.GetSampleSum())
+			series.Quantiles = make(map[string]float64, len(summary.GetQuantile()))
+			for _, quantile := range summary.GetQuantile() {
+				series.Quantiles[formatBound(quantile.GetQuantile())] = quantile.GetValue()
+			}
+		}
+		snapshot.Series = append(snapshot.Series, series)
+	}
+	return snapshot
+}
+
+// float64Ptr returns a pointer to the value. NaN and infinities are replaced by 0 as they cannot be encoded in JSON
+func float64Ptr(value float64) *float64 {
+	if math.IsNaN(value) || math.IsInf(value, 0) {
+		value = 0
+	}
+	return &value
+}
+
+func formatBound(bound float64) string {
+	if math.IsInf(bound, 1) {
+		return "+Inf"
+	}
+	return strconv.FormatFloat(bound, 'g', -1, 64)
+}
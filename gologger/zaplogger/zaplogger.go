@@ -0,0 +1,188 @@
+// Package zaplogger implements gologger.ILogger with a zap logger, for the services which log with zap and
+// want to use the golibraries packages without a second logger:
+//
+//	zapLogger, _ := zap.NewProduction()
+//	logger := zaplogger.New(zapLogger.Sugar())
+//	consumer := kafka.NewKafkaConsumer(brokers, group, topics, kafka.ConsumerLogger(logger))
+//
+// The package depends on the methods of *zap.SugaredLogger only, not on zap, so that zap is not a dependency of
+// golibraries. For the same reason gologger has no factory which selects zap as the backend: the service builds
+// the zap logger, with its encoding and outputs, and passes it to New with the gologger options it uses:
+//
+//	logger := zaplogger.New(zapLogger.Sugar(), gologger.SetLogLevel("INFO"), gologger.GraylogFacility("stock-api"))
+//
+// The level, the facility and the k8s namespace of the options are applied; the options of graylog and of the
+// output have no effect
+package zaplogger
+
+import (
+	"fmt"
+	"sync/atomic"
+
+	"github.com/carwale/golibraries/gologger"
+)
+
+// ISugaredLogger is the part of *zap.SugaredLogger used by the Logger
+type ISugaredLogger interface {
+	Debugw(msg string, keysAndValues ...interface{})
+	Infow(msg string, keysAndValues ...interface{})
+	Warnw(msg string, keysAndValues ...interface{})
+	Errorw(msg string, keysAndValues ...interface{})
+}
+
+// Logger is a gologger.ILogger writing to a zap logger. The pairs are logged as zap fields and the errors in
+// the error field. The messages are filtered by the level of the zap logger and by the level set with
+// SetLogLevel, which is DEBUG by default
+type Logger struct {
+	sugar ISugaredLogger
+	level uint32
+	// base are the fields logged with every message
+	base []interface{}
+}
+
+var _ gologger.ILogger = (*Logger)(nil)
+
+// New returns a logger writing to the sugared zap logger. Without options only the level of the zap logger
+// applies. With options the level is the one of gologger.SetLogLevel, ERROR by default as for gologger.NewLogger,
+// and the facility and the k8s namespace are logged with every message in the fields of gologger.DefaultFieldMapping
+func New(sugar ISugaredLogger, options ...gologger.Option) *Logger {
+	if sugar == nil {
+		panic("zaplogger: the zap logger is nil")
+	}
+	if len(options) == 0 {
+		return &Logger{sugar: sugar, level: uint32(gologger.DEBUG)}
+	}
+	settings := gologger.SettingsOf(options...)
+	return &Logger{
+		sugar: sugar,
+		level: uint32(settings.Level),
+		base: []interface{}{
+			gologger.DefaultFieldMapping.Facility, settings.Facility,
+			gologger.DefaultFieldMapping.Namespace, settings.K8sNamespace,
+		},
+	}
+}
+
+// GetLogLevel returns the level set with SetLogLevel
+func (l *Logger) GetLogLevel() gologger.LogLevels {
+	return gologger.LogLevels(atomic.LoadUint32(&l.level))
+}
+
+// SetLogLevel changes the level of the logger while it is in use. Messages of a level the zap logger does
+// not log are dropped at any level
+func (l *Logger) SetLogLevel(level string) error {
+	logLevel, err := gologger.ParseLogLevel(level)
+	if err != nil {
+		return err
+	}
+	atomic.StoreUint32(&l.level, uint32(logLevel))
+	return nil
+}
+
+// enabled returns true if the messages of the level are logged
+func (l *Logger) enabled(level gologger.LogLevels) bool {
+	return l.GetLogLevel() >= level
+}
+
+// LogError logs the error
+func (l *Logger) LogError(str string, err error) {
+	l.sugar.Errorw(str, l.errorFields(err, nil)...)
+}
+
+// LogErrorWithoutError logs an error message
+func (l *Logger) LogErrorWithoutError(str string) {
+	l.sugar.Errorw(str, l.base...)
+}
+
+// LogErrorWithoutErrorf logs a formatted error message
+func (l *Logger) LogErrorWithoutErrorf(str string, args ...interface{}) {
+	l.sugar.Errorw(fmt.Sprintf(str, args...), l.base...)
+}
+
+// LogErrorMessage logs the error with the pairs
+func (l *Logger) LogErrorMessage(str string, err error, pairs ...gologger.Pair) {
+	l.sugar.Errorw(str, l.errorFields(err, pairs)...)
+}
+
+// LogWarning logs a warning
+func (l *Logger) LogWarning(str string) {
+	if !l.enabled(gologger.WARN) {
+		return
+	}
+	l.sugar.Warnw(str, l.base...)
+}
+
+// LogWarningf logs a formatted warning
+func (l *Logger) LogWarningf(str string, args ...interface{}) {
+	if !l.enabled(gologger.WARN) {
+		return
+	}
+	l.sugar.Warnw(fmt.Sprintf(str, args...), l.base...)
+}
+
+// LogWarningMessage logs a warning with the pairs
+func (l *Logger) LogWarningMessage(str string, pairs ...gologger.Pair) {
+	if !l.enabled(gologger.WARN) {
+		return
+	}
+	l.sugar.Warnw(str, l.fields(pairs)...)
+}
+
+// LogInfo logs an info message
+func (l *Logger) LogInfo(str string) {
+	if !l.enabled(gologger.INFO) {
+		return
+	}
+	l.sugar.Infow(str, l.base...)
+}
+
+// LogInfof logs a formatted info message
+func (l *Logger) LogInfof(str string, args ...interface{}) {
+	if !l.enabled(gologger.INFO) {
+		return
+	}
+	l.sugar.Infow(fmt.Sprintf(str, args...), l.base...)
+}
+
+// LogInfoMessage logs an info message with the pairs
+func (l *Logger) LogInfoMessage(str string, pairs ...gologger.Pair) {
+	if !l.enabled(gologger.INFO) {
+		return
+	}
+	l.sugar.Infow(str, l.fields(pairs)...)
+}
+
+// LogDebug logs a debug message
+func (l *Logger) LogDebug(str string) {
+	if !l.enabled(gologger.DEBUG) {
+		return
+	}
+	l.sugar.Debugw(str, l.base...)
+}
+
+// LogDebugf logs a formatted debug message
+func (l *Logger) LogDebugf(str string, args ...interface{}) {
+	if !l.enabled(gologger.DEBUG) {
+		return
+	}
+	l.sugar.Debugw(fmt.Sprintf(str, args...), l.base...)
+}
+
+// fields returns the base fields and the pairs as zap key value pairs
+func (l *Logger) fields(pairs []gologger.Pair) []interface{} {
+	keysAndValues := make([]interface{}, 0, len(l.base)+2*len(pairs)+2)
+	keysAndValues = append(keysAndValues, l.base...)
+	for _, pair := range pairs {
+		keysAndValues = append(keysAndValues, pair.Key, pair.Value)
+	}
+	return keysAndValues
+}
+
+// errorFields returns the base fields, the pairs and the error as zap key value pairs
+func (l *Logger) errorFields(err error, pairs []gologger.Pair) []interface{} {
+	keysAndValues := l.fields(pairs)
+	if err != nil {
+		keysAndValues = append(keysAndValues, "error", err.Error())
+	}
+	return keysAndValues
+}
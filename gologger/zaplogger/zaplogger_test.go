@@ -0,0 +1,72 @@
+package zaplogger
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/carwale/golibraries/gologger"
+)
+
+// recorder records the calls like a zap observer
+type recorder struct {
+	entries []string
+}
+
+func (r *recorder) record(level string, msg string, keysAndValues []interface{}) {
+	r.entries = append(r.entries, fmt.Sprint(level, " ", msg, " ", keysAndValues))
+}
+
+func (r *recorder) Debugw(msg string, kv ...interface{}) { r.record("debug", msg, kv) }
+func (r *recorder) Infow(msg string, kv ...interface{})  { r.record("info", msg, kv) }
+func (r *recorder) Warnw(msg string, kv ...interface{})  { r.record("warn", msg, kv) }
+func (r *recorder) Errorw(msg string, kv ...interface{}) { r.record("error", msg, kv) }
+
+func TestLogger(t *testing.T) {
+	r := &recorder{}
+	logger := New(r)
+	logger.LogErrorMessage("publish failed", errors.New("timeout"), gologger.Pair{Key: "topic", Value: "stocks"})
+	logger.LogInfof("consumed %d messages", 3)
+	logger.LogDebug("polling")
+
+	want := []string{
+		"error publish failed [topic stocks error timeout]",
+		"info consumed 3 messages []",
+		"debug polling []",
+	}
+	if fmt.Sprint(r.entries) != fmt.Sprint(want) {
+		t.Errorf("entries = %q, want %q", r.entries, want)
+	}
+}
+
+func TestLoggerSetLogLevel(t *testing.T) {
+	r := &recorder{}
+	logger := New(r)
+	if err := gologger.ChangeLogLevel(logger, "warn"); err != nil {
+		t.Fatal(err)
+	}
+	logger.LogInfo("skipped")
+	logger.LogWarning("slow")
+	logger.LogErrorWithoutError("failed")
+	if err := logger.SetLogLevel("VERBOSE"); err == nil || logger.GetLogLevel() != gologger.WARN {
+		t.Errorf("SetLogLevel() of an unknown level = %v and level = %v", err, logger.GetLogLevel())
+	}
+
+	want := []string{"warn slow []", "error failed []"}
+	if fmt.Sprint(r.entries) != fmt.Sprint(want) {
+		t.Errorf("entries = %q, want %q", r.entries, want)
+	}
+}
+
+func TestLoggerAppliesGologgerOptions(t *testing.T) {
+	t.Setenv("K8S_NAMESPACE", "")
+	r := &recorder{}
+	logger := New(r, gologger.SetLogLevel("INFO"), gologger.GraylogFacility("stock-api"), gologger.SetK8sNamespace("prod"))
+	logger.LogDebug("skipped")
+	logger.LogInfoMessage("served", gologger.Pair{Key: "status", Value: "200"})
+
+	want := []string{"info served [log_facility stock-api K8sNamespace prod status 200]"}
+	if fmt.Sprint(r.entries) != fmt.Sprint(want) {
+		t.Errorf("entries = %q, want %q", r.entries, want)
+	}
+}
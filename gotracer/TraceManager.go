@@ -3,7 +3,9 @@ package gotracer
 import (
 	"context"
 	"errors"
+	"time"
 
+	"github.com/carwale/golibraries/buildinfo"
 	"github.com/carwale/golibraries/gologger"
 	"go.opentelemetry.io/contrib/instrumentation/google.golang.org/grpc/otelgrpc"
 	"go.opentelemetry.io/otel/exporters/otlp/otlptrace"
@@ -21,18 +23,26 @@ type CustomTracer struct {
 	collectorHost  string
 	traceContext   context.Context
 	traceProvider  *trace.TracerProvider
-	logger         *gologger.CustomLogger
+	logger         gologger.ILogger
 	sampler        trace.Sampler
 	propagator     propagation.TextMapPropagator
 	exporter       *otlptrace.Exporter
+	spanExporter   trace.SpanExporter
 	resource       *resource.Resource
+	// bounded span queue, used instead of the batcher when queueSize is set
+	queueSize         int
+	queueBatchSize    int
+	queueBatchTimeout time.Duration
+	latencyLogger     gologger.IMultiLogger
+	// toggle switches tracing and the sampler at runtime
+	toggle *toggleSampler
 }
 
 // Option is a function type used to set various options for the CustomTracer
 type Option func(t *CustomTracer)
 
 // SetLogger sets the logger for the CustomTracer
-func SetLogger(logger *gologger.CustomLogger) Option {
+func SetLogger(logger gologger.ILogger) Option {
 	return func(t *CustomTracer) { t.logger = logger }
 }
 
@@ -166,7 +176,7 @@ func (c *CustomTracer) InitResource() (*resource.Resource, error) {
 		semconv.ServiceName(c.serviceName),
 		semconv.OTelScopeName(otelgrpc.ScopeName),
 		semconv.OTelScopeVersion(otelgrpc.Version()),
-	))
+	), resource.WithAttributes(buildinfo.Get().Attributes()...))
 	if err != nil {
 		c.logger.LogError("could not set service name for tracing", err)
 		return nil, err
@@ -186,11 +196,22 @@ func (c *CustomTracer) InitTracerProvider() (*trace.TracerProvider, error) {
 	if err != nil {
 		return nil, err
 	}
-	_, err = c.InitExporter()
-	if err != nil {
-		return nil, err
+	var exporter trace.SpanExporter = c.spanExporter
+	if exporter == nil {
+		_, err = c.InitExporter()
+		if err != nil {
+			return nil, err
+		}
+		exporter = c.exporter
+	}
+	processor := trace.WithBatcher(exporter)
+	if c.queueSize > 0 {
+		if c.latencyLogger == nil {
+			c.latencyLogger = gologger.NewRateLatencyLogger(gologger.SetLogger(c.logger))
+		}
+		processor = trace.WithSpanProcessor(newQueueSpanProcessor(exporter, c.queueSize, c.queueBatchSize, c.queueBatchTimeout, c.logger, c.latencyLogger))
 	}
-	provider := trace.NewTracerProvider(trace.WithResource(c.resource), trace.WithBatcher(c.exporter), trace.WithSampler(c.sampler))
+	provider := trace.NewTracerProvider(trace.WithResource(c.resource), processor, trace.WithSampler(c.toggle))
 	c.traceProvider = provider
 	return provider, nil
 }
@@ -207,6 +228,7 @@ func NewCustomTracer(traceOptions ...Option) *CustomTracer {
 	for _, option := range traceOptions {
 		option(customTracer)
 	}
+	customTracer.toggle = newToggleSampler(customTracer.sampler)
 	if !customTracer.isInKubernetes {
 		customTracer.logger.LogError("cannot enable tracing, as service is not inside kubernetes", errors.New("cannot enable tracing service not inside kubernetes"))
 		return nil
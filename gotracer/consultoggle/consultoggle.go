@@ -0,0 +1,68 @@
+// Package consultoggle switches the tracing of a gotracer.CustomTracer on and off and changes its sampling
+// ratio with a consul key, so that gotracer and the packages tracing with it do not depend on consul.
+//
+//	toggle := consultoggle.New(tracer, logger)
+//	go toggle.Watch(ctx, consulAgent, "stocks/tracing")
+package consultoggle
+
+import (
+	"context"
+	"encoding/json"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"github.com/carwale/golibraries/consulagent"
+	"github.com/carwale/golibraries/gologger"
+	"github.com/carwale/golibraries/gotracer"
+)
+
+// Config is the value of the consul key watched by Watch
+// e.g. {"enabled": true, "samplingRatio": 0.05}
+// A missing samplingRatio keeps the current sampler
+type Config struct {
+	Enabled       bool     `json:"enabled"`
+	SamplingRatio *float64 `json:"samplingRatio,omitempty"`
+}
+
+// Toggle switches the tracing of a tracer with the configs
+type Toggle struct {
+	tracer  *gotracer.CustomTracer
+	logger  gologger.ILogger
+	enabled atomic.Bool
+}
+
+// New returns a toggle of the tracer. Tracing stays as it is until a config is applied
+func New(tracer *gotracer.CustomTracer, logger gologger.ILogger) *Toggle {
+	t := &Toggle{tracer: tracer, logger: logger}
+	t.enabled.Store(tracer.IsEnabled())
+	tracer.SetEnabledFunc(t.enabled.Load)
+	return t
+}
+
+// Apply applies the config to the tracer
+func (t *Toggle) Apply(config Config) {
+	if config.SamplingRatio != nil {
+		t.tracer.SetSamplingRatio(*config.SamplingRatio)
+	}
+	if t.enabled.Swap(config.Enabled) != config.Enabled {
+		t.logger.LogInfo("tracing enabled: " + strconv.FormatBool(config.Enabled))
+	}
+}
+
+// Watch watches the consul key for a Config and applies it every time it changes.
+// Tracing is left as it is while the key is missing or invalid. It blocks until ctx is done
+func (t *Toggle) Watch(ctx context.Context, ca *consulagent.ConsulAgent, key string) {
+	ca.WatchPrefix(ctx, key, 10*time.Second, func(values map[string][]byte) {
+		value, ok := values[key]
+		if !ok {
+			return
+		}
+		var config Config
+		if err := json.Unmarshal(value, &config); err != nil {
+			t.logger.LogError("invalid tracing config in consul key "+key, err)
+			return
+		}
+		t.Apply(config)
+	})
+}
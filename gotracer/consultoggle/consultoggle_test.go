@@ -0,0 +1,26 @@
+package consultoggle
+
+import (
+	"testing"
+
+	"github.com/carwale/golibraries/gologger"
+	"github.com/carwale/golibraries/gotracer"
+)
+
+func TestApply(t *testing.T) {
+	tracer := gotracer.NewCustomTracer(gotracer.SetLogger(gologger.NewLogger()), gotracer.SetIsInKubernetes(true))
+	toggle := New(tracer, gologger.NewLogger())
+	if !tracer.IsEnabled() {
+		t.Fatal("IsEnabled() = false before any config")
+	}
+
+	toggle.Apply(Config{Enabled: false})
+	if tracer.IsEnabled() {
+		t.Error("IsEnabled() = true after a disabling config")
+	}
+	ratio := 0.5
+	toggle.Apply(Config{Enabled: true, SamplingRatio: &ratio})
+	if !tracer.IsEnabled() {
+		t.Error("IsEnabled() = false after an enabling config")
+	}
+}
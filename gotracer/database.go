@@ -0,0 +1,27 @@
+package gotracer
+
+import (
+	"go.opentelemetry.io/otel/attribute"
+	semconv "go.opentelemetry.io/otel/semconv/v1.24.0"
+)
+
+// DBAttributes returns the database semantic convention attributes of a query. system is the db.system value,
+// e.g. mysql or postgresql, and defaults to other_sql. statement should already be sanitized
+func DBAttributes(system string, dbName string, operation string, statement string) []attribute.KeyValue {
+	attrs := make([]attribute.KeyValue, 0, 4)
+	if system == "" {
+		attrs = append(attrs, semconv.DBSystemOtherSQL)
+	} else {
+		attrs = append(attrs, semconv.DBSystemKey.String(system))
+	}
+	if dbName != "" {
+		attrs = append(attrs, semconv.DBName(dbName))
+	}
+	if operation != "" {
+		attrs = append(attrs, semconv.DBOperation(operation))
+	}
+	if statement != "" {
+		attrs = append(attrs, semconv.DBStatement(statement))
+	}
+	return attrs
+}
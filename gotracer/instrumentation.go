@@ -0,0 +1,158 @@
+package gotracer
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/carwale/golibraries/httputil"
+	"go.opentelemetry.io/contrib/instrumentation/google.golang.org/grpc/otelgrpc"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	semconv "go.opentelemetry.io/otel/semconv/v1.24.0"
+	oteltrace "go.opentelemetry.io/otel/trace"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/stats"
+)
+
+const httpTracerName = "github.com/carwale/golibraries/gotracer/http"
+
+// provider returns the tracer provider of the CustomTracer, or the global one if it has not been initialised
+func (c *CustomTracer) provider() oteltrace.TracerProvider {
+	if c.traceProvider != nil {
+		return c.traceProvider
+	}
+	return otel.GetTracerProvider()
+}
+
+// otelgrpcOptions returns the otelgrpc options using the provider and propagator of the CustomTracer,
+// followed by the passed options
+func (c *CustomTracer) otelgrpcOptions(opts []otelgrpc.Option) []otelgrpc.Option {
+	return append([]otelgrpc.Option{
+		otelgrpc.WithTracerProvider(c.provider()),
+		otelgrpc.WithPropagators(c.propagator),
+	}, opts...)
+}
+
+// GRPCServerHandler returns an otelgrpc server stats handler using the provider and propagator of the CustomTracer.
+// Call it after InitTracerProvider
+func (c *CustomTracer) GRPCServerHandler(opts ...otelgrpc.Option) stats.Handler {
+	return otelgrpc.NewServerHandler(c.otelgrpcOptions(opts)...)
+}
+
+// GRPCClientHandler returns an otelgrpc client stats handler using the provider and propagator of the CustomTracer.
+// Call it after InitTracerProvider
+func (c *CustomTracer) GRPCClientHandler(opts ...otelgrpc.Option) stats.Handler {
+	return otelgrpc.NewClientHandler(c.otelgrpcOptions(opts)...)
+}
+
+// GRPCServerOption returns the server option to trace the rpcs of a grpc server
+func (c *CustomTracer) GRPCServerOption(opts ...otelgrpc.Option) grpc.ServerOption {
+	return grpc.StatsHandler(c.GRPCServerHandler(opts...))
+}
+
+// GRPCDialOption returns the dial option to trace the rpcs of a grpc client
+func (c *CustomTracer) GRPCDialOption(opts ...otelgrpc.Option) grpc.DialOption {
+	return grpc.WithStatsHandler(c.GRPCClientHandler(opts...))
+}
+
+// HTTPMiddleware returns a middleware which extracts the trace context from the request headers using the
+// propagator of the CustomTracer and starts a server span for every request.
+// The route is used as the span name, the method and path of the request are used if it is empty
+func (c *CustomTracer) HTTPMiddleware(route string) func(http.Handler) http.Handler {
+	tracer := c.provider().Tracer(httpTracerName)
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ctx := c.propagator.Extract(r.Context(), propagation.HeaderCarrier(r.Header))
+			name := route
+			if name == "" {
+				name = r.Method + " " + r.URL.Path
+			}
+			opts := []oteltrace.SpanStartOption{
+				oteltrace.WithSpanKind(oteltrace.SpanKindServer),
+				oteltrace.WithAttributes(
+					semconv.HTTPRequestMethodKey.String(r.Method),
+					semconv.URLPath(r.URL.Path),
+					semconv.ServerAddress(r.Host),
+					semconv.ClientAddress(httputil.ClientIP(r)),
+					semconv.UserAgentOriginal(r.UserAgent()),
+				),
+			}
+			if route != "" {
+				opts = append(opts, oteltrace.WithAttributes(semconv.HTTPRoute(route)))
+			}
+			ctx, span := tracer.Start(ctx, name, opts...)
+			defer span.End()
+			sw := &statusWriter{ResponseWriter: w, status: http.StatusOK}
+			next.ServeHTTP(sw, r.WithContext(ctx))
+			span.SetAttributes(semconv.HTTPResponseStatusCode(sw.status))
+			if sw.status >= http.StatusInternalServerError {
+				span.SetStatus(codes.Error, strconv.Itoa(sw.status))
+			}
+		})
+	}
+}
+
+// HTTPTransport returns a round tripper which starts a client span for every request and injects the trace
+// context into the request headers using the propagator of the CustomTracer. http.DefaultTransport is used if base is nil
+func (c *CustomTracer) HTTPTransport(base http.RoundTripper) http.RoundTripper {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	return &tracingTransport{base: base, tracer: c.provider().Tracer(httpTracerName), propagator: c.propagator}
+}
+
+type tracingTransport struct {
+	base       http.RoundTripper
+	tracer     oteltrace.Tracer
+	propagator propagation.TextMapPropagator
+}
+
+// RoundTrip traces the request
+func (t *tracingTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	fullURL := r.URL.Scheme + "://" + r.URL.Host + r.URL.EscapedPath()
+	if r.URL.RawQuery != "" {
+		fullURL += "?" + httputil.RedactQuery(r.URL.RawQuery)
+	}
+	ctx, span := t.tracer.Start(r.Context(), r.Method,
+		oteltrace.WithSpanKind(oteltrace.SpanKindClient),
+		oteltrace.WithAttributes(
+			semconv.HTTPRequestMethodKey.String(r.Method),
+			semconv.URLFull(fullURL),
+			semconv.ServerAddress(r.URL.Hostname()),
+		))
+	defer span.End()
+	// The request must not be modified, so the headers are injected into a clone
+	r = r.Clone(ctx)
+	t.propagator.Inject(ctx, propagation.HeaderCarrier(r.Header))
+	resp, err := t.base.RoundTrip(r)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return nil, err
+	}
+	span.SetAttributes(semconv.HTTPResponseStatusCode(resp.StatusCode))
+	if resp.StatusCode >= http.StatusBadRequest {
+		span.SetStatus(codes.Error, strconv.Itoa(resp.StatusCode))
+	}
+	return resp, nil
+}
+
+// statusWriter records the status code written by the handler
+type statusWriter struct {
+	http.ResponseWriter
+	status int
+}
+
+// WriteHeader records the status code
+func (w *statusWriter) WriteHeader(status int) {
+	w.status = status
+	w.ResponseWriter.WriteHeader(status)
+}
+
+// Flush implements http.Flusher if the underlying writer does
+func (w *statusWriter) Flush() {
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
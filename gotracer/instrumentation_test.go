@@ -0,0 +1,52 @@
+package gotracer
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/carwale/golibraries/gologger"
+	"go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	oteltrace "go.opentelemetry.io/otel/trace"
+)
+
+func TestHTTPMiddlewareAndTransportPropagate(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	c := NewCustomTracer(SetLogger(gologger.NewLogger()), SetIsInKubernetes(true))
+	c.traceProvider = trace.NewTracerProvider(trace.WithSpanProcessor(recorder), trace.WithSampler(trace.AlwaysSample()))
+
+	var serverTraceID oteltrace.TraceID
+	server := httptest.NewServer(c.HTTPMiddleware("/stocks")(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		serverTraceID = oteltrace.SpanContextFromContext(r.Context()).TraceID()
+		w.WriteHeader(http.StatusServiceUnavailable)
+	})))
+	defer server.Close()
+
+	client := &http.Client{Transport: c.HTTPTransport(nil)}
+	resp, err := client.Get(server.URL + "/stocks?token=secret")
+	if err != nil {
+		t.Fatalf("Get() error = %v", err)
+	}
+	resp.Body.Close()
+
+	spans := recorder.Ended()
+	if len(spans) != 2 {
+		t.Fatalf("recorded %d spans, want 2", len(spans))
+	}
+	serverSpan, clientSpan := spans[0], spans[1]
+	if serverSpan.SpanKind() != oteltrace.SpanKindServer || clientSpan.SpanKind() != oteltrace.SpanKindClient {
+		t.Fatalf("span kinds = %v %v", serverSpan.SpanKind(), clientSpan.SpanKind())
+	}
+	if serverSpan.Parent().SpanID() != clientSpan.SpanContext().SpanID() || serverTraceID != clientSpan.SpanContext().TraceID() {
+		t.Error("server span is not a child of the client span")
+	}
+	if serverSpan.Name() != "/stocks" {
+		t.Errorf("server span name = %q, want /stocks", serverSpan.Name())
+	}
+	for _, attr := range clientSpan.Attributes() {
+		if attr.Key == "url.full" && attr.Value.AsString() != server.URL+"/stocks?token=REDACTED" {
+			t.Errorf("url.full = %q", attr.Value.AsString())
+		}
+	}
+}
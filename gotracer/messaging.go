@@ -0,0 +1,88 @@
+package gotracer
+
+import (
+	"context"
+
+	"go.opentelemetry.io/otel/attribute"
+	semconv "go.opentelemetry.io/otel/semconv/v1.24.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// RabbitMQDeliveryTagKey is the attribute key of the delivery tag of a consumed rabbitmq message.
+// It is not part of semconv v1.24.0, so the name used by later versions of the conventions is used
+const RabbitMQDeliveryTagKey = attribute.Key("messaging.rabbitmq.message.delivery_tag")
+
+// KafkaPublishAttributes returns the messaging semantic convention attributes of a message published to kafka
+func KafkaPublishAttributes(topic string, partition int32, key []byte, bodySize int) []attribute.KeyValue {
+	attrs := []attribute.KeyValue{
+		semconv.MessagingSystemKafka,
+		semconv.MessagingOperationPublish,
+		semconv.MessagingDestinationName(topic),
+		semconv.MessagingMessageBodySize(bodySize),
+	}
+	if partition >= 0 {
+		attrs = append(attrs, semconv.MessagingKafkaDestinationPartition(int(partition)))
+	}
+	if len(key) > 0 {
+		attrs = append(attrs, semconv.MessagingKafkaMessageKey(string(key)))
+	}
+	return attrs
+}
+
+// KafkaConsumeAttributes returns the messaging semantic convention attributes of a message consumed from kafka
+func KafkaConsumeAttributes(topic string, consumerGroup string, partition int32, offset int64, key []byte, bodySize int) []attribute.KeyValue {
+	attrs := []attribute.KeyValue{
+		semconv.MessagingSystemKafka,
+		semconv.MessagingOperationDeliver,
+		semconv.MessagingDestinationName(topic),
+		semconv.MessagingKafkaDestinationPartition(int(partition)),
+		semconv.MessagingKafkaMessageOffset(int(offset)),
+		semconv.MessagingMessageBodySize(bodySize),
+	}
+	if consumerGroup != "" {
+		attrs = append(attrs, semconv.MessagingKafkaConsumerGroup(consumerGroup))
+	}
+	if len(key) > 0 {
+		attrs = append(attrs, semconv.MessagingKafkaMessageKey(string(key)))
+	}
+	if bodySize == 0 {
+		attrs = append(attrs, semconv.MessagingKafkaMessageTombstone(true))
+	}
+	return attrs
+}
+
+// RabbitMQPublishAttributes returns the messaging semantic convention attributes of a message published to rabbitmq
+func RabbitMQPublishAttributes(exchangeName string, routingKey string, bodySize int) []attribute.KeyValue {
+	return []attribute.KeyValue{
+		semconv.MessagingSystemRabbitmq,
+		semconv.MessagingOperationPublish,
+		semconv.MessagingDestinationName(exchangeName),
+		semconv.MessagingRabbitmqDestinationRoutingKey(routingKey),
+		semconv.MessagingMessageBodySize(bodySize),
+	}
+}
+
+// RabbitMQConsumeAttributes returns the messaging semantic convention attributes of a message consumed from rabbitmq
+func RabbitMQConsumeAttributes(queueName string, routingKey string, deliveryTag uint64, messageID string, bodySize int) []attribute.KeyValue {
+	attrs := []attribute.KeyValue{
+		semconv.MessagingSystemRabbitmq,
+		semconv.MessagingOperationDeliver,
+		semconv.MessagingDestinationName(queueName),
+		semconv.MessagingRabbitmqDestinationRoutingKey(routingKey),
+		RabbitMQDeliveryTagKey.Int64(int64(deliveryTag)),
+		semconv.MessagingMessageBodySize(bodySize),
+	}
+	if messageID != "" {
+		attrs = append(attrs, semconv.MessagingMessageID(messageID))
+	}
+	return attrs
+}
+
+// SetSpanAttributes sets the attributes on the span in the context, if any.
+// It lets processors add the messaging attributes to spans they start themselves
+func SetSpanAttributes(ctx context.Context, attrs ...attribute.KeyValue) {
+	span := trace.SpanFromContext(ctx)
+	if span.IsRecording() {
+		span.SetAttributes(attrs...)
+	}
+}
@@ -0,0 +1,35 @@
+package gotracer
+
+import (
+	"testing"
+
+	"go.opentelemetry.io/otel/attribute"
+)
+
+func TestKafkaConsumeAttributes(t *testing.T) {
+	attrs := attribute.NewSet(KafkaConsumeAttributes("stocks", "indexer", 3, 42, []byte("stock-1"), 0)...)
+	expected := map[attribute.Key]attribute.Value{
+		"messaging.system":                      attribute.StringValue("kafka"),
+		"messaging.destination.name":            attribute.StringValue("stocks"),
+		"messaging.kafka.destination.partition": attribute.IntValue(3),
+		"messaging.kafka.message.offset":        attribute.IntValue(42),
+		"messaging.kafka.consumer.group":        attribute.StringValue("indexer"),
+		"messaging.kafka.message.key":           attribute.StringValue("stock-1"),
+		"messaging.kafka.message.tombstone":     attribute.BoolValue(true),
+	}
+	for key, want := range expected {
+		if got, ok := attrs.Value(key); !ok || got != want {
+			t.Errorf("%s = %v, want %v", key, got.Emit(), want.Emit())
+		}
+	}
+}
+
+func TestRabbitMQConsumeAttributes(t *testing.T) {
+	attrs := attribute.NewSet(RabbitMQConsumeAttributes("stock-queue", "stock.updated", 7, "", 10)...)
+	if got, _ := attrs.Value(RabbitMQDeliveryTagKey); got.AsInt64() != 7 {
+		t.Errorf("delivery tag = %v, want 7", got.Emit())
+	}
+	if _, ok := attrs.Value("messaging.message.id"); ok {
+		t.Error("message id set for message without id")
+	}
+}
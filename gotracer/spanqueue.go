@@ -0,0 +1,198 @@
+package gotracer
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/carwale/golibraries/gologger"
+	"github.com/carwale/golibraries/goutilities"
+	"github.com/prometheus/client_golang/prometheus"
+	"go.opentelemetry.io/otel/sdk/trace"
+)
+
+// SetSpanQueue replaces the default batcher with a bounded queue of size spans. Ending a span never blocks:
+// when the queue is full the span is dropped and counted in the tracer_spans_dropped_total metric.
+// Spans are exported in batches of batchSize or after batchTimeout
+func SetSpanQueue(size int, batchSize int, batchTimeout time.Duration) Option {
+	return func(t *CustomTracer) {
+		if size <= 0 || batchSize <= 0 || batchTimeout <= 0 {
+			t.logger.LogErrorWithoutError("span queue size, batch size and batch timeout must be positive")
+			return
+		}
+		t.queueSize = size
+		t.queueBatchSize = batchSize
+		t.queueBatchTimeout = batchTimeout
+	}
+}
+
+// SetLatencyLogger sets the latency logger used for the metrics of the span queue
+func SetLatencyLogger(latencyLogger gologger.IMultiLogger) Option {
+	return func(t *CustomTracer) { t.latencyLogger = latencyLogger }
+}
+
+const (
+	spansDroppedMetricID  = "TRACER-SPANS-DROPPED"
+	spansExportedMetricID = "TRACER-SPANS-EXPORTED"
+	spanQueueMetricID     = "TRACER-SPAN-QUEUE"
+)
+
+var spanQueueMetrics = gologger.NewMetricSet(func(logger gologger.ILogger) map[string]gologger.IMetricVec {
+	return map[string]gologger.IMetricVec{
+		spansDroppedMetricID: gologger.NewCounterMetric(prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "tracer_spans_dropped_total",
+				Help: "Number of spans dropped as the span queue was full",
+			},
+			[]string{},
+		), logger),
+		spansExportedMetricID: gologger.NewCounterMetric(prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "tracer_spans_exported_total",
+				Help: "Number of spans exported by result",
+			},
+			[]string{"Result"},
+		), logger),
+		spanQueueMetricID: gologger.NewGaugeMetric(prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "tracer_span_queue_depth",
+				Help: "Number of spans waiting in the span queue",
+			},
+			[]string{},
+		), logger),
+	}
+})
+
+// queueSpanProcessor is a batching span processor with a bounded queue and metrics
+type queueSpanProcessor struct {
+	exporter      trace.SpanExporter
+	queue         chan trace.ReadOnlySpan
+	flush         chan chan struct{}
+	batchSize     int
+	batchTimeout  time.Duration
+	logger        gologger.ILogger
+	latencyLogger gologger.IMultiLogger
+	stopLock      sync.RWMutex
+	stopped       bool
+	done          chan struct{}
+}
+
+func newQueueSpanProcessor(exporter trace.SpanExporter, size int, batchSize int, batchTimeout time.Duration,
+	logger gologger.ILogger, latencyLogger gologger.IMultiLogger) *queueSpanProcessor {
+	spanQueueMetrics.AddTo(latencyLogger, logger)
+	p := &queueSpanProcessor{
+		exporter:      exporter,
+		queue:         make(chan trace.ReadOnlySpan, size),
+		flush:         make(chan chan struct{}),
+		batchSize:     batchSize,
+		batchTimeout:  batchTimeout,
+		logger:        logger,
+		latencyLogger: latencyLogger,
+		done:          make(chan struct{}),
+	}
+	goutilities.Go("gotracer-span-queue", p.run)
+	return p
+}
+
+// OnStart does nothing
+func (p *queueSpanProcessor) OnStart(parent context.Context, s trace.ReadWriteSpan) {}
+
+// OnEnd queues sampled spans without blocking
+func (p *queueSpanProcessor) OnEnd(s trace.ReadOnlySpan) {
+	if !s.SpanContext().IsSampled() {
+		return
+	}
+	p.stopLock.RLock()
+	defer p.stopLock.RUnlock()
+	if p.stopped {
+		return
+	}
+	select {
+	case p.queue <- s:
+	default:
+		p.latencyLogger.IncVal(1, spansDroppedMetricID)
+	}
+}
+
+// ForceFlush exports the queued spans
+func (p *queueSpanProcessor) ForceFlush(ctx context.Context) error {
+	flushed := make(chan struct{})
+	select {
+	case p.flush <- flushed:
+	case <-p.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	select {
+	case <-flushed:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Shutdown exports the queued spans and shuts down the exporter
+func (p *queueSpanProcessor) Shutdown(ctx context.Context) error {
+	p.stopLock.Lock()
+	if !p.stopped {
+		p.stopped = true
+		close(p.queue)
+	}
+	p.stopLock.Unlock()
+	select {
+	case <-p.done:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	return p.exporter.Shutdown(ctx)
+}
+
+func (p *queueSpanProcessor) run() {
+	defer close(p.done)
+	batch := make([]trace.ReadOnlySpan, 0, p.batchSize)
+	ticker := time.NewTicker(p.batchTimeout)
+	defer ticker.Stop()
+	for {
+		select {
+		case s, ok := <-p.queue:
+			if !ok {
+				p.export(batch)
+				return
+			}
+			batch = append(batch, s)
+			if len(batch) >= p.batchSize {
+				batch = p.export(batch)
+			}
+		case <-ticker.C:
+			batch = p.export(batch)
+		case flushed := <-p.flush:
+			// Drain the spans queued before the flush
+			for n := len(p.queue); n > 0; n-- {
+				batch = append(batch, <-p.queue)
+				if len(batch) >= p.batchSize {
+					batch = p.export(batch)
+				}
+			}
+			batch = p.export(batch)
+			close(flushed)
+		}
+	}
+}
+
+// export exports the batch and returns an empty batch
+func (p *queueSpanProcessor) export(batch []trace.ReadOnlySpan) []trace.ReadOnlySpan {
+	p.latencyLogger.SetVal(int64(len(p.queue)), spanQueueMetricID)
+	if len(batch) == 0 {
+		return batch
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	if err := p.exporter.ExportSpans(ctx, batch); err != nil {
+		p.logger.LogError("could not export spans", err)
+		p.latencyLogger.IncVal(int64(len(batch)), spansExportedMetricID, "error")
+	} else {
+		p.latencyLogger.IncVal(int64(len(batch)), spansExportedMetricID, "success")
+	}
+	return batch[:0]
+}
@@ -0,0 +1,82 @@
+package gotracer
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/carwale/golibraries/gologger"
+	"go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	oteltrace "go.opentelemetry.io/otel/trace"
+)
+
+type countingMultiLogger struct {
+	lock   sync.Mutex
+	values map[string]int64
+}
+
+func (*countingMultiLogger) AddNewMetric(string, gologger.IMetricVec) {}
+func (*countingMultiLogger) Tic() time.Time                           { return time.Now() }
+func (*countingMultiLogger) Toc(time.Time, string, ...string)         {}
+func (*countingMultiLogger) SubVal(int64, string, ...string)          {}
+func (*countingMultiLogger) SetVal(int64, string, ...string)          {}
+func (m *countingMultiLogger) IncVal(value int64, id string, _ ...string) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	m.values[id] += value
+}
+
+func (m *countingMultiLogger) get(id string) int64 {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	return m.values[id]
+}
+
+type blockingExporter struct {
+	release  chan struct{}
+	exported int64
+}
+
+func (e *blockingExporter) ExportSpans(ctx context.Context, spans []trace.ReadOnlySpan) error {
+	<-e.release
+	atomic.AddInt64(&e.exported, int64(len(spans)))
+	return nil
+}
+
+func (e *blockingExporter) Shutdown(ctx context.Context) error { return nil }
+
+func TestQueueSpanProcessorDropsWhenFull(t *testing.T) {
+	exporter := &blockingExporter{release: make(chan struct{})}
+	metrics := &countingMultiLogger{values: map[string]int64{}}
+	p := newQueueSpanProcessor(exporter, 2, 1, time.Hour, gologger.NewLogger(), metrics)
+
+	sampled := tracetest.SpanStub{SpanContext: oteltrace.NewSpanContext(oteltrace.SpanContextConfig{
+		TraceID:    oteltrace.TraceID{1},
+		SpanID:     oteltrace.SpanID{1},
+		TraceFlags: oteltrace.FlagsSampled,
+	})}.Snapshot()
+
+	// The first span is taken by the exporter, which blocks, the next two fill the queue
+	p.OnEnd(sampled)
+	time.Sleep(50 * time.Millisecond)
+	for i := 0; i < 5; i++ {
+		p.OnEnd(sampled)
+	}
+	if dropped := metrics.get(spansDroppedMetricID); dropped != 3 {
+		t.Errorf("dropped = %d, want 3", dropped)
+	}
+
+	close(exporter.release)
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if err := p.Shutdown(ctx); err != nil {
+		t.Fatalf("Shutdown() error = %v", err)
+	}
+	if exported := atomic.LoadInt64(&exporter.exported); exported != 3 {
+		t.Errorf("exported = %d, want 3", exported)
+	}
+	p.OnEnd(sampled)
+}
@@ -0,0 +1,104 @@
+package gotracer
+
+import (
+	"strconv"
+	"sync"
+	"sync/atomic"
+
+	"go.opentelemetry.io/otel/sdk/trace"
+	oteltrace "go.opentelemetry.io/otel/trace"
+	"go.opentelemetry.io/otel/trace/noop"
+)
+
+// toggleSampler wraps the configured sampler so that tracing can be switched off and the sampler
+// changed without recreating the tracer provider
+type toggleSampler struct {
+	enabled atomic.Bool
+	// enabledFunc replaces enabled when it is set
+	enabledFunc atomic.Pointer[func() bool]
+	lock        sync.RWMutex
+	sampler     trace.Sampler
+}
+
+func newToggleSampler(sampler trace.Sampler) *toggleSampler {
+	s := &toggleSampler{sampler: sampler}
+	s.enabled.Store(true)
+	return s
+}
+
+// ShouldSample drops every span while tracing is disabled
+func (s *toggleSampler) ShouldSample(p trace.SamplingParameters) trace.SamplingResult {
+	if !s.isEnabled() {
+		return trace.SamplingResult{
+			Decision:   trace.Drop,
+			Tracestate: oteltrace.SpanContextFromContext(p.ParentContext).TraceState(),
+		}
+	}
+	s.lock.RLock()
+	sampler := s.sampler
+	s.lock.RUnlock()
+	return sampler.ShouldSample(p)
+}
+
+// Description returns the description of the wrapped sampler
+func (s *toggleSampler) Description() string {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+	return "Toggle{" + s.sampler.Description() + "}"
+}
+
+func (s *toggleSampler) isEnabled() bool {
+	if enabled := s.enabledFunc.Load(); enabled != nil {
+		return (*enabled)()
+	}
+	return s.enabled.Load()
+}
+
+func (s *toggleSampler) setSampler(sampler trace.Sampler) {
+	s.lock.Lock()
+	s.sampler = sampler
+	s.lock.Unlock()
+}
+
+// IsEnabled returns whether spans are being recorded
+func (c *CustomTracer) IsEnabled() bool {
+	return c.toggle.isEnabled()
+}
+
+// SetEnabled switches tracing on or off at runtime. While it is off no spans are recorded or exported
+// and TracerProvider returns a noop provider
+func (c *CustomTracer) SetEnabled(enabled bool) {
+	if c.toggle.enabled.Swap(enabled) != enabled {
+		c.logger.LogInfo("tracing enabled: " + strconv.FormatBool(enabled))
+	}
+}
+
+// SetEnabledFunc makes tracing follow enabled instead of SetEnabled, e.g. to switch it with a feature flag or
+// a consul key, see the consultoggle package. enabled is called for every sampling decision, so it must be
+// cheap and safe for concurrent use. A nil enabled goes back to SetEnabled
+func (c *CustomTracer) SetEnabledFunc(enabled func() bool) {
+	if enabled == nil {
+		c.toggle.enabledFunc.Store(nil)
+		return
+	}
+	c.toggle.enabledFunc.Store(&enabled)
+}
+
+// SetSamplingRatio replaces the sampler at runtime with a parent based sampler sampling the given ratio of traces
+func (c *CustomTracer) SetSamplingRatio(ratio float64) {
+	if ratio < 0 || ratio > 1 {
+		c.logger.LogErrorWithoutError("sampling ratio must be between 0 and 1")
+		return
+	}
+	c.toggle.setSampler(trace.ParentBased(trace.TraceIDRatioBased(ratio)))
+}
+
+// TracerProvider returns the tracer provider while tracing is enabled and a noop provider otherwise.
+// Tracers are bound to the provider they were created from, so call this when creating a tracer
+// rather than holding on to the tracer across toggles
+func (c *CustomTracer) TracerProvider() oteltrace.TracerProvider {
+	if c.traceProvider == nil || !c.IsEnabled() {
+		return noop.NewTracerProvider()
+	}
+	return c.traceProvider
+}
@@ -0,0 +1,56 @@
+package gotracer
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+
+	"github.com/carwale/golibraries/gologger"
+	"go.opentelemetry.io/otel/sdk/trace"
+	oteltrace "go.opentelemetry.io/otel/trace"
+)
+
+func TestToggleSampler(t *testing.T) {
+	c := NewCustomTracer(SetLogger(gologger.NewLogger()), SetIsInKubernetes(true), SetSampler(trace.AlwaysSample()))
+	params := trace.SamplingParameters{ParentContext: context.Background(), TraceID: oteltrace.TraceID{1}, Name: "span"}
+	if d := c.toggle.ShouldSample(params).Decision; d != trace.RecordAndSample {
+		t.Errorf("enabled decision = %v, want RecordAndSample", d)
+	}
+
+	c.SetEnabled(false)
+	if d := c.toggle.ShouldSample(params).Decision; d != trace.Drop {
+		t.Errorf("disabled decision = %v, want Drop", d)
+	}
+	if _, ok := c.TracerProvider().(*trace.TracerProvider); ok {
+		t.Error("TracerProvider() returned the sdk provider while disabled")
+	}
+
+	c.SetEnabled(true)
+	c.SetSamplingRatio(0)
+	if !c.IsEnabled() {
+		t.Error("IsEnabled() = false after enabling")
+	}
+	if d := c.toggle.ShouldSample(params).Decision; d != trace.Drop {
+		t.Errorf("zero ratio decision = %v, want Drop", d)
+	}
+}
+
+func TestSetEnabledFunc(t *testing.T) {
+	c := NewCustomTracer(SetLogger(gologger.NewLogger()), SetIsInKubernetes(true), SetSampler(trace.AlwaysSample()))
+	params := trace.SamplingParameters{ParentContext: context.Background(), TraceID: oteltrace.TraceID{1}, Name: "span"}
+	var enabled atomic.Bool
+	c.SetEnabledFunc(enabled.Load)
+	if d := c.toggle.ShouldSample(params).Decision; d != trace.Drop || c.IsEnabled() {
+		t.Errorf("disabled decision = %v, want Drop", d)
+	}
+	enabled.Store(true)
+	if d := c.toggle.ShouldSample(params).Decision; d != trace.RecordAndSample {
+		t.Errorf("enabled decision = %v, want RecordAndSample", d)
+	}
+
+	c.SetEnabledFunc(nil)
+	c.SetEnabled(false)
+	if c.IsEnabled() {
+		t.Error("IsEnabled() = true after SetEnabled(false) without an enabled func")
+	}
+}
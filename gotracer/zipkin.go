@@ -0,0 +1,204 @@
+package gotracer
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.24.0"
+	oteltrace "go.opentelemetry.io/otel/trace"
+)
+
+// SetSpanExporter sets the exporter used by the tracer provider instead of the otlp grpc exporter of the collector host.
+// Use it to send spans to collectors that do not accept otlp, e.g. with NewZipkinExporter
+func SetSpanExporter(exporter trace.SpanExporter) Option {
+	return func(t *CustomTracer) {
+		if exporter == nil {
+			t.logger.LogError("span exporter cannot be nil", fmt.Errorf("InvalidArgument: span exporter cannot be nil"))
+		} else {
+			t.spanExporter = exporter
+		}
+	}
+}
+
+// ZipkinExporter exports spans in the Zipkin v2 json format to the /api/v2/spans endpoint
+// of a Zipkin compatible collector (Zipkin, Tempo, Jaeger)
+type ZipkinExporter struct {
+	url        string
+	httpClient *http.Client
+}
+
+// NewZipkinExporter returns an exporter for the collector at url, e.g. http://zipkin:9411/api/v2/spans
+func NewZipkinExporter(url string) *ZipkinExporter {
+	return &ZipkinExporter{url: url, httpClient: &http.Client{Timeout: 10 * time.Second}}
+}
+
+type zipkinEndpoint struct {
+	ServiceName string `json:"serviceName,omitempty"`
+}
+
+type zipkinAnnotation struct {
+	Timestamp int64  `json:"timestamp"`
+	Value     string `json:"value"`
+}
+
+type zipkinSpan struct {
+	TraceID       string             `json:"traceId"`
+	ID            string             `json:"id"`
+	ParentID      string             `json:"parentId,omitempty"`
+	Name          string             `json:"name"`
+	Kind          string             `json:"kind,omitempty"`
+	Timestamp     int64              `json:"timestamp"`
+	Duration      int64              `json:"duration"`
+	LocalEndpoint *zipkinEndpoint    `json:"localEndpoint,omitempty"`
+	Annotations   []zipkinAnnotation `json:"annotations,omitempty"`
+	Tags          map[string]string  `json:"tags,omitempty"`
+}
+
+// ExportSpans sends the spans to the collector
+func (e *ZipkinExporter) ExportSpans(ctx context.Context, spans []trace.ReadOnlySpan) error {
+	if len(spans) == 0 {
+		return nil
+	}
+	body, err := encodeZipkinSpans(spans)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, e.url, body)
+	if err != nil {
+		body.Close()
+		return err
+	}
+	req.ContentLength = body.Size()
+	req.Header.Set("Content-Type", "application/json")
+	res, err := e.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	io.Copy(io.Discard, res.Body)
+	if res.StatusCode/100 != 2 {
+		return fmt.Errorf("zipkin collector responded with %s", res.Status)
+	}
+	return nil
+}
+
+// Shutdown does nothing as the exporter does not buffer spans
+func (e *ZipkinExporter) Shutdown(ctx context.Context) error {
+	return nil
+}
+
+// maxPooledBodySize is the capacity above which the buffers of large batches are not kept in the pool
+const maxPooledBodySize = 1 << 20
+
+// zipkinBuffer is a pooled buffer with a preallocated json encoder
+type zipkinBuffer struct {
+	buf bytes.Buffer
+	enc *json.Encoder
+}
+
+var zipkinBufferPool = sync.Pool{
+	New: func() interface{} {
+		b := &zipkinBuffer{}
+		b.enc = json.NewEncoder(&b.buf)
+		return b
+	},
+}
+
+func (b *zipkinBuffer) release() {
+	if b.buf.Cap() <= maxPooledBodySize {
+		b.buf.Reset()
+		zipkinBufferPool.Put(b)
+	}
+}
+
+// zipkinBody is the request body of an export. The transport closes the body when it is done
+// writing the request, which returns the buffer to the pool
+type zipkinBody struct {
+	bytes.Reader
+	buffer *zipkinBuffer
+	once   sync.Once
+}
+
+// Close returns the buffer to the pool. It is safe to call more than once
+func (b *zipkinBody) Close() error {
+	b.once.Do(func() {
+		b.Reset(nil)
+		b.buffer.release()
+	})
+	return nil
+}
+
+// encodeZipkinSpans encodes the spans into a body backed by a pooled buffer
+func encodeZipkinSpans(spans []trace.ReadOnlySpan) (*zipkinBody, error) {
+	buffer := zipkinBufferPool.Get().(*zipkinBuffer)
+	if err := buffer.enc.Encode(toZipkinSpans(spans)); err != nil {
+		buffer.release()
+		return nil, err
+	}
+	body := &zipkinBody{buffer: buffer}
+	body.Reset(buffer.buf.Bytes())
+	return body, nil
+}
+
+func toZipkinSpans(spans []trace.ReadOnlySpan) []zipkinSpan {
+	zipkinSpans := make([]zipkinSpan, 0, len(spans))
+	for _, span := range spans {
+		attributes := span.Attributes()
+		zs := zipkinSpan{
+			TraceID:   span.SpanContext().TraceID().String(),
+			ID:        span.SpanContext().SpanID().String(),
+			Name:      span.Name(),
+			Kind:      zipkinKind(span.SpanKind()),
+			Timestamp: span.StartTime().UnixMicro(),
+			Duration:  span.EndTime().Sub(span.StartTime()).Microseconds(),
+			Tags:      make(map[string]string, len(attributes)+1),
+		}
+		if span.Parent().HasSpanID() {
+			zs.ParentID = span.Parent().SpanID().String()
+		}
+		if res := span.Resource(); res != nil {
+			if name, ok := res.Set().Value(semconv.ServiceNameKey); ok {
+				zs.LocalEndpoint = &zipkinEndpoint{ServiceName: name.Emit()}
+			}
+		}
+		for _, attr := range attributes {
+			zs.Tags[string(attr.Key)] = attr.Value.Emit()
+		}
+		for _, event := range span.Events() {
+			zs.Annotations = append(zs.Annotations, zipkinAnnotation{Timestamp: event.Time.UnixMicro(), Value: event.Name})
+		}
+		if span.Status().Code == codes.Error {
+			zs.Tags["error"] = span.Status().Description
+			if zs.Tags["error"] == "" {
+				zs.Tags["error"] = "true"
+			}
+		}
+		if len(zs.Tags) == 0 {
+			zs.Tags = nil
+		}
+		zipkinSpans = append(zipkinSpans, zs)
+	}
+	return zipkinSpans
+}
+
+func zipkinKind(kind oteltrace.SpanKind) string {
+	switch kind {
+	case oteltrace.SpanKindServer:
+		return "SERVER"
+	case oteltrace.SpanKindClient:
+		return "CLIENT"
+	case oteltrace.SpanKindProducer:
+		return "PRODUCER"
+	case oteltrace.SpanKindConsumer:
+		return "CONSUMER"
+	}
+	return ""
+}
@@ -0,0 +1,121 @@
+package gotracer
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/sdk/resource"
+	"go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	semconv "go.opentelemetry.io/otel/semconv/v1.24.0"
+	oteltrace "go.opentelemetry.io/otel/trace"
+)
+
+func TestToZipkinSpans(t *testing.T) {
+	traceID, _ := oteltrace.TraceIDFromHex("0102030405060708090a0b0c0d0e0f10")
+	spanID, _ := oteltrace.SpanIDFromHex("0102030405060708")
+	parentID, _ := oteltrace.SpanIDFromHex("1112131415161718")
+	start := time.Unix(1700000000, 0)
+	stub := tracetest.SpanStub{
+		Name:        "GET /stocks",
+		SpanContext: oteltrace.NewSpanContext(oteltrace.SpanContextConfig{TraceID: traceID, SpanID: spanID}),
+		Parent:      oteltrace.NewSpanContext(oteltrace.SpanContextConfig{TraceID: traceID, SpanID: parentID}),
+		SpanKind:    oteltrace.SpanKindServer,
+		StartTime:   start,
+		EndTime:     start.Add(1500 * time.Microsecond),
+		Attributes:  []attribute.KeyValue{attribute.Int("http.status_code", 500)},
+		Status:      trace.Status{Code: codes.Error, Description: "internal error"},
+		Resource:    resource.NewSchemaless(semconv.ServiceName("stock")),
+	}
+
+	spans := toZipkinSpans([]trace.ReadOnlySpan{stub.Snapshot()})
+	if len(spans) != 1 {
+		t.Fatalf("toZipkinSpans() returned %d spans, want 1", len(spans))
+	}
+	span := spans[0]
+	if span.TraceID != "0102030405060708090a0b0c0d0e0f10" || span.ID != "0102030405060708" || span.ParentID != "1112131415161718" {
+		t.Errorf("ids = %s %s %s", span.TraceID, span.ID, span.ParentID)
+	}
+	if span.Kind != "SERVER" || span.Duration != 1500 || span.Timestamp != start.UnixMicro() {
+		t.Errorf("span = %+v, want SERVER span of 1500us", span)
+	}
+	if span.LocalEndpoint == nil || span.LocalEndpoint.ServiceName != "stock" {
+		t.Errorf("local endpoint = %+v, want stock", span.LocalEndpoint)
+	}
+	if span.Tags["http.status_code"] != "500" || span.Tags["error"] != "internal error" {
+		t.Errorf("tags = %v", span.Tags)
+	}
+}
+
+func benchmarkSpans(n int) []trace.ReadOnlySpan {
+	traceID, _ := oteltrace.TraceIDFromHex("0102030405060708090a0b0c0d0e0f10")
+	spanID, _ := oteltrace.SpanIDFromHex("0102030405060708")
+	start := time.Unix(1700000000, 0)
+	spans := make([]trace.ReadOnlySpan, n)
+	for i := range spans {
+		stub := tracetest.SpanStub{
+			Name:        "GET /stocks",
+			SpanContext: oteltrace.NewSpanContext(oteltrace.SpanContextConfig{TraceID: traceID, SpanID: spanID}),
+			SpanKind:    oteltrace.SpanKindServer,
+			StartTime:   start,
+			EndTime:     start.Add(time.Millisecond),
+			Attributes:  []attribute.KeyValue{attribute.Int("http.status_code", 200), attribute.String("http.route", "/stocks")},
+			Resource:    resource.NewSchemaless(semconv.ServiceName("stock")),
+		}
+		spans[i] = stub.Snapshot()
+	}
+	return spans
+}
+
+func TestEncodeZipkinSpansReusesBuffer(t *testing.T) {
+	spans := benchmarkSpans(2)
+	for i := 0; i < 2; i++ {
+		body, err := encodeZipkinSpans(spans)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var decoded []zipkinSpan
+		if err := json.NewDecoder(body).Decode(&decoded); err != nil || len(decoded) != 2 {
+			t.Fatalf("decoded %d spans, err %v", len(decoded), err)
+		}
+		body.Close()
+		body.Close()
+	}
+}
+
+// BenchmarkEncodeZipkinSpans measures the encoding of a default batch of 512 spans.
+// The target is under 2ms per batch so that a single exporter keeps up with 250k spans per second
+func BenchmarkEncodeZipkinSpans(b *testing.B) {
+	spans := benchmarkSpans(512)
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		body, err := encodeZipkinSpans(spans)
+		if err != nil {
+			b.Fatal(err)
+		}
+		body.Close()
+	}
+}
+
+func BenchmarkZipkinExport(b *testing.B) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.Copy(io.Discard, r.Body)
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	defer server.Close()
+	exporter := NewZipkinExporter(server.URL)
+	spans := benchmarkSpans(512)
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if err := exporter.ExportSpans(context.Background(), spans); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,160 @@
+package goutilities
+
+import (
+	"fmt"
+	"net"
+	"strings"
+)
+
+// PrivateCIDRs are the loopback, link local and private ranges. Load balancers, ingress controllers
+// and pods of a cluster are usually in these ranges
+var PrivateCIDRs = []string{
+	"127.0.0.0/8",
+	"10.0.0.0/8",
+	"172.16.0.0/12",
+	"192.168.0.0/16",
+	"169.254.0.0/16",
+	"::1/128",
+	"fc00::/7",
+	"fe80::/10",
+}
+
+// NormalizeIP returns the ip address in value in its canonical form. value may have a port and
+// brackets around an ipv6 address. It returns "" if value is not an ip address
+func NormalizeIP(value string) string {
+	value = strings.TrimSpace(value)
+	if host, _, err := net.SplitHostPort(value); err == nil {
+		value = host
+	}
+	value = strings.TrimSuffix(strings.TrimPrefix(value, "["), "]")
+	ip := net.ParseIP(value)
+	if ip == nil {
+		return ""
+	}
+	return ip.String()
+}
+
+// IsValidIP returns true if value is an ipv4 or ipv6 address, with or without a port
+func IsValidIP(value string) bool {
+	return NormalizeIP(value) != ""
+}
+
+// IsPrivateIP returns true if the address is in the private ranges (10.0.0.0/8, 172.16.0.0/12,
+// 192.168.0.0/16 and fc00::/7)
+func IsPrivateIP(value string) bool {
+	ip := net.ParseIP(NormalizeIP(value))
+	return ip != nil && ip.IsPrivate()
+}
+
+// IsLinkLocalIP returns true if the address is a link local address (169.254.0.0/16 or fe80::/10)
+func IsLinkLocalIP(value string) bool {
+	ip := net.ParseIP(NormalizeIP(value))
+	return ip != nil && (ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast())
+}
+
+// IsInternalIP returns true if the address is a loopback, link local or private address, i.e. in PrivateCIDRs
+func IsInternalIP(value string) bool {
+	ip := net.ParseIP(NormalizeIP(value))
+	return ip != nil && (ip.IsLoopback() || ip.IsPrivate() || ip.IsLinkLocalUnicast())
+}
+
+// CIDRList is an allow list of networks. The zero value contains no address
+type CIDRList struct {
+	networks []*net.IPNet
+}
+
+// ParseCIDRList returns the list of the cidrs. A single address has to be given as a /32 or /128 network.
+// It returns an error for the first entry that is not a cidr
+func ParseCIDRList(cidrs ...string) (*CIDRList, error) {
+	l := &CIDRList{networks: make([]*net.IPNet, 0, len(cidrs))}
+	for _, cidr := range cidrs {
+		_, ipNet, err := net.ParseCIDR(strings.TrimSpace(cidr))
+		if err != nil {
+			return nil, fmt.Errorf("invalid cidr %q: %w", cidr, err)
+		}
+		l.networks = append(l.networks, ipNet)
+	}
+	return l, nil
+}
+
+// MustParseCIDRList is ParseCIDRList for lists known to be valid. It panics on an invalid entry
+func MustParseCIDRList(cidrs ...string) *CIDRList {
+	l, err := ParseCIDRList(cidrs...)
+	if err != nil {
+		panic(err)
+	}
+	return l
+}
+
+// Contains returns true if the address is in one of the networks of the list
+func (l *CIDRList) Contains(value string) bool {
+	if l == nil {
+		return false
+	}
+	ip := net.ParseIP(NormalizeIP(value))
+	if ip == nil {
+		return false
+	}
+	for _, network := range l.networks {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// ForwardedChain returns the valid addresses of X-Forwarded-For header values in order
+func ForwardedChain(values ...string) []string {
+	var chain []string
+	for _, value := range values {
+		for _, part := range strings.Split(value, ",") {
+			if ip := NormalizeIP(part); ip != "" {
+				chain = append(chain, ip)
+			}
+		}
+	}
+	return chain
+}
+
+// ClientIPFromChain returns the address of the client of a request received from remoteAddr with the
+// X-Forwarded-For chain. Proxies add the address they received the request from to the chain, so it is
+// read from the right and the first address which is not a trusted proxy is the client. The chain is
+// ignored if remoteAddr is not a trusted proxy. It returns "" if remoteAddr is trusted and the chain is empty
+func ClientIPFromChain(remoteAddr string, chain []string, trusted *CIDRList) string {
+	remote := NormalizeIP(remoteAddr)
+	if remote == "" {
+		remote = remoteAddr
+	}
+	if !trusted.Contains(remote) {
+		return remote
+	}
+	for i := len(chain) - 1; i >= 0; i-- {
+		if !trusted.Contains(chain[i]) {
+			return chain[i]
+		}
+	}
+	if len(chain) > 0 {
+		// All the addresses are trusted, so the leftmost one is the origin
+		return chain[0]
+	}
+	return ""
+}
+
+// GetPrivateIP returns the first private ipv4 address of the network interfaces of the machine, e.g. the pod ip.
+// Unlike GetOutboundIP it does not need a route to the internet. It returns "" if there is none
+func GetPrivateIP() string {
+	addrs, err := net.InterfaceAddrs()
+	if err != nil {
+		return ""
+	}
+	for _, addr := range addrs {
+		ipNet, ok := addr.(*net.IPNet)
+		if !ok {
+			continue
+		}
+		if ip := ipNet.IP.To4(); ip != nil && ip.IsPrivate() {
+			return ip.String()
+		}
+	}
+	return ""
+}
@@ -0,0 +1,78 @@
+package goutilities
+
+import "testing"
+
+func TestNormalizeIP(t *testing.T) {
+	tests := map[string]string{
+		" 10.0.0.1 ":           "10.0.0.1",
+		"10.0.0.1:8080":        "10.0.0.1",
+		"[2001:db8::1]:443":    "2001:db8::1",
+		"2001:DB8:0:0:0:0:0:1": "2001:db8::1",
+		"not-an-ip":            "",
+		"":                     "",
+	}
+	for value, want := range tests {
+		if got := NormalizeIP(value); got != want {
+			t.Errorf("NormalizeIP(%q) = %q, want %q", value, got, want)
+		}
+	}
+}
+
+func TestIPRanges(t *testing.T) {
+	if !IsPrivateIP("172.20.1.1") || IsPrivateIP("8.8.8.8") || IsPrivateIP("127.0.0.1") {
+		t.Error("IsPrivateIP() is wrong")
+	}
+	if !IsLinkLocalIP("169.254.169.254") || !IsLinkLocalIP("fe80::1") || IsLinkLocalIP("10.0.0.1") {
+		t.Error("IsLinkLocalIP() is wrong")
+	}
+	for _, ip := range []string{"127.0.0.1", "10.1.2.3", "169.254.1.1", "::1", "fd00::1"} {
+		if !IsInternalIP(ip) {
+			t.Errorf("IsInternalIP(%q) = false", ip)
+		}
+	}
+	if IsInternalIP("8.8.8.8") || IsInternalIP("junk") {
+		t.Error("IsInternalIP() is true for a public or invalid address")
+	}
+}
+
+func TestCIDRList(t *testing.T) {
+	list, err := ParseCIDRList("10.0.0.0/8", "203.0.113.7/32", "2001:db8::/32")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for ip, want := range map[string]bool{
+		"10.9.9.9":       true,
+		"203.0.113.7:80": true,
+		"203.0.113.8":    false,
+		"2001:db8::5":    true,
+		"junk":           false,
+	} {
+		if got := list.Contains(ip); got != want {
+			t.Errorf("Contains(%q) = %v, want %v", ip, got, want)
+		}
+	}
+	if _, err := ParseCIDRList("10.0.0.0/33"); err == nil {
+		t.Error("ParseCIDRList() accepted an invalid cidr")
+	}
+	if _, err := ParseCIDRList("10.0.0.1"); err == nil {
+		t.Error("ParseCIDRList() accepted an address without a prefix length")
+	}
+	var empty *CIDRList
+	if empty.Contains("10.0.0.1") {
+		t.Error("nil list contains an address")
+	}
+}
+
+func TestClientIPFromChain(t *testing.T) {
+	trusted := MustParseCIDRList(PrivateCIDRs...)
+	chain := ForwardedChain("1.1.1.1, 2.2.2.2", "10.0.0.5")
+	if got := ClientIPFromChain("10.0.0.1:5000", chain, trusted); got != "2.2.2.2" {
+		t.Errorf("ClientIPFromChain() = %q, want 2.2.2.2", got)
+	}
+	if got := ClientIPFromChain("3.3.3.3:5000", chain, trusted); got != "3.3.3.3" {
+		t.Errorf("ClientIPFromChain() from an untrusted peer = %q, want 3.3.3.3", got)
+	}
+	if got := ClientIPFromChain("10.0.0.1:5000", nil, trusted); got != "" {
+		t.Errorf("ClientIPFromChain() without a chain = %q, want empty", got)
+	}
+}
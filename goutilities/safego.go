@@ -0,0 +1,108 @@
+package goutilities
+
+import (
+	"context"
+	"fmt"
+	"runtime/debug"
+	"sync"
+
+	"github.com/carwale/golibraries/crashreport"
+	"github.com/carwale/golibraries/gologger"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const goroutinePanicsMetricID = "GOROUTINE-PANICS"
+
+var (
+	goroutineLock          sync.RWMutex
+	goroutineLogger        gologger.ILogger
+	goroutineLatencyLogger gologger.IMultiLogger
+	goroutineReporter      *crashreport.Reporter
+	goroutineSync          sync.Once
+)
+
+// SetGoroutineLogger sets the logger the panics recovered by Go and GoCtx are logged to.
+// Default is a logger at ERROR level
+func SetGoroutineLogger(logger gologger.ILogger) {
+	goroutineLock.Lock()
+	defer goroutineLock.Unlock()
+	goroutineLogger = logger
+}
+
+// SetGoroutineLatencyLogger sets the latency logger used for the goroutine panic counter.
+// It has to be called before the first panic is recovered to take effect
+func SetGoroutineLatencyLogger(latencyLogger gologger.IMultiLogger) {
+	goroutineLock.Lock()
+	defer goroutineLock.Unlock()
+	goroutineLatencyLogger = latencyLogger
+}
+
+// SetGoroutineReporter reports the panics recovered by Go and GoCtx with the crash reporter
+// instead of only logging them, so that the report has the goroutine dump and the recent logs
+func SetGoroutineReporter(reporter *crashreport.Reporter) {
+	goroutineLock.Lock()
+	defer goroutineLock.Unlock()
+	goroutineReporter = reporter
+}
+
+// Go runs fn in a new goroutine. A panic in fn is recovered, logged with the name of the goroutine and
+// counted in the goroutine_panics_total metric, instead of crashing the process.
+// Use it for background work whose failure should not take the service down; use crashreport.Reporter.Go
+// for goroutines the process cannot run without
+//
+//	goutilities.Go("refresh-cities", func() { refreshCities() })
+func Go(name string, fn func()) {
+	go func() {
+		defer recoverGoroutine(name)
+		fn()
+	}()
+}
+
+// GoCtx is Go for functions taking a context
+func GoCtx(ctx context.Context, name string, fn func(ctx context.Context)) {
+	go func() {
+		defer recoverGoroutine(name)
+		fn(ctx)
+	}()
+}
+
+// recoverGoroutine recovers a panic of the goroutine and reports it. It must be deferred directly
+func recoverGoroutine(name string) {
+	value := recover()
+	if value == nil {
+		return
+	}
+	logger, latencyLogger, reporter := goroutineDependencies()
+	latencyLogger.IncVal(1, goroutinePanicsMetricID, name)
+	if reporter != nil {
+		reporter.Report("goroutine "+name, value)
+		return
+	}
+	logger.LogErrorMessage("Goroutine panicked", fmt.Errorf("%v", value),
+		gologger.Pair{Key: "goroutine", Value: name}, gologger.Pair{Key: "stack_trace", Value: string(debug.Stack())})
+}
+
+// goroutineDependencies returns the logger, latency logger and reporter, creating the defaults and
+// registering the panic counter on first use
+func goroutineDependencies() (gologger.ILogger, gologger.IMultiLogger, *crashreport.Reporter) {
+	goroutineSync.Do(func() {
+		goroutineLock.Lock()
+		defer goroutineLock.Unlock()
+		if goroutineLogger == nil {
+			goroutineLogger = gologger.NewLogger(gologger.SetLogLevel("ERROR"))
+		}
+		if goroutineLatencyLogger == nil {
+			goroutineLatencyLogger = gologger.NewRateLatencyLogger(gologger.SetLogger(goroutineLogger))
+		}
+		goroutineLatencyLogger.AddNewMetric(goroutinePanicsMetricID, gologger.NewCounterMetric(prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "goroutine_panics_total",
+				Help: "Number of panics recovered in the goroutines started with goutilities.Go",
+			},
+			[]string{"Goroutine"},
+		), goroutineLogger))
+	})
+	goroutineLock.RLock()
+	defer goroutineLock.RUnlock()
+	return goroutineLogger, goroutineLatencyLogger, goroutineReporter
+}
@@ -0,0 +1,56 @@
+package goutilities
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/carwale/golibraries/gologger"
+)
+
+type panicLogger struct {
+	gologger.ILogger
+	goroutines chan string
+}
+
+func (l *panicLogger) LogErrorMessage(str string, err error, pairs ...gologger.Pair) {
+	for _, pair := range pairs {
+		if pair.Key == "goroutine" {
+			l.goroutines <- pair.Value
+		}
+	}
+}
+
+type panicCounter struct {
+	gologger.IMultiLogger
+	panics chan string
+}
+
+func (c *panicCounter) AddNewMetric(string, gologger.IMetricVec) {}
+
+func (c *panicCounter) IncVal(val int64, id string, labels ...string) {
+	c.panics <- labels[0]
+}
+
+func TestGoRecoversPanics(t *testing.T) {
+	logger := &panicLogger{goroutines: make(chan string, 2)}
+	counter := &panicCounter{panics: make(chan string, 2)}
+	SetGoroutineLogger(logger)
+	SetGoroutineLatencyLogger(counter)
+
+	Go("refresh", func() { panic("boom") })
+	GoCtx(context.Background(), "watch", func(ctx context.Context) { panic("boom") })
+
+	for i := 0; i < 2; i++ {
+		select {
+		case <-logger.goroutines:
+		case <-time.After(time.Second):
+			t.Fatal("panic was not logged")
+		}
+		select {
+		case <-counter.panics:
+		case <-time.After(time.Second):
+			t.Fatal("panic was not counted")
+		}
+	}
+}
@@ -0,0 +1,172 @@
+package healthcheck
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/health/grpc_health_v1"
+)
+
+// Status is the result of probing a dependency
+type Status struct {
+	// Target is the grpc address or the http url that was probed
+	Target string
+	// Healthy is true if the dependency reported that it is serving
+	Healthy bool
+	// Status is the grpc serving status or the http status line
+	Status string
+	// Latency is the time taken by the probe
+	Latency time.Duration
+	// Err is set if the dependency could not be probed
+	Err error
+}
+
+// String returns a human readable status
+func (s Status) String() string {
+	if s.Err != nil {
+		return fmt.Sprintf("%s: %s (%s): %v", s.Target, s.Status, s.Latency, s.Err)
+	}
+	return fmt.Sprintf("%s: %s (%s)", s.Target, s.Status, s.Latency)
+}
+
+// Client probes the grpc health endpoint or the http health endpoint of dependencies
+type Client struct {
+	timeout     time.Duration
+	service     string
+	httpClient  *http.Client
+	dialOptions []grpc.DialOption
+}
+
+// ClientOption sets a parameter for the health check client
+type ClientOption func(c *Client)
+
+// SetTimeout sets the timeout of a probe, including dialing. Defaults to 2 seconds
+func SetTimeout(timeout time.Duration) ClientOption {
+	return func(c *Client) {
+		if timeout > 0 {
+			c.timeout = timeout
+		}
+	}
+}
+
+// SetService sets the service name sent in grpc health checks. Defaults to "" which is the overall health of the server
+func SetService(service string) ClientOption {
+	return func(c *Client) { c.service = service }
+}
+
+// SetHTTPClient sets the client used for http probes. Defaults to http.DefaultClient
+func SetHTTPClient(httpClient *http.Client) ClientOption {
+	return func(c *Client) { c.httpClient = httpClient }
+}
+
+// SetDialOptions sets the options used to dial grpc targets. Defaults to insecure credentials
+func SetDialOptions(dialOptions ...grpc.DialOption) ClientOption {
+	return func(c *Client) { c.dialOptions = dialOptions }
+}
+
+// NewHealthCheckClient returns a client for probing dependencies
+func NewHealthCheckClient(options ...ClientOption) *Client {
+	c := &Client{
+		timeout:     2 * time.Second,
+		httpClient:  http.DefaultClient,
+		dialOptions: []grpc.DialOption{grpc.WithTransportCredentials(insecure.NewCredentials())},
+	}
+	for _, option := range options {
+		option(c)
+	}
+	return c
+}
+
+// Check probes the target. Targets starting with http:// or https:// are probed with a GET request,
+// other targets are treated as grpc addresses
+func (c *Client) Check(ctx context.Context, target string) Status {
+	if strings.HasPrefix(target, "http://") || strings.HasPrefix(target, "https://") {
+		return c.CheckHTTP(ctx, target)
+	}
+	return c.CheckGRPC(ctx, target)
+}
+
+// CheckGRPC calls the Check method of the grpc health service at the address
+func (c *Client) CheckGRPC(ctx context.Context, address string) Status {
+	start := time.Now()
+	ctx, cancel := context.WithTimeout(ctx, c.timeout)
+	defer cancel()
+	status := Status{Target: address, Status: grpc_health_v1.HealthCheckResponse_UNKNOWN.String()}
+
+	conn, err := grpc.DialContext(ctx, address, append(c.dialOptions, grpc.WithBlock())...)
+	if err != nil {
+		status.Latency = time.Since(start)
+		status.Err = fmt.Errorf("could not dial %s: %w", address, err)
+		return status
+	}
+	defer conn.Close()
+	res, err := grpc_health_v1.NewHealthClient(conn).Check(ctx, &grpc_health_v1.HealthCheckRequest{Service: c.service})
+	status.Latency = time.Since(start)
+	if err != nil {
+		status.Err = err
+		return status
+	}
+	status.Status = res.GetStatus().String()
+	status.Healthy = res.GetStatus() == grpc_health_v1.HealthCheckResponse_SERVING
+	return status
+}
+
+// CheckHTTP sends a GET request to the url. Any 2xx response is healthy
+func (c *Client) CheckHTTP(ctx context.Context, url string) Status {
+	start := time.Now()
+	ctx, cancel := context.WithTimeout(ctx, c.timeout)
+	defer cancel()
+	status := Status{Target: url}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		status.Err = err
+		return status
+	}
+	res, err := c.httpClient.Do(req)
+	status.Latency = time.Since(start)
+	if err != nil {
+		status.Err = err
+		return status
+	}
+	res.Body.Close()
+	status.Status = res.Status
+	status.Healthy = res.StatusCode >= 200 && res.StatusCode < 300
+	return status
+}
+
+// CheckAll probes the targets concurrently. The statuses are in the order of the targets
+func (c *Client) CheckAll(ctx context.Context, targets ...string) []Status {
+	statuses := make([]Status, len(targets))
+	var wg sync.WaitGroup
+	for i, target := range targets {
+		wg.Add(1)
+		go func(i int, target string) {
+			defer wg.Done()
+			statuses[i] = c.Check(ctx, target)
+		}(i, target)
+	}
+	wg.Wait()
+	return statuses
+}
+
+// CheckFunction returns a check function for NewHealthCheckServer which is healthy only if all the targets are healthy
+func (c *Client) CheckFunction(targets ...string) func() (bool, error) {
+	return func() (bool, error) {
+		for _, status := range c.CheckAll(context.Background(), targets...) {
+			if !status.Healthy {
+				if status.Err != nil {
+					return false, fmt.Errorf("dependency %s is unhealthy: %w", status.Target, status.Err)
+				}
+				return false, fmt.Errorf("dependency %s is unhealthy: %s", status.Target, status.Status)
+			}
+		}
+		return true, nil
+	}
+}
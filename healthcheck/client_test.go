@@ -0,0 +1,33 @@
+package healthcheck
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestCheckHTTP(t *testing.T) {
+	healthy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer healthy.Close()
+	unhealthy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer unhealthy.Close()
+
+	c := NewHealthCheckClient()
+	statuses := c.CheckAll(context.Background(), healthy.URL, unhealthy.URL)
+	if !statuses[0].Healthy {
+		t.Errorf("status of healthy server = %v, want healthy", statuses[0])
+	}
+	if statuses[1].Healthy || statuses[1].Err != nil {
+		t.Errorf("status of unhealthy server = %v, want unhealthy without error", statuses[1])
+	}
+
+	if ok, err := c.CheckFunction(healthy.URL, unhealthy.URL)(); ok || err == nil {
+		t.Errorf("CheckFunction() = %v, %v, want false with an error", ok, err)
+	}
+	if ok, err := c.CheckFunction(healthy.URL)(); !ok || err != nil {
+		t.Errorf("CheckFunction() = %v, %v, want true", ok, err)
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"net"
 
 	"github.com/carwale/golibraries/gologger"
+	"github.com/carwale/golibraries/goutilities"
 
 	"google.golang.org/grpc"
 	codes "google.golang.org/grpc/codes"
@@ -16,7 +17,8 @@ import (
 type healthCheckServer struct {
 	healthCheckPort string
 	checkFunction   func() (bool, error)
-	logger          *gologger.CustomLogger
+	logger          gologger.ILogger
+	readiness       *ReadinessController
 }
 
 //Options sets the oprions for the health checking service
@@ -24,10 +26,16 @@ type Options func(hcs *healthCheckServer)
 
 //Logger sets the logger for consul
 //Defaults to consul logger
-func Logger(customLogger *gologger.CustomLogger) Options {
+func Logger(customLogger gologger.ILogger) Options {
 	return func(hcs *healthCheckServer) { hcs.logger = customLogger }
 }
 
+// Readiness sets the readiness controller of the service. The check fails while a component is not ready.
+// Checks for the ReadinessService service only report the readiness of the components
+func Readiness(rc *ReadinessController) Options {
+	return func(hcs *healthCheckServer) { hcs.readiness = rc }
+}
+
 // NewHealthCheckServer starts a health check server with the given port.
 // It exposes a Check function that is compatible with consul
 // The check function will call the 'checkFunction' that is passed and will return accordingly
@@ -45,10 +53,19 @@ func NewHealthCheckServer(healthCheckPort string, checkFunction func() (bool, er
 		hcs.logger = gologger.NewLogger()
 	}
 
-	go hcs.startHealthService()
+	goutilities.Go("healthcheck-server", hcs.startHealthService)
 }
 
 func (hcs *healthCheckServer) Check(ctx context.Context, in *grpc_health_v1.HealthCheckRequest) (*grpc_health_v1.HealthCheckResponse, error) {
+	if hcs.readiness != nil {
+		if ok, err := hcs.readiness.IsReady(); !ok {
+			hcs.logger.LogWarning("Health Check failed as service is not ready: " + err.Error())
+			return &grpc_health_v1.HealthCheckResponse{Status: grpc_health_v1.HealthCheckResponse_NOT_SERVING}, nil
+		}
+		if in.GetService() == ReadinessService {
+			return &grpc_health_v1.HealthCheckResponse{Status: grpc_health_v1.HealthCheckResponse_SERVING}, nil
+		}
+	}
 	res, err := hcs.checkFunction()
 	if err != nil {
 		hcs.logger.LogError("Health Check failed with error", err)
@@ -0,0 +1,137 @@
+package healthcheck
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ReadinessService is the grpc health service name that reports only the readiness of the components
+const ReadinessService = "readiness"
+
+// ComponentState is the readiness of a component
+type ComponentState struct {
+	Ready  bool      `json:"ready"`
+	Reason string    `json:"reason,omitempty"`
+	Since  time.Time `json:"since"`
+	// Details identify the component, like the instance id of a consumer
+	Details map[string]string `json:"details,omitempty"`
+}
+
+// ReadinessController tracks the readiness of the components of a service (kafka consumers, rabbitmq channels,
+// consul registration). The service is ready only when all the registered components are ready.
+// Components are registered as not ready, so traffic is not routed to the service until they are connected
+type ReadinessController struct {
+	lock       sync.RWMutex
+	components map[string]ComponentState
+}
+
+// NewReadinessController returns a controller with the components registered as not ready
+func NewReadinessController(components ...string) *ReadinessController {
+	rc := &ReadinessController{components: make(map[string]ComponentState)}
+	for _, component := range components {
+		rc.Register(component)
+	}
+	return rc
+}
+
+// Register adds the component as not ready. It does nothing if the component is already registered
+func (rc *ReadinessController) Register(component string) {
+	rc.lock.Lock()
+	defer rc.lock.Unlock()
+	if _, ok := rc.components[component]; !ok {
+		rc.components[component] = ComponentState{Reason: "starting", Since: time.Now()}
+	}
+}
+
+// MarkReady marks the component as ready. Unknown components are registered
+func (rc *ReadinessController) MarkReady(component string) {
+	rc.set(component, ComponentState{Ready: true})
+}
+
+// MarkUnready marks the component as not ready with the reason. Unknown components are registered
+func (rc *ReadinessController) MarkUnready(component string, reason string) {
+	rc.set(component, ComponentState{Reason: reason})
+}
+
+// SetDetails sets the details of the component. Unknown components are registered
+func (rc *ReadinessController) SetDetails(component string, details map[string]string) {
+	rc.Register(component)
+	rc.lock.Lock()
+	defer rc.lock.Unlock()
+	state := rc.components[component]
+	state.Details = details
+	rc.components[component] = state
+}
+
+func (rc *ReadinessController) set(component string, state ComponentState) {
+	rc.lock.Lock()
+	defer rc.lock.Unlock()
+	current, ok := rc.components[component]
+	if ok && current.Ready == state.Ready && current.Reason == state.Reason {
+		return
+	}
+	state.Details = current.Details
+	state.Since = time.Now()
+	rc.components[component] = state
+}
+
+// IsReady returns true if all the components are ready. Otherwise the error lists the components that are not ready
+func (rc *ReadinessController) IsReady() (bool, error) {
+	rc.lock.RLock()
+	defer rc.lock.RUnlock()
+	var unready []string
+	for component, state := range rc.components {
+		if !state.Ready {
+			unready = append(unready, component+" ("+state.Reason+")")
+		}
+	}
+	if len(unready) == 0 {
+		return true, nil
+	}
+	sort.Strings(unready)
+	return false, fmt.Errorf("components not ready: %s", strings.Join(unready, ", "))
+}
+
+// Components returns a copy of the states of the components
+func (rc *ReadinessController) Components() map[string]ComponentState {
+	rc.lock.RLock()
+	defer rc.lock.RUnlock()
+	components := make(map[string]ComponentState, len(rc.components))
+	for component, state := range rc.components {
+		components[component] = state
+	}
+	return components
+}
+
+// CheckFunction returns a check function which fails if the service is not ready, and calls check otherwise.
+// check can be nil
+func (rc *ReadinessController) CheckFunction(check func() (bool, error)) func() (bool, error) {
+	return func() (bool, error) {
+		if ok, err := rc.IsReady(); !ok {
+			return false, err
+		}
+		if check == nil {
+			return true, nil
+		}
+		return check()
+	}
+}
+
+// ServeHTTP responds with the states of the components as json. The status is 200 if the service
+// is ready and 503 otherwise, so it can be used as the http readiness probe of kubernetes
+func (rc *ReadinessController) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	ready, _ := rc.IsReady()
+	w.Header().Set("Content-Type", "application/json")
+	if !ready {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+	json.NewEncoder(w).Encode(struct {
+		Ready      bool                      `json:"ready"`
+		Components map[string]ComponentState `json:"components"`
+	}{ready, rc.Components()})
+}
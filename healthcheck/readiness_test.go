@@ -0,0 +1,65 @@
+package healthcheck
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/carwale/golibraries/gologger"
+	"google.golang.org/grpc/health/grpc_health_v1"
+)
+
+func TestReadinessController(t *testing.T) {
+	rc := NewReadinessController("kafka", "consul")
+	if ok, err := rc.IsReady(); ok || err == nil {
+		t.Fatalf("IsReady() = %v, %v before components are ready, want false", ok, err)
+	}
+	rc.MarkReady("kafka")
+	rc.MarkReady("consul")
+	if ok, err := rc.IsReady(); !ok {
+		t.Fatalf("IsReady() = %v, %v, want true", ok, err)
+	}
+	rc.MarkUnready("kafka", "broker down")
+	if ok, _ := rc.IsReady(); ok {
+		t.Fatal("IsReady() = true after a component became unready, want false")
+	}
+
+	rec := httptest.NewRecorder()
+	rc.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("ServeHTTP() status = %d, want 503", rec.Code)
+	}
+}
+
+func TestReadinessDetails(t *testing.T) {
+	rc := NewReadinessController()
+	rc.SetDetails("kafka", map[string]string{"instance_id": "leads-pod-1"})
+	rc.MarkReady("kafka")
+	if state := rc.Components()["kafka"]; !state.Ready || state.Details["instance_id"] != "leads-pod-1" {
+		t.Errorf("state = %+v, want a ready component with its details", state)
+	}
+}
+
+func TestCheckWithReadiness(t *testing.T) {
+	rc := NewReadinessController("rabbitmq")
+	hcs := &healthCheckServer{
+		checkFunction: func() (bool, error) { return false, nil },
+		logger:        gologger.NewLogger(),
+		readiness:     rc,
+	}
+	check := func(service string) grpc_health_v1.HealthCheckResponse_ServingStatus {
+		res, _ := hcs.Check(context.Background(), &grpc_health_v1.HealthCheckRequest{Service: service})
+		return res.GetStatus()
+	}
+	if status := check(ReadinessService); status != grpc_health_v1.HealthCheckResponse_NOT_SERVING {
+		t.Errorf("readiness status = %v before ready, want NOT_SERVING", status)
+	}
+	rc.MarkReady("rabbitmq")
+	if status := check(ReadinessService); status != grpc_health_v1.HealthCheckResponse_SERVING {
+		t.Errorf("readiness status = %v, want SERVING", status)
+	}
+	if status := check(""); status != grpc_health_v1.HealthCheckResponse_NOT_SERVING {
+		t.Errorf("overall status = %v with failing check function, want NOT_SERVING", status)
+	}
+}
@@ -0,0 +1,96 @@
+package httplogs
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/carwale/golibraries/gologger"
+	"google.golang.org/grpc"
+)
+
+// DebugLogHeader is the header (or gRPC metadata key) with the signed token that enables debug logs for a request
+const DebugLogHeader = "X-Debug-Log"
+
+// maxDebugTokenValidity limits how long a leaked token can be used
+const maxDebugTokenValidity = 24 * time.Hour
+
+// SignDebugToken returns a token for the X-Debug-Log header which is valid till expiry.
+// Tokens valid for more than 24 hours are rejected
+func SignDebugToken(secret []byte, expiry time.Time) string {
+	expiryUnix := strconv.FormatInt(expiry.Unix(), 10)
+	return expiryUnix + "." + debugTokenSignature(secret, expiryUnix)
+}
+
+func debugTokenSignature(secret []byte, expiryUnix string) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(expiryUnix))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// isValidDebugToken checks the signature and the expiry of the token
+func isValidDebugToken(secret []byte, token string, now time.Time) bool {
+	if len(secret) == 0 || token == "" {
+		return false
+	}
+	expiryUnix, signature, found := strings.Cut(token, ".")
+	if !found {
+		return false
+	}
+	if !hmac.Equal([]byte(signature), []byte(debugTokenSignature(secret, expiryUnix))) {
+		return false
+	}
+	expiry, err := strconv.ParseInt(expiryUnix, 10, 64)
+	if err != nil {
+		return false
+	}
+	expiryTime := time.Unix(expiry, 0)
+	return expiryTime.After(now) && expiryTime.Sub(now) <= maxDebugTokenValidity
+}
+
+// withDebugLevel elevates the log level of the context to DEBUG if the token is valid
+func withDebugLevel(ctx context.Context, secret []byte, token string) context.Context {
+	if !isValidDebugToken(secret, token, time.Now()) {
+		return ctx
+	}
+	return gologger.WithLogLevel(ctx, gologger.DEBUG)
+}
+
+// DebugLogMiddleware enables debug logs for the requests which have a valid token signed with secret
+// in the X-Debug-Log header. The *WithContext methods of the logger log debug messages for the
+// context of these requests, other requests use the level of the logger
+func DebugLogMiddleware(secret []byte) func(http.Handler) http.Handler {
+	return func(h http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if token := r.Header.Get(DebugLogHeader); token != "" {
+				r = r.WithContext(withDebugLevel(r.Context(), secret, token))
+			}
+			h.ServeHTTP(w, r)
+		})
+	}
+}
+
+// UnaryDebugLogInterceptor is the gRPC equivalent of DebugLogMiddleware. The token is read from the x-debug-log metadata
+func UnaryDebugLogInterceptor(secret []byte) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		if token := firstMetadataValue(ctx, strings.ToLower(DebugLogHeader)); token != "" {
+			ctx = withDebugLevel(ctx, secret, token)
+		}
+		return handler(ctx, req)
+	}
+}
+
+// StreamDebugLogInterceptor is the gRPC equivalent of DebugLogMiddleware for streaming calls
+func StreamDebugLogInterceptor(secret []byte) grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		if token := firstMetadataValue(ss.Context(), strings.ToLower(DebugLogHeader)); token != "" {
+			ss = &loggingServerStream{ServerStream: ss, ctx: withDebugLevel(ss.Context(), secret, token)}
+		}
+		return handler(srv, ss)
+	}
+}
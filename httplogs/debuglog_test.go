@@ -0,0 +1,29 @@
+package httplogs
+
+import (
+	"testing"
+	"time"
+)
+
+func TestDebugToken(t *testing.T) {
+	secret := []byte("secret")
+	now := time.Now()
+	tests := []struct {
+		name  string
+		token string
+		want  bool
+	}{
+		{"valid token", SignDebugToken(secret, now.Add(time.Hour)), true},
+		{"expired token", SignDebugToken(secret, now.Add(-time.Minute)), false},
+		{"token valid for too long", SignDebugToken(secret, now.Add(48*time.Hour)), false},
+		{"wrong secret", SignDebugToken([]byte("other"), now.Add(time.Hour)), false},
+		{"malformed token", "not-a-token", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := isValidDebugToken(secret, tt.token, now); got != tt.want {
+				t.Errorf("isValidDebugToken() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,203 @@
+package httplogs
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/carwale/golibraries/ctxutil"
+	"github.com/carwale/golibraries/gologger"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+)
+
+// UnaryAccessLoggingInterceptor is the gRPC equivalent of HTTPAccessLoggingWrapper for unary calls.
+// It logs the same access log schema and is enabled by the same consul key. Failed calls are always logged
+//
+//	grpc.NewServer(grpc.ChainUnaryInterceptor(httplogs.UnaryAccessLoggingInterceptor()))
+func UnaryAccessLoggingInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		start := time.Now()
+		ctx, requestID := ensureGRPCRequestID(ctx)
+		resp, err := handler(ctx, req)
+		logGRPCLogs(ctx, info.FullMethod, requestID, err, messageSize(req), start)
+		return resp, err
+	}
+}
+
+// StreamAccessLoggingInterceptor is the gRPC equivalent of HTTPAccessLoggingWrapper for streaming calls.
+// The request length is the total size of the messages received on the stream
+//
+//	grpc.NewServer(grpc.ChainStreamInterceptor(httplogs.StreamAccessLoggingInterceptor()))
+func StreamAccessLoggingInterceptor() grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		start := time.Now()
+		ctx, requestID := ensureGRPCRequestID(ss.Context())
+		stream := &loggingServerStream{ServerStream: ss, ctx: ctx}
+		err := handler(srv, stream)
+		logGRPCLogs(ctx, info.FullMethod, requestID, err, stream.received, start)
+		return err
+	}
+}
+
+// loggingServerStream counts the size of the received messages and carries the context with the request ID
+type loggingServerStream struct {
+	grpc.ServerStream
+	ctx      context.Context
+	received int
+}
+
+func (s *loggingServerStream) Context() context.Context {
+	return s.ctx
+}
+
+func (s *loggingServerStream) RecvMsg(m interface{}) error {
+	err := s.ServerStream.RecvMsg(m)
+	if err == nil {
+		s.received += messageSize(m)
+	}
+	return err
+}
+
+// ensureGRPCRequestID returns the context with the request ID from the x-request-id metadata or a new one
+func ensureGRPCRequestID(ctx context.Context) (context.Context, string) {
+	if id := GetRequestID(ctx); id != "" {
+		return ctx, id
+	}
+	id := firstMetadataValue(ctx, strings.ToLower(RequestIDHeader))
+	if !isValidRequestID(id) {
+		id = getTraceRootID(firstMetadataValue(ctx, "x-amzn-trace-id"))
+	}
+	ctx = WithRequestID(ctx, id)
+	if p, ok := peer.FromContext(ctx); !ok || !trustsIdentityHeaders(p.Addr.String()) {
+		return ctx, id
+	}
+	if userID := firstMetadataValue(ctx, strings.ToLower(ctxutil.UserIDHeader)); isValidRequestID(userID) && ctxutil.UserIDFrom(ctx) == "" {
+		ctx = ctxutil.WithUserID(ctx, userID)
+	}
+	if tenantID := firstMetadataValue(ctx, strings.ToLower(ctxutil.TenantIDHeader)); isValidRequestID(tenantID) && ctxutil.TenantIDFrom(ctx) == "" {
+		ctx = ctxutil.WithTenantID(ctx, tenantID)
+	}
+	return ctx, id
+}
+
+// UnaryClientPropagationInterceptor adds the request, user and tenant IDs of the context to the metadata
+// of every outgoing unary call
+//
+//	grpc.Dial(target, grpc.WithChainUnaryInterceptor(httplogs.UnaryClientPropagationInterceptor()))
+func UnaryClientPropagationInterceptor() grpc.UnaryClientInterceptor {
+	return func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		return invoker(OutgoingContext(ctx), method, req, reply, cc, opts...)
+	}
+}
+
+// OutgoingContext returns a copy of ctx with the request, user and tenant IDs of the context in the outgoing
+// gRPC metadata so that they are propagated to the called service
+func OutgoingContext(ctx context.Context) context.Context {
+	var pairs []string
+	if id := ctxutil.RequestIDFrom(ctx); id != "" {
+		pairs = append(pairs, strings.ToLower(ctxutil.RequestIDHeader), id)
+	}
+	if id := ctxutil.UserIDFrom(ctx); id != "" {
+		pairs = append(pairs, strings.ToLower(ctxutil.UserIDHeader), id)
+	}
+	if id := ctxutil.TenantIDFrom(ctx); id != "" {
+		pairs = append(pairs, strings.ToLower(ctxutil.TenantIDHeader), id)
+	}
+	if len(pairs) == 0 {
+		return ctx
+	}
+	return metadata.AppendToOutgoingContext(ctx, pairs...)
+}
+
+func firstMetadataValue(ctx context.Context, key string) string {
+	md, ok := metadata.FromIncomingContext(ctx)
+	if !ok {
+		return ""
+	}
+	if values := md.Get(key); len(values) > 0 {
+		return values[0]
+	}
+	return ""
+}
+
+func messageSize(m interface{}) int {
+	if message, ok := m.(proto.Message); ok {
+		return proto.Size(message)
+	}
+	return 0
+}
+
+func logGRPCLogs(ctx context.Context, method string, requestID string, err error, size int, start time.Time) {
+	code := status.Code(err)
+	// Codes other than OK are logged always like http status codes >= 400
+	if err == nil && !isAccessLogEnabled() {
+		return
+	}
+
+	remoteAddr := ""
+	if p, ok := peer.FromContext(ctx); ok && p.Addr != nil {
+		remoteAddr = p.Addr.String()
+	}
+	var forwardedFor []string
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		forwardedFor = md.Get("x-forwarded-for")
+	}
+	clientIP := _gLogConfig.ipResolver.ClientIPFromForwarded(remoteAddr, forwardedFor...)
+	grpcLog := []gologger.Pair{
+		{Key: "time_iso8601", Value: time.Now().Format(time.RFC3339)},
+		{Key: "proxyUpstreamName", Value: _gLogConfig.serviceName},
+		{Key: "upstreamStatus", Value: strconv.Itoa(httpStatusFromCode(code))},
+		{Key: "upstream", Value: clientIP},
+		{Key: "request_method", Value: "POST"},
+		{Key: "request_uri", Value: firstMetadataValue(ctx, ":authority") + method},
+		{Key: "status", Value: strconv.Itoa(httpStatusFromCode(code))},
+		{Key: "grpc_code", Value: code.String()},
+		{Key: "request_length", Value: strconv.Itoa(size)},
+		{Key: "http_user_agent", Value: firstMetadataValue(ctx, "user-agent")},
+		{Key: "remote_addr", Value: remoteAddr},
+		{Key: "http_referer", Value: ""},
+		{Key: "server_protocol", Value: "grpc"},
+		{Key: "requestuid", Value: requestID},
+		{Key: "request_time", Value: formatSeconds(time.Since(start))},
+	}
+	printAccessLog(grpcLog)
+}
+
+// httpStatusFromCode returns the http status of a gRPC code, as mapped by grpc-gateway, so that the status fields
+// of the gRPC and http access logs can be queried together. The gRPC code is logged in grpc_code
+func httpStatusFromCode(code codes.Code) int {
+	switch code {
+	case codes.OK:
+		return http.StatusOK
+	case codes.Canceled:
+		// the nginx status of a request closed by the client
+		return 499
+	case codes.InvalidArgument, codes.FailedPrecondition, codes.OutOfRange:
+		return http.StatusBadRequest
+	case codes.DeadlineExceeded:
+		return http.StatusGatewayTimeout
+	case codes.NotFound:
+		return http.StatusNotFound
+	case codes.AlreadyExists, codes.Aborted:
+		return http.StatusConflict
+	case codes.PermissionDenied:
+		return http.StatusForbidden
+	case codes.Unauthenticated:
+		return http.StatusUnauthorized
+	case codes.ResourceExhausted:
+		return http.StatusTooManyRequests
+	case codes.Unimplemented:
+		return http.StatusNotImplemented
+	case codes.Unavailable:
+		return http.StatusServiceUnavailable
+	default:
+		return http.StatusInternalServerError
+	}
+}
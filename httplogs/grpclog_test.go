@@ -0,0 +1,190 @@
+package httplogs
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+// lockedBuffer collects the access logs printed by the server goroutines
+type lockedBuffer struct {
+	lock   sync.Mutex
+	buffer bytes.Buffer
+}
+
+func (b *lockedBuffer) Write(p []byte) (int, error) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	return b.buffer.Write(p)
+}
+
+// logs returns the access logs printed so far
+func (b *lockedBuffer) logs(t *testing.T) []map[string]string {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	var logs []map[string]string
+	for _, line := range strings.Split(strings.TrimSpace(b.buffer.String()), "\n") {
+		if line == "" {
+			continue
+		}
+		var log map[string]string
+		if err := json.Unmarshal([]byte(line), &log); err != nil {
+			t.Fatalf("access log %q is not json: %v", line, err)
+		}
+		logs = append(logs, log)
+	}
+	return logs
+}
+
+// captureAccessLogs configures the access logs of the service, disabled as the consul key is not set,
+// and collects them until the test ends
+func captureAccessLogs(t *testing.T) *lockedBuffer {
+	output, config := accessLogOutput, _gLogConfig
+	t.Cleanup(func() { accessLogOutput, _gLogConfig = output, config })
+	buffer := &lockedBuffer{}
+	accessLogOutput = buffer
+	_gLogConfig = setDefaultConfig("stocks")
+	return buffer
+}
+
+// echoService has a unary method failing for the "fail" request and a client streaming method which
+// reads all the messages and fails
+var echoService = grpc.ServiceDesc{
+	ServiceName: "test.Echo",
+	HandlerType: (*interface{})(nil),
+	Methods: []grpc.MethodDesc{{
+		MethodName: "Unary",
+		Handler: func(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+			req := &wrapperspb.StringValue{}
+			if err := dec(req); err != nil {
+				return nil, err
+			}
+			handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+				if req.(*wrapperspb.StringValue).Value == "fail" {
+					return nil, status.Error(codes.NotFound, "stock not found")
+				}
+				return req, nil
+			}
+			return interceptor(ctx, req, &grpc.UnaryServerInfo{FullMethod: "/test.Echo/Unary"}, handler)
+		},
+	}},
+	Streams: []grpc.StreamDesc{{
+		StreamName:    "Stream",
+		ClientStreams: true,
+		Handler: func(srv interface{}, stream grpc.ServerStream) error {
+			for {
+				if err := stream.RecvMsg(&wrapperspb.StringValue{}); err != nil {
+					return status.Error(codes.InvalidArgument, "invalid stream")
+				}
+			}
+		},
+	}},
+}
+
+// newEchoClient returns a connection to an echo server with the access logging interceptors
+func newEchoClient(t *testing.T) *grpc.ClientConn {
+	listener := bufconn.Listen(1 << 20)
+	server := grpc.NewServer(
+		grpc.ChainUnaryInterceptor(UnaryAccessLoggingInterceptor()),
+		grpc.ChainStreamInterceptor(StreamAccessLoggingInterceptor()))
+	server.RegisterService(&echoService, nil)
+	go server.Serve(listener)
+	t.Cleanup(server.Stop)
+
+	conn, err := grpc.DialContext(context.Background(), "bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return listener.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return conn
+}
+
+func TestUnaryAccessLoggingInterceptor(t *testing.T) {
+	buffer := captureAccessLogs(t)
+	conn := newEchoClient(t)
+	ctx := metadata.AppendToOutgoingContext(context.Background(), "x-request-id", "req-1")
+
+	// Successful calls are skipped while the access logs are not enabled with the monitoring key
+	if err := conn.Invoke(ctx, "/test.Echo/Unary", wrapperspb.String("ok"), &wrapperspb.StringValue{}); err != nil {
+		t.Fatal(err)
+	}
+	if logs := buffer.logs(t); len(logs) != 0 {
+		t.Fatalf("logs = %v, want the successful call to be skipped", logs)
+	}
+
+	req := wrapperspb.String("fail")
+	err := conn.Invoke(ctx, "/test.Echo/Unary", req, &wrapperspb.StringValue{})
+	if status.Code(err) != codes.NotFound {
+		t.Fatalf("Invoke() error = %v, want NotFound", err)
+	}
+	logs := buffer.logs(t)
+	if len(logs) != 1 {
+		t.Fatalf("logs = %v, want the failed call", logs)
+	}
+	want := map[string]string{
+		"status":          "404",
+		"upstreamStatus":  "404",
+		"grpc_code":       "NotFound",
+		"request_length":  strconv.Itoa(proto.Size(req)),
+		"requestuid":      "req-1",
+		"server_protocol": "grpc",
+	}
+	for key, value := range want {
+		if logs[0][key] != value {
+			t.Errorf("%s = %q, want %q", key, logs[0][key], value)
+		}
+	}
+	if !strings.HasSuffix(logs[0]["request_uri"], "/test.Echo/Unary") {
+		t.Errorf("request_uri = %q, want the method", logs[0]["request_uri"])
+	}
+}
+
+func TestStreamAccessLoggingInterceptor(t *testing.T) {
+	buffer := captureAccessLogs(t)
+	conn := newEchoClient(t)
+
+	stream, err := conn.NewStream(context.Background(), &echoService.Streams[0], "/test.Echo/Stream")
+	if err != nil {
+		t.Fatal(err)
+	}
+	messages := []*wrapperspb.StringValue{wrapperspb.String("stock-1"), wrapperspb.String("stock-22")}
+	for _, message := range messages {
+		if err := stream.SendMsg(message); err != nil {
+			t.Fatal(err)
+		}
+	}
+	stream.CloseSend()
+	if err := stream.RecvMsg(&wrapperspb.StringValue{}); status.Code(err) != codes.InvalidArgument {
+		t.Fatalf("RecvMsg() error = %v, want InvalidArgument", err)
+	}
+
+	logs := buffer.logs(t)
+	if len(logs) != 1 {
+		t.Fatalf("logs = %v, want the failed stream", logs)
+	}
+	if want := strconv.Itoa(proto.Size(messages[0]) + proto.Size(messages[1])); logs[0]["request_length"] != want {
+		t.Errorf("request_length = %q, want %q", logs[0]["request_length"], want)
+	}
+	if logs[0]["status"] != "400" || logs[0]["grpc_code"] != "InvalidArgument" {
+		t.Errorf("status = %q and grpc_code = %q, want 400 and InvalidArgument", logs[0]["status"], logs[0]["grpc_code"])
+	}
+	if logs[0]["requestuid"] == "" {
+		t.Error("requestuid is empty, want a generated request id")
+	}
+}
@@ -3,24 +3,38 @@ package httplogs
 import (
 	"bytes"
 	"fmt"
+	"io"
 	"net/http"
+	"os"
 
 	"strconv"
 	"time"
 
 	objConsulAgent "github.com/carwale/golibraries/consulagent"
+	"github.com/carwale/golibraries/featureflags"
 	"github.com/carwale/golibraries/gologger"
+	"github.com/carwale/golibraries/goutilities"
+	"github.com/carwale/golibraries/httputil"
+	"github.com/carwale/golibraries/lokilogs"
 )
 
 var _gLogConfig *GlobalParameters
 
+// accessLogOutput is where the access logs are printed
+var accessLogOutput io.Writer = os.Stdout
+
 // GlobalParameters is the class used to store global variables
 type GlobalParameters struct {
-	consulAgent            *objConsulAgent.ConsulAgent
-	serviceLogger          *gologger.CustomLogger
-	serviceName            string
-	consulIP               string
-	isMonitoringLogEnabled bool
+	consulAgent     *objConsulAgent.ConsulAgent
+	serviceLogger   gologger.ILogger
+	serviceName     string
+	consulIP        string
+	accessLogToggle *objConsulAgent.ExpiryToggle
+	featureFlags    *featureflags.Client
+	accessLogFlag   string
+	lokiClient      *lokilogs.Client
+	ipResolver      *httputil.IPResolver
+	identityProxies *goutilities.CIDRList
 }
 
 // Options sets a variable of GlobalParameters
@@ -29,6 +43,10 @@ type Options func(lb *GlobalParameters)
 // HTTPAccessLoggingWrapper is wrapper to enable access logs
 func HTTPAccessLoggingWrapper(h http.Handler) http.Handler {
 	loggingFn := func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		r, _ = ensureRequestID(r)
+		ctx, timing := withUpstreamTiming(r.Context())
+		r = r.WithContext(ctx)
 		lrw := httploggingResponseWriter{
 			ResponseWriter: w,
 			rData: &responseData{
@@ -38,7 +56,7 @@ func HTTPAccessLoggingWrapper(h http.Handler) http.Handler {
 		}
 
 		h.ServeHTTP(&lrw, r) // inject our implementation of http.ResponseWriter
-		logHTTPLogs(r, lrw.rData.status, lrw.rData.size)
+		logHTTPLogs(r, lrw.rData, start, timing)
 	}
 	return http.HandlerFunc(loggingFn)
 }
@@ -57,7 +75,7 @@ func InitLogging(serviceName string, options ...Options) {
 }
 
 // SetLogger (mandatory) parameter in order to configure logger
-func SetLogger(customLogger *gologger.CustomLogger) Options {
+func SetLogger(customLogger gologger.ILogger) Options {
 	return func(lb *GlobalParameters) { lb.serviceLogger = customLogger }
 }
 
@@ -67,10 +85,51 @@ func SetConsulIP(consultIP string) Options {
 	return func(al *GlobalParameters) { al.consulIP = consultIP }
 }
 
+// SetFeatureFlags enables the access logs with the bool flag instead of the end time stored in
+// the Monitoring/<service>/access_logs consul key. Use the expires field of the flag to turn the logs off automatically
+func SetFeatureFlags(flags *featureflags.Client, flagName string) Options {
+	return func(al *GlobalParameters) {
+		al.featureFlags = flags
+		al.accessLogFlag = flagName
+	}
+}
+
+// SetLokiClient pushes the access logs to Loki in addition to printing them.
+// Configure the client with StatusClassLabel("status") to get a status_class label
+func SetLokiClient(client *lokilogs.Client) Options {
+	return func(al *GlobalParameters) { al.lokiClient = client }
+}
+
+// SetTrustedProxies sets the cidrs of the proxies whose X-Forwarded-For headers are used to find the
+// ip address of the client. Defaults to httputil.PrivateCIDRs. It panics if a cidr is invalid
+func SetTrustedProxies(cidrs ...string) Options {
+	resolver, err := httputil.NewIPResolver(cidrs...)
+	if err != nil {
+		panic(err)
+	}
+	return func(al *GlobalParameters) { al.ipResolver = resolver }
+}
+
+// SetTrustedIdentityHeaders imports the X-User-ID and X-Tenant-ID headers, or gRPC metadata, into the context
+// of the requests received from the cidrs, e.g. an authenticating gateway, so that they are available through
+// ctxutil.UserIDFrom and ctxutil.TenantIDFrom and logged. The headers of other callers are ignored, as anyone can
+// send them. By default they are never imported. It panics if a cidr is invalid
+func SetTrustedIdentityHeaders(cidrs ...string) Options {
+	trusted := goutilities.MustParseCIDRList(cidrs...)
+	return func(al *GlobalParameters) { al.identityProxies = trusted }
+}
+
+// trustsIdentityHeaders returns true if the user and tenant IDs sent by the peer at remoteAddr can be imported
+func trustsIdentityHeaders(remoteAddr string) bool {
+	return _gLogConfig != nil && _gLogConfig.identityProxies.Contains(remoteAddr)
+}
+
 func setDefaultConfig(serviceName string) *GlobalParameters {
+	resolver, _ := httputil.NewIPResolver(httputil.PrivateCIDRs...)
 	return &GlobalParameters{
 		consulIP:    "127.0.0.1:8500",
 		serviceName: serviceName,
+		ipResolver:  resolver,
 	}
 }
 
@@ -81,63 +140,60 @@ func setBasicConfig(serviceName string) {
 		objConsulAgent.Logger(_gLogConfig.serviceLogger),
 	)
 
-	monitoringKey := getMonitoringKey(serviceName)
-	go checkHTTPLogStatus(monitoringKey)
+	if _gLogConfig.featureFlags != nil {
+		return
+	}
+	_gLogConfig.accessLogToggle = objConsulAgent.NewExpiryToggle(_gLogConfig.consulAgent, getMonitoringKey(serviceName), 5*time.Minute)
 }
 
-// infinite loop checking the key 'access_logs'
-func checkHTTPLogStatus(key string) {
-	for {
-		_gLogConfig.serviceLogger.LogDebug("The value of access log for " + _gLogConfig.serviceName + " is:" + strconv.FormatBool(_gLogConfig.isMonitoringLogEnabled))
-		time.Sleep(5 * time.Minute)
-
-		// Monitoring key considered here
-		monitoringLoggerTime := getValueFromConsulByKey(key)
-		if monitoringLoggerTime == "" {
-			_gLogConfig.isMonitoringLogEnabled = false
-			continue
-		}
-
-		loggerTime, err := time.Parse("01/02/2006 15:04:05", monitoringLoggerTime)
-		if err != nil {
-			_gLogConfig.isMonitoringLogEnabled = false
-			continue
-		}
-
-		if loggerTime.Before(time.Now()) {
-			_gLogConfig.isMonitoringLogEnabled = false
-			continue
-		}
-
-		_gLogConfig.isMonitoringLogEnabled = true
+// isAccessLogEnabled returns true if the access logs of successful requests are enabled
+func isAccessLogEnabled() bool {
+	if _gLogConfig.featureFlags != nil {
+		return _gLogConfig.featureFlags.Bool(_gLogConfig.accessLogFlag, false)
 	}
+	return _gLogConfig.accessLogToggle != nil && _gLogConfig.accessLogToggle.Enabled()
 }
 
-func logHTTPLogs(r *http.Request, statusCode int, size int) {
-	if !_gLogConfig.isMonitoringLogEnabled && statusCode < 400 {
+func logHTTPLogs(r *http.Request, rData *responseData, start time.Time, timing *upstreamTiming) {
+	statusCode, size := rData.status, rData.size
+	if statusCode < 400 && !isAccessLogEnabled() {
 		return
 	}
 
-	amznTraceID := r.Header.Get("X-Amzn-Trace-Id")
 	httpLog := []gologger.Pair{
 		{Key: "time_iso8601", Value: time.Now().Format(time.RFC3339)},
 		{Key: "proxyUpstreamName", Value: _gLogConfig.serviceName},
 		{Key: "upstreamStatus", Value: fmt.Sprintf("%d", statusCode)},
-		{Key: "upstream", Value: getIP(r)},
+		{Key: "upstream", Value: _gLogConfig.ipResolver.ClientIP(r)},
 		{Key: "request_method", Value: r.Method},
-		{Key: "request_uri", Value: getAbsoluteURL(r)},
+		{Key: "request_uri", Value: httputil.RequestURL(r)},
 		{Key: "status", Value: fmt.Sprintf("%d", statusCode)},
 		{Key: "request_length", Value: fmt.Sprintf("%d", size)},
 		{Key: "http_user_agent", Value: r.UserAgent()},
 		{Key: "remote_addr", Value: r.RemoteAddr},
 		{Key: "http_referer", Value: r.Referer()},
 		{Key: "server_protocol", Value: r.Proto},
-		{Key: "requestuid", Value: getTraceRootID(amznTraceID)},
+		{Key: "requestuid", Value: GetRequestID(r.Context())},
+		{Key: "request_time", Value: formatSeconds(time.Since(start))},
+	}
+	if !rData.firstByte.IsZero() {
+		httpLog = append(httpLog, gologger.Pair{Key: "time_to_first_byte", Value: formatSeconds(rData.firstByte.Sub(start))})
+	}
+	if calls, responseTime, connectTime := timing.get(); calls > 0 {
+		httpLog = append(httpLog,
+			gologger.Pair{Key: "upstream_calls", Value: strconv.Itoa(calls)},
+			gologger.Pair{Key: "upstream_response_time", Value: formatSeconds(responseTime)},
+			gologger.Pair{Key: "upstream_connect_time", Value: formatSeconds(connectTime)})
 	}
 
+	printAccessLog(httpLog)
+}
+
+// printAccessLog prints the access log as a json object on stdout
+func printAccessLog(accessLog []gologger.Pair) {
 	var buffer bytes.Buffer
 	buffer.WriteString("{")
-	for index, pair := range httpLog {
+	for index, pair := range accessLog {
 		if index == 0 {
 			buffer.WriteString(fmt.Sprintf("%q:%q", pair.Key, pair.Value))
 		} else {
@@ -146,5 +202,8 @@ func logHTTPLogs(r *http.Request, statusCode int, size int) {
 	}
 	buffer.WriteString("}")
 
-	fmt.Println(buffer.String())
+	fmt.Fprintln(accessLogOutput, buffer.String())
+	if _gLogConfig != nil && _gLogConfig.lokiClient != nil {
+		_gLogConfig.lokiClient.LogLine(buffer.String(), accessLog)
+	}
 }
@@ -2,12 +2,14 @@ package httplogs
 
 import (
 	"net/http"
+	"time"
 )
 
 type (
 	responseData struct {
-		status int
-		size   int
+		status    int
+		size      int
+		firstByte time.Time
 	}
 
 	httploggingResponseWriter struct {
@@ -17,12 +19,22 @@ type (
 )
 
 func (r *httploggingResponseWriter) Write(b []byte) (int, error) {
+	if r.rData.firstByte.IsZero() {
+		r.rData.firstByte = time.Now()
+	}
+	if r.rData.status == 0 {
+		// WriteHeader is called implicitly with 200 by the first Write
+		r.rData.status = http.StatusOK
+	}
 	size, err := r.ResponseWriter.Write(b)
 	r.rData.size += size
 	return size, err
 }
 
 func (r *httploggingResponseWriter) WriteHeader(statusCode int) {
+	if r.rData.firstByte.IsZero() {
+		r.rData.firstByte = time.Now()
+	}
 	r.ResponseWriter.WriteHeader(statusCode)
 	r.rData.status = statusCode
 }
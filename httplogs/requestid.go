@@ -0,0 +1,80 @@
+package httplogs
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/carwale/golibraries/ctxutil"
+	"github.com/carwale/golibraries/gologger"
+)
+
+// RequestIDHeader is the header used to receive and return the request ID
+const RequestIDHeader = ctxutil.RequestIDHeader
+
+const maxRequestIDLength = 128
+
+// RequestIDMiddleware gives every request an ID. The ID in the X-Request-ID header of the request is used if
+// it is valid, otherwise the root of the X-Amzn-Trace-Id header or a new uuid is used.
+// The ID is set in the X-Request-ID header of the response, is available to the handlers through
+// GetRequestID and is logged as requestuid in the access logs. The X-User-ID and X-Tenant-ID headers are
+// imported into the context only from the proxies trusted with SetTrustedIdentityHeaders
+func RequestIDMiddleware(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r, id := ensureRequestID(r)
+		w.Header().Set(RequestIDHeader, id)
+		h.ServeHTTP(w, r)
+	})
+}
+
+// GetRequestID returns the request ID from the context of a request served by RequestIDMiddleware
+// or HTTPAccessLoggingWrapper. It returns an empty string if there is no request ID
+func GetRequestID(ctx context.Context) string {
+	return ctxutil.RequestIDFrom(ctx)
+}
+
+// WithRequestID returns a copy of ctx with the request ID. It can be used to propagate the ID to background work
+func WithRequestID(ctx context.Context, id string) context.Context {
+	return ctxutil.WithRequestID(ctx, id)
+}
+
+// RequestIDPair returns the request ID of the context as a pair to be added to application logs
+//
+//	logger.LogErrorMessage("Could not get stock", err, httplogs.RequestIDPair(r.Context()))
+func RequestIDPair(ctx context.Context) gologger.Pair {
+	return gologger.Pair{Key: ctxutil.RequestIDLogKey, Value: GetRequestID(ctx)}
+}
+
+// ensureRequestID returns the request with a request ID in its context and the ID
+func ensureRequestID(r *http.Request) (*http.Request, string) {
+	if id := GetRequestID(r.Context()); id != "" {
+		return r, id
+	}
+	id := r.Header.Get(RequestIDHeader)
+	if !isValidRequestID(id) {
+		id = getTraceRootID(r.Header.Get("X-Amzn-Trace-Id"))
+	}
+	ctx := WithRequestID(r.Context(), id)
+	if !trustsIdentityHeaders(r.RemoteAddr) {
+		return r.WithContext(ctx), id
+	}
+	if userID := r.Header.Get(ctxutil.UserIDHeader); isValidRequestID(userID) && ctxutil.UserIDFrom(ctx) == "" {
+		ctx = ctxutil.WithUserID(ctx, userID)
+	}
+	if tenantID := r.Header.Get(ctxutil.TenantIDHeader); isValidRequestID(tenantID) && ctxutil.TenantIDFrom(ctx) == "" {
+		ctx = ctxutil.WithTenantID(ctx, tenantID)
+	}
+	return r.WithContext(ctx), id
+}
+
+// isValidRequestID allows only short printable ascii IDs so that the incoming header cannot be used to inject into the logs
+func isValidRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLength {
+		return false
+	}
+	for i := 0; i < len(id); i++ {
+		if id[i] < 0x21 || id[i] > 0x7e {
+			return false
+		}
+	}
+	return true
+}
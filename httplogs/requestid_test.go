@@ -0,0 +1,77 @@
+package httplogs
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/carwale/golibraries/ctxutil"
+)
+
+func TestRequestIDMiddleware(t *testing.T) {
+	tests := []struct {
+		name     string
+		incoming string
+		want     string
+	}{
+		{"honors incoming id", "abc-123", "abc-123"},
+		{"generates id when missing", "", ""},
+		{"replaces invalid id", "bad id\n" + strings.Repeat("x", 10), ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got string
+			handler := RequestIDMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				got = GetRequestID(r.Context())
+			}))
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			if tt.incoming != "" {
+				req.Header.Set(RequestIDHeader, tt.incoming)
+			}
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+
+			if got == "" || rec.Header().Get(RequestIDHeader) != got {
+				t.Fatalf("request id = %q, response header = %q", got, rec.Header().Get(RequestIDHeader))
+			}
+			if tt.want != "" && got != tt.want {
+				t.Errorf("request id = %q, want %q", got, tt.want)
+			}
+			if tt.want == "" && got == tt.incoming {
+				t.Errorf("request id = %q, want a new id", got)
+			}
+		})
+	}
+}
+
+func TestIdentityHeadersOnlyFromTrustedProxies(t *testing.T) {
+	config := _gLogConfig
+	t.Cleanup(func() { _gLogConfig = config })
+	_gLogConfig = setDefaultConfig("stocks")
+	SetTrustedIdentityHeaders("10.0.0.0/8")(_gLogConfig)
+
+	tests := []struct {
+		name       string
+		remoteAddr string
+		want       string
+	}{
+		{"imports from a trusted proxy", "10.1.2.3:4000", "user-1"},
+		{"ignores other callers", "203.0.113.7:4000", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			req.RemoteAddr = tt.remoteAddr
+			req.Header.Set(ctxutil.UserIDHeader, "user-1")
+			req.Header.Set(ctxutil.TenantIDHeader, "tenant-1")
+			req, _ = ensureRequestID(req)
+			if got := ctxutil.UserIDFrom(req.Context()); got != tt.want {
+				t.Errorf("user id = %q, want %q", got, tt.want)
+			}
+			if got := ctxutil.TenantIDFrom(req.Context()); (got != "") != (tt.want != "") {
+				t.Errorf("tenant id = %q", got)
+			}
+		})
+	}
+}
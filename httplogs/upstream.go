@@ -0,0 +1,108 @@
+package httplogs
+
+import (
+	"context"
+	"crypto/tls"
+	"net/http"
+	"net/http/httptrace"
+	"strconv"
+	"sync"
+	"time"
+)
+
+type upstreamTimingKey struct{}
+
+// upstreamTiming accumulates the time spent in outgoing calls made while serving a request
+type upstreamTiming struct {
+	lock         sync.Mutex
+	calls        int
+	responseTime time.Duration
+	connectTime  time.Duration
+}
+
+func (t *upstreamTiming) add(responseTime, connectTime time.Duration) {
+	t.lock.Lock()
+	t.calls++
+	t.responseTime += responseTime
+	t.connectTime += connectTime
+	t.lock.Unlock()
+}
+
+func (t *upstreamTiming) get() (calls int, responseTime, connectTime time.Duration) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	return t.calls, t.responseTime, t.connectTime
+}
+
+func withUpstreamTiming(ctx context.Context) (context.Context, *upstreamTiming) {
+	timing := &upstreamTiming{}
+	return context.WithValue(ctx, upstreamTimingKey{}, timing), timing
+}
+
+type upstreamTransport struct {
+	base http.RoundTripper
+}
+
+// NewUpstreamTransport returns a transport which measures the outgoing calls made with it.
+// If the request context is the context of a request served by HTTPAccessLoggingWrapper, the time taken by the
+// call till the response headers are received and the time taken to connect are added to the upstream_response_time
+// and upstream_connect_time fields of its access log. This separates the latency of the upstreams from the latency of the app.
+// If base is nil http.DefaultTransport is used
+//
+//	client := &http.Client{Transport: httplogs.NewUpstreamTransport(nil)}
+//	req, _ := http.NewRequestWithContext(r.Context(), http.MethodGet, url, nil)
+//	resp, err := client.Do(req)
+func NewUpstreamTransport(base http.RoundTripper) http.RoundTripper {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	return &upstreamTransport{base: base}
+}
+
+// RoundTrip executes the request with a client trace to measure the timings
+func (t *upstreamTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	timing, ok := req.Context().Value(upstreamTimingKey{}).(*upstreamTiming)
+	if !ok {
+		return t.base.RoundTrip(req)
+	}
+	var lock sync.Mutex
+	var connectStart, tlsStart time.Time
+	var connectTime time.Duration
+	trace := &httptrace.ClientTrace{
+		ConnectStart: func(network, addr string) {
+			lock.Lock()
+			connectStart = time.Now()
+			lock.Unlock()
+		},
+		ConnectDone: func(network, addr string, err error) {
+			lock.Lock()
+			if !connectStart.IsZero() {
+				connectTime += time.Since(connectStart)
+			}
+			lock.Unlock()
+		},
+		TLSHandshakeStart: func() {
+			lock.Lock()
+			tlsStart = time.Now()
+			lock.Unlock()
+		},
+		TLSHandshakeDone: func(tls.ConnectionState, error) {
+			lock.Lock()
+			if !tlsStart.IsZero() {
+				connectTime += time.Since(tlsStart)
+			}
+			lock.Unlock()
+		},
+	}
+	start := time.Now()
+	resp, err := t.base.RoundTrip(req.WithContext(httptrace.WithClientTrace(req.Context(), trace)))
+	lock.Lock()
+	timing.add(time.Since(start), connectTime)
+	lock.Unlock()
+	return resp, err
+}
+
+// formatSeconds formats the duration in seconds with millisecond resolution like nginx
+func formatSeconds(d time.Duration) string {
+	return strconv.FormatFloat(d.Seconds(), 'f', 3, 64)
+}
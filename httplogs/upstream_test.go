@@ -0,0 +1,68 @@
+package httplogs
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestUpstreamTransportLogsUpstreamTimes(t *testing.T) {
+	buffer := captureAccessLogs(t)
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(50 * time.Millisecond)
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer upstream.Close()
+	client := &http.Client{Transport: NewUpstreamTransport(nil)}
+
+	handler := HTTPAccessLoggingWrapper(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		for i := 0; i < 2; i++ {
+			req, _ := http.NewRequestWithContext(r.Context(), http.MethodGet, upstream.URL, nil)
+			resp, err := client.Do(req)
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			resp.Body.Close()
+		}
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/v1/stocks", nil))
+
+	logs := buffer.logs(t)
+	if len(logs) != 1 {
+		t.Fatalf("logs = %v, want the failed request", logs)
+	}
+	log := logs[0]
+	if log["status"] != "502" || log["upstreamStatus"] != "502" || log["upstream_calls"] != "2" {
+		t.Errorf("status = %q, upstreamStatus = %q, upstream_calls = %q, want 502, 502 and 2",
+			log["status"], log["upstreamStatus"], log["upstream_calls"])
+	}
+	responseTime, err := strconv.ParseFloat(log["upstream_response_time"], 64)
+	if err != nil || responseTime < 0.1 {
+		t.Errorf("upstream_response_time = %q, want at least the 2 calls of 50ms", log["upstream_response_time"])
+	}
+	requestTime, _ := strconv.ParseFloat(log["request_time"], 64)
+	if requestTime < responseTime {
+		t.Errorf("request_time = %v, want at least the upstream_response_time %v", requestTime, responseTime)
+	}
+	if _, err := strconv.ParseFloat(log["upstream_connect_time"], 64); err != nil {
+		t.Errorf("upstream_connect_time = %q, want seconds", log["upstream_connect_time"])
+	}
+}
+
+func TestUpstreamTransportWithoutAccessLog(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer upstream.Close()
+	client := &http.Client{Transport: NewUpstreamTransport(nil)}
+	resp, err := client.Get(upstream.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("status = %d, want 200", resp.StatusCode)
+	}
+}
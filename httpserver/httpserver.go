@@ -0,0 +1,402 @@
+// Package httpserver wraps http.Server with the timeouts, graceful shutdown and operational endpoints
+// every http service needs, like the healthcheck package does for grpc services.
+//
+// The handler of the service is served on / next to /healthz, /metrics, /version and the pprof handlers under /debug/pprof/.
+// On SIGINT or SIGTERM the server fails /healthz, waits for the drain delay so that the load balancer stops
+// sending requests, and then waits for the in flight requests before it returns
+//
+//	server := httpserver.NewServer(":8080", router,
+//		httpserver.SetReadiness(readiness),
+//		httpserver.SetDrainDelay(5*time.Second))
+//	if err := server.Run(context.Background()); err != nil {
+//		logger.LogError("http server stopped", err)
+//	}
+package httpserver
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/pprof"
+	"os"
+	"os/signal"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"github.com/carwale/golibraries/buildinfo"
+	"github.com/carwale/golibraries/diagnostics"
+	"github.com/carwale/golibraries/gologger"
+	"github.com/carwale/golibraries/healthcheck"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// ErrServerStarted is returned by Run if the server was already started
+var ErrServerStarted = errors.New("http server already started")
+
+const (
+	connectionsMetricID   = "HTTPSERVER-CONNECTIONS"
+	drainDurationMetricID = "HTTPSERVER-DRAIN-DURATION"
+	droppedMetricID       = "HTTPSERVER-DRAIN-DROPPED"
+)
+
+var httpserverMetrics = gologger.NewMetricSet(func(logger gologger.ILogger) map[string]gologger.IMetricVec {
+	return map[string]gologger.IMetricVec{
+		connectionsMetricID: gologger.NewGaugeMetric(prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "httpserver_open_connections",
+				Help: "Number of open connections of the http server",
+			},
+			[]string{"Server"},
+		), logger),
+		drainDurationMetricID: gologger.NewHistogramMetric(prometheus.NewHistogramVec(
+			prometheus.HistogramOpts{
+				Name:    "httpserver_drain_duration_milliseconds",
+				Help:    "Time taken by the graceful shutdown of the http server, including the drain delay",
+				Buckets: prometheus.ExponentialBuckets(10, 2, 14),
+			},
+			[]string{"Server"},
+		), logger),
+		droppedMetricID: gologger.NewCounterMetric(prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "httpserver_drain_dropped_connections_total",
+				Help: "Number of connections closed because they were still open at the end of the shutdown timeout",
+			},
+			[]string{"Server"},
+		), logger),
+	}
+})
+
+// Server is a http server with graceful shutdown
+type Server struct {
+	name              string
+	address           string
+	handler           http.Handler
+	readHeaderTimeout time.Duration
+	readTimeout       time.Duration
+	writeTimeout      time.Duration
+	idleTimeout       time.Duration
+	shutdownTimeout   time.Duration
+	drainDelay        time.Duration
+	handleSignals     bool
+	tlsConfig         *tls.Config
+	certFile          string
+	keyFile           string
+	metrics           bool
+	pprofEnabled      func() bool
+	readiness         *healthcheck.ReadinessController
+	healthCheck       func() (bool, error)
+	logger            gologger.ILogger
+	latencyLogger     gologger.IMultiLogger
+
+	httpServer  *http.Server
+	started     int32
+	draining    int32
+	connections int64
+	listener    net.Listener
+	ready       chan struct{}
+	shutdown    sync.Once
+	shutdownErr error
+}
+
+// Option sets a parameter of the server
+type Option func(s *Server)
+
+// SetName sets the name of the server used in the logs and as the label of the metrics. Default is http
+func SetName(name string) Option {
+	return func(s *Server) { s.name = name }
+}
+
+// SetReadHeaderTimeout sets the time allowed to read the request headers. Default is 10 seconds
+func SetReadHeaderTimeout(timeout time.Duration) Option {
+	return func(s *Server) { s.readHeaderTimeout = timeout }
+}
+
+// SetReadTimeout sets the time allowed to read the whole request. Default is 30 seconds
+func SetReadTimeout(timeout time.Duration) Option {
+	return func(s *Server) { s.readTimeout = timeout }
+}
+
+// SetWriteTimeout sets the time allowed from the end of the request headers to the end of the response.
+// Default is 60 seconds, which is more than the 30 seconds of a pprof cpu profile
+func SetWriteTimeout(timeout time.Duration) Option {
+	return func(s *Server) { s.writeTimeout = timeout }
+}
+
+// SetIdleTimeout sets how long keep alive connections are kept open between requests. Default is 120 seconds
+func SetIdleTimeout(timeout time.Duration) Option {
+	return func(s *Server) { s.idleTimeout = timeout }
+}
+
+// SetShutdownTimeout sets how long the shutdown waits for the in flight requests after the drain delay.
+// The connections still open after it are closed. Default is 30 seconds
+func SetShutdownTimeout(timeout time.Duration) Option {
+	return func(s *Server) { s.shutdownTimeout = timeout }
+}
+
+// SetDrainDelay sets how long /healthz fails before the server stops accepting connections, so that the
+// kubernetes endpoints or the load balancer stop routing requests to the pod first. Default is 0
+func SetDrainDelay(delay time.Duration) Option {
+	return func(s *Server) { s.drainDelay = delay }
+}
+
+// SetHandleSignals sets whether Run shuts the server down on SIGINT and SIGTERM. Default is true.
+// Turn it off when the shutdown is driven by something else, e.g. servicediscovery.DeregisterOnShutdown
+// calling Shutdown after the service is deregistered
+func SetHandleSignals(handleSignals bool) Option {
+	return func(s *Server) { s.handleSignals = handleSignals }
+}
+
+// SetTLSCertificate serves https with the certificate and key in the files
+func SetTLSCertificate(certFile, keyFile string) Option {
+	return func(s *Server) {
+		s.certFile = certFile
+		s.keyFile = keyFile
+	}
+}
+
+// SetTLSConfig serves https with the tls config. Use it for certificates obtained with ACME, e.g. with the
+// TLSConfig of an autocert.Manager, or with a GetCertificate function that reloads rotated certificates.
+// MinVersion defaults to TLS 1.2 if it is not set
+func SetTLSConfig(config *tls.Config) Option {
+	return func(s *Server) { s.tlsConfig = config }
+}
+
+// SetMetricsEnabled sets whether the prometheus metrics are served on /metrics. Default is true
+func SetMetricsEnabled(enabled bool) Option {
+	return func(s *Server) { s.metrics = enabled }
+}
+
+// SetPprofEnabledFunc sets the function which decides whether the pprof handlers respond. It is called on every
+// request. Default is diagnostics.EnabledFromEnv, so pprof is off unless DIAGNOSTICS_ENABLED is set
+func SetPprofEnabledFunc(enabled func() bool) Option {
+	return func(s *Server) { s.pprofEnabled = enabled }
+}
+
+// SetReadiness makes /healthz respond with the states of the components of the readiness controller
+func SetReadiness(readiness *healthcheck.ReadinessController) Option {
+	return func(s *Server) { s.readiness = readiness }
+}
+
+// SetHealthCheck sets the function called by /healthz. It is called after the readiness controller, if any
+func SetHealthCheck(check func() (bool, error)) Option {
+	return func(s *Server) { s.healthCheck = check }
+}
+
+// SetLogger sets the logger of the server
+func SetLogger(logger gologger.ILogger) Option {
+	return func(s *Server) { s.logger = logger }
+}
+
+// SetLatencyLogger sets the latency logger used for the connection and drain metrics
+func SetLatencyLogger(latencyLogger gologger.IMultiLogger) Option {
+	return func(s *Server) { s.latencyLogger = latencyLogger }
+}
+
+// NewServer returns a server which serves handler on address. The server is started with Run
+func NewServer(address string, handler http.Handler, options ...Option) *Server {
+	s := &Server{
+		name:              "http",
+		address:           address,
+		handler:           handler,
+		readHeaderTimeout: 10 * time.Second,
+		readTimeout:       30 * time.Second,
+		writeTimeout:      60 * time.Second,
+		idleTimeout:       120 * time.Second,
+		shutdownTimeout:   30 * time.Second,
+		handleSignals:     true,
+		metrics:           true,
+		pprofEnabled:      diagnostics.EnabledFromEnv,
+		ready:             make(chan struct{}),
+	}
+	for _, option := range options {
+		option(s)
+	}
+	if s.handler == nil {
+		s.handler = http.NotFoundHandler()
+	}
+	if s.logger == nil {
+		s.logger = gologger.NewLogger(gologger.SetLogLevel("ERROR"))
+	}
+	if s.latencyLogger == nil {
+		s.latencyLogger = gologger.NewRateLatencyLogger(gologger.SetLogger(s.logger))
+	}
+	if s.tlsConfig == nil && s.certFile != "" {
+		s.tlsConfig = &tls.Config{}
+	}
+	if s.tlsConfig != nil && s.tlsConfig.MinVersion == 0 {
+		s.tlsConfig.MinVersion = tls.VersionTLS12
+	}
+	httpserverMetrics.AddTo(s.latencyLogger, s.logger)
+	if s.metrics {
+		buildinfo.RegisterMetric(s.latencyLogger, s.logger)
+	}
+
+	s.httpServer = &http.Server{
+		Handler:           s.Handler(),
+		ReadHeaderTimeout: s.readHeaderTimeout,
+		ReadTimeout:       s.readTimeout,
+		WriteTimeout:      s.writeTimeout,
+		IdleTimeout:       s.idleTimeout,
+		TLSConfig:         s.tlsConfig,
+		ConnState:         s.trackConnection,
+	}
+	return s
+}
+
+// Handler returns the handler of the service with the /healthz, /metrics, /version and pprof handlers
+func (s *Server) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/healthz", s.serveHealth)
+	if s.metrics {
+		mux.Handle("/metrics", promhttp.Handler())
+	}
+	mux.Handle("/version", buildinfo.Handler())
+	mux.Handle("/debug/pprof/", s.pprofOnly(http.HandlerFunc(pprof.Index)))
+	mux.Handle("/debug/pprof/cmdline", s.pprofOnly(http.HandlerFunc(pprof.Cmdline)))
+	mux.Handle("/debug/pprof/profile", s.pprofOnly(http.HandlerFunc(pprof.Profile)))
+	mux.Handle("/debug/pprof/symbol", s.pprofOnly(http.HandlerFunc(pprof.Symbol)))
+	mux.Handle("/debug/pprof/trace", s.pprofOnly(http.HandlerFunc(pprof.Trace)))
+	mux.Handle("/", s.handler)
+	return mux
+}
+
+// Run serves until ctx is done, the process receives SIGINT or SIGTERM, or Shutdown is called, and then
+// shuts the server down gracefully. It returns nil after a graceful shutdown
+func (s *Server) Run(ctx context.Context) error {
+	if !atomic.CompareAndSwapInt32(&s.started, 0, 1) {
+		return ErrServerStarted
+	}
+	listener, err := net.Listen("tcp", s.address)
+	if err != nil {
+		return err
+	}
+	if s.tlsConfig != nil {
+		if s.certFile != "" {
+			certificate, err := tls.LoadX509KeyPair(s.certFile, s.keyFile)
+			if err != nil {
+				listener.Close()
+				return err
+			}
+			s.tlsConfig.Certificates = append(s.tlsConfig.Certificates, certificate)
+		}
+		listener = tls.NewListener(listener, s.tlsConfig)
+	}
+	s.listener = listener
+	close(s.ready)
+
+	served := make(chan error, 1)
+	go func() { served <- s.httpServer.Serve(listener) }()
+	s.logger.LogInfo("HTTP server " + s.name + " listening on " + listener.Addr().String())
+
+	var signals chan os.Signal
+	if s.handleSignals {
+		signals = make(chan os.Signal, 1)
+		signal.Notify(signals, syscall.SIGINT, syscall.SIGTERM)
+		defer signal.Stop(signals)
+	}
+	select {
+	case err := <-served:
+		if err != http.ErrServerClosed {
+			return err
+		}
+		// Shutdown was called
+		return s.Shutdown(context.Background())
+	case sig := <-signals:
+		s.logger.LogWarningf("Caught signal %v. Shutting down http server %s", sig, s.name)
+	case <-ctx.Done():
+	}
+	return s.Shutdown(context.Background())
+}
+
+// Addr returns the address the server listens on. It blocks till Run has started listening
+func (s *Server) Addr() net.Addr {
+	<-s.ready
+	return s.listener.Addr()
+}
+
+// Shutdown fails /healthz, waits for the drain delay and then for the in flight requests for the shutdown
+// timeout or till ctx is done. The connections still open after that are closed.
+// Calls after the first one wait for the first shutdown and return its result
+func (s *Server) Shutdown(ctx context.Context) error {
+	s.shutdown.Do(func() {
+		start := time.Now()
+		atomic.StoreInt32(&s.draining, 1)
+		if s.drainDelay > 0 {
+			s.logger.LogInfof("Draining http server %s for %s", s.name, s.drainDelay)
+			select {
+			case <-time.After(s.drainDelay):
+			case <-ctx.Done():
+			}
+		}
+		ctx, cancel := context.WithTimeout(ctx, s.shutdownTimeout)
+		defer cancel()
+		err := s.httpServer.Shutdown(ctx)
+		if err != nil {
+			dropped := atomic.LoadInt64(&s.connections)
+			s.latencyLogger.IncVal(dropped, droppedMetricID, s.name)
+			s.logger.LogWarningf("Closing %d connections still open after the shutdown timeout of http server %s", dropped, s.name)
+			s.httpServer.Close()
+		}
+		s.latencyLogger.Toc(start, drainDurationMetricID, s.name)
+		s.shutdownErr = err
+	})
+	return s.shutdownErr
+}
+
+// Draining returns true once the shutdown has started
+func (s *Server) Draining() bool {
+	return atomic.LoadInt32(&s.draining) == 1
+}
+
+// serveHealth fails while the server drains, then checks the readiness controller and the health check
+func (s *Server) serveHealth(w http.ResponseWriter, r *http.Request) {
+	if s.Draining() {
+		http.Error(w, "draining", http.StatusServiceUnavailable)
+		return
+	}
+	if s.readiness != nil {
+		if ok, _ := s.readiness.IsReady(); !ok || s.healthCheck == nil {
+			s.readiness.ServeHTTP(w, r)
+			return
+		}
+	}
+	if s.healthCheck != nil {
+		if ok, err := s.healthCheck(); !ok {
+			message := "unhealthy"
+			if err != nil {
+				message = err.Error()
+			}
+			http.Error(w, message, http.StatusServiceUnavailable)
+			return
+		}
+	}
+	w.Write([]byte("OK"))
+}
+
+// pprofOnly responds with 404 while pprof is disabled
+func (s *Server) pprofOnly(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !s.pprofEnabled() {
+			http.NotFound(w, r)
+			return
+		}
+		h.ServeHTTP(w, r)
+	})
+}
+
+// trackConnection counts the open connections
+func (s *Server) trackConnection(conn net.Conn, state http.ConnState) {
+	switch state {
+	case http.StateNew:
+		atomic.AddInt64(&s.connections, 1)
+		s.latencyLogger.IncVal(1, connectionsMetricID, s.name)
+	case http.StateHijacked, http.StateClosed:
+		atomic.AddInt64(&s.connections, -1)
+		s.latencyLogger.SubVal(1, connectionsMetricID, s.name)
+	}
+}
@@ -0,0 +1,101 @@
+package httpserver
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/carwale/golibraries/healthcheck"
+)
+
+func get(t *testing.T, url string) (int, string) {
+	t.Helper()
+	resp, err := http.Get(url)
+	if err != nil {
+		t.Fatalf("GET %s failed: %v", url, err)
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body)
+	return resp.StatusCode, string(body)
+}
+
+func TestServerGracefulShutdown(t *testing.T) {
+	started := make(chan struct{})
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/slow" {
+			close(started)
+			time.Sleep(200 * time.Millisecond)
+		}
+		w.Write([]byte("hello"))
+	})
+	readiness := healthcheck.NewReadinessController("db")
+	s := NewServer("127.0.0.1:0", handler, SetHandleSignals(false), SetReadiness(readiness),
+		SetDrainDelay(100*time.Millisecond), SetPprofEnabledFunc(func() bool { return false }))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- s.Run(ctx) }()
+	base := "http://" + s.Addr().String()
+
+	if status, body := get(t, base+"/"); status != http.StatusOK || body != "hello" {
+		t.Fatalf("expected the handler to respond, got %d %q", status, body)
+	}
+	if status, _ := get(t, base+"/healthz"); status != http.StatusServiceUnavailable {
+		t.Fatalf("expected /healthz to fail while a component is not ready, got %d", status)
+	}
+	readiness.MarkReady("db")
+	if status, _ := get(t, base+"/healthz"); status != http.StatusOK {
+		t.Fatalf("expected /healthz to succeed, got %d", status)
+	}
+	if status, _ := get(t, base+"/debug/pprof/"); status != http.StatusNotFound {
+		t.Fatalf("expected pprof to be disabled, got %d", status)
+	}
+	if status, _ := get(t, base+"/metrics"); status != http.StatusOK {
+		t.Fatalf("expected /metrics to be served, got %d", status)
+	}
+	if status, body := get(t, base+"/version"); status != http.StatusOK || !strings.Contains(body, `"version"`) {
+		t.Fatalf("expected /version to be served, got %d %s", status, body)
+	}
+
+	slow := make(chan string, 1)
+	go func() {
+		_, body := get(t, base+"/slow")
+		slow <- body
+	}()
+	<-started
+	cancel()
+	time.Sleep(20 * time.Millisecond)
+	if !s.Draining() {
+		t.Fatal("expected the server to drain after the context is done")
+	}
+	if status, _ := get(t, base+"/healthz"); status != http.StatusServiceUnavailable {
+		t.Fatalf("expected /healthz to fail while draining, got %d", status)
+	}
+	if body := <-slow; body != "hello" {
+		t.Fatalf("expected the in flight request to complete, got %q", body)
+	}
+	if err := <-done; err != nil {
+		t.Fatalf("expected a graceful shutdown, got %v", err)
+	}
+	if _, err := http.Get(base + "/"); err == nil {
+		t.Fatal("expected the server to be closed")
+	}
+}
+
+func TestServerRunTwice(t *testing.T) {
+	s := NewServer("127.0.0.1:0", nil, SetHandleSignals(false))
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- s.Run(ctx) }()
+	s.Addr()
+	if err := s.Run(ctx); err != ErrServerStarted {
+		t.Fatalf("expected ErrServerStarted, got %v", err)
+	}
+	cancel()
+	if err := <-done; err != nil {
+		t.Fatalf("expected a graceful shutdown, got %v", err)
+	}
+}
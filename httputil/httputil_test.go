@@ -0,0 +1,80 @@
+package httputil
+
+import (
+	"crypto/tls"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestClientIP(t *testing.T) {
+	resolver, err := NewIPResolver("10.0.0.0/8")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name       string
+		remoteAddr string
+		headers    map[string][]string
+		want       string
+	}{
+		{"direct client", "203.0.113.7:5000", nil, "203.0.113.7"},
+		{"untrusted remote ignores headers", "203.0.113.7:5000", map[string][]string{"X-Forwarded-For": {"198.51.100.1"}}, "203.0.113.7"},
+		{"rightmost untrusted address", "10.0.0.2:80", map[string][]string{"X-Forwarded-For": {"1.1.1.1, 198.51.100.1, 10.0.0.5"}}, "198.51.100.1"},
+		{"multiple headers", "10.0.0.2:80", map[string][]string{"X-Forwarded-For": {"198.51.100.1", "10.0.0.5"}}, "198.51.100.1"},
+		{"invalid entries are skipped", "10.0.0.2:80", map[string][]string{"X-Forwarded-For": {"198.51.100.1, unknown"}}, "198.51.100.1"},
+		{"addresses with ports", "10.0.0.2:80", map[string][]string{"X-Forwarded-For": {"[2001:db8::1]:443"}}, "2001:db8::1"},
+		{"all trusted", "10.0.0.2:80", map[string][]string{"X-Forwarded-For": {"10.0.0.9, 10.0.0.5"}}, "10.0.0.9"},
+		{"real ip", "10.0.0.2:80", map[string][]string{"X-Real-Ip": {"198.51.100.2"}}, "198.51.100.2"},
+		{"trusted proxy without headers", "10.0.0.2:80", nil, "10.0.0.2"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+			r.RemoteAddr = tt.remoteAddr
+			for k, v := range tt.headers {
+				r.Header[k] = v
+			}
+			if got := resolver.ClientIP(r); got != tt.want {
+				t.Errorf("ClientIP() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNewIPResolverInvalidCIDR(t *testing.T) {
+	if _, err := NewIPResolver("10.0.0.0"); err == nil {
+		t.Error("NewIPResolver() should return an error for an invalid cidr")
+	}
+}
+
+func TestRedactQuery(t *testing.T) {
+	tests := map[string]string{
+		"a=1&b=2":                    "a=1&b=2",
+		"token=abc&a=1":              "token=REDACTED&a=1",
+		"API_KEY=abc&x-auth-token=d": "API_KEY=REDACTED&x-auth-token=REDACTED",
+		"monkey=1&flag&password=":    "monkey=1&flag&password=REDACTED",
+		"pass%77ord=abc":             "pass%77ord=REDACTED",
+	}
+	for query, want := range tests {
+		if got := RedactQuery(query); got != want {
+			t.Errorf("RedactQuery(%s) = %s, want %s", query, got, want)
+		}
+	}
+}
+
+func TestAbsoluteURL(t *testing.T) {
+	r := httptest.NewRequest(http.MethodGet, "http://example.com/used/cars?city=1&session=xyz", nil)
+	if got := AbsoluteURL(r); got != "http://example.com/used/cars?city=1&session=REDACTED" {
+		t.Errorf("AbsoluteURL() = %s", got)
+	}
+	r.Header.Set("X-Forwarded-Proto", "https")
+	if got := AbsoluteURL(r); got != "https://example.com/used/cars?city=1&session=REDACTED" {
+		t.Errorf("AbsoluteURL() = %s with X-Forwarded-Proto", got)
+	}
+	r.TLS = &tls.ConnectionState{}
+	r.Header.Del("X-Forwarded-Proto")
+	if got := RequestURL(r); got != "example.com/used/cars?city=1&session=REDACTED" {
+		t.Errorf("RequestURL() = %s", got)
+	}
+}
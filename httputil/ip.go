@@ -0,0 +1,65 @@
+// Package httputil has helpers for http requests shared by the logging packages: the ip address of
+// the client behind proxies and the url of the request with secrets removed from the query
+package httputil
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/carwale/golibraries/goutilities"
+)
+
+// PrivateCIDRs are the loopback, link local and private ranges. Load balancers and ingress controllers
+// of a cluster are usually in these ranges
+var PrivateCIDRs = goutilities.PrivateCIDRs
+
+// IPResolver finds the ip address of the client of a request. Proxies add the address they received the
+// request from to X-Forwarded-For, so the chain is read from the right and the first address which is
+// not a trusted proxy is the client. Addresses left of it could have been sent by the client and are not trusted
+type IPResolver struct {
+	trusted *goutilities.CIDRList
+}
+
+// NewIPResolver returns a resolver which trusts the proxies in the cidrs
+func NewIPResolver(trustedCIDRs ...string) (*IPResolver, error) {
+	trusted, err := goutilities.ParseCIDRList(trustedCIDRs...)
+	if err != nil {
+		return nil, fmt.Errorf("invalid trusted proxy: %w", err)
+	}
+	return &IPResolver{trusted: trusted}, nil
+}
+
+var defaultResolver, _ = NewIPResolver(PrivateCIDRs...)
+
+// ClientIP returns the ip address of the client trusting proxies in the private ranges
+func ClientIP(r *http.Request) string {
+	return defaultResolver.ClientIP(r)
+}
+
+// ClientIP returns the ip address of the client of the request. X-Forwarded-For and X-Real-IP are only
+// used if the request was received from a trusted proxy
+func (res *IPResolver) ClientIP(r *http.Request) string {
+	if ip := goutilities.ClientIPFromChain(r.RemoteAddr, goutilities.ForwardedChain(r.Header.Values("X-Forwarded-For")...), res.trusted); ip != "" {
+		return ip
+	}
+	if realIP := goutilities.NormalizeIP(r.Header.Get("X-Real-IP")); realIP != "" {
+		return realIP
+	}
+	return remoteIP(r.RemoteAddr)
+}
+
+// ClientIPFromForwarded returns the ip address of the client of a request received from remoteAddr with the
+// X-Forwarded-For values, e.g. of the metadata of a gRPC call. It returns the address of remoteAddr if there is no client in the chain
+func (res *IPResolver) ClientIPFromForwarded(remoteAddr string, forwardedFor ...string) string {
+	if ip := goutilities.ClientIPFromChain(remoteAddr, goutilities.ForwardedChain(forwardedFor...), res.trusted); ip != "" {
+		return ip
+	}
+	return remoteIP(remoteAddr)
+}
+
+func remoteIP(remoteAddr string) string {
+	if ip := goutilities.NormalizeIP(remoteAddr); ip != "" {
+		return ip
+	}
+	return remoteAddr
+}
@@ -0,0 +1,63 @@
+package httputil
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// RedactedValue replaces the values of sensitive query parameters
+const RedactedValue = "REDACTED"
+
+// SensitiveQueryParams are the query parameters redacted by RequestURL. Names are matched case insensitively
+// and a parameter is also redacted if its name ends with one of them, like api_key or x-auth-token
+var SensitiveQueryParams = []string{"password", "passwd", "secret", "token", "key", "auth", "signature", "sig", "session", "code", "otp"}
+
+// RequestURL returns the host, path and query of the request with the values of sensitive query parameters redacted
+func RequestURL(r *http.Request) string {
+	path := r.URL.EscapedPath()
+	if r.URL.RawQuery == "" {
+		return r.Host + path
+	}
+	return r.Host + path + "?" + RedactQuery(r.URL.RawQuery)
+}
+
+// AbsoluteURL returns the url of the request with its scheme. The scheme is taken from X-Forwarded-Proto
+// when the request was forwarded by a proxy that terminates tls
+func AbsoluteURL(r *http.Request) string {
+	scheme := "http"
+	if r.TLS != nil {
+		scheme = "https"
+	} else if proto := r.Header.Get("X-Forwarded-Proto"); proto == "https" || proto == "http" {
+		scheme = proto
+	}
+	return scheme + "://" + RequestURL(r)
+}
+
+// RedactQuery replaces the values of sensitive parameters in the raw query. The order of the parameters is kept
+func RedactQuery(rawQuery string) string {
+	parts := strings.Split(rawQuery, "&")
+	for i, part := range parts {
+		name, _, hasValue := strings.Cut(part, "=")
+		if !hasValue {
+			continue
+		}
+		if unescaped, err := url.QueryUnescape(name); err == nil {
+			name = unescaped
+		}
+		if isSensitive(name) {
+			parts[i] = part[:strings.IndexByte(part, '=')+1] + RedactedValue
+		}
+	}
+	return strings.Join(parts, "&")
+}
+
+func isSensitive(name string) bool {
+	name = strings.ToLower(name)
+	for _, sensitive := range SensitiveQueryParams {
+		if name == sensitive || strings.HasSuffix(name, "_"+sensitive) || strings.HasSuffix(name, "-"+sensitive) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,104 @@
+package kafka
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/confluentinc/confluent-kafka-go/kafka"
+	"github.com/google/uuid"
+)
+
+// HeaderClaimCheck is the header holding the reference of a payload moved to the blob store
+const HeaderClaimCheck = "claim-check"
+
+// IBlobStore stores the payloads of large messages. Implement it to use an object store like S3; the objectstore
+// package implements it, and the memcachedblob package for payloads under the item size limit of memcached
+type IBlobStore interface {
+	Put(ctx context.Context, reference string, payload []byte) error
+	Get(ctx context.Context, reference string) ([]byte, error)
+}
+
+// BlobStoreFuncs is an IBlobStore backed by functions
+type BlobStoreFuncs struct {
+	PutFunc func(ctx context.Context, reference string, payload []byte) error
+	GetFunc func(ctx context.Context, reference string) ([]byte, error)
+}
+
+// Put calls PutFunc
+func (s BlobStoreFuncs) Put(ctx context.Context, reference string, payload []byte) error {
+	return s.PutFunc(ctx, reference, payload)
+}
+
+// Get calls GetFunc
+func (s BlobStoreFuncs) Get(ctx context.Context, reference string) ([]byte, error) {
+	return s.GetFunc(ctx, reference)
+}
+
+// SetClaimCheck moves the payloads larger than threshold bytes to the blob store. The message is published
+// with an empty value and the reference of the payload in the claim-check header. If the payload cannot be
+// stored the message is published as it is. Disabled by default
+func SetClaimCheck(store IBlobStore, threshold int) ProducerOption {
+	return func(kp *Producer) {
+		kp.claimCheckStore = store
+		kp.claimCheckThreshold = threshold
+	}
+}
+
+// SetConsumerClaimCheck gets the payloads of messages with a claim-check header from the blob store,
+// so that the processor receives the original payload. A message whose payload cannot be fetched is retried
+// and then put in the undeliverable store, which must be set with SetUndeliverableStore
+func SetConsumerClaimCheck(store IBlobStore) ConsumerOption {
+	return func(kc *Consumer) { kc.claimCheckStore = store }
+}
+
+// produce sends the message to the producer, after encrypting it and moving a large payload to the blob store
+func (kp *Producer) produce(msg *kafka.Message) {
+	if !kp.encrypt(msg) {
+		notifyDelivery(msg, errNotEncrypted)
+		return
+	}
+	if kp.claimCheckStore != nil && len(msg.Value) > kp.claimCheckThreshold {
+		reference := uuid.NewString()
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		err := kp.claimCheckStore.Put(ctx, reference, msg.Value)
+		cancel()
+		if err != nil {
+			kp.logger.LogError("could not store the payload of a large message, publishing it as it is", err)
+		} else {
+			msg.Value = nil
+			msg.Headers = append(msg.Headers, kafka.Header{Key: HeaderClaimCheck, Value: []byte(reference)})
+		}
+	}
+	kp.publishChannel <- msg
+}
+
+// claimCheckReference returns the reference of the payload of the message, if it was moved to the blob store
+func claimCheckReference(m *kafka.Message) (string, bool) {
+	for _, header := range m.Headers {
+		if header.Key == HeaderClaimCheck {
+			return string(header.Value), true
+		}
+	}
+	return "", false
+}
+
+// resolveClaimCheck replaces the value of the message with its payload from the blob store.
+// It returns an error if the payload could not be fetched
+func (kc *Consumer) resolveClaimCheck(m *kafka.Message) error {
+	if kc.claimCheckStore == nil {
+		return nil
+	}
+	reference, ok := claimCheckReference(m)
+	if !ok {
+		return nil
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	payload, err := kc.claimCheckStore.Get(ctx, reference)
+	if err != nil {
+		return fmt.Errorf("could not get the payload of claim check %s: %w", reference, err)
+	}
+	m.Value = payload
+	return nil
+}
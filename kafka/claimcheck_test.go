@@ -0,0 +1,82 @@
+package kafka
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/carwale/golibraries/gologger"
+	"github.com/carwale/golibraries/poison"
+	"github.com/confluentinc/confluent-kafka-go/kafka"
+)
+
+func newMemoryBlobStore() (IBlobStore, map[string][]byte) {
+	blobs := map[string][]byte{}
+	return BlobStoreFuncs{
+		PutFunc: func(ctx context.Context, reference string, payload []byte) error {
+			blobs[reference] = payload
+			return nil
+		},
+		GetFunc: func(ctx context.Context, reference string) ([]byte, error) {
+			payload, ok := blobs[reference]
+			if !ok {
+				return nil, errors.New("not found")
+			}
+			return payload, nil
+		},
+	}, blobs
+}
+
+func TestClaimCheckRoundTrip(t *testing.T) {
+	store, blobs := newMemoryBlobStore()
+	kp := &Producer{logger: gologger.NewLogger(), publishChannel: make(chan *kafka.Message, 2)}
+	SetClaimCheck(store, 4)(kp)
+	topic := "stocks"
+
+	kp.PublishMessageToTopic(&[]byte{1, 2, 3}, topic)
+	small := <-kp.publishChannel
+	if _, ok := claimCheckReference(small); ok || len(small.Value) != 3 {
+		t.Errorf("small message was moved to the blob store: %+v", small)
+	}
+
+	payload := []byte("a large payload")
+	kp.PublishMessageToTopic(&payload, topic)
+	large := <-kp.publishChannel
+	reference, ok := claimCheckReference(large)
+	if !ok || large.Value != nil || string(blobs[reference]) != string(payload) {
+		t.Fatalf("large message was not moved to the blob store: %+v", large)
+	}
+
+	kc := &Consumer{logger: gologger.NewLogger()}
+	SetConsumerClaimCheck(store)(kc)
+	if err := kc.resolveClaimCheck(large); err != nil || string(large.Value) != string(payload) {
+		t.Errorf("resolved value = %q, %v, want %q", large.Value, err, payload)
+	}
+}
+
+func TestMissingClaimCheckIsUndeliverable(t *testing.T) {
+	store, _ := newMemoryBlobStore()
+	topic := "stocks"
+	msg := &kafka.Message{
+		TopicPartition: kafka.TopicPartition{Topic: &topic},
+		Headers:        []kafka.Header{{Key: HeaderClaimCheck, Value: []byte("missing")}},
+	}
+	kc := &Consumer{logger: gologger.NewLogger()}
+	SetConsumerClaimCheck(store)(kc)
+	err := kc.resolveClaimCheck(msg)
+	if err == nil {
+		t.Fatal("missing payload was resolved")
+	}
+	if kc.storeUndeliverable(msg, err) {
+		t.Error("message was stored without an undeliverable store")
+	}
+
+	var stored []poison.QuarantinedMessage
+	SetUndeliverableStore(poison.StoreFunc(func(m poison.QuarantinedMessage) error {
+		stored = append(stored, m)
+		return nil
+	}))(kc)
+	if !kc.storeUndeliverable(msg, err) || len(stored) != 1 || stored[0].Source != topic {
+		t.Errorf("stored = %+v, want the message of %s", stored, topic)
+	}
+}
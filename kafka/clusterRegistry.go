@@ -0,0 +1,242 @@
+package kafka
+
+import (
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/carwale/golibraries/gologger"
+	"github.com/confluentinc/confluent-kafka-go/kafka"
+)
+
+// ClusterConfig holds the settings of a named kafka cluster
+type ClusterConfig struct {
+	Name          string
+	BrokerServers string
+	// CustomConfig is applied to every producer and consumer created for the cluster
+	// before the options passed while creating them
+	CustomConfig map[string]interface{}
+}
+
+// ClusterRegistry holds the named kafka clusters (eg. primary and DR) of a service.
+// Clusters are configured once and producers and consumers are created using the cluster name
+type ClusterRegistry struct {
+	mu       sync.RWMutex
+	clusters map[string]ClusterConfig
+	logger   gologger.ILogger
+}
+
+// NewClusterRegistry creates a new cluster registry with the given clusters.
+// If logger is nil a default logger is used
+func NewClusterRegistry(logger gologger.ILogger, clusters ...ClusterConfig) (*ClusterRegistry, error) {
+	if logger == nil {
+		logger = gologger.NewLogger()
+	}
+	r := &ClusterRegistry{
+		clusters: make(map[string]ClusterConfig),
+		logger:   logger,
+	}
+	for _, cluster := range clusters {
+		if err := r.Register(cluster); err != nil {
+			return nil, err
+		}
+	}
+	return r, nil
+}
+
+// Register adds a cluster to the registry. It returns an error if the cluster
+// is invalid or a cluster with the same name is already registered
+func (r *ClusterRegistry) Register(cluster ClusterConfig) error {
+	if cluster.Name == "" {
+		return fmt.Errorf("cluster name cannot be empty")
+	}
+	if cluster.BrokerServers == "" {
+		return fmt.Errorf("broker servers cannot be empty for cluster %s", cluster.Name)
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.clusters[cluster.Name]; ok {
+		return fmt.Errorf("cluster %s is already registered", cluster.Name)
+	}
+	r.clusters[cluster.Name] = cluster
+	r.logger.LogInfof("Registered kafka cluster %s with brokers %s", cluster.Name, cluster.BrokerServers)
+	return nil
+}
+
+// GetCluster returns the config of the cluster with the given name
+func (r *ClusterRegistry) GetCluster(name string) (ClusterConfig, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	cluster, ok := r.clusters[name]
+	return cluster, ok
+}
+
+// ClusterNames returns the names of all the registered clusters
+func (r *ClusterRegistry) ClusterNames() []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	names := make([]string, 0, len(r.clusters))
+	for name := range r.clusters {
+		names = append(names, name)
+	}
+	return names
+}
+
+// NewProducer creates a new producer for the named cluster
+func (r *ClusterRegistry) NewProducer(clusterName string, options ...ProducerOption) (*Producer, error) {
+	cluster, ok := r.GetCluster(clusterName)
+	if !ok {
+		return nil, fmt.Errorf("cluster %s is not registered", clusterName)
+	}
+	producerOptions := []ProducerOption{SetProducerCustomConfig(cluster.CustomConfig), SetProducerLogger(r.logger)}
+	return NewKafkaProducer(cluster.BrokerServers, append(producerOptions, options...)...), nil
+}
+
+// NewConsumer creates a new consumer for the named cluster
+func (r *ClusterRegistry) NewConsumer(clusterName string, consumerGroupName string, topics []string, options ...ConsumerOption) (*Consumer, error) {
+	cluster, ok := r.GetCluster(clusterName)
+	if !ok {
+		return nil, fmt.Errorf("cluster %s is not registered", clusterName)
+	}
+	consumerOptions := []ConsumerOption{SetConsumerCustomConfig(cluster.CustomConfig), ConsumerLogger(r.logger)}
+	return NewKafkaConsumer(cluster.BrokerServers, consumerGroupName, topics, append(consumerOptions, options...)...), nil
+}
+
+// FailoverEvent is emitted by the FailoverPublisher whenever it switches clusters
+type FailoverEvent struct {
+	FromCluster string
+	ToCluster   string
+	// LastError is the last delivery error received on the cluster that failed
+	LastError error
+	Time      time.Time
+}
+
+// FailoverPublisher publishes to the first healthy cluster from a list of clusters.
+// It switches to the next cluster when the number of consecutive delivery errors
+// on the active cluster reaches the error threshold
+type FailoverPublisher struct {
+	clusters          []string
+	producers         []*Producer
+	active            int32
+	consecutiveErrors int32
+	errorThreshold    int32
+	lastSwitch        time.Time
+	minSwitchInterval time.Duration
+	mu                sync.Mutex
+	logger            gologger.ILogger
+	// Events receives an event every time the publisher switches clusters.
+	// Events are dropped if the channel is full
+	Events chan FailoverEvent
+}
+
+// FailoverOption sets a parameter for the FailoverPublisher
+type FailoverOption func(fp *FailoverPublisher)
+
+// SetFailoverErrorThreshold sets the number of consecutive delivery errors after which the
+// publisher switches to the next cluster. Default is 100
+func SetFailoverErrorThreshold(threshold int) FailoverOption {
+	return func(fp *FailoverPublisher) {
+		if threshold > 0 {
+			fp.errorThreshold = int32(threshold)
+		}
+	}
+}
+
+// SetFailoverMinSwitchInterval sets the minimum time between two cluster switches.
+// This stops the publisher from flapping between clusters when all of them are failing. Default is 1 minute
+func SetFailoverMinSwitchInterval(interval time.Duration) FailoverOption {
+	return func(fp *FailoverPublisher) {
+		if interval > 0 {
+			fp.minSwitchInterval = interval
+		}
+	}
+}
+
+// NewFailoverPublisher creates a failover publisher for the given clusters in order of preference.
+// A producer is created for every cluster so that switching is instant
+func (r *ClusterRegistry) NewFailoverPublisher(clusterNames []string, producerOptions []ProducerOption, options ...FailoverOption) (*FailoverPublisher, error) {
+	if len(clusterNames) == 0 {
+		return nil, fmt.Errorf("at least one cluster is required for failover publisher")
+	}
+	fp := &FailoverPublisher{
+		clusters:          clusterNames,
+		errorThreshold:    100,
+		minSwitchInterval: time.Minute,
+		logger:            r.logger,
+		Events:            make(chan FailoverEvent, 10),
+	}
+	for _, option := range options {
+		option(fp)
+	}
+	for i, clusterName := range clusterNames {
+		clusterIndex := int32(i)
+		reportHandler := SetDeliveryReportHandler(func(m *kafka.Message) {
+			fp.handleDeliveryReport(clusterIndex, m)
+		})
+		producer, err := r.NewProducer(clusterName, append(producerOptions, reportHandler)...)
+		if err != nil {
+			return nil, err
+		}
+		fp.producers = append(fp.producers, producer)
+	}
+	return fp, nil
+}
+
+// ActiveCluster returns the name of the cluster that messages are currently published to
+func (fp *FailoverPublisher) ActiveCluster() string {
+	return fp.clusters[atomic.LoadInt32(&fp.active)]
+}
+
+// PublishMessageToTopic publishes message to topic on the active cluster
+func (fp *FailoverPublisher) PublishMessageToTopic(msg *[]byte, topic string) {
+	fp.producers[atomic.LoadInt32(&fp.active)].PublishMessageToTopic(msg, topic)
+}
+
+// PublishMessageToTopicWithKey publishes message to topic with key on the active cluster
+func (fp *FailoverPublisher) PublishMessageToTopicWithKey(msg *[]byte, topic string, key string) {
+	fp.producers[atomic.LoadInt32(&fp.active)].PublishMessageToTopicWithKey(msg, topic, key)
+}
+
+func (fp *FailoverPublisher) handleDeliveryReport(clusterIndex int32, m *kafka.Message) {
+	// Reports from clusters that are not active anymore are ignored
+	if clusterIndex != atomic.LoadInt32(&fp.active) {
+		return
+	}
+	if m.TopicPartition.Error == nil {
+		atomic.StoreInt32(&fp.consecutiveErrors, 0)
+		return
+	}
+	if atomic.AddInt32(&fp.consecutiveErrors, 1) >= fp.errorThreshold {
+		fp.failover(clusterIndex, m.TopicPartition.Error)
+	}
+}
+
+func (fp *FailoverPublisher) failover(fromIndex int32, lastErr error) {
+	fp.mu.Lock()
+	defer fp.mu.Unlock()
+	if fromIndex != atomic.LoadInt32(&fp.active) || len(fp.clusters) == 1 {
+		return
+	}
+	if time.Since(fp.lastSwitch) < fp.minSwitchInterval {
+		return
+	}
+	toIndex := (fromIndex + 1) % int32(len(fp.clusters))
+	atomic.StoreInt32(&fp.active, toIndex)
+	atomic.StoreInt32(&fp.consecutiveErrors, 0)
+	fp.lastSwitch = time.Now()
+	event := FailoverEvent{
+		FromCluster: fp.clusters[fromIndex],
+		ToCluster:   fp.clusters[toIndex],
+		LastError:   lastErr,
+		Time:        fp.lastSwitch,
+	}
+	fp.logger.LogErrorMessage("Kafka publisher failed over to another cluster", lastErr,
+		gologger.Pair{Key: "from_cluster", Value: event.FromCluster},
+		gologger.Pair{Key: "to_cluster", Value: event.ToCluster})
+	select {
+	case fp.Events <- event:
+	default:
+		fp.logger.LogWarning("Failover events channel is full. Dropping failover event")
+	}
+}
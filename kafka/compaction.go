@@ -0,0 +1,156 @@
+package kafka
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"syscall"
+
+	"github.com/confluentinc/confluent-kafka-go/kafka"
+	"github.com/google/uuid"
+)
+
+// PublishTombstone publishes a message with the key and a nil value to the topic.
+// On a compacted topic this deletes the key once the topic is compacted
+func (kp *Producer) PublishTombstone(topic string, key string) {
+	kp.produce(&kafka.Message{TopicPartition: kafka.TopicPartition{
+		Topic:     &topic,
+		Partition: kp.getPartition(topic, []byte(key)),
+	},
+		Key: []byte(key),
+	})
+}
+
+// CompactedTopicView materializes a compacted topic, like a city or dealer master, into an in-memory map of
+// the latest value of every key. It reads the topic from the beginning with a consumer group of its own,
+// so every instance of a service builds the complete snapshot.
+// The snapshot is ready once the end of every assigned partition has been reached. After that the view keeps
+// streaming the updates of the topic and calls the update handler for each of them
+type CompactedTopicView struct {
+	consumer *Consumer
+	values   map[string][]byte
+	lock     sync.RWMutex
+	onUpdate func(key string, value []byte)
+	eofs     map[int32]bool
+	ready    chan struct{}
+}
+
+// NewCompactedTopicView creates a view of the topic. The update handler is called for every message received
+// after the snapshot is ready, with a nil value for tombstones. It can be nil.
+// The consumer options are applied to the underlying consumer
+func NewCompactedTopicView(brokerServers string, topic string, onUpdate func(key string, value []byte), options ...ConsumerOption) *CompactedTopicView {
+	v := &CompactedTopicView{
+		values:   map[string][]byte{},
+		onUpdate: onUpdate,
+		eofs:     map[int32]bool{},
+		ready:    make(chan struct{}),
+	}
+	consumerGroupName := fmt.Sprintf("%s-view-%s", topic, uuid.NewString())
+	v.consumer = NewKafkaConsumer(brokerServers, consumerGroupName, []string{topic}, options...)
+	v.consumer.eofHandler = v.partitionEOF
+	return v
+}
+
+// Start starts consuming the topic and blocks till the consumer is closed
+func (v *CompactedTopicView) Start() {
+	v.consumer.Start(v)
+}
+
+// Close closes the underlying consumer
+func (v *CompactedTopicView) Close() {
+	select {
+	case v.consumer.CloseChannel <- syscall.SIGTERM:
+	default:
+		// a signal is already pending for the consumer
+	}
+}
+
+// WaitReady blocks till the snapshot is ready or the context is done
+func (v *CompactedTopicView) WaitReady(ctx context.Context) error {
+	select {
+	case <-v.ready:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// IsReady returns true once the snapshot has been built
+func (v *CompactedTopicView) IsReady() bool {
+	select {
+	case <-v.ready:
+		return true
+	default:
+		return false
+	}
+}
+
+// Get returns the latest value of the key
+func (v *CompactedTopicView) Get(key string) ([]byte, bool) {
+	v.lock.RLock()
+	defer v.lock.RUnlock()
+	value, ok := v.values[key]
+	return value, ok
+}
+
+// Len returns the number of keys in the view
+func (v *CompactedTopicView) Len() int {
+	v.lock.RLock()
+	defer v.lock.RUnlock()
+	return len(v.values)
+}
+
+// Snapshot returns a copy of the latest values of all the keys
+func (v *CompactedTopicView) Snapshot() map[string][]byte {
+	v.lock.RLock()
+	defer v.lock.RUnlock()
+	snapshot := make(map[string][]byte, len(v.values))
+	for key, value := range v.values {
+		snapshot[key] = value
+	}
+	return snapshot
+}
+
+// ProcessMessage applies the message to the view. A nil value deletes the key
+func (v *CompactedTopicView) ProcessMessage(msg *Message) bool {
+	if msg.Key == nil {
+		return true
+	}
+	key := string(msg.Key)
+	v.lock.Lock()
+	if msg.Data == nil {
+		delete(v.values, key)
+	} else {
+		v.values[key] = msg.Data
+	}
+	v.lock.Unlock()
+	if v.onUpdate != nil && v.IsReady() {
+		v.onUpdate(key, msg.Data)
+	}
+	return true
+}
+
+func (v *CompactedTopicView) partitionEOF(eof kafka.PartitionEOF) {
+	assigned, err := v.consumer.Consumer.Assignment()
+	if err != nil {
+		v.consumer.logger.LogError("could not get the assigned partitions of "+v.consumer.InstanceID, err)
+		return
+	}
+	v.markEOF(eof.Partition, assigned)
+}
+
+// markEOF records that the end of the partition was reached and marks the snapshot as ready
+// once the end of all the assigned partitions has been reached
+func (v *CompactedTopicView) markEOF(partition int32, assigned []kafka.TopicPartition) {
+	if v.IsReady() {
+		return
+	}
+	v.eofs[partition] = true
+	for _, tp := range assigned {
+		if !v.eofs[tp.Partition] {
+			return
+		}
+	}
+	v.consumer.logger.LogInfof("Snapshot of %v is ready with %d keys", v.consumer.Topics, v.Len())
+	close(v.ready)
+}
@@ -0,0 +1,38 @@
+package kafka
+
+import (
+	"testing"
+
+	"github.com/carwale/golibraries/gologger"
+	"github.com/confluentinc/confluent-kafka-go/kafka"
+)
+
+func TestCompactedTopicView(t *testing.T) {
+	var updates []string
+	v := &CompactedTopicView{
+		consumer: &Consumer{logger: gologger.NewLogger()},
+		values:   map[string][]byte{},
+		onUpdate: func(key string, value []byte) { updates = append(updates, key+"="+string(value)) },
+		eofs:     map[int32]bool{},
+		ready:    make(chan struct{}),
+	}
+	assigned := []kafka.TopicPartition{{Partition: 0}, {Partition: 1}}
+
+	v.ProcessMessage(&Message{Key: []byte("1"), Data: RawEvent("Mumbai")})
+	v.ProcessMessage(&Message{Key: []byte("2"), Data: RawEvent("Pune")})
+	v.ProcessMessage(&Message{Key: []byte("2")})
+	v.markEOF(0, assigned)
+	if v.IsReady() {
+		t.Fatal("view is ready before the end of all the partitions was reached")
+	}
+	v.markEOF(1, assigned)
+	if !v.IsReady() || v.Len() != 1 || len(updates) != 0 {
+		t.Fatalf("ready = %v, len = %d, updates = %v, want a ready snapshot of 1 key and no updates", v.IsReady(), v.Len(), updates)
+	}
+
+	v.ProcessMessage(&Message{Key: []byte("1"), Data: RawEvent("Bombay")})
+	v.ProcessMessage(&Message{Key: []byte("1")})
+	if _, ok := v.Get("1"); ok || len(updates) != 2 || updates[0] != "1=Bombay" || updates[1] != "1=" {
+		t.Errorf("updates = %v, want the update and the tombstone of key 1", updates)
+	}
+}
@@ -0,0 +1,167 @@
+package kafka
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/carwale/golibraries/gologger"
+	"github.com/confluentinc/confluent-kafka-go/kafka"
+)
+
+// ConfigError is returned when a kafka config map has unknown keys or values that conflict with the
+// way the consumer or the producer of this package work
+type ConfigError struct {
+	// UnknownKeys are not librdkafka or go client properties. librdkafka refuses to create a client with them
+	UnknownKeys []string
+	// Conflicts describe the values that break the consumer or the producer
+	Conflicts []string
+}
+
+func (e *ConfigError) Error() string {
+	var problems []string
+	if len(e.UnknownKeys) > 0 {
+		problems = append(problems, "unknown keys "+strings.Join(e.UnknownKeys, ", "))
+	}
+	problems = append(problems, e.Conflicts...)
+	return "invalid kafka config: " + strings.Join(problems, "; ")
+}
+
+// commonConfigKeys are the librdkafka properties used by both consumers and producers, and the properties of the go client
+var commonConfigKeys = []string{
+	"builtin.features", "client.id", "metadata.broker.list", "bootstrap.servers", "message.max.bytes",
+	"message.copy.max.bytes", "receive.message.max.bytes", "max.in.flight.requests.per.connection", "max.in.flight",
+	"topic.metadata.refresh.interval.ms", "metadata.max.age.ms", "topic.metadata.refresh.fast.interval.ms",
+	"topic.metadata.refresh.sparse", "topic.metadata.propagation.max.ms", "topic.blacklist", "debug",
+	"socket.timeout.ms", "socket.send.buffer.bytes", "socket.receive.buffer.bytes", "socket.keepalive.enable",
+	"socket.nagle.disable", "socket.max.fails", "broker.address.ttl", "broker.address.family",
+	"socket.connection.setup.timeout.ms", "connections.max.idle.ms", "reconnect.backoff.ms", "reconnect.backoff.max.ms",
+	"statistics.interval.ms", "log_level", "log.queue", "log.thread.name", "enable.random.seed",
+	"log.connection.close", "internal.termination.signal", "api.version.request", "api.version.request.timeout.ms",
+	"api.version.fallback.ms", "broker.version.fallback", "allow.auto.create.topics", "security.protocol",
+	"ssl.cipher.suites", "ssl.curves.list", "ssl.sigalgs.list", "ssl.key.location", "ssl.key.password", "ssl.key.pem",
+	"ssl.certificate.location", "ssl.certificate.pem", "ssl.ca.location", "ssl.ca.pem", "ssl.ca.certificate.stores",
+	"ssl.crl.location", "ssl.keystore.location", "ssl.keystore.password", "ssl.providers", "ssl.engine.location",
+	"ssl.engine.id", "enable.ssl.certificate.verification", "ssl.endpoint.identification.algorithm",
+	"sasl.mechanisms", "sasl.mechanism", "sasl.kerberos.service.name", "sasl.kerberos.principal",
+	"sasl.kerberos.kinit.cmd", "sasl.kerberos.keytab", "sasl.kerberos.min.time.before.relogin", "sasl.username",
+	"sasl.password", "sasl.oauthbearer.config", "enable.sasl.oauthbearer.unsecure.jwt", "sasl.oauthbearer.method",
+	"sasl.oauthbearer.client.id", "sasl.oauthbearer.client.secret", "sasl.oauthbearer.scope",
+	"sasl.oauthbearer.extensions", "sasl.oauthbearer.token.endpoint.url", "plugin.library.paths", "client.rack",
+	"client.dns.lookup", "retry.backoff.ms", "retry.backoff.max.ms",
+	"go.logs.channel.enable", "go.logs.channel", "go.events.channel.size",
+}
+
+// consumerConfigKeys are the librdkafka and go client properties of consumers
+var consumerConfigKeys = []string{
+	"group.id", "group.instance.id", "partition.assignment.strategy", "session.timeout.ms", "heartbeat.interval.ms",
+	"group.protocol.type", "group.protocol", "group.remote.assignor", "coordinator.query.interval.ms",
+	"max.poll.interval.ms", "enable.auto.commit", "auto.commit.interval.ms", "enable.auto.offset.store",
+	"queued.min.messages", "queued.max.messages.kbytes", "fetch.wait.max.ms", "fetch.queue.backoff.ms",
+	"fetch.message.max.bytes", "max.partition.fetch.bytes", "fetch.max.bytes", "fetch.min.bytes",
+	"fetch.error.backoff.ms", "isolation.level", "enable.partition.eof", "check.crcs", "auto.offset.reset",
+	"go.events.channel.enable", "go.application.rebalance.enable",
+}
+
+// producerConfigKeys are the librdkafka and go client properties of producers
+var producerConfigKeys = []string{
+	"transactional.id", "transaction.timeout.ms", "enable.idempotence", "enable.gapless.guarantee",
+	"queue.buffering.max.messages", "queue.buffering.max.kbytes", "queue.buffering.max.ms", "linger.ms",
+	"message.send.max.retries", "retries", "queue.buffering.backpressure.threshold", "compression.codec",
+	"compression.type", "compression.level", "batch.num.messages", "batch.size", "delivery.report.only.error",
+	"sticky.partitioning.linger.ms", "request.required.acks", "acks", "request.timeout.ms", "message.timeout.ms",
+	"delivery.timeout.ms", "partitioner", "go.batch.producer", "go.delivery.reports", "go.delivery.report.fields",
+	"go.produce.channel.size",
+}
+
+// ValidateConsumerConfig checks the config of a consumer. It returns a *ConfigError listing the unknown keys
+// and the values that conflict with the manual offset commits and the event channel used by Consumer
+func ValidateConsumerConfig(config kafka.ConfigMap) error {
+	configErr := &ConfigError{UnknownKeys: unknownConfigKeys(config, commonConfigKeys, consumerConfigKeys)}
+	if isTrue(config["enable.auto.commit"]) {
+		configErr.Conflicts = append(configErr.Conflicts, "enable.auto.commit=true conflicts with the offset commits of the consumer, use SetOffsetCommitMessageInterval")
+	}
+	if isFalse(config["go.events.channel.enable"]) {
+		configErr.Conflicts = append(configErr.Conflicts, "go.events.channel.enable=false stops the consumer from receiving messages")
+	}
+	if isFalse(config["enable.partition.eof"]) {
+		configErr.Conflicts = append(configErr.Conflicts, "enable.partition.eof=false breaks replay mode, the watchdog and compacted topic views")
+	}
+	return configErr.orNil()
+}
+
+// ValidateProducerConfig checks the config of a producer. It returns a *ConfigError listing the unknown keys
+// and the values that conflict with the delivery reports used by Producer or with each other
+func ValidateProducerConfig(config kafka.ConfigMap) error {
+	configErr := &ConfigError{UnknownKeys: unknownConfigKeys(config, commonConfigKeys, producerConfigKeys)}
+	if isFalse(config["go.delivery.reports"]) {
+		configErr.Conflicts = append(configErr.Conflicts, "go.delivery.reports=false disables the delivery report handler, callbacks and metrics")
+	}
+	if isTrue(config["enable.idempotence"]) {
+		for _, key := range []string{"acks", "request.required.acks"} {
+			if acks, ok := config[key]; ok && fmt.Sprint(acks) != "all" && fmt.Sprint(acks) != "-1" {
+				configErr.Conflicts = append(configErr.Conflicts, fmt.Sprintf("enable.idempotence=true requires %s=all, not %v", key, acks))
+			}
+		}
+		for _, key := range []string{"max.in.flight.requests.per.connection", "max.in.flight"} {
+			if inFlight, ok := config[key]; ok && toInt(inFlight) > 5 {
+				configErr.Conflicts = append(configErr.Conflicts, fmt.Sprintf("enable.idempotence=true requires %s<=5, not %v", key, inFlight))
+			}
+		}
+	}
+	return configErr.orNil()
+}
+
+func (e *ConfigError) orNil() error {
+	if len(e.UnknownKeys) == 0 && len(e.Conflicts) == 0 {
+		return nil
+	}
+	return e
+}
+
+// validateConfig logs the unknown keys of the config and panics if it has conflicts.
+// Unknown keys are only logged as librdkafka reports them when the client is created
+func validateConfig(logger gologger.ILogger, name string, err error) {
+	configErr, ok := err.(*ConfigError)
+	if !ok {
+		return
+	}
+	for _, key := range configErr.UnknownKeys {
+		logger.LogWarning(name + ": unknown kafka config key " + key)
+	}
+	if len(configErr.Conflicts) > 0 {
+		panic(fmt.Sprintf("%s: %s", name, (&ConfigError{Conflicts: configErr.Conflicts}).Error()))
+	}
+}
+
+func unknownConfigKeys(config kafka.ConfigMap, knownKeys ...[]string) []string {
+	known := map[string]bool{}
+	for _, keys := range knownKeys {
+		for _, key := range keys {
+			known[key] = true
+		}
+	}
+	var unknown []string
+	for key := range config {
+		// default.topic.config holds topic properties, which are also accepted at the top level
+		if !known[key] && key != "default.topic.config" {
+			unknown = append(unknown, key)
+		}
+	}
+	sort.Strings(unknown)
+	return unknown
+}
+
+func isTrue(value kafka.ConfigValue) bool {
+	return value != nil && strings.EqualFold(fmt.Sprint(value), "true")
+}
+
+func isFalse(value kafka.ConfigValue) bool {
+	return value != nil && strings.EqualFold(fmt.Sprint(value), "false")
+}
+
+func toInt(value kafka.ConfigValue) int {
+	var n int
+	fmt.Sscan(fmt.Sprint(value), &n)
+	return n
+}
@@ -0,0 +1,31 @@
+package kafka
+
+import (
+	"testing"
+
+	"github.com/confluentinc/confluent-kafka-go/kafka"
+)
+
+func TestValidateConsumerConfig(t *testing.T) {
+	if err := ValidateConsumerConfig(kafka.ConfigMap{"group.id": "leads", "enable.auto.commit": false, "fetch.min.bytes": 1}); err != nil {
+		t.Errorf("ValidateConsumerConfig() = %v, want nil", err)
+	}
+	err := ValidateConsumerConfig(kafka.ConfigMap{"enable.auto.commit": "true", "auto.ofset.reset": "latest"})
+	configErr, ok := err.(*ConfigError)
+	if !ok {
+		t.Fatalf("ValidateConsumerConfig() = %v, want *ConfigError", err)
+	}
+	if len(configErr.UnknownKeys) != 1 || configErr.UnknownKeys[0] != "auto.ofset.reset" || len(configErr.Conflicts) != 1 {
+		t.Errorf("ValidateConsumerConfig() = %+v, want the misspelt key and the auto commit conflict", configErr)
+	}
+}
+
+func TestValidateProducerConfig(t *testing.T) {
+	if err := ValidateProducerConfig(kafka.ConfigMap{"enable.idempotence": true, "acks": "all", "max.in.flight.requests.per.connection": 5}); err != nil {
+		t.Errorf("ValidateProducerConfig() = %v, want nil", err)
+	}
+	err := ValidateProducerConfig(kafka.ConfigMap{"enable.idempotence": true, "acks": "1", "max.in.flight.requests.per.connection": 1000000})
+	if configErr, ok := err.(*ConfigError); !ok || len(configErr.Conflicts) != 2 {
+		t.Errorf("ValidateProducerConfig() = %v, want the acks and in flight conflicts", err)
+	}
+}
@@ -0,0 +1,32 @@
+package kafka
+
+import (
+	"errors"
+
+	"github.com/confluentinc/confluent-kafka-go/kafka"
+)
+
+// errNotEncrypted is reported to the delivery callback of a message which could not be encrypted
+var errNotEncrypted = errors.New("kafka: message could not be encrypted")
+
+// deliveryCallback is set as the opaque of the messages published with PublishMessageWithCallback
+type deliveryCallback func(err error)
+
+// PublishMessageWithCallback publishes the message to the topic and calls callback with the result of its
+// delivery report. The error is nil if the message was delivered. A nil key publishes the message without a key.
+// The callback is called from the event goroutine of the producer and must not block
+func (kp *Producer) PublishMessageWithCallback(msg []byte, topic string, key []byte, callback func(err error)) {
+	kp.produce(&kafka.Message{
+		TopicPartition: kafka.TopicPartition{Topic: &topic, Partition: kp.getPartition(topic, key)},
+		Key:            key,
+		Value:          msg,
+		Opaque:         deliveryCallback(callback),
+	})
+}
+
+// notifyDelivery calls the delivery callback of the message, if it has one
+func notifyDelivery(m *kafka.Message, err error) {
+	if callback, ok := m.Opaque.(deliveryCallback); ok && callback != nil {
+		callback(err)
+	}
+}
@@ -0,0 +1,56 @@
+package kafka
+
+import (
+	"fmt"
+
+	"github.com/carwale/golibraries/payloadcrypto"
+	"github.com/confluentinc/confluent-kafka-go/kafka"
+)
+
+// SetEncryption encrypts the values of the published messages with the cipher. The id of the key is sent
+// in the payloadcrypto.HeaderKeyID header. A message which cannot be encrypted is not published
+func SetEncryption(cipher *payloadcrypto.Cipher) ProducerOption {
+	return func(kp *Producer) { kp.cipher = cipher }
+}
+
+// SetConsumerEncryption decrypts the values of the consumed messages which have a key id header.
+// A message which cannot be decrypted is retried and then put in the undeliverable store, which must be set
+// with SetUndeliverableStore
+func SetConsumerEncryption(cipher *payloadcrypto.Cipher) ConsumerOption {
+	return func(kc *Consumer) { kc.cipher = cipher }
+}
+
+// encrypt encrypts the value of the message. It returns false if the message must not be published.
+// Tombstones are not encrypted, they must stay nil for the topic to be compacted
+func (kp *Producer) encrypt(msg *kafka.Message) bool {
+	if kp.cipher == nil || msg.Value == nil {
+		return true
+	}
+	id, encrypted, err := kp.cipher.Encrypt(msg.Value)
+	if err != nil {
+		kp.logger.LogError("could not encrypt message for topic "+topicOf(msg), err)
+		return false
+	}
+	msg.Value = encrypted
+	msg.Headers = append(msg.Headers, kafka.Header{Key: payloadcrypto.HeaderKeyID, Value: []byte(id)})
+	return true
+}
+
+// decrypt decrypts the value of the message if it has a key id header. It returns an error if it could not be decrypted
+func (kc *Consumer) decrypt(m *kafka.Message) error {
+	if kc.cipher == nil {
+		return nil
+	}
+	for _, header := range m.Headers {
+		if header.Key != payloadcrypto.HeaderKeyID {
+			continue
+		}
+		payload, err := kc.cipher.Decrypt(string(header.Value), m.Value)
+		if err != nil {
+			return fmt.Errorf("could not decrypt the message with key %s: %w", header.Value, err)
+		}
+		m.Value = payload
+		return nil
+	}
+	return nil
+}
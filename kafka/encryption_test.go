@@ -0,0 +1,49 @@
+package kafka
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/carwale/golibraries/gologger"
+	"github.com/carwale/golibraries/payloadcrypto"
+	"github.com/confluentinc/confluent-kafka-go/kafka"
+)
+
+func TestEncryptionWithClaimCheck(t *testing.T) {
+	cipher := payloadcrypto.NewCipher(payloadcrypto.NewStaticKeyProvider("k1", bytes.Repeat([]byte{1}, 32)))
+	store, _ := newMemoryBlobStore()
+	kp := &Producer{logger: gologger.NewLogger(), publishChannel: make(chan *kafka.Message, 1)}
+	SetEncryption(cipher)(kp)
+	SetClaimCheck(store, 8)(kp)
+
+	payload := []byte(`{"mobile":"9999999999"}`)
+	kp.PublishMessageToTopicWithKey(&payload, "leads", "lead-1")
+	msg := <-kp.publishChannel
+	if len(msg.Headers) != 2 || msg.Headers[0].Key != payloadcrypto.HeaderKeyID {
+		t.Fatalf("headers = %v, want the key id and the claim check", msg.Headers)
+	}
+
+	kc := &Consumer{logger: gologger.NewLogger()}
+	SetConsumerClaimCheck(store)(kc)
+	SetConsumerEncryption(cipher)(kc)
+	if kc.resolveClaimCheck(msg) != nil || kc.decrypt(msg) != nil || !bytes.Equal(msg.Value, payload) {
+		t.Errorf("consumed value = %q, want %q", msg.Value, payload)
+	}
+}
+
+func TestUndecryptableMessageIsUndeliverable(t *testing.T) {
+	cipher := payloadcrypto.NewCipher(payloadcrypto.NewStaticKeyProvider("k1", bytes.Repeat([]byte{1}, 32)))
+	kc := &Consumer{logger: gologger.NewLogger()}
+	SetConsumerEncryption(cipher)(kc)
+	msg := &kafka.Message{
+		Value:   []byte("not encrypted"),
+		Headers: []kafka.Header{{Key: payloadcrypto.HeaderKeyID, Value: []byte("k1")}},
+	}
+	err := kc.decrypt(msg)
+	if err == nil {
+		t.Fatal("message was decrypted")
+	}
+	if kc.storeUndeliverable(msg, err) {
+		t.Error("message was stored without an undeliverable store")
+	}
+}
@@ -0,0 +1,44 @@
+package kafka
+
+import (
+	"strconv"
+
+	"github.com/carwale/golibraries/envelope"
+	"github.com/confluentinc/confluent-kafka-go/kafka"
+)
+
+// Headers set on messages published with PublishEnvelope, so that events can be routed without decoding them
+const (
+	HeaderContentType  = "content-type"
+	HeaderEventType    = "event-type"
+	HeaderEventVersion = "event-version"
+)
+
+// PublishEnvelope encodes the envelope and publishes it to the topic with the key.
+// The type and version of the event are also set as headers
+func (kp *Producer) PublishEnvelope(env *envelope.Envelope, topic string, key string) error {
+	data, err := envelope.Encode(env)
+	if err != nil {
+		return err
+	}
+	msg := &kafka.Message{
+		TopicPartition: kafka.TopicPartition{Topic: &topic, Partition: kafka.PartitionAny},
+		Value:          data,
+		Headers: []kafka.Header{
+			{Key: HeaderContentType, Value: []byte(envelope.ContentType)},
+			{Key: HeaderEventType, Value: []byte(env.Type)},
+			{Key: HeaderEventVersion, Value: []byte(strconv.Itoa(env.Version))},
+		},
+	}
+	if key != "" {
+		msg.Key = []byte(key)
+		msg.TopicPartition.Partition = kp.getPartition(topic, msg.Key)
+	}
+	kp.produce(msg)
+	return nil
+}
+
+// Envelope decodes the data of the message as an envelope
+func (m *Message) Envelope() (*envelope.Envelope, error) {
+	return envelope.Decode(m.Data)
+}
@@ -0,0 +1,52 @@
+package kafka
+
+import (
+	"os"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// InstanceMetadata identifies a consumer across processes and restarts
+type InstanceMetadata struct {
+	InstanceID    string    `json:"instance_id"`
+	ConsumerGroup string    `json:"consumer_group"`
+	Topics        []string  `json:"topics"`
+	Host          string    `json:"host"`
+	CreatedAt     time.Time `json:"created_at"`
+}
+
+// SetInstanceID sets the instance id of the consumer, which is used in the logs, as the client.id of the
+// consumer and in the readiness details. Defaults to NewInstanceID(consumerGroupName)
+func SetInstanceID(instanceID string) ConsumerOption {
+	return func(kc *Consumer) { kc.InstanceID = instanceID }
+}
+
+// NewInstanceID returns an id made of the prefix, the pod (POD_NAME, or the hostname) and a random suffix,
+// like leads-consumer-leads-api-7d9f8-x2x4k-1f3a9c2e. It is unique across processes and restarts
+func NewInstanceID(prefix string) string {
+	return prefix + "-" + instanceHost() + "-" + uuid.NewString()[:8]
+}
+
+// Metadata returns the metadata of the consumer
+func (kc *Consumer) Metadata() InstanceMetadata {
+	return InstanceMetadata{
+		InstanceID:    kc.InstanceID,
+		ConsumerGroup: kc.ConsumerGroupName,
+		Topics:        kc.Topics,
+		Host:          instanceHost(),
+		CreatedAt:     kc.createdAt,
+	}
+}
+
+// instanceHost returns the name of the pod from the POD_NAME environment variable (set it with the downward api),
+// or the hostname, which is the pod name by default in kubernetes
+func instanceHost() string {
+	if pod := os.Getenv("POD_NAME"); pod != "" {
+		return pod
+	}
+	if hostname, err := os.Hostname(); err == nil {
+		return hostname
+	}
+	return "unknown"
+}
@@ -0,0 +1,17 @@
+package kafka
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestNewInstanceID(t *testing.T) {
+	t.Setenv("POD_NAME", "leads-api-7d9f8-x2x4k")
+	first, second := NewInstanceID("leads"), NewInstanceID("leads")
+	if !strings.HasPrefix(first, "leads-leads-api-7d9f8-x2x4k-") {
+		t.Errorf("NewInstanceID() = %s, want the prefix and the pod name", first)
+	}
+	if first == second {
+		t.Errorf("NewInstanceID() returned %s twice", first)
+	}
+}
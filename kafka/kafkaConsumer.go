@@ -6,10 +6,17 @@ import (
 	"os"
 	"os/signal"
 	"strconv"
+	"sync"
 	"syscall"
 	"time"
 
+	"github.com/carwale/golibraries/crashreport"
 	"github.com/carwale/golibraries/gologger"
+	"github.com/carwale/golibraries/goutilities"
+	"github.com/carwale/golibraries/healthcheck"
+	"github.com/carwale/golibraries/payloadcrypto"
+	"github.com/carwale/golibraries/poison"
+	"github.com/carwale/golibraries/secrets"
 	"github.com/confluentinc/confluent-kafka-go/kafka"
 )
 
@@ -19,12 +26,11 @@ type RawEvent []byte
 // Message the message that is published to kafka
 type Message struct {
 	Data           RawEvent
+	Key            []byte
 	TopicPartition kafka.TopicPartition
 	Timestamp      time.Time
 }
 
-var consumerInstanceCount int
-
 // IProcessor : interface for consuming messages from queue
 type IProcessor interface {
 	ProcessMessage(*Message) bool
@@ -33,12 +39,14 @@ type IProcessor interface {
 // Consumer holds the configuration for kafka consumers
 type Consumer struct {
 	InstanceID                      string
-	logger                          *gologger.CustomLogger
+	logger                          gologger.ILogger
 	config                          *kafka.ConfigMap
 	BrokerServers                   string
 	Topics                          []string
 	ConsumerGroupName               string
 	Consumer                        *kafka.Consumer
+	consumerLock                    sync.RWMutex // held to close or replace Consumer, see useConsumer
+	closed                          bool
 	CloseChannel                    chan os.Signal
 	enableDL                        bool
 	dlConsumer                      *DLConsumer
@@ -50,6 +58,23 @@ type Consumer struct {
 	ReplayFrom                      time.Duration //duration - defaults to 1h
 	ReplayType                      ReplayType
 	ReplyCompletionChannel          chan bool
+	committer                       func()                       // replaces ForceCommitOffset when offsets are committed by a bridge
+	beforeClose                     func()                       // called by Start before the consumer is closed
+	revokeHandler                   func([]kafka.TopicPartition) // called before the revoked partitions are unassigned
+	eofHandler                      func(kafka.PartitionEOF)     // called when the end of a partition is reached
+	poison                          *poison.Detector
+	readiness                       *healthcheck.ReadinessController
+	readinessComponent              string
+	crashReporter                   *crashreport.Reporter
+	latencyLogger                   gologger.IMultiLogger
+	claimCheckStore                 IBlobStore
+	undeliverableStore              poison.IQuarantineStore
+	cipher                          *payloadcrypto.Cipher
+	watchdog                        *consumerWatchdog
+	createdAt                       time.Time
+	replay                          *replayProgress
+	retries                         *partitionRetries
+	replayProgressInterval          time.Duration
 }
 
 // ForceCommitOffset Methods actually call kafka commit offset API
@@ -60,8 +85,17 @@ func (kc *Consumer) ForceCommitOffset() {
 func (kc *Consumer) commitOffset() {
 	kc.lastOffsetCommitMessageInterval = (kc.lastOffsetCommitMessageInterval + 1) % kc.offsetCommitMessageInterval
 	if kc.lastOffsetCommitMessageInterval == 0 {
-		kc.ForceCommitOffset()
+		kc.commit()
+	}
+}
+
+// commit commits the offsets with the committer if one is set
+func (kc *Consumer) commit() {
+	if kc.committer != nil {
+		kc.committer()
+		return
 	}
+	kc.ForceCommitOffset()
 }
 
 // ConsumerOption sets a parameter for the KafkaProducer
@@ -78,9 +112,15 @@ func SetConsumerCustomConfig(customConfig map[string]interface{}) ConsumerOption
 	}
 }
 
+// SetConsumerSecretConfig sets the config keys to the secrets of the provider, e.g. a secrets.Manager, by
+// config key, like {"sasl.password": "stocks/kafka#password"}. It panics if a secret cannot be read
+func SetConsumerSecretConfig(provider secrets.IProvider, secretsByKey map[string]string) ConsumerOption {
+	return func(kc *Consumer) { setSecretConfig(kc.config, provider, secretsByKey) }
+}
+
 //ConsumerLogger sets the logger for consul
 //Defaults to consul logger
-func ConsumerLogger(customLogger *gologger.CustomLogger) ConsumerOption {
+func ConsumerLogger(customLogger gologger.ILogger) ConsumerOption {
 	return func(kc *Consumer) { kc.logger = customLogger }
 }
 
@@ -142,9 +182,10 @@ func NewKafkaConsumer(brokerServers string, consumerGroupName string, topics []s
 		ReplayMode:                      false,
 		ReplayType:                      TIMESTAMP,
 		ReplayFrom:                      time.Duration(1 * time.Hour),
+		createdAt:                       time.Now(),
+		replayProgressInterval:          30 * time.Second,
 	}
-	consumerInstanceCount++
-	kc.InstanceID = fmt.Sprintf("%s-instance-%d", consumerGroupName, consumerInstanceCount)
+	kc.InstanceID = NewInstanceID(consumerGroupName)
 	signal.Notify(kc.CloseChannel, syscall.SIGINT, syscall.SIGTERM)
 
 	kc.config = &kafka.ConfigMap{
@@ -165,6 +206,20 @@ func NewKafkaConsumer(brokerServers string, consumerGroupName string, topics []s
 	if kc.logger == nil {
 		kc.logger = gologger.NewLogger()
 	}
+	if kc.latencyLogger == nil {
+		kc.latencyLogger = gologger.NewRateLatencyLogger(gologger.SetLogger(kc.logger))
+	}
+	registerKafkaMetrics(kc.latencyLogger, kc.logger)
+	if (kc.claimCheckStore != nil || kc.cipher != nil) && kc.undeliverableStore == nil {
+		msg := fmt.Sprintf("%s needs an undeliverable store with a claim check or encryption", kc.InstanceID)
+		kc.logger.LogErrorWithoutError(msg)
+		panic(msg)
+	}
+	if _, ok := (*kc.config)["client.id"]; !ok {
+		kc.config.SetKey("client.id", kc.InstanceID)
+	}
+	kc.setReadinessDetails()
+	validateConfig(kc.logger, kc.InstanceID, ValidateConsumerConfig(*kc.config))
 	if kc.ReplayMode {
 		kc.config.SetKey("go.application.rebalance.enable", true)
 	}
@@ -175,6 +230,14 @@ func NewKafkaConsumer(brokerServers string, consumerGroupName string, topics []s
 	}
 	kc.Consumer = c
 	kc.logger.LogInfo(fmt.Sprintf("Created %s: %v", kc.InstanceID, c))
+	config := configMap(kc.config)
+	config["topics"] = kc.Topics
+	config["offset_commit_message_interval"] = kc.offsetCommitMessageInterval
+	config["dead_lettering"] = kc.enableDL
+	if kc.ReplayMode {
+		config["replay"] = fmt.Sprintf("%s %s", kc.ReplayType, kc.ReplayFrom)
+	}
+	gologger.LogConfig(kc.logger, kc.InstanceID, config)
 	return kc
 }
 
@@ -200,9 +263,9 @@ func (kc *Consumer) startDeadLetteringConsumer(processor IProcessor) {
 			dlTopics = append(dlTopics, fmt.Sprintf("%s-%s", topic, "DLQ"))
 		}
 		kc.dlConsumer.Topics = dlTopics
-		go func() {
+		goutilities.Go("kafka-dead-letter-consumer", func() {
 			kc.dlConsumer.Start(processor)
-		}()
+		})
 	}
 }
 
@@ -214,20 +277,36 @@ func (kc *Consumer) Start(processor IProcessor) {
 	err := kc.Consumer.SubscribeTopics(kc.Topics, nil)
 	if err != nil {
 		kc.logger.LogError(fmt.Sprintf("Error in topic Subscription for %s:", kc.InstanceID), err)
+		kc.markUnready("subscription failed")
+	} else {
+		kc.markReady()
 	}
 	// If DeadLettering is enable Start the Kafaka DLConsumer
 	kc.logger.LogWarning("Consumer started for topic: " + kc.Topics[0])
 	kc.startDeadLetteringConsumer(processor)
 	consumerStartTime := time.Now()
+	stopWatchdog := kc.startWatchdog()
+	if kc.ReplayMode {
+		kc.replay = newReplayProgress(consumerStartTime)
+	}
+	stopReplayReporter := kc.startReplayReporter()
+	kc.retries = newPartitionRetries()
 consumeloop:
 	for {
 		select {
+		case <-kc.watchdog.restartChannel():
+			if err := kc.restart(); err != nil {
+				kc.logger.LogError("Stopping "+kc.InstanceID, err)
+				break consumeloop
+			}
+		case tp := <-kc.retries.resume:
+			kc.resumePartition(tp)
 		case sig := <-kc.CloseChannel:
 			if kc.enableDL {
 				kc.dlConsumer.CloseChannel <- sig
 			}
 			kc.logger.LogWarning(fmt.Sprintf("Caught signal %v in consumeloop : %s terminating ", sig, kc.InstanceID))
-			kc.ForceCommitOffset()
+			kc.commit()
 			break consumeloop
 		case ev := <-kc.Consumer.Events():
 			if ev == nil {
@@ -239,9 +318,22 @@ consumeloop:
 			}
 		}
 	}
+	close(stopWatchdog)
+	close(stopReplayReporter)
+	close(kc.retries.stop)
 	kc.logger.LogWarning(fmt.Sprintf("Closing %s", kc.InstanceID))
-	kc.Consumer.Close()
+	kc.markUnready("closed")
+	if kc.beforeClose != nil {
+		kc.beforeClose()
+	}
+	kc.consumerLock.Lock()
+	if !kc.closed {
+		kc.Consumer.Close()
+		kc.closed = true
+	}
+	kc.consumerLock.Unlock()
 	if kc.ReplayMode {
+		kc.reportReplay(time.Now())
 		kc.ReplyCompletionChannel <- true
 	}
 }
@@ -256,7 +348,26 @@ func (kc *Consumer) processEvent(ev kafka.Event, processor IProcessor, consumerS
 				return true
 			}
 		}
-		processor.ProcessMessage(&Message{Data: e.Value, TopicPartition: e.TopicPartition, Timestamp: e.Timestamp})
+		if kc.retries.isPaused(e.TopicPartition) {
+			// fetched before the partition was paused, it is consumed again once the partition is resumed
+			return false
+		}
+		kc.watchdog.touch()
+		kc.recordConsumed(e)
+		if kc.replay != nil {
+			kc.replay.record(topicOf(e), e.TopicPartition.Partition, int64(e.TopicPartition.Offset))
+		}
+		if err = kc.resolveClaimCheck(e); err == nil {
+			err = kc.decrypt(e)
+		}
+		if err != nil {
+			if !kc.deliveryFailed(e, err) {
+				return false
+			}
+		} else {
+			kc.retries.delivered(e.TopicPartition)
+			kc.processMessage(processor, &Message{Data: e.Value, Key: e.Key, TopicPartition: e.TopicPartition, Timestamp: e.Timestamp})
+		}
 		//kc.logger.LogDebug(fmt.Sprintf("Message on %s %s: %s Headers: %v", kc.InstanceID,
 		//	e.TopicPartition, string(e.Value), e.Headers))
 		kc.commitOffset()
@@ -267,6 +378,7 @@ func (kc *Consumer) processEvent(ev kafka.Event, processor IProcessor, consumerS
 		kc.logger.LogError(fmt.Sprintf("Error in %s: %v", kc.InstanceID, e.Code()), e)
 		if e.Code() == kafka.ErrUnknownTopicOrPart {
 			kc.logger.LogErrorWithoutError("error is fatal. Exiting")
+			kc.markUnready(e.Error())
 			return true
 		}
 
@@ -304,10 +416,22 @@ func (kc *Consumer) processEvent(ev kafka.Event, processor IProcessor, consumerS
 		}
 
 		kc.Consumer.Assign(partitionsToAssign)
+		if kc.replay != nil {
+			kc.trackReplay(partitionsToAssign)
+		}
+		kc.watchdog.touch()
 	case kafka.RevokedPartitions:
+		if kc.revokeHandler != nil {
+			kc.revokeHandler(e.Partitions)
+		}
+		kc.retries.remove(e.Partitions)
 		kc.Consumer.Unassign()
 	case kafka.PartitionEOF:
+		kc.watchdog.touch()
 		kc.logger.LogWarning("Reached End of partition")
+		if kc.eofHandler != nil {
+			kc.eofHandler(e)
+		}
 		if kc.ReplayMode {
 			return true
 		}
@@ -12,12 +12,10 @@ import (
 	"github.com/confluentinc/confluent-kafka-go/kafka"
 )
 
-var dlConsumerInstanceCount int
-
 //DLConsumer holds the configuration for the DL consumer
 type DLConsumer struct {
 	InstanceID                      string
-	logger                          *gologger.CustomLogger
+	logger                          gologger.ILogger
 	config                          *kafka.ConfigMap
 	BrokerServers                   string
 	Topics                          []string
@@ -47,13 +45,12 @@ func (kc *DLConsumer) applyCustomConfig(customConfig map[string]interface{}) {
 }
 
 // NewKafkaDLConsumer Initialize a DLConsumer for provided configuration
-func NewKafkaDLConsumer(brokerServers string, consumerGroupName string, customConfig map[string]interface{}, logger *gologger.CustomLogger) *DLConsumer {
+func NewKafkaDLConsumer(brokerServers string, consumerGroupName string, customConfig map[string]interface{}, logger gologger.ILogger) *DLConsumer {
 	kc := &DLConsumer{
 		CloseChannel: make(chan os.Signal, 1),
 	}
 	signal.Notify(kc.CloseChannel, syscall.SIGINT, syscall.SIGTERM)
-	dlConsumerInstanceCount++
-	kc.InstanceID = fmt.Sprintf("%s-instance-%d", consumerGroupName, dlConsumerInstanceCount)
+	kc.InstanceID = NewInstanceID(consumerGroupName)
 	kc.logger = logger
 	kc.config = &kafka.ConfigMap{
 		"bootstrap.servers":     brokerServers,
@@ -62,10 +59,12 @@ func NewKafkaDLConsumer(brokerServers string, consumerGroupName string, customCo
 		"session.timeout.ms":    6000,
 		"enable.auto.commit":    false,
 		"auto.offset.reset":     "earliest",
+		"client.id":             kc.InstanceID,
 	}
 	kc.RetryCount = 5
 	kc.RetryDuration = time.Duration(24) * time.Hour
 	kc.applyCustomConfig(customConfig)
+	validateConfig(kc.logger, kc.InstanceID, ValidateConsumerConfig(*kc.config))
 	c, err := kafka.NewConsumer(kc.config)
 	if err != nil {
 		kc.logger.LogError(fmt.Sprintf("Failed to create  %s", kc.InstanceID), err)
@@ -8,12 +8,15 @@ import (
 	"syscall"
 
 	"github.com/carwale/golibraries/gologger"
+	"github.com/carwale/golibraries/goutilities"
+	"github.com/carwale/golibraries/payloadcrypto"
+	"github.com/carwale/golibraries/secrets"
 	"github.com/confluentinc/confluent-kafka-go/kafka"
 )
 
 // Producer carries all the settings for the kafka producer
 type Producer struct {
-	logger                *gologger.CustomLogger
+	logger                gologger.ILogger
 	config                *kafka.ConfigMap
 	BrokerServers         string
 	IsAutoEventLogEnabled bool
@@ -21,6 +24,13 @@ type Producer struct {
 	EventsChannel         chan kafka.Event
 	publishChannel        chan *kafka.Message
 	CloseChannel          chan os.Signal
+	customPartitioner     Partitioner
+	partitionCounts       partitionCountCache
+	deliveryReportHandler func(*kafka.Message)
+	latencyLogger         gologger.IMultiLogger
+	claimCheckStore       IBlobStore
+	claimCheckThreshold   int
+	cipher                *payloadcrypto.Cipher
 }
 
 //KafkaTopic is used to create topics in kafka.
@@ -31,10 +41,17 @@ type KafkaTopic struct {
 }
 
 func (kp *Producer) startEventLogging() {
-	go func() {
+	goutilities.Go("kafka-producer-events", func() {
 		for {
 			select {
 			case event := <-kp.EventsChannel:
+				if m, ok := event.(*kafka.Message); ok {
+					kp.recordDelivery(m)
+					notifyDelivery(m, m.TopicPartition.Error)
+					if kp.deliveryReportHandler != nil {
+						kp.deliveryReportHandler(m)
+					}
+				}
 				if !kp.IsAutoEventLogEnabled {
 					continue
 				}
@@ -55,39 +72,39 @@ func (kp *Producer) startEventLogging() {
 				}
 			}
 		}
-	}()
+	})
 }
 
 func (kp *Producer) setGracefulCleaning() {
-	go func() {
+	goutilities.Go("kafka-producer-close", func() {
 		_ = <-kp.CloseChannel
 		kp.logger.LogWarning("Caught closing signal in producer : terminating")
 		kp.producer.Flush(30000)
 		kp.producer.Close()
 		kp.logger.LogWarning("Gracefully closed producer")
-	}()
+	})
 }
 
 //PublishMessageToTopic publishes message to topic
 func (kp *Producer) PublishMessageToTopic(msg *[]byte, topic string) {
-	kp.publishChannel <- &kafka.Message{
+	kp.produce(&kafka.Message{
 		TopicPartition: kafka.TopicPartition{
 			Topic:     &topic,
 			Partition: kafka.PartitionAny,
 		},
 		Value: *msg,
-	}
+	})
 }
 
 //PublishMessageToTopicWithKey publishes message to topic with key
 func (kp *Producer) PublishMessageToTopicWithKey(msg *[]byte, topic string, key string) {
-	kp.publishChannel <- &kafka.Message{TopicPartition: kafka.TopicPartition{
+	kp.produce(&kafka.Message{TopicPartition: kafka.TopicPartition{
 		Topic:     &topic,
-		Partition: kafka.PartitionAny,
+		Partition: kp.getPartition(topic, []byte(key)),
 	},
 		Key:   []byte(key),
 		Value: *msg,
-	}
+	})
 }
 
 //CreateTopics creats a new topics if they do not exist.
@@ -139,12 +156,24 @@ func SetProducerCustomConfig(customConfig map[string]interface{}) ProducerOption
 	}
 }
 
+// SetProducerSecretConfig sets the config keys to the secrets of the provider, e.g. a secrets.Manager, by
+// config key, like {"sasl.password": "stocks/kafka#password"}. It panics if a secret cannot be read
+func SetProducerSecretConfig(provider secrets.IProvider, secretsByKey map[string]string) ProducerOption {
+	return func(kp *Producer) { setSecretConfig(kp.config, provider, secretsByKey) }
+}
+
 //SetProducerLogger sets the logger for consul
 //Defaults to consul logger
-func SetProducerLogger(customLogger *gologger.CustomLogger) ProducerOption {
+func SetProducerLogger(customLogger gologger.ILogger) ProducerOption {
 	return func(kp *Producer) { kp.logger = customLogger }
 }
 
+// SetDeliveryReportHandler sets a callback that is called with the delivery report of every published message.
+// The callback is called from the event loop of the producer, so it should not block
+func SetDeliveryReportHandler(handler func(*kafka.Message)) ProducerOption {
+	return func(kp *Producer) { kp.deliveryReportHandler = handler }
+}
+
 //EnableEventLogging will enable event logging. By default it is disabled
 func EnableEventLogging(enableEventLogging bool) ProducerOption {
 	return func(kp *Producer) { kp.IsAutoEventLogEnabled = enableEventLogging }
@@ -165,6 +194,7 @@ func NewKafkaProducer(brokerServers string, options ...ProducerOption) *Producer
 	kp := &Producer{
 		CloseChannel:          make(chan os.Signal, 1),
 		IsAutoEventLogEnabled: false,
+		partitionCounts:       partitionCountCache{topics: make(map[string]topicPartitionCount)},
 	}
 	signal.Notify(kp.CloseChannel, syscall.SIGINT, syscall.SIGTERM)
 
@@ -187,6 +217,11 @@ func NewKafkaProducer(brokerServers string, options ...ProducerOption) *Producer
 	if kp.logger == nil {
 		kp.logger = gologger.NewLogger()
 	}
+	if kp.latencyLogger == nil {
+		kp.latencyLogger = gologger.NewRateLatencyLogger(gologger.SetLogger(kp.logger))
+	}
+	registerKafkaMetrics(kp.latencyLogger, kp.logger)
+	validateConfig(kp.logger, "kafka producer", ValidateProducerConfig(*kp.config))
 
 	producer, err := kafka.NewProducer(kp.config)
 	if err != nil {
@@ -197,7 +232,17 @@ func NewKafkaProducer(brokerServers string, options ...ProducerOption) *Producer
 	kp.publishChannel = producer.ProduceChannel()
 	kp.EventsChannel = producer.Events()
 	kp.logger.LogInfo("Created Producer")
+	gologger.LogConfig(kp.logger, "kafka producer", configMap(kp.config))
 	kp.startEventLogging()
 	kp.setGracefulCleaning()
 	return kp
 }
+
+// configMap returns a copy of the config map to be logged
+func configMap(config *kafka.ConfigMap) map[string]interface{} {
+	copied := make(map[string]interface{}, len(*config))
+	for key, value := range *config {
+		copied[key] = value
+	}
+	return copied
+}
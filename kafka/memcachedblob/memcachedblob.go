@@ -0,0 +1,49 @@
+// Package memcachedblob implements the blob store of the kafka claim check with memcached.
+//
+// Memcached rejects the items above its item size limit, 1MB by default, so the store only suits payloads
+// between the claim check threshold and that limit; the producer publishes the larger payloads as they are,
+// which the broker may reject too. Memcached also evicts items before their ttl when it is short of memory,
+// and the consumer does not commit a message whose payload is gone, see kafka.SetUndeliverableStore. Use an
+// object store, like the objectstore package, for payloads of any size that must not be lost
+package memcachedblob
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/carwale/golibraries/memcached"
+)
+
+// Store stores the payloads in memcached for ttl. It implements kafka.IBlobStore
+type Store struct {
+	client *memcached.CacheClient
+	ttl    time.Duration
+}
+
+// NewStore returns a blob store backed by the memcached client.
+// The ttl must be longer than the time the consumers can lag behind
+func NewStore(client *memcached.CacheClient, ttl time.Duration) *Store {
+	return &Store{client: client, ttl: ttl}
+}
+
+// Put adds the payload to memcached
+func (s *Store) Put(ctx context.Context, reference string, payload []byte) error {
+	_, err := s.client.AddItem(reference, payload, int32(s.ttl/time.Second))
+	return err
+}
+
+// Get gets the payload from memcached
+func (s *Store) Get(ctx context.Context, reference string) ([]byte, error) {
+	value, err := s.client.GetItem(reference, 0, func() (interface{}, error) {
+		return nil, errors.New("claim check " + reference + " not found in memcached")
+	})
+	if err != nil {
+		return nil, err
+	}
+	payload, ok := value.([]byte)
+	if !ok {
+		return nil, errors.New("claim check " + reference + " is not a payload")
+	}
+	return payload, nil
+}
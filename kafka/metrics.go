@@ -0,0 +1,120 @@
+package kafka
+
+import (
+	"github.com/carwale/golibraries/gologger"
+	"github.com/confluentinc/confluent-kafka-go/kafka"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const (
+	producedBytesMetricID   = "KAFKA-PRODUCED-BYTES"
+	producedSizeMetricID    = "KAFKA-PRODUCED-MESSAGE-SIZE"
+	consumedBytesMetricID   = "KAFKA-CONSUMED-BYTES"
+	consumedSizeMetricID    = "KAFKA-CONSUMED-MESSAGE-SIZE"
+	consumerStallsMetricID  = "KAFKA-CONSUMER-STALLS"
+	replayRemainingMetricID = "KAFKA-REPLAY-REMAINING"
+)
+
+// messageSizeBuckets are the buckets of the message size histograms in bytes, from 64B to 4MB
+var messageSizeBuckets = prometheus.ExponentialBuckets(64, 4, 9)
+
+var kafkaMetrics = gologger.NewMetricSet(func(logger gologger.ILogger) map[string]gologger.IMetricVec {
+	return map[string]gologger.IMetricVec{
+		producedBytesMetricID: gologger.NewCounterMetric(prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "kafka_produced_bytes_total",
+				Help: "Bytes of the messages delivered or failed per topic, including keys and headers",
+			},
+			[]string{"Topic", "Status"},
+		), logger),
+		producedSizeMetricID: gologger.NewValueHistogramMetric(prometheus.NewHistogramVec(
+			prometheus.HistogramOpts{
+				Name:    "kafka_produced_message_size_bytes",
+				Help:    "Size of the delivered messages per topic, including keys and headers",
+				Buckets: messageSizeBuckets,
+			},
+			[]string{"Topic"},
+		), logger),
+		consumedBytesMetricID: gologger.NewCounterMetric(prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "kafka_consumed_bytes_total",
+				Help: "Bytes of the consumed messages per topic, including keys and headers",
+			},
+			[]string{"Topic"},
+		), logger),
+		consumedSizeMetricID: gologger.NewValueHistogramMetric(prometheus.NewHistogramVec(
+			prometheus.HistogramOpts{
+				Name:    "kafka_consumed_message_size_bytes",
+				Help:    "Size of the consumed messages per topic, including keys and headers",
+				Buckets: messageSizeBuckets,
+			},
+			[]string{"Topic"},
+		), logger),
+		consumerStallsMetricID: gologger.NewCounterMetric(prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "kafka_consumer_stalls_total",
+				Help: "Number of times the watchdog found a consumer with lag that received no events",
+			},
+			[]string{"ConsumerGroup"},
+		), logger),
+		replayRemainingMetricID: gologger.NewGaugeMetric(prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "kafka_replay_remaining_messages",
+				Help: "Messages left to replay per partition by a consumer in replay mode",
+			},
+			[]string{"ConsumerGroup", "Topic", "Partition"},
+		), logger),
+	}
+})
+
+// SetProducerLatencyLogger sets the latency logger used for the payload size metrics of the producer.
+// Defaults to the rate latency logger
+func SetProducerLatencyLogger(latencyLogger gologger.IMultiLogger) ProducerOption {
+	return func(kp *Producer) { kp.latencyLogger = latencyLogger }
+}
+
+// SetConsumerLatencyLogger sets the latency logger used for the payload size metrics of the consumer.
+// Defaults to the rate latency logger
+func SetConsumerLatencyLogger(latencyLogger gologger.IMultiLogger) ConsumerOption {
+	return func(kc *Consumer) { kc.latencyLogger = latencyLogger }
+}
+
+func registerKafkaMetrics(latencyLogger gologger.IMultiLogger, logger gologger.ILogger) {
+	kafkaMetrics.AddTo(latencyLogger, logger)
+}
+
+// messageSize returns the size of the key, value and headers of the message
+func messageSize(m *kafka.Message) int64 {
+	size := len(m.Key) + len(m.Value)
+	for _, header := range m.Headers {
+		size += len(header.Key) + len(header.Value)
+	}
+	return int64(size)
+}
+
+func topicOf(m *kafka.Message) string {
+	if m.TopicPartition.Topic == nil {
+		return ""
+	}
+	return *m.TopicPartition.Topic
+}
+
+// recordDelivery records the size of a message from its delivery report
+func (kp *Producer) recordDelivery(m *kafka.Message) {
+	topic := topicOf(m)
+	size := messageSize(m)
+	if m.TopicPartition.Error != nil {
+		kp.latencyLogger.IncVal(size, producedBytesMetricID, topic, "failed")
+		return
+	}
+	kp.latencyLogger.IncVal(size, producedBytesMetricID, topic, "delivered")
+	kp.latencyLogger.IncVal(size, producedSizeMetricID, topic)
+}
+
+// recordConsumed records the size of a consumed message
+func (kc *Consumer) recordConsumed(m *kafka.Message) {
+	topic := topicOf(m)
+	size := messageSize(m)
+	kc.latencyLogger.IncVal(size, consumedBytesMetricID, topic)
+	kc.latencyLogger.IncVal(size, consumedSizeMetricID, topic)
+}
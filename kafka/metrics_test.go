@@ -0,0 +1,21 @@
+package kafka
+
+import (
+	"testing"
+
+	"github.com/confluentinc/confluent-kafka-go/kafka"
+)
+
+func TestMessageSize(t *testing.T) {
+	m := &kafka.Message{
+		Key:     []byte("stock-1"),
+		Value:   []byte(`{"id":1}`),
+		Headers: []kafka.Header{{Key: "event-type", Value: []byte("stock")}},
+	}
+	if got := messageSize(m); got != 7+8+10+5 {
+		t.Errorf("messageSize() = %d, want 30", got)
+	}
+	if got := topicOf(m); got != "" {
+		t.Errorf("topicOf() = %q for a message without topic", got)
+	}
+}
@@ -0,0 +1,81 @@
+package kafka
+
+import (
+	"sync"
+	"syscall"
+
+	"github.com/carwale/golibraries/gologger"
+	"github.com/carwale/golibraries/goutilities"
+)
+
+// ConsumerGroupSpec describes one of the consumer groups of a MultiGroupConsumer
+type ConsumerGroupSpec struct {
+	ConsumerGroupName string
+	Processor         IProcessor
+	// Options are applied after the common options of the MultiGroupConsumer
+	Options []ConsumerOption
+}
+
+// MultiGroupConsumer runs multiple consumers with different consumer groups on the same topics
+// in one process. Every consumer group has its own processor, so the same topic can be used
+// for real time processing and a lagging audit pipeline.
+// All the consumers share a lifecycle. If one of them stops, the others are stopped too.
+type MultiGroupConsumer struct {
+	consumers  []*Consumer
+	processors []IProcessor
+	logger     gologger.ILogger
+	stopOnce   sync.Once
+}
+
+// NewMultiGroupConsumer creates a consumer for every consumer group spec. The common options are applied to all the consumers
+func NewMultiGroupConsumer(brokerServers string, topics []string, specs []ConsumerGroupSpec, options ...ConsumerOption) *MultiGroupConsumer {
+	if len(specs) == 0 {
+		panic("At least one consumer group spec is required for multi group consumer")
+	}
+	mc := &MultiGroupConsumer{}
+	for _, spec := range specs {
+		if spec.Processor == nil {
+			panic("Processor cannot be nil for consumer group " + spec.ConsumerGroupName)
+		}
+		consumerOptions := append(append([]ConsumerOption{}, options...), spec.Options...)
+		consumer := NewKafkaConsumer(brokerServers, spec.ConsumerGroupName, topics, consumerOptions...)
+		mc.consumers = append(mc.consumers, consumer)
+		mc.processors = append(mc.processors, spec.Processor)
+	}
+	mc.logger = mc.consumers[0].logger
+	return mc
+}
+
+// Consumers returns the consumers in the same order as the consumer group specs
+func (mc *MultiGroupConsumer) Consumers() []*Consumer {
+	return mc.consumers
+}
+
+// Start starts all the consumers and blocks till all of them have stopped
+func (mc *MultiGroupConsumer) Start() {
+	var wg sync.WaitGroup
+	for i := range mc.consumers {
+		wg.Add(1)
+		consumer, processor := mc.consumers[i], mc.processors[i]
+		goutilities.Go("kafka-consumer-"+consumer.InstanceID, func() {
+			defer wg.Done()
+			consumer.Start(processor)
+			mc.logger.LogWarningf("Consumer %s stopped. Stopping all consumer groups", consumer.InstanceID)
+			mc.Stop()
+		})
+	}
+	wg.Wait()
+}
+
+// Stop signals all the consumers to commit their offsets and stop
+func (mc *MultiGroupConsumer) Stop() {
+	mc.stopOnce.Do(func() {
+		for _, consumer := range mc.consumers {
+			select {
+			case consumer.CloseChannel <- syscall.SIGTERM:
+			default:
+				// a signal is already pending for this consumer
+			}
+		}
+	})
+}
@@ -0,0 +1,151 @@
+package kafka
+
+import (
+	"fmt"
+	"io"
+	"text/tabwriter"
+	"time"
+
+	"github.com/confluentinc/confluent-kafka-go/kafka"
+)
+
+// offsetToolTimeout is the timeout of the broker calls made by the offset tools
+const offsetToolTimeout = 10 * time.Second
+
+// OffsetStatus is the committed and the latest offset of a partition for the consumer group
+type OffsetStatus struct {
+	Topic     string
+	Partition int32
+	// Committed is negative if the consumer group has not committed an offset for the partition
+	Committed int64
+	Latest    int64
+	Lag       int64
+}
+
+// The offset tools below commit offsets for the consumer group of the consumer. They are meant for incident
+// recovery and must be used on a consumer which was created but not started, with all the other members of the
+// consumer group stopped. Otherwise the running members overwrite the offsets with their next commit.
+
+// SeekToTimestamp commits, for every partition of the topics of the consumer, the earliest offset whose timestamp
+// is at or after t. Partitions without such a message are moved to their end
+func (kc *Consumer) SeekToTimestamp(t time.Time) error {
+	partitions, err := kc.topicPartitions()
+	if err != nil {
+		return err
+	}
+	for i := range partitions {
+		partitions[i].Offset = kafka.Offset(t.UnixNano() / int64(time.Millisecond))
+	}
+	offsets, err := kc.Consumer.OffsetsForTimes(partitions, int(offsetToolTimeout/time.Millisecond))
+	if err != nil {
+		return fmt.Errorf("could not get the offsets for %s: %w", t, err)
+	}
+	for i, tp := range offsets {
+		if tp.Offset < 0 {
+			_, high, err := kc.Consumer.QueryWatermarkOffsets(*tp.Topic, tp.Partition, int(offsetToolTimeout/time.Millisecond))
+			if err != nil {
+				return fmt.Errorf("could not get the latest offset of %s: %w", tp, err)
+			}
+			offsets[i].Offset = kafka.Offset(high)
+		}
+	}
+	return kc.commitOffsets(offsets)
+}
+
+// SeekToOffset commits the offsets, by partition, for the topic
+func (kc *Consumer) SeekToOffset(topic string, offsets map[int32]int64) error {
+	partitions := make([]kafka.TopicPartition, 0, len(offsets))
+	for partition, offset := range offsets {
+		partitions = append(partitions, kafka.TopicPartition{Topic: &topic, Partition: partition, Offset: kafka.Offset(offset)})
+	}
+	return kc.commitOffsets(partitions)
+}
+
+// ResetToEnd commits the latest offset of every partition of the topics of the consumer, skipping all the pending messages
+func (kc *Consumer) ResetToEnd() error {
+	statuses, err := kc.Offsets()
+	if err != nil {
+		return err
+	}
+	partitions := make([]kafka.TopicPartition, 0, len(statuses))
+	for _, status := range statuses {
+		topic := status.Topic
+		partitions = append(partitions, kafka.TopicPartition{Topic: &topic, Partition: status.Partition, Offset: kafka.Offset(status.Latest)})
+	}
+	return kc.commitOffsets(partitions)
+}
+
+// Offsets returns the committed and the latest offset of every partition of the topics of the consumer
+func (kc *Consumer) Offsets() ([]OffsetStatus, error) {
+	partitions, err := kc.topicPartitions()
+	if err != nil {
+		return nil, err
+	}
+	committed, err := kc.Consumer.Committed(partitions, int(offsetToolTimeout/time.Millisecond))
+	if err != nil {
+		return nil, fmt.Errorf("could not get the committed offsets of %s: %w", kc.ConsumerGroupName, err)
+	}
+	statuses := make([]OffsetStatus, 0, len(committed))
+	for _, tp := range committed {
+		_, high, err := kc.Consumer.QueryWatermarkOffsets(*tp.Topic, tp.Partition, int(offsetToolTimeout/time.Millisecond))
+		if err != nil {
+			return nil, fmt.Errorf("could not get the latest offset of %s: %w", tp, err)
+		}
+		statuses = append(statuses, newOffsetStatus(*tp.Topic, tp.Partition, int64(tp.Offset), high))
+	}
+	return statuses, nil
+}
+
+// PrintOffsets writes the committed and the latest offset of every partition of the topics of the consumer as a table
+func (kc *Consumer) PrintOffsets(w io.Writer) error {
+	statuses, err := kc.Offsets()
+	if err != nil {
+		return err
+	}
+	return writeOffsets(w, kc.ConsumerGroupName, statuses)
+}
+
+func newOffsetStatus(topic string, partition int32, committed int64, latest int64) OffsetStatus {
+	status := OffsetStatus{Topic: topic, Partition: partition, Committed: committed, Latest: latest, Lag: latest}
+	if committed >= 0 {
+		status.Lag = latest - committed
+	}
+	return status
+}
+
+func writeOffsets(w io.Writer, consumerGroupName string, statuses []OffsetStatus) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(tw, "GROUP\tTOPIC\tPARTITION\tCOMMITTED\tLATEST\tLAG\n")
+	for _, status := range statuses {
+		committed := "-"
+		if status.Committed >= 0 {
+			committed = fmt.Sprint(status.Committed)
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%d\t%s\t%d\t%d\n", consumerGroupName, status.Topic, status.Partition, committed, status.Latest, status.Lag)
+	}
+	return tw.Flush()
+}
+
+// topicPartitions returns all the partitions of the topics of the consumer
+func (kc *Consumer) topicPartitions() ([]kafka.TopicPartition, error) {
+	var partitions []kafka.TopicPartition
+	for _, topic := range kc.Topics {
+		topic := topic
+		metadata, err := kc.Consumer.GetMetadata(&topic, false, int(offsetToolTimeout/time.Millisecond))
+		if err != nil {
+			return nil, fmt.Errorf("could not get the metadata of %s: %w", topic, err)
+		}
+		for _, partition := range metadata.Topics[topic].Partitions {
+			partitions = append(partitions, kafka.TopicPartition{Topic: &topic, Partition: partition.ID})
+		}
+	}
+	return partitions, nil
+}
+
+func (kc *Consumer) commitOffsets(partitions []kafka.TopicPartition) error {
+	if _, err := kc.Consumer.CommitOffsets(partitions); err != nil {
+		return fmt.Errorf("could not commit the offsets of %s: %w", kc.ConsumerGroupName, err)
+	}
+	kc.logger.LogWarningf("Committed offsets of %s: %v", kc.ConsumerGroupName, partitions)
+	return nil
+}
@@ -0,0 +1,25 @@
+package kafka
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestWriteOffsets(t *testing.T) {
+	statuses := []OffsetStatus{
+		newOffsetStatus("leads", 0, 90, 100),
+		newOffsetStatus("leads", 1, -1001, 40),
+	}
+	if statuses[0].Lag != 10 || statuses[1].Lag != 40 {
+		t.Fatalf("lags = %d, %d, want 10 and 40", statuses[0].Lag, statuses[1].Lag)
+	}
+	var out bytes.Buffer
+	if err := writeOffsets(&out, "lead-sync", statuses); err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	if len(lines) != 3 || strings.Fields(lines[2])[3] != "-" {
+		t.Errorf("output = %q, want a header and 2 rows with no committed offset for partition 1", out.String())
+	}
+}
@@ -0,0 +1,167 @@
+package kafka
+
+import (
+	"sync"
+	"time"
+
+	"github.com/confluentinc/confluent-kafka-go/kafka"
+)
+
+// Partitioners supported natively by librdkafka. These can be set using the SetPartitioner option
+const (
+	// PartitionerRandom assigns a random partition to every message
+	PartitionerRandom = "random"
+	// PartitionerConsistent uses CRC32 hash of the key. Empty and NULL keys are mapped to a single partition
+	PartitionerConsistent = "consistent"
+	// PartitionerConsistentRandom uses CRC32 hash of the key. Empty and NULL keys are randomly partitioned
+	PartitionerConsistentRandom = "consistent_random"
+	// PartitionerMurmur2 uses Java producer compatible Murmur2 hash of the key. NULL keys are mapped to a single partition
+	PartitionerMurmur2 = "murmur2"
+	// PartitionerMurmur2Random uses Java producer compatible Murmur2 hash of the key. NULL keys are randomly partitioned
+	PartitionerMurmur2Random = "murmur2_random"
+	// PartitionerFnv1a uses FNV-1a hash of the key. NULL keys are mapped to a single partition
+	PartitionerFnv1a = "fnv1a"
+	// PartitionerFnv1aRandom uses FNV-1a hash of the key. NULL keys are randomly partitioned
+	PartitionerFnv1aRandom = "fnv1a_random"
+)
+
+// partitionCountRefreshInterval is the time after which the cached partition count of a topic is refreshed
+const partitionCountRefreshInterval = 5 * time.Minute
+
+// Partitioner is a go callback that returns the partition for a key given the partition count of the topic.
+// The returned partition should be in the range [0, partitionCount)
+type Partitioner func(key []byte, partitionCount int32) int32
+
+// SetPartitioner sets the librdkafka partitioner for the producer. Use one of the Partitioner constants.
+// Default is consistent_random
+func SetPartitioner(partitioner string) ProducerOption {
+	return func(kp *Producer) {
+		if partitioner != "" {
+			kp.config.SetKey("partitioner", partitioner)
+		}
+	}
+}
+
+// SetStickyPartitioning enables the sticky partitioner for messages without keys.
+// Messages without keys will be sent to the same partition for lingerMs milliseconds
+// before switching to a new partition
+func SetStickyPartitioning(lingerMs int) ProducerOption {
+	return func(kp *Producer) {
+		if lingerMs > 0 {
+			kp.config.SetKey("sticky.partitioning.linger.ms", lingerMs)
+		}
+	}
+}
+
+// SetCustomPartitioner sets a go callback partitioner for the producer.
+// It is only used for messages that are published with a key. The partition count of the
+// topic is fetched from kafka metadata and cached for 5 minutes.
+// Use JavaDefaultPartitioner to co-partition with topics written by java producers
+func SetCustomPartitioner(partitioner Partitioner) ProducerOption {
+	return func(kp *Producer) { kp.customPartitioner = partitioner }
+}
+
+// JavaDefaultPartitioner is the partitioner used by the default java producer for messages with keys.
+// It uses the murmur2 hash of the key
+func JavaDefaultPartitioner(key []byte, partitionCount int32) int32 {
+	return toPositive(murmur2(key)) % partitionCount
+}
+
+// toPositive converts a number to a positive number the same way as the java client does
+func toPositive(number int32) int32 {
+	return number & 0x7fffffff
+}
+
+// murmur2 generates a 32-bit murmur2 hash for the given byte array.
+// This is the same implementation as org.apache.kafka.common.utils.Utils.murmur2
+func murmur2(data []byte) int32 {
+	const (
+		seed uint32 = 0x9747b28c
+		m    uint32 = 0x5bd1e995
+		r           = 24
+	)
+	length := len(data)
+	h := seed ^ uint32(length)
+	length4 := length / 4
+	for i := 0; i < length4; i++ {
+		i4 := i * 4
+		k := uint32(data[i4]) | uint32(data[i4+1])<<8 | uint32(data[i4+2])<<16 | uint32(data[i4+3])<<24
+		k *= m
+		k ^= k >> r
+		k *= m
+		h *= m
+		h ^= k
+	}
+	switch length % 4 {
+	case 3:
+		h ^= uint32(data[(length&^3)+2]) << 16
+		fallthrough
+	case 2:
+		h ^= uint32(data[(length&^3)+1]) << 8
+		fallthrough
+	case 1:
+		h ^= uint32(data[length&^3])
+		h *= m
+	}
+	h ^= h >> 13
+	h *= m
+	h ^= h >> 15
+	return int32(h)
+}
+
+// topicPartitionCount holds the cached partition count of a topic
+type topicPartitionCount struct {
+	count     int32
+	fetchedAt time.Time
+}
+
+// partitionCountCache caches the partition count of topics for the custom partitioner
+type partitionCountCache struct {
+	sync.RWMutex
+	topics map[string]topicPartitionCount
+}
+
+// getPartition returns the partition for the key using the custom partitioner.
+// It returns kafka.PartitionAny if no custom partitioner is set, the partition count could not be fetched or
+// the custom partitioner returned a partition out of range
+func (kp *Producer) getPartition(topic string, key []byte) int32 {
+	if kp.customPartitioner == nil || key == nil {
+		return kafka.PartitionAny
+	}
+	partitionCount := kp.getPartitionCount(topic)
+	if partitionCount <= 0 {
+		return kafka.PartitionAny
+	}
+	partition := kp.customPartitioner(key, partitionCount)
+	if partition < 0 || partition >= partitionCount {
+		kp.logger.LogWarningf("Custom partitioner returned partition %d for topic %s with %d partitions", partition, topic, partitionCount)
+		return kafka.PartitionAny
+	}
+	return partition
+}
+
+// getPartitionCount returns the partition count of the topic from cache, refreshing it from metadata if stale
+func (kp *Producer) getPartitionCount(topic string) int32 {
+	kp.partitionCounts.RLock()
+	cached, ok := kp.partitionCounts.topics[topic]
+	kp.partitionCounts.RUnlock()
+	if ok && time.Since(cached.fetchedAt) < partitionCountRefreshInterval {
+		return cached.count
+	}
+
+	metadata, err := kp.producer.GetMetadata(&topic, false, 5000)
+	if err != nil {
+		kp.logger.LogError("Could not get metadata for topic "+topic, err)
+		return cached.count
+	}
+	topicMetadata, ok := metadata.Topics[topic]
+	if !ok || len(topicMetadata.Partitions) == 0 {
+		kp.logger.LogWarningf("Partition count not found in metadata for topic %s", topic)
+		return cached.count
+	}
+	count := int32(len(topicMetadata.Partitions))
+	kp.partitionCounts.Lock()
+	kp.partitionCounts.topics[topic] = topicPartitionCount{count: count, fetchedAt: time.Now()}
+	kp.partitionCounts.Unlock()
+	return count
+}
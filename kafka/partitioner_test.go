@@ -0,0 +1,51 @@
+package kafka
+
+import (
+	"testing"
+	"time"
+
+	"github.com/carwale/golibraries/gologger"
+	"github.com/confluentinc/confluent-kafka-go/kafka"
+)
+
+func TestMurmur2(t *testing.T) {
+	// Expected values are taken from the java client's UtilsTest
+	tests := map[string]int32{
+		"21":                         -973932308,
+		"foobar":                     -790332482,
+		"a-little-bit-long-string":   -985981536,
+		"a-little-bit-longer-string": -1486304829,
+		"lkjh234lh9fiuh90y23oiuhsafujhadof229phr9h19h89h8": -58897971,
+		"abc": 479470107,
+	}
+	for key, want := range tests {
+		if got := murmur2([]byte(key)); got != want {
+			t.Errorf("murmur2(%q) = %d, want %d", key, got, want)
+		}
+	}
+}
+
+func TestJavaDefaultPartitioner(t *testing.T) {
+	for _, key := range []string{"21", "foobar", "abc", ""} {
+		partition := JavaDefaultPartitioner([]byte(key), 12)
+		if partition < 0 || partition >= 12 {
+			t.Errorf("JavaDefaultPartitioner(%q, 12) = %d, out of range", key, partition)
+		}
+	}
+}
+
+func TestGetPartitionFallsBackOnOutOfRangePartition(t *testing.T) {
+	for partition, want := range map[int32]int32{2: 2, 4: kafka.PartitionAny, -1: kafka.PartitionAny} {
+		partition := partition
+		kp := &Producer{
+			logger:            gologger.NewLogger(),
+			customPartitioner: func(key []byte, partitionCount int32) int32 { return partition },
+			partitionCounts: partitionCountCache{topics: map[string]topicPartitionCount{
+				"orders": {count: 4, fetchedAt: time.Now()},
+			}},
+		}
+		if got := kp.getPartition("orders", []byte("key")); got != want {
+			t.Errorf("getPartition() with the partitioner returning %d = %d, want %d", partition, got, want)
+		}
+	}
+}
@@ -0,0 +1,87 @@
+package kafka
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/carwale/golibraries/crashreport"
+	"github.com/carwale/golibraries/poison"
+	"github.com/confluentinc/confluent-kafka-go/kafka"
+)
+
+// SetPoisonDetector sets the detector used to quarantine messages that keep failing.
+// Every message for which the processor returns false is recorded as a failure, and messages
+// that have been quarantined are skipped without calling the processor
+func SetPoisonDetector(detector *poison.Detector) ConsumerOption {
+	return func(kc *Consumer) { kc.poison = detector }
+}
+
+// NewQuarantineTopicStore returns a store which publishes the quarantined messages as json to the topic.
+// The fingerprint of the message is used as the key
+func NewQuarantineTopicStore(producer *Producer, topic string) poison.IQuarantineStore {
+	return poison.StoreFunc(func(msg poison.QuarantinedMessage) error {
+		body, err := json.Marshal(msg)
+		if err != nil {
+			return err
+		}
+		producer.PublishMessageToTopicWithKey(&body, topic, msg.Fingerprint)
+		return nil
+	})
+}
+
+// SetUndeliverableStore sets the store of the messages which cannot be given to the processor, e.g. when the
+// payload of a claim check cannot be fetched. It is required with SetConsumerClaimCheck and SetConsumerEncryption.
+// The partition of such a message is paused and the message retried with a backoff; after 3 attempts it is put in
+// the store and its offset committed. While the store fails the partition keeps being retried, with the backoff
+// capped at 30 seconds. NewQuarantineTopicStore can be used as a dead letter topic
+func SetUndeliverableStore(store poison.IQuarantineStore) ConsumerOption {
+	return func(kc *Consumer) { kc.undeliverableStore = store }
+}
+
+// SetConsumerCrashReporter reports a panic of the processor with a crash report before the consumer crashes
+func SetConsumerCrashReporter(reporter *crashreport.Reporter) ConsumerOption {
+	return func(kc *Consumer) { kc.crashReporter = reporter }
+}
+
+// processMessage calls the processor unless the message has been quarantined and records the outcome
+func (kc *Consumer) processMessage(processor IProcessor, msg *Message) {
+	if kc.crashReporter != nil {
+		defer kc.crashReporter.Recover("kafka consumer " + kc.ConsumerGroupName)
+	}
+	if kc.poison == nil {
+		processor.ProcessMessage(msg)
+		return
+	}
+	if kc.poison.IsQuarantined(msg.Data) {
+		kc.logger.LogWarning("Skipping quarantined message on " + msg.TopicPartition.String())
+		return
+	}
+	if processor.ProcessMessage(msg) {
+		kc.poison.RecordSuccess(msg.Data)
+	} else {
+		kc.poison.RecordFailure(msg.Data, nil)
+	}
+}
+
+// storeUndeliverable puts the message which could not be given to the processor in the undeliverable store.
+// It returns false if the message was not stored and its offset must not be committed
+func (kc *Consumer) storeUndeliverable(m *kafka.Message, cause error) bool {
+	if kc.undeliverableStore == nil {
+		return false
+	}
+	now := time.Now()
+	err := kc.undeliverableStore.Quarantine(poison.QuarantinedMessage{
+		Fingerprint:  poison.Fingerprint(m.Value),
+		Source:       topicOf(m),
+		Payload:      m.Value,
+		Attempts:     1,
+		FirstFailure: now,
+		LastFailure:  now,
+		LastError:    cause.Error(),
+	})
+	if err != nil {
+		kc.logger.LogError("could not store the undeliverable message on "+m.TopicPartition.String(), err)
+		return false
+	}
+	return true
+}
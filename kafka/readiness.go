@@ -0,0 +1,36 @@
+package kafka
+
+import "github.com/carwale/golibraries/healthcheck"
+
+// SetConsumerReadiness registers the consumer as a component of the readiness controller.
+// The component is ready once the consumer has subscribed to its topics, and not ready after a fatal error or when the consumer is closed
+func SetConsumerReadiness(rc *healthcheck.ReadinessController, component string) ConsumerOption {
+	return func(kc *Consumer) {
+		rc.Register(component)
+		kc.readiness = rc
+		kc.readinessComponent = component
+	}
+}
+
+func (kc *Consumer) markReady() {
+	if kc.readiness != nil {
+		kc.readiness.MarkReady(kc.readinessComponent)
+	}
+}
+
+func (kc *Consumer) markUnready(reason string) {
+	if kc.readiness != nil {
+		kc.readiness.MarkUnready(kc.readinessComponent, reason)
+	}
+}
+
+// setReadinessDetails adds the metadata of the consumer to its readiness component
+func (kc *Consumer) setReadinessDetails() {
+	if kc.readiness != nil {
+		kc.readiness.SetDetails(kc.readinessComponent, map[string]string{
+			"instance_id":    kc.InstanceID,
+			"consumer_group": kc.ConsumerGroupName,
+			"host":           instanceHost(),
+		})
+	}
+}
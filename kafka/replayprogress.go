@@ -0,0 +1,161 @@
+package kafka
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/carwale/golibraries/goutilities"
+	"github.com/confluentinc/confluent-kafka-go/kafka"
+)
+
+// SetReplayProgressInterval sets how often the progress of a replay is logged and the remaining messages gauge
+// is updated. Only used in replay mode. Defaults to 30 seconds
+func SetReplayProgressInterval(interval time.Duration) ConsumerOption {
+	return func(kc *Consumer) { kc.replayProgressInterval = interval }
+}
+
+// partitionReplay is the progress of the replay of a partition
+type partitionReplay struct {
+	topic     string
+	partition int32
+	start     int64
+	current   int64
+	target    int64
+}
+
+func (p *partitionReplay) remaining() int64 {
+	if p.current >= p.target {
+		return 0
+	}
+	return p.target - p.current
+}
+
+// replayProgress tracks the progress of a replay from the offsets the partitions were reset to
+// up to their high watermarks at the time of the assignment
+type replayProgress struct {
+	lock       sync.Mutex
+	partitions map[string]*partitionReplay
+	startedAt  time.Time
+	consumed   int64
+}
+
+func newReplayProgress(startedAt time.Time) *replayProgress {
+	return &replayProgress{partitions: map[string]*partitionReplay{}, startedAt: startedAt}
+}
+
+func replayKey(topic string, partition int32) string {
+	return topic + "/" + strconv.Itoa(int(partition))
+}
+
+// assign starts tracking the partition
+func (r *replayProgress) assign(topic string, partition int32, start int64, target int64) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	r.partitions[replayKey(topic, partition)] = &partitionReplay{topic: topic, partition: partition, start: start, current: start, target: target}
+}
+
+// record moves the partition of the message past its offset
+func (r *replayProgress) record(topic string, partition int32, offset int64) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	r.consumed++
+	if p, ok := r.partitions[replayKey(topic, partition)]; ok && offset+1 > p.current {
+		p.current = offset + 1
+	}
+}
+
+// snapshot returns the progress of the partitions sorted by topic and partition,
+// the rate of the replay in messages per second and the estimated time to finish it
+func (r *replayProgress) snapshot(now time.Time) ([]partitionReplay, float64, time.Duration) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	partitions := make([]partitionReplay, 0, len(r.partitions))
+	var remaining int64
+	for _, p := range r.partitions {
+		partitions = append(partitions, *p)
+		remaining += p.remaining()
+	}
+	sort.Slice(partitions, func(i, j int) bool {
+		if partitions[i].topic != partitions[j].topic {
+			return partitions[i].topic < partitions[j].topic
+		}
+		return partitions[i].partition < partitions[j].partition
+	})
+	var rate float64
+	if elapsed := now.Sub(r.startedAt).Seconds(); elapsed > 0 {
+		rate = float64(r.consumed) / elapsed
+	}
+	var eta time.Duration
+	if rate > 0 {
+		eta = time.Duration(float64(remaining) / rate * float64(time.Second))
+	}
+	return partitions, rate, eta
+}
+
+// summary returns a one line description of the progress
+func (r *replayProgress) summary(now time.Time) string {
+	partitions, rate, eta := r.snapshot(now)
+	var replayed, total int64
+	for _, p := range partitions {
+		replayed += p.current - p.start
+		total += p.target - p.start
+	}
+	return fmt.Sprintf("replayed %d of %d messages in %d partitions in %s at %.1f messages/s, ETA %s",
+		replayed, total, len(partitions), now.Sub(r.startedAt).Round(time.Second), rate, eta.Round(time.Second))
+}
+
+// trackReplay starts tracking the replay of the assigned partitions, which have been reset to their replay offsets
+func (kc *Consumer) trackReplay(partitions []kafka.TopicPartition) {
+	for _, tp := range partitions {
+		low, high, err := kc.Consumer.QueryWatermarkOffsets(*tp.Topic, tp.Partition, 5000)
+		if err != nil {
+			kc.logger.LogError(fmt.Sprintf("could not get the watermarks of %s for the replay progress", tp), err)
+			continue
+		}
+		start := int64(tp.Offset)
+		switch {
+		case tp.Offset == kafka.OffsetEnd:
+			// no message after the replay timestamp
+			start = high
+		case start < low:
+			// OffsetBeginning, or an offset removed by the retention
+			start = low
+		}
+		kc.replay.assign(*tp.Topic, tp.Partition, start, high)
+	}
+}
+
+// startReplayReporter logs the progress of the replay every interval till the returned channel is closed
+func (kc *Consumer) startReplayReporter() chan struct{} {
+	stop := make(chan struct{})
+	if kc.replay == nil {
+		return stop
+	}
+	goutilities.Go("replay-progress "+kc.InstanceID, func() {
+		ticker := time.NewTicker(kc.replayProgressInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-stop:
+				return
+			case now := <-ticker.C:
+				kc.reportReplay(now)
+			}
+		}
+	})
+	return stop
+}
+
+// reportReplay logs the progress of every partition and updates the remaining messages gauge
+func (kc *Consumer) reportReplay(now time.Time) {
+	partitions, _, _ := kc.replay.snapshot(now)
+	for _, p := range partitions {
+		kc.logger.LogWarningf("%s replay of %s [%d]: offset %d of %d, %d messages remaining",
+			kc.InstanceID, p.topic, p.partition, p.current, p.target, p.remaining())
+		kc.latencyLogger.SetVal(p.remaining(), replayRemainingMetricID, kc.ConsumerGroupName, p.topic, strconv.Itoa(int(p.partition)))
+	}
+	kc.logger.LogWarningf("%s %s", kc.InstanceID, kc.replay.summary(now))
+}
@@ -0,0 +1,28 @@
+package kafka
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestReplayProgress(t *testing.T) {
+	start := time.Now()
+	progress := newReplayProgress(start)
+	progress.assign("leads", 1, 100, 300)
+	progress.assign("leads", 0, 0, 100)
+	for offset := int64(100); offset < 200; offset++ {
+		progress.record("leads", 1, offset)
+	}
+
+	partitions, rate, eta := progress.snapshot(start.Add(10 * time.Second))
+	if len(partitions) != 2 || partitions[0].partition != 0 || partitions[1].remaining() != 100 {
+		t.Fatalf("partitions = %+v, want partition 0 first and 100 messages remaining on partition 1", partitions)
+	}
+	if rate != 10 || eta != 20*time.Second {
+		t.Errorf("rate, eta = %v, %s, want 10 messages/s and 20s for the 200 remaining messages", rate, eta)
+	}
+	if summary := progress.summary(start.Add(10 * time.Second)); !strings.HasPrefix(summary, "replayed 100 of 300 messages in 2 partitions") {
+		t.Errorf("summary() = %q", summary)
+	}
+}
@@ -0,0 +1,127 @@
+package kafka
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/confluentinc/confluent-kafka-go/kafka"
+)
+
+// undeliverableAttempts is the number of times the consumer tries to deliver a message, e.g. to get the payload
+// of its claim check, before it puts the message in the undeliverable store
+const undeliverableAttempts = 3
+
+// maxRetryBackoff caps the time a partition stays paused before its message is retried
+const maxRetryBackoff = 30 * time.Second
+
+// partitionRetries tracks the partitions paused to retry a message which could not be delivered.
+// It is only used on the event loop of the consumer, the resume channel is written by the backoff timers
+type partitionRetries struct {
+	attempts map[partitionKey]int
+	paused   map[partitionKey]bool
+	resume   chan kafka.TopicPartition
+	stop     chan struct{}
+}
+
+func newPartitionRetries() *partitionRetries {
+	return &partitionRetries{
+		attempts: make(map[partitionKey]int),
+		paused:   make(map[partitionKey]bool),
+		resume:   make(chan kafka.TopicPartition),
+		stop:     make(chan struct{}),
+	}
+}
+
+// isPaused returns true if the partition is paused. Its messages still in the events channel are consumed again
+// from the offset of the failed message once it is resumed
+func (r *partitionRetries) isPaused(tp kafka.TopicPartition) bool {
+	return r.paused[partitionKey{stringValue(tp.Topic), tp.Partition}]
+}
+
+// failed records a failed attempt to deliver a message of the partition and returns the number of attempts
+func (r *partitionRetries) failed(tp kafka.TopicPartition) int {
+	key := partitionKey{stringValue(tp.Topic), tp.Partition}
+	r.attempts[key]++
+	return r.attempts[key]
+}
+
+// delivered forgets the failed attempts of the partition
+func (r *partitionRetries) delivered(tp kafka.TopicPartition) {
+	delete(r.attempts, partitionKey{stringValue(tp.Topic), tp.Partition})
+}
+
+// remove forgets the partitions, e.g. when they are revoked
+func (r *partitionRetries) remove(partitions []kafka.TopicPartition) {
+	for _, tp := range partitions {
+		key := partitionKey{stringValue(tp.Topic), tp.Partition}
+		delete(r.attempts, key)
+		delete(r.paused, key)
+	}
+}
+
+// reset forgets all the partitions, e.g. when the consumer is recreated
+func (r *partitionRetries) reset() {
+	r.attempts = make(map[partitionKey]int)
+	r.paused = make(map[partitionKey]bool)
+}
+
+// retryBackoff returns the time to wait before the given attempt, doubling from 100ms up to maxRetryBackoff
+func retryBackoff(attempt int) time.Duration {
+	backoff := 100 * time.Millisecond
+	for i := 1; i < attempt && backoff < maxRetryBackoff; i++ {
+		backoff *= 2
+	}
+	if backoff > maxRetryBackoff {
+		return maxRetryBackoff
+	}
+	return backoff
+}
+
+// deliveryFailed handles a message which could not be given to the processor. After undeliverableAttempts the
+// message is put in the undeliverable store and it returns true, its offset can then be committed. Otherwise the
+// partition is paused and rewound to the message, which is consumed again after a backoff
+func (kc *Consumer) deliveryFailed(m *kafka.Message, cause error) bool {
+	attempt := kc.retries.failed(m.TopicPartition)
+	if attempt >= undeliverableAttempts && kc.storeUndeliverable(m, cause) {
+		kc.retries.delivered(m.TopicPartition)
+		return true
+	}
+	kc.logger.LogError(fmt.Sprintf("could not deliver the message on %s in attempt %d, retrying", m.TopicPartition, attempt), cause)
+	kc.retryLater(m.TopicPartition, retryBackoff(attempt))
+	return false
+}
+
+// retryLater pauses the partition and rewinds it to the offset of the message, so that neither the message nor the
+// ones after it are committed, and resumes it after the backoff
+func (kc *Consumer) retryLater(tp kafka.TopicPartition, backoff time.Duration) {
+	partition := []kafka.TopicPartition{tp}
+	if err := kc.Consumer.Pause(partition); err != nil {
+		kc.logger.LogError("could not pause "+tp.String(), err)
+	}
+	if err := kc.Consumer.Seek(tp, 0); err != nil {
+		kc.logger.LogError("could not rewind "+tp.String(), err)
+	}
+	if _, err := kc.Consumer.StoreOffsets(partition); err != nil {
+		kc.logger.LogError("could not store the offset of "+tp.String(), err)
+	}
+	kc.retries.paused[partitionKey{stringValue(tp.Topic), tp.Partition}] = true
+	retries := kc.retries
+	time.AfterFunc(backoff, func() {
+		select {
+		case retries.resume <- tp:
+		case <-retries.stop:
+		}
+	})
+}
+
+// resumePartition resumes a partition paused by retryLater, unless it was revoked in the meantime
+func (kc *Consumer) resumePartition(tp kafka.TopicPartition) {
+	key := partitionKey{stringValue(tp.Topic), tp.Partition}
+	if !kc.retries.paused[key] {
+		return
+	}
+	delete(kc.retries.paused, key)
+	if err := kc.Consumer.Resume([]kafka.TopicPartition{tp}); err != nil {
+		kc.logger.LogError("could not resume "+tp.String(), err)
+	}
+}
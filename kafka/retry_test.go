@@ -0,0 +1,37 @@
+package kafka
+
+import (
+	"testing"
+	"time"
+
+	"github.com/confluentinc/confluent-kafka-go/kafka"
+)
+
+func TestRetryBackoffIsCapped(t *testing.T) {
+	if b := retryBackoff(1); b != 100*time.Millisecond {
+		t.Errorf("backoff of the first attempt = %s, want 100ms", b)
+	}
+	if b := retryBackoff(3); b != 400*time.Millisecond {
+		t.Errorf("backoff of the third attempt = %s, want 400ms", b)
+	}
+	if b := retryBackoff(100); b != maxRetryBackoff {
+		t.Errorf("backoff of the 100th attempt = %s, want %s", b, maxRetryBackoff)
+	}
+}
+
+func TestPartitionRetriesForgetRevokedPartitions(t *testing.T) {
+	topic := "stocks"
+	tp := kafka.TopicPartition{Topic: &topic, Partition: 2, Offset: 10}
+	r := newPartitionRetries()
+	if r.failed(tp) != 1 || r.failed(tp) != 2 {
+		t.Error("failed attempts were not counted")
+	}
+	r.paused[partitionKey{topic, 2}] = true
+	if !r.isPaused(tp) {
+		t.Error("partition is not paused")
+	}
+	r.remove([]kafka.TopicPartition{tp})
+	if r.isPaused(tp) || r.failed(tp) != 1 {
+		t.Error("revoked partition was not forgotten")
+	}
+}
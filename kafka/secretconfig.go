@@ -0,0 +1,13 @@
+package kafka
+
+import (
+	"github.com/carwale/golibraries/secrets"
+	"github.com/confluentinc/confluent-kafka-go/kafka"
+)
+
+// setSecretConfig sets the config keys to the secrets of the provider
+func setSecretConfig(config *kafka.ConfigMap, provider secrets.IProvider, secretsByKey map[string]string) {
+	for key, name := range secretsByKey {
+		config.SetKey(key, secrets.MustGet(provider, name))
+	}
+}
@@ -0,0 +1,17 @@
+package kafka
+
+import (
+	"github.com/carwale/golibraries/gotracer"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// SpanAttributes returns the messaging semantic convention attributes of the message consumed by the consumer,
+// to be set on the spans started by processors so that traces can be searched by topic, partition and offset
+func (kc *Consumer) SpanAttributes(m *Message) []attribute.KeyValue {
+	var topic string
+	if m.TopicPartition.Topic != nil {
+		topic = *m.TopicPartition.Topic
+	}
+	return gotracer.KafkaConsumeAttributes(topic, kc.ConsumerGroupName, m.TopicPartition.Partition,
+		int64(m.TopicPartition.Offset), m.Key, len(m.Data))
+}
@@ -0,0 +1,190 @@
+package kafka
+
+import (
+	"errors"
+	"fmt"
+	"sync/atomic"
+	"time"
+
+	"github.com/carwale/golibraries/goutilities"
+	"github.com/confluentinc/confluent-kafka-go/kafka"
+)
+
+// WatchdogAction is what the consumer watchdog does when it detects a stalled consumer
+type WatchdogAction int
+
+const (
+	// WatchdogLog logs the stall and increments the kafka_consumer_stalls_total metric
+	WatchdogLog WatchdogAction = iota
+	// WatchdogRestart logs the stall, increments the metric and recreates the underlying librdkafka consumer
+	WatchdogRestart
+)
+
+// String returns the name of the action
+func (a WatchdogAction) String() string {
+	return [...]string{"log", "restart"}[a]
+}
+
+// consumerWatchdog tracks the time of the last event received by a consumer
+type consumerWatchdog struct {
+	timeout      time.Duration
+	action       WatchdogAction
+	lastActivity int64 // unix nano, accessed atomically
+	restart      chan struct{}
+}
+
+// SetWatchdog starts a watchdog with the consumer. If the consumer receives no messages and no end of partition
+// events for timeout while it has lag on its assigned partitions, it is considered stalled and the action is taken.
+// Disabled by default
+func SetWatchdog(timeout time.Duration, action WatchdogAction) ConsumerOption {
+	return func(kc *Consumer) {
+		kc.watchdog = &consumerWatchdog{timeout: timeout, action: action, restart: make(chan struct{}, 1)}
+	}
+}
+
+// touch records an event of the consumer
+func (w *consumerWatchdog) touch() {
+	if w != nil {
+		atomic.StoreInt64(&w.lastActivity, time.Now().UnixNano())
+	}
+}
+
+// idleFor returns the time since the last event of the consumer
+func (w *consumerWatchdog) idleFor(now time.Time) time.Duration {
+	return now.Sub(time.Unix(0, atomic.LoadInt64(&w.lastActivity)))
+}
+
+// restartChannel returns the channel on which restarts are requested. It is nil if the watchdog is disabled
+func (w *consumerWatchdog) restartChannel() <-chan struct{} {
+	if w == nil {
+		return nil
+	}
+	return w.restart
+}
+
+// startWatchdog starts checking the consumer for stalls till the returned channel is closed
+func (kc *Consumer) startWatchdog() chan struct{} {
+	stop := make(chan struct{})
+	if kc.watchdog == nil {
+		return stop
+	}
+	kc.watchdog.touch()
+	goutilities.Go("watchdog "+kc.InstanceID, func() {
+		ticker := time.NewTicker(kc.watchdog.timeout / 4)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-stop:
+				return
+			case now := <-ticker.C:
+				kc.checkStall(now)
+			}
+		}
+	})
+	return stop
+}
+
+// checkStall takes the action of the watchdog if the consumer has been idle for too long while it has lag
+func (kc *Consumer) checkStall(now time.Time) {
+	idle := kc.watchdog.idleFor(now)
+	if idle < kc.watchdog.timeout {
+		return
+	}
+	lag, err := kc.lag()
+	if err != nil {
+		kc.logger.LogError("watchdog could not get the lag of "+kc.InstanceID, err)
+		return
+	}
+	if lag == 0 {
+		return
+	}
+	kc.logger.LogErrorWithoutErrorf("%s received no events for %s with a lag of %d messages. Watchdog action: %s",
+		kc.InstanceID, idle.Round(time.Second), lag, kc.watchdog.action)
+	kc.latencyLogger.IncVal(1, consumerStallsMetricID, kc.ConsumerGroupName)
+	// The next check is a full timeout away, giving the consumer or the restart time to recover
+	kc.watchdog.touch()
+	if kc.watchdog.action == WatchdogRestart {
+		select {
+		case kc.watchdog.restart <- struct{}{}:
+		default:
+			// a restart is already pending
+		}
+	}
+}
+
+// errConsumerClosed is returned by useConsumer once the underlying consumer is closed
+var errConsumerClosed = errors.New("consumer is closed")
+
+// useConsumer calls use with the underlying consumer, which is not closed or replaced while use runs.
+// The goroutines other than the event loop, which closes and replaces the consumer, must go through it
+func (kc *Consumer) useConsumer(use func(c *kafka.Consumer) error) error {
+	kc.consumerLock.RLock()
+	defer kc.consumerLock.RUnlock()
+	if kc.Consumer == nil || kc.closed {
+		return errConsumerClosed
+	}
+	return use(kc.Consumer)
+}
+
+// lag returns the number of messages between the position of the consumer and the end of its assigned partitions
+func (kc *Consumer) lag() (int64, error) {
+	var lag int64
+	err := kc.useConsumer(func(c *kafka.Consumer) error {
+		assigned, err := c.Assignment()
+		if err != nil {
+			return err
+		}
+		positions, err := c.Position(assigned)
+		if err != nil {
+			return err
+		}
+		for _, tp := range positions {
+			low, high, err := c.QueryWatermarkOffsets(*tp.Topic, tp.Partition, 5000)
+			if err != nil {
+				return err
+			}
+			lag += partitionLag(tp.Offset, low, high)
+		}
+		return nil
+	})
+	return lag, err
+}
+
+// partitionLag returns the lag of a partition. A consumer without a position has not fetched anything yet
+func partitionLag(position kafka.Offset, low int64, high int64) int64 {
+	if position < 0 {
+		return high - low
+	}
+	if int64(position) >= high {
+		return 0
+	}
+	return high - int64(position)
+}
+
+// restart closes the underlying consumer and creates a new one subscribed to the same topics. It returns an error
+// if the consumer could not be recreated, the consumer is then closed and must stop
+func (kc *Consumer) restart() error {
+	kc.logger.LogWarning(fmt.Sprintf("Restarting %s", kc.InstanceID))
+	kc.markUnready("restarting")
+	kc.commit()
+	kc.consumerLock.Lock()
+	defer kc.consumerLock.Unlock()
+	if err := kc.Consumer.Close(); err != nil {
+		kc.logger.LogError(fmt.Sprintf("Failed to close %s for restart", kc.InstanceID), err)
+	}
+	c, err := kafka.NewConsumer(kc.config)
+	if err != nil {
+		kc.closed = true
+		kc.markUnready("restart failed")
+		return fmt.Errorf("could not recreate %s: %w", kc.InstanceID, err)
+	}
+	kc.Consumer = c
+	kc.retries.reset()
+	if err := kc.Consumer.SubscribeTopics(kc.Topics, nil); err != nil {
+		kc.logger.LogError(fmt.Sprintf("Error in topic Subscription for %s:", kc.InstanceID), err)
+		kc.markUnready("subscription failed")
+		return nil
+	}
+	kc.markReady()
+	return nil
+}
@@ -0,0 +1,41 @@
+package kafka
+
+import (
+	"testing"
+	"time"
+
+	"github.com/confluentinc/confluent-kafka-go/kafka"
+)
+
+func TestPartitionLag(t *testing.T) {
+	tests := []struct {
+		position  kafka.Offset
+		low, high int64
+		want      int64
+	}{
+		{position: 90, low: 0, high: 100, want: 10},
+		{position: 100, low: 0, high: 100, want: 0},
+		{position: kafka.OffsetInvalid, low: 20, high: 100, want: 80},
+		{position: kafka.OffsetInvalid, low: 100, high: 100, want: 0},
+	}
+	for _, test := range tests {
+		if got := partitionLag(test.position, test.low, test.high); got != test.want {
+			t.Errorf("partitionLag(%v, %d, %d) = %d, want %d", test.position, test.low, test.high, got, test.want)
+		}
+	}
+}
+
+func TestWatchdogIdle(t *testing.T) {
+	var disabled *consumerWatchdog
+	disabled.touch()
+	if disabled.restartChannel() != nil {
+		t.Error("disabled watchdog has a restart channel")
+	}
+
+	kc := &Consumer{}
+	SetWatchdog(time.Minute, WatchdogRestart)(kc)
+	kc.watchdog.touch()
+	if idle := kc.watchdog.idleFor(time.Now().Add(2 * time.Minute)); idle < time.Minute {
+		t.Errorf("idleFor() = %s, want more than the timeout", idle)
+	}
+}
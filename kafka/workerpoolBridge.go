@@ -0,0 +1,245 @@
+package kafka
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/carwale/golibraries/goutilities"
+	"github.com/carwale/golibraries/workerpool"
+	"github.com/confluentinc/confluent-kafka-go/kafka"
+)
+
+// BridgeOption sets a parameter for the WorkerPoolBridge
+type BridgeOption func(b *WorkerPoolBridge)
+
+// SetBridgeWorkers sets the number of workers processing the messages. Default is workerpool.DefaultWorkers(workerpool.IOBound)
+func SetBridgeWorkers(workers int) BridgeOption {
+	return func(b *WorkerPoolBridge) {
+		b.dispatcherOptions = append(b.dispatcherOptions, workerpool.SetKeyedWorkers(workers))
+	}
+}
+
+// SetBridgeQueueSize sets the number of messages queued per worker. Default is 100.
+// The consumer stops reading messages while the queue of a worker is full
+func SetBridgeQueueSize(queueSize int) BridgeOption {
+	return func(b *WorkerPoolBridge) {
+		b.dispatcherOptions = append(b.dispatcherOptions, workerpool.SetKeyedQueueSize(queueSize))
+	}
+}
+
+// SetBridgeCommitInterval sets the interval at which the completed offsets are committed. Default is 5 seconds
+func SetBridgeCommitInterval(interval time.Duration) BridgeOption {
+	return func(b *WorkerPoolBridge) {
+		if interval > 0 {
+			b.commitInterval = interval
+		}
+	}
+}
+
+// WorkerPoolBridge processes the messages of a consumer in parallel on a keyed dispatcher.
+// Messages with the same key are processed in order on the same worker. Messages without a key are keyed by partition.
+// The offset of a partition is committed only once all the messages before it have been processed (the low watermark
+// of the partition), so a crash never skips an unprocessed message and the consumer stays at-least-once.
+// Like Consumer.Start, the result of the processor does not stop the offset from being committed
+type WorkerPoolBridge struct {
+	consumer          *Consumer
+	processor         IProcessor
+	dispatcher        *workerpool.KeyedDispatcher
+	dispatcherOptions []workerpool.KeyedOption
+	tracker           *offsetTracker
+	commitInterval    time.Duration
+	commitLock        sync.Mutex
+	stopCommits       chan struct{}
+	commitsStopped    chan struct{}
+}
+
+// NewWorkerPoolBridge returns a bridge for the consumer. The consumer must not be started by itself
+func NewWorkerPoolBridge(consumer *Consumer, processor IProcessor, options ...BridgeOption) *WorkerPoolBridge {
+	b := &WorkerPoolBridge{
+		consumer:       consumer,
+		processor:      processor,
+		tracker:        newOffsetTracker(),
+		commitInterval: 5 * time.Second,
+	}
+	for _, option := range options {
+		option(b)
+	}
+	b.dispatcherOptions = append(b.dispatcherOptions, workerpool.SetKeyedLogger(consumer.logger))
+	consumer.committer = b.commit
+	consumer.revokeHandler = b.revoke
+	consumer.beforeClose = b.stopCommitLoop
+	return b
+}
+
+// Start starts the consumer and blocks till it is closed through its CloseChannel.
+// The messages that are still queued when the consumer closes are processed before Start returns,
+// their offsets are committed by the next consumer of the partition
+func (b *WorkerPoolBridge) Start() {
+	b.dispatcher = workerpool.NewKeyedDispatcher(b.consumer.InstanceID, b.dispatcherOptions...)
+	b.stopCommits = make(chan struct{})
+	b.commitsStopped = make(chan struct{})
+	goutilities.Go("kafka-bridge-commits", b.commitLoop)
+	b.consumer.Start(b)
+	b.dispatcher.Stop()
+}
+
+// ProcessMessage is called by the consumer for every message. It queues the message on the worker of its key
+func (b *WorkerPoolBridge) ProcessMessage(msg *Message) bool {
+	b.tracker.start(msg.TopicPartition)
+	key := msg.Key
+	if len(key) == 0 {
+		key = []byte(fmt.Sprintf("%s-%d", stringValue(msg.TopicPartition.Topic), msg.TopicPartition.Partition))
+	}
+	b.dispatcher.Submit(key, &bridgeJob{bridge: b, msg: msg})
+	return true
+}
+
+func (b *WorkerPoolBridge) commitLoop() {
+	defer close(b.commitsStopped)
+	ticker := time.NewTicker(b.commitInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			b.commit()
+		case <-b.stopCommits:
+			return
+		}
+	}
+}
+
+// stopCommitLoop stops the commit loop and commits the completed offsets for the last time.
+// It is called by the consumer before it is closed, so that no commit uses the closed consumer
+func (b *WorkerPoolBridge) stopCommitLoop() {
+	close(b.stopCommits)
+	<-b.commitsStopped
+	b.commit()
+}
+
+// commit commits the low watermarks of the partitions that changed since the last commit
+func (b *WorkerPoolBridge) commit() {
+	b.commitLock.Lock()
+	defer b.commitLock.Unlock()
+	offsets := b.tracker.committable()
+	if len(offsets) == 0 {
+		return
+	}
+	err := b.consumer.useConsumer(func(c *kafka.Consumer) error {
+		_, err := c.CommitOffsets(offsets)
+		return err
+	})
+	if err != nil {
+		b.consumer.logger.LogError("Failed to commit offsets of "+b.consumer.InstanceID, err)
+		return
+	}
+	b.tracker.committed(offsets)
+}
+
+// revoke commits the completed offsets of the revoked partitions and stops tracking them
+func (b *WorkerPoolBridge) revoke(partitions []kafka.TopicPartition) {
+	b.commit()
+	b.tracker.remove(partitions)
+}
+
+type bridgeJob struct {
+	bridge *WorkerPoolBridge
+	msg    *Message
+}
+
+func (j *bridgeJob) Process() error {
+	defer j.bridge.tracker.done(j.msg.TopicPartition)
+	j.bridge.processor.ProcessMessage(j.msg)
+	return nil
+}
+
+type partitionKey struct {
+	topic     string
+	partition int32
+}
+
+// partitionOffsets holds the offsets of a partition that are being processed
+type partitionOffsets struct {
+	pending       map[kafka.Offset]struct{}
+	highest       kafka.Offset
+	lastCommitted kafka.Offset
+}
+
+// offsetTracker tracks the offsets in flight per partition to find the offsets which are safe to commit
+type offsetTracker struct {
+	lock       sync.Mutex
+	partitions map[partitionKey]*partitionOffsets
+}
+
+func newOffsetTracker() *offsetTracker {
+	return &offsetTracker{partitions: make(map[partitionKey]*partitionOffsets)}
+}
+
+func (t *offsetTracker) start(tp kafka.TopicPartition) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	key := partitionKey{stringValue(tp.Topic), tp.Partition}
+	p, ok := t.partitions[key]
+	if !ok {
+		p = &partitionOffsets{pending: make(map[kafka.Offset]struct{}), highest: -1, lastCommitted: -1}
+		t.partitions[key] = p
+	}
+	p.pending[tp.Offset] = struct{}{}
+	if tp.Offset > p.highest {
+		p.highest = tp.Offset
+	}
+}
+
+func (t *offsetTracker) done(tp kafka.TopicPartition) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	if p, ok := t.partitions[partitionKey{stringValue(tp.Topic), tp.Partition}]; ok {
+		delete(p.pending, tp.Offset)
+	}
+}
+
+// committable returns the next offset to consume of every partition whose low watermark moved.
+// It is the lowest offset still being processed, or the offset after the highest one if all are processed
+func (t *offsetTracker) committable() []kafka.TopicPartition {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	var offsets []kafka.TopicPartition
+	for key, p := range t.partitions {
+		watermark := p.highest + 1
+		for offset := range p.pending {
+			if offset < watermark {
+				watermark = offset
+			}
+		}
+		if watermark > p.lastCommitted && watermark > 0 {
+			topic := key.topic
+			offsets = append(offsets, kafka.TopicPartition{Topic: &topic, Partition: key.partition, Offset: watermark})
+		}
+	}
+	return offsets
+}
+
+func (t *offsetTracker) committed(offsets []kafka.TopicPartition) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	for _, tp := range offsets {
+		if p, ok := t.partitions[partitionKey{stringValue(tp.Topic), tp.Partition}]; ok && tp.Offset > p.lastCommitted {
+			p.lastCommitted = tp.Offset
+		}
+	}
+}
+
+func (t *offsetTracker) remove(partitions []kafka.TopicPartition) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	for _, tp := range partitions {
+		delete(t.partitions, partitionKey{stringValue(tp.Topic), tp.Partition})
+	}
+}
+
+func stringValue(s *string) string {
+	if s == nil {
+		return ""
+	}
+	return *s
+}
@@ -0,0 +1,37 @@
+package kafka
+
+import (
+	"testing"
+
+	"github.com/confluentinc/confluent-kafka-go/kafka"
+)
+
+func TestOffsetTrackerLowWatermark(t *testing.T) {
+	topic := "orders"
+	tp := func(offset kafka.Offset) kafka.TopicPartition {
+		return kafka.TopicPartition{Topic: &topic, Partition: 1, Offset: offset}
+	}
+	tracker := newOffsetTracker()
+	for offset := kafka.Offset(10); offset <= 12; offset++ {
+		tracker.start(tp(offset))
+	}
+
+	// 11 and 12 are done but 10 is still being processed
+	tracker.done(tp(11))
+	tracker.done(tp(12))
+	offsets := tracker.committable()
+	if len(offsets) != 1 || offsets[0].Offset != 10 {
+		t.Fatalf("committable() = %v, want offset 10", offsets)
+	}
+	tracker.committed(offsets)
+
+	tracker.done(tp(10))
+	offsets = tracker.committable()
+	if len(offsets) != 1 || offsets[0].Offset != 13 {
+		t.Fatalf("committable() = %v, want offset 13", offsets)
+	}
+	tracker.committed(offsets)
+	if offsets = tracker.committable(); len(offsets) != 0 {
+		t.Errorf("committable() = %v, want nothing after commit", offsets)
+	}
+}
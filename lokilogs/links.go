@@ -0,0 +1,69 @@
+package lokilogs
+
+import (
+	"encoding/json"
+	"net/url"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/carwale/golibraries/gologger"
+)
+
+// ExploreURL returns a link to the grafana explore page with the Loki logs of the query.
+// grafanaURL is the address of grafana, datasource is the name of the Loki datasource and the selector
+// has the labels of the stream, usually the static labels of the client
+//
+//	link := lokilogs.ExploreURL(grafanaURL, "Loki", map[string]string{"service": "stock"}, gologger.NewLogQuery(ctx, time.Now(), 0))
+func ExploreURL(grafanaURL string, datasource string, selector map[string]string, query gologger.LogQuery) string {
+	type exploreQuery struct {
+		RefID string `json:"refId"`
+		Expr  string `json:"expr"`
+	}
+	type exploreRange struct {
+		From string `json:"from"`
+		To   string `json:"to"`
+	}
+	state := struct {
+		Datasource string         `json:"datasource"`
+		Queries    []exploreQuery `json:"queries"`
+		Range      *exploreRange  `json:"range,omitempty"`
+	}{
+		Datasource: datasource,
+		Queries:    []exploreQuery{{RefID: "A", Expr: LogQL(selector, query)}},
+	}
+	if !query.From.IsZero() && !query.To.IsZero() {
+		state.Range = &exploreRange{
+			From: strconv.FormatInt(query.From.UnixMilli(), 10),
+			To:   strconv.FormatInt(query.To.UnixMilli(), 10),
+		}
+	}
+	left, _ := json.Marshal(state)
+	return strings.TrimRight(grafanaURL, "/") + "/explore?" + url.Values{"left": {string(left)}}.Encode()
+}
+
+// LogQL returns the LogQL query of the stream selector that filters the lines with the trace ID or the request ID of the query
+func LogQL(selector map[string]string, query gologger.LogQuery) string {
+	keys := make([]string, 0, len(selector))
+	for key := range selector {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	matchers := make([]string, 0, len(keys))
+	for _, key := range keys {
+		matchers = append(matchers, key+"="+strconv.Quote(selector[key]))
+	}
+	expr := "{" + strings.Join(matchers, ", ") + "}"
+
+	var ids []string
+	for _, id := range []string{query.TraceID, query.RequestID} {
+		if id != "" {
+			ids = append(ids, regexp.QuoteMeta(id))
+		}
+	}
+	if len(ids) > 0 {
+		expr += " |~ " + strconv.Quote(strings.Join(ids, "|"))
+	}
+	return expr
+}
@@ -0,0 +1,37 @@
+package lokilogs
+
+import (
+	"encoding/json"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/carwale/golibraries/gologger"
+)
+
+func TestExploreURL(t *testing.T) {
+	from := time.UnixMilli(1000)
+	query := gologger.LogQuery{TraceID: "abc", RequestID: "req.1", From: from, To: from.Add(time.Second)}
+	link, err := url.Parse(ExploreURL("https://grafana.example.com/", "Loki", map[string]string{"service": "stock", "env": "prod"}, query))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasSuffix(link.Path, "/explore") {
+		t.Errorf("path = %q, want /explore", link.Path)
+	}
+	var state struct {
+		Datasource string
+		Queries    []struct{ Expr string }
+		Range      struct{ From, To string }
+	}
+	if err := json.Unmarshal([]byte(link.Query().Get("left")), &state); err != nil {
+		t.Fatal(err)
+	}
+	if want := `{env="prod", service="stock"} |~ "abc|req\\.1"`; len(state.Queries) != 1 || state.Queries[0].Expr != want {
+		t.Errorf("queries = %+v, want %s", state.Queries, want)
+	}
+	if state.Datasource != "Loki" || state.Range.From != "1000" || state.Range.To != "2000" {
+		t.Errorf("state = %+v", state)
+	}
+}
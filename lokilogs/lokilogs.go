@@ -0,0 +1,295 @@
+// Package lokilogs pushes log lines directly to the Loki push api (/loki/api/v1/push).
+// Lines are queued, grouped into streams by their labels and pushed in batches, so that services
+// do not send a request to Loki for every log line. Labels should have a low cardinality: use static
+// labels for the service and derived labels like the status class, and keep ids in the log line
+package lokilogs
+
+import (
+	"encoding/json"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/carwale/golibraries/gologger"
+	"github.com/carwale/golibraries/goutilities"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// LabelFunc derives a label from the pairs of a log line. It returns false if the label should not be set
+type LabelFunc func(pairs []gologger.Pair) (key string, value string, ok bool)
+
+// PairLabel returns a label with the value of the pair with the key. Only use it for pairs with few distinct values
+func PairLabel(label string, pairKey string) LabelFunc {
+	return func(pairs []gologger.Pair) (string, string, bool) {
+		for _, pair := range pairs {
+			if pair.Key == pairKey {
+				return label, pair.Value, pair.Value != ""
+			}
+		}
+		return "", "", false
+	}
+}
+
+// StatusClassLabel returns a status_class label (2xx, 4xx, 5xx) derived from the http status in the pair with the key
+func StatusClassLabel(pairKey string) LabelFunc {
+	return func(pairs []gologger.Pair) (string, string, bool) {
+		for _, pair := range pairs {
+			if pair.Key == pairKey && len(pair.Value) == 3 && pair.Value[0] >= '1' && pair.Value[0] <= '5' {
+				return "status_class", pair.Value[:1] + "xx", true
+			}
+		}
+		return "", "", false
+	}
+}
+
+// Option sets a parameter for the Client
+type Option func(c *Client)
+
+// SetStaticLabels sets the labels added to every line, e.g. the service and environment
+func SetStaticLabels(labels map[string]string) Option {
+	return func(c *Client) {
+		for k, v := range labels {
+			c.staticLabels[k] = v
+		}
+	}
+}
+
+// AddDerivedLabel adds a label derived from the pairs of every line
+func AddDerivedLabel(label LabelFunc) Option {
+	return func(c *Client) { c.derivedLabels = append(c.derivedLabels, label) }
+}
+
+// SetBatchSize sets the maximum number of lines pushed in one request. Defaults to 500
+func SetBatchSize(size int) Option {
+	return func(c *Client) {
+		if size > 0 {
+			c.batchSize = size
+		}
+	}
+}
+
+// SetBatchWait sets the maximum age of a batch before it is pushed. Defaults to 1 second
+func SetBatchWait(wait time.Duration) Option {
+	return func(c *Client) {
+		if wait > 0 {
+			c.batchWait = wait
+		}
+	}
+}
+
+// SetQueueSize sets the number of lines that can wait to be batched. Lines are dropped when the queue is full. Defaults to 10000
+func SetQueueSize(size int) Option {
+	return func(c *Client) {
+		if size > 0 {
+			c.queueSize = size
+		}
+	}
+}
+
+// SetMaxRetries sets the number of times a failed push is retried before the batch is dropped. Defaults to 3
+func SetMaxRetries(retries int) Option {
+	return func(c *Client) {
+		if retries >= 0 {
+			c.maxRetries = retries
+		}
+	}
+}
+
+// SetRetryBackoff sets the wait before the first retry. It doubles with every retry. Defaults to 500 milliseconds
+func SetRetryBackoff(backoff time.Duration) Option {
+	return func(c *Client) {
+		if backoff > 0 {
+			c.retryBackoff = backoff
+		}
+	}
+}
+
+// SetTenantID sets the X-Scope-OrgID header for multi tenant Loki
+func SetTenantID(tenantID string) Option {
+	return func(c *Client) { c.tenantID = tenantID }
+}
+
+// SetHTTPClient sets the client used to push. Defaults to a client with a 10 second timeout
+func SetHTTPClient(httpClient *http.Client) Option {
+	return func(c *Client) { c.httpClient = httpClient }
+}
+
+// SetEnabledFunc gates the client: lines logged while enabled returns false are discarded.
+// Use it with consulagent.ExpiryToggle to push only for a while, like the access logs of httplogs
+func SetEnabledFunc(enabled func() bool) Option {
+	return func(c *Client) { c.enabled = enabled }
+}
+
+// SetLogger sets the logger used for errors of the client. It should not write to Loki
+func SetLogger(logger gologger.ILogger) Option {
+	return func(c *Client) { c.logger = logger }
+}
+
+// SetLatencyLogger sets the latency logger used for the metrics of the client
+func SetLatencyLogger(latencyLogger gologger.IMultiLogger) Option {
+	return func(c *Client) { c.latencyLogger = latencyLogger }
+}
+
+const (
+	pushedMetricID  = "LOKI-PUSHED"
+	droppedMetricID = "LOKI-DROPPED"
+	latencyMetricID = "LOKI-PUSH-LATENCY"
+)
+
+var lokilogsMetrics = gologger.NewMetricSet(func(logger gologger.ILogger) map[string]gologger.IMetricVec {
+	return map[string]gologger.IMetricVec{
+		pushedMetricID: gologger.NewCounterMetric(prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "loki_lines_pushed_total",
+				Help: "Number of log lines pushed to Loki",
+			},
+			[]string{},
+		), logger),
+		droppedMetricID: gologger.NewCounterMetric(prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "loki_lines_dropped_total",
+				Help: "Number of log lines dropped by reason",
+			},
+			[]string{"Reason"},
+		), logger),
+		latencyMetricID: gologger.NewHistogramMetric(prometheus.NewHistogramVec(
+			prometheus.HistogramOpts{
+				Name:    "loki_push_duration_milliseconds",
+				Help:    "Time taken to push a batch to Loki",
+				Buckets: []float64{5, 10, 25, 50, 100, 250, 500, 1000, 2500},
+			},
+			[]string{},
+		), logger),
+	}
+})
+
+type entry struct {
+	timestamp time.Time
+	line      string
+	labels    map[string]string
+}
+
+// Client batches log lines and pushes them to Loki
+type Client struct {
+	url           string
+	staticLabels  map[string]string
+	derivedLabels []LabelFunc
+	batchSize     int
+	batchWait     time.Duration
+	queueSize     int
+	maxRetries    int
+	retryBackoff  time.Duration
+	tenantID      string
+	enabled       func() bool
+	httpClient    *http.Client
+	logger        gologger.ILogger
+	latencyLogger gologger.IMultiLogger
+	queue         chan entry
+	closeLock     sync.RWMutex
+	closed        bool
+	done          chan struct{}
+}
+
+// NewClient returns a client which pushes to the Loki at url, e.g. http://loki:3100, and starts batching
+func NewClient(url string, options ...Option) *Client {
+	c := &Client{
+		url:          strings.TrimSuffix(url, "/") + "/loki/api/v1/push",
+		staticLabels: make(map[string]string),
+		batchSize:    500,
+		batchWait:    time.Second,
+		queueSize:    10000,
+		maxRetries:   3,
+		retryBackoff: 500 * time.Millisecond,
+		httpClient:   &http.Client{Timeout: 10 * time.Second},
+		done:         make(chan struct{}),
+	}
+	for _, option := range options {
+		option(c)
+	}
+	if c.logger == nil {
+		c.logger = gologger.NewLogger()
+	}
+	if c.latencyLogger == nil {
+		c.latencyLogger = gologger.NewRateLatencyLogger(gologger.SetLogger(c.logger))
+	}
+	lokilogsMetrics.AddTo(c.latencyLogger, c.logger)
+	c.queue = make(chan entry, c.queueSize)
+	goutilities.Go("lokilogs-push", c.run)
+	return c
+}
+
+// Log queues a line with the json encoding of the pairs. It never blocks: the line is dropped if the queue is full
+func (c *Client) Log(pairs ...gologger.Pair) {
+	c.LogLine(encodePairs(pairs), pairs)
+}
+
+// LogLine queues the line with labels derived from the pairs. It never blocks: the line is dropped if the queue is full
+func (c *Client) LogLine(line string, pairs []gologger.Pair) {
+	if c.enabled != nil && !c.enabled() {
+		return
+	}
+	e := entry{timestamp: time.Now(), line: line, labels: c.labels(pairs)}
+	c.closeLock.RLock()
+	defer c.closeLock.RUnlock()
+	if c.closed {
+		c.latencyLogger.IncVal(1, droppedMetricID, "closed")
+		return
+	}
+	select {
+	case c.queue <- e:
+	default:
+		c.latencyLogger.IncVal(1, droppedMetricID, "queue_full")
+	}
+}
+
+// Close pushes the queued lines and stops the client. Lines logged after Close are dropped
+func (c *Client) Close() {
+	c.closeLock.Lock()
+	if !c.closed {
+		c.closed = true
+		close(c.queue)
+	}
+	c.closeLock.Unlock()
+	<-c.done
+}
+
+func (c *Client) labels(pairs []gologger.Pair) map[string]string {
+	labels := make(map[string]string, len(c.staticLabels)+len(c.derivedLabels))
+	for k, v := range c.staticLabels {
+		labels[k] = v
+	}
+	for _, derive := range c.derivedLabels {
+		if k, v, ok := derive(pairs); ok {
+			labels[k] = v
+		}
+	}
+	return labels
+}
+
+func encodePairs(pairs []gologger.Pair) string {
+	line := make(map[string]string, len(pairs))
+	for _, pair := range pairs {
+		line[pair.Key] = pair.Value
+	}
+	encoded, _ := json.Marshal(line)
+	return string(encoded)
+}
+
+// labelsKey returns a stable key for the label set
+func labelsKey(labels map[string]string) string {
+	keys := make([]string, 0, len(labels))
+	for k := range labels {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	var b strings.Builder
+	for _, k := range keys {
+		b.WriteString(k)
+		b.WriteByte('=')
+		b.WriteString(labels[k])
+		b.WriteByte(',')
+	}
+	return b.String()
+}
@@ -0,0 +1,109 @@
+package lokilogs
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/carwale/golibraries/gologger"
+)
+
+type testMultiLogger struct {
+	lock    sync.Mutex
+	dropped map[string]int64
+}
+
+func (l *testMultiLogger) AddNewMetric(string, gologger.IMetricVec) {}
+func (l *testMultiLogger) Tic() time.Time                           { return time.Now() }
+func (l *testMultiLogger) Toc(time.Time, string, ...string)         {}
+func (l *testMultiLogger) SubVal(int64, string, ...string)          {}
+func (l *testMultiLogger) SetVal(int64, string, ...string)          {}
+func (l *testMultiLogger) IncVal(val int64, id string, labels ...string) {
+	if id != droppedMetricID {
+		return
+	}
+	l.lock.Lock()
+	l.dropped[labels[0]] += val
+	l.lock.Unlock()
+}
+
+func TestClientBatchesByLabels(t *testing.T) {
+	var lock sync.Mutex
+	var requests []pushRequest
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req pushRequest
+		json.NewDecoder(r.Body).Decode(&req)
+		lock.Lock()
+		requests = append(requests, req)
+		lock.Unlock()
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	c := NewClient(server.URL, SetLatencyLogger(&testMultiLogger{dropped: map[string]int64{}}),
+		SetStaticLabels(map[string]string{"service": "stock"}), AddDerivedLabel(StatusClassLabel("status")),
+		SetBatchSize(3), SetBatchWait(time.Hour))
+	c.Log(gologger.Pair{Key: "status", Value: "200"})
+	c.Log(gologger.Pair{Key: "status", Value: "201"})
+	c.Log(gologger.Pair{Key: "status", Value: "503"})
+	c.Log(gologger.Pair{Key: "status", Value: "200"})
+	c.Close()
+
+	lock.Lock()
+	defer lock.Unlock()
+	if len(requests) != 2 {
+		t.Fatalf("pushed %d requests, want 2 (a full batch and the flush on close)", len(requests))
+	}
+	streams := map[string]int{}
+	for _, stream := range requests[0].Streams {
+		if stream.Stream["service"] != "stock" {
+			t.Errorf("stream labels = %v, want the static labels", stream.Stream)
+		}
+		streams[stream.Stream["status_class"]] = len(stream.Values)
+	}
+	if streams["2xx"] != 2 || streams["5xx"] != 1 {
+		t.Errorf("lines by status class = %v, want 2xx:2 5xx:1", streams)
+	}
+}
+
+func TestClientDropsAfterRetries(t *testing.T) {
+	attempts := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attempts++
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	metrics := &testMultiLogger{dropped: map[string]int64{}}
+	c := NewClient(server.URL, SetLatencyLogger(metrics), SetMaxRetries(2), SetRetryBackoff(time.Millisecond))
+	c.Log(gologger.Pair{Key: "msg", Value: "hello"})
+	c.Close()
+
+	if attempts != 3 {
+		t.Errorf("push attempts = %d, want 3", attempts)
+	}
+	if metrics.dropped["retries_exhausted"] != 1 {
+		t.Errorf("dropped = %v, want 1 line dropped after retries", metrics.dropped)
+	}
+}
+
+func TestClientDropsRejectedBatch(t *testing.T) {
+	attempts := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attempts++
+		w.WriteHeader(http.StatusBadRequest)
+	}))
+	defer server.Close()
+
+	metrics := &testMultiLogger{dropped: map[string]int64{}}
+	c := NewClient(server.URL, SetLatencyLogger(metrics), SetRetryBackoff(time.Millisecond))
+	c.Log(gologger.Pair{Key: "msg", Value: "hello"})
+	c.Close()
+
+	if attempts != 1 || metrics.dropped["rejected"] != 1 {
+		t.Errorf("attempts = %d, dropped = %v, want 1 attempt and 1 rejected line", attempts, metrics.dropped)
+	}
+}
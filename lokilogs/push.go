@@ -0,0 +1,140 @@
+package lokilogs
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+type pushStream struct {
+	Stream map[string]string `json:"stream"`
+	Values [][2]string       `json:"values"`
+}
+
+type pushRequest struct {
+	Streams []*pushStream `json:"streams"`
+}
+
+// batch groups the entries by their labels
+type batch struct {
+	streams map[string]*pushStream
+	size    int
+}
+
+func newBatch() *batch {
+	return &batch{streams: make(map[string]*pushStream)}
+}
+
+func (b *batch) add(e entry) {
+	key := labelsKey(e.labels)
+	stream, ok := b.streams[key]
+	if !ok {
+		stream = &pushStream{Stream: e.labels}
+		b.streams[key] = stream
+	}
+	stream.Values = append(stream.Values, [2]string{strconv.FormatInt(e.timestamp.UnixNano(), 10), e.line})
+	b.size++
+}
+
+func (b *batch) encode() ([]byte, error) {
+	req := pushRequest{Streams: make([]*pushStream, 0, len(b.streams))}
+	for _, stream := range b.streams {
+		req.Streams = append(req.Streams, stream)
+	}
+	return json.Marshal(req)
+}
+
+// run batches the queued entries until the queue is closed
+func (c *Client) run() {
+	defer close(c.done)
+	current := newBatch()
+	timer := time.NewTimer(c.batchWait)
+	defer timer.Stop()
+	for {
+		select {
+		case e, ok := <-c.queue:
+			if !ok {
+				c.push(current)
+				return
+			}
+			current.add(e)
+			if current.size >= c.batchSize {
+				c.push(current)
+				current = newBatch()
+				if !timer.Stop() {
+					<-timer.C
+				}
+				timer.Reset(c.batchWait)
+			}
+		case <-timer.C:
+			if current.size > 0 {
+				c.push(current)
+				current = newBatch()
+			}
+			timer.Reset(c.batchWait)
+		}
+	}
+}
+
+// push sends the batch and retries on network errors, 429 and 5xx responses. Other responses drop the batch
+func (c *Client) push(b *batch) {
+	if b.size == 0 {
+		return
+	}
+	body, err := b.encode()
+	if err != nil {
+		c.logger.LogError("Could not encode batch of log lines for Loki", err)
+		c.latencyLogger.IncVal(int64(b.size), droppedMetricID, "encode_error")
+		return
+	}
+	backoff := c.retryBackoff
+	for attempt := 0; ; attempt++ {
+		start := c.latencyLogger.Tic()
+		retry, err := c.send(body)
+		c.latencyLogger.Toc(start, latencyMetricID)
+		if err == nil {
+			c.latencyLogger.IncVal(int64(b.size), pushedMetricID)
+			return
+		}
+		if !retry {
+			c.logger.LogError("Loki rejected batch of log lines", err)
+			c.latencyLogger.IncVal(int64(b.size), droppedMetricID, "rejected")
+			return
+		}
+		if attempt >= c.maxRetries {
+			c.logger.LogError("Could not push batch of log lines to Loki", err)
+			c.latencyLogger.IncVal(int64(b.size), droppedMetricID, "retries_exhausted")
+			return
+		}
+		time.Sleep(backoff)
+		backoff *= 2
+	}
+}
+
+// send pushes the body once. It returns true if the push can be retried
+func (c *Client) send(body []byte) (bool, error) {
+	req, err := http.NewRequest(http.MethodPost, c.url, bytes.NewReader(body))
+	if err != nil {
+		return false, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if c.tenantID != "" {
+		req.Header.Set("X-Scope-OrgID", c.tenantID)
+	}
+	res, err := c.httpClient.Do(req)
+	if err != nil {
+		return true, err
+	}
+	defer res.Body.Close()
+	if res.StatusCode/100 == 2 {
+		io.Copy(io.Discard, res.Body)
+		return false, nil
+	}
+	message, _ := io.ReadAll(io.LimitReader(res.Body, 1024))
+	err = fmt.Errorf("loki responded with %s: %s", res.Status, bytes.TrimSpace(message))
+	return res.StatusCode == http.StatusTooManyRequests || res.StatusCode >= 500, err
+}
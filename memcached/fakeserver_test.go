@@ -0,0 +1,116 @@
+package memcached
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/carwale/golibraries/gologger"
+	"github.com/carwale/gomemcache/memcache"
+)
+
+// fakeServer is an in memory memcached speaking the text protocol, for the commands used by CacheClient.
+// It records the expiration of the items as sent by the client instead of expiring them
+type fakeServer struct {
+	mu    sync.Mutex
+	items map[string]fakeItem
+}
+
+type fakeItem struct {
+	value      []byte
+	expiration int64
+}
+
+// newTestClient returns a client connected to a new fake server
+func newTestClient(t *testing.T) (*CacheClient, *fakeServer) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { listener.Close() })
+	server := &fakeServer{items: map[string]fakeItem{}}
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go server.serve(conn)
+		}
+	}()
+	return &CacheClient{client: memcache.New(listener.Addr().String()), logger: gologger.NewLogger()}, server
+}
+
+// item returns the item of the key
+func (s *fakeServer) item(key string) (fakeItem, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	item, ok := s.items[key]
+	return item, ok
+}
+
+func (s *fakeServer) serve(conn net.Conn) {
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+	w := bufio.NewWriter(conn)
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return
+		}
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			return
+		}
+		s.mu.Lock()
+		switch fields[0] {
+		case "gets", "get":
+			for _, key := range fields[1:] {
+				if item, ok := s.items[key]; ok {
+					fmt.Fprintf(w, "VALUE %s 0 %d 1\r\n%s\r\n", key, len(item.value), item.value)
+				}
+			}
+			w.WriteString("END\r\n")
+		case "set", "add":
+			size, _ := strconv.Atoi(fields[4])
+			value := make([]byte, size+2)
+			if _, err := io.ReadFull(r, value); err != nil {
+				s.mu.Unlock()
+				return
+			}
+			expiration, _ := strconv.ParseInt(fields[3], 10, 64)
+			if _, ok := s.items[fields[1]]; ok && fields[0] == "add" {
+				w.WriteString("NOT_STORED\r\n")
+			} else {
+				s.items[fields[1]] = fakeItem{value: value[:size], expiration: expiration}
+				w.WriteString("STORED\r\n")
+			}
+		case "touch":
+			if item, ok := s.items[fields[1]]; ok {
+				item.expiration, _ = strconv.ParseInt(fields[2], 10, 64)
+				s.items[fields[1]] = item
+				w.WriteString("TOUCHED\r\n")
+			} else {
+				w.WriteString("NOT_FOUND\r\n")
+			}
+		case "delete":
+			if _, ok := s.items[fields[1]]; ok {
+				delete(s.items, fields[1])
+				w.WriteString("DELETED\r\n")
+			} else {
+				w.WriteString("NOT_FOUND\r\n")
+			}
+		default:
+			w.WriteString("ERROR\r\n")
+		}
+		s.mu.Unlock()
+		if err := w.Flush(); err != nil {
+			return
+		}
+	}
+}
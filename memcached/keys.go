@@ -0,0 +1,82 @@
+package memcached
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// MaxKeyLength is the maximum length of a memcached key
+const MaxKeyLength = 250
+
+// KeyBuilder builds memcached keys of the form namespace:v<version>:part1:part2.
+// Keys that are longer than MaxKeyLength or have characters memcached does not allow (spaces and control
+// characters) are replaced by the sha256 of the parts, keeping the namespace and the version readable.
+// Bump the version when the schema of the cached values changes, so that the old values are not read
+//
+//	var stockKeys = memcached.NewKeyBuilder("stock", 2)
+//	key := stockKeys.Key(cityID, modelID) // stock:v2:1:523
+type KeyBuilder struct {
+	prefix string
+}
+
+// NewKeyBuilder returns a key builder for the namespace and the schema version.
+// It panics if the namespace is empty or cannot be part of a memcached key
+func NewKeyBuilder(namespace string, version int) *KeyBuilder {
+	if namespace == "" || !isSafeKey(namespace) || len(namespace) > MaxKeyLength/2 {
+		panic(fmt.Sprintf("invalid memcached key namespace %q", namespace))
+	}
+	return &KeyBuilder{prefix: namespace + ":v" + strconv.Itoa(version) + ":"}
+}
+
+// Key returns the key of the parts. Strings, integers and booleans are formatted directly, other
+// values with fmt.Sprint
+func (b *KeyBuilder) Key(parts ...interface{}) string {
+	var builder strings.Builder
+	builder.WriteString(b.prefix)
+	for i, part := range parts {
+		if i > 0 {
+			builder.WriteByte(':')
+		}
+		writeKeyPart(&builder, part)
+	}
+	key := builder.String()
+	if len(key) <= MaxKeyLength && isSafeKey(key) {
+		return key
+	}
+	sum := sha256.Sum256([]byte(key[len(b.prefix):]))
+	return b.prefix + "h:" + hex.EncodeToString(sum[:])
+}
+
+func writeKeyPart(builder *strings.Builder, part interface{}) {
+	switch v := part.(type) {
+	case string:
+		builder.WriteString(v)
+	case int:
+		builder.WriteString(strconv.Itoa(v))
+	case int32:
+		builder.WriteString(strconv.FormatInt(int64(v), 10))
+	case int64:
+		builder.WriteString(strconv.FormatInt(v, 10))
+	case uint32:
+		builder.WriteString(strconv.FormatUint(uint64(v), 10))
+	case uint64:
+		builder.WriteString(strconv.FormatUint(v, 10))
+	case bool:
+		builder.WriteString(strconv.FormatBool(v))
+	default:
+		fmt.Fprint(builder, v)
+	}
+}
+
+// isSafeKey returns false if the key has characters memcached does not allow in keys
+func isSafeKey(key string) bool {
+	for i := 0; i < len(key); i++ {
+		if key[i] <= ' ' || key[i] == 0x7f {
+			return false
+		}
+	}
+	return true
+}
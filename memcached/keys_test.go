@@ -0,0 +1,39 @@
+package memcached
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestKeyBuilder(t *testing.T) {
+	keys := NewKeyBuilder("stock", 2)
+	if got := keys.Key(1, int64(523), "petrol", true); got != "stock:v2:1:523:petrol:true" {
+		t.Errorf("Key() = %q", got)
+	}
+	if got := keys.Key(); got != "stock:v2:" {
+		t.Errorf("Key() without parts = %q", got)
+	}
+}
+
+func TestKeyBuilderHashesUnsafeKeys(t *testing.T) {
+	keys := NewKeyBuilder("stock", 2)
+	spaced := keys.Key("new delhi")
+	long := keys.Key(strings.Repeat("a", MaxKeyLength))
+	for _, key := range []string{spaced, long} {
+		if !strings.HasPrefix(key, "stock:v2:h:") || len(key) > MaxKeyLength || !isSafeKey(key) {
+			t.Errorf("Key() = %q, want a hashed key", key)
+		}
+	}
+	if spaced == long || spaced != keys.Key("new delhi") {
+		t.Errorf("hashed keys are not stable and distinct: %q %q", spaced, long)
+	}
+}
+
+func TestNewKeyBuilderPanicsOnInvalidNamespace(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Error("NewKeyBuilder() did not panic")
+		}
+	}()
+	NewKeyBuilder("stock prices", 1)
+}
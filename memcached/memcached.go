@@ -2,8 +2,13 @@ package memcached
 
 import (
 	"bytes"
+	"crypto/sha256"
 	"encoding/gob"
+	"encoding/hex"
 	"fmt"
+	"sort"
+	"strings"
+	"time"
 
 	"github.com/carwale/golibraries/gologger"
 	"github.com/carwale/gomemcache/memcache"
@@ -11,8 +16,9 @@ import (
 
 // CacheClient is used to add,update,remove items from memcache
 type CacheClient struct {
-	client *memcache.Client
-	logger *gologger.CustomLogger
+	client            *memcache.Client
+	logger            gologger.ILogger
+	slidingExpiration bool
 }
 
 // GetBytes converts interface{} to a byte array
@@ -70,16 +76,48 @@ func NewMemCachedClient(serverList []string) (*CacheClient, error) {
 	return c, nil
 }
 
-func (c *CacheClient) SetLogger(logger *gologger.CustomLogger) {
+func (c *CacheClient) SetLogger(logger gologger.ILogger) {
 	c.logger = logger
 }
 
+// SetSlidingExpiration makes GetItem and GetItemWithLock extend the expiration of the items they read
+// to the expiration they are called with, so that items like sessions expire only when they are not read.
+// Every hit costs an extra round trip to memcached for the touch, failures are only logged
+func (c *CacheClient) SetSlidingExpiration(enabled bool) {
+	c.slidingExpiration = enabled
+}
+
+// Touch sets the expiration of an item without rewriting its value.
+// expiration is the cache expiration time, in seconds: either a relative
+// time from now (up to 1 month), or an absolute Unix epoch time.
+// Zero means the Item has no expiration time.
+// It returns false, memcache.ErrCacheMiss if the key does not exist
+func (c *CacheClient) Touch(key string, expiration int32) (bool, error) {
+	err := c.client.Touch(key, expiration)
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// extendExpiration touches the item if sliding expiration is enabled. It is done before the read returns,
+// so that a burst of reads does not pile up touches in the background
+func (c *CacheClient) extendExpiration(key string, expiration int32) {
+	if !c.slidingExpiration {
+		return
+	}
+	if _, err := c.Touch(key, expiration); err != nil && err != memcache.ErrCacheMiss {
+		c.logger.LogError("Failed to extend the expiration of the item in memcache.", err)
+	}
+}
+
 // GetItem takes in the key, expiration and a dbCallBack function.
 // If a cache miss occurs, the dbCallBack function is called which retrieves data from the database.
 // This value from the database is saved back to memcache.
 // expiration is the cache expiration time, in seconds: either a relative
 // time from now (up to 1 month), or an absolute Unix epoch time.
-// Zero means the Item has no expiration time.
+// Zero means the Item has no expiration time. With sliding expiration the expiration of a cached item
+// is extended on every read.
 // It returns (nil, err) if there's any other error, else returns an interface{} object.
 func (c *CacheClient) GetItem(key string, expiration int32, dbCallBack func() (interface{}, error)) (interface{}, error) {
 	item, err := c.client.Get(key)
@@ -99,6 +137,7 @@ func (c *CacheClient) GetItem(key string, expiration int32, dbCallBack func() (i
 		}
 		return value, nil
 	}
+	c.extendExpiration(key, expiration)
 	res, err := BytesToEmptyInterface(item.Value)
 	if err != nil {
 		return res, err
@@ -106,6 +145,85 @@ func (c *CacheClient) GetItem(key string, expiration int32, dbCallBack func() (i
 	return res, nil
 }
 
+const (
+	// lockPollInterval is how often the callers waiting for the holder of a rebuild lock check the cache
+	lockPollInterval = 50 * time.Millisecond
+	// defaultLockTTL is the lockTTL, in seconds, used by GetItemWithLock when it is not positive,
+	// as memcached would keep a lock without an expiration forever
+	defaultLockTTL int32 = 10
+	lockKeySuffix        = ":lock"
+)
+
+// GetItemWithLock is GetItem for values that are expensive to rebuild. On a cache miss only the caller that
+// acquires a lock in memcache (an add of the key with a ":lock" suffix that expires after lockTTL seconds)
+// calls dbCallBack, across all the pods. The other callers poll the cache for up to lockTTL seconds and
+// call dbCallBack themselves only if the value does not show up, e.g. because the holder of the lock died.
+// lockTTL should be a little longer than the time dbCallBack takes, a lockTTL that is not positive is 10 seconds.
+// If memcache fails, dbCallBack is called without the lock
+func (c *CacheClient) GetItemWithLock(key string, expiration int32, lockTTL int32, dbCallBack func() (interface{}, error)) (interface{}, error) {
+	if lockTTL <= 0 {
+		lockTTL = defaultLockTTL
+	}
+	item, err := c.client.Get(key)
+	if err == nil {
+		c.extendExpiration(key, expiration)
+		return BytesToEmptyInterface(item.Value)
+	}
+	if err != memcache.ErrCacheMiss {
+		c.logger.LogError("Failed to get item from memcache.", err)
+		return c.loadItem(key, expiration, dbCallBack)
+	}
+
+	lockKey := lockKeyOf(key)
+	err = c.client.Add(&memcache.Item{Key: lockKey, Value: []byte{1}, Expiration: lockTTL})
+	if err == nil {
+		defer c.client.Delete(lockKey)
+		return c.loadItem(key, expiration, dbCallBack)
+	}
+	if err != memcache.ErrNotStored {
+		c.logger.LogError("Failed to acquire the rebuild lock in memcache.", err)
+		return c.loadItem(key, expiration, dbCallBack)
+	}
+
+	deadline := time.Now().Add(time.Duration(lockTTL) * time.Second)
+	for time.Now().Before(deadline) {
+		time.Sleep(lockPollInterval)
+		item, err := c.client.Get(key)
+		if err == nil {
+			return BytesToEmptyInterface(item.Value)
+		}
+		if err != memcache.ErrCacheMiss {
+			c.logger.LogError("Failed to get item from memcache.", err)
+			break
+		}
+	}
+	c.logger.LogWarningf("Value of key %s was not rebuilt by the holder of the lock in %d seconds", key, lockTTL)
+	return c.loadItem(key, expiration, dbCallBack)
+}
+
+// lockKeyOf returns the key of the rebuild lock of the key. The suffix of a key close to MaxKeyLength is added
+// to the sha256 of the key instead, so that the lock key is not longer than MaxKeyLength
+func lockKeyOf(key string) string {
+	if len(key)+len(lockKeySuffix) <= MaxKeyLength {
+		return key + lockKeySuffix
+	}
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:]) + lockKeySuffix
+}
+
+// loadItem gets the value with dbCallBack and adds it to the cache
+func (c *CacheClient) loadItem(key string, expiration int32, dbCallBack func() (interface{}, error)) (interface{}, error) {
+	value, err := dbCallBack()
+	if err != nil {
+		return value, err
+	}
+	if _, err := c.AddItem(key, value, expiration); err != nil && err != memcache.ErrNotStored {
+		c.logger.LogError("Error occurred while adding item to cache.", err)
+		return value, err
+	}
+	return value, nil
+}
+
 // AddItem saves an Item to cache.
 // It returns false,error if it is unable to save the Item.
 // expiration is the cache expiration time, in seconds: either a relative
@@ -158,9 +276,9 @@ func (c *CacheClient) UpdateItem(key string, value interface{}, expiration int32
 	return true, nil
 }
 
-// DeleteWithoutDelay deletes a given key from the server without any delay
-// It returns false,error if delete was unsuccessful.
-func (c *CacheClient) DeleteWithoutDelay(key string) (bool, error) {
+// DeleteNow deletes a given key from the server immediately.
+// It returns false, memcache.ErrCacheMiss if the key does not exist
+func (c *CacheClient) DeleteNow(key string) (bool, error) {
 	err := c.client.Delete(key)
 	if err != nil {
 		return false, err
@@ -168,21 +286,65 @@ func (c *CacheClient) DeleteWithoutDelay(key string) (bool, error) {
 	return true, nil
 }
 
-// DeleteItem deletes a given key from the server after a 5 mins delay.
+// DeleteAfter makes the item expire after delay seconds by changing its expiration, without reading
+// or rewriting its value. The item is served until then, so use it only when stale reads during the
+// delay are fine, e.g. to spread the rebuilds of many keys.
+// It returns false, memcache.ErrCacheMiss if the key does not exist
+func (c *CacheClient) DeleteAfter(key string, delay int32) (bool, error) {
+	if delay <= 0 {
+		return c.DeleteNow(key)
+	}
+	return c.Touch(key, delay)
+}
+
+// DeleteMultiError is returned by DeleteMulti when some of the keys could not be deleted
+type DeleteMultiError struct {
+	// Errors has the error of every key that could not be deleted
+	Errors map[string]error
+}
+
+func (e *DeleteMultiError) Error() string {
+	keys := make([]string, 0, len(e.Errors))
+	for key := range e.Errors {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return fmt.Sprintf("failed to delete %d keys from memcache: %s", len(keys), strings.Join(keys, ", "))
+}
+
+// DeleteMulti deletes the keys from the server immediately. Keys that do not exist are not failures.
+// It returns a *DeleteMultiError with the keys that could not be deleted
+func (c *CacheClient) DeleteMulti(keys []string) error {
+	var errs map[string]error
+	for _, key := range keys {
+		if err := c.client.Delete(key); err != nil && err != memcache.ErrCacheMiss {
+			if errs == nil {
+				errs = make(map[string]error)
+			}
+			errs[key] = err
+		}
+	}
+	if errs != nil {
+		return &DeleteMultiError{Errors: errs}
+	}
+	return nil
+}
+
+// DeleteWithoutDelay deletes a given key from the server without any delay
+// It returns false,error if delete was unsuccessful.
+//
+// Deprecated: use DeleteNow
+func (c *CacheClient) DeleteWithoutDelay(key string) (bool, error) {
+	return c.DeleteNow(key)
+}
+
+// DeleteItem makes a given key expire after 5 mins. The item is still served till then.
 // It returns false, error if the operation was unsuccessful.
 // key is the memcache key to be deleted.
+//
+// Deprecated: use DeleteNow, or DeleteAfter if serving the item for a while is intended
 func (c *CacheClient) DeleteItem(key string) (bool, error) {
-	delay := int32(300) // 5 minutes
-	item, err := c.client.Get(key)
-	if err != nil {
-		return false, err
-	}
-	newItem := &memcache.Item{Key: item.Key, Value: item.Value, Expiration: delay}
-	err = c.client.Replace(newItem)
-	if err != nil {
-		return false, err
-	}
-	return true, nil
+	return c.DeleteAfter(key, 300)
 }
 
 // Checks whether a key exists in memcache
@@ -2,7 +2,13 @@ package memcached
 
 import (
 	"encoding/gob"
+	"errors"
+	"strings"
+	"sync/atomic"
 	"testing"
+	"time"
+
+	"github.com/carwale/gomemcache/memcache"
 )
 
 func TestBytesInterfaceConversionString(t *testing.T) {
@@ -96,3 +102,138 @@ func TestCreateMemcacheObjectCreation(t *testing.T) {
 	}
 
 }
+
+func TestDeleteMultiErrorListsKeys(t *testing.T) {
+	err := &DeleteMultiError{Errors: map[string]error{"b": errors.New("timeout"), "a": errors.New("timeout")}}
+	if got, want := err.Error(), "failed to delete 2 keys from memcache: a, b"; got != want {
+		t.Errorf("Error() = %q, want %q", got, want)
+	}
+}
+
+func TestGetItemWithLockLoadsAMissOnce(t *testing.T) {
+	client, server := newTestClient(t)
+	var calls int32
+	load := func() (interface{}, error) {
+		atomic.AddInt32(&calls, 1)
+		if _, ok := server.item("stock:1" + lockKeySuffix); !ok {
+			t.Error("the value was loaded without the lock")
+		}
+		return "value", nil
+	}
+
+	for i := 0; i < 2; i++ {
+		value, err := client.GetItemWithLock("stock:1", 60, 5, load)
+		if err != nil || value != "value" {
+			t.Fatalf("GetItemWithLock() = %v, %v, want value", value, err)
+		}
+	}
+	if calls != 1 {
+		t.Errorf("dbCallBack called %d times, want once", calls)
+	}
+	if _, ok := server.item("stock:1" + lockKeySuffix); ok {
+		t.Error("the lock was not deleted after the value was loaded")
+	}
+}
+
+func TestGetItemWithLockWaitsForTheHolderOfTheLock(t *testing.T) {
+	client, _ := newTestClient(t)
+	if _, err := client.AddItem("stock:1"+lockKeySuffix, 1, 5); err != nil {
+		t.Fatal(err)
+	}
+	go func() {
+		time.Sleep(2 * lockPollInterval)
+		client.AddItem("stock:1", "rebuilt", 60)
+	}()
+	value, err := client.GetItemWithLock("stock:1", 60, 5, func() (interface{}, error) {
+		t.Error("dbCallBack called while another caller holds the lock")
+		return "loaded", nil
+	})
+	if err != nil || value != "rebuilt" {
+		t.Errorf("GetItemWithLock() = %v, %v, want the value of the holder of the lock", value, err)
+	}
+}
+
+func TestGetItemWithLockExpiresTheLock(t *testing.T) {
+	client, server := newTestClient(t)
+	client.GetItemWithLock("stock:1", 60, 0, func() (interface{}, error) {
+		if lock, ok := server.item("stock:1" + lockKeySuffix); !ok || lock.expiration != int64(defaultLockTTL) {
+			t.Errorf("lock = %+v, %v, want it to expire after %d seconds", lock, ok, defaultLockTTL)
+		}
+		return "value", nil
+	})
+}
+
+func TestGetItemWithLockHashesLongLockKeys(t *testing.T) {
+	client, server := newTestClient(t)
+	key := strings.Repeat("k", MaxKeyLength)
+	lockKey := lockKeyOf(key)
+	if len(lockKey) > MaxKeyLength {
+		t.Fatalf("lock key %q is longer than %d", lockKey, MaxKeyLength)
+	}
+	client.GetItemWithLock(key, 60, 5, func() (interface{}, error) {
+		if _, ok := server.item(lockKey); !ok {
+			t.Error("the value was loaded without the lock")
+		}
+		return "value", nil
+	})
+	if lockKeyOf("stock:1") != "stock:1"+lockKeySuffix {
+		t.Errorf("lockKeyOf(stock:1) = %q, want the suffix", lockKeyOf("stock:1"))
+	}
+}
+
+func TestTouch(t *testing.T) {
+	client, server := newTestClient(t)
+	client.AddItem("stock:1", "value", 60)
+	if ok, err := client.Touch("stock:1", 600); !ok || err != nil {
+		t.Fatalf("Touch() = %v, %v, want true", ok, err)
+	}
+	if item, _ := server.item("stock:1"); item.expiration != 600 {
+		t.Errorf("expiration = %d, want 600", item.expiration)
+	}
+	if ok, err := client.Touch("stock:2", 600); ok || err != memcache.ErrCacheMiss {
+		t.Errorf("Touch() of a missing key = %v, %v, want false, ErrCacheMiss", ok, err)
+	}
+}
+
+// waitForExpiration waits for the expiration of the item extended in the background
+func waitForExpiration(server *fakeServer, key string, expiration int64) bool {
+	for deadline := time.Now().Add(time.Second); time.Now().Before(deadline); time.Sleep(5 * time.Millisecond) {
+		if item, ok := server.item(key); ok && item.expiration == expiration {
+			return true
+		}
+	}
+	return false
+}
+
+func TestSlidingExpiration(t *testing.T) {
+	client, server := newTestClient(t)
+	client.SetSlidingExpiration(true)
+	client.AddItem("stock:1", "value", 60)
+
+	value, err := client.GetItem("stock:1", 600, func() (interface{}, error) {
+		t.Error("dbCallBack called for a hit")
+		return nil, nil
+	})
+	if err != nil || value != "value" {
+		t.Fatalf("GetItem() = %v, %v, want value", value, err)
+	}
+	if !waitForExpiration(server, "stock:1", 600) {
+		t.Error("the expiration of the hit was not extended to 600")
+	}
+
+	if _, err := client.GetItemWithLock("stock:1", 900, 5, nil); err != nil {
+		t.Fatal(err)
+	}
+	if !waitForExpiration(server, "stock:1", 900) {
+		t.Error("the expiration of the hit with lock was not extended to 900")
+	}
+
+	// A miss is loaded and added with the expiration, there is nothing to extend
+	value, err = client.GetItem("stock:2", 600, func() (interface{}, error) { return "loaded", nil })
+	if err != nil || value != "loaded" {
+		t.Fatalf("GetItem() of a miss = %v, %v, want loaded", value, err)
+	}
+	if item, ok := server.item("stock:2"); !ok || item.expiration != 600 {
+		t.Errorf("missed item = %+v, %v, want it added for 600 seconds", item, ok)
+	}
+}
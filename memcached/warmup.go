@@ -0,0 +1,206 @@
+package memcached
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/carwale/golibraries/gologger"
+	"github.com/carwale/gomemcache/memcache"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// KV is an item loaded into the cache by Warmup.
+// Expiration is the cache expiration time, in seconds, like in AddItem
+type KV struct {
+	Key        string
+	Value      interface{}
+	Expiration int32
+}
+
+// WarmupResult has the number of items stored, skipped because they were already in the cache,
+// and failed during a warmup
+type WarmupResult struct {
+	Stored  int64
+	Skipped int64
+	Failed  int64
+}
+
+// WarmupOption sets a parameter of Warmup
+type WarmupOption func(w *warmupConfig)
+
+type warmupConfig struct {
+	rate             int
+	overwrite        bool
+	progressInterval time.Duration
+	latencyLogger    gologger.IMultiLogger
+}
+
+// SetWarmupRate limits the number of items stored per second across all the workers. Default is 0, no limit
+func SetWarmupRate(perSecond int) WarmupOption {
+	return func(w *warmupConfig) {
+		if perSecond >= 0 {
+			w.rate = perSecond
+		}
+	}
+}
+
+// SetWarmupOverwrite replaces the items that are already in the cache. By default they are kept,
+// as they are at least as fresh as the loaded values
+func SetWarmupOverwrite(overwrite bool) WarmupOption {
+	return func(w *warmupConfig) { w.overwrite = overwrite }
+}
+
+// SetWarmupProgressInterval sets how often the progress of the warmup is logged. Default is 10 seconds
+func SetWarmupProgressInterval(interval time.Duration) WarmupOption {
+	return func(w *warmupConfig) {
+		if interval > 0 {
+			w.progressInterval = interval
+		}
+	}
+}
+
+// SetWarmupLatencyLogger sets the latency logger used for the warmup metrics. Default is the RateLatencyLogger
+func SetWarmupLatencyLogger(latencyLogger gologger.IMultiLogger) WarmupOption {
+	return func(w *warmupConfig) { w.latencyLogger = latencyLogger }
+}
+
+const warmupItemsMetricID = "MEMCACHED-WARMUP-ITEMS"
+
+// warmupMetrics are registered with prometheus once and added to every latency logger used by a warmup
+var warmupMetrics = gologger.NewMetricSet(func(logger gologger.ILogger) map[string]gologger.IMetricVec {
+	return map[string]gologger.IMetricVec{
+		warmupItemsMetricID: gologger.NewCounterMetric(prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "memcached_warmup_items_total",
+				Help: "Number of items processed by the cache warmup by status (stored, skipped, failed)",
+			},
+			[]string{"Status"},
+		), logger),
+	}
+})
+
+var (
+	defaultWarmupLatencyLoggerMu sync.Mutex
+	// defaultWarmupLatencyLogger is the latency logger of the warmups without SetWarmupLatencyLogger
+	defaultWarmupLatencyLogger gologger.IMultiLogger
+)
+
+// warmupLatencyLogger returns the latency logger of the warmup, with the warmup metrics added to it
+func (c *CacheClient) warmupLatencyLogger(latencyLogger gologger.IMultiLogger) gologger.IMultiLogger {
+	if latencyLogger == nil {
+		defaultWarmupLatencyLoggerMu.Lock()
+		if defaultWarmupLatencyLogger == nil {
+			defaultWarmupLatencyLogger = gologger.NewRateLatencyLogger(gologger.SetLogger(c.logger))
+		}
+		latencyLogger = defaultWarmupLatencyLogger
+		defaultWarmupLatencyLoggerMu.Unlock()
+	}
+	warmupMetrics.AddTo(latencyLogger, c.logger)
+	return latencyLogger
+}
+
+// Warmup streams the items sent by loader into the cache with concurrency workers. It is used after deploys
+// or flushes of the cache cluster so that the first requests do not all go to the database.
+// loader must send the items on the channel and return when done; Warmup closes the channel after that.
+// Warmup stops early when ctx is done. It returns the counts of the items and the error of the loader or of ctx
+//
+//	result, err := client.Warmup(ctx, func(ch chan<- memcached.KV) error {
+//		for _, stock := range stocks {
+//			ch <- memcached.KV{Key: keys.Key(stock.ID), Value: stock, Expiration: 3600}
+//		}
+//		return nil
+//	}, 8, memcached.SetWarmupRate(2000))
+func (c *CacheClient) Warmup(ctx context.Context, loader func(ch chan<- KV) error, concurrency int, options ...WarmupOption) (WarmupResult, error) {
+	config := warmupConfig{progressInterval: 10 * time.Second}
+	for _, option := range options {
+		option(&config)
+	}
+	config.latencyLogger = c.warmupLatencyLogger(config.latencyLogger)
+	if concurrency <= 0 {
+		concurrency = 1
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	var throttle <-chan time.Time
+	if config.rate > 0 {
+		ticker := time.NewTicker(time.Second / time.Duration(config.rate))
+		defer ticker.Stop()
+		throttle = ticker.C
+	}
+
+	items := make(chan KV, concurrency)
+	var result WarmupResult
+	var wg sync.WaitGroup
+	wg.Add(concurrency)
+	for i := 0; i < concurrency; i++ {
+		go func() {
+			defer wg.Done()
+			// Drain the channel after ctx is done, so that the loader is not blocked
+			for kv := range items {
+				if ctx.Err() != nil {
+					continue
+				}
+				if throttle != nil {
+					select {
+					case <-throttle:
+					case <-ctx.Done():
+						continue
+					}
+				}
+				c.warmupItem(kv, config, &result)
+			}
+		}()
+	}
+
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		ticker := time.NewTicker(config.progressInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				c.logger.LogInfof("Cache warmup in progress: %d stored, %d skipped, %d failed",
+					atomic.LoadInt64(&result.Stored), atomic.LoadInt64(&result.Skipped), atomic.LoadInt64(&result.Failed))
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	err := loader(items)
+	close(items)
+	wg.Wait()
+	if err == nil {
+		err = ctx.Err()
+	}
+	c.logger.LogInfof("Cache warmup finished: %d stored, %d skipped, %d failed", result.Stored, result.Skipped, result.Failed)
+	return result, err
+}
+
+// warmupItem stores the item and counts the result
+func (c *CacheClient) warmupItem(kv KV, config warmupConfig, result *WarmupResult) {
+	item, err := CreateMemCacheObject(kv.Key, kv.Value, kv.Expiration)
+	if err == nil {
+		if config.overwrite {
+			err = c.client.Set(item)
+		} else {
+			err = c.client.Add(item)
+		}
+	}
+	switch {
+	case err == nil:
+		atomic.AddInt64(&result.Stored, 1)
+		config.latencyLogger.IncVal(1, warmupItemsMetricID, "stored")
+	case err == memcache.ErrNotStored:
+		atomic.AddInt64(&result.Skipped, 1)
+		config.latencyLogger.IncVal(1, warmupItemsMetricID, "skipped")
+	default:
+		atomic.AddInt64(&result.Failed, 1)
+		config.latencyLogger.IncVal(1, warmupItemsMetricID, "failed")
+		c.logger.LogErrorWithoutErrorf("Cache warmup failed to store key %s: %v", kv.Key, err)
+	}
+}
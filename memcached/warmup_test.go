@@ -0,0 +1,82 @@
+package memcached
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/carwale/golibraries/gologger"
+)
+
+type recordingLatencyLogger struct {
+	lock    sync.Mutex
+	metrics map[string]bool
+	values  map[string]int64
+}
+
+func newRecordingLatencyLogger() *recordingLatencyLogger {
+	return &recordingLatencyLogger{metrics: map[string]bool{}, values: map[string]int64{}}
+}
+
+func (l *recordingLatencyLogger) Tic() time.Time                                   { return time.Now() }
+func (l *recordingLatencyLogger) Toc(start time.Time, id string, labels ...string) {}
+func (l *recordingLatencyLogger) IncVal(value int64, id string, labels ...string) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	if !l.metrics[id] {
+		return
+	}
+	l.values[id+"/"+strings.Join(labels, "/")] += value
+}
+func (l *recordingLatencyLogger) SubVal(value int64, id string, labels ...string) {
+	l.IncVal(-value, id, labels...)
+}
+func (l *recordingLatencyLogger) SetVal(value int64, id string, labels ...string) {}
+func (l *recordingLatencyLogger) AddNewMetric(id string, metric gologger.IMetricVec) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	l.metrics[id] = true
+}
+
+func loadKeys(keys ...string) func(ch chan<- KV) error {
+	return func(ch chan<- KV) error {
+		for _, key := range keys {
+			ch <- KV{Key: key, Value: key, Expiration: 60}
+		}
+		return nil
+	}
+}
+
+func TestWarmupCountsItemsOnEveryLatencyLogger(t *testing.T) {
+	client, server := newTestClient(t)
+	first, second := newRecordingLatencyLogger(), newRecordingLatencyLogger()
+
+	result, err := client.Warmup(context.Background(), loadKeys("a", "b"), 2, SetWarmupLatencyLogger(first))
+	if err != nil || result.Stored != 2 {
+		t.Fatalf("Warmup() = %+v, %v, want 2 stored", result, err)
+	}
+	result, err = client.Warmup(context.Background(), loadKeys("b", "c"), 2, SetWarmupLatencyLogger(second))
+	if err != nil || result.Stored != 1 || result.Skipped != 1 {
+		t.Fatalf("Warmup() = %+v, %v, want 1 stored and 1 skipped", result, err)
+	}
+
+	if first.values[warmupItemsMetricID+"/stored"] != 2 {
+		t.Errorf("first latency logger values = %v, want 2 stored", first.values)
+	}
+	if second.values[warmupItemsMetricID+"/stored"] != 1 || second.values[warmupItemsMetricID+"/skipped"] != 1 {
+		t.Errorf("second latency logger values = %v, want 1 stored and 1 skipped", second.values)
+	}
+	if item, ok := server.item("c"); !ok || item.expiration != 60 {
+		t.Errorf("item c = %+v, %v, want it stored for 60 seconds", item, ok)
+	}
+}
+
+func TestWarmupReusesTheDefaultLatencyLogger(t *testing.T) {
+	client, _ := newTestClient(t)
+	first := client.warmupLatencyLogger(nil)
+	if second := client.warmupLatencyLogger(nil); second != first {
+		t.Error("each warmup got a new default latency logger")
+	}
+}
@@ -0,0 +1,225 @@
+// Package notifier sends operational alerts, like a dead letter queue overflowing or a circuit opening,
+// by e-mail, SMS or webhook. Senders implement INotifier; wrap them with NewNotifier to add rate
+// limiting, timeouts, logging and delivery metrics
+//
+//	mail := notifier.NewSMTPNotifier("smtp.internal:587", "alerts@carwale.com",
+//		notifier.SetSMTPAuth(username, password), notifier.SetSMTPRecipients("oncall@carwale.com"))
+//	alerts := notifier.NewNotifier("oncall", mail, notifier.SetRateLimit(10, time.Minute))
+//
+//	overflow := notifier.MustTemplate("DLQ {{.Queue}} overflowing", "{{.Count}} messages in {{.Queue}}")
+//	message, err := overflow.Render(map[string]interface{}{"Queue": "stocks-dlq", "Count": 1200})
+//	err = alerts.Notify(ctx, message)
+package notifier
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"sync"
+	"text/template"
+	"time"
+
+	"github.com/carwale/golibraries/gologger"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// ErrRateLimited is returned by Notify when the notification is dropped by the rate limit
+var ErrRateLimited = errors.New("notification dropped by the rate limit")
+
+// Message is a notification
+type Message struct {
+	Subject string
+	Body    string
+	// Recipients are the addresses or phone numbers of the message. The default recipients of
+	// the sender are used if it is empty
+	Recipients []string
+}
+
+// INotifier sends notifications
+type INotifier interface {
+	Notify(ctx context.Context, message Message) error
+}
+
+// NotifierFunc is a function implementing INotifier
+type NotifierFunc func(ctx context.Context, message Message) error
+
+// Notify calls f
+func (f NotifierFunc) Notify(ctx context.Context, message Message) error {
+	return f(ctx, message)
+}
+
+// Template renders the subject and the body of messages with text/template
+type Template struct {
+	subject *template.Template
+	body    *template.Template
+}
+
+// NewTemplate parses the templates of the subject and of the body
+func NewTemplate(subject string, body string) (*Template, error) {
+	subjectTemplate, err := template.New("subject").Parse(subject)
+	if err != nil {
+		return nil, err
+	}
+	bodyTemplate, err := template.New("body").Parse(body)
+	if err != nil {
+		return nil, err
+	}
+	return &Template{subject: subjectTemplate, body: bodyTemplate}, nil
+}
+
+// MustTemplate is like NewTemplate but panics if a template cannot be parsed
+func MustTemplate(subject string, body string) *Template {
+	t, err := NewTemplate(subject, body)
+	if err != nil {
+		panic("notifier: " + err.Error())
+	}
+	return t
+}
+
+// Render returns the message for the data
+func (t *Template) Render(data interface{}, recipients ...string) (Message, error) {
+	var subject, body bytes.Buffer
+	if err := t.subject.Execute(&subject, data); err != nil {
+		return Message{}, err
+	}
+	if err := t.body.Execute(&body, data); err != nil {
+		return Message{}, err
+	}
+	return Message{Subject: subject.String(), Body: body.String(), Recipients: recipients}, nil
+}
+
+// Option sets a parameter of the notifier
+type Option func(n *Notifier)
+
+// SetRateLimit lets at most limit notifications through every interval, with bursts up to limit.
+// Notifications over the limit are dropped. Disabled by default
+func SetRateLimit(limit int, interval time.Duration) Option {
+	return func(n *Notifier) {
+		if limit > 0 && interval > 0 {
+			n.limiter = &tokenBucket{capacity: float64(limit), tokens: float64(limit), rate: float64(limit) / interval.Seconds()}
+		}
+	}
+}
+
+// SetTimeout sets how long a notification can take. Default is 10 seconds
+func SetTimeout(timeout time.Duration) Option {
+	return func(n *Notifier) {
+		if timeout > 0 {
+			n.timeout = timeout
+		}
+	}
+}
+
+// SetLogger sets the logger of the notifier
+func SetLogger(logger gologger.ILogger) Option {
+	return func(n *Notifier) { n.logger = logger }
+}
+
+// SetLatencyLogger sets the latency logger used for the metrics of the notifier
+func SetLatencyLogger(latencyLogger gologger.IMultiLogger) Option {
+	return func(n *Notifier) { n.latencyLogger = latencyLogger }
+}
+
+const (
+	notificationsMetricID = "NOTIFIER-NOTIFICATIONS"
+	latencyMetricID       = "NOTIFIER-LATENCY"
+)
+
+var notifierMetrics = gologger.NewMetricSet(func(logger gologger.ILogger) map[string]gologger.IMetricVec {
+	return map[string]gologger.IMetricVec{
+		notificationsMetricID: gologger.NewCounterMetric(prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "notifier_notifications_total",
+				Help: "Number of notifications by notifier and status (sent, failed, dropped)",
+			},
+			[]string{"Notifier", "Status"},
+		), logger),
+		latencyMetricID: gologger.NewHistogramMetric(prometheus.NewHistogramVec(
+			prometheus.HistogramOpts{
+				Name: "notifier_latency_milliseconds",
+				Help: "Latency of the notifications by notifier",
+			},
+			[]string{"Notifier"},
+		), logger),
+	}
+})
+
+// Notifier sends notifications with a sender, dropping the notifications over the rate limit
+// and recording the deliveries in the metrics
+type Notifier struct {
+	name          string
+	sender        INotifier
+	limiter       *tokenBucket
+	timeout       time.Duration
+	logger        gologger.ILogger
+	latencyLogger gologger.IMultiLogger
+}
+
+var _ INotifier = (*Notifier)(nil)
+
+// NewNotifier returns a notifier sending with the sender. name is the label of the metrics
+func NewNotifier(name string, sender INotifier, options ...Option) *Notifier {
+	n := &Notifier{name: name, sender: sender, timeout: 10 * time.Second}
+	for _, option := range options {
+		option(n)
+	}
+	if n.logger == nil {
+		n.logger = gologger.NewLogger(gologger.SetLogLevel("ERROR"))
+	}
+	if n.latencyLogger == nil {
+		n.latencyLogger = gologger.NewRateLatencyLogger(gologger.SetLogger(n.logger))
+	}
+	notifierMetrics.AddTo(n.latencyLogger, n.logger)
+	return n
+}
+
+// Notify sends the message. It returns ErrRateLimited without sending if the message is over the rate limit.
+// It blocks until the message is sent, call it in a goroutine from code which must not wait
+func (n *Notifier) Notify(ctx context.Context, message Message) error {
+	if n.limiter != nil && !n.limiter.take(time.Now()) {
+		n.latencyLogger.IncVal(1, notificationsMetricID, n.name, "dropped")
+		n.logger.LogWarningMessage("Notification dropped by the rate limit",
+			gologger.Pair{Key: "notifier", Value: n.name}, gologger.Pair{Key: "notification_subject", Value: message.Subject})
+		return ErrRateLimited
+	}
+	ctx, cancel := context.WithTimeout(ctx, n.timeout)
+	defer cancel()
+	start := time.Now()
+	err := n.sender.Notify(ctx, message)
+	n.latencyLogger.Toc(start, latencyMetricID, n.name)
+	if err != nil {
+		n.latencyLogger.IncVal(1, notificationsMetricID, n.name, "failed")
+		n.logger.LogErrorMessage("could not send notification", err,
+			gologger.Pair{Key: "notifier", Value: n.name}, gologger.Pair{Key: "notification_subject", Value: message.Subject})
+		return err
+	}
+	n.latencyLogger.IncVal(1, notificationsMetricID, n.name, "sent")
+	return nil
+}
+
+// tokenBucket is a token bucket refilled at rate tokens per second
+type tokenBucket struct {
+	mu       sync.Mutex
+	capacity float64
+	tokens   float64
+	rate     float64
+	last     time.Time
+}
+
+// take takes a token if there is one
+func (b *tokenBucket) take(now time.Time) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if !b.last.IsZero() {
+		b.tokens += now.Sub(b.last).Seconds() * b.rate
+		if b.tokens > b.capacity {
+			b.tokens = b.capacity
+		}
+	}
+	b.last = now
+	if b.tokens < 1 {
+		return false
+	}
+	b.tokens--
+	return true
+}
@@ -0,0 +1,125 @@
+package notifier
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestTemplateRender(t *testing.T) {
+	overflow := MustTemplate("DLQ {{.Queue}} overflowing", "{{.Count}} messages in {{.Queue}}")
+	message, err := overflow.Render(map[string]interface{}{"Queue": "stocks-dlq", "Count": 1200}, "oncall@carwale.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if message.Subject != "DLQ stocks-dlq overflowing" || message.Body != "1200 messages in stocks-dlq" || len(message.Recipients) != 1 {
+		t.Errorf("message = %+v", message)
+	}
+}
+
+func TestNotifierRateLimit(t *testing.T) {
+	var sent int
+	n := NewNotifier("test", NotifierFunc(func(ctx context.Context, message Message) error {
+		sent++
+		return nil
+	}), SetRateLimit(2, time.Hour))
+	for i := 0; i < 3; i++ {
+		err := n.Notify(context.Background(), Message{Subject: "circuit open"})
+		if i < 2 && err != nil || i == 2 && !errors.Is(err, ErrRateLimited) {
+			t.Errorf("Notify() %d error = %v", i, err)
+		}
+	}
+	if sent != 2 {
+		t.Errorf("sent %d notifications, want 2", sent)
+	}
+}
+
+func TestWebhookNotifier(t *testing.T) {
+	var received map[string]interface{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Api-Key") != "secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		json.NewDecoder(r.Body).Decode(&received)
+	}))
+	defer server.Close()
+
+	n := NewWebhookNotifier(server.URL, SetWebhookHeader("X-Api-Key", "secret"))
+	if err := n.Notify(context.Background(), Message{Subject: "circuit open", Body: "stocks"}); err != nil {
+		t.Fatal(err)
+	}
+	if received["subject"] != "circuit open" || received["body"] != "stocks" {
+		t.Errorf("received = %v", received)
+	}
+	if err := NewWebhookNotifier(server.URL).Notify(context.Background(), Message{}); err == nil {
+		t.Error("Notify() did not fail on the 401 status")
+	}
+}
+
+func TestSMTPNotifier(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+	data := make(chan string, 1)
+	go serveSMTP(listener, data)
+
+	n := NewSMTPNotifier(listener.Addr().String(), "alerts@carwale.com", SetSMTPRecipients("oncall@carwale.com"))
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := n.Notify(ctx, Message{Subject: "DLQ overflowing", Body: "1200 messages\nin stocks-dlq"}); err != nil {
+		t.Fatal(err)
+	}
+	mail := <-data
+	if !strings.Contains(mail, "To: oncall@carwale.com\r\n") || !strings.Contains(mail, "Subject: DLQ overflowing\r\n") ||
+		!strings.HasSuffix(mail, "1200 messages\r\nin stocks-dlq\r\n") {
+		t.Errorf("mail = %q", mail)
+	}
+}
+
+// serveSMTP serves one session of a minimal SMTP server and sends the data of the mail
+func serveSMTP(listener net.Listener, data chan<- string) {
+	conn, err := listener.Accept()
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+	reader := bufio.NewReader(conn)
+	conn.Write([]byte("220 localhost ready\r\n"))
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			return
+		}
+		switch command := strings.ToUpper(strings.Fields(line)[0]); command {
+		case "EHLO", "HELO", "MAIL", "RCPT":
+			conn.Write([]byte("250 ok\r\n"))
+		case "DATA":
+			conn.Write([]byte("354 go ahead\r\n"))
+			var mail strings.Builder
+			for {
+				dataLine, err := reader.ReadString('\n')
+				if err != nil || dataLine == ".\r\n" {
+					break
+				}
+				mail.WriteString(dataLine)
+			}
+			data <- mail.String()
+			conn.Write([]byte("250 queued\r\n"))
+		case "QUIT":
+			conn.Write([]byte("221 bye\r\n"))
+			return
+		default:
+			conn.Write([]byte("502 not implemented\r\n"))
+		}
+	}
+}
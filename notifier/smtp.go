@@ -0,0 +1,144 @@
+package notifier
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"errors"
+	"mime"
+	"net"
+	"net/smtp"
+	"strings"
+	"time"
+)
+
+// SMTPOption sets a parameter of the SMTP notifier
+type SMTPOption func(n *SMTPNotifier)
+
+// SetSMTPAuth sets the credentials of the PLAIN authentication. The server must support STARTTLS,
+// as net/smtp only sends the credentials over TLS or to localhost
+func SetSMTPAuth(username, password string) SMTPOption {
+	return func(n *SMTPNotifier) {
+		n.username = username
+		n.password = password
+	}
+}
+
+// SetSMTPRecipients sets the addresses of the messages without recipients
+func SetSMTPRecipients(recipients ...string) SMTPOption {
+	return func(n *SMTPNotifier) { n.recipients = recipients }
+}
+
+// SetSMTPTLSConfig sets the TLS configuration of STARTTLS. Default verifies the certificate of the host
+func SetSMTPTLSConfig(config *tls.Config) SMTPOption {
+	return func(n *SMTPNotifier) { n.tlsConfig = config }
+}
+
+// SMTPNotifier sends the notifications as plain text e-mails
+type SMTPNotifier struct {
+	address    string
+	host       string
+	from       string
+	username   string
+	password   string
+	recipients []string
+	tlsConfig  *tls.Config
+}
+
+var _ INotifier = (*SMTPNotifier)(nil)
+
+// NewSMTPNotifier returns a notifier sending from the address from through the server at address, e.g.
+// smtp.internal:587. It panics if address has no port
+func NewSMTPNotifier(address string, from string, options ...SMTPOption) *SMTPNotifier {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		panic("notifier: invalid smtp address " + address + ": " + err.Error())
+	}
+	n := &SMTPNotifier{address: address, host: host, from: from}
+	for _, option := range options {
+		option(n)
+	}
+	if n.tlsConfig == nil {
+		n.tlsConfig = &tls.Config{ServerName: host, MinVersion: tls.VersionTLS12}
+	}
+	return n
+}
+
+// Notify sends the message. The connection is closed when ctx is done
+func (n *SMTPNotifier) Notify(ctx context.Context, message Message) error {
+	recipients := message.Recipients
+	if len(recipients) == 0 {
+		recipients = n.recipients
+	}
+	if len(recipients) == 0 {
+		return errors.New("notifier: e-mail has no recipients")
+	}
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "tcp", n.address)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		select {
+		case <-ctx.Done():
+			conn.SetDeadline(time.Now())
+		case <-done:
+		}
+	}()
+
+	client, err := smtp.NewClient(conn, n.host)
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+	if ok, _ := client.Extension("STARTTLS"); ok {
+		if err := client.StartTLS(n.tlsConfig); err != nil {
+			return err
+		}
+	}
+	if n.username != "" {
+		if err := client.Auth(smtp.PlainAuth("", n.username, n.password, n.host)); err != nil {
+			return err
+		}
+	}
+	if err := client.Mail(n.from); err != nil {
+		return err
+	}
+	for _, recipient := range recipients {
+		if err := client.Rcpt(recipient); err != nil {
+			return err
+		}
+	}
+	writer, err := client.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := writer.Write(n.compose(message, recipients, time.Now())); err != nil {
+		return err
+	}
+	if err := writer.Close(); err != nil {
+		return err
+	}
+	return client.Quit()
+}
+
+// compose returns the headers and the body of the e-mail
+func (n *SMTPNotifier) compose(message Message, recipients []string, now time.Time) []byte {
+	var b bytes.Buffer
+	b.WriteString("From: " + n.from + "\r\n")
+	b.WriteString("To: " + strings.Join(recipients, ", ") + "\r\n")
+	b.WriteString("Subject: " + mime.QEncoding.Encode("utf-8", message.Subject) + "\r\n")
+	b.WriteString("Date: " + now.Format(time.RFC1123Z) + "\r\n")
+	b.WriteString("MIME-Version: 1.0\r\n")
+	b.WriteString("Content-Type: text/plain; charset=utf-8\r\n")
+	b.WriteString("\r\n")
+	// lines end with CRLF in SMTP
+	b.WriteString(strings.ReplaceAll(strings.ReplaceAll(message.Body, "\r\n", "\n"), "\n", "\r\n"))
+	return b.Bytes()
+}
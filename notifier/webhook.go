@@ -0,0 +1,127 @@
+package notifier
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// WebhookOption sets a parameter of the webhook notifier
+type WebhookOption func(n *WebhookNotifier)
+
+// SetWebhookEncoder sets the function building the body of the request from the message, e.g. the payload
+// of a chat webhook or of an SMS gateway. Default is the json object {"subject", "body", "recipients"}
+func SetWebhookEncoder(encode func(message Message) ([]byte, error)) WebhookOption {
+	return func(n *WebhookNotifier) { n.encode = encode }
+}
+
+// SetWebhookHeader sets a header of the requests, e.g. the api key of the provider
+func SetWebhookHeader(key, value string) WebhookOption {
+	return func(n *WebhookNotifier) { n.header.Set(key, value) }
+}
+
+// SetWebhookHTTPClient sets the http client of the requests. Default is a client with a 10 second timeout
+func SetWebhookHTTPClient(httpClient *http.Client) WebhookOption {
+	return func(n *WebhookNotifier) { n.httpClient = httpClient }
+}
+
+// WebhookNotifier posts the notifications to a url
+type WebhookNotifier struct {
+	url        string
+	encode     func(message Message) ([]byte, error)
+	header     http.Header
+	httpClient *http.Client
+}
+
+var _ INotifier = (*WebhookNotifier)(nil)
+
+// NewWebhookNotifier returns a notifier posting to the url
+func NewWebhookNotifier(url string, options ...WebhookOption) *WebhookNotifier {
+	n := &WebhookNotifier{
+		url:        url,
+		encode:     encodeMessage,
+		header:     http.Header{"Content-Type": {"application/json"}},
+		httpClient: &http.Client{Timeout: 10 * time.Second},
+	}
+	for _, option := range options {
+		option(n)
+	}
+	return n
+}
+
+// Notify posts the message. A response status other than 2xx is an error
+func (n *WebhookNotifier) Notify(ctx context.Context, message Message) error {
+	body, err := n.encode(message)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, n.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	for key, values := range n.header {
+		req.Header[key] = values
+	}
+	resp, err := n.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		reply, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("webhook responded with status %d: %s", resp.StatusCode, strings.TrimSpace(string(reply)))
+	}
+	io.Copy(io.Discard, resp.Body)
+	return nil
+}
+
+func encodeMessage(message Message) ([]byte, error) {
+	return json.Marshal(struct {
+		Subject    string   `json:"subject"`
+		Body       string   `json:"body"`
+		Recipients []string `json:"recipients,omitempty"`
+	}{message.Subject, message.Body, message.Recipients})
+}
+
+// ISMSProvider sends a text message to a phone number. Implement it for the SMS gateway in use
+type ISMSProvider interface {
+	SendSMS(ctx context.Context, phoneNumber string, text string) error
+}
+
+// SMSNotifier sends the notifications as text messages to every recipient
+type SMSNotifier struct {
+	provider   ISMSProvider
+	recipients []string
+}
+
+var _ INotifier = (*SMSNotifier)(nil)
+
+// NewSMSNotifier returns a notifier sending with the provider. recipients are the phone numbers of the
+// messages without recipients
+func NewSMSNotifier(provider ISMSProvider, recipients ...string) *SMSNotifier {
+	return &SMSNotifier{provider: provider, recipients: recipients}
+}
+
+// Notify sends the subject and the body, separated by a new line, to every recipient. It stops at the
+// first recipient the provider fails to send to
+func (n *SMSNotifier) Notify(ctx context.Context, message Message) error {
+	recipients := message.Recipients
+	if len(recipients) == 0 {
+		recipients = n.recipients
+	}
+	text := message.Subject
+	if message.Body != "" {
+		text += "\n" + message.Body
+	}
+	for _, recipient := range recipients {
+		if err := n.provider.SendSMS(ctx, recipient, text); err != nil {
+			return fmt.Errorf("could not send sms to %s: %w", recipient, err)
+		}
+	}
+	return nil
+}
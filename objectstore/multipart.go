@@ -0,0 +1,147 @@
+package objectstore
+
+import (
+	"bytes"
+	"context"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/carwale/golibraries/gologger"
+)
+
+type completedPart struct {
+	PartNumber int    `xml:"PartNumber"`
+	ETag       string `xml:"ETag"`
+}
+
+type completeMultipartUpload struct {
+	XMLName xml.Name        `xml:"CompleteMultipartUpload"`
+	Parts   []completedPart `xml:"Part"`
+}
+
+// putMultipart uploads the first part, read into the buffer of the part size, and the rest of r in parts.
+// It aborts the upload if a part cannot be uploaded, so that the store does not keep the parts
+func (c *Client) putMultipart(ctx context.Context, key string, buffer []byte, r io.Reader, contentType string) error {
+	uploadID, err := c.createMultipartUpload(ctx, key, contentType)
+	if err != nil {
+		return err
+	}
+	parts, uploaded, err := c.uploadParts(ctx, key, uploadID, buffer, r)
+	if err == nil {
+		err = c.completeMultipartUpload(ctx, key, uploadID, parts)
+	}
+	if err != nil {
+		// the context of the upload can be done, the abort gets its own
+		abortCtx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+		if abortErr := c.abortMultipartUpload(abortCtx, key, uploadID); abortErr != nil {
+			c.logger.LogErrorMessage("could not abort multipart upload", abortErr,
+				gologger.Pair{Key: "object_key", Value: key}, gologger.Pair{Key: "upload_id", Value: uploadID})
+		}
+		return err
+	}
+	c.latencyLogger.IncVal(uploaded, bytesMetricID, c.bucket, "upload")
+	return nil
+}
+
+func (c *Client) createMultipartUpload(ctx context.Context, key string, contentType string) (string, error) {
+	header := http.Header{}
+	if contentType != "" {
+		header.Set("Content-Type", contentType)
+	}
+	resp, err := c.do(ctx, request{operation: "create_multipart", method: http.MethodPost, key: key,
+		query: url.Values{"uploads": {""}}, header: header})
+	if err != nil {
+		return "", err
+	}
+	defer drain(resp)
+	var result struct {
+		UploadID string `xml:"UploadId"`
+	}
+	if err := xml.NewDecoder(resp.Body).Decode(&result); err != nil || result.UploadID == "" {
+		return "", fmt.Errorf("could not read the upload id of %s: %v", key, err)
+	}
+	return result.UploadID, nil
+}
+
+// uploadParts uploads the full buffer and then r in parts, reading one part at a time into the buffer.
+// It returns the parts and the number of bytes
+func (c *Client) uploadParts(ctx context.Context, key string, uploadID string, buffer []byte, r io.Reader) ([]completedPart, int64, error) {
+	var parts []completedPart
+	var uploaded int64
+	n := len(buffer)
+	var readErr error
+	for number := 1; ; number++ {
+		if number > 1 {
+			n, readErr = io.ReadFull(r, buffer)
+			if readErr != nil && readErr != io.EOF && readErr != io.ErrUnexpectedEOF {
+				return nil, 0, readErr
+			}
+			if n == 0 {
+				return parts, uploaded, nil
+			}
+		}
+		if number > maxParts {
+			return nil, 0, fmt.Errorf("object %s has more than %d parts of %d bytes", key, maxParts, c.partSize)
+		}
+		resp, err := c.do(ctx, request{operation: "put_part", method: http.MethodPut, key: key,
+			query: url.Values{"partNumber": {strconv.Itoa(number)}, "uploadId": {uploadID}},
+			body:  bytes.NewReader(buffer[:n]), size: int64(n)})
+		if err != nil {
+			return nil, 0, err
+		}
+		drain(resp)
+		parts = append(parts, completedPart{PartNumber: number, ETag: resp.Header.Get("ETag")})
+		uploaded += int64(n)
+		if readErr != nil {
+			return parts, uploaded, nil
+		}
+	}
+}
+
+func (c *Client) completeMultipartUpload(ctx context.Context, key string, uploadID string, parts []completedPart) error {
+	body, err := xml.Marshal(completeMultipartUpload{Parts: parts})
+	if err != nil {
+		return err
+	}
+	resp, err := c.do(ctx, request{operation: "complete_multipart", method: http.MethodPost, key: key,
+		query: url.Values{"uploadId": {uploadID}}, header: http.Header{"Content-Type": {"application/xml"}},
+		body: bytes.NewReader(body), size: int64(len(body))})
+	if err != nil {
+		return err
+	}
+	defer drain(resp)
+	// S3 can fail the completion after sending the 200 status, with an error in the body
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	if strings.Contains(string(data), "<Error>") {
+		var errorBody struct {
+			Code    string `xml:"Code"`
+			Message string `xml:"Message"`
+		}
+		xml.Unmarshal(data, &errorBody)
+		return &ResponseError{Status: resp.StatusCode, Code: errorBody.Code, Message: errorBody.Message}
+	}
+	return nil
+}
+
+func (c *Client) abortMultipartUpload(ctx context.Context, key string, uploadID string) error {
+	resp, err := c.do(ctx, request{operation: "abort_multipart", method: http.MethodDelete, key: key,
+		query: url.Values{"uploadId": {uploadID}}})
+	if IsNotFound(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	drain(resp)
+	return nil
+}
@@ -0,0 +1,447 @@
+// Package objectstore is a client for S3 and the S3 compatible stores like MinIO. Objects are uploaded and
+// downloaded as streams, large uploads are sent in parts, failed requests are retried with a backoff and
+// every request is recorded in the metrics.
+//
+// The client implements the IBlobStore of the kafka package, so it can hold the payloads of the claim check
+//
+//	store := objectstore.NewClient("http://minio:9000", "claim-checks", accessKey, secretKey)
+//	producer := kafka.NewKafkaProducer(brokers, kafka.SetClaimCheck(store, 512*1024))
+//
+//	err := store.PutObject(ctx, "images/1.jpg", file, size, "image/jpeg")
+//	url, err := store.PresignGet("images/1.jpg", 15*time.Minute)
+package objectstore
+
+import (
+	"bytes"
+	"context"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/carwale/golibraries/gologger"
+	"github.com/carwale/golibraries/reconnect"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const (
+	// MinPartSize is the smallest part S3 accepts for the parts of a multipart upload but the last one
+	MinPartSize = 5 << 20
+	// DefaultPartSize is the default size of the parts of multipart uploads
+	DefaultPartSize = 16 << 20
+	// maxParts is the largest number of parts of a multipart upload
+	maxParts = 10000
+)
+
+// DefaultRetryPolicy retries a request twice, waiting 200 milliseconds and then 400 milliseconds
+var DefaultRetryPolicy = reconnect.Policy{InitialDelay: 200 * time.Millisecond, MaxDelay: 5 * time.Second, Multiplier: 2, Jitter: 0.2, MaxAttempts: 3}
+
+// ResponseError is the error of a request the store responded to with an error status
+type ResponseError struct {
+	Status int
+	// Code and Message are read from the error of the response, e.g. NoSuchKey
+	Code    string
+	Message string
+}
+
+// Error returns the status, code and message of the error
+func (e *ResponseError) Error() string {
+	if e.Code == "" {
+		return "object store request failed with status " + strconv.Itoa(e.Status)
+	}
+	return fmt.Sprintf("object store request failed with status %d: %s: %s", e.Status, e.Code, e.Message)
+}
+
+// IsNotFound returns true if err is a response error with the 404 status
+func IsNotFound(err error) bool {
+	var responseErr *ResponseError
+	return errors.As(err, &responseErr) && responseErr.Status == http.StatusNotFound
+}
+
+// ObjectInfo is the metadata of an object
+type ObjectInfo struct {
+	Key          string
+	Size         int64
+	ETag         string
+	ContentType  string
+	LastModified time.Time
+}
+
+// Option sets a parameter of the client
+type Option func(c *Client)
+
+// SetRegion sets the region the requests are signed for. Default is us-east-1, which MinIO accepts
+func SetRegion(region string) Option {
+	return func(c *Client) {
+		if region != "" {
+			c.signer.region = region
+		}
+	}
+}
+
+// SetVirtualHostedStyle addresses the bucket as a subdomain of the endpoint, e.g. https://bucket.s3.amazonaws.com,
+// instead of as the first segment of the path. Default is false, the path style MinIO needs
+func SetVirtualHostedStyle(flag bool) Option {
+	return func(c *Client) { c.virtualHosted = flag }
+}
+
+// SetHTTPClient sets the http client of the requests. Default is a client without a timeout, as the
+// downloads are streamed; use contexts to bound the requests
+func SetHTTPClient(httpClient *http.Client) Option {
+	return func(c *Client) { c.httpClient = httpClient }
+}
+
+// SetRetryPolicy sets the retries of the requests which fail with a network error or with the 429, 500, 502,
+// 503 or 504 status. Uploads of unknown size are not retried, as their body cannot be read again. Default is
+// DefaultRetryPolicy
+func SetRetryPolicy(policy reconnect.Policy) Option {
+	return func(c *Client) { c.retryPolicy = policy }
+}
+
+// SetPartSize sets the size of the parts of multipart uploads. Objects up to the part size are uploaded in
+// one request. Default is DefaultPartSize, sizes below MinPartSize are raised to it
+func SetPartSize(size int64) Option {
+	return func(c *Client) {
+		if size < MinPartSize {
+			size = MinPartSize
+		}
+		c.partSize = size
+	}
+}
+
+// SetLogger sets the logger of the client
+func SetLogger(logger gologger.ILogger) Option {
+	return func(c *Client) { c.logger = logger }
+}
+
+// SetLatencyLogger sets the latency logger used for the metrics of the client
+func SetLatencyLogger(latencyLogger gologger.IMultiLogger) Option {
+	return func(c *Client) { c.latencyLogger = latencyLogger }
+}
+
+const (
+	requestLatencyMetricID = "OBJECTSTORE-REQUEST-LATENCY"
+	requestErrorsMetricID  = "OBJECTSTORE-REQUEST-ERRORS"
+	bytesMetricID          = "OBJECTSTORE-BYTES"
+)
+
+var objectstoreMetrics = gologger.NewMetricSet(func(logger gologger.ILogger) map[string]gologger.IMetricVec {
+	return map[string]gologger.IMetricVec{
+		requestLatencyMetricID: gologger.NewHistogramMetric(prometheus.NewHistogramVec(
+			prometheus.HistogramOpts{
+				Name: "objectstore_request_latency_milliseconds",
+				Help: "Latency of the requests to the object store by bucket and operation, including the retries",
+			},
+			[]string{"Bucket", "Operation"},
+		), logger),
+		requestErrorsMetricID: gologger.NewCounterMetric(prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "objectstore_request_errors_total",
+				Help: "Number of failed requests to the object store by bucket, operation and status (0 for network errors)",
+			},
+			[]string{"Bucket", "Operation", "Status"},
+		), logger),
+		bytesMetricID: gologger.NewCounterMetric(prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "objectstore_bytes_total",
+				Help: "Number of bytes uploaded to and downloaded from the object store by bucket and direction",
+			},
+			[]string{"Bucket", "Direction"},
+		), logger),
+	}
+})
+
+// Client reads and writes the objects of a bucket
+type Client struct {
+	endpoint      *url.URL
+	bucket        string
+	signer        signer
+	virtualHosted bool
+	httpClient    *http.Client
+	retryPolicy   reconnect.Policy
+	partSize      int64
+	logger        gologger.ILogger
+	latencyLogger gologger.IMultiLogger
+}
+
+// NewClient returns a client of the bucket at the endpoint, e.g. https://s3.ap-south-1.amazonaws.com or
+// http://minio:9000. It panics if the endpoint is not a url or the bucket is empty
+func NewClient(endpoint string, bucket string, accessKey string, secretKey string, options ...Option) *Client {
+	u, err := url.Parse(strings.TrimSuffix(endpoint, "/"))
+	if err != nil || u.Scheme == "" || u.Host == "" {
+		panic("objectstore: invalid endpoint " + strconv.Quote(endpoint))
+	}
+	if bucket == "" {
+		panic("objectstore: no bucket")
+	}
+	c := &Client{
+		endpoint:    u,
+		bucket:      bucket,
+		signer:      signer{accessKey: accessKey, secretKey: secretKey, region: "us-east-1"},
+		httpClient:  &http.Client{},
+		retryPolicy: DefaultRetryPolicy,
+		partSize:    DefaultPartSize,
+	}
+	for _, option := range options {
+		option(c)
+	}
+	if c.logger == nil {
+		c.logger = gologger.NewLogger(gologger.SetLogLevel("ERROR"))
+	}
+	if c.latencyLogger == nil {
+		c.latencyLogger = gologger.NewRateLatencyLogger(gologger.SetLogger(c.logger))
+	}
+	objectstoreMetrics.AddTo(c.latencyLogger, c.logger)
+	return c
+}
+
+// Bucket returns the name of the bucket
+func (c *Client) Bucket() string {
+	return c.bucket
+}
+
+// objectURL returns the url of the object with the key and the query
+func (c *Client) objectURL(key string, query url.Values) *url.URL {
+	u := *c.endpoint
+	path := "/" + key
+	if c.virtualHosted {
+		u.Host = c.bucket + "." + u.Host
+	} else {
+		path = "/" + c.bucket + path
+	}
+	u.Path = strings.TrimSuffix(u.Path, "/") + path
+	u.RawPath = canonicalPath(&u)
+	u.RawQuery = canonicalQuery(query)
+	return &u
+}
+
+// request is a request to the store. A body which is not a *bytes.Reader is not retried
+type request struct {
+	operation string
+	method    string
+	key       string
+	query     url.Values
+	header    http.Header
+	body      io.Reader
+	size      int64
+}
+
+// do sends the request, retrying it on network errors and on the retryable statuses. The caller closes the
+// body of the response. A response with an error status is returned as a *ResponseError
+func (c *Client) do(ctx context.Context, r request) (*http.Response, error) {
+	start := time.Now()
+	defer c.latencyLogger.Toc(start, requestLatencyMetricID, c.bucket, r.operation)
+	replayable, canRetry := r.body.(*bytes.Reader)
+	if r.body == nil {
+		canRetry = true
+	}
+	backoff := c.retryPolicy.NewBackoff()
+	attempts := c.retryPolicy.MaxAttempts
+	if attempts <= 0 || !canRetry {
+		attempts = 1
+	}
+	for attempt := 1; ; attempt++ {
+		if replayable != nil {
+			replayable.Seek(0, io.SeekStart)
+		}
+		resp, retry, err := c.send(ctx, r)
+		if err == nil {
+			return resp, nil
+		}
+		if !retry || attempt >= attempts || !backoff.Sleep(ctx) {
+			status := 0
+			var responseErr *ResponseError
+			if errors.As(err, &responseErr) {
+				status = responseErr.Status
+			}
+			if status != http.StatusNotFound {
+				c.latencyLogger.IncVal(1, requestErrorsMetricID, c.bucket, r.operation, strconv.Itoa(status))
+			}
+			return nil, err
+		}
+		c.logger.LogDebugf("Retrying %s of %s/%s after %v", r.operation, c.bucket, r.key, err)
+	}
+}
+
+// send sends the request once. It returns true if the request can be retried
+func (c *Client) send(ctx context.Context, r request) (*http.Response, bool, error) {
+	req, err := http.NewRequestWithContext(ctx, r.method, c.objectURL(r.key, r.query).String(), r.body)
+	if err != nil {
+		return nil, false, err
+	}
+	if r.body != nil {
+		req.ContentLength = r.size
+		if r.size < 0 {
+			req.ContentLength = -1
+		}
+	}
+	for name, values := range r.header {
+		req.Header[name] = values
+	}
+	c.signer.sign(req, time.Now())
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, ctx.Err() == nil, err
+	}
+	if resp.StatusCode < http.StatusBadRequest {
+		return resp, false, nil
+	}
+	defer resp.Body.Close()
+	responseErr := &ResponseError{Status: resp.StatusCode}
+	var errorBody struct {
+		Code    string `xml:"Code"`
+		Message string `xml:"Message"`
+	}
+	if xml.NewDecoder(io.LimitReader(resp.Body, 1<<16)).Decode(&errorBody) == nil {
+		responseErr.Code = errorBody.Code
+		responseErr.Message = errorBody.Message
+	}
+	switch resp.StatusCode {
+	case http.StatusTooManyRequests, http.StatusInternalServerError, http.StatusBadGateway,
+		http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return nil, true, responseErr
+	}
+	return nil, false, responseErr
+}
+
+// PutObject uploads the object from r. size is the length of r, or -1 if it is not known; objects larger
+// than the part size, or of unknown size, are uploaded in parts of the part size, so at most one part
+// is held in memory. The upload is aborted if a part fails
+func (c *Client) PutObject(ctx context.Context, key string, r io.Reader, size int64, contentType string) error {
+	if size >= 0 && size <= c.partSize {
+		body, err := io.ReadAll(io.LimitReader(r, size))
+		if err != nil {
+			return err
+		}
+		return c.putSingle(ctx, key, body, contentType)
+	}
+	buffer := make([]byte, c.partSize)
+	n, err := io.ReadFull(r, buffer)
+	if err == io.EOF || err == io.ErrUnexpectedEOF {
+		return c.putSingle(ctx, key, buffer[:n], contentType)
+	}
+	if err != nil {
+		return err
+	}
+	return c.putMultipart(ctx, key, buffer, r, contentType)
+}
+
+func (c *Client) putSingle(ctx context.Context, key string, body []byte, contentType string) error {
+	header := http.Header{}
+	if contentType != "" {
+		header.Set("Content-Type", contentType)
+	}
+	resp, err := c.do(ctx, request{operation: "put", method: http.MethodPut, key: key, header: header,
+		body: bytes.NewReader(body), size: int64(len(body))})
+	if err != nil {
+		return err
+	}
+	drain(resp)
+	c.latencyLogger.IncVal(int64(len(body)), bytesMetricID, c.bucket, "upload")
+	return nil
+}
+
+// GetObject returns the content of the object as a stream. The caller closes it
+func (c *Client) GetObject(ctx context.Context, key string) (io.ReadCloser, error) {
+	resp, err := c.do(ctx, request{operation: "get", method: http.MethodGet, key: key})
+	if err != nil {
+		return nil, err
+	}
+	return &countingReader{ReadCloser: resp.Body, client: c}, nil
+}
+
+// StatObject returns the metadata of the object
+func (c *Client) StatObject(ctx context.Context, key string) (ObjectInfo, error) {
+	resp, err := c.do(ctx, request{operation: "stat", method: http.MethodHead, key: key})
+	if err != nil {
+		return ObjectInfo{}, err
+	}
+	drain(resp)
+	info := ObjectInfo{
+		Key:         key,
+		Size:        resp.ContentLength,
+		ETag:        strings.Trim(resp.Header.Get("ETag"), `"`),
+		ContentType: resp.Header.Get("Content-Type"),
+	}
+	info.LastModified, _ = http.ParseTime(resp.Header.Get("Last-Modified"))
+	return info, nil
+}
+
+// DeleteObject deletes the object. Deleting an object that does not exist is not an error
+func (c *Client) DeleteObject(ctx context.Context, key string) error {
+	resp, err := c.do(ctx, request{operation: "delete", method: http.MethodDelete, key: key})
+	if IsNotFound(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	drain(resp)
+	return nil
+}
+
+// Put stores the payload under the reference, so that the client can be the blob store of the claim check
+// of the kafka package
+func (c *Client) Put(ctx context.Context, reference string, payload []byte) error {
+	return c.putSingle(ctx, reference, payload, "application/octet-stream")
+}
+
+// Get returns the payload stored under the reference
+func (c *Client) Get(ctx context.Context, reference string) ([]byte, error) {
+	body, err := c.GetObject(ctx, reference)
+	if err != nil {
+		return nil, err
+	}
+	defer body.Close()
+	return io.ReadAll(body)
+}
+
+// PresignGet returns a url which downloads the object without credentials until it expires, e.g. to hand
+// out images to browsers. Expiry is at most 7 days
+func (c *Client) PresignGet(key string, expires time.Duration) (string, error) {
+	return c.presign(http.MethodGet, key, expires)
+}
+
+// PresignPut returns a url which uploads the object without credentials until it expires, e.g. to let
+// browsers upload images directly. Expiry is at most 7 days
+func (c *Client) PresignPut(key string, expires time.Duration) (string, error) {
+	return c.presign(http.MethodPut, key, expires)
+}
+
+func (c *Client) presign(method string, key string, expires time.Duration) (string, error) {
+	if expires < time.Second || expires > 7*24*time.Hour {
+		return "", fmt.Errorf("presigned url expiry %s is not between 1 second and 7 days", expires)
+	}
+	u := c.objectURL(key, nil)
+	c.signer.presign(method, u, expires, time.Now())
+	return u.String(), nil
+}
+
+// countingReader counts the downloaded bytes in the metrics when it is closed
+type countingReader struct {
+	io.ReadCloser
+	client *Client
+	read   int64
+}
+
+func (r *countingReader) Read(p []byte) (int, error) {
+	n, err := r.ReadCloser.Read(p)
+	r.read += int64(n)
+	return n, err
+}
+
+func (r *countingReader) Close() error {
+	r.client.latencyLogger.IncVal(r.read, bytesMetricID, r.client.bucket, "download")
+	return r.ReadCloser.Close()
+}
+
+// drain reads the rest of the body so that the connection can be reused
+func drain(resp *http.Response) {
+	io.Copy(io.Discard, resp.Body)
+	resp.Body.Close()
+}
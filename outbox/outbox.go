@@ -0,0 +1,262 @@
+// Package outbox implements the transactional outbox: events are written to an outbox table in the same
+// database transaction as the change they describe, and a poller publishes them to kafka afterwards.
+// An event is published only if its transaction commits, and it is published even if the service
+// crashes right after the commit.
+//
+// Publishing is at least once: a poller that crashes after publishing and before marking the events as
+// published publishes them again. The event id is unique, so consumers can drop the duplicates with the
+// dedupe package. With MySQL the data source name needs parseTime=true, so that the times can be read
+//
+//	store := outbox.NewStore(db, outbox.SetDialect(outbox.MySQL))
+//	err := store.CreateTable(ctx)
+//
+//	tx, err := db.BeginTx(ctx, nil)
+//	... update the stock in tx ...
+//	err = store.InsertEvent(ctx, tx, outbox.Event{Topic: "stock-updated", Key: stockID, Payload: payload})
+//	err = tx.Commit()
+//
+//	poller := outbox.NewPoller(store, outbox.KafkaPublisher(producer.PublishMessageWithCallback))
+//	go poller.Run(ctx)
+package outbox
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Dialect is the sql dialect of the outbox table
+type Dialect int
+
+const (
+	// MySQL is the dialect of MySQL 8 and MariaDB 10.6 and later, which support SKIP LOCKED
+	MySQL Dialect = iota
+	// Postgres is the dialect of PostgreSQL 9.5 and later
+	Postgres
+)
+
+// DefaultTable is the default name of the outbox table
+const DefaultTable = "outbox_events"
+
+// Event is an event to publish to a kafka topic
+type Event struct {
+	// ID identifies the event for deduplication. A uuid is generated by InsertEvent if it is empty
+	ID string
+	// Topic is the kafka topic the event is published to
+	Topic string
+	// Key is the kafka key of the event. Events with the same topic and key are published one after the other in
+	// the order they were inserted, also by several pollers: an event is published only once the earlier ones are
+	// delivered. Events without a key are not ordered
+	Key string
+	// Payload is the value of the kafka message, e.g. an encoded envelope
+	Payload []byte
+	// CreatedAt is the time the event was inserted. It is set by InsertEvent
+	CreatedAt time.Time
+
+	sequence int64
+}
+
+// IExecer executes a statement. It is implemented by *sql.Tx, *sql.DB and *sql.Conn
+type IExecer interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+}
+
+// StoreOption sets a parameter of the store
+type StoreOption func(s *Store)
+
+// SetTable sets the name of the outbox table. Default is DefaultTable. It panics if the name is not a
+// plain sql identifier, as it is used in the statements
+func SetTable(table string) StoreOption {
+	return func(s *Store) {
+		if !identifierRegex.MatchString(table) {
+			panic("outbox: invalid table name " + strconv.Quote(table))
+		}
+		s.table = table
+	}
+}
+
+// SetDialect sets the sql dialect of the database. Default is MySQL
+func SetDialect(dialect Dialect) StoreOption {
+	return func(s *Store) { s.dialect = dialect }
+}
+
+var identifierRegex = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*(\.[A-Za-z_][A-Za-z0-9_]*)?$`)
+
+// Store reads and writes the events of an outbox table
+type Store struct {
+	db      *sql.DB
+	table   string
+	dialect Dialect
+}
+
+// NewStore returns the store of the outbox table in db
+func NewStore(db *sql.DB, options ...StoreOption) *Store {
+	s := &Store{db: db, table: DefaultTable, dialect: MySQL}
+	for _, option := range options {
+		option(s)
+	}
+	return s
+}
+
+// Table returns the name of the outbox table
+func (s *Store) Table() string {
+	return s.table
+}
+
+// Schema returns the statements which create the outbox table and its index, if they do not exist
+func (s *Store) Schema() []string {
+	index := "idx_" + strings.ReplaceAll(s.table, ".", "_") + "_pending"
+	if s.dialect == Postgres {
+		return []string{
+			`CREATE TABLE IF NOT EXISTS ` + s.table + ` (
+	id BIGSERIAL PRIMARY KEY,
+	event_id VARCHAR(64) NOT NULL,
+	topic VARCHAR(255) NOT NULL,
+	event_key VARCHAR(255) NOT NULL DEFAULT '',
+	payload BYTEA NOT NULL,
+	created_at TIMESTAMPTZ NOT NULL,
+	published_at TIMESTAMPTZ NULL
+)`,
+			`CREATE INDEX IF NOT EXISTS ` + index + ` ON ` + s.table + ` (id) WHERE published_at IS NULL`,
+		}
+	}
+	return []string{
+		`CREATE TABLE IF NOT EXISTS ` + s.table + ` (
+	id BIGINT NOT NULL AUTO_INCREMENT PRIMARY KEY,
+	event_id VARCHAR(64) NOT NULL,
+	topic VARCHAR(255) NOT NULL,
+	event_key VARCHAR(255) NOT NULL DEFAULT '',
+	payload LONGBLOB NOT NULL,
+	created_at DATETIME(6) NOT NULL,
+	published_at DATETIME(6) NULL,
+	INDEX ` + index + ` (published_at, id)
+)`,
+	}
+}
+
+// CreateTable creates the outbox table and its index if they do not exist
+func (s *Store) CreateTable(ctx context.Context) error {
+	for _, statement := range s.Schema() {
+		if _, err := s.db.ExecContext(ctx, statement); err != nil {
+			return fmt.Errorf("could not create outbox table %s: %w", s.table, err)
+		}
+	}
+	return nil
+}
+
+// InsertEvent writes the event to the outbox table with tx, the transaction of the change the event describes.
+// The event is published by the poller once tx commits
+func (s *Store) InsertEvent(ctx context.Context, tx IExecer, event Event) error {
+	if event.Topic == "" {
+		return fmt.Errorf("outbox event %s has no topic", event.ID)
+	}
+	if event.ID == "" {
+		event.ID = uuid.NewString()
+	}
+	if event.Payload == nil {
+		event.Payload = []byte{}
+	}
+	_, err := tx.ExecContext(ctx, "INSERT INTO "+s.table+" (event_id, topic, event_key, payload, created_at) VALUES ("+
+		s.placeholders(1, 5)+")", event.ID, event.Topic, event.Key, event.Payload, time.Now().UTC())
+	if err != nil {
+		return fmt.Errorf("could not insert outbox event %s: %w", event.ID, err)
+	}
+	return nil
+}
+
+// claim locks up to limit unpublished events in tx, skipping the events locked by other pollers
+func (s *Store) claim(ctx context.Context, tx *sql.Tx, limit int) ([]Event, error) {
+	rows, err := tx.QueryContext(ctx, "SELECT id, event_id, topic, event_key, payload, created_at FROM "+s.table+
+		" WHERE published_at IS NULL ORDER BY id LIMIT "+strconv.Itoa(limit)+" FOR UPDATE SKIP LOCKED")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	events := make([]Event, 0, limit)
+	for rows.Next() {
+		var event Event
+		if err := rows.Scan(&event.sequence, &event.ID, &event.Topic, &event.Key, &event.Payload, &event.CreatedAt); err != nil {
+			return nil, err
+		}
+		events = append(events, event)
+	}
+	return events, rows.Err()
+}
+
+// pending returns the id, topic and key of the unpublished events up to the last claimed event which have the keys
+// of the claimed events, including the ones claimed by other pollers
+func (s *Store) pending(ctx context.Context, tx *sql.Tx, claimed []Event) ([]Event, error) {
+	keys := make(map[string]bool)
+	args := []interface{}{claimed[len(claimed)-1].sequence}
+	for _, event := range claimed {
+		if event.Key != "" && !keys[event.Key] {
+			keys[event.Key] = true
+			args = append(args, event.Key)
+		}
+	}
+	if len(keys) == 0 {
+		return nil, nil
+	}
+	rows, err := tx.QueryContext(ctx, "SELECT id, topic, event_key FROM "+s.table+" WHERE published_at IS NULL AND id <= "+
+		s.placeholders(1, 1)+" AND event_key IN ("+s.placeholders(2, len(keys))+") ORDER BY id", args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var events []Event
+	for rows.Next() {
+		var event Event
+		if err := rows.Scan(&event.sequence, &event.Topic, &event.Key); err != nil {
+			return nil, err
+		}
+		events = append(events, event)
+	}
+	return events, rows.Err()
+}
+
+// markPublished sets the publish time of the events in tx
+func (s *Store) markPublished(ctx context.Context, tx *sql.Tx, events []Event) error {
+	if len(events) == 0 {
+		return nil
+	}
+	args := make([]interface{}, 0, len(events)+1)
+	args = append(args, time.Now().UTC())
+	for _, event := range events {
+		args = append(args, event.sequence)
+	}
+	_, err := tx.ExecContext(ctx, "UPDATE "+s.table+" SET published_at = "+s.placeholders(1, 1)+
+		" WHERE id IN ("+s.placeholders(2, len(events))+")", args...)
+	return err
+}
+
+// DeletePublished deletes the events published before the time. Run it periodically to keep the table small
+func (s *Store) DeletePublished(ctx context.Context, before time.Time) (int64, error) {
+	result, err := s.db.ExecContext(ctx, "DELETE FROM "+s.table+" WHERE published_at IS NOT NULL AND published_at < "+
+		s.placeholders(1, 1), before.UTC())
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+// placeholders returns count comma separated placeholders, numbered from first for Postgres
+func (s *Store) placeholders(first, count int) string {
+	var b strings.Builder
+	for i := 0; i < count; i++ {
+		if i > 0 {
+			b.WriteString(", ")
+		}
+		if s.dialect == Postgres {
+			b.WriteString("$" + strconv.Itoa(first+i))
+		} else {
+			b.WriteByte('?')
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,258 @@
+package outbox
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeTable is an in memory outbox table which understands the statements of the store
+type fakeTable struct {
+	lock    sync.Mutex
+	rows    [][]driver.Value
+	queries []string
+}
+
+type fakeConnector struct{ table *fakeTable }
+
+func (c fakeConnector) Connect(context.Context) (driver.Conn, error) { return fakeConn(c), nil }
+func (c fakeConnector) Driver() driver.Driver                        { return nil }
+
+type fakeConn struct{ table *fakeTable }
+
+func (c fakeConn) Prepare(string) (driver.Stmt, error) { return nil, errors.New("not supported") }
+func (c fakeConn) Close() error                        { return nil }
+func (c fakeConn) Begin() (driver.Tx, error)           { return fakeTx{}, nil }
+
+func (c fakeConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	t := c.table
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	t.queries = append(t.queries, query)
+	switch {
+	case strings.HasPrefix(query, "INSERT"):
+		row := []driver.Value{int64(len(t.rows) + 1)}
+		for _, arg := range args {
+			row = append(row, arg.Value)
+		}
+		t.rows = append(t.rows, append(row, nil))
+	case strings.HasPrefix(query, "UPDATE"):
+		for _, arg := range args[1:] {
+			t.rows[arg.Value.(int64)-1][6] = args[0].Value
+		}
+	}
+	return driver.RowsAffected(1), nil
+}
+
+func (c fakeConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	t := c.table
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	t.queries = append(t.queries, query)
+	rows := &fakeRows{columns: []string{"id", "event_id", "topic", "event_key", "payload", "created_at"}}
+	if strings.HasPrefix(query, "SELECT id, topic, event_key") {
+		rows.columns = []string{"id", "topic", "event_key"}
+	}
+	for _, row := range t.rows {
+		if row[6] != nil {
+			continue
+		}
+		if len(rows.columns) == 3 {
+			rows.rows = append(rows.rows, []driver.Value{row[0], row[2], row[3]})
+		} else {
+			rows.rows = append(rows.rows, row[:6])
+		}
+	}
+	return rows, nil
+}
+
+type fakeTx struct{}
+
+func (fakeTx) Commit() error   { return nil }
+func (fakeTx) Rollback() error { return nil }
+
+type fakeRows struct {
+	columns []string
+	rows    [][]driver.Value
+}
+
+func (r *fakeRows) Columns() []string { return r.columns }
+func (r *fakeRows) Close() error      { return nil }
+func (r *fakeRows) Next(dest []driver.Value) error {
+	if len(r.rows) == 0 {
+		return io.EOF
+	}
+	copy(dest, r.rows[0])
+	r.rows = r.rows[1:]
+	return nil
+}
+
+func TestSchema(t *testing.T) {
+	mysql := NewStore(nil).Schema()
+	if len(mysql) != 1 || !strings.Contains(mysql[0], "CREATE TABLE IF NOT EXISTS outbox_events") || !strings.Contains(mysql[0], "AUTO_INCREMENT") {
+		t.Fatalf("unexpected mysql schema %v", mysql)
+	}
+	postgres := NewStore(nil, SetDialect(Postgres), SetTable("events.outbox")).Schema()
+	if len(postgres) != 2 || !strings.Contains(postgres[0], "BIGSERIAL") ||
+		!strings.Contains(postgres[1], "idx_events_outbox_pending ON events.outbox (id) WHERE published_at IS NULL") {
+		t.Fatalf("unexpected postgres schema %v", postgres)
+	}
+}
+
+func TestSetTableInvalid(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Fatal("expected a panic for an invalid table name")
+		}
+	}()
+	NewStore(nil, SetTable("outbox; DROP TABLE stock"))
+}
+
+func TestPlaceholders(t *testing.T) {
+	if actual := NewStore(nil).placeholders(2, 3); actual != "?, ?, ?" {
+		t.Errorf("unexpected mysql placeholders %s", actual)
+	}
+	if actual := NewStore(nil, SetDialect(Postgres)).placeholders(2, 3); actual != "$2, $3, $4" {
+		t.Errorf("unexpected postgres placeholders %s", actual)
+	}
+}
+
+func TestPoll(t *testing.T) {
+	table := &fakeTable{}
+	db := sql.OpenDB(fakeConnector{table})
+	defer db.Close()
+	store := NewStore(db)
+	ctx := context.Background()
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, key := range []string{"1", "2", "3"} {
+		if err := store.InsertEvent(ctx, tx, Event{Topic: "stock-updated", Key: key, Payload: []byte("stock " + key)}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.InsertEvent(ctx, db, Event{Key: "4"}); err == nil {
+		t.Fatal("expected an error for an event without a topic")
+	}
+
+	var lock sync.Mutex
+	var keys []string
+	publisher := KafkaPublisher(func(msg []byte, topic string, key []byte, callback func(err error)) {
+		lock.Lock()
+		keys = append(keys, string(key))
+		lock.Unlock()
+		if string(key) == "2" {
+			go callback(errors.New("broker down"))
+			return
+		}
+		go callback(nil)
+	})
+	poller := NewPoller(store, publisher, SetBatchSize(10), SetPublishTimeout(time.Second))
+
+	published, err := poller.Poll(ctx)
+	if err != nil || published != 2 {
+		t.Fatalf("expected 2 events to be published, got %d %v", published, err)
+	}
+	if strings.Join(keys, ",") != "1,2,3" {
+		t.Fatalf("expected the events to be published in order, got %v", keys)
+	}
+	keys = nil
+	published, err = poller.Poll(ctx)
+	if err != nil || published != 0 || strings.Join(keys, ",") != "2" {
+		t.Fatalf("expected only the failed event to be published again, got %d %v %v", published, keys, err)
+	}
+	if !strings.HasSuffix(table.queries[len(table.queries)-2], "FOR UPDATE SKIP LOCKED") {
+		t.Fatalf("expected the events to be claimed with SKIP LOCKED, got %s", table.queries[len(table.queries)-2])
+	}
+}
+
+func TestPollTimeout(t *testing.T) {
+	table := &fakeTable{}
+	db := sql.OpenDB(fakeConnector{table})
+	defer db.Close()
+	store := NewStore(db)
+	if err := store.InsertEvent(context.Background(), db, Event{Topic: "stock-updated"}); err != nil {
+		t.Fatal(err)
+	}
+	poller := NewPoller(store, PublisherFunc(func(Event, func(error)) {}), SetPublishTimeout(50*time.Millisecond))
+	if _, err := poller.Poll(context.Background()); err == nil {
+		t.Fatal("expected the poll to time out")
+	}
+	if table.rows[0][6] != nil {
+		t.Fatal("expected the event not to be marked as published")
+	}
+}
+
+func TestPollStopsKeyAtFailure(t *testing.T) {
+	table := &fakeTable{}
+	db := sql.OpenDB(fakeConnector{table})
+	defer db.Close()
+	store := NewStore(db)
+	ctx := context.Background()
+	for _, payload := range []string{"a", "b", "c"} {
+		if err := store.InsertEvent(ctx, db, Event{Topic: "stock-updated", Key: "1", Payload: []byte(payload)}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var lock sync.Mutex
+	var payloads []string
+	fail := true
+	publisher := KafkaPublisher(func(msg []byte, topic string, key []byte, callback func(err error)) {
+		lock.Lock()
+		defer lock.Unlock()
+		payloads = append(payloads, string(msg))
+		if string(msg) == "b" && fail {
+			fail = false
+			go callback(errors.New("broker down"))
+			return
+		}
+		go callback(nil)
+	})
+	poller := NewPoller(store, publisher, SetPublishTimeout(time.Second))
+
+	published, err := poller.Poll(ctx)
+	if err != nil || published != 1 || strings.Join(payloads, ",") != "a,b" {
+		t.Fatalf("expected the key to stop at the failed event, got %d %v %v", published, payloads, err)
+	}
+	payloads = nil
+	published, err = poller.Poll(ctx)
+	if err != nil || published != 2 || strings.Join(payloads, ",") != "b,c" {
+		t.Fatalf("expected the failed event and the next one to be published in order, got %d %v %v", published, payloads, err)
+	}
+}
+
+func TestSequencesSkipKeysClaimedByOtherPollers(t *testing.T) {
+	claimed := []Event{
+		{sequence: 2, Topic: "stock", Key: "1"},
+		{sequence: 3, Topic: "stock", Key: "2"},
+		{sequence: 4, Topic: "stock"},
+		{sequence: 5, Topic: "stock", Key: "2"},
+		{sequence: 7, Topic: "stock", Key: "2"},
+	}
+	// event 1 of key 1 and event 6 of key 2 are claimed by another poller
+	pending := []Event{
+		{sequence: 1, Topic: "stock", Key: "1"},
+		{sequence: 2, Topic: "stock", Key: "1"},
+		{sequence: 3, Topic: "stock", Key: "2"},
+		{sequence: 5, Topic: "stock", Key: "2"},
+		{sequence: 6, Topic: "stock", Key: "2"},
+		{sequence: 7, Topic: "stock", Key: "2"},
+	}
+	actual := fmt.Sprint(sequences(claimed, pending))
+	if actual != "[[1 3] [2]]" {
+		t.Errorf("sequences() = %s, want [[1 3] [2]]", actual)
+	}
+}
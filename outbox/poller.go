@@ -0,0 +1,300 @@
+package outbox
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/carwale/golibraries/gologger"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// IPublisher publishes the events claimed by the poller. PublishEvent must call done exactly once with
+// the result of the delivery; it may call it asynchronously. Events with the same topic and key are passed
+// one after the other in the order they were inserted, the next one once the previous one is delivered
+type IPublisher interface {
+	PublishEvent(event Event, done func(err error))
+}
+
+// PublisherFunc is a function implementing IPublisher
+type PublisherFunc func(event Event, done func(err error))
+
+// PublishEvent calls f
+func (f PublisherFunc) PublishEvent(event Event, done func(err error)) {
+	f(event, done)
+}
+
+// KafkaPublisher returns a publisher which publishes the events with the PublishMessageWithCallback method of
+// a kafka producer, or with any function with the same signature
+//
+//	poller := outbox.NewPoller(store, outbox.KafkaPublisher(producer.PublishMessageWithCallback))
+func KafkaPublisher(publish func(msg []byte, topic string, key []byte, callback func(err error))) IPublisher {
+	return PublisherFunc(func(event Event, done func(err error)) {
+		var key []byte
+		if event.Key != "" {
+			key = []byte(event.Key)
+		}
+		publish(event.Payload, event.Topic, key, done)
+	})
+}
+
+// PollerOption sets a parameter of the poller
+type PollerOption func(p *Poller)
+
+// SetPollInterval sets the time between polls when the outbox table has no events. Default is 1 second
+func SetPollInterval(interval time.Duration) PollerOption {
+	return func(p *Poller) {
+		if interval > 0 {
+			p.interval = interval
+		}
+	}
+}
+
+// SetBatchSize sets the number of events claimed and published in one transaction. Default is 100
+func SetBatchSize(size int) PollerOption {
+	return func(p *Poller) {
+		if size > 0 {
+			p.batchSize = size
+		}
+	}
+}
+
+// SetPublishTimeout sets how long the poller waits for the delivery of a batch. Events not delivered in time
+// are published again by a later poll. Default is 30 seconds
+func SetPublishTimeout(timeout time.Duration) PollerOption {
+	return func(p *Poller) {
+		if timeout > 0 {
+			p.publishTimeout = timeout
+		}
+	}
+}
+
+// SetPollerLogger sets the logger of the poller
+func SetPollerLogger(logger gologger.ILogger) PollerOption {
+	return func(p *Poller) { p.logger = logger }
+}
+
+// SetPollerLatencyLogger sets the latency logger used for the poller metrics
+func SetPollerLatencyLogger(latencyLogger gologger.IMultiLogger) PollerOption {
+	return func(p *Poller) { p.latencyLogger = latencyLogger }
+}
+
+const (
+	publishedMetricID = "OUTBOX-PUBLISHED"
+	lagMetricID       = "OUTBOX-LAG"
+)
+
+var pollerMetrics = gologger.NewMetricSet(func(logger gologger.ILogger) map[string]gologger.IMetricVec {
+	return map[string]gologger.IMetricVec{
+		publishedMetricID: gologger.NewCounterMetric(prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "outbox_events_published_total",
+				Help: "Number of outbox events published by status (published, failed)",
+			},
+			[]string{"Table", "Status"},
+		), logger),
+		lagMetricID: gologger.NewHistogramMetric(prometheus.NewHistogramVec(
+			prometheus.HistogramOpts{
+				Name:    "outbox_publish_lag_milliseconds",
+				Help:    "Time from the insert of an outbox event to its publication",
+				Buckets: prometheus.ExponentialBuckets(10, 2, 14),
+			},
+			[]string{"Table"},
+		), logger),
+	}
+})
+
+// Poller publishes the events of an outbox table. Several pollers can run on the same table, e.g. one in
+// every pod of the service, as every poll locks the events it publishes
+type Poller struct {
+	store          *Store
+	publisher      IPublisher
+	interval       time.Duration
+	batchSize      int
+	publishTimeout time.Duration
+	logger         gologger.ILogger
+	latencyLogger  gologger.IMultiLogger
+}
+
+// NewPoller returns a poller which publishes the events of the store with the publisher
+func NewPoller(store *Store, publisher IPublisher, options ...PollerOption) *Poller {
+	p := &Poller{
+		store:          store,
+		publisher:      publisher,
+		interval:       time.Second,
+		batchSize:      100,
+		publishTimeout: 30 * time.Second,
+	}
+	for _, option := range options {
+		option(p)
+	}
+	if p.logger == nil {
+		p.logger = gologger.NewLogger(gologger.SetLogLevel("ERROR"))
+	}
+	if p.latencyLogger == nil {
+		p.latencyLogger = gologger.NewRateLatencyLogger(gologger.SetLogger(p.logger))
+	}
+	pollerMetrics.AddTo(p.latencyLogger, p.logger)
+	return p
+}
+
+// Run publishes the events until ctx is done. Full batches are followed by the next poll right away
+func (p *Poller) Run(ctx context.Context) error {
+	for {
+		published, err := p.Poll(ctx)
+		if err != nil && ctx.Err() == nil {
+			p.logger.LogError("could not poll outbox table "+p.store.table, err)
+		}
+		if published == p.batchSize && err == nil {
+			continue
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(p.interval):
+		}
+	}
+}
+
+// Poll claims a batch of events, publishes them and marks the delivered ones as published, in one transaction.
+// It returns the number of events published
+func (p *Poller) Poll(ctx context.Context) (int, error) {
+	tx, err := p.store.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+	events, err := p.store.claim(ctx, tx, p.batchSize)
+	if err != nil || len(events) == 0 {
+		return 0, err
+	}
+
+	pending, err := p.store.pending(ctx, tx, events)
+	if err != nil {
+		return 0, err
+	}
+
+	results := make([]error, len(events))
+	delivered := make([]bool, len(events))
+	stopped := make(chan struct{})
+	defer close(stopped)
+	var wg sync.WaitGroup
+	for _, sequence := range sequences(events, pending) {
+		wg.Add(1)
+		p.publishSequence(events, sequence, results, delivered, stopped, &wg)
+	}
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+	timeout := time.NewTimer(p.publishTimeout)
+	defer timeout.Stop()
+	select {
+	case <-done:
+	case <-timeout.C:
+		return 0, fmt.Errorf("delivery of %d outbox events timed out after %s", len(events), p.publishTimeout)
+	case <-ctx.Done():
+		return 0, ctx.Err()
+	}
+
+	published := make([]Event, 0, len(events))
+	for i, event := range events {
+		if results[i] != nil {
+			p.latencyLogger.IncVal(1, publishedMetricID, p.store.table, "failed")
+			p.logger.LogErrorMessage("could not publish outbox event", results[i],
+				gologger.Pair{Key: "event_id", Value: event.ID}, gologger.Pair{Key: "topic", Value: event.Topic})
+		}
+		if delivered[i] {
+			published = append(published, event)
+		}
+	}
+	if err := p.store.markPublished(ctx, tx, published); err != nil {
+		return 0, err
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, err
+	}
+	for _, event := range published {
+		p.latencyLogger.IncVal(1, publishedMetricID, p.store.table, "published")
+		p.latencyLogger.Toc(event.CreatedAt, lagMetricID, p.store.table)
+	}
+	return len(published), nil
+}
+
+// publishSequence publishes the events of the sequence one after the other. It stops at the first failure, so the
+// later events of the sequence are published again by a later poll after the failed one, or when the poll is over
+func (p *Poller) publishSequence(events []Event, sequence []int, results []error, delivered []bool,
+	stopped <-chan struct{}, wg *sync.WaitGroup) {
+	select {
+	case <-stopped:
+		wg.Done()
+		return
+	default:
+	}
+	i := sequence[0]
+	var once sync.Once
+	p.publisher.PublishEvent(events[i], func(err error) {
+		once.Do(func() {
+			results[i] = err
+			delivered[i] = err == nil
+			if err != nil || len(sequence) == 1 {
+				wg.Done()
+				return
+			}
+			p.publishSequence(events, sequence[1:], results, delivered, stopped, wg)
+		})
+	})
+}
+
+type sequenceKey struct {
+	topic string
+	key   string
+}
+
+// sequences returns the indexes of the claimed events to publish, in sequences of events with the same topic and
+// key. Events without a key are a sequence of their own. Pending has the unpublished events of the keys: the events
+// of a key which come after an event claimed by another poller are left for a later poll
+func sequences(claimed []Event, pending []Event) [][]int {
+	isClaimed := make(map[int64]bool, len(claimed))
+	for _, event := range claimed {
+		isClaimed[event.sequence] = true
+	}
+	// the number of events of every key which come before the first event claimed by another poller
+	ready := make(map[sequenceKey]int)
+	blocked := make(map[sequenceKey]bool)
+	for _, event := range pending {
+		key := sequenceKey{event.Topic, event.Key}
+		if blocked[key] {
+			continue
+		}
+		if !isClaimed[event.sequence] {
+			blocked[key] = true
+			continue
+		}
+		ready[key]++
+	}
+
+	var result [][]int
+	positions := make(map[sequenceKey]int)
+	for i, event := range claimed {
+		if event.Key == "" {
+			result = append(result, []int{i})
+			continue
+		}
+		key := sequenceKey{event.Topic, event.Key}
+		if ready[key] == 0 {
+			continue
+		}
+		ready[key]--
+		position, ok := positions[key]
+		if !ok {
+			position = len(result)
+			positions[key] = position
+			result = append(result, nil)
+		}
+		result[position] = append(result[position], i)
+	}
+	return result
+}
@@ -0,0 +1,148 @@
+// Package payloadcrypto encrypts message payloads with AES-GCM, so that events carrying personal data are
+// not readable on the wire or at rest in the brokers. The id of the key is sent with the message, in the
+// HeaderKeyID header for kafka and rabbitmq, so that keys can be rotated: new messages are encrypted with
+// the current key while messages encrypted with older keys can still be decrypted.
+package payloadcrypto
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"errors"
+	"io"
+	"sync"
+)
+
+// HeaderKeyID is the message header holding the id of the key the payload was encrypted with
+const HeaderKeyID = "encryption-key-id"
+
+// ErrUnknownKey is returned when the key of a payload is not known to the key provider
+var ErrUnknownKey = errors.New("payloadcrypto: unknown key")
+
+// ErrInvalidPayload is returned when a payload cannot be decrypted
+var ErrInvalidPayload = errors.New("payloadcrypto: invalid payload")
+
+// IKeyProvider provides the keys. Keys must be 16, 24 or 32 bytes long for AES-128, AES-192 or AES-256
+type IKeyProvider interface {
+	// CurrentKey returns the key new payloads are encrypted with and its id
+	CurrentKey() (id string, key []byte, err error)
+	// Key returns the key with the id
+	Key(id string) ([]byte, error)
+}
+
+// Cipher encrypts and decrypts payloads with the keys of the provider. It is safe for concurrent use
+type Cipher struct {
+	provider IKeyProvider
+	lock     sync.RWMutex
+	aeads    map[string]cipher.AEAD
+}
+
+// NewCipher returns a cipher using the keys of the provider
+func NewCipher(provider IKeyProvider) *Cipher {
+	return &Cipher{provider: provider, aeads: map[string]cipher.AEAD{}}
+}
+
+// Encrypt encrypts the payload with the current key. It returns the id of the key and the nonce followed
+// by the sealed payload
+func (c *Cipher) Encrypt(payload []byte) (string, []byte, error) {
+	id, key, err := c.provider.CurrentKey()
+	if err != nil {
+		return "", nil, err
+	}
+	aead, err := c.aead(id, key)
+	if err != nil {
+		return "", nil, err
+	}
+	nonce := make([]byte, aead.NonceSize(), aead.NonceSize()+len(payload)+aead.Overhead())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return "", nil, err
+	}
+	return id, aead.Seal(nonce, nonce, payload, nil), nil
+}
+
+// Decrypt decrypts a payload encrypted with the key with the id
+func (c *Cipher) Decrypt(id string, encrypted []byte) ([]byte, error) {
+	key, err := c.provider.Key(id)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := c.aead(id, key)
+	if err != nil {
+		return nil, err
+	}
+	if len(encrypted) < aead.NonceSize() {
+		return nil, ErrInvalidPayload
+	}
+	nonce, sealed := encrypted[:aead.NonceSize()], encrypted[aead.NonceSize():]
+	payload, err := aead.Open(nil, nonce, sealed, nil)
+	if err != nil {
+		return nil, ErrInvalidPayload
+	}
+	return payload, nil
+}
+
+// aead returns the cached AES-GCM of the key
+func (c *Cipher) aead(id string, key []byte) (cipher.AEAD, error) {
+	c.lock.RLock()
+	aead, ok := c.aeads[id]
+	c.lock.RUnlock()
+	if ok {
+		return aead, nil
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	aead, err = cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	c.lock.Lock()
+	c.aeads[id] = aead
+	c.lock.Unlock()
+	return aead, nil
+}
+
+// StaticKeyProvider holds the keys in memory, e.g. loaded from a secret at startup.
+// Keys cannot be changed once added, rotate by adding a key with a new id and making it current
+type StaticKeyProvider struct {
+	lock    sync.RWMutex
+	keys    map[string][]byte
+	current string
+}
+
+// NewStaticKeyProvider returns a provider with the key as the current key
+func NewStaticKeyProvider(id string, key []byte) *StaticKeyProvider {
+	return &StaticKeyProvider{keys: map[string][]byte{id: key}, current: id}
+}
+
+// AddKey adds a key which can be used to decrypt payloads. If current is true new payloads are encrypted with it.
+// Keys should be added to all the consumers before they are made current in the producers
+func (p *StaticKeyProvider) AddKey(id string, key []byte, current bool) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	if _, ok := p.keys[id]; !ok {
+		p.keys[id] = key
+	}
+	if current {
+		p.current = id
+	}
+}
+
+// CurrentKey returns the current key
+func (p *StaticKeyProvider) CurrentKey() (string, []byte, error) {
+	p.lock.RLock()
+	defer p.lock.RUnlock()
+	return p.current, p.keys[p.current], nil
+}
+
+// Key returns the key with the id
+func (p *StaticKeyProvider) Key(id string) ([]byte, error) {
+	p.lock.RLock()
+	defer p.lock.RUnlock()
+	key, ok := p.keys[id]
+	if !ok {
+		return nil, ErrUnknownKey
+	}
+	return key, nil
+}
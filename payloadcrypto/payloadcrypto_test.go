@@ -0,0 +1,47 @@
+package payloadcrypto
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestEncryptDecryptWithRotation(t *testing.T) {
+	provider := NewStaticKeyProvider("2024-01", bytes.Repeat([]byte{1}, 32))
+	c := NewCipher(provider)
+	payload := []byte(`{"mobile":"9999999999"}`)
+
+	oldID, oldEncrypted, err := c.Encrypt(payload)
+	if err != nil {
+		t.Fatalf("Encrypt() error = %v", err)
+	}
+	if oldID != "2024-01" || bytes.Contains(oldEncrypted, payload) {
+		t.Fatalf("Encrypt() = %s %q", oldID, oldEncrypted)
+	}
+
+	provider.AddKey("2024-06", bytes.Repeat([]byte{2}, 32), true)
+	newID, newEncrypted, err := c.Encrypt(payload)
+	if err != nil || newID != "2024-06" {
+		t.Fatalf("Encrypt() after rotation = %s, %v", newID, err)
+	}
+	for id, encrypted := range map[string][]byte{oldID: oldEncrypted, newID: newEncrypted} {
+		decrypted, err := c.Decrypt(id, encrypted)
+		if err != nil || !bytes.Equal(decrypted, payload) {
+			t.Errorf("Decrypt(%s) = %q, %v", id, decrypted, err)
+		}
+	}
+}
+
+func TestDecryptErrors(t *testing.T) {
+	c := NewCipher(NewStaticKeyProvider("k1", bytes.Repeat([]byte{1}, 16)))
+	_, encrypted, _ := c.Encrypt([]byte("payload"))
+	if _, err := c.Decrypt("k2", encrypted); err != ErrUnknownKey {
+		t.Errorf("Decrypt() with unknown key error = %v", err)
+	}
+	encrypted[len(encrypted)-1] ^= 1
+	if _, err := c.Decrypt("k1", encrypted); err != ErrInvalidPayload {
+		t.Errorf("Decrypt() of tampered payload error = %v", err)
+	}
+	if _, err := c.Decrypt("k1", []byte{1}); err != ErrInvalidPayload {
+		t.Errorf("Decrypt() of short payload error = %v", err)
+	}
+}
@@ -0,0 +1,250 @@
+// Package poison detects messages that fail repeatedly (poison messages) and moves them to a quarantine store,
+// so that consumers stop retrying them. It is used by the kafka and rabbitmq consumers
+package poison
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"sync"
+	"time"
+
+	"github.com/carwale/golibraries/gologger"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// QuarantinedMessage is a message that failed too many times
+type QuarantinedMessage struct {
+	Fingerprint  string    `json:"fingerprint"`
+	Source       string    `json:"source"`
+	Payload      []byte    `json:"payload"`
+	Attempts     int       `json:"attempts"`
+	FirstFailure time.Time `json:"first_failure"`
+	LastFailure  time.Time `json:"last_failure"`
+	LastError    string    `json:"last_error,omitempty"`
+}
+
+// IQuarantineStore stores the quarantined messages
+type IQuarantineStore interface {
+	Quarantine(msg QuarantinedMessage) error
+}
+
+// Option sets a parameter for the Detector
+type Option func(d *Detector)
+
+// SetMaxAttempts sets the number of failures after which a message is quarantined. Default is 5
+func SetMaxAttempts(attempts int) Option {
+	return func(d *Detector) {
+		if attempts > 0 {
+			d.maxAttempts = attempts
+		}
+	}
+}
+
+// SetFailureWindow sets how long the failures of a message are remembered. Default is 1 hour
+func SetFailureWindow(window time.Duration) Option {
+	return func(d *Detector) {
+		if window > 0 {
+			d.window = window
+		}
+	}
+}
+
+// SetMaxTracked sets the maximum number of failing messages that are tracked. Default is 10000.
+// The oldest ones are forgotten when there are more
+func SetMaxTracked(maxTracked int) Option {
+	return func(d *Detector) {
+		if maxTracked > 0 {
+			d.maxTracked = maxTracked
+		}
+	}
+}
+
+// SetLogger sets the logger of the detector
+func SetLogger(logger gologger.ILogger) Option {
+	return func(d *Detector) { d.logger = logger }
+}
+
+// SetLatencyLogger sets the latency logger used for the metrics of the detector
+func SetLatencyLogger(latencyLogger gologger.IMultiLogger) Option {
+	return func(d *Detector) { d.latencyLogger = latencyLogger }
+}
+
+const (
+	failuresMetricID    = "POISON-FAILURES"
+	quarantinedMetricID = "POISON-QUARANTINED"
+	trackedMetricID     = "POISON-TRACKED"
+)
+
+var poisonMetrics = gologger.NewMetricSet(func(logger gologger.ILogger) map[string]gologger.IMetricVec {
+	return map[string]gologger.IMetricVec{
+		failuresMetricID: gologger.NewCounterMetric(prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "poison_message_failures_total",
+				Help: "Number of message failures recorded by the poison detector",
+			},
+			[]string{"Source"},
+		), logger),
+		quarantinedMetricID: gologger.NewCounterMetric(prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "poison_messages_quarantined_total",
+				Help: "Number of messages moved to the quarantine store",
+			},
+			[]string{"Source"},
+		), logger),
+		trackedMetricID: gologger.NewGaugeMetric(prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "poison_messages_tracked",
+				Help: "Number of failing messages tracked by the poison detector",
+			},
+			[]string{"Source"},
+		), logger),
+	}
+})
+
+type failureRecord struct {
+	attempts     int
+	firstFailure time.Time
+	lastFailure  time.Time
+	quarantined  bool
+}
+
+// Detector fingerprints the payloads of failing messages and quarantines a payload once it has failed max attempts times
+type Detector struct {
+	source        string
+	store         IQuarantineStore
+	maxAttempts   int
+	window        time.Duration
+	maxTracked    int
+	lock          sync.Mutex
+	failures      map[string]*failureRecord
+	logger        gologger.ILogger
+	latencyLogger gologger.IMultiLogger
+}
+
+// NewDetector returns a detector for the messages of source (e.g. the queue or topic name).
+// It panics if store is nil
+func NewDetector(source string, store IQuarantineStore, options ...Option) *Detector {
+	if store == nil {
+		panic("quarantine store of poison detector " + source + " is nil")
+	}
+	d := &Detector{
+		source:      source,
+		store:       store,
+		maxAttempts: 5,
+		window:      time.Hour,
+		maxTracked:  10000,
+		failures:    make(map[string]*failureRecord),
+	}
+	for _, option := range options {
+		option(d)
+	}
+	if d.logger == nil {
+		d.logger = gologger.NewLogger()
+	}
+	if d.latencyLogger == nil {
+		d.latencyLogger = gologger.NewRateLatencyLogger(gologger.SetLogger(d.logger))
+	}
+	poisonMetrics.AddTo(d.latencyLogger, d.logger)
+	return d
+}
+
+// Fingerprint returns the fingerprint of the payload
+func Fingerprint(payload []byte) string {
+	sum := sha256.Sum256(payload)
+	return hex.EncodeToString(sum[:])
+}
+
+// RecordFailure records a failure of the payload. It returns true if the payload has been quarantined,
+// in which case the consumer should drop the message instead of retrying it
+func (d *Detector) RecordFailure(payload []byte, err error) bool {
+	fingerprint := Fingerprint(payload)
+	now := time.Now()
+	d.latencyLogger.IncVal(1, failuresMetricID, d.source)
+
+	d.lock.Lock()
+	record, ok := d.failures[fingerprint]
+	if !ok || now.Sub(record.lastFailure) > d.window {
+		// lastFailure is set before pruning so that the new record is not the oldest one
+		record = &failureRecord{firstFailure: now, lastFailure: now}
+		d.failures[fingerprint] = record
+		d.prune(now)
+	}
+	record.attempts++
+	record.lastFailure = now
+	if record.quarantined {
+		d.lock.Unlock()
+		return true
+	}
+	if record.attempts < d.maxAttempts {
+		d.lock.Unlock()
+		return false
+	}
+	record.quarantined = true
+	msg := QuarantinedMessage{
+		Fingerprint:  fingerprint,
+		Source:       d.source,
+		Payload:      payload,
+		Attempts:     record.attempts,
+		FirstFailure: record.firstFailure,
+		LastFailure:  record.lastFailure,
+	}
+	d.lock.Unlock()
+
+	if err != nil {
+		msg.LastError = err.Error()
+	}
+	if storeErr := d.store.Quarantine(msg); storeErr != nil {
+		d.logger.LogErrorMessage("Could not quarantine poison message", storeErr,
+			gologger.Pair{Key: "source", Value: d.source}, gologger.Pair{Key: "fingerprint", Value: fingerprint})
+		// The message is retried so that it is not lost
+		d.lock.Lock()
+		record.quarantined = false
+		d.lock.Unlock()
+		return false
+	}
+	d.latencyLogger.IncVal(1, quarantinedMetricID, d.source)
+	d.logger.LogWarningMessage("Quarantined poison message",
+		gologger.Pair{Key: "source", Value: d.source}, gologger.Pair{Key: "fingerprint", Value: fingerprint},
+		gologger.Pair{Key: "attempts", Value: itoa(msg.Attempts)})
+	return true
+}
+
+// RecordSuccess forgets the failures of the payload
+func (d *Detector) RecordSuccess(payload []byte) {
+	fingerprint := Fingerprint(payload)
+	d.lock.Lock()
+	delete(d.failures, fingerprint)
+	d.lock.Unlock()
+}
+
+// IsQuarantined returns true if the payload has been quarantined. Consumers can use it to drop
+// redeliveries of quarantined messages without processing them
+func (d *Detector) IsQuarantined(payload []byte) bool {
+	fingerprint := Fingerprint(payload)
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	record, ok := d.failures[fingerprint]
+	return ok && record.quarantined
+}
+
+// prune removes expired records and the oldest records if too many are tracked. It is called with the lock held
+func (d *Detector) prune(now time.Time) {
+	if len(d.failures) > d.maxTracked {
+		for fingerprint, record := range d.failures {
+			if now.Sub(record.lastFailure) > d.window {
+				delete(d.failures, fingerprint)
+			}
+		}
+		for len(d.failures) > d.maxTracked {
+			var oldest string
+			var oldestTime time.Time
+			for fingerprint, record := range d.failures {
+				if oldest == "" || record.lastFailure.Before(oldestTime) {
+					oldest, oldestTime = fingerprint, record.lastFailure
+				}
+			}
+			delete(d.failures, oldest)
+		}
+	}
+	d.latencyLogger.SetVal(int64(len(d.failures)), trackedMetricID, d.source)
+}
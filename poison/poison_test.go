@@ -0,0 +1,85 @@
+package poison
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/carwale/golibraries/gologger"
+)
+
+type nopMultiLogger struct{}
+
+func (nopMultiLogger) AddNewMetric(string, gologger.IMetricVec) {}
+func (nopMultiLogger) Tic() time.Time                           { return time.Now() }
+func (nopMultiLogger) Toc(time.Time, string, ...string)         {}
+func (nopMultiLogger) IncVal(int64, string, ...string)          {}
+func (nopMultiLogger) SubVal(int64, string, ...string)          {}
+func (nopMultiLogger) SetVal(int64, string, ...string)          {}
+
+func newTestDetector(store IQuarantineStore, options ...Option) *Detector {
+	options = append(options, SetLatencyLogger(nopMultiLogger{}))
+	return NewDetector("test", store, options...)
+}
+
+func TestDetectorQuarantinesAfterMaxAttempts(t *testing.T) {
+	var quarantined []QuarantinedMessage
+	d := newTestDetector(StoreFunc(func(msg QuarantinedMessage) error {
+		quarantined = append(quarantined, msg)
+		return nil
+	}), SetMaxAttempts(3))
+	payload := []byte(`{"id":1}`)
+
+	for i := 1; i < 3; i++ {
+		if d.RecordFailure(payload, errors.New("failed")) {
+			t.Fatalf("RecordFailure() = true after %d attempts, want false", i)
+		}
+	}
+	if !d.RecordFailure(payload, errors.New("failed")) {
+		t.Fatal("RecordFailure() = false after 3 attempts, want true")
+	}
+	if !d.IsQuarantined(payload) {
+		t.Error("IsQuarantined() = false, want true")
+	}
+	if len(quarantined) != 1 || quarantined[0].Attempts != 3 || quarantined[0].LastError != "failed" {
+		t.Errorf("quarantined = %+v, want one message with 3 attempts", quarantined)
+	}
+	if quarantined[0].Fingerprint != Fingerprint(payload) {
+		t.Errorf("fingerprint = %s, want %s", quarantined[0].Fingerprint, Fingerprint(payload))
+	}
+}
+
+func TestDetectorSuccessResetsFailures(t *testing.T) {
+	d := newTestDetector(StoreFunc(func(QuarantinedMessage) error { return nil }), SetMaxAttempts(2))
+	payload := []byte("payload")
+	d.RecordFailure(payload, nil)
+	d.RecordSuccess(payload)
+	if d.RecordFailure(payload, nil) {
+		t.Error("RecordFailure() = true after a success, want false")
+	}
+}
+
+func TestDetectorStoreErrorKeepsRetrying(t *testing.T) {
+	d := newTestDetector(StoreFunc(func(QuarantinedMessage) error { return errors.New("store down") }), SetMaxAttempts(1))
+	payload := []byte("payload")
+	if d.RecordFailure(payload, nil) {
+		t.Error("RecordFailure() = true when the store failed, want false")
+	}
+	if d.IsQuarantined(payload) {
+		t.Error("IsQuarantined() = true when the store failed, want false")
+	}
+}
+
+func TestDetectorPrunesOldestRecords(t *testing.T) {
+	d := newTestDetector(StoreFunc(func(QuarantinedMessage) error { return nil }), SetMaxTracked(2), SetMaxAttempts(10))
+	for _, payload := range []string{"a", "b", "c"} {
+		d.RecordFailure([]byte(payload), nil)
+		time.Sleep(time.Millisecond)
+	}
+	if len(d.failures) != 2 {
+		t.Fatalf("tracked %d messages, want 2", len(d.failures))
+	}
+	if _, ok := d.failures[Fingerprint([]byte("a"))]; ok {
+		t.Error("oldest message was not pruned")
+	}
+}
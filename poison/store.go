@@ -0,0 +1,50 @@
+package poison
+
+import (
+	"encoding/json"
+	"os"
+	"strconv"
+	"sync"
+)
+
+// StoreFunc is an adapter to use a function as an IQuarantineStore
+type StoreFunc func(msg QuarantinedMessage) error
+
+// Quarantine calls f(msg)
+func (f StoreFunc) Quarantine(msg QuarantinedMessage) error {
+	return f(msg)
+}
+
+// FileStore appends the quarantined messages to a file as JSON lines
+type FileStore struct {
+	path string
+	lock sync.Mutex
+}
+
+// NewFileStore returns a store which appends to the file at path. The file is created if it does not exist
+func NewFileStore(path string) *FileStore {
+	return &FileStore{path: path}
+}
+
+// Quarantine appends the message to the file
+func (s *FileStore) Quarantine(msg QuarantinedMessage) error {
+	line, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	f, err := os.OpenFile(s.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	if _, err = f.Write(append(line, '\n')); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+func itoa(i int) string {
+	return strconv.Itoa(i)
+}
@@ -0,0 +1,125 @@
+package rabbitmq
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/streadway/amqp"
+)
+
+// AckMode decides how the consumer acknowledges processed messages
+type AckMode int
+
+const (
+	// AckPerMessage acks every message as soon as it is processed. This is the default
+	AckPerMessage AckMode = iota
+	// AckBatched acks processed messages with a single multiple ack every batch size messages or every interval,
+	// whichever comes first. Messages that are not acked when the channel is closed are redelivered by the broker,
+	// so processors must be idempotent
+	AckBatched
+	// AckManual passes an AckHandle to the processor which is responsible for acking the message.
+	// The processor must implement IManualAckProcessor
+	AckManual
+)
+
+const (
+	defaultAckBatchSize     = 50
+	defaultAckBatchInterval = time.Second
+)
+
+// IManualAckProcessor is implemented by processors that ack messages themselves. It is used with AckManual.
+// The message is redelivered only if the handle is nacked with requeue or the channel is closed before it is settled
+type IManualAckProcessor interface {
+	ProcessMessageWithAck(ctx context.Context, data map[string]interface{}, handle *AckHandle)
+}
+
+// SetAckMode sets how the consumer acknowledges messages. Defaults to AckPerMessage
+func SetAckMode(mode AckMode) Option {
+	return func(om *OperationManager) { om.ackMode = mode }
+}
+
+// SetAckBatch sets the batch size and the flush interval used by AckBatched.
+// Defaults to 50 messages and 1 second. The prefetch count of the consumer is raised to the batch size
+func SetAckBatch(size int, interval time.Duration) Option {
+	return func(om *OperationManager) {
+		if size > 0 {
+			om.ackBatchSize = size
+		}
+		if interval > 0 {
+			om.ackBatchInterval = interval
+		}
+	}
+}
+
+// AckHandle settles a single delivery. Only the first call to Ack, Nack or Reject has an effect
+type AckHandle struct {
+	delivery amqp.Delivery
+	once     sync.Once
+}
+
+// DeliveryTag returns the delivery tag of the message on the channel
+func (h *AckHandle) DeliveryTag() uint64 {
+	return h.delivery.DeliveryTag
+}
+
+// Redelivered returns true if the message was delivered before and was not acked
+func (h *AckHandle) Redelivered() bool {
+	return h.delivery.Redelivered
+}
+
+// Ack acknowledges the message
+func (h *AckHandle) Ack() (err error) {
+	h.once.Do(func() { err = h.delivery.Ack(false) })
+	return
+}
+
+// Nack negatively acknowledges the message. If requeue is false the message is dead lettered or dropped by the broker
+func (h *AckHandle) Nack(requeue bool) (err error) {
+	h.once.Do(func() { err = h.delivery.Nack(false, requeue) })
+	return
+}
+
+// Reject rejects the message. If requeue is false the message is dead lettered or dropped by the broker
+func (h *AckHandle) Reject(requeue bool) (err error) {
+	h.once.Do(func() { err = h.delivery.Reject(requeue) })
+	return
+}
+
+// batchAcker acks delivery tags of a channel in batches using multiple acks. Every delivery of the channel must
+// be added or nacked through the acker, so that a multiple ack never covers a delivery which was not processed
+type batchAcker struct {
+	ch      amqp.Acknowledger
+	size    int
+	pending int
+	lastTag uint64
+}
+
+// add marks the tag as processed and flushes if the batch is full
+func (b *batchAcker) add(tag uint64) error {
+	b.pending++
+	if tag > b.lastTag {
+		b.lastTag = tag
+	}
+	if b.pending >= b.size {
+		return b.flush()
+	}
+	return nil
+}
+
+// flush acks all the delivery tags up to the last processed tag
+func (b *batchAcker) flush() error {
+	if b.pending == 0 {
+		return nil
+	}
+	b.pending = 0
+	return b.ch.Ack(b.lastTag, true)
+}
+
+// nack acks the processed tags and then nacks the tag alone
+func (b *batchAcker) nack(tag uint64, requeue bool) error {
+	if err := b.flush(); err != nil {
+		return err
+	}
+	return b.ch.Nack(tag, false, requeue)
+}
@@ -0,0 +1,66 @@
+package rabbitmq
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+
+	"github.com/streadway/amqp"
+)
+
+// recordingAcknowledger records the acks and nacks of a channel
+type recordingAcknowledger struct {
+	calls []string
+}
+
+func (r *recordingAcknowledger) Ack(tag uint64, multiple bool) error {
+	r.calls = append(r.calls, fmt.Sprintf("ack %d %v", tag, multiple))
+	return nil
+}
+
+func (r *recordingAcknowledger) Nack(tag uint64, multiple bool, requeue bool) error {
+	r.calls = append(r.calls, fmt.Sprintf("nack %d %v", tag, multiple))
+	return nil
+}
+
+func (r *recordingAcknowledger) Reject(tag uint64, requeue bool) error {
+	r.calls = append(r.calls, fmt.Sprintf("reject %d", tag))
+	return nil
+}
+
+func TestBatchAckerTracksLastTag(t *testing.T) {
+	acker := &batchAcker{size: 10}
+	for _, tag := range []uint64{1, 3, 2} {
+		if err := acker.add(tag); err != nil {
+			t.Fatalf("add() error = %v", err)
+		}
+	}
+	if acker.pending != 3 || acker.lastTag != 3 {
+		t.Errorf("acker = %+v, want 3 pending up to tag 3", acker)
+	}
+}
+
+func TestBatchAckerNackFlushesProcessedTags(t *testing.T) {
+	ch := &recordingAcknowledger{}
+	acker := &batchAcker{ch: ch, size: 10}
+	acker.add(1)
+	acker.add(2)
+	acker.nack(3, false)
+	acker.add(4)
+	acker.flush()
+	want := []string{"ack 2 true", "nack 3 false", "ack 4 true"}
+	if !reflect.DeepEqual(ch.calls, want) {
+		t.Errorf("calls = %v, want %v", ch.calls, want)
+	}
+}
+
+func TestAckHandleSettlesOnce(t *testing.T) {
+	ch := &recordingAcknowledger{}
+	h := &AckHandle{delivery: amqp.Delivery{Acknowledger: ch, DeliveryTag: 7}}
+	h.Reject(false)
+	h.Ack()
+	h.Nack(true)
+	if want := []string{"reject 7"}; !reflect.DeepEqual(ch.calls, want) {
+		t.Errorf("calls = %v, want %v", ch.calls, want)
+	}
+}
@@ -1,14 +1,15 @@
 package channelprovider
 
 import (
+	"context"
 	"fmt"
 	"sync"
-	"time"
 
 	"github.com/carwale/golibraries/gologger"
 
 	"github.com/carwale/golibraries/rabbitmq/connection"
 	"github.com/carwale/golibraries/rabbitmq/connectionpool"
+	"github.com/carwale/golibraries/reconnect"
 	"github.com/spf13/viper"
 	"github.com/streadway/amqp"
 )
@@ -18,22 +19,29 @@ var channelPro *ChannelProvider
 
 // ChannelProvider is container for logger and connection pool, has method to get channel.
 type ChannelProvider struct {
-	uclogger *gologger.CustomLogger
+	uclogger gologger.ILogger
 	pool     *connectionpool.Pool
 }
 
 //NewChannelProvider gives you a new channel provider. It takes the list of servers from "rabbitmq" in config
-func NewChannelProvider(logger *gologger.CustomLogger, username string, password string) *ChannelProvider {
+func NewChannelProvider(logger gologger.ILogger, username string, password string) *ChannelProvider {
 	return NewChannelProviderWithServers(logger, viper.GetStringSlice("rabbitmq"), username, password)
 }
 
 //NewChannelProviderWithServers gives you a new channel provider. You have to pass a list of rabbitmq servers.
-func NewChannelProviderWithServers(logger *gologger.CustomLogger, rabbitMqServers []string, username string, password string) *ChannelProvider {
+func NewChannelProviderWithServers(logger gologger.ILogger, rabbitMqServers []string, username string, password string) *ChannelProvider {
+	return NewChannelProviderWithConfig(logger, rabbitMqServers, username, password, nil)
+}
+
+// NewChannelProviderWithConfig gives you a new channel provider which connects to the servers using the connection config.
+// Use it to connect with amqps, a non default port or vhost, or to change the heartbeat.
+// The channel provider is a singleton, so only the config passed while creating it the first time is used
+func NewChannelProviderWithConfig(logger gologger.ILogger, rabbitMqServers []string, username string, password string, config *connection.ConnectionConfig) *ChannelProvider {
 
 	once.Do(func() {
 		serverList := rabbitMqServers
 		channelPro = &ChannelProvider{
-			pool:     connectionpool.NewConnectionPool(&serverList, username, password, &connection.Provider{}, logger),
+			pool:     connectionpool.NewConnectionPool(&serverList, username, password, &connection.Provider{Config: config}, logger),
 			uclogger: logger,
 		}
 
@@ -41,39 +49,44 @@ func NewChannelProviderWithServers(logger *gologger.CustomLogger, rabbitMqServer
 	return channelPro
 }
 
-// GetChannel creates and returns a channel
+// GetChannel creates and returns a channel. Failures are retried with connection.DefaultReconnectPolicy
 func (cp *ChannelProvider) GetChannel() (*amqp.Channel, error) {
 
 	if cp.pool == nil {
 		return nil, fmt.Errorf("connection pool is not initialised")
 	}
 
-	connectDelay := 1 // 1 second
-
-	maxDelay := 1800 // 30 minutes
-
-	for {
-
-		connection, err := cp.pool.GetConnection()
-
+	var channel *amqp.Channel
+	manager := reconnect.NewManager("rabbitmq channel", reconnect.SetLogger(cp.uclogger), reconnect.SetPolicy(connection.DefaultReconnectPolicy))
+	err := manager.Do(context.Background(), func(ctx context.Context) error {
+		conn, err := cp.pool.GetConnection()
 		if err != nil {
-			cp.uclogger.LogError("Error getting connection from pool", err)
-			continue
+			return fmt.Errorf("error getting connection from pool: %w", err)
 		}
-
-		channel, err := connection.Channel()
-
+		channel, err = conn.Channel()
 		if err != nil {
-			cp.uclogger.LogError("error creating channel", err)
-
-			if connectDelay < maxDelay {
-				connectDelay *= 2
-			} else {
-				return nil, fmt.Errorf("max delay reached while trying to get channel")
-			}
-			time.Sleep(time.Duration(connectDelay) * time.Second)
-		} else {
-			return channel, nil
+			return fmt.Errorf("error creating channel: %w", err)
 		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("could not get channel: %w", err)
+	}
+	return channel, nil
+}
+
+// IsBlocked returns true if any connection used by the channel provider is blocked by the broker.
+// Publishing on a blocked connection will hang till the broker unblocks it
+func (cp *ChannelProvider) IsBlocked() bool {
+	if cp.pool == nil {
+		return false
+	}
+	return cp.pool.IsBlocked()
+}
+
+// AddBlockedListener adds a listener that is called whenever a connection is blocked or unblocked by the broker
+func (cp *ChannelProvider) AddBlockedListener(listener func(server string, blocked bool, reason string)) {
+	if cp.pool != nil {
+		cp.pool.AddBlockedListener(listener)
 	}
 }
@@ -0,0 +1,145 @@
+package channelprovider
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/carwale/golibraries/gologger"
+	"github.com/carwale/golibraries/goutilities"
+	"github.com/streadway/amqp"
+)
+
+// ConfirmedChannel is a channel in publisher confirm mode. Confirmations from the broker are
+// multiplexed to the callers that published the messages, so it can be shared by multiple goroutines.
+// Messages published with mandatory set that cannot be routed to a queue are passed to the return handler
+type ConfirmedChannel struct {
+	channel       *amqp.Channel
+	logger        gologger.ILogger
+	returnHandler func(amqp.Return)
+	publishLock   sync.Mutex
+	pendingLock   sync.Mutex
+	nextTag       uint64
+	pending       map[uint64]chan amqp.Confirmation
+	closed        bool
+}
+
+// GetConfirmedChannel creates a new channel and puts it in confirm mode.
+// returnHandler is called for every unroutable message published with mandatory set. If it is nil
+// the returned messages are logged as errors
+func (cp *ChannelProvider) GetConfirmedChannel(returnHandler func(amqp.Return)) (*ConfirmedChannel, error) {
+	channel, err := cp.GetChannel()
+	if err != nil {
+		return nil, err
+	}
+	if err = channel.Confirm(false); err != nil {
+		channel.Close()
+		return nil, fmt.Errorf("could not put channel in confirm mode: %w", err)
+	}
+	cc := &ConfirmedChannel{
+		channel:       channel,
+		logger:        cp.uclogger,
+		returnHandler: returnHandler,
+		pending:       make(map[uint64]chan amqp.Confirmation),
+	}
+	if cc.returnHandler == nil {
+		cc.returnHandler = cc.logReturn
+	}
+	confirms := channel.NotifyPublish(make(chan amqp.Confirmation, 100))
+	returns := channel.NotifyReturn(make(chan amqp.Return, 10))
+	goutilities.Go("rabbitmq-confirms", func() { cc.dispatchConfirms(confirms) })
+	goutilities.Go("rabbitmq-returns", func() { cc.dispatchReturns(returns) })
+	return cc, nil
+}
+
+// Channel returns the underlying channel. Publishing directly on it will break the confirm tracking
+func (cc *ConfirmedChannel) Channel() *amqp.Channel {
+	return cc.channel
+}
+
+// PublishAsync publishes the message and returns a channel which receives the confirmation from the broker.
+// If the channel is closed before the confirmation is received, a negative confirmation is sent
+func (cc *ConfirmedChannel) PublishAsync(exchange, routingKey string, mandatory bool, msg amqp.Publishing) (<-chan amqp.Confirmation, error) {
+	confirmation := make(chan amqp.Confirmation, 1)
+
+	// Publishes are serialised so that the delivery tag assigned by the broker is known
+	cc.publishLock.Lock()
+	defer cc.publishLock.Unlock()
+
+	cc.pendingLock.Lock()
+	if cc.closed {
+		cc.pendingLock.Unlock()
+		return nil, amqp.ErrClosed
+	}
+	tag := cc.nextTag + 1
+	cc.pending[tag] = confirmation
+	cc.pendingLock.Unlock()
+
+	if err := cc.channel.Publish(exchange, routingKey, mandatory, false, msg); err != nil {
+		cc.pendingLock.Lock()
+		delete(cc.pending, tag)
+		cc.pendingLock.Unlock()
+		return nil, err
+	}
+	cc.nextTag = tag
+	return confirmation, nil
+}
+
+// Publish publishes the message and waits for the confirmation from the broker.
+// It returns an error if the broker nacks the message or the context is done before the confirmation
+func (cc *ConfirmedChannel) Publish(ctx context.Context, exchange, routingKey string, mandatory bool, msg amqp.Publishing) error {
+	confirmation, err := cc.PublishAsync(exchange, routingKey, mandatory, msg)
+	if err != nil {
+		return err
+	}
+	select {
+	case c := <-confirmation:
+		if !c.Ack {
+			return fmt.Errorf("message with delivery tag %d was nacked by the broker", c.DeliveryTag)
+		}
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Close closes the underlying channel. Pending confirmations are nacked
+func (cc *ConfirmedChannel) Close() error {
+	return cc.channel.Close()
+}
+
+func (cc *ConfirmedChannel) dispatchConfirms(confirms chan amqp.Confirmation) {
+	for c := range confirms {
+		cc.pendingLock.Lock()
+		confirmation, ok := cc.pending[c.DeliveryTag]
+		delete(cc.pending, c.DeliveryTag)
+		cc.pendingLock.Unlock()
+		if ok {
+			confirmation <- c
+		} else {
+			cc.logger.LogWarningf("Received confirmation for unknown delivery tag %d", c.DeliveryTag)
+		}
+	}
+	// The confirms channel is closed when the channel is closed
+	cc.pendingLock.Lock()
+	cc.closed = true
+	for tag, confirmation := range cc.pending {
+		confirmation <- amqp.Confirmation{DeliveryTag: tag, Ack: false}
+		delete(cc.pending, tag)
+	}
+	cc.pendingLock.Unlock()
+}
+
+func (cc *ConfirmedChannel) dispatchReturns(returns chan amqp.Return) {
+	for r := range returns {
+		cc.returnHandler(r)
+	}
+}
+
+func (cc *ConfirmedChannel) logReturn(r amqp.Return) {
+	cc.logger.LogErrorMessage("Message returned by the broker as it could not be routed", nil,
+		gologger.Pair{Key: "exchange", Value: r.Exchange},
+		gologger.Pair{Key: "routing_key", Value: r.RoutingKey},
+		gologger.Pair{Key: "reply_code", Value: fmt.Sprintf("%d", r.ReplyCode)},
+		gologger.Pair{Key: "reply_text", Value: r.ReplyText})
+}
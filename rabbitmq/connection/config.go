@@ -0,0 +1,115 @@
+package connection
+
+import (
+	"crypto/tls"
+	"net"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/streadway/amqp"
+)
+
+// ConnectionConfig holds the settings used to connect to rabbitmq.
+// Zero values are replaced by the defaults of DefaultConnectionConfig
+type ConnectionConfig struct {
+	// Scheme is either amqp or amqps. Defaults to amqp
+	Scheme string
+	// Port is used for servers that do not have a port. Defaults to 5672 for amqp and 5671 for amqps
+	Port int
+	// VHost is the virtual host to connect to. Defaults to "/"
+	VHost string
+	// Heartbeat is the interval of heartbeats sent to the broker. Defaults to 10 seconds
+	Heartbeat time.Duration
+	// ChannelMax is the maximum number of channels per connection. 0 means the limit of the broker
+	ChannelMax int
+	// ConnectionTimeout is the timeout for establishing the tcp connection and the amqp handshake. Defaults to 30 seconds
+	ConnectionTimeout time.Duration
+	// Locale used in the connection handshake. Defaults to en_US
+	Locale string
+	// TLSConfig is used for amqps connections. If it is nil the system root certificates are used
+	TLSConfig *tls.Config
+}
+
+// DefaultConnectionConfig returns the config that is used when no config is given
+func DefaultConnectionConfig() *ConnectionConfig {
+	return &ConnectionConfig{
+		Scheme:            "amqp",
+		VHost:             "/",
+		Heartbeat:         10 * time.Second,
+		ConnectionTimeout: 30 * time.Second,
+		Locale:            "en_US",
+	}
+}
+
+// withDefaults returns a copy of the config with zero values replaced by defaults
+func (c *ConnectionConfig) withDefaults() *ConnectionConfig {
+	config := DefaultConnectionConfig()
+	if c == nil {
+		c = config
+	}
+	merged := *c
+	if merged.Scheme == "" {
+		merged.Scheme = config.Scheme
+	}
+	if merged.VHost == "" {
+		merged.VHost = config.VHost
+	}
+	if merged.Heartbeat <= 0 {
+		merged.Heartbeat = config.Heartbeat
+	}
+	if merged.ConnectionTimeout <= 0 {
+		merged.ConnectionTimeout = config.ConnectionTimeout
+	}
+	if merged.Locale == "" {
+		merged.Locale = config.Locale
+	}
+	if merged.Port == 0 {
+		merged.Port = 5672
+		if merged.Scheme == "amqps" {
+			merged.Port = 5671
+		}
+	}
+	return &merged
+}
+
+// buildURI returns the amqp uri for the server. The port of the config is used if the server does not have one
+func (c *ConnectionConfig) buildURI(server string, username string, password string) string {
+	host := server
+	if _, _, err := net.SplitHostPort(server); err != nil {
+		host = net.JoinHostPort(server, strconv.Itoa(c.Port))
+	}
+	uri := url.URL{
+		Scheme: c.Scheme,
+		User:   url.UserPassword(username, password),
+		Host:   host,
+	}
+	if c.VHost != "/" {
+		uri.Path = "/" + c.VHost
+		uri.RawPath = "/" + url.PathEscape(c.VHost)
+	}
+	return uri.String()
+}
+
+// amqpConfig returns the config used to dial the broker
+func (c *ConnectionConfig) amqpConfig() amqp.Config {
+	timeout := c.ConnectionTimeout
+	return amqp.Config{
+		Heartbeat:       c.Heartbeat,
+		ChannelMax:      c.ChannelMax,
+		Locale:          c.Locale,
+		TLSClientConfig: c.TLSConfig,
+		Dial: func(network, addr string) (net.Conn, error) {
+			conn, err := net.DialTimeout(network, addr, timeout)
+			if err != nil {
+				return nil, err
+			}
+			// The deadline covers the amqp handshake. It is cleared by the library once the connection is open
+			if err := conn.SetDeadline(time.Now().Add(timeout)); err != nil {
+				conn.Close()
+				return nil, err
+			}
+			return conn, nil
+		},
+	}
+}
@@ -1,52 +1,61 @@
 package connection
 
 import (
+	"context"
 	"fmt"
 	"time"
 
 	"github.com/carwale/golibraries/gologger"
+	"github.com/carwale/golibraries/reconnect"
 
 	"github.com/streadway/amqp"
 )
 
-// Provider is a placeholder struct for implementing the IConnectionProvider interface.
+// Provider implements the IConnectionProvider interface.
+// Config is used for all the connections. If it is nil DefaultConnectionConfig is used
 type Provider struct {
+	Config *ConnectionConfig
+	// ReconnectPolicy is the backoff used to retry a failed connection. If it is nil DefaultReconnectPolicy is used
+	ReconnectPolicy *reconnect.Policy
+	// ReconnectListener, if set, receives the events of the connection attempts
+	ReconnectListener func(reconnect.Event)
 }
 
-var uclogger *gologger.CustomLogger
+// DefaultReconnectPolicy retries a connection for up to 30 minutes, waiting from 2 seconds up to 5 minutes between attempts
+var DefaultReconnectPolicy = reconnect.Policy{InitialDelay: 2 * time.Second, MaxDelay: 5 * time.Minute, Multiplier: 2, Jitter: 0.2, MaxElapsed: 30 * time.Minute}
 
-// NewConnection provides a new rabbitmq connection, retries for up to 30 minutes in case of failure
+var uclogger gologger.ILogger
+
+// NewConnection provides a new rabbitmq connection, retries with the reconnect policy (up to 30 minutes by default) in case of failure
 func (provider *Provider) NewConnection(server string, username string, password string, logger *gologger.CustomLogger) (*amqp.Connection, error) {
-	var connection *amqp.Connection
+	return provider.NewConnectionWithLogger(server, username, password, logger)
+}
 
-	var err error
+// NewConnectionWithLogger is NewConnection with any gologger.ILogger
+func (provider *Provider) NewConnectionWithLogger(server string, username string, password string, logger gologger.ILogger) (*amqp.Connection, error) {
+	var connection *amqp.Connection
 
 	uclogger = logger
 
-	connectDelay := 1 // 1 second
-
-	maxDelay := 1800 //30 minutes
-
-	// uri := "amqp://guest:guest@" + server
-	uri := fmt.Sprintf("amqp://%s:%s@%s", username, password, server)
-	for {
-		connection, err = amqp.Dial(uri)
-
-		if err != nil {
-			uclogger.LogError("error while connecting to rabbitmq", err)
-
-			if connectDelay < maxDelay {
-				connectDelay *= 2
-			} else {
-				return nil, fmt.Errorf("max delay reached while trying to establish rabbitmq connection to %s", server)
-			}
-
-			time.Sleep(time.Duration(connectDelay) * time.Second)
-		} else {
-			uclogger.LogDebug("connection established successfully")
-
-			return connection, nil
-		}
+	policy := DefaultReconnectPolicy
+	if provider.ReconnectPolicy != nil {
+		policy = *provider.ReconnectPolicy
+	}
+	options := []reconnect.Option{reconnect.SetLogger(uclogger), reconnect.SetPolicy(policy)}
+	if provider.ReconnectListener != nil {
+		options = append(options, reconnect.AddListener(provider.ReconnectListener))
 	}
 
+	config := provider.Config.withDefaults()
+	uri := config.buildURI(server, username, password)
+	err := reconnect.NewManager("rabbitmq "+server, options...).Do(context.Background(), func(ctx context.Context) error {
+		var err error
+		connection, err = amqp.DialConfig(uri, config.amqpConfig())
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("could not establish rabbitmq connection to %s: %w", server, err)
+	}
+	uclogger.LogDebug("connection established successfully")
+	return connection, nil
 }
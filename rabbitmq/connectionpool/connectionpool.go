@@ -1,10 +1,14 @@
 package connectionpool
 
 import (
+	"context"
 	"fmt"
+	"sync"
 	"time"
 
 	"github.com/carwale/golibraries/gologger"
+	"github.com/carwale/golibraries/goutilities"
+	"github.com/prometheus/client_golang/prometheus"
 
 	"github.com/streadway/amqp"
 )
@@ -17,23 +21,92 @@ type Pool struct {
 	addConnection      chan *Container
 	removeConnection   chan *Container
 	connectionProvider IConnectionProvider
+	blockedLock        sync.RWMutex
+	blockedServers     map[string]string
+	blockedListeners   []func(server string, blocked bool, reason string)
+	health             *serverHealth
+	latencyLogger      gologger.IMultiLogger
+	// customLogger is passed to the providers which do not implement ILoggerConnectionProvider
+	customLogger *gologger.CustomLogger
 }
 
+// Option sets a parameter for the connection pool
+type Option func(pool *Pool)
+
+// SetLatencyLogger sets the latency logger used for the pool metrics.
+// Defaults to the rate latency logger
+func SetLatencyLogger(latencyLogger gologger.IMultiLogger) Option {
+	return func(pool *Pool) { pool.latencyLogger = latencyLogger }
+}
+
+// SetFailureThreshold sets the number of connection failures of a server within the failure window
+// after which the server is deprioritised. Defaults to 3 failures in 5 minutes
+func SetFailureThreshold(failures int, window time.Duration) Option {
+	return func(pool *Pool) {
+		if failures > 0 && window > 0 {
+			pool.health.threshold = failures
+			pool.health.window = window
+		}
+	}
+}
+
+const (
+	openConnectionsMetricID    = "RABBITMQ-POOL-OPEN-CONNECTIONS"
+	acquisitionLatencyMetricID = "RABBITMQ-POOL-ACQUISITION-LATENCY"
+	serverFailuresMetricID     = "RABBITMQ-POOL-SERVER-FAILURES"
+)
+
+var poolMetrics = gologger.NewMetricSet(func(logger gologger.ILogger) map[string]gologger.IMetricVec {
+	return map[string]gologger.IMetricVec{
+		openConnectionsMetricID: gologger.NewGaugeMetric(prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "rabbitmq_pool_open_connections",
+				Help: "Number of open connections in the rabbitmq connection pool",
+			},
+			[]string{},
+		), logger),
+		acquisitionLatencyMetricID: gologger.NewHistogramMetric(prometheus.NewHistogramVec(
+			prometheus.HistogramOpts{
+				Name: "rabbitmq_pool_connection_acquisition_milliseconds",
+				Help: "Time taken to get a connection from the rabbitmq connection pool",
+			},
+			[]string{},
+		), logger),
+		serverFailuresMetricID: gologger.NewCounterMetric(prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "rabbitmq_pool_server_failures",
+				Help: "Number of connection failures for a rabbitmq server",
+			},
+			[]string{"Server"},
+		), logger),
+	}
+})
+
 // IConnectionProvider defines the interface to be implemented by a connection provider.
 type IConnectionProvider interface {
 	NewConnection(string, string, string, *gologger.CustomLogger) (*amqp.Connection, error)
 }
 
+// ILoggerConnectionProvider is implemented by the connection providers which take any gologger.ILogger, like
+// connection.Provider. The pool calls NewConnectionWithLogger instead of NewConnection when the provider
+// implements it. With other providers the logger of the pool is passed to NewConnection if it is a
+// *gologger.CustomLogger, and a logger at ERROR level otherwise
+type ILoggerConnectionProvider interface {
+	NewConnectionWithLogger(string, string, string, gologger.ILogger) (*amqp.Connection, error)
+}
+
 // Container contains connection and related info
 type Container struct {
 	connection *amqp.Connection
 	serverInfo string
 }
 
-var uclogger *gologger.CustomLogger
+var uclogger gologger.ILogger
 
 // NewConnectionPool returns new connection pool, waits for 3 seconds before returning
-func NewConnectionPool(serverList *[]string, username string, password string,  connectionProvider IConnectionProvider, logger *gologger.CustomLogger) *Pool {
+// Connections are handed out round robin. Servers whose connections failed repeatedly in the recent past
+// are only used when there is no connection to a healthy server
+func NewConnectionPool(serverList *[]string, username string, password string, connectionProvider IConnectionProvider, logger gologger.ILogger, options ...Option) *Pool {
 	pool := &Pool{
 		connections:        make(map[string]*Container),
 		serverList:         *serverList,
@@ -41,35 +114,49 @@ func NewConnectionPool(serverList *[]string, username string, password string,
 		addConnection:      make(chan *Container),
 		removeConnection:   make(chan *Container),
 		connectionProvider: connectionProvider,
+		blockedServers:     make(map[string]string),
+		health:             newServerHealth(3, 5*time.Minute),
 	}
 
 	uclogger = logger
+	if _, ok := connectionProvider.(ILoggerConnectionProvider); !ok {
+		if pool.customLogger, ok = logger.(*gologger.CustomLogger); !ok {
+			pool.customLogger = gologger.NewLogger(gologger.SetLogLevel("ERROR"))
+		}
+	}
+	for _, option := range options {
+		option(pool)
+	}
+	if pool.latencyLogger == nil {
+		pool.latencyLogger = gologger.NewRateLatencyLogger(gologger.SetLogger(logger))
+	}
+	poolMetrics.AddTo(pool.latencyLogger, logger)
 	for _, server := range *serverList {
-		go pool.addNewConnection(server, username, password)
+		server := server
+		goutilities.Go("rabbitmq-pool-connect", func() { pool.addNewConnection(server, username, password) })
 	}
 
-	go func() {
+	goutilities.Go("rabbitmq-pool", func() {
 		nextNodeIndex := 0
 		for {
 			var sendConnection chan *Container
 			var nextConnection *Container
 			if len(pool.connections) > 0 {
 				sendConnection = pool.getConnection
-				for nextConnection == nil {
-					nextNodeIndex = (nextNodeIndex + 1) % len(*serverList)
-					nextConnection = pool.connections[(*serverList)[nextNodeIndex]]
-				}
+				nextNodeIndex, nextConnection = pool.nextContainer(nextNodeIndex)
 			}
 
 			select {
 			case container := <-pool.addConnection:
 				pool.connections[container.serverInfo] = container
+				pool.latencyLogger.SetVal(int64(len(pool.connections)), openConnectionsMetricID)
 			case container := <-pool.removeConnection:
 				delete(pool.connections, container.serverInfo)
+				pool.latencyLogger.SetVal(int64(len(pool.connections)), openConnectionsMetricID)
 			case sendConnection <- nextConnection:
 			}
 		}
-	}()
+	})
 
 	return pool
 }
@@ -77,15 +164,20 @@ func NewConnectionPool(serverList *[]string, username string, password string,
 // addNewConnection manages establishing new connection and adding it to pool,
 // also listens for connection errors and retries connecting.
 func (pool *Pool) addNewConnection(server string, username string, password string) {
-	conn, err := pool.connectionProvider.NewConnection(server, username, password, uclogger)
+	conn, err := pool.newConnection(server, username, password)
 	if err != nil {
+		pool.recordFailure(server)
 		uclogger.LogError("could not establish rabbitmq connection", err)
-		go pool.addNewConnection(server, username, password) // retry establishing connection
+		// retry establishing connection
+		goutilities.Go("rabbitmq-pool-connect", func() { pool.addNewConnection(server, username, password) })
 		return
 	}
 
 	errorChannel := make(chan *amqp.Error)
 	conn.NotifyClose(errorChannel)
+	blockingChannel := make(chan amqp.Blocking, 1)
+	conn.NotifyBlocked(blockingChannel)
+	goutilities.Go("rabbitmq-pool-blocked", func() { pool.watchBlocked(server, blockingChannel) })
 
 	container := &Container{
 		connection: conn,
@@ -94,25 +186,172 @@ func (pool *Pool) addNewConnection(server string, username string, password stri
 
 	pool.addConnection <- container // send container to be added to pool
 
-	go func() {
+	goutilities.Go("rabbitmq-pool-close", func() {
 		conerr := <-errorChannel
 
 		if conerr != nil {
+			pool.recordFailure(server)
 			pool.removeConnection <- container // send container to be removed from pool
 			uclogger.LogErrorWithoutError(fmt.Sprintf("Error in rabbitmq connection Code: %d Reason: %q, Server: %s", conerr.Code, conerr.Reason, server))
 			pool.addNewConnection(server, username, password)
 		}
-	}()
+	})
+}
+
+// newConnection opens a connection with the provider, passing the logger of the pool
+func (pool *Pool) newConnection(server string, username string, password string) (*amqp.Connection, error) {
+	if provider, ok := pool.connectionProvider.(ILoggerConnectionProvider); ok {
+		return provider.NewConnectionWithLogger(server, username, password, uclogger)
+	}
+	return pool.connectionProvider.NewConnection(server, username, password, pool.customLogger)
+}
+
+// watchBlocked tracks connection.blocked and connection.unblocked notifications of a server.
+// The blocking channel is closed by the library when the connection is closed
+func (pool *Pool) watchBlocked(server string, blockingChannel chan amqp.Blocking) {
+	for blocking := range blockingChannel {
+		pool.setBlocked(server, blocking.Active, blocking.Reason)
+	}
+	pool.setBlocked(server, false, "connection closed")
+}
+
+func (pool *Pool) setBlocked(server string, blocked bool, reason string) {
+	pool.blockedLock.Lock()
+	_, wasBlocked := pool.blockedServers[server]
+	if blocked {
+		pool.blockedServers[server] = reason
+	} else {
+		delete(pool.blockedServers, server)
+	}
+	listeners := pool.blockedListeners
+	pool.blockedLock.Unlock()
+
+	if wasBlocked == blocked {
+		return
+	}
+	if blocked {
+		uclogger.LogErrorWithoutError(fmt.Sprintf("RabbitMQ connection to %s is blocked by the broker. Reason: %q", server, reason))
+	} else {
+		uclogger.LogWarning(fmt.Sprintf("RabbitMQ connection to %s is unblocked", server))
+	}
+	for _, listener := range listeners {
+		listener(server, blocked, reason)
+	}
+}
+
+// AddBlockedListener adds a listener that is called whenever a connection of the pool
+// is blocked or unblocked by the broker (eg. because of a memory or disk alarm)
+func (pool *Pool) AddBlockedListener(listener func(server string, blocked bool, reason string)) {
+	pool.blockedLock.Lock()
+	defer pool.blockedLock.Unlock()
+	pool.blockedListeners = append(pool.blockedListeners, listener)
+}
+
+// BlockedServers returns the servers whose connections are currently blocked along with the reason
+func (pool *Pool) BlockedServers() map[string]string {
+	pool.blockedLock.RLock()
+	defer pool.blockedLock.RUnlock()
+	blocked := make(map[string]string, len(pool.blockedServers))
+	for server, reason := range pool.blockedServers {
+		blocked[server] = reason
+	}
+	return blocked
+}
+
+// IsBlocked returns true if any connection of the pool is blocked by the broker
+func (pool *Pool) IsBlocked() bool {
+	pool.blockedLock.RLock()
+	defer pool.blockedLock.RUnlock()
+	return len(pool.blockedServers) > 0
 }
 
 // GetConnection provides a rabbitmq connection from connection pool, times out in 1 minute if unable to get a connection
 func (pool *Pool) GetConnection() (*amqp.Connection, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	return pool.GetConnectionWithContext(ctx)
+}
+
+// GetConnectionWithContext provides a rabbitmq connection from connection pool.
+// It returns an error if the context is done before a connection is available
+func (pool *Pool) GetConnectionWithContext(ctx context.Context) (*amqp.Connection, error) {
+	start := pool.latencyLogger.Tic()
 	select {
 	case container := <-pool.getConnection:
+		pool.latencyLogger.Toc(start, acquisitionLatencyMetricID)
 		return container.connection, nil
-	case <-time.After(1 * time.Minute):
-		err := fmt.Errorf("timeout occurred while trying to get a connection")
+	case <-ctx.Done():
+		err := fmt.Errorf("timeout occurred while trying to get a connection: %w", ctx.Err())
 		uclogger.LogError("error while trying to get connection from pool", err)
 		return nil, err
 	}
 }
+
+// nextContainer returns the next connection in round robin order starting after lastIndex along with its index.
+// Connections to healthy servers are preferred over connections to servers that are flapping.
+// It should only be called from the pool loop when there is at least one connection
+func (pool *Pool) nextContainer(lastIndex int) (int, *Container) {
+	fallbackIndex := -1
+	for i := 1; i <= len(pool.serverList); i++ {
+		index := (lastIndex + i) % len(pool.serverList)
+		server := pool.serverList[index]
+		container, ok := pool.connections[server]
+		if !ok {
+			continue
+		}
+		if !pool.health.isFlapping(server) {
+			return index, container
+		}
+		if fallbackIndex == -1 {
+			fallbackIndex = index
+		}
+	}
+	return fallbackIndex, pool.connections[pool.serverList[fallbackIndex]]
+}
+
+func (pool *Pool) recordFailure(server string) {
+	pool.health.recordFailure(server)
+	pool.latencyLogger.IncVal(1, serverFailuresMetricID, server)
+}
+
+// serverHealth tracks the recent connection failures of every server
+type serverHealth struct {
+	sync.Mutex
+	failures  map[string][]time.Time
+	threshold int
+	window    time.Duration
+}
+
+func newServerHealth(threshold int, window time.Duration) *serverHealth {
+	return &serverHealth{
+		failures:  make(map[string][]time.Time),
+		threshold: threshold,
+		window:    window,
+	}
+}
+
+func (h *serverHealth) recordFailure(server string) {
+	h.Lock()
+	defer h.Unlock()
+	h.failures[server] = append(h.recentFailures(server), time.Now())
+}
+
+// isFlapping returns true if the server failed threshold times within the window
+func (h *serverHealth) isFlapping(server string) bool {
+	h.Lock()
+	defer h.Unlock()
+	failures := h.recentFailures(server)
+	h.failures[server] = failures
+	return len(failures) >= h.threshold
+}
+
+// recentFailures returns the failures of the server within the window. It should be called with the lock held
+func (h *serverHealth) recentFailures(server string) []time.Time {
+	failures := h.failures[server]
+	cutoff := time.Now().Add(-h.window)
+	i := 0
+	for i < len(failures) && failures[i].Before(cutoff) {
+		i++
+	}
+	return failures[i:]
+}
@@ -0,0 +1,57 @@
+package connectionpool
+
+import (
+	"testing"
+	"time"
+)
+
+func TestNextContainerSkipsFlappingServers(t *testing.T) {
+	pool := &Pool{
+		serverList: []string{"server1", "server2", "server3"},
+		connections: map[string]*Container{
+			"server1": {serverInfo: "server1"},
+			"server2": {serverInfo: "server2"},
+			"server3": {serverInfo: "server3"},
+		},
+		health: newServerHealth(2, time.Minute),
+	}
+	pool.health.recordFailure("server2")
+	pool.health.recordFailure("server2")
+
+	index := 0
+	for i := 0; i < 4; i++ {
+		var container *Container
+		index, container = pool.nextContainer(index)
+		if container.serverInfo == "server2" {
+			t.Fatalf("nextContainer() returned flapping server2")
+		}
+	}
+}
+
+func TestNextContainerFallsBackToFlappingServer(t *testing.T) {
+	pool := &Pool{
+		serverList: []string{"server1", "server2"},
+		connections: map[string]*Container{
+			"server2": {serverInfo: "server2"},
+		},
+		health: newServerHealth(1, time.Minute),
+	}
+	pool.health.recordFailure("server2")
+
+	_, container := pool.nextContainer(0)
+	if container == nil || container.serverInfo != "server2" {
+		t.Fatalf("nextContainer() = %v, want server2", container)
+	}
+}
+
+func TestServerHealthWindow(t *testing.T) {
+	health := newServerHealth(1, 10*time.Millisecond)
+	health.recordFailure("server1")
+	if !health.isFlapping("server1") {
+		t.Fatalf("isFlapping() = false, want true right after a failure")
+	}
+	time.Sleep(20 * time.Millisecond)
+	if health.isFlapping("server1") {
+		t.Fatalf("isFlapping() = true, want false after the window")
+	}
+}
@@ -0,0 +1,125 @@
+package rabbitmq
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/carwale/golibraries/payloadcrypto"
+	"github.com/carwale/golibraries/poison"
+	"github.com/streadway/amqp"
+)
+
+const (
+	// headerDecryptAttempts counts the attempts to decrypt a message sent back through the dead letter queue
+	headerDecryptAttempts = "x-decrypt-attempts"
+	// decryptAttempts is the number of times a message is decrypted before it goes to the undeliverable store
+	decryptAttempts = 3
+)
+
+// SetEncryption encrypts the bodies of the published messages and decrypts the bodies of the consumed
+// messages with the cipher. The id of the key is sent in the payloadcrypto.HeaderKeyID header, and consumed
+// messages without it are processed as they are, so that encryption can be enabled before all publishers use it.
+// A consumer with encryption needs an undeliverable store, see SetUndeliverableStore
+func SetEncryption(cipher *payloadcrypto.Cipher) Option {
+	return func(om *OperationManager) { om.cipher = cipher }
+}
+
+// SetUndeliverableStore sets the store of the messages which cannot be decrypted. StartConsumer requires it with SetEncryption.
+// Such a message is sent through the dead letter queue to be decrypted again after its ttl; after 3 attempts it is
+// put in the store, still encrypted, and acked. While the store fails the message keeps going through the dead
+// letter queue, and if it cannot be published there it is requeued, so it is never dropped
+func SetUndeliverableStore(store poison.IQuarantineStore) Option {
+	return func(om *OperationManager) { om.undeliverableStore = store }
+}
+
+// encrypt encrypts the body of the publishing. It returns false if the message must not be published
+func (om *OperationManager) encrypt(publishing *amqp.Publishing) bool {
+	if om.cipher == nil {
+		return true
+	}
+	id, encrypted, err := om.cipher.Encrypt(publishing.Body)
+	if err != nil {
+		om.logger.LogError("Failed to encrypt a message", err)
+		return false
+	}
+	publishing.Body = encrypted
+	publishing.Headers[payloadcrypto.HeaderKeyID] = id
+	return true
+}
+
+// decrypt decrypts the body of the delivery if it has a key id header
+func (om *OperationManager) decrypt(msg *amqp.Delivery) error {
+	if om.cipher == nil {
+		return nil
+	}
+	id := amqpHeadersCarrier(msg.Headers).Get(payloadcrypto.HeaderKeyID)
+	if id == "" {
+		return nil
+	}
+	body, err := om.cipher.Decrypt(id, msg.Body)
+	if err != nil {
+		return err
+	}
+	msg.Body = body
+	return nil
+}
+
+// decryptAttemptsOf returns the number of times the delivery failed to be decrypted before
+func decryptAttemptsOf(msg amqp.Delivery) int {
+	switch attempts := msg.Headers[headerDecryptAttempts].(type) {
+	case int32:
+		return int(attempts)
+	case int64:
+		return int(attempts)
+	case int:
+		return attempts
+	}
+	return 0
+}
+
+// undecryptable settles a delivery which could not be decrypted. It is put in the undeliverable store after
+// decryptAttempts, or sent through the dead letter queue to be decrypted again
+func (om *OperationManager) undecryptable(acker *batchAcker, msg amqp.Delivery, cause error) {
+	attempts := decryptAttemptsOf(msg) + 1
+	om.logger.LogError(fmt.Sprintf("Failed to decrypt a message from queue %s in attempt %d", om.queueProps.queueName, attempts), cause)
+	if attempts >= decryptAttempts {
+		now := time.Now()
+		err := om.undeliverableStore.Quarantine(poison.QuarantinedMessage{
+			Fingerprint:  poison.Fingerprint(msg.Body),
+			Source:       om.queueProps.queueName,
+			Payload:      msg.Body,
+			Attempts:     attempts,
+			FirstFailure: now,
+			LastFailure:  now,
+			LastError:    cause.Error(),
+		})
+		if err == nil {
+			om.ack(acker, msg)
+			return
+		}
+		om.logger.LogError("Failed to store the undecryptable message from queue "+om.queueProps.queueName, err)
+	}
+	headers := amqp.Table{}
+	for k, v := range msg.Headers {
+		headers[k] = v
+	}
+	headers[headerDecryptAttempts] = int32(attempts)
+	// The body is published as it is, it is still encrypted with the key of its key id header
+	publishing := newPublishing(msg.Body)
+	publishing.Headers = headers
+	dlch, _ := om.NewRabbitmqChannel(false)
+	if dlch != nil {
+		// Undecryptable messages are rare, the dead letter queue is declared for each of them
+		err := om.SetBindings(dlch, true)
+		if err == nil {
+			err = dlch.Publish(om.dlQueueProps.exchangeName, om.dlQueueProps.routingKey, false, false, publishing)
+		}
+		dlch.Close()
+		if err == nil {
+			om.ack(acker, msg)
+			return
+		}
+		om.logger.LogError("Failed to send the undecryptable message to the dead letter queue", err)
+	}
+	om.requeue(acker, msg)
+}
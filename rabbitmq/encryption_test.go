@@ -0,0 +1,17 @@
+package rabbitmq
+
+import (
+	"testing"
+
+	"github.com/streadway/amqp"
+)
+
+func TestDecryptAttemptsOf(t *testing.T) {
+	if got := decryptAttemptsOf(amqp.Delivery{}); got != 0 {
+		t.Errorf("attempts without header = %d, want 0", got)
+	}
+	msg := amqp.Delivery{Headers: amqp.Table{headerDecryptAttempts: int32(2)}}
+	if got := decryptAttemptsOf(msg); got != 2 {
+		t.Errorf("attempts = %d, want 2", got)
+	}
+}
@@ -0,0 +1,25 @@
+package rabbitmq
+
+import (
+	"context"
+
+	"github.com/carwale/golibraries/envelope"
+	"github.com/streadway/amqp"
+)
+
+// PublishEnvelope encodes the envelope and publishes it to given queue. The type of the event is set as
+// the type property of the message and its version as the event-version header
+func (om *OperationManager) PublishEnvelope(ctx context.Context, ch *amqp.Channel, env *envelope.Envelope) error {
+	data, err := envelope.Encode(env)
+	if err != nil {
+		return err
+	}
+	publishing := newPublishing(data)
+	publishing.ContentType = envelope.ContentType
+	publishing.Type = env.Type
+	publishing.AppId = env.Producer
+	publishing.Timestamp = env.Timestamp
+	publishing.Headers = amqp.Table{"event-version": int32(env.Version)}
+	om.publish(ctx, ch, om.queueProps.exchangeName, om.queueProps.routingKey, publishing)
+	return nil
+}
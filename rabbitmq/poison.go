@@ -0,0 +1,51 @@
+package rabbitmq
+
+import (
+	"encoding/json"
+	"errors"
+
+	"github.com/carwale/golibraries/poison"
+	"github.com/streadway/amqp"
+)
+
+// SetPoisonDetector sets the detector used to quarantine messages that keep failing.
+// Quarantined messages are not dead lettered again, and redeliveries of them are acked without processing
+func SetPoisonDetector(detector *poison.Detector) Option {
+	return func(om *OperationManager) { om.poison = detector }
+}
+
+// QuarantineStore returns a store which publishes the quarantined messages as json to the queue of the operation manager
+func (om *OperationManager) QuarantineStore() poison.IQuarantineStore {
+	return poison.StoreFunc(func(msg poison.QuarantinedMessage) error {
+		body, err := json.Marshal(msg)
+		if err != nil {
+			return err
+		}
+		ch, _ := om.NewRabbitmqChannel(false)
+		if ch == nil {
+			return errors.New("could not open channel to quarantine queue " + om.queueProps.queueName)
+		}
+		defer ch.Close()
+		publishing := newPublishing(body)
+		publishing.Headers = amqp.Table{}
+		if !om.encrypt(&publishing) {
+			return errors.New("could not encrypt quarantined message")
+		}
+		return ch.Publish(om.queueProps.exchangeName, om.queueProps.routingKey, false, false, publishing)
+	})
+}
+
+// poisonPayload returns the payload used to fingerprint the message. The retry count is left out
+// as it changes every time the message is dead lettered
+func poisonPayload(data map[string]interface{}) []byte {
+	count, ok := data["count"]
+	if ok {
+		delete(data, "count")
+	}
+	// Keys of maps are sorted by json.Marshal, so the payload is stable
+	payload, _ := json.Marshal(data)
+	if ok {
+		data["count"] = count
+	}
+	return payload
+}
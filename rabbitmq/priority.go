@@ -0,0 +1,73 @@
+package rabbitmq
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/streadway/amqp"
+)
+
+// SetMaxPriority declares the queue as a priority queue with the given maximum priority (x-max-priority).
+// Rabbitmq recommends using a maximum priority of 10 or less. Priority queues are only supported by classic queues
+func SetMaxPriority(maxPriority uint8) Option {
+	return func(om *OperationManager) { om.maxPriority = maxPriority }
+}
+
+// PriorityQueueArgs returns the arguments to declare a classic priority queue
+func PriorityQueueArgs(maxPriority uint8) amqp.Table {
+	return amqp.Table{argQueueType: string(ClassicQueue), argMaxPriority: int32(maxPriority)}
+}
+
+// withMaxPriority returns a copy of the args with x-max-priority set
+func withMaxPriority(args amqp.Table, maxPriority uint8) amqp.Table {
+	newArgs := make(amqp.Table, len(args)+1)
+	for k, v := range args {
+		newArgs[k] = v
+	}
+	newArgs[argMaxPriority] = int32(maxPriority)
+	return newArgs
+}
+
+// PublishWithPriority publishes the message bytes to given queue with the given priority.
+// Messages with a higher priority are consumed before messages with a lower priority.
+// Priorities above the max priority of the queue are treated as the max priority by the broker
+func (om *OperationManager) PublishWithPriority(ctx context.Context, ch *amqp.Channel, msg []byte, priority uint8) {
+	if om.maxPriority == 0 {
+		om.logger.LogWarning("Publishing with priority to queue " + om.queueProps.queueName + " which is not declared as a priority queue. Use SetMaxPriority option")
+	} else if priority > om.maxPriority {
+		om.logger.LogDebugf("Priority %d is greater than max priority %d of queue %s", priority, om.maxPriority, om.queueProps.queueName)
+	}
+	publishing := newPublishing(msg)
+	publishing.Priority = priority
+	om.publish(ctx, ch, om.queueProps.exchangeName, om.queueProps.routingKey, publishing)
+}
+
+// ValidatePriorityQueue checks that the queue on the broker was declared with the same x-max-priority.
+// The broker rejects a declaration with inequivalent arguments, so the queue is re-declared on
+// a separate channel and the error from the broker is returned
+func (om *OperationManager) ValidatePriorityQueue() error {
+	if om.maxPriority == 0 {
+		return fmt.Errorf("max priority is not set for queue %s", om.queueProps.queueName)
+	}
+	// NewRabbitmqChannel retries until the broker is reachable, a validation fails instead
+	ch, err := om.channelProvider.GetChannel()
+	if err != nil {
+		return fmt.Errorf("could not open a channel to validate queue %s: %w", om.queueProps.queueName, err)
+	}
+	defer ch.Close()
+	_, err = ch.QueueDeclare(
+		om.queueProps.queueName,
+		true,               // durable
+		false,              // delete when usused
+		false,              // exclusive
+		false,              // no-wait
+		om.queueProps.args, // arguments
+	)
+	if err != nil {
+		if amqpErr, ok := err.(*amqp.Error); ok && amqpErr.Code == amqp.PreconditionFailed {
+			return fmt.Errorf("queue %s was declared with different arguments: %s", om.queueProps.queueName, amqpErr.Reason)
+		}
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,95 @@
+package rabbitmq
+
+import (
+	"context"
+	"fmt"
+	"runtime/debug"
+	"time"
+
+	"github.com/carwale/golibraries/crashreport"
+	"github.com/carwale/golibraries/gologger"
+)
+
+// SetFailureCircuit pauses consumption for pause after threshold consecutive messages failed to process.
+// The channel is closed so that the prefetched messages go back to the queue, and the consumer
+// subscribes again after the pause. It protects the broker and the dependencies of the processor
+// from tight loops of failing messages. Disabled by default
+func SetFailureCircuit(threshold int, pause time.Duration) Option {
+	return func(om *OperationManager) {
+		if threshold > 0 && pause > 0 {
+			om.circuit = &failureCircuit{threshold: threshold, pause: pause}
+		}
+	}
+}
+
+// failureCircuit counts consecutive failures of the processor. It is only used by the consumer goroutine
+type failureCircuit struct {
+	threshold   int
+	pause       time.Duration
+	consecutive int
+}
+
+// record records the outcome of a message and returns true if consumption should be paused
+func (c *failureCircuit) record(isProcessed bool) bool {
+	if isProcessed {
+		c.consecutive = 0
+		return false
+	}
+	c.consecutive++
+	if c.consecutive >= c.threshold {
+		c.consecutive = 0
+		return true
+	}
+	return false
+}
+
+// safeProcessMessage calls the processor and recovers from panics. A panic is logged with its stack trace
+// and the message is treated as not processed, so it is nacked without requeue and dead lettered
+func (om *OperationManager) safeProcessMessage(ctx context.Context, processor IProcessor, data map[string]interface{}) (isProcessed bool) {
+	defer func() {
+		if r := recover(); r != nil {
+			om.logPanic(r)
+			isProcessed = false
+		}
+	}()
+	return processMessage(ctx, processor, data)
+}
+
+// safeProcessMessageWithAck calls the manual ack processor and recovers from panics.
+// The message is nacked without requeue if the processor panics before settling it
+func (om *OperationManager) safeProcessMessageWithAck(ctx context.Context, processor IManualAckProcessor, data map[string]interface{}, handle *AckHandle) {
+	defer func() {
+		if r := recover(); r != nil {
+			om.logPanic(r)
+			handle.Nack(false)
+		}
+	}()
+	processor.ProcessMessageWithAck(ctx, data, handle)
+}
+
+// SetCrashReporter reports the panics of the processor with a crash report instead of only logging the stack
+func SetCrashReporter(reporter *crashreport.Reporter) Option {
+	return func(om *OperationManager) { om.crashReporter = reporter }
+}
+
+func (om *OperationManager) logPanic(r interface{}) {
+	if om.crashReporter != nil {
+		om.crashReporter.Report("rabbitmq consumer "+om.queueProps.queueName, r)
+		return
+	}
+	om.logger.LogErrorMessage("Processor panicked while processing a message", fmt.Errorf("%v", r),
+		gologger.Pair{Key: "queue", Value: om.queueProps.queueName},
+		gologger.Pair{Key: "stack_trace", Value: string(debug.Stack())})
+}
+
+// retryCount returns the count of the message. JSON numbers are decoded as float64
+func retryCount(data map[string]interface{}) int {
+	switch count := data["count"].(type) {
+	case float64:
+		return int(count)
+	case int:
+		return count
+	default:
+		return 0
+	}
+}
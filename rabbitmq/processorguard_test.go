@@ -0,0 +1,36 @@
+package rabbitmq
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+func TestFailureCircuit(t *testing.T) {
+	circuit := &failureCircuit{threshold: 3, pause: time.Second}
+	outcomes := []bool{false, false, true, false, false}
+	for _, isProcessed := range outcomes {
+		if circuit.record(isProcessed) {
+			t.Fatalf("circuit opened before %d consecutive failures", circuit.threshold)
+		}
+	}
+	if !circuit.record(false) {
+		t.Error("circuit should open after 3 consecutive failures")
+	}
+	if circuit.record(false) {
+		t.Error("circuit should reset after opening")
+	}
+}
+
+func TestRetryCountFromJSON(t *testing.T) {
+	var data map[string]interface{}
+	if err := json.Unmarshal([]byte(`{"count": 2}`), &data); err != nil {
+		t.Fatal(err)
+	}
+	if got := retryCount(data); got != 2 {
+		t.Errorf("retryCount() = %d, want 2", got)
+	}
+	if got := retryCount(map[string]interface{}{}); got != 0 {
+		t.Errorf("retryCount() = %d, want 0", got)
+	}
+}
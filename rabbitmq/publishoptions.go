@@ -0,0 +1,62 @@
+package rabbitmq
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	"github.com/streadway/amqp"
+)
+
+// PublishOptions holds the metadata of a published message.
+// Zero values use the defaults of Publish, a persistent message with content type application/octet-stream
+type PublishOptions struct {
+	// ContentType is the MIME type of the payload. Defaults to application/octet-stream
+	ContentType string
+	// Headers are copied into the message headers. Trace headers are added to them if tracing is enabled
+	Headers amqp.Table
+	// Expiration is the per message TTL. The broker discards the message if it is not consumed in time. 0 means no expiration
+	Expiration time.Duration
+	// Priority of the message. Only used by priority queues, see SetMaxPriority
+	Priority uint8
+	// MessageID is an application defined identifier of the message
+	MessageID string
+	// Timestamp is the time the message was created. It is not set if it is zero
+	Timestamp time.Time
+	// Transient messages are not written to disk by the broker
+	Transient bool
+}
+
+// publishing returns the amqp publishing for the payload with the options applied
+func (opts PublishOptions) publishing(payload []byte) amqp.Publishing {
+	publishing := newPublishing(payload)
+	if opts.ContentType != "" {
+		publishing.ContentType = opts.ContentType
+	}
+	if len(opts.Headers) > 0 {
+		// The headers are copied as trace headers are injected into them while publishing
+		publishing.Headers = make(amqp.Table, len(opts.Headers))
+		for k, v := range opts.Headers {
+			publishing.Headers[k] = v
+		}
+	}
+	if opts.Expiration > 0 {
+		publishing.Expiration = strconv.FormatInt(opts.Expiration.Milliseconds(), 10)
+	}
+	if opts.Transient {
+		publishing.DeliveryMode = amqp.Transient
+	}
+	publishing.Priority = opts.Priority
+	publishing.MessageId = opts.MessageID
+	publishing.Timestamp = opts.Timestamp
+	return publishing
+}
+
+// PublishWithOptions publishes the payload to given queue with the metadata in opts.
+// If tracing is enabled the trace context of ctx is propagated to the consumer through the message headers
+func (om *OperationManager) PublishWithOptions(ctx context.Context, ch *amqp.Channel, payload []byte, opts PublishOptions) {
+	if opts.Priority > 0 && om.maxPriority == 0 {
+		om.logger.LogWarning("Publishing with priority to queue " + om.queueProps.queueName + " which is not declared as a priority queue. Use SetMaxPriority option")
+	}
+	om.publish(ctx, ch, om.queueProps.exchangeName, om.queueProps.routingKey, opts.publishing(payload))
+}
@@ -0,0 +1,41 @@
+package rabbitmq
+
+import (
+	"testing"
+	"time"
+
+	"github.com/streadway/amqp"
+)
+
+func TestPublishOptionsDefaults(t *testing.T) {
+	publishing := PublishOptions{}.publishing([]byte("payload"))
+	if publishing.ContentType != "application/octet-stream" || publishing.DeliveryMode != amqp.Persistent {
+		t.Errorf("publishing() = %+v, want persistent octet-stream", publishing)
+	}
+	if publishing.Expiration != "" || !publishing.Timestamp.IsZero() {
+		t.Errorf("publishing() = %+v, want no expiration and timestamp", publishing)
+	}
+}
+
+func TestPublishOptions(t *testing.T) {
+	headers := amqp.Table{"tenant": "in"}
+	now := time.Now()
+	publishing := PublishOptions{
+		ContentType: "application/json",
+		Headers:     headers,
+		Expiration:  1500 * time.Millisecond,
+		Priority:    3,
+		MessageID:   "id-1",
+		Timestamp:   now,
+		Transient:   true,
+	}.publishing([]byte("payload"))
+
+	if publishing.ContentType != "application/json" || publishing.Expiration != "1500" || publishing.Priority != 3 ||
+		publishing.MessageId != "id-1" || !publishing.Timestamp.Equal(now) || publishing.DeliveryMode != amqp.Transient {
+		t.Errorf("publishing() = %+v", publishing)
+	}
+	publishing.Headers["traceparent"] = "00-trace"
+	if len(headers) != 1 {
+		t.Errorf("publishing() should copy the headers, got %v", headers)
+	}
+}
@@ -0,0 +1,178 @@
+package rabbitmq
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/streadway/amqp"
+)
+
+// QueueType is the type of the rabbitmq queue (x-queue-type)
+type QueueType string
+
+const (
+	// ClassicQueue is the default rabbitmq queue type
+	ClassicQueue QueueType = "classic"
+	// QuorumQueue is a durable replicated queue based on raft. It replaces classic mirrored queues (x-ha-policy)
+	QuorumQueue QueueType = "quorum"
+	// StreamQueue is an append only log with non destructive consumers
+	StreamQueue QueueType = "stream"
+)
+
+// Argument keys used in queue declarations
+const (
+	argQueueType          = "x-queue-type"
+	argQueueMode          = "x-queue-mode"
+	argHAPolicy           = "x-ha-policy"
+	argMaxPriority        = "x-max-priority"
+	argDeliveryLimit      = "x-delivery-limit"
+	argInitialGroupSize   = "x-quorum-initial-group-size"
+	argMaxAge             = "x-max-age"
+	argMaxLengthBytes     = "x-max-length-bytes"
+	argStreamSegmentSize  = "x-stream-max-segment-size-bytes"
+	argDeadLetterExchange = "x-dead-letter-exchange"
+	argDeadLetterKey      = "x-dead-letter-routing-key"
+	argMessageTTL         = "x-message-ttl"
+	argExpires            = "x-expires"
+	argOverflow           = "x-overflow"
+	argSingleActive       = "x-single-active-consumer"
+)
+
+// unsupportedArgs lists the arguments that the broker rejects or silently ignores for each queue type
+var unsupportedArgs = map[QueueType][]string{
+	ClassicQueue: {argDeliveryLimit, argInitialGroupSize, argMaxAge, argStreamSegmentSize},
+	QuorumQueue:  {argQueueMode, argHAPolicy, argMaxPriority, argMaxAge, argStreamSegmentSize},
+	StreamQueue: {argQueueMode, argHAPolicy, argMaxPriority, argDeliveryLimit, argDeadLetterExchange,
+		argDeadLetterKey, argMessageTTL, argExpires, argOverflow, argSingleActive},
+}
+
+// knownArgs are the queue arguments supported by rabbitmq
+var knownArgs = map[string]bool{
+	argQueueType: true, argQueueMode: true, argHAPolicy: true, argMaxPriority: true, argDeliveryLimit: true,
+	argInitialGroupSize: true, argMaxAge: true, argMaxLengthBytes: true, argStreamSegmentSize: true,
+	argDeadLetterExchange: true, argDeadLetterKey: true, argMessageTTL: true, argExpires: true, argOverflow: true,
+	argSingleActive: true, "x-max-length": true, "x-dead-letter-strategy": true, "x-queue-master-locator": true,
+	"x-queue-leader-locator": true, "x-queue-version": true, "x-initial-cluster-size": true,
+	"x-stream-filter-size-bytes": true,
+}
+
+// QuorumQueueArgs returns the arguments to declare a quorum queue.
+// deliveryLimit is the number of times a message is redelivered before it is dead lettered or dropped.
+// initialGroupSize is the number of replicas. Zero values use the broker defaults
+func QuorumQueueArgs(deliveryLimit int, initialGroupSize int) amqp.Table {
+	args := amqp.Table{argQueueType: string(QuorumQueue)}
+	if deliveryLimit > 0 {
+		args[argDeliveryLimit] = int32(deliveryLimit)
+	}
+	if initialGroupSize > 0 {
+		args[argInitialGroupSize] = int32(initialGroupSize)
+	}
+	return args
+}
+
+// LazyQueueArgs returns the arguments to declare a classic lazy queue which keeps messages on disk.
+// Note that from rabbitmq 3.12 all classic queues behave like lazy queues and the argument is ignored
+func LazyQueueArgs() amqp.Table {
+	return amqp.Table{argQueueType: string(ClassicQueue), argQueueMode: "lazy"}
+}
+
+// StreamQueueArgs returns the arguments to declare a stream queue.
+// maxAge is the retention of the stream (eg. "7D", "12h"). maxLengthBytes is the maximum size of the stream.
+// Zero values mean no limit
+func StreamQueueArgs(maxAge string, maxLengthBytes int64) amqp.Table {
+	args := amqp.Table{argQueueType: string(StreamQueue)}
+	if maxAge != "" {
+		args[argMaxAge] = maxAge
+	}
+	if maxLengthBytes > 0 {
+		args[argMaxLengthBytes] = maxLengthBytes
+	}
+	return args
+}
+
+// QueueArgsError is returned when the queue arguments are not valid for the queue type
+type QueueArgsError struct {
+	QueueType QueueType
+	// InvalidArgs are the arguments that are not supported by the queue type
+	InvalidArgs []string
+}
+
+func (e *QueueArgsError) Error() string {
+	return fmt.Sprintf("arguments %s are not supported for %s queues", strings.Join(e.InvalidArgs, ", "), e.QueueType)
+}
+
+// GetQueueType returns the queue type from the arguments. It defaults to classic
+func GetQueueType(args amqp.Table) QueueType {
+	if queueType, ok := args[argQueueType].(string); ok && queueType != "" {
+		return QueueType(queueType)
+	}
+	return ClassicQueue
+}
+
+// ValidateQueueArgs checks that the arguments are valid for the queue type set in the arguments.
+// It returns a *QueueArgsError listing the unsupported arguments
+func ValidateQueueArgs(args amqp.Table) error {
+	queueType := GetQueueType(args)
+	unsupported, ok := unsupportedArgs[queueType]
+	if !ok {
+		return fmt.Errorf("unknown queue type %q", queueType)
+	}
+	var invalidArgs []string
+	for _, arg := range unsupported {
+		if _, ok := args[arg]; ok {
+			invalidArgs = append(invalidArgs, arg)
+		}
+	}
+	if len(invalidArgs) > 0 {
+		sort.Strings(invalidArgs)
+		return &QueueArgsError{QueueType: queueType, InvalidArgs: invalidArgs}
+	}
+	return nil
+}
+
+// MigrationWarnings returns warnings for arguments that are deprecated on modern rabbitmq versions
+// and should be migrated before upgrading the broker
+func MigrationWarnings(args amqp.Table) []string {
+	var warnings []string
+	if _, ok := args[argHAPolicy]; ok {
+		warnings = append(warnings, "x-ha-policy (classic mirrored queues) is removed in rabbitmq 4.0. Migrate to quorum queues")
+	}
+	if mode, ok := args[argQueueMode]; ok && mode == "lazy" {
+		warnings = append(warnings, "x-queue-mode=lazy is ignored from rabbitmq 3.12 as all classic queues are lazy")
+	}
+	return warnings
+}
+
+// UnknownQueueArgs returns the arguments that are not rabbitmq queue arguments, usually misspelt ones.
+// The broker accepts them and ignores them
+func UnknownQueueArgs(args amqp.Table) []string {
+	var unknown []string
+	for arg := range args {
+		if !knownArgs[arg] {
+			unknown = append(unknown, arg)
+		}
+	}
+	sort.Strings(unknown)
+	return unknown
+}
+
+// SetQueueArgs sets the arguments used to declare the queue. Use QuorumQueueArgs, LazyQueueArgs or
+// StreamQueueArgs for presets. The dead letter queue is declared with a compatible queue type.
+// NewRabbitMQManager panics if the arguments are not valid for the queue type
+func SetQueueArgs(args amqp.Table) Option {
+	return func(om *OperationManager) { om.queueArgs = args }
+}
+
+// deadLetterQueueArgs returns the arguments for the dead letter queue of a queue of the given type.
+// Streams do not support dead lettering so a quorum queue is used for their dead letter queue
+func deadLetterQueueArgs(queueType QueueType) amqp.Table {
+	args := make(amqp.Table)
+	switch queueType {
+	case QuorumQueue, StreamQueue:
+		args[argQueueType] = string(QuorumQueue)
+	default:
+		args[argHAPolicy] = "all"
+	}
+	return args
+}
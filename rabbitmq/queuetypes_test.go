@@ -0,0 +1,71 @@
+package rabbitmq
+
+import (
+	"testing"
+
+	"github.com/streadway/amqp"
+)
+
+func TestValidateQueueArgs(t *testing.T) {
+	tests := []struct {
+		name        string
+		args        amqp.Table
+		invalidArgs []string
+	}{
+		{"nil args are a classic queue", nil, nil},
+		{"quorum preset", QuorumQueueArgs(5, 3), nil},
+		{"lazy preset", LazyQueueArgs(), nil},
+		{"stream preset", StreamQueueArgs("7D", 1<<30), nil},
+		{"quorum with ha policy", amqp.Table{"x-queue-type": "quorum", "x-ha-policy": "all"}, []string{"x-ha-policy"}},
+		{"stream with dead lettering", amqp.Table{"x-queue-type": "stream", "x-message-ttl": int32(100), "x-dead-letter-exchange": "ex"},
+			[]string{"x-dead-letter-exchange", "x-message-ttl"}},
+		{"classic with delivery limit", amqp.Table{"x-delivery-limit": int32(5)}, []string{"x-delivery-limit"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateQueueArgs(tt.args)
+			if tt.invalidArgs == nil {
+				if err != nil {
+					t.Errorf("ValidateQueueArgs() error = %v, want nil", err)
+				}
+				return
+			}
+			argsErr, ok := err.(*QueueArgsError)
+			if !ok {
+				t.Fatalf("ValidateQueueArgs() error = %v, want *QueueArgsError", err)
+			}
+			if len(argsErr.InvalidArgs) != len(tt.invalidArgs) {
+				t.Fatalf("ValidateQueueArgs() invalid args = %v, want %v", argsErr.InvalidArgs, tt.invalidArgs)
+			}
+			for i := range tt.invalidArgs {
+				if argsErr.InvalidArgs[i] != tt.invalidArgs[i] {
+					t.Errorf("ValidateQueueArgs() invalid args = %v, want %v", argsErr.InvalidArgs, tt.invalidArgs)
+				}
+			}
+		})
+	}
+}
+
+func TestUnknownQueueArgs(t *testing.T) {
+	unknown := UnknownQueueArgs(amqp.Table{"x-queue-type": "quorum", "x-max-lenght": int32(10), "x-message-ttl": int32(100)})
+	if len(unknown) != 1 || unknown[0] != "x-max-lenght" {
+		t.Errorf("UnknownQueueArgs() = %v, want the misspelt argument", unknown)
+	}
+}
+
+func TestValidateQueueArgsUnknownType(t *testing.T) {
+	if err := ValidateQueueArgs(amqp.Table{"x-queue-type": "unknown"}); err == nil {
+		t.Error("ValidateQueueArgs() should return an error for unknown queue type")
+	}
+}
+
+func TestDeadLetterQueueArgs(t *testing.T) {
+	for _, queueType := range []QueueType{ClassicQueue, QuorumQueue, StreamQueue} {
+		args := deadLetterQueueArgs(queueType)
+		args["x-dead-letter-exchange"] = "ex"
+		args["x-message-ttl"] = int32(1000)
+		if err := ValidateQueueArgs(args); err != nil {
+			t.Errorf("dead letter args for %s queue are invalid: %v", queueType, err)
+		}
+	}
+}
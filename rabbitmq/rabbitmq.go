@@ -1,13 +1,26 @@
 package rabbitmq
 
 import (
+	"context"
 	"encoding/json"
 	"strings"
 	"sync"
+	"time"
 
+	"github.com/carwale/golibraries/crashreport"
 	"github.com/carwale/golibraries/gologger"
+	"github.com/carwale/golibraries/healthcheck"
+	"github.com/carwale/golibraries/payloadcrypto"
+	"github.com/carwale/golibraries/poison"
 	"github.com/carwale/golibraries/rabbitmq/channelprovider"
+	"github.com/carwale/golibraries/rabbitmq/connection"
+	"github.com/carwale/golibraries/reconnect"
+	"github.com/carwale/golibraries/secrets"
+	"github.com/prometheus/client_golang/prometheus"
 	"github.com/streadway/amqp"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
 )
 
 const (
@@ -24,15 +37,77 @@ type IProcessor interface {
 
 // OperationManager manages rabbitmq connections and operations like publish & consume
 type OperationManager struct {
-	logger          *gologger.CustomLogger
-	rabbitMqServers []string
-	channelProvider *channelprovider.ChannelProvider
-	queueProps      queueProperties
-	dlQueueProps    queueProperties
-	username 		string
-	password 		string
+	logger             gologger.ILogger
+	rabbitMqServers    []string
+	channelProvider    *channelprovider.ChannelProvider
+	queueProps         queueProperties
+	dlQueueProps       queueProperties
+	username           string
+	password           string
+	latencyLogger      gologger.IMultiLogger
+	tracer             trace.Tracer
+	propagator         propagation.TextMapPropagator
+	queueArgs          amqp.Table
+	maxPriority        uint8
+	connConfig         *connection.ConnectionConfig
+	ackMode            AckMode
+	ackBatchSize       int
+	ackBatchInterval   time.Duration
+	circuit            *failureCircuit
+	poison             *poison.Detector
+	readiness          *healthcheck.ReadinessController
+	readinessName      string
+	crashReporter      *crashreport.Reporter
+	cipher             *payloadcrypto.Cipher
+	undeliverableStore poison.IQuarantineStore
+	prefetchCount      int
 }
 
+// Option sets a parameter for the OperationManager
+type Option func(om *OperationManager)
+
+// SetConnectionConfig sets the config used to connect to the rabbitmq servers (scheme, port, vhost, heartbeat etc).
+// Defaults to connection.DefaultConnectionConfig
+func SetConnectionConfig(config *connection.ConnectionConfig) Option {
+	return func(om *OperationManager) { om.connConfig = config }
+}
+
+// SetLatencyLogger sets the latency logger used for rabbitmq metrics.
+// Defaults to the rate latency logger
+func SetLatencyLogger(latencyLogger gologger.IMultiLogger) Option {
+	return func(om *OperationManager) { om.latencyLogger = latencyLogger }
+}
+
+// SetCredentialsSecrets reads the username and the password from the secrets provider, e.g. a secrets.Manager,
+// instead of the arguments of NewRabbitMQManager. It panics if a secret cannot be read
+func SetCredentialsSecrets(provider secrets.IProvider, usernameSecret string, passwordSecret string) Option {
+	return func(om *OperationManager) {
+		om.username = secrets.MustGet(provider, usernameSecret)
+		om.password = secrets.MustGet(provider, passwordSecret)
+	}
+}
+
+// SetPrefetchCount sets the number of unacked messages the broker delivers to the consumer.
+// Defaults to 5. With AckBatched it is raised to the batch size
+func SetPrefetchCount(count int) Option {
+	return func(om *OperationManager) { om.prefetchCount = count }
+}
+
+const blockedGaugeMetricID = "RABBITMQ-BLOCKED"
+
+// blockedMetrics are registered with prometheus once and added to every latency logger used by a manager
+var blockedMetrics = gologger.NewMetricSet(func(logger gologger.ILogger) map[string]gologger.IMetricVec {
+	return map[string]gologger.IMetricVec{
+		blockedGaugeMetricID: gologger.NewGaugeMetric(prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "rabbitmq_connection_blocked",
+				Help: "Whether the rabbitmq connection to the server is blocked by the broker",
+			},
+			[]string{"Server"},
+		), logger),
+	}
+})
+
 // queueProperties struct holds queue details
 type queueProperties struct {
 	queueName    string
@@ -44,20 +119,43 @@ type queueProperties struct {
 
 // NewRabbitMQManager : returns RabbitMQ OperationManager.
 // panics if empty server list given.
-func NewRabbitMQManager(logger *gologger.CustomLogger, rabbitMqServers []string, queueName string, username string, password string) *OperationManager {
+func NewRabbitMQManager(logger gologger.ILogger, rabbitMqServers []string, queueName string, username string, password string, options ...Option) *OperationManager {
 	if len(rabbitMqServers) == 0 {
 		panic("No rabbitmq servers provided.")
 	}
-	if (username == "" || password == "") {
+	om := &OperationManager{
+		logger:           logger,
+		rabbitMqServers:  rabbitMqServers,
+		username:         username,
+		password:         password,
+		ackBatchSize:     defaultAckBatchSize,
+		ackBatchInterval: defaultAckBatchInterval,
+		prefetchCount:    5,
+	}
+	for _, option := range options {
+		option(om)
+	}
+	if om.username == "" || om.password == "" {
 		panic("RabbitMQ username or password is empty")
 	}
-	om := &OperationManager{
-		logger:          logger,
-		rabbitMqServers: rabbitMqServers,
-		username: username,
-		password: password,
+	if om.latencyLogger == nil {
+		om.latencyLogger = gologger.NewRateLatencyLogger(gologger.SetLogger(om.logger))
+	}
+	if om.maxPriority > 0 {
+		om.queueArgs = withMaxPriority(om.queueArgs, om.maxPriority)
+	}
+	if err := ValidateQueueArgs(om.queueArgs); err != nil {
+		om.logger.LogError("Invalid queue arguments for queue "+queueName, err)
+		panic(err)
+	}
+	for _, warning := range MigrationWarnings(om.queueArgs) {
+		om.logger.LogWarning("Queue " + queueName + ": " + warning)
 	}
-	om.channelProvider = channelprovider.NewChannelProviderWithServers(om.logger, om.rabbitMqServers, om.username, om.password)
+	for _, arg := range UnknownQueueArgs(om.queueArgs) {
+		om.logger.LogWarning("Queue " + queueName + ": unknown argument " + arg + " is ignored by the broker")
+	}
+	om.channelProvider = channelprovider.NewChannelProviderWithConfig(om.logger, om.rabbitMqServers, om.username, om.password, om.connConfig)
+	om.initBlockedMetric()
 	// Init queue properties
 	queueName = strings.ToUpper(queueName)
 	dlQueueName := strings.ToUpper(queueName) + dlQueueSuffix
@@ -66,11 +164,10 @@ func NewRabbitMQManager(logger *gologger.CustomLogger, rabbitMqServers []string,
 		exchangeType: "direct",
 		exchangeName: queueName + exchangeSuffix,
 		routingKey:   queueName + keySuffix,
-		args:         nil,
+		args:         om.queueArgs,
 	}
 	// DL Queue args
-	dlargs := make(amqp.Table)
-	dlargs["x-ha-policy"] = "all"
+	dlargs := deadLetterQueueArgs(GetQueueType(om.queueArgs))
 	dlargs["x-dead-letter-exchange"] = om.queueProps.exchangeName
 	dlargs["x-dead-letter-routing-key"] = om.queueProps.routingKey
 	dlargs["x-message-ttl"] = ttl
@@ -82,10 +179,53 @@ func NewRabbitMQManager(logger *gologger.CustomLogger, rabbitMqServers []string,
 		routingKey:   dlQueueName + keySuffix,
 		args:         dlargs,
 	}
+	gologger.LogConfig(om.logger, "rabbitmq "+om.queueProps.queueName, om.effectiveConfig())
 
 	return om
 }
 
+// effectiveConfig returns the configuration of the manager to be logged
+func (om *OperationManager) effectiveConfig() map[string]interface{} {
+	config := map[string]interface{}{
+		"servers":        om.rabbitMqServers,
+		"username":       om.username,
+		"password":       om.password,
+		"queue":          om.queueProps.queueName,
+		"exchange":       om.queueProps.exchangeName,
+		"queue_args":     map[string]interface{}(om.queueArgs),
+		"ack_mode":       int(om.ackMode),
+		"prefetch_count": om.prefetchCount,
+		"max_priority":   om.maxPriority,
+		"encryption":     om.cipher != nil,
+		"tracing":        om.tracer != nil,
+	}
+	if om.connConfig != nil {
+		config["scheme"] = om.connConfig.Scheme
+		config["port"] = om.connConfig.Port
+		config["vhost"] = om.connConfig.VHost
+		config["heartbeat"] = om.connConfig.Heartbeat.String()
+	}
+	return config
+}
+
+// initBlockedMetric adds the blocked gauge to the latency logger of the manager
+func (om *OperationManager) initBlockedMetric() {
+	blockedMetrics.AddTo(om.latencyLogger, om.logger)
+	om.channelProvider.AddBlockedListener(func(server string, blocked bool, reason string) {
+		var value int64
+		if blocked {
+			value = 1
+		}
+		om.latencyLogger.SetVal(value, blockedGaugeMetricID, server)
+	})
+}
+
+// IsBlocked returns true if the broker has blocked any of the connections (eg. because of a memory alarm).
+// Publishing will hang while the connection is blocked. This can be used in the health check function
+func (om *OperationManager) IsBlocked() bool {
+	return om.channelProvider.IsBlocked()
+}
+
 // NewRabbitmqChannel : initializes the rabbitmq channel.
 // Input parameter is a flag to notify error on channel
 // NOTE: Add a listener to returned error channel to handle connection errors.
@@ -158,12 +298,44 @@ func (om *OperationManager) SetBindings(ch *amqp.Channel, isDL bool) error {
 
 // StartConsumer : starts the consumer from given queue
 // Also it declares a dead letter queue and publishes the failed messages to DL
+// If the queue is deleted while consuming (basic.cancel), the topology is re-declared and the consumer re-subscribes
 func (om *OperationManager) StartConsumer(processor IProcessor) {
+	if om.maxPriority > 0 {
+		if err := om.ValidatePriorityQueue(); err != nil {
+			om.logger.LogError("Queue "+om.queueProps.queueName+" is not a priority queue. Messages will be consumed without priorities", err)
+		}
+	}
+	if om.cipher != nil && om.undeliverableStore == nil {
+		panic("consumer with encryption needs an undeliverable store")
+	}
+	manualProcessor, isManualProcessor := processor.(IManualAckProcessor)
+	if om.ackMode == AckManual && !isManualProcessor {
+		panic("processor must implement IManualAckProcessor to use AckManual")
+	}
+	prefetch := om.prefetchCount
+	if om.ackMode == AckBatched && om.ackBatchSize > prefetch {
+		// The broker stops delivering once prefetch messages are unacked
+		prefetch = om.ackBatchSize
+	}
 	once := sync.Once{}
+	redeclare := false
+	backoff := reconnect.DefaultPolicy.NewBackoff()
 	for {
 		ch, errChan := om.NewRabbitmqChannel(true)
+		cancelChan := ch.NotifyCancel(make(chan string, 1))
 
-		ch.Qos(5, 0, false) // Per consumer limit
+		if redeclare {
+			om.logger.LogWarning("Re-declaring bindings for queue " + om.queueProps.queueName)
+			if err := om.SetBindings(ch, false); err != nil {
+				om.logger.LogError("Failed to re-declare queue bindings", err)
+				ch.Close()
+				backoff.Sleep(context.Background())
+				continue
+			}
+			redeclare = false
+		}
+
+		ch.Qos(prefetch, 0, false) // Per consumer limit
 
 		om.logger.LogInfo("Waiting for Messages to process")
 		deliveryChan, err := ch.Consume(
@@ -176,31 +348,91 @@ func (om *OperationManager) StartConsumer(processor IProcessor) {
 			nil,                     // args
 		)
 		if err != nil {
+			om.markUnready("consume failed")
 			om.logger.LogError("Failed to register a consumer", err)
+			// The queue may not exist anymore
+			redeclare = true
+			backoff.Sleep(context.Background())
 			continue
 		}
+		backoff.Reset()
+		om.markReady()
+		var acker *batchAcker
+		var ticker *time.Ticker
+		var flushTick <-chan time.Time
+		if om.ackMode == AckBatched {
+			acker = &batchAcker{ch: ch, size: om.ackBatchSize}
+			ticker = time.NewTicker(om.ackBatchInterval)
+			flushTick = ticker.C
+		}
 	consumeLoop:
 		for {
 			select {
+			case <-flushTick:
+				if err := acker.flush(); err != nil {
+					om.logger.LogError("Failed to ack the batch of messages", err)
+				}
 			case err := <-errChan:
 				if err != nil {
 					om.logger.LogError("Error received on RabbitMQ error channel", err)
 					break consumeLoop
 				}
-			case msg := <-deliveryChan:
+			case consumerTag := <-cancelChan:
+				om.logger.LogErrorWithoutError("Consumer " + consumerTag + " was cancelled by the broker. The queue " + om.queueProps.queueName + " may have been deleted")
+				redeclare = true
+				ch.Close()
+				break consumeLoop
+			case msg, ok := <-deliveryChan:
+				if !ok {
+					om.logger.LogWarning("Delivery channel closed. Re-subscribing to queue " + om.queueProps.queueName)
+					ch.Close()
+					break consumeLoop
+				}
+				if err := om.decrypt(&msg); err != nil {
+					om.undecryptable(acker, msg, err)
+					continue
+				}
 				var data map[string]interface{}
 				err := json.Unmarshal(msg.Body, &data)
 				// If msg is not in right format then discard it
 				if err != nil {
 					om.logger.LogErrorMessage("Failed to parse the data from json message", err, gologger.Pair{Key: "message_body", Value: string(msg.Body)})
+					om.nack(acker, msg)
 					continue
 				}
 
+				var payload []byte
+				if om.poison != nil {
+					payload = poisonPayload(data)
+					if om.poison.IsQuarantined(payload) {
+						om.logger.LogWarning("Dropping redelivery of quarantined message from queue " + om.queueProps.queueName)
+						om.ack(acker, msg)
+						continue
+					}
+				}
+
 				// Processing the received message
-				isProcessed := processor.ProcessMessage(data)
+				ctx, span := om.startConsumeSpan(msg)
+				if om.ackMode == AckManual {
+					om.safeProcessMessageWithAck(ctx, manualProcessor, data, &AckHandle{delivery: msg})
+					if span != nil {
+						span.End()
+					}
+					continue
+				}
+				isProcessed := om.safeProcessMessage(ctx, processor, data)
+				if span != nil {
+					if !isProcessed {
+						span.SetStatus(codes.Error, "message processing failed")
+					}
+					span.End()
+				}
 				if isProcessed {
 					om.logger.LogInfo("Message successfully processed")
-					msg.Ack(false)
+					if om.poison != nil {
+						om.poison.RecordSuccess(payload)
+					}
+					om.ack(acker, msg)
 				} else {
 					once.Do(func() {
 						dlch, _ := om.NewRabbitmqChannel(false)
@@ -211,42 +443,110 @@ func (om *OperationManager) StartConsumer(processor IProcessor) {
 						}
 						dlch.Close()
 					})
-					msg.Nack(false, false)
+					om.nack(acker, msg)
 
-					if _, isExists := data["count"]; isExists {
-						data["count"] = data["count"].(int) + 1
-					} else {
-						data["count"] = 1
-					}
-					if cnt, _ := data["count"]; cnt.(int) <= 5 {
+					quarantined := om.poison != nil && om.poison.RecordFailure(payload, nil)
+					count := retryCount(data) + 1
+					data["count"] = count
+					if count <= 5 && !quarantined {
 						dataBytes, err := json.Marshal(data)
 						if err != nil {
 							om.logger.LogError("Failed to marshal the data to json", err)
-							continue
+						} else {
+							dlch, _ := om.NewRabbitmqChannel(false)
+							om.publish(ctx, dlch, om.dlQueueProps.exchangeName, om.dlQueueProps.routingKey, newPublishing(dataBytes))
+							dlch.Close()
 						}
-						dlch, _ := om.NewRabbitmqChannel(false)
-						om.PublishDL(dlch, dataBytes)
-						dlch.Close()
 					}
 				}
+				if om.circuit != nil && om.circuit.record(isProcessed) {
+					om.logger.LogErrorWithoutErrorf("%d consecutive messages of queue %s failed. Pausing consumption for %s", om.circuit.threshold, om.queueProps.queueName, om.circuit.pause)
+					ch.Close()
+					time.Sleep(om.circuit.pause)
+					break consumeLoop
+				}
 
 			}
 		}
+		om.markUnready("reconnecting")
+		if ticker != nil {
+			// Unacked messages of the batch are redelivered by the broker as the channel is closed
+			ticker.Stop()
+		}
+	}
+}
+
+// ack acks the message, in the batch of the acker if it is not nil
+func (om *OperationManager) ack(acker *batchAcker, msg amqp.Delivery) {
+	if acker == nil {
+		msg.Ack(false)
+		return
+	}
+	if err := acker.add(msg.DeliveryTag); err != nil {
+		om.logger.LogError("Failed to ack the batch of messages", err)
+	}
+}
+
+// nack nacks the message without requeueing it, so that it is dead lettered or dropped by the broker. With an
+// acker the processed messages are acked first, so that the next multiple ack does not cover the message
+func (om *OperationManager) nack(acker *batchAcker, msg amqp.Delivery) {
+	if acker == nil {
+		msg.Nack(false, false)
+		return
+	}
+	if err := acker.nack(msg.DeliveryTag, false); err != nil {
+		om.logger.LogError("Failed to nack the message", err)
+	}
+}
+
+// requeue nacks the message with requeue, so that the broker delivers it again
+func (om *OperationManager) requeue(acker *batchAcker, msg amqp.Delivery) {
+	if acker == nil {
+		msg.Nack(false, true)
+		return
+	}
+	if err := acker.nack(msg.DeliveryTag, true); err != nil {
+		om.logger.LogError("Failed to requeue the message", err)
 	}
 }
 
 // PublishDL : publishes the message bytes to dead letter queue
 func (om *OperationManager) PublishDL(ch *amqp.Channel, msg []byte) {
-	om.publish(msg, ch, om.dlQueueProps.exchangeName, om.dlQueueProps.routingKey)
+	om.publish(context.Background(), ch, om.dlQueueProps.exchangeName, om.dlQueueProps.routingKey, newPublishing(msg))
 }
 
 // Publish : publishes the message bytes to given queue
 func (om *OperationManager) Publish(ch *amqp.Channel, msg []byte) {
-	om.publish(msg, ch, om.queueProps.exchangeName, om.queueProps.routingKey)
+	om.publish(context.Background(), ch, om.queueProps.exchangeName, om.queueProps.routingKey, newPublishing(msg))
+}
+
+// PublishWithContext : publishes the message bytes to given queue.
+// If tracing is enabled the trace context of ctx is propagated to the consumer through the message headers
+func (om *OperationManager) PublishWithContext(ctx context.Context, ch *amqp.Channel, msg []byte) {
+	om.publish(ctx, ch, om.queueProps.exchangeName, om.queueProps.routingKey, newPublishing(msg))
+}
+
+// newPublishing returns a persistent publishing with the default content type
+func newPublishing(msg []byte) amqp.Publishing {
+	return amqp.Publishing{
+		ContentType:  "application/octet-stream",
+		DeliveryMode: 2,
+		Body:         msg,
+	}
 }
 
-func (om *OperationManager) publish(msg []byte, ch *amqp.Channel, exchangeName string, routingKey string) {
+func (om *OperationManager) publish(ctx context.Context, ch *amqp.Channel, exchangeName string, routingKey string, publishing amqp.Publishing) {
 	if ch != nil {
+		if publishing.Headers == nil {
+			publishing.Headers = amqp.Table{}
+		}
+		if !om.encrypt(&publishing) {
+			return
+		}
+		span := om.startPublishSpan(ctx, publishing.Headers, exchangeName, routingKey, len(publishing.Body))
+		if span != nil {
+			defer span.End()
+		}
 		if err := ch.Publish(
 			exchangeName, // exchange
 			routingKey,   // routing key
@@ -254,12 +554,12 @@ func (om *OperationManager) publish(msg []byte, ch *amqp.Channel, exchangeName s
 			//If this flag is set to true, the server will return an unroutable message to the producer
 			//with a `basic.return` AMQP method. If this flag is set to false, the server silently drops the message)
 			false, // immediate
-			amqp.Publishing{
-				ContentType:  "application/octet-stream",
-				DeliveryMode: 2,
-				Body:         msg,
-			}); err != nil {
+			publishing); err != nil {
 			om.logger.LogError("Failed to publish a message", err)
+			if span != nil {
+				span.RecordError(err)
+				span.SetStatus(codes.Error, "publish failed")
+			}
 		}
 	} else {
 		om.logger.LogErrorWithoutError("RabbitMQ channel is nil")
@@ -0,0 +1,25 @@
+package rabbitmq
+
+import "github.com/carwale/golibraries/healthcheck"
+
+// SetReadiness registers the consumer of the queue as a component of the readiness controller.
+// The component is ready while the consumer is subscribed to the queue, and not ready while it reconnects
+func SetReadiness(rc *healthcheck.ReadinessController, component string) Option {
+	return func(om *OperationManager) {
+		rc.Register(component)
+		om.readiness = rc
+		om.readinessName = component
+	}
+}
+
+func (om *OperationManager) markReady() {
+	if om.readiness != nil {
+		om.readiness.MarkReady(om.readinessName)
+	}
+}
+
+func (om *OperationManager) markUnready(reason string) {
+	if om.readiness != nil {
+		om.readiness.MarkUnready(om.readinessName, reason)
+	}
+}
@@ -0,0 +1,111 @@
+package rabbitmq
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/carwale/golibraries/gotracer"
+	"github.com/streadway/amqp"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const tracerName = "github.com/carwale/golibraries/rabbitmq"
+
+// IContextProcessor can be implemented by a processor to receive the context of the consumer span.
+// If the processor implements this interface, ProcessMessageWithContext is called instead of ProcessMessage
+type IContextProcessor interface {
+	ProcessMessageWithContext(context.Context, map[string]interface{}) bool
+}
+
+// SetTracer enables tracing of published and consumed messages.
+// The trace context is injected into the message headers on publish using the propagator
+// of the tracer and extracted on consume to create a consumer span which is a child of the producer span
+func SetTracer(tracer *gotracer.CustomTracer) Option {
+	return func(om *OperationManager) {
+		if tracer == nil {
+			return
+		}
+		var provider trace.TracerProvider = otel.GetTracerProvider()
+		if tracer.GetTracerProvider() != nil {
+			provider = tracer.GetTracerProvider()
+		}
+		om.tracer = provider.Tracer(tracerName)
+		om.propagator = tracer.GetTextMapPropagator()
+	}
+}
+
+// amqpHeadersCarrier adapts amqp.Table to propagation.TextMapCarrier
+type amqpHeadersCarrier amqp.Table
+
+// Get returns the value associated with the passed key.
+func (c amqpHeadersCarrier) Get(key string) string {
+	value, ok := c[key]
+	if !ok {
+		return ""
+	}
+	switch v := value.(type) {
+	case string:
+		return v
+	case []byte:
+		return string(v)
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+// Set stores the key-value pair.
+func (c amqpHeadersCarrier) Set(key string, value string) {
+	c[key] = value
+}
+
+// Keys lists the keys stored in this carrier.
+func (c amqpHeadersCarrier) Keys() []string {
+	keys := make([]string, 0, len(c))
+	for k := range c {
+		keys = append(keys, k)
+	}
+	return keys
+}
+
+var _ propagation.TextMapCarrier = amqpHeadersCarrier{}
+
+// startPublishSpan starts a producer span and injects its context into the headers.
+// It returns a nil span if tracing is not enabled
+func (om *OperationManager) startPublishSpan(ctx context.Context, headers amqp.Table, exchangeName string, routingKey string, bodySize int) trace.Span {
+	if om.tracer == nil {
+		return nil
+	}
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	ctx, span := om.tracer.Start(ctx, exchangeName+" publish",
+		trace.WithSpanKind(trace.SpanKindProducer),
+		trace.WithAttributes(gotracer.RabbitMQPublishAttributes(exchangeName, routingKey, bodySize)...))
+	om.propagator.Inject(ctx, amqpHeadersCarrier(headers))
+	return span
+}
+
+// startConsumeSpan extracts the producer context from the delivery headers and starts a consumer span.
+// It returns the context to be passed to the processor and a nil span if tracing is not enabled
+func (om *OperationManager) startConsumeSpan(msg amqp.Delivery) (context.Context, trace.Span) {
+	ctx := context.Background()
+	if om.tracer == nil {
+		return ctx, nil
+	}
+	if msg.Headers != nil {
+		ctx = om.propagator.Extract(ctx, amqpHeadersCarrier(msg.Headers))
+	}
+	return om.tracer.Start(ctx, om.queueProps.queueName+" process",
+		trace.WithSpanKind(trace.SpanKindConsumer),
+		trace.WithAttributes(gotracer.RabbitMQConsumeAttributes(om.queueProps.queueName, msg.RoutingKey, msg.DeliveryTag, msg.MessageId, len(msg.Body))...))
+}
+
+// processMessage calls the processor with the context if it implements IContextProcessor
+func processMessage(ctx context.Context, processor IProcessor, data map[string]interface{}) bool {
+	if ctxProcessor, ok := processor.(IContextProcessor); ok {
+		return ctxProcessor.ProcessMessageWithContext(ctx, data)
+	}
+	return processor.ProcessMessage(data)
+}
@@ -0,0 +1,212 @@
+// Package reconnect retries connections to brokers with a capped exponential backoff and jitter.
+// It is used by the rabbitmq packages and can be used for any client that has to reconnect
+package reconnect
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"sync"
+	"time"
+
+	"github.com/carwale/golibraries/gologger"
+)
+
+// ErrGaveUp is returned by Manager.Do when the policy does not allow more attempts
+var ErrGaveUp = errors.New("reconnect: gave up")
+
+// Policy decides the wait between attempts and when to give up
+type Policy struct {
+	// InitialDelay is the wait after the first failed attempt. Defaults to 1 second
+	InitialDelay time.Duration
+	// MaxDelay caps the wait between attempts. Defaults to 1 minute
+	MaxDelay time.Duration
+	// Multiplier is applied to the wait after every failed attempt. Defaults to 2
+	Multiplier float64
+	// Jitter is the fraction of the wait that is randomised, so clients do not reconnect at the same time.
+	// 0.2 waits between 80% and 120% of the delay. Defaults to 0.2, a negative value disables it
+	Jitter float64
+	// MaxAttempts is the number of attempts after which Do gives up. 0 means no limit
+	MaxAttempts int
+	// MaxElapsed is the time after which Do gives up. 0 means no limit
+	MaxElapsed time.Duration
+}
+
+// DefaultPolicy retries forever, waiting from 1 second up to 1 minute between attempts
+var DefaultPolicy = Policy{InitialDelay: time.Second, MaxDelay: time.Minute, Multiplier: 2, Jitter: 0.2}
+
+func (p Policy) withDefaults() Policy {
+	if p.InitialDelay <= 0 {
+		p.InitialDelay = DefaultPolicy.InitialDelay
+	}
+	if p.MaxDelay <= 0 {
+		p.MaxDelay = DefaultPolicy.MaxDelay
+	}
+	if p.Multiplier < 1 {
+		p.Multiplier = DefaultPolicy.Multiplier
+	}
+	if p.Jitter == 0 {
+		p.Jitter = DefaultPolicy.Jitter
+	}
+	return p
+}
+
+// Backoff returns the waits of the policy. It is not safe for concurrent use
+type Backoff struct {
+	policy Policy
+	delay  time.Duration
+	random *rand.Rand
+}
+
+// NewBackoff returns a backoff for the policy
+func (p Policy) NewBackoff() *Backoff {
+	return &Backoff{policy: p.withDefaults(), random: rand.New(rand.NewSource(time.Now().UnixNano()))}
+}
+
+// Next returns the wait before the next attempt
+func (b *Backoff) Next() time.Duration {
+	if b.delay == 0 {
+		b.delay = b.policy.InitialDelay
+	} else {
+		b.delay = time.Duration(float64(b.delay) * b.policy.Multiplier)
+	}
+	if b.delay > b.policy.MaxDelay {
+		b.delay = b.policy.MaxDelay
+	}
+	if b.policy.Jitter <= 0 {
+		return b.delay
+	}
+	spread := float64(b.delay) * b.policy.Jitter
+	return time.Duration(float64(b.delay) - spread + 2*spread*b.random.Float64())
+}
+
+// Reset starts the waits again from the initial delay, after a successful attempt
+func (b *Backoff) Reset() {
+	b.delay = 0
+}
+
+// Sleep waits for the next delay or till ctx is done. It returns false if ctx is done
+func (b *Backoff) Sleep(ctx context.Context) bool {
+	timer := time.NewTimer(b.Next())
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+// EventType is the type of a reconnection event
+type EventType int
+
+const (
+	// AttemptFailed is sent after every failed attempt, with the error and the wait before the next attempt
+	AttemptFailed EventType = iota
+	// Connected is sent after a successful attempt
+	Connected
+	// GaveUp is sent when the policy does not allow more attempts
+	GaveUp
+)
+
+// String returns the name of the event type
+func (t EventType) String() string {
+	return [...]string{"attempt_failed", "connected", "gave_up"}[t]
+}
+
+// Event describes an attempt of a manager
+type Event struct {
+	Name    string
+	Type    EventType
+	Attempt int
+	Delay   time.Duration
+	Err     error
+}
+
+// Manager runs connection attempts with the backoff of its policy and sends events to its listeners
+type Manager struct {
+	name      string
+	policy    Policy
+	logger    gologger.ILogger
+	lock      sync.RWMutex
+	listeners []func(Event)
+}
+
+// Option sets a parameter for the Manager
+type Option func(m *Manager)
+
+// SetPolicy sets the backoff policy. Defaults to DefaultPolicy
+func SetPolicy(policy Policy) Option {
+	return func(m *Manager) { m.policy = policy }
+}
+
+// SetLogger sets the logger used for the failed attempts. Defaults to gologger.NewLogger()
+func SetLogger(logger gologger.ILogger) Option {
+	return func(m *Manager) { m.logger = logger }
+}
+
+// AddListener adds a listener for the events of the manager
+func AddListener(listener func(Event)) Option {
+	return func(m *Manager) { m.listeners = append(m.listeners, listener) }
+}
+
+// NewManager returns a manager. The name identifies the connection in logs and events
+func NewManager(name string, options ...Option) *Manager {
+	m := &Manager{name: name, policy: DefaultPolicy}
+	for _, option := range options {
+		option(m)
+	}
+	if m.logger == nil {
+		m.logger = gologger.NewLogger()
+	}
+	return m
+}
+
+// AddListener adds a listener for the events of the manager
+func (m *Manager) AddListener(listener func(Event)) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	m.listeners = append(m.listeners, listener)
+}
+
+// Do calls connect till it succeeds, ctx is done or the policy gives up. In the last case the error wraps
+// ErrGaveUp and the last error of connect
+func (m *Manager) Do(ctx context.Context, connect func(ctx context.Context) error) error {
+	backoff := m.policy.NewBackoff()
+	start := time.Now()
+	for attempt := 1; ; attempt++ {
+		err := connect(ctx)
+		if err == nil {
+			m.notify(Event{Name: m.name, Type: Connected, Attempt: attempt})
+			return nil
+		}
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if (m.policy.MaxAttempts > 0 && attempt >= m.policy.MaxAttempts) ||
+			(m.policy.MaxElapsed > 0 && time.Since(start) >= m.policy.MaxElapsed) {
+			m.logger.LogError(fmt.Sprintf("giving up connecting to %s after %d attempts", m.name, attempt), err)
+			m.notify(Event{Name: m.name, Type: GaveUp, Attempt: attempt, Err: err})
+			return fmt.Errorf("%w connecting to %s after %d attempts: %v", ErrGaveUp, m.name, attempt, err)
+		}
+		delay := backoff.Next()
+		m.logger.LogError(fmt.Sprintf("attempt %d to connect to %s failed, retrying in %s", attempt, m.name, delay.Round(time.Millisecond)), err)
+		m.notify(Event{Name: m.name, Type: AttemptFailed, Attempt: attempt, Delay: delay, Err: err})
+		timer := time.NewTimer(delay)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		}
+	}
+}
+
+func (m *Manager) notify(event Event) {
+	m.lock.RLock()
+	defer m.lock.RUnlock()
+	for _, listener := range m.listeners {
+		listener(event)
+	}
+}
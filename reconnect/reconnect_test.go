@@ -0,0 +1,57 @@
+package reconnect
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestBackoff(t *testing.T) {
+	backoff := Policy{InitialDelay: time.Second, MaxDelay: 5 * time.Second, Jitter: -1}.NewBackoff()
+	want := []time.Duration{time.Second, 2 * time.Second, 4 * time.Second, 5 * time.Second, 5 * time.Second}
+	for i, delay := range want {
+		if got := backoff.Next(); got != delay {
+			t.Errorf("Next() #%d = %s, want %s", i, got, delay)
+		}
+	}
+	backoff.Reset()
+	if got := backoff.Next(); got != time.Second {
+		t.Errorf("Next() after Reset() = %s, want 1s", got)
+	}
+
+	jittered := Policy{InitialDelay: time.Second}.NewBackoff()
+	if got := jittered.Next(); got < 800*time.Millisecond || got > 1200*time.Millisecond {
+		t.Errorf("Next() with jitter = %s, want within 20%% of 1s", got)
+	}
+}
+
+func TestManagerGivesUp(t *testing.T) {
+	var events []EventType
+	m := NewManager("rabbitmq-1",
+		SetPolicy(Policy{InitialDelay: time.Millisecond, MaxAttempts: 3}),
+		AddListener(func(e Event) { events = append(events, e.Type) }))
+	attempts := 0
+	err := m.Do(context.Background(), func(ctx context.Context) error {
+		attempts++
+		return errors.New("connection refused")
+	})
+	if !errors.Is(err, ErrGaveUp) || attempts != 3 {
+		t.Fatalf("Do() = %v after %d attempts, want ErrGaveUp after 3", err, attempts)
+	}
+	if len(events) != 3 || events[0] != AttemptFailed || events[2] != GaveUp {
+		t.Errorf("events = %v, want 2 failed attempts and gave up", events)
+	}
+
+	attempts = 0
+	err = m.Do(context.Background(), func(ctx context.Context) error {
+		attempts++
+		if attempts < 2 {
+			return errors.New("connection refused")
+		}
+		return nil
+	})
+	if err != nil || events[len(events)-1] != Connected {
+		t.Errorf("Do() = %v, last event %v, want a connection on the second attempt", err, events[len(events)-1])
+	}
+}
@@ -0,0 +1,363 @@
+// Package reload applies configuration changes to a running service. Components register a hook with the
+// coordinator; on SIGHUP, or on a trigger like a consul watch, the coordinator loads the configuration again
+// and calls the hooks of the components whose keys changed. The changes are applied to all the components or
+// to none: the hooks are validated first, and the components already reloaded are rolled back to the previous
+// configuration if a hook fails.
+//
+//	coordinator := reload.NewCoordinator(reload.KVLoader(consulAgent.GetKeyValuePairs, "stocks/config/"),
+//		reload.SetLogger(logger))
+//	coordinator.Register("logger", "log.", reload.ReloadFunc(func(cfg reload.Config) error {
+//		logLevel.Set(cfg.String("log.level", "ERROR"))
+//		return nil
+//	}))
+//	err := coordinator.Reload(ctx)
+//	go coordinator.Run(ctx)
+//	go consulAgent.WatchPrefix(ctx, "stocks/config/", time.Second, func(map[string][]byte) { coordinator.Trigger() })
+package reload
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/signal"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"github.com/carwale/golibraries/gologger"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Config is a flat configuration, e.g. log.level=DEBUG
+type Config map[string]string
+
+// String returns the value of the key, or defaultValue if the key is not set
+func (c Config) String(key string, defaultValue string) string {
+	if value, ok := c[key]; ok {
+		return value
+	}
+	return defaultValue
+}
+
+// Int returns the integer value of the key, or defaultValue if the key is not set or is not an integer
+func (c Config) Int(key string, defaultValue int) int {
+	if value, err := strconv.Atoi(c[key]); err == nil {
+		return value
+	}
+	return defaultValue
+}
+
+// Bool returns the boolean value of the key, or defaultValue if the key is not set or is not a boolean
+func (c Config) Bool(key string, defaultValue bool) bool {
+	if value, err := strconv.ParseBool(c[key]); err == nil {
+		return value
+	}
+	return defaultValue
+}
+
+// Duration returns the duration value of the key, e.g. 1m30s, or defaultValue if the key is not set or is
+// not a duration
+func (c Config) Duration(key string, defaultValue time.Duration) time.Duration {
+	if value, err := time.ParseDuration(c[key]); err == nil {
+		return value
+	}
+	return defaultValue
+}
+
+// ILoader loads the configuration
+type ILoader interface {
+	Load(ctx context.Context) (Config, error)
+}
+
+// LoaderFunc is a function implementing ILoader
+type LoaderFunc func(ctx context.Context) (Config, error)
+
+// Load calls f
+func (f LoaderFunc) Load(ctx context.Context) (Config, error) {
+	return f(ctx)
+}
+
+// KVLoader loads the key value pairs under the prefix with list, e.g. the GetKeyValuePairs method of the
+// consul agent. The prefix is removed from the keys and the slashes are replaced with dots, so
+// stocks/config/log/level is log.level
+func KVLoader(list func(prefix string) map[string][]byte, prefix string) ILoader {
+	return LoaderFunc(func(ctx context.Context) (Config, error) {
+		pairs := list(prefix)
+		if pairs == nil {
+			return nil, fmt.Errorf("could not list the keys under %s", prefix)
+		}
+		cfg := make(Config, len(pairs))
+		for key, value := range pairs {
+			key = strings.Trim(strings.TrimPrefix(key, prefix), "/")
+			if key != "" {
+				cfg[strings.ReplaceAll(key, "/", ".")] = string(value)
+			}
+		}
+		return cfg, nil
+	})
+}
+
+// EnvLoader loads the environment variables starting with prefix. The prefix is removed from the names,
+// which are lower cased with the underscores replaced with dots, so STOCKS_LOG_LEVEL is log.level for
+// the STOCKS_ prefix
+func EnvLoader(prefix string) ILoader {
+	return LoaderFunc(func(ctx context.Context) (Config, error) {
+		cfg := Config{}
+		for _, variable := range os.Environ() {
+			name, value, _ := strings.Cut(variable, "=")
+			if strings.HasPrefix(name, prefix) && len(name) > len(prefix) {
+				cfg[strings.ReplaceAll(strings.ToLower(name[len(prefix):]), "_", ".")] = value
+			}
+		}
+		return cfg, nil
+	})
+}
+
+// IReloadable applies a configuration. Reload is called with the whole configuration when a key under the
+// prefix of the component changed
+type IReloadable interface {
+	Reload(cfg Config) error
+}
+
+// IValidator is implemented by the components which can check a configuration before any component applies it
+type IValidator interface {
+	Validate(cfg Config) error
+}
+
+// ReloadFunc is a function implementing IReloadable
+type ReloadFunc func(cfg Config) error
+
+// Reload calls f
+func (f ReloadFunc) Reload(cfg Config) error {
+	return f(cfg)
+}
+
+// Option sets a parameter of the coordinator
+type Option func(c *Coordinator)
+
+// SetSignals sets the signals triggering a reload in Run. Default is SIGHUP
+func SetSignals(signals ...os.Signal) Option {
+	return func(c *Coordinator) { c.signals = signals }
+}
+
+// SetLogger sets the logger of the coordinator
+func SetLogger(logger gologger.ILogger) Option {
+	return func(c *Coordinator) { c.logger = logger }
+}
+
+// SetLatencyLogger sets the latency logger used for the metrics of the coordinator
+func SetLatencyLogger(latencyLogger gologger.IMultiLogger) Option {
+	return func(c *Coordinator) { c.latencyLogger = latencyLogger }
+}
+
+const reloadsMetricID = "RELOAD-RELOADS"
+
+var reloadMetrics = gologger.NewMetricSet(func(logger gologger.ILogger) map[string]gologger.IMetricVec {
+	return map[string]gologger.IMetricVec{
+		reloadsMetricID: gologger.NewCounterMetric(prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "config_reloads_total",
+				Help: "Number of configuration reloads by status (applied, unchanged, failed, rolled_back)",
+			},
+			[]string{"Status"},
+		), logger),
+	}
+})
+
+type component struct {
+	name       string
+	prefix     string
+	reloadable IReloadable
+}
+
+// Coordinator reloads the configuration of the registered components
+type Coordinator struct {
+	loader        ILoader
+	signals       []os.Signal
+	logger        gologger.ILogger
+	latencyLogger gologger.IMultiLogger
+
+	// mu serializes the reloads and guards components
+	mu         sync.Mutex
+	components []component
+	current    atomic.Value
+	trigger    chan struct{}
+}
+
+// NewCoordinator returns a coordinator loading the configuration with the loader
+func NewCoordinator(loader ILoader, options ...Option) *Coordinator {
+	c := &Coordinator{
+		loader:  loader,
+		signals: []os.Signal{syscall.SIGHUP},
+		trigger: make(chan struct{}, 1),
+	}
+	for _, option := range options {
+		option(c)
+	}
+	if c.logger == nil {
+		c.logger = gologger.NewLogger(gologger.SetLogLevel("ERROR"))
+	}
+	if c.latencyLogger == nil {
+		c.latencyLogger = gologger.NewRateLatencyLogger(gologger.SetLogger(c.logger))
+	}
+	reloadMetrics.AddTo(c.latencyLogger, c.logger)
+	c.current.Store(Config{})
+	return c
+}
+
+// Register adds a component reloaded when a key starting with prefix changes. An empty prefix matches
+// every key. Components are reloaded in the order they are registered
+func (c *Coordinator) Register(name string, prefix string, reloadable IReloadable) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.components = append(c.components, component{name: name, prefix: prefix, reloadable: reloadable})
+}
+
+// Current returns the configuration last applied. It must not be modified
+func (c *Coordinator) Current() Config {
+	return c.current.Load().(Config)
+}
+
+// Trigger asks Run to reload. Triggers received during a reload are merged into one reload
+func (c *Coordinator) Trigger() {
+	select {
+	case c.trigger <- struct{}{}:
+	default:
+	}
+}
+
+// Run reloads on the signals and on the triggers until ctx is done. Errors are logged
+func (c *Coordinator) Run(ctx context.Context) {
+	signals := make(chan os.Signal, 1)
+	if len(c.signals) > 0 {
+		signal.Notify(signals, c.signals...)
+		defer signal.Stop(signals)
+	}
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case sig := <-signals:
+			c.logger.LogInfof("Reloading the configuration on %v", sig)
+		case <-c.trigger:
+		}
+		if err := c.Reload(ctx); err != nil {
+			c.logger.LogError("could not reload the configuration", err)
+		}
+	}
+}
+
+// Reload loads the configuration and applies the changes to the components whose keys changed. Nothing is
+// applied if a component rejects the configuration, and the components already reloaded are rolled back if
+// a component fails to reload
+func (c *Coordinator) Reload(ctx context.Context) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	cfg, err := c.loader.Load(ctx)
+	if err != nil {
+		c.latencyLogger.IncVal(1, reloadsMetricID, "failed")
+		return err
+	}
+	previous := c.Current()
+	changed := changedKeys(previous, cfg)
+	if len(changed) == 0 {
+		c.latencyLogger.IncVal(1, reloadsMetricID, "unchanged")
+		c.logger.LogDebug("Configuration unchanged")
+		return nil
+	}
+
+	var affected []component
+	for _, comp := range c.components {
+		if matchesAny(comp.prefix, changed) {
+			affected = append(affected, comp)
+		}
+	}
+	for _, comp := range affected {
+		if validator, ok := comp.reloadable.(IValidator); ok {
+			if err := validator.Validate(cfg); err != nil {
+				c.latencyLogger.IncVal(1, reloadsMetricID, "failed")
+				return fmt.Errorf("%s rejected the configuration: %w", comp.name, err)
+			}
+		}
+	}
+	for i, comp := range affected {
+		if err := comp.reloadable.Reload(cfg); err != nil {
+			c.rollback(affected[:i], previous)
+			c.latencyLogger.IncVal(1, reloadsMetricID, "rolled_back")
+			return fmt.Errorf("%s could not reload the configuration: %w", comp.name, err)
+		}
+	}
+	c.current.Store(cfg)
+	c.latencyLogger.IncVal(1, reloadsMetricID, "applied")
+	names := make([]string, len(affected))
+	for i, comp := range affected {
+		names[i] = comp.name
+	}
+	c.logger.LogInfoMessage("Configuration reloaded",
+		gologger.Pair{Key: "config_diff", Value: diffJSON(previous, cfg, changed)},
+		gologger.Pair{Key: "config_components", Value: strings.Join(names, ",")})
+	return nil
+}
+
+// rollback applies the previous configuration to the components, in reverse order
+func (c *Coordinator) rollback(components []component, previous Config) {
+	for i := len(components) - 1; i >= 0; i-- {
+		if err := components[i].reloadable.Reload(previous); err != nil {
+			c.logger.LogErrorMessage("could not roll back the configuration", err,
+				gologger.Pair{Key: "config_component", Value: components[i].name})
+		}
+	}
+}
+
+// changedKeys returns the sorted keys added, removed or changed between the configurations
+func changedKeys(previous, cfg Config) []string {
+	var changed []string
+	for key, value := range cfg {
+		if old, ok := previous[key]; !ok || old != value {
+			changed = append(changed, key)
+		}
+	}
+	for key := range previous {
+		if _, ok := cfg[key]; !ok {
+			changed = append(changed, key)
+		}
+	}
+	sort.Strings(changed)
+	return changed
+}
+
+func matchesAny(prefix string, keys []string) bool {
+	for _, key := range keys {
+		if strings.HasPrefix(key, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// diffJSON returns the json of the changes, "old -> new" by key, with the values of secret keys masked
+func diffJSON(previous, cfg Config, changed []string) string {
+	diff := make(map[string]interface{}, len(changed))
+	for _, key := range changed {
+		old, hadOld := previous[key]
+		value, hasValue := cfg[key]
+		switch {
+		case !hadOld:
+			diff[key] = "(unset) -> " + value
+		case !hasValue:
+			diff[key] = old + " -> (unset)"
+		default:
+			diff[key] = old + " -> " + value
+		}
+	}
+	var b strings.Builder
+	encoder := json.NewEncoder(&b)
+	encoder.SetEscapeHTML(false)
+	encoder.Encode(gologger.MaskConfig(diff))
+	return strings.TrimSuffix(b.String(), "\n")
+}
@@ -0,0 +1,111 @@
+package reload
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+// recorder is a component recording the configurations it applied
+type recorder struct {
+	applied  []Config
+	fail     bool
+	validate func(cfg Config) error
+}
+
+func (r *recorder) Reload(cfg Config) error {
+	if r.fail {
+		return errors.New("reload failed")
+	}
+	r.applied = append(r.applied, cfg)
+	return nil
+}
+
+func (r *recorder) Validate(cfg Config) error {
+	if r.validate != nil {
+		return r.validate(cfg)
+	}
+	return nil
+}
+
+func staticLoader(cfg *Config) ILoader {
+	return LoaderFunc(func(ctx context.Context) (Config, error) { return *cfg, nil })
+}
+
+func TestReloadCallsChangedComponents(t *testing.T) {
+	cfg := Config{"log.level": "ERROR", "pool.size": "10"}
+	c := NewCoordinator(staticLoader(&cfg))
+	logger, pool := &recorder{}, &recorder{}
+	c.Register("logger", "log.", logger)
+	c.Register("pool", "pool.", pool)
+
+	if err := c.Reload(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	cfg = Config{"log.level": "DEBUG", "pool.size": "10"}
+	if err := c.Reload(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if len(logger.applied) != 2 || len(pool.applied) != 1 {
+		t.Errorf("logger reloaded %d times, pool %d times, want 2 and 1", len(logger.applied), len(pool.applied))
+	}
+	if c.Current().String("log.level", "") != "DEBUG" {
+		t.Errorf("current = %v", c.Current())
+	}
+}
+
+func TestReloadIsAtomic(t *testing.T) {
+	cfg := Config{"log.level": "ERROR", "pool.size": "10"}
+	c := NewCoordinator(staticLoader(&cfg))
+	logger, pool := &recorder{}, &recorder{}
+	c.Register("logger", "", logger)
+	c.Register("pool", "pool.", pool)
+	if err := c.Reload(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	// a rejected configuration is applied to no component
+	pool.validate = func(cfg Config) error {
+		if cfg.Int("pool.size", 0) <= 0 {
+			return errors.New("pool.size must be positive")
+		}
+		return nil
+	}
+	cfg = Config{"log.level": "DEBUG", "pool.size": "0"}
+	if err := c.Reload(context.Background()); err == nil {
+		t.Fatal("Reload() accepted a rejected configuration")
+	}
+	if len(logger.applied) != 1 {
+		t.Errorf("logger reloaded %d times, want 1", len(logger.applied))
+	}
+
+	// a failed reload rolls back the components already reloaded
+	pool.fail = true
+	cfg = Config{"log.level": "DEBUG", "pool.size": "20"}
+	if err := c.Reload(context.Background()); err == nil {
+		t.Fatal("Reload() did not fail")
+	}
+	last := logger.applied[len(logger.applied)-1]
+	if last.String("log.level", "") != "ERROR" || c.Current().String("pool.size", "") != "10" {
+		t.Errorf("logger has %v and current is %v after the rollback", last, c.Current())
+	}
+}
+
+func TestKVLoader(t *testing.T) {
+	loader := KVLoader(func(prefix string) map[string][]byte {
+		return map[string][]byte{prefix + "log/level": []byte("INFO"), prefix: nil}
+	}, "stocks/config/")
+	cfg, err := loader.Load(context.Background())
+	if err != nil || len(cfg) != 1 || cfg["log.level"] != "INFO" {
+		t.Errorf("Load() = %v, %v", cfg, err)
+	}
+}
+
+func TestDiffMasksSecrets(t *testing.T) {
+	diff := diffJSON(Config{"db.password": "old"}, Config{"db.password": "new", "log.level": "INFO"},
+		[]string{"db.password", "log.level"})
+	want := `{"db.password":"******","log.level":"(unset) -> INFO"}`
+	if diff != want {
+		t.Errorf("diff = %s, want %s", diff, want)
+	}
+}
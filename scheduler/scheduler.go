@@ -0,0 +1,352 @@
+// Package scheduler runs jobs on cron schedules. Every job runs in its own goroutine and never overlaps
+// itself; a panic fails the run without stopping the scheduler. Runs can be spread with a random jitter,
+// and runs missed while a job was still running are skipped or run once, by the missed run policy.
+//
+// With a locker, like the consul agent, a singleton job runs on one replica of the service per schedule:
+//
+//	s := scheduler.NewScheduler(scheduler.SetLocker(consulAgent), scheduler.SetLogger(logger))
+//	err := s.AddJob("refresh-prices", "*/15 * * * *", refreshPrices, scheduler.SetSingleton(true),
+//		scheduler.SetJitter(30*time.Second))
+//	s.Start()
+//	defer s.Stop()
+package scheduler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"runtime/debug"
+	"sync"
+	"time"
+
+	"github.com/carwale/golibraries/crashreport"
+	"github.com/carwale/golibraries/gologger"
+	"github.com/carwale/golibraries/goutilities"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// ILocker takes cluster wide locks. It is implemented by *consulagent.ConsulAgent
+type ILocker interface {
+	// TryLock takes the lock of the key if it is free. lockCtx is cancelled when the lock is released or lost,
+	// unlock releases it
+	TryLock(ctx context.Context, key string) (lockCtx context.Context, unlock func(), acquired bool, err error)
+}
+
+// MissedRunPolicy decides what happens to the runs missed while a job was running or the process was paused
+type MissedRunPolicy int
+
+const (
+	// MissedRunSkip skips the missed runs, the job runs next at its next scheduled time
+	MissedRunSkip MissedRunPolicy = iota
+	// MissedRunOnce runs the job once right away for all the missed runs
+	MissedRunOnce
+)
+
+var errPanicked = errors.New("job panicked")
+
+// Option sets a parameter of the scheduler
+type Option func(s *Scheduler)
+
+// SetLocation sets the time zone of the schedules. Default is time.Local
+func SetLocation(location *time.Location) Option {
+	return func(s *Scheduler) {
+		if location != nil {
+			s.location = location
+		}
+	}
+}
+
+// SetLocker sets the locker of the singleton jobs
+func SetLocker(locker ILocker) Option {
+	return func(s *Scheduler) { s.locker = locker }
+}
+
+// SetLockPrefix sets the prefix of the lock keys of the singleton jobs, the key being the prefix and the
+// name of the job. Default is "scheduler/"
+func SetLockPrefix(prefix string) Option {
+	return func(s *Scheduler) { s.lockPrefix = prefix }
+}
+
+// SetCrashReporter reports the panics of the jobs with a crash report instead of only logging the stack
+func SetCrashReporter(reporter *crashreport.Reporter) Option {
+	return func(s *Scheduler) { s.crashReporter = reporter }
+}
+
+// SetLogger sets the logger of the scheduler
+func SetLogger(logger gologger.ILogger) Option {
+	return func(s *Scheduler) { s.logger = logger }
+}
+
+// SetLatencyLogger sets the latency logger used for the metrics of the scheduler
+func SetLatencyLogger(latencyLogger gologger.IMultiLogger) Option {
+	return func(s *Scheduler) { s.latencyLogger = latencyLogger }
+}
+
+// JobOption sets a parameter of a job
+type JobOption func(j *job)
+
+// SetJitter delays every run by a random duration up to jitter, so that the replicas of a service
+// do not all hit a dependency at the same time. Default is 0
+func SetJitter(jitter time.Duration) JobOption {
+	return func(j *job) {
+		if jitter > 0 {
+			j.jitter = jitter
+		}
+	}
+}
+
+// SetMissedRunPolicy sets what happens to the missed runs. Default is MissedRunSkip
+func SetMissedRunPolicy(policy MissedRunPolicy) JobOption {
+	return func(j *job) { j.missedRunPolicy = policy }
+}
+
+// SetSingleton runs the job on one replica per schedule, the one taking the lock of the job. The lock is
+// kept for at least half the time to the next run, so that replicas with skewed clocks do not run the
+// job again. Default is false
+func SetSingleton(flag bool) JobOption {
+	return func(j *job) { j.singleton = flag }
+}
+
+// SetJobTimeout sets the timeout of the context of a run. Default is no timeout
+func SetJobTimeout(timeout time.Duration) JobOption {
+	return func(j *job) {
+		if timeout > 0 {
+			j.timeout = timeout
+		}
+	}
+}
+
+const (
+	runsMetricID        = "SCHEDULER-RUNS"
+	runDurationMetricID = "SCHEDULER-RUN-DURATION"
+)
+
+var schedulerMetrics = gologger.NewMetricSet(func(logger gologger.ILogger) map[string]gologger.IMetricVec {
+	return map[string]gologger.IMetricVec{
+		runsMetricID: gologger.NewCounterMetric(prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "scheduler_runs_total",
+				Help: "Number of runs of the jobs by status (succeeded, failed, panicked, skipped, missed, lock_failed)",
+			},
+			[]string{"Job", "Status"},
+		), logger),
+		runDurationMetricID: gologger.NewHistogramMetric(prometheus.NewHistogramVec(
+			prometheus.HistogramOpts{
+				Name:    "scheduler_run_duration_milliseconds",
+				Help:    "Duration of the runs of the jobs",
+				Buckets: prometheus.ExponentialBuckets(10, 4, 10),
+			},
+			[]string{"Job"},
+		), logger),
+	}
+})
+
+type job struct {
+	name            string
+	schedule        ISchedule
+	run             func(ctx context.Context) error
+	jitter          time.Duration
+	missedRunPolicy MissedRunPolicy
+	singleton       bool
+	timeout         time.Duration
+}
+
+// Scheduler runs jobs on schedules
+type Scheduler struct {
+	location      *time.Location
+	locker        ILocker
+	lockPrefix    string
+	crashReporter *crashreport.Reporter
+	logger        gologger.ILogger
+	latencyLogger gologger.IMultiLogger
+
+	mu      sync.Mutex
+	jobs    map[string]*job
+	ctx     context.Context
+	cancel  context.CancelFunc
+	running sync.WaitGroup
+}
+
+// NewScheduler returns a scheduler without jobs
+func NewScheduler(options ...Option) *Scheduler {
+	s := &Scheduler{location: time.Local, lockPrefix: "scheduler/", jobs: map[string]*job{}}
+	for _, option := range options {
+		option(s)
+	}
+	if s.logger == nil {
+		s.logger = gologger.NewLogger(gologger.SetLogLevel("ERROR"))
+	}
+	if s.latencyLogger == nil {
+		s.latencyLogger = gologger.NewRateLatencyLogger(gologger.SetLogger(s.logger))
+	}
+	schedulerMetrics.AddTo(s.latencyLogger, s.logger)
+	return s
+}
+
+// AddJob adds a job running on the cron expression spec, see ParseSchedule
+func (s *Scheduler) AddJob(name string, spec string, run func(ctx context.Context) error, options ...JobOption) error {
+	schedule, err := ParseSchedule(spec)
+	if err != nil {
+		return err
+	}
+	return s.AddSchedule(name, schedule, run, options...)
+}
+
+// AddSchedule adds a job running on the schedule. Jobs added after Start start right away
+func (s *Scheduler) AddSchedule(name string, schedule ISchedule, run func(ctx context.Context) error, options ...JobOption) error {
+	j := &job{name: name, schedule: schedule, run: run}
+	for _, option := range options {
+		option(j)
+	}
+	if j.singleton && s.locker == nil {
+		return fmt.Errorf("singleton job %s needs a locker", name)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.jobs[name]; ok {
+		return fmt.Errorf("job %s already exists", name)
+	}
+	s.jobs[name] = j
+	if s.ctx != nil {
+		s.startJob(j)
+	}
+	return nil
+}
+
+// Start starts the jobs. It does nothing if the scheduler is started
+func (s *Scheduler) Start() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.ctx != nil {
+		return
+	}
+	s.ctx, s.cancel = context.WithCancel(context.Background())
+	for _, j := range s.jobs {
+		s.startJob(j)
+	}
+}
+
+// Stop cancels the context of the running jobs and waits for them to return. A stopped scheduler cannot
+// be started again
+func (s *Scheduler) Stop() {
+	s.mu.Lock()
+	if s.cancel != nil {
+		s.cancel()
+	}
+	s.mu.Unlock()
+	s.running.Wait()
+}
+
+func (s *Scheduler) startJob(j *job) {
+	s.running.Add(1)
+	goutilities.Go("scheduler-"+j.name, func() {
+		defer s.running.Done()
+		s.loop(s.ctx, j)
+	})
+}
+
+// loop runs the job at its scheduled times until ctx is done
+func (s *Scheduler) loop(ctx context.Context, j *job) {
+	next := j.schedule.Next(time.Now().In(s.location))
+	for {
+		if next.IsZero() {
+			s.logger.LogWarningMessage("Job has no next run", gologger.Pair{Key: "job", Value: j.name})
+			return
+		}
+		delay := time.Until(next)
+		if j.jitter > 0 {
+			delay += time.Duration(rand.Int63n(int64(j.jitter)))
+		}
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+
+		following := j.schedule.Next(next)
+		s.execute(ctx, j, next, following)
+		if ctx.Err() != nil {
+			return
+		}
+		now := time.Now().In(s.location)
+		if !following.IsZero() && following.Before(now) {
+			missed := 0
+			for t := following; !t.IsZero() && t.Before(now) && missed < 1000; t = j.schedule.Next(t) {
+				missed++
+			}
+			s.latencyLogger.IncVal(int64(missed), runsMetricID, j.name, "missed")
+			s.logger.LogWarningMessage("Job missed runs",
+				gologger.Pair{Key: "job", Value: j.name}, gologger.Pair{Key: "missed_runs", Value: fmt.Sprint(missed)})
+			if j.missedRunPolicy == MissedRunOnce {
+				following = now
+			} else {
+				following = j.schedule.Next(now)
+			}
+		}
+		next = following
+	}
+}
+
+// execute runs the job scheduled at scheduled, taking its lock if it is a singleton. A singleton job runs
+// under the context of the lock, so it is cancelled if the lock is lost
+func (s *Scheduler) execute(ctx context.Context, j *job, scheduled time.Time, following time.Time) {
+	runCtx := ctx
+	if j.singleton {
+		lockCtx, unlock, acquired, err := s.locker.TryLock(ctx, s.lockPrefix+j.name)
+		if err != nil {
+			s.latencyLogger.IncVal(1, runsMetricID, j.name, "lock_failed")
+			s.logger.LogErrorMessage("could not take the lock of the job", err, gologger.Pair{Key: "job", Value: j.name})
+			return
+		}
+		if !acquired {
+			s.latencyLogger.IncVal(1, runsMetricID, j.name, "skipped")
+			s.logger.LogDebugf("Job %s runs on another replica", j.name)
+			return
+		}
+		defer func() {
+			if hold := time.Until(scheduled.Add(following.Sub(scheduled) / 2)); !following.IsZero() && hold > 0 {
+				time.AfterFunc(hold, unlock)
+				return
+			}
+			unlock()
+		}()
+		runCtx = lockCtx
+	}
+
+	if j.timeout > 0 {
+		var cancel context.CancelFunc
+		runCtx, cancel = context.WithTimeout(runCtx, j.timeout)
+		defer cancel()
+	}
+	start := time.Now()
+	err := s.call(runCtx, j)
+	s.latencyLogger.Toc(start, runDurationMetricID, j.name)
+	switch {
+	case errors.Is(err, errPanicked):
+		s.latencyLogger.IncVal(1, runsMetricID, j.name, "panicked")
+	case err != nil:
+		s.latencyLogger.IncVal(1, runsMetricID, j.name, "failed")
+		s.logger.LogErrorMessage("Job failed", err, gologger.Pair{Key: "job", Value: j.name})
+	default:
+		s.latencyLogger.IncVal(1, runsMetricID, j.name, "succeeded")
+	}
+}
+
+// call runs the job, turning a panic into errPanicked
+func (s *Scheduler) call(ctx context.Context, j *job) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			if s.crashReporter != nil {
+				s.crashReporter.Report("scheduler job "+j.name, r)
+			} else {
+				s.logger.LogErrorMessage("Job panicked", fmt.Errorf("%v", r),
+					gologger.Pair{Key: "job", Value: j.name}, gologger.Pair{Key: "stack_trace", Value: string(debug.Stack())})
+			}
+			err = errPanicked
+		}
+	}()
+	return j.run(ctx)
+}
@@ -0,0 +1,165 @@
+package scheduler
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestParseScheduleNext(t *testing.T) {
+	ist := time.FixedZone("IST", 5*3600+1800)
+	from := time.Date(2026, 1, 30, 10, 7, 30, 0, ist) // a friday
+	tests := []struct {
+		spec string
+		want time.Time
+	}{
+		{"*/15 * * * *", time.Date(2026, 1, 30, 10, 15, 0, 0, ist)},
+		{"0 9-18 * * mon-fri", time.Date(2026, 1, 30, 11, 0, 0, 0, ist)},
+		{"30 2 * * sat,sun", time.Date(2026, 1, 31, 2, 30, 0, 0, ist)},
+		{"0 0 29 2 *", time.Date(2028, 2, 29, 0, 0, 0, 0, ist)},
+		{"0 0 1 * 1", time.Date(2026, 2, 1, 0, 0, 0, 0, ist)},
+		{"@daily", time.Date(2026, 1, 31, 0, 0, 0, 0, ist)},
+		{"@every 90s", from.Add(90 * time.Second)},
+	}
+	for _, test := range tests {
+		schedule, err := ParseSchedule(test.spec)
+		if err != nil {
+			t.Errorf("ParseSchedule(%q) error = %v", test.spec, err)
+			continue
+		}
+		if got := schedule.Next(from); !got.Equal(test.want) {
+			t.Errorf("%q: Next() = %v, want %v", test.spec, got, test.want)
+		}
+	}
+	for _, spec := range []string{"* * * *", "60 * * * *", "5-1 * * * *", "*/0 * * * *", "@every 1ms"} {
+		if _, err := ParseSchedule(spec); err == nil {
+			t.Errorf("ParseSchedule(%q) accepted an invalid schedule", spec)
+		}
+	}
+}
+
+// interval is a schedule for the tests, as cron schedules run at most once a minute
+type interval time.Duration
+
+func (i interval) Next(t time.Time) time.Time {
+	return t.Add(time.Duration(i))
+}
+
+func TestSchedulerSurvivesPanics(t *testing.T) {
+	s := NewScheduler()
+	var runs int32
+	err := s.AddSchedule("panicking", interval(20*time.Millisecond), func(ctx context.Context) error {
+		if atomic.AddInt32(&runs, 1) == 1 {
+			panic("first run")
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.Start()
+	time.Sleep(110 * time.Millisecond)
+	s.Stop()
+	if runs < 3 {
+		t.Errorf("job ran %d times, want at least 3", runs)
+	}
+}
+
+func TestSchedulerSkipsMissedRuns(t *testing.T) {
+	s := NewScheduler()
+	var runs int32
+	s.AddSchedule("slow", interval(20*time.Millisecond), func(ctx context.Context) error {
+		atomic.AddInt32(&runs, 1)
+		time.Sleep(70 * time.Millisecond)
+		return nil
+	})
+	s.Start()
+	time.Sleep(150 * time.Millisecond)
+	s.Stop()
+	// the runs missed during the first run are skipped, so the job runs twice instead of 7 times
+	if runs != 2 {
+		t.Errorf("job ran %d times, want 2", runs)
+	}
+}
+
+type fakeLocker struct {
+	mu   sync.Mutex
+	held map[string]bool
+	// lost is returned as the context of the locks if it is set
+	lost context.Context
+}
+
+func (l *fakeLocker) TryLock(ctx context.Context, key string) (context.Context, func(), bool, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.held[key] {
+		return nil, nil, false, nil
+	}
+	l.held[key] = true
+	lockCtx, cancel := context.WithCancel(ctx)
+	if l.lost != nil {
+		lockCtx, cancel = l.lost, func() {}
+	}
+	return lockCtx, func() {
+		cancel()
+		l.mu.Lock()
+		defer l.mu.Unlock()
+		delete(l.held, key)
+	}, true, nil
+}
+
+func TestSingletonJobRunsOnOneReplica(t *testing.T) {
+	locker := &fakeLocker{held: map[string]bool{}}
+	var runs int32
+	job := func(ctx context.Context) error {
+		atomic.AddInt32(&runs, 1)
+		time.Sleep(10 * time.Millisecond)
+		return nil
+	}
+	replicas := []*Scheduler{NewScheduler(SetLocker(locker)), NewScheduler(SetLocker(locker))}
+	for _, s := range replicas {
+		if err := s.AddSchedule("report", interval(100*time.Millisecond), job, SetSingleton(true)); err != nil {
+			t.Fatal(err)
+		}
+		s.Start()
+	}
+	time.Sleep(150 * time.Millisecond)
+	for _, s := range replicas {
+		s.Stop()
+	}
+	if runs != 1 {
+		t.Errorf("job ran %d times, want 1", runs)
+	}
+	if err := NewScheduler().AddJob("report", "@hourly", job, SetSingleton(true)); err == nil {
+		t.Error("AddJob() accepted a singleton job without a locker")
+	}
+}
+
+func TestSingletonJobIsCancelledWhenTheLockIsLost(t *testing.T) {
+	lost, lose := context.WithCancel(context.Background())
+	locker := &fakeLocker{held: map[string]bool{}, lost: lost}
+	cancelled := make(chan struct{})
+	var once sync.Once
+	job := func(ctx context.Context) error {
+		lose()
+		select {
+		case <-ctx.Done():
+			once.Do(func() { close(cancelled) })
+		case <-time.After(time.Second):
+		}
+		return ctx.Err()
+	}
+	s := NewScheduler(SetLocker(locker))
+	if err := s.AddSchedule("report", interval(50*time.Millisecond), job, SetSingleton(true)); err != nil {
+		t.Fatal(err)
+	}
+	s.Start()
+	defer s.Stop()
+	select {
+	case <-cancelled:
+	case <-time.After(2 * time.Second):
+		t.Error("the job was not cancelled when its lock was lost")
+	}
+}
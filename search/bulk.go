@@ -0,0 +1,138 @@
+package search
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/carwale/golibraries/workerpool"
+)
+
+// Bulk actions
+const (
+	ActionIndex  = "index"
+	ActionCreate = "create"
+	ActionUpdate = "update"
+	ActionDelete = "delete"
+)
+
+// BulkItem is an action of a bulk request. Document is encoded as json; for updates it is the body of the
+// update, e.g. {"doc": {...}}, and for deletes it is not used
+type BulkItem struct {
+	Action   string
+	Index    string
+	ID       string
+	Document interface{}
+}
+
+// BulkItemError is the error of an item of a bulk request
+type BulkItemError struct {
+	Status int
+	Type   string
+	Reason string
+}
+
+// Error returns the status, type and reason of the error
+func (e *BulkItemError) Error() string {
+	return fmt.Sprintf("bulk item failed with status %d: %s: %s", e.Status, e.Type, e.Reason)
+}
+
+type bulkResponse struct {
+	Errors bool                                `json:"errors"`
+	Items  []map[string]bulkResponseItemResult `json:"items"`
+}
+
+type bulkResponseItemResult struct {
+	Status int `json:"status"`
+	Error  *struct {
+		Type   string `json:"type"`
+		Reason string `json:"reason"`
+	} `json:"error"`
+}
+
+// Bulk sends the items in one bulk request. The error is a *workerpool.BatchError with the error of every
+// item if only some items failed, and the error of the request otherwise
+func (c *Client) Bulk(ctx context.Context, items []BulkItem) error {
+	if len(items) == 0 {
+		return nil
+	}
+	var body bytes.Buffer
+	encoder := json.NewEncoder(&body)
+	for _, item := range items {
+		action := item.Action
+		if action == "" {
+			action = ActionIndex
+		}
+		meta := map[string]map[string]string{action: {"_index": item.Index}}
+		if item.ID != "" {
+			meta[action]["_id"] = item.ID
+		}
+		if err := encoder.Encode(meta); err != nil {
+			return err
+		}
+		if action == ActionDelete {
+			continue
+		}
+		if err := encoder.Encode(item.Document); err != nil {
+			return fmt.Errorf("could not encode document %s of index %s: %w", item.ID, item.Index, err)
+		}
+	}
+
+	var response bulkResponse
+	if err := c.Do(ctx, "bulk", http.MethodPost, "/_bulk", body.Bytes(), &response); err != nil {
+		c.latencyLogger.IncVal(int64(len(items)), bulkItemsMetricID, "failed")
+		return err
+	}
+	if len(response.Items) != len(items) {
+		c.latencyLogger.IncVal(int64(len(items)), bulkItemsMetricID, "failed")
+		return fmt.Errorf("bulk response has %d items for %d actions", len(response.Items), len(items))
+	}
+	batchErr := workerpool.NewBatchError(len(items))
+	for i, result := range response.Items {
+		for _, itemResult := range result {
+			if itemResult.Error != nil || itemResult.Status >= http.StatusBadRequest {
+				itemErr := &BulkItemError{Status: itemResult.Status}
+				if itemResult.Error != nil {
+					itemErr.Type = itemResult.Error.Type
+					itemErr.Reason = itemResult.Error.Reason
+				}
+				batchErr.Errors[i] = itemErr
+			}
+		}
+	}
+	failed := batchErr.Failed()
+	c.latencyLogger.IncVal(int64(len(items)-failed), bulkItemsMetricID, "succeeded")
+	if failed == 0 {
+		return nil
+	}
+	c.latencyLogger.IncVal(int64(failed), bulkItemsMetricID, "failed")
+	return batchErr
+}
+
+// BulkIndexer collects the items added from any goroutine into bulk requests, which are sent when they have
+// the batch size items or when the first item is older than the max age (see workerpool.BatchDispatcher)
+type BulkIndexer struct {
+	dispatcher *workerpool.BatchDispatcher[BulkItem]
+}
+
+// NewBulkIndexer returns a started bulk indexer. name is the label of the batch metrics
+//
+//	indexer := client.NewBulkIndexer("stocks", workerpool.SetBatchSize(500), workerpool.SetBatchMaxAge(2*time.Second))
+//	defer indexer.Stop()
+//	future := indexer.Add(ctx, search.BulkItem{Index: "stocks", ID: stock.ID, Document: stock})
+func (c *Client) NewBulkIndexer(name string, options ...workerpool.BatchOption) *BulkIndexer {
+	options = append([]workerpool.BatchOption{workerpool.SetBatchLogger(c.logger)}, options...)
+	return &BulkIndexer{dispatcher: workerpool.NewBatchDispatcher(name, c.Bulk, options...)}
+}
+
+// Add queues the item. The future completes with the result of the item once its bulk request is sent
+func (b *BulkIndexer) Add(ctx context.Context, item BulkItem) *workerpool.Future[struct{}] {
+	return b.dispatcher.Submit(ctx, item)
+}
+
+// Stop sends the queued items and waits for the bulk requests
+func (b *BulkIndexer) Stop() {
+	b.dispatcher.Stop()
+}
@@ -0,0 +1,87 @@
+package search
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/url"
+)
+
+// Hit is a document found by a search
+type Hit struct {
+	Index  string          `json:"_index"`
+	ID     string          `json:"_id"`
+	Score  float64         `json:"_score"`
+	Source json.RawMessage `json:"_source"`
+}
+
+// SearchResult is the response of a search. Aggregations are kept encoded, decode them into the
+// types of the aggregations of the query
+type SearchResult struct {
+	Took     int  `json:"took"`
+	TimedOut bool `json:"timed_out"`
+	Hits     struct {
+		Total struct {
+			Value    int64  `json:"value"`
+			Relation string `json:"relation"`
+		} `json:"total"`
+		Hits []Hit `json:"hits"`
+	} `json:"hits"`
+	Aggregations map[string]json.RawMessage `json:"aggregations,omitempty"`
+}
+
+// Index indexes the json encoding of doc with the id, replacing the document if it exists.
+// An empty id lets the cluster generate one
+func (c *Client) Index(ctx context.Context, index string, id string, doc interface{}) error {
+	body, err := json.Marshal(doc)
+	if err != nil {
+		return err
+	}
+	if id == "" {
+		return c.Do(ctx, "index", http.MethodPost, "/"+url.PathEscape(index)+"/_doc", body, nil)
+	}
+	return c.Do(ctx, "index", http.MethodPut, documentPath(index, id), body, nil)
+}
+
+// Get decodes the source of the document with the id into v. It returns false if the document does not exist
+func (c *Client) Get(ctx context.Context, index string, id string, v interface{}) (bool, error) {
+	var response struct {
+		Found  bool            `json:"found"`
+		Source json.RawMessage `json:"_source"`
+	}
+	err := c.Do(ctx, "get", http.MethodGet, documentPath(index, id), nil, &response)
+	if IsNotFound(err) {
+		return false, nil
+	}
+	if err != nil || !response.Found {
+		return false, err
+	}
+	return true, json.Unmarshal(response.Source, v)
+}
+
+// Delete deletes the document with the id. Deleting a document that does not exist is not an error
+func (c *Client) Delete(ctx context.Context, index string, id string) error {
+	err := c.Do(ctx, "delete", http.MethodDelete, documentPath(index, id), nil, nil)
+	if IsNotFound(err) {
+		return nil
+	}
+	return err
+}
+
+// Search runs the query, the json body of a _search request, on the index. index can be a comma
+// separated list of indices or a pattern
+func (c *Client) Search(ctx context.Context, index string, query interface{}) (*SearchResult, error) {
+	body, err := json.Marshal(query)
+	if err != nil {
+		return nil, err
+	}
+	var result SearchResult
+	if err := c.Do(ctx, "search", http.MethodPost, "/"+url.PathEscape(index)+"/_search", body, &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+func documentPath(index string, id string) string {
+	return "/" + url.PathEscape(index) + "/_doc/" + url.PathEscape(id)
+}
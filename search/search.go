@@ -0,0 +1,257 @@
+// Package search is a thin client for the rest api of Elasticsearch and OpenSearch. It retries failed requests
+// with a backoff, logs slow requests and records request metrics, so that services do not build the requests
+// and the retries themselves.
+//
+// Documents are indexed one by one with Index, in bulk with Bulk, or in batches built in the background by a
+// BulkIndexer
+//
+//	client := search.NewClient([]string{"http://es-1:9200", "http://es-2:9200"}, search.SetLogger(logger))
+//	err := client.Index(ctx, "stocks", stock.ID, stock)
+//	result, err := client.Search(ctx, "stocks", map[string]interface{}{"query": query})
+package search
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/carwale/golibraries/gologger"
+	"github.com/carwale/golibraries/reconnect"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// DefaultRetryPolicy retries a request twice, waiting 100 milliseconds and then 200 milliseconds
+var DefaultRetryPolicy = reconnect.Policy{InitialDelay: 100 * time.Millisecond, MaxDelay: 2 * time.Second, Multiplier: 2, Jitter: 0.2, MaxAttempts: 3}
+
+// ResponseError is the error of a request the cluster responded to with an error status
+type ResponseError struct {
+	Status int
+	// Type and Reason are read from the error of the response, e.g. index_not_found_exception
+	Type   string
+	Reason string
+}
+
+// Error returns the status, type and reason of the error
+func (e *ResponseError) Error() string {
+	if e.Type == "" {
+		return "search request failed with status " + strconv.Itoa(e.Status)
+	}
+	return fmt.Sprintf("search request failed with status %d: %s: %s", e.Status, e.Type, e.Reason)
+}
+
+// IsNotFound returns true if err is a response error with the 404 status
+func IsNotFound(err error) bool {
+	var responseErr *ResponseError
+	return errors.As(err, &responseErr) && responseErr.Status == http.StatusNotFound
+}
+
+// Option sets a parameter of the client
+type Option func(c *Client)
+
+// SetHTTPClient sets the http client of the requests. Default is a client with a 30 second timeout
+func SetHTTPClient(httpClient *http.Client) Option {
+	return func(c *Client) { c.httpClient = httpClient }
+}
+
+// SetBasicAuth sets the credentials of the requests
+func SetBasicAuth(username, password string) Option {
+	return func(c *Client) {
+		c.username = username
+		c.password = password
+	}
+}
+
+// SetRetryPolicy sets the retries of the requests which fail with a network error or with the 429, 502, 503
+// or 504 status. Retries go to the next address. Default is DefaultRetryPolicy
+func SetRetryPolicy(policy reconnect.Policy) Option {
+	return func(c *Client) { c.retryPolicy = policy }
+}
+
+// SetSlowRequestThreshold logs the requests taking longer than threshold as warnings. Default is 1 second,
+// 0 disables the logs
+func SetSlowRequestThreshold(threshold time.Duration) Option {
+	return func(c *Client) { c.slowRequest = threshold }
+}
+
+// SetLogger sets the logger of the client
+func SetLogger(logger gologger.ILogger) Option {
+	return func(c *Client) { c.logger = logger }
+}
+
+// SetLatencyLogger sets the latency logger used for the metrics of the client
+func SetLatencyLogger(latencyLogger gologger.IMultiLogger) Option {
+	return func(c *Client) { c.latencyLogger = latencyLogger }
+}
+
+const (
+	requestLatencyMetricID = "SEARCH-REQUEST-LATENCY"
+	requestErrorsMetricID  = "SEARCH-REQUEST-ERRORS"
+	bulkItemsMetricID      = "SEARCH-BULK-ITEMS"
+)
+
+var searchMetrics = gologger.NewMetricSet(func(logger gologger.ILogger) map[string]gologger.IMetricVec {
+	return map[string]gologger.IMetricVec{
+		requestLatencyMetricID: gologger.NewHistogramMetric(prometheus.NewHistogramVec(
+			prometheus.HistogramOpts{
+				Name: "search_request_latency_milliseconds",
+				Help: "Latency of the requests to elasticsearch by operation, including the retries",
+			},
+			[]string{"Operation"},
+		), logger),
+		requestErrorsMetricID: gologger.NewCounterMetric(prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "search_request_errors_total",
+				Help: "Number of failed requests to elasticsearch by operation and status (0 for network errors)",
+			},
+			[]string{"Operation", "Status"},
+		), logger),
+		bulkItemsMetricID: gologger.NewCounterMetric(prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "search_bulk_items_total",
+				Help: "Number of the items of bulk requests by status (succeeded, failed)",
+			},
+			[]string{"Status"},
+		), logger),
+	}
+})
+
+// Client sends requests to the nodes of a cluster in turn
+type Client struct {
+	addresses     []string
+	next          uint32
+	httpClient    *http.Client
+	username      string
+	password      string
+	retryPolicy   reconnect.Policy
+	slowRequest   time.Duration
+	logger        gologger.ILogger
+	latencyLogger gologger.IMultiLogger
+}
+
+// NewClient returns a client for the nodes at the addresses, e.g. http://es-1:9200. It panics if there are
+// no addresses
+func NewClient(addresses []string, options ...Option) *Client {
+	if len(addresses) == 0 {
+		panic("search: no addresses")
+	}
+	c := &Client{
+		httpClient:  &http.Client{Timeout: 30 * time.Second},
+		retryPolicy: DefaultRetryPolicy,
+		slowRequest: time.Second,
+	}
+	for _, address := range addresses {
+		c.addresses = append(c.addresses, strings.TrimSuffix(address, "/"))
+	}
+	for _, option := range options {
+		option(c)
+	}
+	if c.logger == nil {
+		c.logger = gologger.NewLogger(gologger.SetLogLevel("ERROR"))
+	}
+	if c.latencyLogger == nil {
+		c.latencyLogger = gologger.NewRateLatencyLogger(gologger.SetLogger(c.logger))
+	}
+	searchMetrics.AddTo(c.latencyLogger, c.logger)
+	return c
+}
+
+// Do sends the request with the body, retrying it on network errors and on the 429, 502, 503 and 504 statuses,
+// and decodes the json response into v if v is not nil. operation is the label of the metrics, e.g. search.
+// A response with an error status is returned as a *ResponseError
+func (c *Client) Do(ctx context.Context, operation string, method string, path string, body []byte, v interface{}) error {
+	start := time.Now()
+	backoff := c.retryPolicy.NewBackoff()
+	attempts := c.retryPolicy.MaxAttempts
+	if attempts <= 0 {
+		attempts = 1
+	}
+	var err error
+	for attempt := 1; ; attempt++ {
+		var retry bool
+		retry, err = c.send(ctx, method, path, body, v)
+		if err == nil || !retry || attempt >= attempts || !backoff.Sleep(ctx) {
+			break
+		}
+		c.logger.LogDebugf("Retrying %s request to %s after %v", operation, path, err)
+	}
+	c.latencyLogger.Toc(start, requestLatencyMetricID, operation)
+	if err != nil {
+		status := 0
+		var responseErr *ResponseError
+		if errors.As(err, &responseErr) {
+			status = responseErr.Status
+		}
+		if status != http.StatusNotFound {
+			c.latencyLogger.IncVal(1, requestErrorsMetricID, operation, strconv.Itoa(status))
+		}
+	}
+	if elapsed := time.Since(start); c.slowRequest > 0 && elapsed >= c.slowRequest {
+		c.logger.LogWarningMessage("Slow search request",
+			gologger.Pair{Key: "search_operation", Value: operation},
+			gologger.Pair{Key: "search_path", Value: path},
+			gologger.Pair{Key: "elapsed_ms", Value: strconv.FormatInt(elapsed.Milliseconds(), 10)})
+	}
+	return err
+}
+
+// send sends the request to the next address. It returns true if the request can be retried
+func (c *Client) send(ctx context.Context, method string, path string, body []byte, v interface{}) (bool, error) {
+	address := c.addresses[int(atomic.AddUint32(&c.next, 1)-1)%len(c.addresses)]
+	var reader io.Reader
+	if body != nil {
+		reader = bytes.NewReader(body)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, address+path, reader)
+	if err != nil {
+		return false, err
+	}
+	if body != nil {
+		if strings.Contains(path, "/_bulk") {
+			req.Header.Set("Content-Type", "application/x-ndjson")
+		} else {
+			req.Header.Set("Content-Type", "application/json")
+		}
+	}
+	if c.username != "" {
+		req.SetBasicAuth(c.username, c.password)
+	}
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return ctx.Err() == nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= http.StatusBadRequest {
+		responseErr := &ResponseError{Status: resp.StatusCode}
+		var errorBody struct {
+			Error struct {
+				Type   string `json:"type"`
+				Reason string `json:"reason"`
+			} `json:"error"`
+		}
+		if json.NewDecoder(io.LimitReader(resp.Body, 1<<16)).Decode(&errorBody) == nil {
+			responseErr.Type = errorBody.Error.Type
+			responseErr.Reason = errorBody.Error.Reason
+		}
+		switch resp.StatusCode {
+		case http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+			return true, responseErr
+		}
+		return false, responseErr
+	}
+	if v == nil {
+		io.Copy(io.Discard, resp.Body)
+		return false, nil
+	}
+	if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+		return false, fmt.Errorf("could not decode search response: %w", err)
+	}
+	return false, nil
+}
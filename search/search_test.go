@@ -0,0 +1,133 @@
+package search
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/carwale/golibraries/reconnect"
+	"github.com/carwale/golibraries/workerpool"
+)
+
+func newTestClient(t *testing.T, handler http.HandlerFunc) *Client {
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+	return NewClient([]string{server.URL + "/"},
+		SetRetryPolicy(reconnect.Policy{InitialDelay: time.Millisecond, MaxDelay: time.Millisecond, Multiplier: 1, MaxAttempts: 3}))
+}
+
+func TestDoRetriesUnavailable(t *testing.T) {
+	var calls int32
+	client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&calls, 1) < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		io.WriteString(w, `{"found":true,"_source":{"name":"swift"}}`)
+	})
+	var doc struct{ Name string }
+	found, err := client.Get(context.Background(), "stocks", "1", &doc)
+	if err != nil || !found || doc.Name != "swift" {
+		t.Fatalf("Get() = %v, %v, %+v", found, err, doc)
+	}
+	if calls != 3 {
+		t.Errorf("calls = %d, want 3", calls)
+	}
+}
+
+func TestDoDoesNotRetryBadRequest(t *testing.T) {
+	var calls int32
+	client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		w.WriteHeader(http.StatusBadRequest)
+		io.WriteString(w, `{"error":{"type":"parsing_exception","reason":"unknown query"}}`)
+	})
+	_, err := client.Search(context.Background(), "stocks", map[string]interface{}{"query": "bad"})
+	var responseErr *ResponseError
+	if !errors.As(err, &responseErr) || responseErr.Type != "parsing_exception" {
+		t.Fatalf("Search() error = %v, want a parsing_exception response error", err)
+	}
+	if calls != 1 {
+		t.Errorf("calls = %d, want 1", calls)
+	}
+}
+
+func TestGetNotFound(t *testing.T) {
+	client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.EscapedPath() != "/stocks/_doc/a%2Fb" {
+			t.Errorf("path = %s", r.URL.EscapedPath())
+		}
+		w.WriteHeader(http.StatusNotFound)
+		io.WriteString(w, `{"found":false}`)
+	})
+	var doc struct{}
+	found, err := client.Get(context.Background(), "stocks", "a/b", &doc)
+	if err != nil || found {
+		t.Fatalf("Get() = %v, %v, want false, nil", found, err)
+	}
+	if err := client.Delete(context.Background(), "stocks", "a/b"); err != nil {
+		t.Fatalf("Delete() error = %v", err)
+	}
+}
+
+func TestSearchDecodesHits(t *testing.T) {
+	client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.URL.Path != "/stocks/_search" {
+			t.Errorf("request = %s %s", r.Method, r.URL.Path)
+		}
+		io.WriteString(w, `{"took":3,"hits":{"total":{"value":1,"relation":"eq"},"hits":[{"_index":"stocks","_id":"1","_score":1.5,"_source":{"name":"swift"}}]}}`)
+	})
+	result, err := client.Search(context.Background(), "stocks", map[string]interface{}{"query": map[string]interface{}{"match_all": struct{}{}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Hits.Total.Value != 1 || len(result.Hits.Hits) != 1 || result.Hits.Hits[0].ID != "1" {
+		t.Errorf("result = %+v", result)
+	}
+}
+
+func TestBulkReportsItemErrors(t *testing.T) {
+	client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Content-Type") != "application/x-ndjson" {
+			t.Errorf("content type = %s", r.Header.Get("Content-Type"))
+		}
+		body, _ := io.ReadAll(r.Body)
+		if lines := strings.Count(string(body), "\n"); lines != 3 {
+			t.Errorf("bulk body has %d lines, want 3:\n%s", lines, body)
+		}
+		io.WriteString(w, `{"errors":true,"items":[{"index":{"status":201}},{"delete":{"status":409,"error":{"type":"version_conflict_engine_exception","reason":"conflict"}}}]}`)
+	})
+	err := client.Bulk(context.Background(), []BulkItem{
+		{Index: "stocks", ID: "1", Document: map[string]string{"name": "swift"}},
+		{Action: ActionDelete, Index: "stocks", ID: "2"},
+	})
+	var batchErr *workerpool.BatchError
+	if !errors.As(err, &batchErr) {
+		t.Fatalf("Bulk() error = %v, want a batch error", err)
+	}
+	var itemErr *BulkItemError
+	if batchErr.Errors[0] != nil || !errors.As(batchErr.Errors[1], &itemErr) || itemErr.Status != http.StatusConflict {
+		t.Errorf("item errors = %v", batchErr.Errors)
+	}
+}
+
+func TestBulkIndexer(t *testing.T) {
+	client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, `{"errors":false,"items":[{"index":{"status":201}},{"index":{"status":201}}]}`)
+	})
+	indexer := client.NewBulkIndexer("test", workerpool.SetBatchSize(2), workerpool.SetBatchMaxAge(time.Second))
+	defer indexer.Stop()
+	first := indexer.Add(context.Background(), BulkItem{Index: "stocks", ID: "1", Document: struct{}{}})
+	second := indexer.Add(context.Background(), BulkItem{Index: "stocks", ID: "2", Document: struct{}{}})
+	for _, future := range []*workerpool.Future[struct{}]{first, second} {
+		if _, err := future.Get(context.Background()); err != nil {
+			t.Errorf("Get() error = %v", err)
+		}
+	}
+}
@@ -0,0 +1,258 @@
+// Package secrets reads the credentials of a service from the environment, consul or vault behind one
+// interface, so that a service can move its credentials to vault without changing the code that uses them.
+//
+// The Manager caches the secrets, reads them again periodically to pick up the rotations and calls the
+// callbacks of the rotated secrets. Every secret read is masked in the logs of the gologger CustomLoggers.
+//
+//	manager := secrets.NewManager(secrets.NewVaultProvider("https://vault:8200", "secret",
+//		secrets.SetVaultKubernetesAuth("kubernetes", "stocks")), secrets.SetLogger(logger))
+//	go manager.Run(ctx)
+//	manager.OnRotate("stocks/rabbitmq#password", func(password string) { ... })
+//	rabbitmq.NewRabbitMQManager(logger, servers, queue, "", "",
+//		rabbitmq.SetCredentialsSecrets(manager, "stocks/rabbitmq#username", "stocks/rabbitmq#password"))
+package secrets
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/carwale/golibraries/gologger"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// ErrNotFound is returned when the secret does not exist
+var ErrNotFound = errors.New("secret not found")
+
+// IProvider reads a secret by name. The format of the name depends on the provider
+type IProvider interface {
+	Get(ctx context.Context, name string) (string, error)
+}
+
+// ProviderFunc is a function implementing IProvider
+type ProviderFunc func(ctx context.Context, name string) (string, error)
+
+// Get calls f
+func (f ProviderFunc) Get(ctx context.Context, name string) (string, error) {
+	return f(ctx, name)
+}
+
+// EnvProvider reads the secrets from the environment variables. The name is upper cased with the slashes,
+// dots and dashes replaced with underscores and prefixed with prefix, so stocks/rabbitmq.password is
+// read from APP_STOCKS_RABBITMQ_PASSWORD for the APP_ prefix
+func EnvProvider(prefix string) IProvider {
+	replacer := strings.NewReplacer("/", "_", ".", "_", "-", "_")
+	return ProviderFunc(func(ctx context.Context, name string) (string, error) {
+		value, ok := os.LookupEnv(prefix + strings.ToUpper(replacer.Replace(name)))
+		if !ok {
+			return "", ErrNotFound
+		}
+		return value, nil
+	})
+}
+
+// ConsulProvider reads the secrets from the keys under prefix with get, e.g. the GetValue method of the
+// consul agent. A missing key and a failed read are both ErrNotFound, as GetValue does not tell them apart
+func ConsulProvider(get func(key string) []byte, prefix string) IProvider {
+	return ProviderFunc(func(ctx context.Context, name string) (string, error) {
+		value := get(prefix + name)
+		if value == nil {
+			return "", ErrNotFound
+		}
+		return string(value), nil
+	})
+}
+
+// Option sets a parameter of the manager
+type Option func(m *Manager)
+
+// SetRefreshInterval sets how often Run reads the cached secrets again. Default is 5 minutes
+func SetRefreshInterval(interval time.Duration) Option {
+	return func(m *Manager) { m.refreshInterval = interval }
+}
+
+// SetLogger sets the logger of the manager
+func SetLogger(logger gologger.ILogger) Option {
+	return func(m *Manager) { m.logger = logger }
+}
+
+// SetLatencyLogger sets the latency logger used for the metrics of the manager
+func SetLatencyLogger(latencyLogger gologger.IMultiLogger) Option {
+	return func(m *Manager) { m.latencyLogger = latencyLogger }
+}
+
+const (
+	readsMetricID     = "SECRETS-READS"
+	rotationsMetricID = "SECRETS-ROTATIONS"
+)
+
+var secretsMetrics = gologger.NewMetricSet(func(logger gologger.ILogger) map[string]gologger.IMetricVec {
+	return map[string]gologger.IMetricVec{
+		readsMetricID: gologger.NewCounterMetric(prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "secrets_reads_total",
+				Help: "Number of secrets read from the provider by status (ok, not_found, error)",
+			},
+			[]string{"Status"},
+		), logger),
+		rotationsMetricID: gologger.NewCounterMetric(prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "secrets_rotations_total",
+				Help: "Number of secrets whose value changed since they were last read",
+			},
+			[]string{},
+		), logger),
+	}
+})
+
+// Manager caches the secrets of a provider and follows their rotations. It implements IProvider
+type Manager struct {
+	provider        IProvider
+	refreshInterval time.Duration
+	logger          gologger.ILogger
+	latencyLogger   gologger.IMultiLogger
+
+	mu        sync.Mutex
+	values    map[string]string
+	callbacks map[string][]func(value string)
+}
+
+// NewManager returns a manager reading the secrets from the provider
+func NewManager(provider IProvider, options ...Option) *Manager {
+	m := &Manager{
+		provider:        provider,
+		refreshInterval: 5 * time.Minute,
+		values:          map[string]string{},
+		callbacks:       map[string][]func(value string){},
+	}
+	for _, option := range options {
+		option(m)
+	}
+	if m.refreshInterval <= 0 {
+		panic("secrets: refresh interval must be positive")
+	}
+	if m.logger == nil {
+		m.logger = gologger.NewLogger(gologger.SetLogLevel("ERROR"))
+	}
+	if m.latencyLogger == nil {
+		m.latencyLogger = gologger.NewRateLatencyLogger(gologger.SetLogger(m.logger))
+	}
+	secretsMetrics.AddTo(m.latencyLogger, m.logger)
+	return m
+}
+
+// Get returns the secret, from the cache if it was read before
+func (m *Manager) Get(ctx context.Context, name string) (string, error) {
+	m.mu.Lock()
+	value, ok := m.values[name]
+	m.mu.Unlock()
+	if ok {
+		return value, nil
+	}
+	value, err := m.read(ctx, name)
+	if err != nil {
+		return "", err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if cached, ok := m.values[name]; ok {
+		return cached, nil
+	}
+	m.values[name] = value
+	return value, nil
+}
+
+// OnRotate calls callback with the new value of the secret when Refresh finds that it changed. The secret is
+// refreshed from then on even if Get was not called for it
+func (m *Manager) OnRotate(name string, callback func(value string)) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.callbacks[name] = append(m.callbacks[name], callback)
+}
+
+// Refresh reads the cached secrets and the secrets with callbacks again, and calls the callbacks of the
+// secrets whose value changed. The previous value of a secret is kept if it cannot be read
+func (m *Manager) Refresh(ctx context.Context) error {
+	m.mu.Lock()
+	names := make([]string, 0, len(m.values)+len(m.callbacks))
+	for name := range m.values {
+		names = append(names, name)
+	}
+	for name := range m.callbacks {
+		if _, ok := m.values[name]; !ok {
+			names = append(names, name)
+		}
+	}
+	m.mu.Unlock()
+
+	var errs []error
+	for _, name := range names {
+		value, err := m.read(ctx, name)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("could not read the secret %s: %w", name, err))
+			continue
+		}
+		m.mu.Lock()
+		old, cached := m.values[name]
+		m.values[name] = value
+		callbacks := m.callbacks[name]
+		m.mu.Unlock()
+		if cached && old != value {
+			m.latencyLogger.IncVal(1, rotationsMetricID)
+			m.logger.LogInfoMessage("Secret rotated", gologger.Pair{Key: "secret_name", Value: name})
+			for _, callback := range callbacks {
+				callback(value)
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Run refreshes the secrets every refresh interval until ctx is done. Errors are logged
+func (m *Manager) Run(ctx context.Context) {
+	ticker := time.NewTicker(m.refreshInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := m.Refresh(ctx); err != nil {
+				m.logger.LogError("could not refresh the secrets", err)
+			}
+		}
+	}
+}
+
+// read reads the secret from the provider and masks it in the logs
+func (m *Manager) read(ctx context.Context, name string) (string, error) {
+	value, err := m.provider.Get(ctx, name)
+	switch {
+	case errors.Is(err, ErrNotFound):
+		m.latencyLogger.IncVal(1, readsMetricID, "not_found")
+		return "", err
+	case err != nil:
+		m.latencyLogger.IncVal(1, readsMetricID, "error")
+		return "", err
+	}
+	m.latencyLogger.IncVal(1, readsMetricID, "ok")
+	gologger.AddSecretValue(value)
+	return value, nil
+}
+
+// MustGet returns the secret or panics, for the options of the constructors which read their credentials
+// from a provider
+func MustGet(provider IProvider, name string) string {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	value, err := provider.Get(ctx, name)
+	if err != nil {
+		panic(fmt.Sprintf("secrets: could not read the secret %s: %v", name, err))
+	}
+	gologger.AddSecretValue(value)
+	return value
+}
@@ -0,0 +1,99 @@
+package secrets
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestEnvProvider(t *testing.T) {
+	t.Setenv("APP_STOCKS_RABBITMQ_PASSWORD", "guest")
+	provider := EnvProvider("APP_")
+	if value, err := provider.Get(context.Background(), "stocks/rabbitmq.password"); err != nil || value != "guest" {
+		t.Errorf("Get() = %q, %v", value, err)
+	}
+	if _, err := provider.Get(context.Background(), "stocks/missing"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Get() of a missing secret error = %v", err)
+	}
+}
+
+func TestManagerCallsRotationCallbacks(t *testing.T) {
+	values := map[string]string{"db/password": "first"}
+	m := NewManager(ProviderFunc(func(ctx context.Context, name string) (string, error) {
+		if value, ok := values[name]; ok {
+			return value, nil
+		}
+		return "", ErrNotFound
+	}))
+	var rotated []string
+	m.OnRotate("db/password", func(value string) { rotated = append(rotated, value) })
+
+	if value, err := m.Get(context.Background(), "db/password"); err != nil || value != "first" {
+		t.Fatalf("Get() = %q, %v", value, err)
+	}
+	values["db/password"] = "second"
+	if value, _ := m.Get(context.Background(), "db/password"); value != "first" {
+		t.Errorf("Get() = %q, want the cached value", value)
+	}
+	if err := m.Refresh(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	delete(values, "db/password")
+	if err := m.Refresh(context.Background()); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Refresh() error = %v, want %v", err, ErrNotFound)
+	}
+	if value, _ := m.Get(context.Background(), "db/password"); value != "second" || len(rotated) != 1 || rotated[0] != "second" {
+		t.Errorf("Get() = %q and callbacks got %v, want second", value, rotated)
+	}
+}
+
+func TestVaultProviderKubernetesAuth(t *testing.T) {
+	logins := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/v1/auth/kubernetes/login":
+			var login map[string]string
+			json.NewDecoder(r.Body).Decode(&login)
+			if login["role"] != "stocks" || login["jwt"] != "pod-jwt" {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			logins++
+			w.Write([]byte(`{"auth":{"client_token":"pod-token","lease_duration":3600}}`))
+		case "/v1/secret/data/stocks/rabbitmq":
+			if r.Header.Get("X-Vault-Token") != "pod-token" {
+				w.WriteHeader(http.StatusForbidden)
+				w.Write([]byte(`{"errors":["permission denied"]}`))
+				return
+			}
+			w.Write([]byte(`{"data":{"data":{"username":"stocks","password":"s3cret"}}}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`{"errors":[]}`))
+		}
+	}))
+	defer server.Close()
+	jwtPath := filepath.Join(t.TempDir(), "token")
+	os.WriteFile(jwtPath, []byte("pod-jwt\n"), 0600)
+
+	v := NewVaultProvider(server.URL, "secret", SetVaultKubernetesAuth("kubernetes", "stocks"))
+	v.jwtPath = jwtPath
+	ctx := context.Background()
+	if value, err := v.Get(ctx, "stocks/rabbitmq#password"); err != nil || value != "s3cret" {
+		t.Errorf("Get() = %q, %v", value, err)
+	}
+	if value, err := v.Get(ctx, "stocks/rabbitmq#username"); err != nil || value != "stocks" || logins != 1 {
+		t.Errorf("Get() = %q, %v after %d logins, want 1 login", value, err, logins)
+	}
+	if _, err := v.Get(ctx, "stocks/rabbitmq#missing"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Get() of a missing field error = %v", err)
+	}
+	if _, err := v.Get(ctx, "stocks/missing"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Get() of a missing secret error = %v", err)
+	}
+}
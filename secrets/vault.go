@@ -0,0 +1,168 @@
+package secrets
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// serviceAccountTokenPath is where kubernetes mounts the token of the service account of the pod
+const serviceAccountTokenPath = "/var/run/secrets/kubernetes.io/serviceaccount/token"
+
+// VaultOption sets a parameter of the vault provider
+type VaultOption func(v *VaultProvider)
+
+// SetVaultToken sets the token used to read the secrets. Default is the VAULT_TOKEN environment variable
+func SetVaultToken(token string) VaultOption {
+	return func(v *VaultProvider) { v.token = token }
+}
+
+// SetVaultKubernetesAuth logs in to vault with the service account of the pod, using the kubernetes auth
+// method mounted at mount with the role. The token is renewed by logging in again before it expires
+func SetVaultKubernetesAuth(mount string, role string) VaultOption {
+	return func(v *VaultProvider) {
+		v.authMount = mount
+		v.role = role
+	}
+}
+
+// SetVaultHTTPClient sets the http client used to call vault
+func SetVaultHTTPClient(client *http.Client) VaultOption {
+	return func(v *VaultProvider) { v.client = client }
+}
+
+// VaultProvider reads the secrets from a KV version 2 secrets engine of HashiCorp Vault. The name of a secret
+// is the path of the secret in the engine and the field, e.g. stocks/rabbitmq#password. The field defaults
+// to value, so stocks/rabbitmq-password is the field value of the secret stocks/rabbitmq-password
+type VaultProvider struct {
+	address   string
+	mount     string
+	client    *http.Client
+	authMount string
+	role      string
+	jwtPath   string
+
+	mu          sync.Mutex
+	token       string
+	tokenExpiry time.Time
+}
+
+// NewVaultProvider returns a provider reading the secrets of the KV engine mounted at mount of the vault
+// server at address, e.g. https://vault:8200. It panics if there is neither a token nor a kubernetes role
+func NewVaultProvider(address string, mount string, options ...VaultOption) *VaultProvider {
+	v := &VaultProvider{
+		address: strings.TrimSuffix(address, "/"),
+		mount:   strings.Trim(mount, "/"),
+		client:  &http.Client{Timeout: 10 * time.Second},
+		token:   os.Getenv("VAULT_TOKEN"),
+		jwtPath: serviceAccountTokenPath,
+	}
+	for _, option := range options {
+		option(v)
+	}
+	if v.token == "" && v.role == "" {
+		panic("secrets: vault needs a token or a kubernetes role")
+	}
+	return v
+}
+
+// Get reads the field of the secret. With kubernetes auth, a denied read logs in again and is retried once
+func (v *VaultProvider) Get(ctx context.Context, name string) (string, error) {
+	path, field, ok := strings.Cut(name, "#")
+	if !ok {
+		field = "value"
+	}
+	token, err := v.currentToken(ctx)
+	if err != nil {
+		return "", err
+	}
+	var secret struct {
+		Data struct {
+			Data map[string]interface{} `json:"data"`
+		} `json:"data"`
+	}
+	status, err := v.do(ctx, http.MethodGet, "/v1/"+v.mount+"/data/"+strings.Trim(path, "/"), token, nil, &secret)
+	if status == http.StatusForbidden && v.role != "" {
+		v.mu.Lock()
+		v.token = ""
+		v.mu.Unlock()
+		if token, err = v.currentToken(ctx); err != nil {
+			return "", err
+		}
+		status, err = v.do(ctx, http.MethodGet, "/v1/"+v.mount+"/data/"+strings.Trim(path, "/"), token, nil, &secret)
+	}
+	if status == http.StatusNotFound {
+		return "", ErrNotFound
+	}
+	if err != nil {
+		return "", err
+	}
+	value, ok := secret.Data.Data[field]
+	if !ok {
+		return "", ErrNotFound
+	}
+	if s, ok := value.(string); ok {
+		return s, nil
+	}
+	return fmt.Sprint(value), nil
+}
+
+// currentToken returns the token, logging in with kubernetes auth if it is missing or about to expire
+func (v *VaultProvider) currentToken(ctx context.Context) (string, error) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	if v.role == "" || (v.token != "" && time.Now().Before(v.tokenExpiry)) {
+		return v.token, nil
+	}
+	jwt, err := os.ReadFile(v.jwtPath)
+	if err != nil {
+		return "", fmt.Errorf("could not read the service account token: %w", err)
+	}
+	body, _ := json.Marshal(map[string]string{"role": v.role, "jwt": strings.TrimSpace(string(jwt))})
+	var login struct {
+		Auth struct {
+			ClientToken   string `json:"client_token"`
+			LeaseDuration int    `json:"lease_duration"`
+		} `json:"auth"`
+	}
+	if _, err := v.do(ctx, http.MethodPost, "/v1/auth/"+v.authMount+"/login", "", body, &login); err != nil {
+		return "", fmt.Errorf("could not log in to vault: %w", err)
+	}
+	v.token = login.Auth.ClientToken
+	// log in again when 80% of the lease is gone, so that a read never uses an expired token
+	v.tokenExpiry = time.Now().Add(time.Duration(login.Auth.LeaseDuration) * time.Second * 8 / 10)
+	return v.token, nil
+}
+
+// do calls vault and decodes the json response in out. It returns the status of the response
+func (v *VaultProvider) do(ctx context.Context, method string, path string, token string, body []byte,
+	out interface{}) (int, error) {
+	req, err := http.NewRequestWithContext(ctx, method, v.address+path, bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+	if token != "" {
+		req.Header.Set("X-Vault-Token", token)
+	}
+	resp, err := v.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		var vaultErr struct {
+			Errors []string `json:"errors"`
+		}
+		json.NewDecoder(io.LimitReader(resp.Body, 64<<10)).Decode(&vaultErr)
+		return resp.StatusCode, fmt.Errorf("vault returned %s for %s %s: %s", resp.Status, method, path,
+			strings.Join(vaultErr.Errors, "; "))
+	}
+	return resp.StatusCode, json.NewDecoder(resp.Body).Decode(out)
+}
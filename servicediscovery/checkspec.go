@@ -0,0 +1,151 @@
+package servicediscovery
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/hashicorp/consul/api"
+)
+
+// CheckKind is the type of a consul service check
+type CheckKind string
+
+// The kinds of checks supported by consul
+const (
+	ScriptCheck CheckKind = "script"
+	HTTPCheck   CheckKind = "http"
+	TCPCheck    CheckKind = "tcp"
+	GRPCCheck   CheckKind = "grpc"
+	TTLCheck    CheckKind = "ttl"
+)
+
+// CheckSpec describes a consul service check
+type CheckSpec struct {
+	// ID is appended to the service id to make the check id
+	ID string
+	// Name is the name of the check shown in consul
+	Name string
+	Kind CheckKind
+	// Args is the command and arguments of a script check
+	Args []string
+	// Target is the url of an http check or the address of a tcp or grpc check.
+	// If it is empty for tcp and grpc checks, the ip address and health check port of the service are used
+	Target string
+	// Method and Header are used by http checks. Method defaults to GET
+	Method string
+	Header map[string][]string
+	// TLSSkipVerify disables certificate verification of https checks
+	TLSSkipVerify bool
+	// GRPCUseTLS enables tls for grpc checks
+	GRPCUseTLS bool
+	// Interval between checks. Defaults to 10 seconds. Not used by ttl checks
+	Interval time.Duration
+	// Timeout of a check. Defaults to 5 seconds. Not used by ttl checks
+	Timeout time.Duration
+	// TTL is the time after which a ttl check becomes critical if it is not updated. Defaults to 30 seconds
+	TTL time.Duration
+	// DeregisterCriticalServiceAfter deregisters the service when the check is critical for so long. Defaults to 24 hours
+	DeregisterCriticalServiceAfter time.Duration
+}
+
+// ScriptCheckSpec returns a check which runs the command with the args
+func ScriptCheckSpec(id, name string, args ...string) CheckSpec {
+	return CheckSpec{ID: id, Name: name, Kind: ScriptCheck, Args: args}
+}
+
+// HTTPCheckSpec returns a check which sends a GET request to the url. Any 2xx response is passing
+func HTTPCheckSpec(id, name, url string) CheckSpec {
+	return CheckSpec{ID: id, Name: name, Kind: HTTPCheck, Target: url}
+}
+
+// TCPCheckSpec returns a check which connects to the address. An empty address is the health check port of the service
+func TCPCheckSpec(id, name, address string) CheckSpec {
+	return CheckSpec{ID: id, Name: name, Kind: TCPCheck, Target: address}
+}
+
+// GRPCCheckSpec returns a check which calls the grpc health service at the address.
+// An empty address is the health check port of the service
+func GRPCCheckSpec(id, name, address string) CheckSpec {
+	return CheckSpec{ID: id, Name: name, Kind: GRPCCheck, Target: address}
+}
+
+// TTLCheckSpec returns a check which the service has to update with UpdateTTL before the ttl expires
+func TTLCheckSpec(id, name string, ttl time.Duration) CheckSpec {
+	return CheckSpec{ID: id, Name: name, Kind: TTLCheck, TTL: ttl}
+}
+
+// agentServiceCheck returns the consul check for the spec. defaultTarget is used by tcp and grpc checks without a target
+func (s CheckSpec) agentServiceCheck(defaultTarget string) (api.AgentServiceCheck, error) {
+	check := api.AgentServiceCheck{
+		DeregisterCriticalServiceAfter: durationOrDefault(s.DeregisterCriticalServiceAfter, 24*time.Hour),
+	}
+	if s.Kind != TTLCheck {
+		check.Interval = durationOrDefault(s.Interval, 10*time.Second)
+		check.Timeout = durationOrDefault(s.Timeout, 5*time.Second)
+	}
+	target := s.Target
+	if target == "" {
+		target = defaultTarget
+	}
+	switch s.Kind {
+	case ScriptCheck:
+		if len(s.Args) == 0 {
+			return check, fmt.Errorf("script check %s has no args", s.ID)
+		}
+		check.Args = s.Args
+	case HTTPCheck:
+		if s.Target == "" {
+			return check, fmt.Errorf("http check %s has no url", s.ID)
+		}
+		check.HTTP = s.Target
+		check.Method = s.Method
+		check.Header = s.Header
+		check.TLSSkipVerify = s.TLSSkipVerify
+	case TCPCheck:
+		check.TCP = target
+	case GRPCCheck:
+		check.GRPC = target
+		check.GRPCUseTLS = s.GRPCUseTLS
+	case TTLCheck:
+		check.TTL = durationOrDefault(s.TTL, 30*time.Second)
+	default:
+		return check, fmt.Errorf("check %s has unknown kind %q", s.ID, s.Kind)
+	}
+	return check, nil
+}
+
+func durationOrDefault(d, defaultDuration time.Duration) string {
+	if d <= 0 {
+		d = defaultDuration
+	}
+	return d.String()
+}
+
+// ServiceChecks sets the checks registered by RegisterService instead of the default mon script and gRPC checks.
+// The gRPC health check server is still started with the check function passed to RegisterService
+func ServiceChecks(checks ...CheckSpec) Options {
+	return func(c *ConsulAgent) { c.checks = checks }
+}
+
+// registerCheckSpec registers the check for the service
+func (c *ConsulAgent) registerCheckSpec(serviceID string, spec CheckSpec, defaultTarget string) error {
+	check, err := spec.agentServiceCheck(defaultTarget)
+	if err != nil {
+		return err
+	}
+	err = c.consulAgent.Agent().CheckRegister(&api.AgentCheckRegistration{
+		ID:                serviceID + spec.ID,
+		Name:              spec.Name,
+		ServiceID:         serviceID,
+		AgentServiceCheck: check,
+	})
+	if err != nil {
+		c.logger.LogError("Error registering service check "+spec.ID+" in consul", err)
+	}
+	return err
+}
+
+// UpdateTTL updates a ttl check of the service. status is one of api.HealthPassing, api.HealthWarning or api.HealthCritical
+func (c *ConsulAgent) UpdateTTL(serviceID, checkID, output, status string) error {
+	return c.consulAgent.Agent().UpdateTTL(serviceID+checkID, output, status)
+}
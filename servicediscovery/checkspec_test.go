@@ -0,0 +1,42 @@
+package servicediscovery
+
+import (
+	"testing"
+	"time"
+)
+
+func TestCheckSpecAgentServiceCheck(t *testing.T) {
+	check, err := GRPCCheckSpec("checkService", "grpc", "").agentServiceCheck("10.0.0.1:50051")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if check.GRPC != "10.0.0.1:50051" || check.Interval != "10s" || check.Timeout != "5s" || check.DeregisterCriticalServiceAfter != "24h0m0s" {
+		t.Errorf("grpc check = %+v, want default target and durations", check)
+	}
+
+	check, err = TTLCheckSpec("checkTTL", "ttl", time.Minute).agentServiceCheck("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if check.TTL != "1m0s" || check.Interval != "" || check.Timeout != "" {
+		t.Errorf("ttl check = %+v, want ttl without interval and timeout", check)
+	}
+
+	http := HTTPCheckSpec("checkHTTP", "http", "http://10.0.0.1/healthz")
+	http.Method = "HEAD"
+	if check, err = http.agentServiceCheck(""); err != nil || check.HTTP != "http://10.0.0.1/healthz" || check.Method != "HEAD" {
+		t.Errorf("http check = %+v, %v", check, err)
+	}
+}
+
+func TestCheckSpecInvalid(t *testing.T) {
+	for _, spec := range []CheckSpec{
+		ScriptCheckSpec("checkMon", "mon"),
+		HTTPCheckSpec("checkHTTP", "http", ""),
+		{ID: "unknown", Kind: "unknown"},
+	} {
+		if _, err := spec.agentServiceCheck("10.0.0.1:50051"); err == nil {
+			t.Errorf("agentServiceCheck() of %+v should return an error", spec)
+		}
+	}
+}
@@ -6,6 +6,7 @@ import (
 	"os"
 	"path/filepath"
 	"strconv"
+	"time"
 
 	"github.com/carwale/golibraries/healthcheck"
 
@@ -16,11 +17,16 @@ import (
 
 // ConsulAgent is the custom consul agent that will be used by all go lang applications
 type ConsulAgent struct {
-	consulHostName      string
-	consulPortNumber    int
-	consulMonScriptName string
-	consulAgent         *api.Client
-	logger              *gologger.CustomLogger
+	consulHostName       string
+	consulPortNumber     int
+	consulMonScriptName  string
+	consulAgent          *api.Client
+	logger               gologger.ILogger
+	readiness            *healthcheck.ReadinessController
+	checks               []CheckSpec
+	deregisterOnShutdown bool
+	drainPeriod          time.Duration
+	onDrained            func()
 }
 
 // Options sets a parameter for consul agent
@@ -57,10 +63,21 @@ func ConsulMonScriptName(name string) Options {
 
 // Logger sets the logger for consul
 // Defaults to consul logger
-func Logger(customLogger *gologger.CustomLogger) Options {
+func Logger(customLogger gologger.ILogger) Options {
 	return func(c *ConsulAgent) { c.logger = customLogger }
 }
 
+// Readiness registers consul as a component of the readiness controller. It is ready once the service and its
+// checks are registered and not ready after it is deregistered. The controller is also used by the gRPC health check server
+func Readiness(rc *healthcheck.ReadinessController) Options {
+	return func(c *ConsulAgent) {
+		rc.Register(consulReadinessComponent)
+		c.readiness = rc
+	}
+}
+
+const consulReadinessComponent = "consul"
+
 // NewConsulAgent will initialize consul client.
 func NewConsulAgent(options ...Options) IServiceDiscoveryAgent {
 
@@ -90,6 +107,7 @@ func NewConsulAgent(options ...Options) IServiceDiscoveryAgent {
 // It will also register two checks for the service. A mon check and a gRPC check
 // mon check can be used for releases while the gRPC service check script should check
 // whether the service is running or not.
+// Other checks can be registered instead with the ServiceChecks option
 func (c *ConsulAgent) RegisterService(name, ipAddress, port, healthCheckPort string, checkFunction func() (bool, error), isDockerType bool, tags []string, metadata map[string]string) (string, error) {
 	consulServiceName := name
 	gatewayPort, err := strconv.Atoi(port[1:])
@@ -106,23 +124,22 @@ func (c *ConsulAgent) RegisterService(name, ipAddress, port, healthCheckPort str
 		c.logger.LogError(fmt.Sprintf("Could not register %s on consul", consulServiceName), err)
 		panic(fmt.Errorf("could not register %s on consul", consulServiceName))
 	}
-	workingDir, err := filepath.Abs(filepath.Dir(os.Args[0]))
-	if err != nil {
-		c.logger.LogWarning("Could not get working directory. Setting it as current directory" + err.Error())
-		workingDir = "."
+	checks := c.checks
+	if checks == nil {
+		checks = c.defaultChecks(name, isDockerType)
+	}
+	if c.deregisterOnShutdown {
+		c.installShutdownHook(serviceID)
 	}
+	c.startHealthCheckServer(healthCheckPort, checkFunction)
 	err = nil
-	if !isDockerType {
-		monScriptLocation := workingDir + string(os.PathSeparator) + "mon" + string(os.PathSeparator) + c.consulMonScriptName
-		ok := c.registerCheck(serviceID, "checkMon", name+" check mon", monScriptLocation)
-		if !ok {
-			err = errors.New("could not register consul service check")
+	for _, check := range checks {
+		if checkErr := c.registerCheckSpec(serviceID, check, ipAddress+healthCheckPort); checkErr != nil {
+			err = fmt.Errorf("could not register consul service check %s: %w", check.ID, checkErr)
 		}
 	}
-
-	ok := c.registerGrpcCheck(serviceID, "checkService", name+" check service", ipAddress, healthCheckPort, checkFunction)
-	if !ok {
-		err = errors.New("could not register gRPC consul service check")
+	if c.readiness != nil && err == nil {
+		c.readiness.MarkReady(consulReadinessComponent)
 	}
 	return serviceID, err
 }
@@ -145,50 +162,38 @@ func (c *ConsulAgent) registerServiceOnConsul(name, ipAddress, hostName string,
 	return serviceID, nil
 }
 
-func (c *ConsulAgent) registerCheck(serviceID, checkID, checkName, scriptLocation string) bool {
-	err := c.consulAgent.Agent().CheckRegister(&api.AgentCheckRegistration{
-		ID:        serviceID + checkID,
-		Name:      checkName,
-		ServiceID: serviceID,
-		AgentServiceCheck: api.AgentServiceCheck{
-			Args:                           []string{"python", scriptLocation},
-			Interval:                       "10s",
-			Timeout:                        "5s",
-			DeregisterCriticalServiceAfter: "24h",
-		},
-	})
-	if err != nil {
-		c.logger.LogError("Error registering service check in consul", err)
-		return false
+// defaultChecks returns the mon script check for non docker services and the gRPC check
+func (c *ConsulAgent) defaultChecks(name string, isDockerType bool) []CheckSpec {
+	checks := make([]CheckSpec, 0, 2)
+	if !isDockerType {
+		workingDir, err := filepath.Abs(filepath.Dir(os.Args[0]))
+		if err != nil {
+			c.logger.LogWarning("Could not get working directory. Setting it as current directory" + err.Error())
+			workingDir = "."
+		}
+		monScriptLocation := workingDir + string(os.PathSeparator) + "mon" + string(os.PathSeparator) + c.consulMonScriptName
+		checks = append(checks, ScriptCheckSpec("checkMon", name+" check mon", "python", monScriptLocation))
 	}
-	return true
+	grpcCheck := GRPCCheckSpec("checkService", name+" check service", "")
+	grpcCheck.Timeout = time.Second
+	return append(checks, grpcCheck)
 }
 
-func (c *ConsulAgent) registerGrpcCheck(serviceID, checkID, checkName, ipAddress, healthCheckPort string, checkFunction func() (bool, error)) bool {
-	healthcheck.NewHealthCheckServer(healthCheckPort, checkFunction, healthcheck.Logger(c.logger))
-	err := c.consulAgent.Agent().CheckRegister(&api.AgentCheckRegistration{
-		ID:        serviceID + checkID,
-		Name:      checkName,
-		ServiceID: serviceID,
-		AgentServiceCheck: api.AgentServiceCheck{
-			GRPC:                           ipAddress + healthCheckPort,
-			Interval:                       "10s",
-			Timeout:                        "1s",
-			DeregisterCriticalServiceAfter: "24h",
-			GRPCUseTLS:                     false,
-		},
-	})
-	if err != nil {
-		c.logger.LogError("Error registering service check in consul", err)
-		return false
+func (c *ConsulAgent) startHealthCheckServer(healthCheckPort string, checkFunction func() (bool, error)) {
+	options := []healthcheck.Options{healthcheck.Logger(c.logger)}
+	if c.readiness != nil {
+		options = append(options, healthcheck.Readiness(c.readiness))
 	}
-	return true
+	healthcheck.NewHealthCheckServer(healthCheckPort, checkFunction, options...)
 }
 
 // DeregisterService will deregister all the checks and the service itself
 // This should be used on an exit listener of the application. It will help
 // reduce clutter in consul
 func (c *ConsulAgent) DeregisterService(serviceID string) {
+	if c.readiness != nil {
+		c.readiness.MarkUnready(consulReadinessComponent, "deregistered")
+	}
 	err := c.consulAgent.Agent().ServiceDeregister(serviceID)
 	if err != nil {
 		c.logger.LogError("Error deregistering service in consul", err)
@@ -0,0 +1,58 @@
+package servicediscovery
+
+import (
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/carwale/golibraries/goutilities"
+)
+
+// DeregisterOnShutdown makes RegisterService install a hook for SIGINT and SIGTERM which deregisters the service
+// from consul, and then waits for drainPeriod so that clients stop sending requests and in flight requests complete.
+// onDrained is called after the drain period and should stop the application, e.g. by shutting down its servers
+// so that main returns. If onDrained is nil the signal is raised again after the drain, with the default handling
+// of the signals restored, so the application is stopped as if the hook was not installed; where the signal
+// cannot be raised, like on Windows, the process exits with the status 1.
+// The drain only holds if the application does not handle SIGINT or SIGTERM itself with signal.Notify, as it
+// would receive the signal at the same time as the hook. Such applications should stop from onDrained instead
+func DeregisterOnShutdown(drainPeriod time.Duration, onDrained func()) Options {
+	return func(c *ConsulAgent) {
+		c.deregisterOnShutdown = true
+		c.drainPeriod = drainPeriod
+		c.onDrained = onDrained
+	}
+}
+
+// installShutdownHook deregisters the service when the process receives SIGINT or SIGTERM
+func (c *ConsulAgent) installShutdownHook(serviceID string) {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGINT, syscall.SIGTERM)
+	goutilities.Go("servicediscovery-shutdown", func() {
+		sig := <-signals
+		signal.Stop(signals)
+		c.logger.LogWarning(fmt.Sprintf("Caught signal %v. Deregistering %s from consul and draining for %s", sig, serviceID, c.drainPeriod))
+		c.DeregisterService(serviceID)
+		time.Sleep(c.drainPeriod)
+		c.logger.LogWarning("Drained " + serviceID)
+		if c.onDrained != nil {
+			c.onDrained()
+			return
+		}
+		if err := raise(sig); err != nil {
+			c.logger.LogError(fmt.Sprintf("Could not raise %v again after draining %s", sig, serviceID), err)
+			os.Exit(1)
+		}
+	})
+}
+
+// raise sends the signal to the process
+func raise(sig os.Signal) error {
+	process, err := os.FindProcess(os.Getpid())
+	if err != nil {
+		return err
+	}
+	return process.Signal(sig)
+}
@@ -0,0 +1,162 @@
+// Package timeutil has timezone aware helpers for the start and end of days and weeks, business hours
+// and human readable durations. IST is a fixed zone, so the helpers do not depend on the tz database of the container
+package timeutil
+
+import (
+	"strconv"
+	"strings"
+	"time"
+)
+
+// IST is Indian Standard Time, UTC+05:30. India does not observe daylight saving time
+var IST = time.FixedZone("IST", 5*60*60+30*60)
+
+// NowIST returns the current time in IST
+func NowIST() time.Time {
+	return time.Now().In(IST)
+}
+
+// InIST returns t in IST
+func InIST(t time.Time) time.Time {
+	return t.In(IST)
+}
+
+// StartOfDay returns midnight at the start of the day of t in the location of t
+func StartOfDay(t time.Time) time.Time {
+	year, month, day := t.Date()
+	return time.Date(year, month, day, 0, 0, 0, 0, t.Location())
+}
+
+// EndOfDay returns the last nanosecond of the day of t in the location of t
+func EndOfDay(t time.Time) time.Time {
+	return StartOfDay(t).AddDate(0, 0, 1).Add(-time.Nanosecond)
+}
+
+// StartOfWeek returns midnight at the start of the week of t in the location of t. Weeks start on weekStart
+func StartOfWeek(t time.Time, weekStart time.Weekday) time.Time {
+	days := (int(t.Weekday()) - int(weekStart) + 7) % 7
+	return StartOfDay(t).AddDate(0, 0, -days)
+}
+
+// EndOfWeek returns the last nanosecond of the week of t in the location of t. Weeks start on weekStart
+func EndOfWeek(t time.Time, weekStart time.Weekday) time.Time {
+	return StartOfWeek(t, weekStart).AddDate(0, 0, 7).Add(-time.Nanosecond)
+}
+
+// StartOfMonth returns midnight at the start of the month of t in the location of t
+func StartOfMonth(t time.Time) time.Time {
+	year, month, _ := t.Date()
+	return time.Date(year, month, 1, 0, 0, 0, 0, t.Location())
+}
+
+// BusinessHours are the opening hours on the working days in a location. Open and Close are the
+// times since midnight; a time equal to Close is outside business hours
+type BusinessHours struct {
+	Open     time.Duration
+	Close    time.Duration
+	Days     []time.Weekday
+	Location *time.Location
+	// Holidays are dates (any time of the day) on which the business is closed
+	Holidays []time.Time
+}
+
+// DefaultBusinessHours are 09:30 to 18:30 IST from Monday to Saturday
+var DefaultBusinessHours = BusinessHours{
+	Open:     9*time.Hour + 30*time.Minute,
+	Close:    18*time.Hour + 30*time.Minute,
+	Days:     []time.Weekday{time.Monday, time.Tuesday, time.Wednesday, time.Thursday, time.Friday, time.Saturday},
+	Location: IST,
+}
+
+// IsOpen returns true if t is within business hours
+func (b BusinessHours) IsOpen(t time.Time) bool {
+	t = t.In(b.location())
+	if !b.isWorkingDay(t) {
+		return false
+	}
+	sinceMidnight := t.Sub(StartOfDay(t))
+	return sinceMidnight >= b.Open && sinceMidnight < b.Close
+}
+
+// NextOpen returns t if it is within business hours, otherwise the time business hours next start.
+// It returns the zero time if there are no working days
+func (b BusinessHours) NextOpen(t time.Time) time.Time {
+	t = t.In(b.location())
+	if b.IsOpen(t) {
+		return t
+	}
+	day := StartOfDay(t)
+	// A year covers any set of working days and holidays that leaves at least one working day
+	for i := 0; i <= 366; i++ {
+		open := day.AddDate(0, 0, i).Add(b.Open)
+		if open.After(t) && b.isWorkingDay(open) {
+			return open
+		}
+	}
+	return time.Time{}
+}
+
+func (b BusinessHours) location() *time.Location {
+	if b.Location == nil {
+		return IST
+	}
+	return b.Location
+}
+
+func (b BusinessHours) isWorkingDay(t time.Time) bool {
+	working := false
+	for _, day := range b.Days {
+		if t.Weekday() == day {
+			working = true
+			break
+		}
+	}
+	if !working {
+		return false
+	}
+	year, month, day := t.Date()
+	for _, holiday := range b.Holidays {
+		holiday = holiday.In(b.location())
+		if hYear, hMonth, hDay := holiday.Date(); hYear == year && hMonth == month && hDay == day {
+			return false
+		}
+	}
+	return true
+}
+
+// Humanize returns the duration with its two largest units, e.g. "3d 4h", "2h 5m", "45s" or "120ms".
+// Durations under a millisecond are formatted by time.Duration
+func Humanize(d time.Duration) string {
+	if d < 0 {
+		return "-" + Humanize(-d)
+	}
+	if d < time.Millisecond {
+		return d.String()
+	}
+	if d < time.Second {
+		return strconv.FormatInt(d.Milliseconds(), 10) + "ms"
+	}
+	units := []struct {
+		size   time.Duration
+		suffix string
+	}{
+		{24 * time.Hour, "d"},
+		{time.Hour, "h"},
+		{time.Minute, "m"},
+		{time.Second, "s"},
+	}
+	parts := make([]string, 0, 2)
+	for _, unit := range units {
+		if d >= unit.size {
+			parts = append(parts, strconv.FormatInt(int64(d/unit.size), 10)+unit.suffix)
+			d %= unit.size
+		} else if len(parts) > 0 {
+			// Keep the two units adjacent, "1d 0h" is shown as "1d"
+			break
+		}
+		if len(parts) == 2 {
+			break
+		}
+	}
+	return strings.Join(parts, " ")
+}
@@ -0,0 +1,85 @@
+package timeutil
+
+import (
+	"testing"
+	"time"
+)
+
+func TestInIST(t *testing.T) {
+	utc := time.Date(2024, 3, 1, 20, 0, 0, 0, time.UTC)
+	if got := InIST(utc); got.Format("2006-01-02 15:04") != "2024-03-02 01:30" {
+		t.Errorf("InIST() = %v", got)
+	}
+}
+
+func TestDayAndWeekBoundaries(t *testing.T) {
+	// Wednesday
+	ts := time.Date(2024, 3, 6, 15, 4, 5, 6, IST)
+	tests := []struct {
+		name string
+		got  time.Time
+		want time.Time
+	}{
+		{"start of day", StartOfDay(ts), time.Date(2024, 3, 6, 0, 0, 0, 0, IST)},
+		{"end of day", EndOfDay(ts), time.Date(2024, 3, 6, 23, 59, 59, 999999999, IST)},
+		{"start of week on monday", StartOfWeek(ts, time.Monday), time.Date(2024, 3, 4, 0, 0, 0, 0, IST)},
+		{"start of week on sunday", StartOfWeek(ts, time.Sunday), time.Date(2024, 3, 3, 0, 0, 0, 0, IST)},
+		{"start of week on thursday", StartOfWeek(ts, time.Thursday), time.Date(2024, 2, 29, 0, 0, 0, 0, IST)},
+		{"end of week", EndOfWeek(ts, time.Monday), time.Date(2024, 3, 10, 23, 59, 59, 999999999, IST)},
+		{"start of month", StartOfMonth(ts), time.Date(2024, 3, 1, 0, 0, 0, 0, IST)},
+	}
+	for _, tt := range tests {
+		if !tt.got.Equal(tt.want) {
+			t.Errorf("%s = %v, want %v", tt.name, tt.got, tt.want)
+		}
+	}
+}
+
+func TestBusinessHours(t *testing.T) {
+	hours := DefaultBusinessHours
+	hours.Holidays = []time.Time{time.Date(2024, 3, 8, 0, 0, 0, 0, IST)}
+	tests := []struct {
+		name     string
+		at       time.Time
+		open     bool
+		nextOpen time.Time
+	}{
+		{"weekday morning", time.Date(2024, 3, 6, 10, 0, 0, 0, IST), true, time.Date(2024, 3, 6, 10, 0, 0, 0, IST)},
+		{"before opening", time.Date(2024, 3, 6, 9, 0, 0, 0, IST), false, time.Date(2024, 3, 6, 9, 30, 0, 0, IST)},
+		{"at closing", time.Date(2024, 3, 6, 18, 30, 0, 0, IST), false, time.Date(2024, 3, 7, 9, 30, 0, 0, IST)},
+		{"before a holiday", time.Date(2024, 3, 7, 20, 0, 0, 0, IST), false, time.Date(2024, 3, 9, 9, 30, 0, 0, IST)},
+		{"sunday", time.Date(2024, 3, 10, 11, 0, 0, 0, IST), false, time.Date(2024, 3, 11, 9, 30, 0, 0, IST)},
+		{"utc time in business hours", time.Date(2024, 3, 6, 5, 0, 0, 0, time.UTC), true, time.Date(2024, 3, 6, 10, 30, 0, 0, IST)},
+	}
+	for _, tt := range tests {
+		if got := hours.IsOpen(tt.at); got != tt.open {
+			t.Errorf("%s: IsOpen() = %v, want %v", tt.name, got, tt.open)
+		}
+		if got := hours.NextOpen(tt.at); !got.Equal(tt.nextOpen) {
+			t.Errorf("%s: NextOpen() = %v, want %v", tt.name, got, tt.nextOpen)
+		}
+	}
+	if got := (BusinessHours{}).NextOpen(time.Now()); !got.IsZero() {
+		t.Errorf("NextOpen() without working days = %v, want zero", got)
+	}
+}
+
+func TestHumanize(t *testing.T) {
+	tests := []struct {
+		d    time.Duration
+		want string
+	}{
+		{500 * time.Microsecond, "500µs"},
+		{120 * time.Millisecond, "120ms"},
+		{45 * time.Second, "45s"},
+		{2*time.Hour + 5*time.Minute + 3*time.Second, "2h 5m"},
+		{76 * time.Hour, "3d 4h"},
+		{24*time.Hour + 5*time.Minute, "1d"},
+		{-90 * time.Second, "-1m 30s"},
+	}
+	for _, tt := range tests {
+		if got := Humanize(tt.d); got != tt.want {
+			t.Errorf("Humanize(%v) = %q, want %q", tt.d, got, tt.want)
+		}
+	}
+}
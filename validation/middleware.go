@@ -0,0 +1,185 @@
+package validation
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/carwale/golibraries/apierror"
+	"github.com/carwale/golibraries/ctxutil"
+	"github.com/carwale/golibraries/gologger"
+)
+
+// Codes of the errors returned by DecodeJSON and Validate
+const (
+	CodeValidationFailed = "VALIDATION_FAILED"
+	CodeInvalidJSON      = "INVALID_JSON"
+	CodeBodyTooLarge     = "BODY_TOO_LARGE"
+	CodeUnsupportedMedia = "UNSUPPORTED_MEDIA_TYPE"
+)
+
+// DefaultMaxBodyBytes is the default limit of the size of a request body
+const DefaultMaxBodyBytes = 1 << 20
+
+// Option sets a parameter of the decoding of requests
+type Option func(c *config)
+
+type config struct {
+	maxBodyBytes          int64
+	disallowUnknownFields bool
+	logger                gologger.ILogger
+}
+
+// SetMaxBodyBytes sets the limit of the size of the request body. Default is DefaultMaxBodyBytes
+func SetMaxBodyBytes(maxBodyBytes int64) Option {
+	return func(c *config) {
+		if maxBodyBytes > 0 {
+			c.maxBodyBytes = maxBodyBytes
+		}
+	}
+}
+
+// SetDisallowUnknownFields rejects bodies with fields that are not in the struct
+func SetDisallowUnknownFields(disallow bool) Option {
+	return func(c *config) { c.disallowUnknownFields = disallow }
+}
+
+// SetLogger sets the logger the rejected requests are logged to as warnings. Default is a logger at ERROR
+// level, which does not log them
+func SetLogger(logger gologger.ILogger) Option {
+	return func(c *config) { c.logger = logger }
+}
+
+func newConfig(options []Option) config {
+	c := config{maxBodyBytes: DefaultMaxBodyBytes}
+	for _, option := range options {
+		option(&c)
+	}
+	if c.logger == nil {
+		c.logger = gologger.NewLogger(gologger.SetLogLevel("ERROR"))
+	}
+	return c
+}
+
+// DecodeJSON decodes the json body of the request into v and validates it. It returns an *Error if the body
+// is not json, is too large or is not valid
+func DecodeJSON(r *http.Request, v interface{}, options ...Option) error {
+	return decodeJSON(r, v, newConfig(options))
+}
+
+func decodeJSON(r *http.Request, v interface{}, c config) error {
+	if contentType := r.Header.Get("Content-Type"); contentType != "" && !strings.Contains(strings.ToLower(contentType), "json") {
+		return &Error{Status: http.StatusUnsupportedMediaType, Code: CodeUnsupportedMedia, Message: "content type must be application/json"}
+	}
+	counter := &countingReader{reader: io.LimitReader(r.Body, c.maxBodyBytes+1)}
+	decoder := json.NewDecoder(counter)
+	if c.disallowUnknownFields {
+		decoder.DisallowUnknownFields()
+	}
+	if err := decoder.Decode(v); err != nil {
+		if counter.read > c.maxBodyBytes {
+			return &Error{Status: http.StatusRequestEntityTooLarge, Code: CodeBodyTooLarge, Message: "request body is too large"}
+		}
+		return invalidJSON(err)
+	}
+	if decoder.More() {
+		return &Error{Status: http.StatusBadRequest, Code: CodeInvalidJSON, Message: "request body must have a single json value"}
+	}
+	return Validate(v)
+}
+
+// invalidJSON returns the error for a body that could not be decoded, with the field if it is known
+func invalidJSON(err error) *Error {
+	e := &Error{Status: http.StatusBadRequest, Code: CodeInvalidJSON, Message: "request body is not valid json"}
+	var typeErr *json.UnmarshalTypeError
+	if errors.As(err, &typeErr) && typeErr.Field != "" {
+		e.Fields = []FieldError{{Field: typeErr.Field, Rule: "type", Message: "must be a " + typeErr.Type.String()}}
+	} else if strings.HasPrefix(err.Error(), "json: unknown field ") {
+		field := strings.Trim(strings.TrimPrefix(err.Error(), "json: unknown field "), `"`)
+		e.Fields = []FieldError{{Field: field, Rule: "unknown", Message: "is not allowed"}}
+	} else if err == io.EOF {
+		e.Message = "request body is empty"
+	}
+	return e
+}
+
+type countingReader struct {
+	reader io.Reader
+	read   int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.reader.Read(p)
+	c.read += int64(n)
+	return n, err
+}
+
+// Bind decodes and validates the json body of the request into v. If it fails it writes the error response,
+// logs the failure and returns false, so the handler only has to return
+//
+//	var lead CreateLead
+//	if !validation.Bind(w, r, &lead) {
+//		return
+//	}
+func Bind(w http.ResponseWriter, r *http.Request, v interface{}, options ...Option) bool {
+	c := newConfig(options)
+	err := decodeJSON(r, v, c)
+	if err == nil {
+		return true
+	}
+	logFailure(c.logger, r, err)
+	WriteError(w, r, err)
+	return false
+}
+
+// Handler returns a handler which decodes and validates the json body of the request into a T and calls
+// handle with it. Invalid requests are answered with the error response and are not passed to handle
+//
+//	mux.Handle("/v1/leads", validation.Handler(func(w http.ResponseWriter, r *http.Request, lead *CreateLead) {
+//		...
+//	}, validation.SetLogger(logger)))
+func Handler[T any](handle func(w http.ResponseWriter, r *http.Request, body *T), options ...Option) http.Handler {
+	c := newConfig(options)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body := new(T)
+		if err := decodeJSON(r, body, c); err != nil {
+			logFailure(c.logger, r, err)
+			WriteError(w, r, err)
+			return
+		}
+		handle(w, r, body)
+	})
+}
+
+// WriteError writes err as an apierror response. An *Error is written with its status and code and the errors
+// of the fields as the details, other errors as a 400 with the VALIDATION_FAILED code
+func WriteError(w http.ResponseWriter, r *http.Request, err error) {
+	var e *Error
+	if !errors.As(err, &e) {
+		e = &Error{Status: http.StatusBadRequest, Code: CodeValidationFailed, Message: err.Error()}
+	}
+	apiErr := apierror.Wrap(e, e.HTTPStatus(), e.Code, e.Message)
+	if details := e.ErrorDetails(); details != nil {
+		apiErr = apiErr.WithDetails(details)
+	}
+	apierror.WriteError(w, r, apiErr)
+}
+
+func logFailure(logger gologger.ILogger, r *http.Request, err error) {
+	pairs := []gologger.Pair{
+		{Key: "path", Value: r.URL.Path},
+		{Key: "method", Value: r.Method},
+		{Key: gologger.ErrorCodeKey, Value: CodeValidationFailed},
+		{Key: "validation_error", Value: err.Error()},
+	}
+	var e *Error
+	if errors.As(err, &e) {
+		pairs[2].Value = e.Code
+	}
+	if requestID := ctxutil.RequestIDFrom(r.Context()); requestID != "" {
+		pairs = append(pairs, gologger.Pair{Key: ctxutil.RequestIDLogKey, Value: requestID})
+	}
+	logger.LogWarningMessage("Request validation failed", pairs...)
+}
@@ -0,0 +1,288 @@
+// Package validation validates structs with rules in their validate tags and decodes and validates json request
+// bodies, responding with the same error payload in all the services.
+//
+// The rules of a field are separated by commas
+//
+//	type CreateLead struct {
+//		Name     string   `json:"name" validate:"required,max=100"`
+//		CityID   int      `json:"cityId" validate:"required,min=1"`
+//		Platform string   `json:"platform" validate:"oneof=desktop msite android ios"`
+//		Models   []int    `json:"models" validate:"min=1,max=5"`
+//		Address  *Address `json:"address"`
+//	}
+//
+// required fails for the zero value and for nil or empty slices and maps. min and max compare the value of numbers
+// and the length of strings (in characters), slices and maps. oneof takes the allowed values separated by spaces.
+// A nil pointer only fails required; the rules apply to the value it points to. Nested structs, pointers to
+// structs and slices of structs are validated with their own rules
+package validation
+
+import (
+	"fmt"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode/utf8"
+)
+
+// TagName is the name of the struct tag holding the rules
+const TagName = "validate"
+
+// FieldError is a rule that a field does not satisfy. Field is the path of the field with its json names,
+// e.g. address.pincode or models[2]
+type FieldError struct {
+	Field   string `json:"field"`
+	Rule    string `json:"rule"`
+	Message string `json:"message"`
+}
+
+// Error is the error of a request that could not be decoded or is not valid. It is written by WriteError
+// as an apierror response with the errors of the fields as the details
+type Error struct {
+	Status  int          `json:"-"`
+	Code    string       `json:"code"`
+	Message string       `json:"message"`
+	Fields  []FieldError `json:"errors,omitempty"`
+}
+
+// Error returns the message and the errors of the fields
+func (e *Error) Error() string {
+	if len(e.Fields) == 0 {
+		return e.Message
+	}
+	fields := make([]string, len(e.Fields))
+	for i, field := range e.Fields {
+		fields[i] = field.Field + " " + field.Message
+	}
+	return e.Message + ": " + strings.Join(fields, "; ")
+}
+
+// ErrorCode returns the code of the error, so that it is used as the error code of gologger
+func (e *Error) ErrorCode() string {
+	return e.Code
+}
+
+// HTTPStatus returns the status of the response, so that apierror responds with it
+func (e *Error) HTTPStatus() int {
+	if e.Status == 0 {
+		return http.StatusBadRequest
+	}
+	return e.Status
+}
+
+// ErrorDetails returns the errors of the fields, which apierror sends as the details of the response
+func (e *Error) ErrorDetails() interface{} {
+	if len(e.Fields) == 0 {
+		return nil
+	}
+	return e.Fields
+}
+
+type rule struct {
+	name   string
+	limit  float64
+	values []string
+}
+
+type fieldRules struct {
+	index []int
+	name  string
+	rules []rule
+}
+
+// typeRules caches the rules of the struct types
+var typeRules sync.Map
+
+// Validate checks the rules of the fields of v, a struct or a pointer to a struct. It returns nil if v is valid
+// and an *Error with an entry for every failed rule otherwise. It panics if a tag has an unknown rule or an
+// invalid parameter, as that is a programming error
+func Validate(v interface{}) error {
+	value := reflect.ValueOf(v)
+	for value.Kind() == reflect.Ptr {
+		if value.IsNil() {
+			return nil
+		}
+		value = value.Elem()
+	}
+	if value.Kind() != reflect.Struct {
+		return nil
+	}
+	var errors []FieldError
+	validateStruct(value, "", &errors)
+	if len(errors) == 0 {
+		return nil
+	}
+	return &Error{Status: http.StatusBadRequest, Code: CodeValidationFailed, Message: "request is not valid", Fields: errors}
+}
+
+func validateStruct(value reflect.Value, prefix string, errors *[]FieldError) {
+	for _, field := range rulesOf(value.Type()) {
+		validateField(value.FieldByIndex(field.index), prefix+field.name, field.rules, errors)
+	}
+}
+
+func validateField(value reflect.Value, path string, rules []rule, errors *[]FieldError) {
+	for _, r := range rules {
+		if r.name == "required" && isEmpty(value) {
+			*errors = append(*errors, FieldError{Field: path, Rule: r.name, Message: "is required"})
+			return
+		}
+	}
+	for value.Kind() == reflect.Ptr || value.Kind() == reflect.Interface {
+		if value.IsNil() {
+			return
+		}
+		value = value.Elem()
+	}
+	for _, r := range rules {
+		if r.name == "required" {
+			continue
+		}
+		if message := check(value, r); message != "" {
+			*errors = append(*errors, FieldError{Field: path, Rule: r.name, Message: message})
+		}
+	}
+	switch value.Kind() {
+	case reflect.Struct:
+		validateStruct(value, path+".", errors)
+	case reflect.Slice, reflect.Array:
+		if elem := value.Type().Elem(); elem.Kind() == reflect.Struct || (elem.Kind() == reflect.Ptr && elem.Elem().Kind() == reflect.Struct) {
+			for i := 0; i < value.Len(); i++ {
+				validateField(value.Index(i), path+"["+strconv.Itoa(i)+"]", nil, errors)
+			}
+		}
+	}
+}
+
+// check returns the message for the rule if the value does not satisfy it
+func check(value reflect.Value, r rule) string {
+	switch r.name {
+	case "min", "max":
+		number, isLength, ok := measure(value)
+		if !ok {
+			return ""
+		}
+		limit := strconv.FormatFloat(r.limit, 'f', -1, 64)
+		if r.name == "min" && number < r.limit {
+			if isLength {
+				return "must have a length of at least " + limit
+			}
+			return "must be at least " + limit
+		}
+		if r.name == "max" && number > r.limit {
+			if isLength {
+				return "must have a length of at most " + limit
+			}
+			return "must be at most " + limit
+		}
+	case "oneof":
+		actual := fmt.Sprint(value.Interface())
+		for _, allowed := range r.values {
+			if actual == allowed {
+				return ""
+			}
+		}
+		return "must be one of " + strings.Join(r.values, ", ")
+	}
+	return ""
+}
+
+// measure returns the number compared by min and max: the value of numbers and the length of the others
+func measure(value reflect.Value) (number float64, isLength bool, ok bool) {
+	switch value.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(value.Int()), false, true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return float64(value.Uint()), false, true
+	case reflect.Float32, reflect.Float64:
+		return value.Float(), false, true
+	case reflect.String:
+		return float64(utf8.RuneCountInString(value.String())), true, true
+	case reflect.Slice, reflect.Array, reflect.Map:
+		return float64(value.Len()), true, true
+	}
+	return 0, false, false
+}
+
+func isEmpty(value reflect.Value) bool {
+	switch value.Kind() {
+	case reflect.Slice, reflect.Map:
+		return value.Len() == 0
+	case reflect.Invalid:
+		return true
+	}
+	return value.IsZero()
+}
+
+// rulesOf returns the fields of the struct type with their rules. Fields without rules are included when
+// they can hold a struct, so that the struct is validated
+func rulesOf(t reflect.Type) []fieldRules {
+	if cached, ok := typeRules.Load(t); ok {
+		return cached.([]fieldRules)
+	}
+	var fields []fieldRules
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+		tag := field.Tag.Get(TagName)
+		if tag == "-" {
+			continue
+		}
+		rules := parseRules(t, field, tag)
+		if len(rules) == 0 && !canHoldStruct(field.Type) {
+			continue
+		}
+		fields = append(fields, fieldRules{index: field.Index, name: jsonName(field), rules: rules})
+	}
+	typeRules.Store(t, fields)
+	return fields
+}
+
+func parseRules(t reflect.Type, field reflect.StructField, tag string) []rule {
+	var rules []rule
+	for _, part := range strings.Split(tag, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		name, param, _ := strings.Cut(part, "=")
+		r := rule{name: name}
+		switch name {
+		case "required":
+		case "min", "max":
+			limit, err := strconv.ParseFloat(param, 64)
+			if err != nil {
+				panic(fmt.Sprintf("validation: invalid %s rule %q of %s.%s", name, param, t.Name(), field.Name))
+			}
+			r.limit = limit
+		case "oneof":
+			r.values = strings.Fields(param)
+			if len(r.values) == 0 {
+				panic(fmt.Sprintf("validation: oneof rule of %s.%s has no values", t.Name(), field.Name))
+			}
+		default:
+			panic(fmt.Sprintf("validation: unknown rule %q of %s.%s", name, t.Name(), field.Name))
+		}
+		rules = append(rules, r)
+	}
+	return rules
+}
+
+func canHoldStruct(t reflect.Type) bool {
+	for t.Kind() == reflect.Ptr || t.Kind() == reflect.Slice || t.Kind() == reflect.Array {
+		t = t.Elem()
+	}
+	return t.Kind() == reflect.Struct
+}
+
+// jsonName returns the name of the field in json
+func jsonName(field reflect.StructField) string {
+	if name, _, _ := strings.Cut(field.Tag.Get("json"), ","); name != "" && name != "-" {
+		return name
+	}
+	return field.Name
+}
@@ -0,0 +1,130 @@
+package validation
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/carwale/golibraries/ctxutil"
+)
+
+type address struct {
+	Pincode string `json:"pincode" validate:"required,min=6,max=6"`
+}
+
+type lead struct {
+	Name     string     `json:"name" validate:"required,max=10"`
+	CityID   int        `json:"cityId" validate:"required,min=1"`
+	Platform string     `json:"platform" validate:"oneof=desktop msite"`
+	Models   []int      `json:"models" validate:"max=2"`
+	Address  *address   `json:"address"`
+	Previous []address  `json:"previous"`
+	Budget   *float64   `json:"budget" validate:"min=0"`
+	Ignored  string     `validate:"-"`
+	Extra    chan error `json:"-"`
+}
+
+func TestValidate(t *testing.T) {
+	negative := -1.5
+	tests := []struct {
+		name   string
+		value  interface{}
+		fields []string
+	}{
+		{"valid", &lead{Name: "Ravi", CityID: 1, Platform: "msite"}, nil},
+		{"missing", lead{Platform: "desktop"}, []string{"name:required", "cityId:required"}},
+		{"ranges", lead{Name: "a very long name", CityID: 1, Platform: "desktop", Models: []int{1, 2, 3}, Budget: &negative},
+			[]string{"name:max", "models:max", "budget:min"}},
+		{"oneof", lead{Name: "Ravi", CityID: 1, Platform: "tv"}, []string{"platform:oneof"}},
+		{"nested", lead{Name: "Ravi", CityID: 1, Platform: "msite", Address: &address{Pincode: "4000"},
+			Previous: []address{{Pincode: "400001"}, {}}}, []string{"address.pincode:min", "previous[1].pincode:required"}},
+		{"nil pointer", (*lead)(nil), nil},
+		{"not a struct", 5, nil},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := Validate(test.value)
+			var got []string
+			if err != nil {
+				for _, field := range err.(*Error).Fields {
+					got = append(got, field.Field+":"+field.Rule)
+				}
+			}
+			if strings.Join(got, ",") != strings.Join(test.fields, ",") {
+				t.Fatalf("expected errors %v, got %v", test.fields, got)
+			}
+		})
+	}
+}
+
+func TestValidateUnknownRulePanics(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Fatal("expected a panic for an unknown rule")
+		}
+	}()
+	Validate(struct {
+		Name string `validate:"email"`
+	}{})
+}
+
+func TestHandler(t *testing.T) {
+	called := false
+	handler := Handler(func(w http.ResponseWriter, r *http.Request, body *lead) {
+		called = true
+		if body.Name != "Ravi" {
+			t.Errorf("expected the decoded body, got %+v", body)
+		}
+	}, SetMaxBodyBytes(100), SetDisallowUnknownFields(true))
+
+	tests := []struct {
+		name   string
+		body   string
+		status int
+		code   string
+	}{
+		{"valid", `{"name":"Ravi","cityId":2,"platform":"msite"}`, http.StatusOK, ""},
+		{"invalid", `{"name":"Ravi","platform":"msite"}`, http.StatusBadRequest, CodeValidationFailed},
+		{"not json", `{"name":`, http.StatusBadRequest, CodeInvalidJSON},
+		{"wrong type", `{"name":1}`, http.StatusBadRequest, CodeInvalidJSON},
+		{"unknown field", `{"nme":"Ravi"}`, http.StatusBadRequest, CodeInvalidJSON},
+		{"too large", `{"name":"` + strings.Repeat("a", 200) + `"}`, http.StatusRequestEntityTooLarge, CodeBodyTooLarge},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			called = false
+			r := httptest.NewRequest(http.MethodPost, "/leads", strings.NewReader(test.body))
+			r = r.WithContext(ctxutil.WithRequestID(r.Context(), "req-1"))
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, r)
+			if w.Code != test.status {
+				t.Fatalf("expected status %d, got %d: %s", test.status, w.Code, w.Body.String())
+			}
+			if test.code == "" {
+				if !called {
+					t.Fatal("expected the handler to be called")
+				}
+				return
+			}
+			if called {
+				t.Fatal("expected the handler not to be called")
+			}
+			var response struct {
+				Code      string       `json:"code"`
+				Details   []FieldError `json:"details"`
+				RequestID string       `json:"request_id"`
+			}
+			if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+				t.Fatal(err)
+			}
+			if response.Code != test.code || response.RequestID != "req-1" {
+				t.Fatalf("expected code %s with the request id, got %+v", test.code, response)
+			}
+			if test.code == CodeValidationFailed && (len(response.Details) != 1 || response.Details[0].Field != "cityId") {
+				t.Fatalf("expected the error of cityId in the details, got %+v", response.Details)
+			}
+		})
+	}
+}
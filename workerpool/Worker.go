@@ -1,8 +1,10 @@
 package workerpool
 
 import (
+	"errors"
 	"sync"
 
+	"github.com/carwale/golibraries/crashreport"
 	"github.com/carwale/golibraries/gologger"
 	"github.com/prometheus/client_golang/prometheus"
 )
@@ -64,7 +66,7 @@ func newWorker(workerPool chan chan IJob, number int) IWorker {
 // Option sets a parameter for the Dispatcher
 type Option func(d *Dispatcher)
 
-// SetMaxWorkers sets the number of workers. Default is 10
+// SetMaxWorkers sets the number of workers. Default is DefaultWorkers(IOBound)
 func SetMaxWorkers(maxWorkers int) Option {
 	return func(d *Dispatcher) {
 		if maxWorkers > 0 {
@@ -73,6 +75,14 @@ func SetMaxWorkers(maxWorkers int) Option {
 	}
 }
 
+// SetWorkload sets the number of workers to the default of the workload. Use CPUBound for pools
+// that keep the cpu busy so that they do not run more workers than the container can schedule
+func SetWorkload(workload Workload) Option {
+	return func(d *Dispatcher) {
+		d.maxWorkers = DefaultWorkers(workload)
+	}
+}
+
 // SetNewWorker sets the Worker initialisation function in dispatcher
 func SetNewWorker(newWorker func(chan chan IJob, int) IWorker) Option {
 	return func(d *Dispatcher) {
@@ -81,7 +91,7 @@ func SetNewWorker(newWorker func(chan chan IJob, int) IWorker) Option {
 }
 
 // SetLogger sets the logger in dispatcher
-func SetLogger(logger *gologger.CustomLogger) Option {
+func SetLogger(logger gologger.ILogger) Option {
 	return func(d *Dispatcher) {
 		d.logger = logger
 	}
@@ -95,6 +105,14 @@ func SetLatencyLogger(logger gologger.IMultiLogger) Option {
 	}
 }
 
+// SetPanicReporter recovers panics of the jobs and reports them with the reporter, instead of crashing the worker.
+// Process returns an error for a job that panicked
+func SetPanicReporter(reporter *crashreport.Reporter) Option {
+	return func(d *Dispatcher) {
+		d.reporter = reporter
+	}
+}
+
 // SetJobQueue sets the JobQueue in dispatcher
 func SetJobQueue(jobQueue chan IJob) Option {
 	return func(d *Dispatcher) {
@@ -118,16 +136,20 @@ type Dispatcher struct {
 	latencyLogger         gologger.IMultiLogger
 	resetMaxWorkerCount   chan bool
 	maxWorkersGaugeMetric *gologger.GaugeMetric
-	logger                *gologger.CustomLogger
+	logger                gologger.ILogger
+	reporter              *crashreport.Reporter
+	workers               []IWorker
+	quit                  chan struct{}
+	stopOnce              sync.Once
 }
 
 func (d *Dispatcher) run() {
 	// starting n number of workers
+	d.workers = make([]IWorker, d.maxWorkers)
 	for i := 0; i < d.maxWorkers; i++ {
-		go func(j int) {
-			worker := d.newWorker(d.workerPool, j) // Initialise a new worker
-			worker.Start()
-		}(i) // Start the worker
+		worker := d.newWorker(d.workerPool, i) // Initialise a new worker
+		d.workers[i] = worker
+		go worker.Start() // Start the worker
 	}
 	d.trackWorkers() // Start tracking used workers
 	go d.dispatch()  // Start the dispatcher
@@ -142,8 +164,13 @@ func (d *Dispatcher) dispatch() {
 			jobChannel := <-d.workerPool
 			// track number of workers processing concurrently
 			d.workerTracker <- d.maxWorkers - len(d.workerPool)
+			if d.reporter != nil {
+				job = &reportingJob{job: job, reporter: d.reporter, component: "dispatcher " + d.name}
+			}
 			// dispatch the job to the worker job channel
 			jobChannel <- job
+		case <-d.quit:
+			return
 		}
 	}
 }
@@ -163,11 +190,24 @@ func (d *Dispatcher) trackWorkers() {
 					d.logger.LogDebug("setting max workers to " + string(numWorkers))
 					d.latencyLogger.SetVal(int64(numWorkers), maxWorkerGaugeMetricID, d.name)
 				}
+			case <-d.quit:
+				return
 			}
 		}
 	}()
 }
 
+// Stop stops the workers and the goroutines of the dispatcher. The jobs still in JobQueue are not processed,
+// so stop sending jobs and let the queue drain before. JobQueue must not be used after Stop
+func (d *Dispatcher) Stop() {
+	d.stopOnce.Do(func() {
+		close(d.quit)
+		for _, worker := range d.workers {
+			worker.Stop()
+		}
+	})
+}
+
 //ResetDispatcherMaxWorkerUsed should be called whenever the max worker count needs to be reset
 func (d *Dispatcher) ResetDispatcherMaxWorkerUsed() {
 	d.logger.LogDebug("Reseting max worker count")
@@ -175,16 +215,17 @@ func (d *Dispatcher) ResetDispatcherMaxWorkerUsed() {
 }
 
 // NewDispatcher : returns a new dispatcher. When no options are given, it returns a dispatcher with default settings
-// 10 Workers and `newWorker` initialisation and default logger which logs to graylog @ 127.0.0.1:11100.
+// DefaultWorkers(IOBound) workers and `newWorker` initialisation and default logger which logs to graylog @ 127.0.0.1:11100.
 // This is not in use. So it is prety much useless.
 // Set log level to INFO to track max used workers.
 func NewDispatcher(dispatcherName string, options ...Option) *Dispatcher {
 	d := &Dispatcher{
 		name:                dispatcherName,
-		maxWorkers:          10,
+		maxWorkers:          DefaultWorkers(IOBound),
 		newWorker:           newWorker,
 		workerTracker:       make(chan int, 100),
 		resetMaxWorkerCount: make(chan bool, 10),
+		quit:                make(chan struct{}),
 	}
 
 	for _, option := range options {
@@ -214,3 +255,21 @@ func NewDispatcher(dispatcherName string, options ...Option) *Dispatcher {
 	d.run()
 	return d
 }
+
+// reportingJob reports a panic of the job with the crash reporter
+type reportingJob struct {
+	job       IJob
+	reporter  *crashreport.Reporter
+	component string
+}
+
+// Process processes the job and recovers from a panic
+func (j *reportingJob) Process() (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			j.reporter.Report(j.component, r)
+			err = errors.New("job panicked")
+		}
+	}()
+	return j.job.Process()
+}
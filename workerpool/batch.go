@@ -0,0 +1,307 @@
+package workerpool
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/carwale/golibraries/gologger"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// BatchError reports the errors of the items of a batch. Errors has an entry for every item of the batch
+// in the same order, nil for the items that succeeded. Return it from the process function of a
+// BatchDispatcher to fail only some of the items; any other error fails all the items of the batch
+type BatchError struct {
+	Errors []error
+}
+
+// NewBatchError returns a BatchError for a batch of size items
+func NewBatchError(size int) *BatchError {
+	return &BatchError{Errors: make([]error, size)}
+}
+
+// Failed returns the number of failed items
+func (e *BatchError) Failed() int {
+	failed := 0
+	for _, err := range e.Errors {
+		if err != nil {
+			failed++
+		}
+	}
+	return failed
+}
+
+// Error returns the number of failed items and the first error
+func (e *BatchError) Error() string {
+	for _, err := range e.Errors {
+		if err != nil {
+			return fmt.Sprintf("%d of %d items of the batch failed, first error: %v", e.Failed(), len(e.Errors), err)
+		}
+	}
+	return "no items of the batch failed"
+}
+
+// BatchOption sets a parameter for the BatchDispatcher
+type BatchOption func(c *batchConfig)
+
+type batchConfig struct {
+	size          int
+	maxAge        time.Duration
+	workers       int
+	queueSize     int
+	logger        gologger.ILogger
+	latencyLogger gologger.IMultiLogger
+}
+
+// SetBatchSize sets the number of items at which a batch is flushed. Default is 100
+func SetBatchSize(size int) BatchOption {
+	return func(c *batchConfig) {
+		if size > 0 {
+			c.size = size
+		}
+	}
+}
+
+// SetBatchMaxAge sets the time after the first item of a batch at which the batch is flushed
+// even if it is not full. Default is 1 second
+func SetBatchMaxAge(maxAge time.Duration) BatchOption {
+	return func(c *batchConfig) {
+		if maxAge > 0 {
+			c.maxAge = maxAge
+		}
+	}
+}
+
+// SetBatchWorkers sets the number of batches processed at the same time. Default is DefaultWorkers(IOBound)
+func SetBatchWorkers(workers int) BatchOption {
+	return func(c *batchConfig) {
+		if workers > 0 {
+			c.workers = workers
+		}
+	}
+}
+
+// SetBatchQueueSize sets the number of items that can wait to be batched. Submit blocks while the queue is full.
+// Default is the batch size
+func SetBatchQueueSize(queueSize int) BatchOption {
+	return func(c *batchConfig) {
+		if queueSize > 0 {
+			c.queueSize = queueSize
+		}
+	}
+}
+
+// SetBatchLogger sets the logger of the batch dispatcher
+func SetBatchLogger(logger gologger.ILogger) BatchOption {
+	return func(c *batchConfig) { c.logger = logger }
+}
+
+// SetBatchLatencyLogger sets the latency logger of the batch dispatcher
+func SetBatchLatencyLogger(latencyLogger gologger.IMultiLogger) BatchOption {
+	return func(c *batchConfig) { c.latencyLogger = latencyLogger }
+}
+
+const (
+	batchSizeMetricID    = "WORKERPOOL-BATCH-SIZE"
+	batchLatencyMetricID = "WORKERPOOL-BATCH-LATENCY"
+)
+
+var batchMetrics = gologger.NewMetricSet(func(logger gologger.ILogger) map[string]gologger.IMetricVec {
+	return map[string]gologger.IMetricVec{
+		batchSizeMetricID: gologger.NewValueHistogramMetric(prometheus.NewHistogramVec(
+			prometheus.HistogramOpts{
+				Name:    "workerpool_batch_size",
+				Help:    "Number of items in the batches of the batch dispatcher",
+				Buckets: prometheus.ExponentialBuckets(1, 2, 12),
+			},
+			[]string{"DispatcherName"},
+		), logger),
+		batchLatencyMetricID: gologger.NewHistogramMetric(prometheus.NewHistogramVec(
+			prometheus.HistogramOpts{
+				Name: "workerpool_batch_latency_milliseconds",
+				Help: "Processing latency of the batches of the batch dispatcher",
+			},
+			[]string{"DispatcherName"},
+		), logger),
+	}
+})
+
+// batchItem is a submitted item with the context and the future of its caller
+type batchItem[T any] struct {
+	ctx    context.Context
+	item   T
+	future *Future[struct{}]
+}
+
+// BatchDispatcher collects the submitted items into batches and processes every batch with one call,
+// to amortize the cost per item of sinks with bulk apis (e.g. elasticsearch bulk indexing).
+// A batch is flushed when it has the batch size items or when its first item is older than the max age.
+// The result of every item is reported to the Future returned by Submit
+type BatchDispatcher[T any] struct {
+	name    string
+	config  batchConfig
+	process func(context.Context, []T) error
+	items   chan batchItem[T]
+	batches chan []batchItem[T]
+	wg      sync.WaitGroup
+	stop    sync.Once
+}
+
+// NewBatchDispatcher returns a started batch dispatcher which calls process for every batch.
+// process is called with a background context as the items of a batch come from different callers;
+// items whose context is done before their batch is processed fail with the error of the context
+//
+//	d := workerpool.NewBatchDispatcher("es-index", indexDocuments, workerpool.SetBatchSize(500))
+//	err := d.Submit(ctx, document).Get(ctx)
+func NewBatchDispatcher[T any](dispatcherName string, process func(context.Context, []T) error, options ...BatchOption) *BatchDispatcher[T] {
+	config := batchConfig{
+		size:    100,
+		maxAge:  time.Second,
+		workers: DefaultWorkers(IOBound),
+	}
+	for _, option := range options {
+		option(&config)
+	}
+	if config.queueSize == 0 {
+		config.queueSize = config.size
+	}
+	if config.logger == nil {
+		config.logger = gologger.NewLogger(gologger.SetLogLevel("ERROR"))
+	}
+	if config.latencyLogger == nil {
+		config.latencyLogger = gologger.NewRateLatencyLogger(gologger.SetLogger(config.logger))
+	}
+	batchMetrics.AddTo(config.latencyLogger, config.logger)
+
+	d := &BatchDispatcher[T]{
+		name:    dispatcherName,
+		config:  config,
+		process: process,
+		items:   make(chan batchItem[T], config.queueSize),
+		batches: make(chan []batchItem[T]),
+	}
+	d.wg.Add(config.workers)
+	for i := 0; i < config.workers; i++ {
+		go d.work()
+	}
+	go d.collect()
+	config.logger.LogDebug("New batch dispatcher created " + dispatcherName)
+	return d
+}
+
+// Submit queues the item to be processed in a batch. It blocks while the queue is full.
+// If ctx is done before the item is queued or processed, the future fails with the error of ctx.
+// Submit must not be called after Stop
+func (d *BatchDispatcher[T]) Submit(ctx context.Context, item T) *Future[struct{}] {
+	future := newFuture[struct{}]()
+	select {
+	case d.items <- batchItem[T]{ctx: ctx, item: item, future: future}:
+	case <-ctx.Done():
+		future.complete(struct{}{}, ctx.Err())
+	}
+	return future
+}
+
+// Stop flushes the queued items and waits till all the batches are processed
+func (d *BatchDispatcher[T]) Stop() {
+	d.stop.Do(func() { close(d.items) })
+	d.wg.Wait()
+}
+
+// collect groups the items into batches and hands them to the workers
+func (d *BatchDispatcher[T]) collect() {
+	defer close(d.batches)
+	timer := time.NewTimer(d.config.maxAge)
+	timer.Stop()
+	var batch []batchItem[T]
+	flush := func() {
+		if len(batch) > 0 {
+			d.batches <- batch
+			batch = nil
+		}
+		if !timer.Stop() {
+			select {
+			case <-timer.C:
+			default:
+			}
+		}
+	}
+	for {
+		select {
+		case item, ok := <-d.items:
+			if !ok {
+				flush()
+				return
+			}
+			if len(batch) == 0 {
+				batch = make([]batchItem[T], 0, d.config.size)
+				timer.Reset(d.config.maxAge)
+			}
+			batch = append(batch, item)
+			if len(batch) >= d.config.size {
+				flush()
+			}
+		case <-timer.C:
+			flush()
+		}
+	}
+}
+
+func (d *BatchDispatcher[T]) work() {
+	defer d.wg.Done()
+	for batch := range d.batches {
+		d.processBatch(batch)
+	}
+}
+
+// processBatch processes the items whose context is not done and completes the futures of all the items
+func (d *BatchDispatcher[T]) processBatch(batch []batchItem[T]) {
+	live := batch[:0]
+	items := make([]T, 0, len(batch))
+	for _, item := range batch {
+		if err := item.ctx.Err(); err != nil {
+			item.future.complete(struct{}{}, err)
+			continue
+		}
+		live = append(live, item)
+		items = append(items, item.item)
+	}
+	if len(items) == 0 {
+		return
+	}
+	d.config.latencyLogger.IncVal(int64(len(items)), batchSizeMetricID, d.name)
+	start := time.Now()
+	err := d.call(items)
+	d.config.latencyLogger.Toc(start, batchLatencyMetricID, d.name)
+
+	batchErr, perItem := err.(*BatchError)
+	if perItem && batchErr == nil {
+		err, perItem = nil, false
+	}
+	if perItem && len(batchErr.Errors) != len(items) {
+		err = fmt.Errorf("batch error has %d errors for %d items: %w", len(batchErr.Errors), len(items), err)
+		perItem = false
+	}
+	if err != nil {
+		d.config.logger.LogDebugf("Batch of dispatcher %s failed: %v", d.name, err)
+	}
+	for i, item := range live {
+		if perItem {
+			item.future.complete(struct{}{}, batchErr.Errors[i])
+		} else {
+			item.future.complete(struct{}{}, err)
+		}
+	}
+}
+
+// call runs the process function. A panic fails all the items of the batch instead of the worker
+func (d *BatchDispatcher[T]) call(items []T) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("batch panicked: %v", r)
+		}
+	}()
+	return d.process(context.Background(), items)
+}
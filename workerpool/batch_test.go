@@ -0,0 +1,86 @@
+package workerpool
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestBatchDispatcherFlushesOnSize(t *testing.T) {
+	var lock sync.Mutex
+	var sizes []int
+	d := NewBatchDispatcher("test", func(ctx context.Context, items []int) error {
+		lock.Lock()
+		sizes = append(sizes, len(items))
+		lock.Unlock()
+		return nil
+	}, SetBatchSize(3), SetBatchMaxAge(time.Hour), SetBatchWorkers(1), SetBatchLatencyLogger(testMultiLogger{}))
+
+	futures := make([]*Future[struct{}], 6)
+	for i := range futures {
+		futures[i] = d.Submit(context.Background(), i)
+	}
+	if _, err := GetAll(context.Background(), futures); err != nil {
+		t.Fatal(err)
+	}
+	d.Stop()
+	if len(sizes) != 2 || sizes[0] != 3 || sizes[1] != 3 {
+		t.Errorf("batch sizes = %v, want [3 3]", sizes)
+	}
+}
+
+func TestBatchDispatcherFlushesOnAge(t *testing.T) {
+	d := NewBatchDispatcher("test", func(ctx context.Context, items []int) error { return nil },
+		SetBatchSize(100), SetBatchMaxAge(10*time.Millisecond), SetBatchLatencyLogger(testMultiLogger{}))
+	defer d.Stop()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if _, err := d.Submit(context.Background(), 1).Get(ctx); err != nil {
+		t.Errorf("Get() error = %v, want the partial batch to be flushed", err)
+	}
+}
+
+func TestBatchDispatcherReportsItemErrors(t *testing.T) {
+	failed := errors.New("mapping error")
+	d := NewBatchDispatcher("test", func(ctx context.Context, items []int) error {
+		batchErr := NewBatchError(len(items))
+		for i, item := range items {
+			if item%2 == 1 {
+				batchErr.Errors[i] = failed
+			}
+		}
+		return batchErr
+	}, SetBatchSize(4), SetBatchWorkers(1), SetBatchLatencyLogger(testMultiLogger{}))
+
+	cancelled, cancel := context.WithCancel(context.Background())
+	cancel()
+	futures := []*Future[struct{}]{
+		d.Submit(context.Background(), 0),
+		d.Submit(context.Background(), 1),
+		d.Submit(context.Background(), 2),
+		d.Submit(cancelled, 3),
+	}
+	d.Stop()
+	want := []error{nil, failed, nil, context.Canceled}
+	for i, future := range futures {
+		if _, err := future.Get(context.Background()); !errors.Is(err, want[i]) {
+			t.Errorf("item %d error = %v, want %v", i, err, want[i])
+		}
+	}
+}
+
+func TestBatchDispatcherFailsAllItemsOnBatchError(t *testing.T) {
+	failed := errors.New("cluster unavailable")
+	d := NewBatchDispatcher("test", func(ctx context.Context, items []int) error { return failed },
+		SetBatchSize(2), SetBatchLatencyLogger(testMultiLogger{}))
+	futures := []*Future[struct{}]{d.Submit(context.Background(), 1), d.Submit(context.Background(), 2)}
+	d.Stop()
+	for i, future := range futures {
+		if _, err := future.Get(context.Background()); err != failed {
+			t.Errorf("item %d error = %v, want %v", i, err, failed)
+		}
+	}
+}
@@ -0,0 +1,95 @@
+package workerpool
+
+import (
+	"math"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Workload decides the default number of workers of a pool
+type Workload int
+
+const (
+	// IOBound pools spend most of their time waiting on the network or the disk and get
+	// ioWorkersPerCPU workers for every available cpu. This is the default
+	IOBound Workload = iota
+	// CPUBound pools get one worker for every available cpu
+	CPUBound
+)
+
+const (
+	ioWorkersPerCPU = 8
+	cgroupRoot      = "/sys/fs/cgroup"
+)
+
+var (
+	availableCPUs     int
+	availableCPUsOnce sync.Once
+)
+
+// AvailableCPUs returns the number of cpus the process can use. It is the lower of GOMAXPROCS
+// and the cpu limit of the container (the cgroup cpu quota rounded up), and at least 1
+func AvailableCPUs() int {
+	availableCPUsOnce.Do(func() {
+		availableCPUs = cpusWithLimit(runtime.GOMAXPROCS(0), cgroupRoot)
+	})
+	return availableCPUs
+}
+
+// DefaultWorkers returns the default number of workers of a pool with the workload
+func DefaultWorkers(workload Workload) int {
+	if workload == CPUBound {
+		return AvailableCPUs()
+	}
+	return AvailableCPUs() * ioWorkersPerCPU
+}
+
+func cpusWithLimit(maxProcs int, root string) int {
+	cpus := maxProcs
+	if limit, ok := cgroupCPULimit(root); ok {
+		if quota := int(math.Ceil(limit)); quota < cpus {
+			cpus = quota
+		}
+	}
+	if cpus < 1 {
+		return 1
+	}
+	return cpus
+}
+
+// cgroupCPULimit returns the cpu quota of the cgroup in cpus. It reads cpu.max of cgroup v2 and
+// falls back to cpu.cfs_quota_us and cpu.cfs_period_us of cgroup v1. It returns false if there is no limit
+func cgroupCPULimit(root string) (float64, bool) {
+	if content, err := os.ReadFile(filepath.Join(root, "cpu.max")); err == nil {
+		fields := strings.Fields(string(content))
+		if len(fields) != 2 || fields[0] == "max" {
+			return 0, false
+		}
+		return cpuQuota(fields[0], fields[1])
+	}
+	quota, err := os.ReadFile(filepath.Join(root, "cpu", "cpu.cfs_quota_us"))
+	if err != nil {
+		return 0, false
+	}
+	period, err := os.ReadFile(filepath.Join(root, "cpu", "cpu.cfs_period_us"))
+	if err != nil {
+		return 0, false
+	}
+	return cpuQuota(strings.TrimSpace(string(quota)), strings.TrimSpace(string(period)))
+}
+
+func cpuQuota(quota, period string) (float64, bool) {
+	q, err := strconv.ParseFloat(quota, 64)
+	if err != nil || q <= 0 {
+		return 0, false
+	}
+	p, err := strconv.ParseFloat(period, 64)
+	if err != nil || p <= 0 {
+		return 0, false
+	}
+	return q / p, true
+}
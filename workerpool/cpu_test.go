@@ -0,0 +1,58 @@
+package workerpool
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func writeFile(t *testing.T, path string, content string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestCPUsWithLimit(t *testing.T) {
+	v2 := t.TempDir()
+	writeFile(t, filepath.Join(v2, "cpu.max"), "150000 100000\n")
+	unlimited := t.TempDir()
+	writeFile(t, filepath.Join(unlimited, "cpu.max"), "max 100000\n")
+	v1 := t.TempDir()
+	writeFile(t, filepath.Join(v1, "cpu", "cpu.cfs_quota_us"), "50000\n")
+	writeFile(t, filepath.Join(v1, "cpu", "cpu.cfs_period_us"), "100000\n")
+	v1Unlimited := t.TempDir()
+	writeFile(t, filepath.Join(v1Unlimited, "cpu", "cpu.cfs_quota_us"), "-1\n")
+	writeFile(t, filepath.Join(v1Unlimited, "cpu", "cpu.cfs_period_us"), "100000\n")
+
+	tests := []struct {
+		name     string
+		maxProcs int
+		root     string
+		want     int
+	}{
+		{"cgroup v2 quota is rounded up", 8, v2, 2},
+		{"cgroup v2 without limit", 8, unlimited, 8},
+		{"cgroup v1 quota below one cpu", 8, v1, 1},
+		{"cgroup v1 without limit", 4, v1Unlimited, 4},
+		{"gomaxprocs below the quota", 1, v2, 1},
+		{"no cgroup", 3, filepath.Join(v2, "missing"), 3},
+	}
+	for _, tt := range tests {
+		if got := cpusWithLimit(tt.maxProcs, tt.root); got != tt.want {
+			t.Errorf("%s: cpusWithLimit() = %d, want %d", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestDefaultWorkers(t *testing.T) {
+	if got := DefaultWorkers(CPUBound); got != AvailableCPUs() {
+		t.Errorf("DefaultWorkers(CPUBound) = %d, want %d", got, AvailableCPUs())
+	}
+	if got := DefaultWorkers(IOBound); got != AvailableCPUs()*ioWorkersPerCPU {
+		t.Errorf("DefaultWorkers(IOBound) = %d, want %d", got, AvailableCPUs()*ioWorkersPerCPU)
+	}
+}
@@ -0,0 +1,171 @@
+package workerpool
+
+import (
+	"sync"
+	"time"
+
+	"github.com/carwale/golibraries/gologger"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// IKeyedJob is a job with a key. Jobs with the same key do the same work, e.g. refresh the same cache entry
+type IKeyedJob interface {
+	IJob
+	Key() string
+}
+
+// DedupeOption sets a parameter for the DedupingDispatcher
+type DedupeOption func(d *DedupingDispatcher)
+
+// SetDedupeWindow suppresses the jobs submitted within the window after a job with the same key was accepted,
+// even if that job has already run. Default is 0 which only suppresses the jobs whose key is already queued
+func SetDedupeWindow(window time.Duration) DedupeOption {
+	return func(d *DedupingDispatcher) {
+		if window >= 0 {
+			d.window = window
+		}
+	}
+}
+
+// SetDedupeLogger sets the logger of the deduping dispatcher
+func SetDedupeLogger(logger gologger.ILogger) DedupeOption {
+	return func(d *DedupingDispatcher) { d.logger = logger }
+}
+
+// SetDedupeLatencyLogger sets the latency logger of the deduping dispatcher
+func SetDedupeLatencyLogger(latencyLogger gologger.IMultiLogger) DedupeOption {
+	return func(d *DedupingDispatcher) { d.latencyLogger = latencyLogger }
+}
+
+const dedupeSuppressedMetricID = "WORKERPOOL-DEDUPE-SUPPRESSED"
+
+var dedupeMetrics = gologger.NewMetricSet(func(logger gologger.ILogger) map[string]gologger.IMetricVec {
+	return map[string]gologger.IMetricVec{
+		dedupeSuppressedMetricID: gologger.NewCounterMetric(prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "workerpool_suppressed_duplicate_jobs_total",
+				Help: "Number of jobs suppressed because a job with the same key was pending or recently submitted",
+			},
+			[]string{"DispatcherName"},
+		), logger),
+	}
+})
+
+// DedupingDispatcher coalesces identical jobs before they reach a dispatcher. A job is suppressed if a job with
+// the same key is queued and has not started yet, or was accepted within the dedupe window. A job with the key
+// of a running job is accepted, so that the work done after the latest submit is never skipped
+//
+//	deduping := workerpool.NewDedupingDispatcher("cache-refresh", dispatcher, workerpool.SetDedupeWindow(time.Second))
+//	deduping.Submit(refreshJob)
+type DedupingDispatcher struct {
+	name          string
+	dispatcher    *Dispatcher
+	window        time.Duration
+	logger        gologger.ILogger
+	latencyLogger gologger.IMultiLogger
+
+	lock      sync.Mutex
+	queued    map[string]struct{}
+	accepted  map[string]time.Time
+	lastSweep time.Time
+}
+
+// NewDedupingDispatcher returns a deduping dispatcher that submits the accepted jobs to the job queue of dispatcher.
+// It panics if dispatcher is nil
+func NewDedupingDispatcher(name string, dispatcher *Dispatcher, options ...DedupeOption) *DedupingDispatcher {
+	if dispatcher == nil {
+		panic("deduping dispatcher " + name + " needs a dispatcher")
+	}
+	d := &DedupingDispatcher{
+		name:       name,
+		dispatcher: dispatcher,
+		queued:     make(map[string]struct{}),
+		accepted:   make(map[string]time.Time),
+		lastSweep:  time.Now(),
+	}
+	for _, option := range options {
+		option(d)
+	}
+	if d.logger == nil {
+		d.logger = gologger.NewLogger(gologger.SetLogLevel("ERROR"))
+	}
+	if d.latencyLogger == nil {
+		d.latencyLogger = gologger.NewRateLatencyLogger(gologger.SetLogger(d.logger))
+	}
+	dedupeMetrics.AddTo(d.latencyLogger, d.logger)
+	return d
+}
+
+// Submit queues the job on the dispatcher unless it is a duplicate. It returns false if the job was suppressed.
+// Like sending to the JobQueue of the dispatcher, it blocks while the queue is full
+func (d *DedupingDispatcher) Submit(job IKeyedJob) bool {
+	key := job.Key()
+	now := time.Now()
+	d.lock.Lock()
+	if d.isDuplicate(key, now) {
+		d.lock.Unlock()
+		d.latencyLogger.IncVal(1, dedupeSuppressedMetricID, d.name)
+		return false
+	}
+	d.queued[key] = struct{}{}
+	if d.window > 0 {
+		d.accepted[key] = now
+		d.sweep(now)
+	}
+	d.lock.Unlock()
+
+	d.dispatcher.JobQueue <- &dedupeJob{job: job, key: key, dispatcher: d}
+	return true
+}
+
+// Pending returns the number of accepted jobs that have not started yet
+func (d *DedupingDispatcher) Pending() int {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	return len(d.queued)
+}
+
+// isDuplicate must be called with the lock held
+func (d *DedupingDispatcher) isDuplicate(key string, now time.Time) bool {
+	if _, ok := d.queued[key]; ok {
+		return true
+	}
+	if acceptedAt, ok := d.accepted[key]; ok && now.Sub(acceptedAt) < d.window {
+		return true
+	}
+	return false
+}
+
+// sweep removes the keys accepted before the window once every window, so that the keys of
+// jobs that are never submitted again do not pile up. It must be called with the lock held
+func (d *DedupingDispatcher) sweep(now time.Time) {
+	if now.Sub(d.lastSweep) < d.window {
+		return
+	}
+	for key, acceptedAt := range d.accepted {
+		if now.Sub(acceptedAt) >= d.window {
+			delete(d.accepted, key)
+		}
+	}
+	d.lastSweep = now
+}
+
+// started releases the key when the job is picked up by a worker
+func (d *DedupingDispatcher) started(key string) {
+	d.lock.Lock()
+	delete(d.queued, key)
+	d.lock.Unlock()
+}
+
+// dedupeJob releases the key of the job before processing it
+type dedupeJob struct {
+	job        IKeyedJob
+	key        string
+	dispatcher *DedupingDispatcher
+}
+
+// Process processes the job
+func (j *dedupeJob) Process() error {
+	j.dispatcher.started(j.key)
+	return j.job.Process()
+}
@@ -0,0 +1,62 @@
+package workerpool
+
+import (
+	"testing"
+	"time"
+)
+
+type keyedJob struct {
+	key  string
+	runs chan string
+}
+
+func (j keyedJob) Key() string { return j.key }
+
+func (j keyedJob) Process() error {
+	j.runs <- j.key
+	return nil
+}
+
+// newTestDedupingDispatcher returns a deduping dispatcher whose jobs are started by the test
+func newTestDedupingDispatcher(options ...DedupeOption) (*DedupingDispatcher, chan IJob) {
+	queue := make(chan IJob, 10)
+	options = append(options, SetDedupeLatencyLogger(testMultiLogger{}))
+	return NewDedupingDispatcher("test", &Dispatcher{JobQueue: queue}, options...), queue
+}
+
+func TestDedupingDispatcherCoalescesQueuedJobs(t *testing.T) {
+	d, queue := newTestDedupingDispatcher()
+	runs := make(chan string, 10)
+
+	if !d.Submit(keyedJob{"a", runs}) || !d.Submit(keyedJob{"b", runs}) {
+		t.Fatal("Submit() suppressed the first jobs of the keys")
+	}
+	if d.Submit(keyedJob{"a", runs}) {
+		t.Error("Submit() accepted a job whose key is queued")
+	}
+	if d.Pending() != 2 {
+		t.Errorf("Pending() = %d, want 2", d.Pending())
+	}
+	(<-queue).Process()
+	if !d.Submit(keyedJob{"a", runs}) {
+		t.Error("Submit() suppressed a job whose key has started")
+	}
+}
+
+func TestDedupingDispatcherWindow(t *testing.T) {
+	d, queue := newTestDedupingDispatcher(SetDedupeWindow(20 * time.Millisecond))
+	runs := make(chan string, 10)
+
+	d.Submit(keyedJob{"a", runs})
+	(<-queue).Process()
+	if d.Submit(keyedJob{"a", runs}) {
+		t.Error("Submit() accepted a job within the window")
+	}
+	time.Sleep(25 * time.Millisecond)
+	if !d.Submit(keyedJob{"a", runs}) {
+		t.Error("Submit() suppressed a job after the window")
+	}
+	if len(d.accepted) != 1 {
+		t.Errorf("accepted keys = %d, want 1", len(d.accepted))
+	}
+}
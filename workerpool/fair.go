@@ -0,0 +1,263 @@
+package workerpool
+
+import (
+	"errors"
+	"sync"
+
+	"github.com/carwale/golibraries/gologger"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// ErrTenantQueueFull is returned by FairDispatcher.Submit when the tenant already has the maximum number of queued jobs
+var ErrTenantQueueFull = errors.New("tenant queue is full")
+
+// ErrDispatcherStopped is returned by FairDispatcher.Submit after Stop
+var ErrDispatcherStopped = errors.New("dispatcher is stopped")
+
+// FairOption sets a parameter for the FairDispatcher
+type FairOption func(d *FairDispatcher)
+
+// SetFairWorkers sets the number of workers. Default is DefaultWorkers(IOBound)
+func SetFairWorkers(workers int) FairOption {
+	return func(d *FairDispatcher) {
+		if workers > 0 {
+			d.workers = workers
+		}
+	}
+}
+
+// SetTenantQueueSize sets the number of jobs a tenant can have queued. Submit returns ErrTenantQueueFull
+// for the jobs above the quota. Default is 100
+func SetTenantQueueSize(queueSize int) FairOption {
+	return func(d *FairDispatcher) {
+		if queueSize > 0 {
+			d.tenantQueueSize = queueSize
+		}
+	}
+}
+
+// SetTenantConcurrency sets the number of jobs of a tenant that can run at the same time.
+// Default is 0 which lets a tenant use all the workers when the other tenants have no jobs
+func SetTenantConcurrency(concurrency int) FairOption {
+	return func(d *FairDispatcher) {
+		if concurrency >= 0 {
+			d.tenantConcurrency = concurrency
+		}
+	}
+}
+
+// SetTenantWeight sets the weight of the tenant. A tenant with weight n gets n jobs dispatched for every
+// job of a tenant with weight 1 when both have queued jobs. Default weight is 1
+func SetTenantWeight(tenant string, weight int) FairOption {
+	return func(d *FairDispatcher) {
+		if weight > 0 {
+			d.weights[tenant] = weight
+		}
+	}
+}
+
+// SetFairLogger sets the logger of the dispatcher
+func SetFairLogger(logger gologger.ILogger) FairOption {
+	return func(d *FairDispatcher) { d.logger = logger }
+}
+
+// SetFairLatencyLogger sets the latency logger of the dispatcher
+func SetFairLatencyLogger(latencyLogger gologger.IMultiLogger) FairOption {
+	return func(d *FairDispatcher) { d.latencyLogger = latencyLogger }
+}
+
+const fairRejectedMetricID = "FAIR-DISPATCHER-REJECTED"
+
+var fairMetrics = gologger.NewMetricSet(func(logger gologger.ILogger) map[string]gologger.IMetricVec {
+	return map[string]gologger.IMetricVec{
+		fairRejectedMetricID: gologger.NewCounterMetric(prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "fair_dispatcher_rejected_jobs_total",
+				Help: "Number of jobs rejected because the queue of the tenant was full",
+			},
+			[]string{"DispatcherName"},
+		), logger),
+	}
+})
+
+// tenantQueue is the queue of a tenant. credit is the number of jobs the tenant can still get
+// dispatched in the current round
+type tenantQueue struct {
+	name    string
+	jobs    []IJob
+	running int
+	weight  int
+	credit  int
+	active  bool
+}
+
+// FairDispatcher shares a fixed set of workers between tenants (e.g. dealers). Every tenant has its own
+// bounded queue and the workers take the jobs of the tenants in weighted round robin, so a tenant with
+// a bulk upload only delays the jobs of the other tenants by its share of the workers
+//
+//	if err := dispatcher.Submit(dealerID, job); err == workerpool.ErrTenantQueueFull {
+//		// ask the dealer to retry later
+//	}
+type FairDispatcher struct {
+	name              string
+	workers           int
+	tenantQueueSize   int
+	tenantConcurrency int
+	weights           map[string]int
+	logger            gologger.ILogger
+	latencyLogger     gologger.IMultiLogger
+
+	lock    sync.Mutex
+	cond    *sync.Cond
+	tenants map[string]*tenantQueue
+	active  []*tenantQueue // tenants with queued jobs in round robin order
+	cursor  int
+	stopped bool
+	wg      sync.WaitGroup
+}
+
+// NewFairDispatcher returns a started fair dispatcher
+func NewFairDispatcher(dispatcherName string, options ...FairOption) *FairDispatcher {
+	d := &FairDispatcher{
+		name:            dispatcherName,
+		workers:         DefaultWorkers(IOBound),
+		tenantQueueSize: 100,
+		weights:         make(map[string]int),
+		tenants:         make(map[string]*tenantQueue),
+	}
+	for _, option := range options {
+		option(d)
+	}
+	if d.logger == nil {
+		d.logger = gologger.NewLogger(gologger.SetLogLevel("ERROR"))
+	}
+	if d.latencyLogger == nil {
+		d.latencyLogger = gologger.NewRateLatencyLogger(gologger.SetLogger(d.logger))
+	}
+	fairMetrics.AddTo(d.latencyLogger, d.logger)
+	d.cond = sync.NewCond(&d.lock)
+	for i := 0; i < d.workers; i++ {
+		d.wg.Add(1)
+		go d.work()
+	}
+	d.logger.LogDebug("New fair dispatcher created " + dispatcherName)
+	return d
+}
+
+// Submit queues the job of the tenant. It never blocks: it returns ErrTenantQueueFull if the tenant
+// has reached its queue quota and ErrDispatcherStopped after Stop
+func (d *FairDispatcher) Submit(tenant string, job IJob) error {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	if d.stopped {
+		return ErrDispatcherStopped
+	}
+	t, ok := d.tenants[tenant]
+	if !ok {
+		weight := d.weights[tenant]
+		if weight == 0 {
+			weight = 1
+		}
+		t = &tenantQueue{name: tenant, weight: weight}
+		d.tenants[tenant] = t
+	}
+	if len(t.jobs) >= d.tenantQueueSize {
+		d.latencyLogger.IncVal(1, fairRejectedMetricID, d.name)
+		return ErrTenantQueueFull
+	}
+	t.jobs = append(t.jobs, job)
+	if !t.active {
+		t.active = true
+		t.credit = t.weight
+		d.active = append(d.active, t)
+	}
+	d.cond.Signal()
+	return nil
+}
+
+// Queued returns the number of queued jobs of the tenant
+func (d *FairDispatcher) Queued(tenant string) int {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	if t, ok := d.tenants[tenant]; ok {
+		return len(t.jobs)
+	}
+	return 0
+}
+
+// Stop stops accepting jobs and waits till all the queued jobs are processed
+func (d *FairDispatcher) Stop() {
+	d.lock.Lock()
+	d.stopped = true
+	d.cond.Broadcast()
+	d.lock.Unlock()
+	d.wg.Wait()
+}
+
+func (d *FairDispatcher) work() {
+	defer d.wg.Done()
+	for {
+		d.lock.Lock()
+		t, job := d.next()
+		for job == nil {
+			if d.stopped && len(d.active) == 0 {
+				d.lock.Unlock()
+				return
+			}
+			d.cond.Wait()
+			t, job = d.next()
+		}
+		d.lock.Unlock()
+
+		if err := job.Process(); err != nil {
+			d.logger.LogDebugf("Job of fair dispatcher %s failed: %v", d.name, err)
+		}
+
+		d.lock.Lock()
+		t.running--
+		if t.running == 0 && !t.active {
+			delete(d.tenants, t.name)
+		}
+		// A slot of the tenant is free, a waiting worker may be able to take its next job
+		d.cond.Broadcast()
+		d.lock.Unlock()
+	}
+}
+
+// next returns the next job in weighted round robin order. The tenant at the cursor keeps getting jobs
+// till its credit for the round is used up. Tenants at their concurrency quota are skipped.
+// It returns a nil job if no tenant can run a job. It must be called with the lock held
+func (d *FairDispatcher) next() (*tenantQueue, IJob) {
+	n := len(d.active)
+	// Every tenant is visited at most twice: once to refill its credit and once to use it
+	for i := 0; i <= 2*n && n > 0; i++ {
+		t := d.active[d.cursor]
+		if t.credit > 0 && (d.tenantConcurrency == 0 || t.running < d.tenantConcurrency) {
+			job := t.jobs[0]
+			t.jobs[0] = nil
+			t.jobs = t.jobs[1:]
+			t.credit--
+			t.running++
+			if len(t.jobs) == 0 {
+				d.deactivate()
+			}
+			return t, job
+		}
+		t.credit = t.weight
+		d.cursor = (d.cursor + 1) % n
+	}
+	return nil, nil
+}
+
+// deactivate removes the tenant at the cursor from the round robin. The cursor moves to the next tenant
+func (d *FairDispatcher) deactivate() {
+	t := d.active[d.cursor]
+	t.active = false
+	t.jobs = nil
+	copy(d.active[d.cursor:], d.active[d.cursor+1:])
+	d.active[len(d.active)-1] = nil
+	d.active = d.active[:len(d.active)-1]
+	if d.cursor >= len(d.active) {
+		d.cursor = 0
+	}
+}
@@ -0,0 +1,115 @@
+package workerpool
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/carwale/golibraries/gologger"
+)
+
+type testMultiLogger struct{}
+
+func (testMultiLogger) AddNewMetric(string, gologger.IMetricVec) {}
+func (testMultiLogger) Tic() time.Time                           { return time.Now() }
+func (testMultiLogger) Toc(time.Time, string, ...string)         {}
+func (testMultiLogger) IncVal(int64, string, ...string)          {}
+func (testMultiLogger) SubVal(int64, string, ...string)          {}
+func (testMultiLogger) SetVal(int64, string, ...string)          {}
+
+type funcJob func() error
+
+func (f funcJob) Process() error { return f() }
+
+// recorder records the order in which the jobs of the tenants ran
+type recorder struct {
+	lock  sync.Mutex
+	order []string
+}
+
+func (r *recorder) job(tenant string, gate <-chan struct{}) IJob {
+	return funcJob(func() error {
+		if gate != nil {
+			<-gate
+		}
+		r.lock.Lock()
+		r.order = append(r.order, tenant)
+		r.lock.Unlock()
+		return nil
+	})
+}
+
+func TestFairDispatcherInterleavesTenants(t *testing.T) {
+	d := NewFairDispatcher("test", SetFairWorkers(1), SetFairLatencyLogger(testMultiLogger{}), SetTenantWeight("gold", 2))
+	r := &recorder{}
+	gate := make(chan struct{})
+	// The first job holds the only worker till all the jobs are queued
+	d.Submit("bulk", r.job("bulk", gate))
+	for d.Queued("bulk") != 0 {
+		time.Sleep(time.Millisecond)
+	}
+	for i := 0; i < 5; i++ {
+		d.Submit("bulk", r.job("bulk", nil))
+	}
+	d.Submit("small", r.job("small", nil))
+	for i := 0; i < 3; i++ {
+		d.Submit("gold", r.job("gold", nil))
+	}
+	close(gate)
+	d.Stop()
+
+	want := []string{"bulk", "bulk", "small", "gold", "gold", "bulk", "gold", "bulk", "bulk", "bulk"}
+	if len(r.order) != len(want) {
+		t.Fatalf("order = %v, want %v", r.order, want)
+	}
+	for i := range want {
+		if r.order[i] != want[i] {
+			t.Fatalf("order = %v, want %v", r.order, want)
+		}
+	}
+}
+
+func TestFairDispatcherQuotas(t *testing.T) {
+	d := NewFairDispatcher("test", SetFairWorkers(4), SetFairLatencyLogger(testMultiLogger{}),
+		SetTenantQueueSize(2), SetTenantConcurrency(1))
+	gate := make(chan struct{})
+	var lock sync.Mutex
+	running, maxRunning := 0, 0
+	job := funcJob(func() error {
+		lock.Lock()
+		running++
+		if running > maxRunning {
+			maxRunning = running
+		}
+		lock.Unlock()
+		<-gate
+		lock.Lock()
+		running--
+		lock.Unlock()
+		return nil
+	})
+
+	if err := d.Submit("bulk", job); err != nil {
+		t.Fatal(err)
+	}
+	// Wait till the first job runs so that it no longer counts against the queue quota
+	for d.Queued("bulk") != 0 {
+		time.Sleep(time.Millisecond)
+	}
+	for i := 0; i < 2; i++ {
+		if err := d.Submit("bulk", job); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := d.Submit("bulk", job); err != ErrTenantQueueFull {
+		t.Errorf("Submit() error = %v, want ErrTenantQueueFull", err)
+	}
+	close(gate)
+	d.Stop()
+	if maxRunning != 1 {
+		t.Errorf("max running jobs of the tenant = %d, want 1", maxRunning)
+	}
+	if err := d.Submit("bulk", job); err != ErrDispatcherStopped {
+		t.Errorf("Submit() after Stop error = %v, want ErrDispatcherStopped", err)
+	}
+}
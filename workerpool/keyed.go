@@ -0,0 +1,106 @@
+package workerpool
+
+import (
+	"hash/fnv"
+	"sync"
+
+	"github.com/carwale/golibraries/gologger"
+)
+
+// KeyedOption sets a parameter for the KeyedDispatcher
+type KeyedOption func(d *KeyedDispatcher)
+
+// SetKeyedWorkers sets the number of workers. Default is DefaultWorkers(IOBound)
+func SetKeyedWorkers(workers int) KeyedOption {
+	return func(d *KeyedDispatcher) {
+		if workers > 0 {
+			d.workers = workers
+		}
+	}
+}
+
+// SetKeyedWorkload sets the number of workers to the default of the workload
+func SetKeyedWorkload(workload Workload) KeyedOption {
+	return func(d *KeyedDispatcher) { d.workers = DefaultWorkers(workload) }
+}
+
+// SetKeyedQueueSize sets the size of the queue of every worker. Default is 100
+func SetKeyedQueueSize(queueSize int) KeyedOption {
+	return func(d *KeyedDispatcher) {
+		if queueSize > 0 {
+			d.queueSize = queueSize
+		}
+	}
+}
+
+// SetKeyedLogger sets the logger of the dispatcher
+func SetKeyedLogger(logger gologger.ILogger) KeyedOption {
+	return func(d *KeyedDispatcher) { d.logger = logger }
+}
+
+// KeyedDispatcher runs jobs on a fixed set of workers. Jobs with the same key always run on the same worker
+// in the order they were submitted, jobs with different keys run in parallel.
+// It is used when the order of the jobs of an entity (e.g. the messages of a kafka key) has to be kept
+type KeyedDispatcher struct {
+	name      string
+	workers   int
+	queueSize int
+	queues    []chan IJob
+	logger    gologger.ILogger
+	wg        sync.WaitGroup
+	stopOnce  sync.Once
+}
+
+// NewKeyedDispatcher returns a started keyed dispatcher
+func NewKeyedDispatcher(dispatcherName string, options ...KeyedOption) *KeyedDispatcher {
+	d := &KeyedDispatcher{
+		name:      dispatcherName,
+		workers:   DefaultWorkers(IOBound),
+		queueSize: 100,
+	}
+	for _, option := range options {
+		option(d)
+	}
+	if d.logger == nil {
+		d.logger = gologger.NewLogger(gologger.SetLogLevel("ERROR"))
+	}
+	d.queues = make([]chan IJob, d.workers)
+	for i := range d.queues {
+		d.queues[i] = make(chan IJob, d.queueSize)
+		d.wg.Add(1)
+		go d.work(d.queues[i])
+	}
+	d.logger.LogDebug("New keyed dispatcher created " + dispatcherName)
+	return d
+}
+
+func (d *KeyedDispatcher) work(queue chan IJob) {
+	defer d.wg.Done()
+	for job := range queue {
+		if err := job.Process(); err != nil {
+			d.logger.LogDebugf("Job of keyed dispatcher %s failed: %v", d.name, err)
+		}
+	}
+}
+
+// Submit queues the job on the worker of the key. It blocks while the queue of the worker is full.
+// Submit must not be called after Stop
+func (d *KeyedDispatcher) Submit(key []byte, job IJob) {
+	d.queues[d.worker(key)] <- job
+}
+
+// Stop stops accepting jobs and waits till all the queued jobs are processed
+func (d *KeyedDispatcher) Stop() {
+	d.stopOnce.Do(func() {
+		for _, queue := range d.queues {
+			close(queue)
+		}
+	})
+	d.wg.Wait()
+}
+
+func (d *KeyedDispatcher) worker(key []byte) int {
+	h := fnv.New32a()
+	h.Write(key)
+	return int(h.Sum32() % uint32(d.workers))
+}
@@ -0,0 +1,97 @@
+package workerpool
+
+import (
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestKeyedDispatcherKeepsTheOrderOfAKey(t *testing.T) {
+	d := NewKeyedDispatcher("test", SetKeyedWorkers(4), SetKeyedQueueSize(10))
+	var lock sync.Mutex
+	processed := map[string][]int{}
+	keys := []string{"stock-1", "stock-2", "stock-3", "stock-4", "stock-5"}
+	for i := 0; i < 50; i++ {
+		for _, key := range keys {
+			key, i := key, i
+			d.Submit([]byte(key), funcJob(func() error {
+				if i%7 == 0 {
+					time.Sleep(time.Millisecond)
+				}
+				lock.Lock()
+				processed[key] = append(processed[key], i)
+				lock.Unlock()
+				return nil
+			}))
+		}
+	}
+	d.Stop()
+
+	for _, key := range keys {
+		if len(processed[key]) != 50 {
+			t.Fatalf("%s processed %d jobs, want 50", key, len(processed[key]))
+		}
+		for i, seq := range processed[key] {
+			if seq != i {
+				t.Fatalf("jobs of %s ran in order %v", key, processed[key])
+			}
+		}
+	}
+}
+
+func TestKeyedDispatcherRunsKeysInParallel(t *testing.T) {
+	d := NewKeyedDispatcher("test", SetKeyedWorkers(4))
+	defer d.Stop()
+	first := []byte("stock-0")
+	var second []byte
+	for i := 1; second == nil; i++ {
+		if key := []byte(fmt.Sprintf("stock-%d", i)); d.worker(key) != d.worker(first) {
+			second = key
+		}
+	}
+
+	// The job of the first key waits for the job of the second key, which only runs if they are on
+	// different workers
+	secondRan := make(chan struct{})
+	done := make(chan struct{})
+	d.Submit(first, funcJob(func() error {
+		select {
+		case <-secondRan:
+		case <-time.After(time.Second):
+			t.Error("the job of the second key did not run while the first key was busy")
+		}
+		close(done)
+		return nil
+	}))
+	d.Submit(second, funcJob(func() error {
+		close(secondRan)
+		return nil
+	}))
+	<-done
+}
+
+func TestKeyedDispatcherStopDrainsTheQueues(t *testing.T) {
+	d := NewKeyedDispatcher("test", SetKeyedWorkers(2), SetKeyedQueueSize(20))
+	release := make(chan struct{})
+	var processed int32
+	for i := 0; i < 20; i++ {
+		i := i
+		d.Submit([]byte(fmt.Sprintf("stock-%d", i)), funcJob(func() error {
+			if i == 0 {
+				<-release
+			}
+			atomic.AddInt32(&processed, 1)
+			return nil
+		}))
+	}
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		close(release)
+	}()
+	d.Stop()
+	if processed != 20 {
+		t.Errorf("processed %d jobs before Stop returned, want 20", processed)
+	}
+}
@@ -0,0 +1,218 @@
+package workerpool
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/carwale/golibraries/gologger"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Stage is a step of a Pipeline. Process is called for every item that reaches the stage and its output is passed
+// to the next stage. Returning a nil output without an error drops the item. The output of the last stage is ignored
+type Stage struct {
+	Name string
+	// Workers is the number of workers of the stage. Default is DefaultWorkers(IOBound)
+	Workers int
+	// QueueSize is the size of the queue in front of the stage. When it is full the previous stage
+	// (or Submit for the first stage) blocks. Default is the number of workers
+	QueueSize int
+	Process   func(ctx context.Context, item interface{}) (interface{}, error)
+}
+
+// PipelineOption sets a parameter for the Pipeline
+type PipelineOption func(p *Pipeline)
+
+// SetPipelineLogger sets the logger of the pipeline and its dispatchers
+func SetPipelineLogger(logger gologger.ILogger) PipelineOption {
+	return func(p *Pipeline) { p.logger = logger }
+}
+
+// SetPipelineLatencyLogger sets the latency logger of the pipeline and its dispatchers
+func SetPipelineLatencyLogger(latencyLogger gologger.IMultiLogger) PipelineOption {
+	return func(p *Pipeline) { p.latencyLogger = latencyLogger }
+}
+
+// SetPipelineErrorHandler sets the function called when a stage returns an error. The item is dropped after it.
+// By default the error is logged
+func SetPipelineErrorHandler(handler func(stage string, item interface{}, err error)) PipelineOption {
+	return func(p *Pipeline) { p.errorHandler = handler }
+}
+
+const (
+	pipelineLatencyMetricID      = "PIPELINE-LATENCY"
+	pipelineStageLatencyMetricID = "PIPELINE-STAGE-LATENCY"
+	pipelineErrorsMetricID       = "PIPELINE-ERRORS"
+)
+
+// pipelineMetrics are registered with prometheus once and added to every latency logger used by a pipeline
+var pipelineMetrics = gologger.NewMetricSet(func(logger gologger.ILogger) map[string]gologger.IMetricVec {
+	return map[string]gologger.IMetricVec{
+		pipelineLatencyMetricID: gologger.NewHistogramMetric(prometheus.NewHistogramVec(
+			prometheus.HistogramOpts{
+				Name: "pipeline_latency_milliseconds",
+				Help: "End to end latency of the items of the pipeline",
+			},
+			[]string{"Pipeline"},
+		), logger),
+		pipelineStageLatencyMetricID: gologger.NewHistogramMetric(prometheus.NewHistogramVec(
+			prometheus.HistogramOpts{
+				Name: "pipeline_stage_latency_milliseconds",
+				Help: "Processing latency of the stages of the pipeline",
+			},
+			[]string{"Pipeline", "Stage"},
+		), logger),
+		pipelineErrorsMetricID: gologger.NewCounterMetric(prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "pipeline_stage_errors_total",
+				Help: "Number of items that failed in the stages of the pipeline",
+			},
+			[]string{"Pipeline", "Stage"},
+		), logger),
+	}
+})
+
+// Pipeline chains stages (e.g. parse→enrich→persist) which run on their own dispatchers with bounded queues
+// between them. A slow stage fills its queue and blocks the previous stage, so backpressure propagates to Submit.
+// The end to end latency of the items and the latency and errors of every stage are exported as metrics
+type Pipeline struct {
+	name          string
+	stages        []Stage
+	dispatchers   []*Dispatcher
+	logger        gologger.ILogger
+	latencyLogger gologger.IMultiLogger
+	errorHandler  func(stage string, item interface{}, err error)
+	inFlight      sync.WaitGroup
+	lock          sync.RWMutex
+	stopped       bool
+}
+
+// pipelineJob is an item on its way through the pipeline
+type pipelineJob struct {
+	pipeline *Pipeline
+	ctx      context.Context
+	stage    int
+	item     interface{}
+	start    time.Time
+}
+
+// NewPipeline returns a pipeline with the stages in the given order. It panics if there are no stages
+func NewPipeline(pipelineName string, stages []Stage, options ...PipelineOption) *Pipeline {
+	if len(stages) == 0 {
+		panic("pipeline " + pipelineName + " needs at least one stage")
+	}
+	p := &Pipeline{
+		name:   pipelineName,
+		stages: stages,
+	}
+	for _, option := range options {
+		option(p)
+	}
+	if p.logger == nil {
+		p.logger = gologger.NewLogger(gologger.SetLogLevel("ERROR"))
+	}
+	if p.latencyLogger == nil {
+		p.latencyLogger = gologger.NewRateLatencyLogger(gologger.SetLogger(p.logger))
+	}
+	if p.errorHandler == nil {
+		p.errorHandler = func(stage string, item interface{}, err error) {
+			p.logger.LogErrorMessage("Pipeline stage failed", err,
+				gologger.Pair{Key: "pipeline", Value: p.name}, gologger.Pair{Key: "stage", Value: stage})
+		}
+	}
+	pipelineMetrics.AddTo(p.latencyLogger, p.logger)
+
+	p.dispatchers = make([]*Dispatcher, len(stages))
+	for i, stage := range stages {
+		workers := stage.Workers
+		if workers <= 0 {
+			workers = DefaultWorkers(IOBound)
+		}
+		queueSize := stage.QueueSize
+		if queueSize <= 0 {
+			queueSize = workers
+		}
+		p.dispatchers[i] = NewDispatcher(pipelineName+"-"+stage.Name,
+			SetMaxWorkers(workers),
+			SetJobQueue(make(chan IJob, queueSize)),
+			SetLogger(p.logger),
+			SetLatencyLogger(p.latencyLogger))
+	}
+	return p
+}
+
+// Submit queues the item in the first stage. It blocks while the queue is full and returns the error of ctx
+// if ctx is done before the item is queued and ErrDispatcherStopped after Stop. ctx is passed to all the stages
+func (p *Pipeline) Submit(ctx context.Context, item interface{}) error {
+	p.lock.RLock()
+	if p.stopped {
+		p.lock.RUnlock()
+		return ErrDispatcherStopped
+	}
+	p.inFlight.Add(1)
+	p.lock.RUnlock()
+	job := &pipelineJob{pipeline: p, ctx: ctx, item: item, start: time.Now()}
+	select {
+	case p.dispatchers[0].JobQueue <- job:
+		return nil
+	case <-ctx.Done():
+		p.inFlight.Done()
+		return ctx.Err()
+	}
+}
+
+// Wait blocks till all the submitted items have left the pipeline
+func (p *Pipeline) Wait() {
+	p.inFlight.Wait()
+}
+
+// Stop stops accepting items, waits till the submitted items have left the pipeline and stops the dispatchers
+// of the stages
+func (p *Pipeline) Stop() {
+	p.lock.Lock()
+	p.stopped = true
+	p.lock.Unlock()
+	p.inFlight.Wait()
+	for _, dispatcher := range p.dispatchers {
+		dispatcher.Stop()
+	}
+}
+
+// Process runs the stage of the item and passes the output to the next stage. A panic of the stage is
+// handled like an error, so that the item still leaves the pipeline
+func (j *pipelineJob) Process() (err error) {
+	p := j.pipeline
+	stage := p.stages[j.stage]
+	forwarded := false
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("stage %s of pipeline %s panicked: %v", stage.Name, p.name, r)
+			p.latencyLogger.IncVal(1, pipelineErrorsMetricID, p.name, stage.Name)
+			p.errorHandler(stage.Name, j.item, err)
+		}
+		if !forwarded {
+			p.inFlight.Done()
+		}
+	}()
+	stageStart := p.latencyLogger.Tic()
+	output, err := stage.Process(j.ctx, j.item)
+	p.latencyLogger.Toc(stageStart, pipelineStageLatencyMetricID, p.name, stage.Name)
+	if err != nil {
+		p.latencyLogger.IncVal(1, pipelineErrorsMetricID, p.name, stage.Name)
+		p.errorHandler(stage.Name, j.item, err)
+		return err
+	}
+	if j.stage == len(p.stages)-1 {
+		p.latencyLogger.Toc(j.start, pipelineLatencyMetricID, p.name)
+		return nil
+	}
+	if output == nil {
+		return nil
+	}
+	// Blocks while the queue of the next stage is full, which keeps the worker of this stage busy
+	p.dispatchers[j.stage+1].JobQueue <- &pipelineJob{pipeline: p, ctx: j.ctx, stage: j.stage + 1, item: output, start: j.start}
+	forwarded = true
+	return nil
+}
@@ -0,0 +1,166 @@
+package workerpool
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/carwale/golibraries/gologger"
+)
+
+// metricsLogger records the metrics added to it
+type metricsLogger struct {
+	testMultiLogger
+	lock    sync.Mutex
+	metrics map[string]bool
+}
+
+func (l *metricsLogger) AddNewMetric(id string, metric gologger.IMetricVec) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	l.metrics[id] = true
+}
+
+// waitWithTimeout fails the test if the pipeline does not drain in time
+func waitWithTimeout(t *testing.T, p *Pipeline) {
+	t.Helper()
+	done := make(chan struct{})
+	go func() {
+		p.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("Wait() did not return")
+	}
+}
+
+func TestPipelineRunsTheStagesInOrder(t *testing.T) {
+	var lock sync.Mutex
+	var results []string
+	p := NewPipeline("test", []Stage{
+		{Name: "upper", Process: func(ctx context.Context, item interface{}) (interface{}, error) {
+			return strings.ToUpper(item.(string)), nil
+		}},
+		{Name: "drop", Process: func(ctx context.Context, item interface{}) (interface{}, error) {
+			if item == "SKIP" {
+				return nil, nil
+			}
+			return item, nil
+		}},
+		{Name: "collect", Workers: 1, Process: func(ctx context.Context, item interface{}) (interface{}, error) {
+			lock.Lock()
+			results = append(results, item.(string))
+			lock.Unlock()
+			return nil, nil
+		}},
+	}, SetPipelineLatencyLogger(testMultiLogger{}))
+
+	for _, item := range []string{"a", "skip", "b"} {
+		if err := p.Submit(context.Background(), item); err != nil {
+			t.Fatal(err)
+		}
+	}
+	waitWithTimeout(t, p)
+	sort.Strings(results)
+	if strings.Join(results, ",") != "A,B" {
+		t.Errorf("results = %v, want [A B]", results)
+	}
+}
+
+func TestPipelineHandlesStageErrorsAndPanics(t *testing.T) {
+	var lock sync.Mutex
+	failed := map[string]error{}
+	p := NewPipeline("test", []Stage{
+		{Name: "process", Process: func(ctx context.Context, item interface{}) (interface{}, error) {
+			switch item {
+			case "error":
+				return nil, errors.New("invalid item")
+			case "panic":
+				panic("nil map")
+			}
+			return item, nil
+		}},
+	}, SetPipelineLatencyLogger(testMultiLogger{}), SetPipelineErrorHandler(func(stage string, item interface{}, err error) {
+		lock.Lock()
+		failed[item.(string)] = err
+		lock.Unlock()
+	}))
+
+	for _, item := range []string{"ok", "error", "panic"} {
+		p.Submit(context.Background(), item)
+	}
+	waitWithTimeout(t, p)
+	if len(failed) != 2 || failed["error"] == nil || failed["panic"] == nil {
+		t.Errorf("failed items = %v, want error and panic", failed)
+	}
+}
+
+func TestPipelineSubmitReturnsWhenContextIsDone(t *testing.T) {
+	release := make(chan struct{})
+	defer close(release)
+	p := NewPipeline("test", []Stage{
+		{Name: "block", Workers: 1, QueueSize: 1, Process: func(ctx context.Context, item interface{}) (interface{}, error) {
+			<-release
+			return nil, nil
+		}},
+	}, SetPipelineLatencyLogger(testMultiLogger{}))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	var err error
+	for i := 0; i < 10 && err == nil; i++ {
+		err = p.Submit(ctx, i)
+	}
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Submit() error = %v, want the deadline of the context", err)
+	}
+}
+
+func TestPipelineAddsTheMetricsToEveryLatencyLogger(t *testing.T) {
+	stages := []Stage{{Name: "noop", Process: func(ctx context.Context, item interface{}) (interface{}, error) {
+		return nil, nil
+	}}}
+	first := &metricsLogger{metrics: map[string]bool{}}
+	second := &metricsLogger{metrics: map[string]bool{}}
+	NewPipeline("first", stages, SetPipelineLatencyLogger(first))
+	NewPipeline("second", stages, SetPipelineLatencyLogger(second))
+	for _, logger := range []*metricsLogger{first, second} {
+		if !logger.metrics[pipelineLatencyMetricID] || !logger.metrics[pipelineStageLatencyMetricID] ||
+			!logger.metrics[pipelineErrorsMetricID] {
+			t.Errorf("metrics = %v, want the pipeline metrics", logger.metrics)
+		}
+	}
+}
+
+func TestPipelineStopDrainsAndRejectsItems(t *testing.T) {
+	var lock sync.Mutex
+	processed := 0
+	p := NewPipeline("test", []Stage{
+		{Name: "count", Process: func(ctx context.Context, item interface{}) (interface{}, error) {
+			time.Sleep(time.Millisecond)
+			lock.Lock()
+			processed++
+			lock.Unlock()
+			return nil, nil
+		}},
+	}, SetPipelineLatencyLogger(testMultiLogger{}))
+
+	for i := 0; i < 5; i++ {
+		if err := p.Submit(context.Background(), i); err != nil {
+			t.Fatal(err)
+		}
+	}
+	p.Stop()
+	if processed != 5 {
+		t.Errorf("processed %d items before Stop returned, want 5", processed)
+	}
+	if err := p.Submit(context.Background(), 6); err != ErrDispatcherStopped {
+		t.Errorf("Submit() after Stop error = %v, want %v", err, ErrDispatcherStopped)
+	}
+}
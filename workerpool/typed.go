@@ -0,0 +1,130 @@
+package workerpool
+
+import (
+	"context"
+	"fmt"
+)
+
+// Future is the result of a job submitted to a TypedDispatcher
+type Future[R any] struct {
+	done   chan struct{}
+	result R
+	err    error
+}
+
+func newFuture[R any]() *Future[R] {
+	return &Future[R]{done: make(chan struct{})}
+}
+
+func (f *Future[R]) complete(result R, err error) {
+	f.result = result
+	f.err = err
+	close(f.done)
+}
+
+// Done returns a channel which is closed when the result is available
+func (f *Future[R]) Done() <-chan struct{} {
+	return f.done
+}
+
+// Get waits for the result of the job. It returns the error of ctx if ctx is done before the result is available
+func (f *Future[R]) Get(ctx context.Context) (R, error) {
+	select {
+	case <-f.done:
+		return f.result, f.err
+	case <-ctx.Done():
+		var zero R
+		return zero, ctx.Err()
+	}
+}
+
+// typedJob adapts a typed function call to IJob
+type typedJob[T, R any] struct {
+	ctx     context.Context
+	input   T
+	process func(context.Context, T) (R, error)
+	future  *Future[R]
+}
+
+// Process runs the function and completes the future. A panic in the function fails the future instead of the worker
+func (j *typedJob[T, R]) Process() (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("job panicked: %v", r)
+			var zero R
+			j.future.complete(zero, err)
+		}
+	}()
+	if err = j.ctx.Err(); err != nil {
+		var zero R
+		j.future.complete(zero, err)
+		return err
+	}
+	result, err := j.process(j.ctx, j.input)
+	j.future.complete(result, err)
+	return err
+}
+
+// TypedDispatcher runs a typed function on a worker pool. Submit returns a Future with the result of the call,
+// so the callers can fan out work and collect the results without their own channels.
+// It uses a Dispatcher, so all the dispatcher options can be used
+type TypedDispatcher[T, R any] struct {
+	dispatcher *Dispatcher
+	process    func(context.Context, T) (R, error)
+}
+
+// NewTypedDispatcher returns a dispatcher which calls process for every submitted input
+//
+//	d := workerpool.NewTypedDispatcher("prices", getPrice, workerpool.SetMaxWorkers(20))
+//	futures := d.SubmitAll(ctx, cityIDs)
+//	prices, err := workerpool.GetAll(ctx, futures)
+func NewTypedDispatcher[T, R any](dispatcherName string, process func(context.Context, T) (R, error), options ...Option) *TypedDispatcher[T, R] {
+	return &TypedDispatcher[T, R]{
+		dispatcher: NewDispatcher(dispatcherName, options...),
+		process:    process,
+	}
+}
+
+// Dispatcher returns the underlying dispatcher. Untyped jobs can be submitted to its JobQueue
+func (d *TypedDispatcher[T, R]) Dispatcher() *Dispatcher {
+	return d.dispatcher
+}
+
+// Submit queues the input to be processed. It blocks while the job queue is full.
+// If ctx is done before the job is queued or processed, the future fails with the error of ctx.
+// ctx is passed to the function
+func (d *TypedDispatcher[T, R]) Submit(ctx context.Context, input T) *Future[R] {
+	future := newFuture[R]()
+	job := &typedJob[T, R]{ctx: ctx, input: input, process: d.process, future: future}
+	select {
+	case d.dispatcher.JobQueue <- job:
+	case <-ctx.Done():
+		var zero R
+		future.complete(zero, ctx.Err())
+	}
+	return future
+}
+
+// SubmitAll submits all the inputs and returns the futures in the same order
+func (d *TypedDispatcher[T, R]) SubmitAll(ctx context.Context, inputs []T) []*Future[R] {
+	futures := make([]*Future[R], len(inputs))
+	for i, input := range inputs {
+		futures[i] = d.Submit(ctx, input)
+	}
+	return futures
+}
+
+// GetAll waits for all the futures and returns the results in the same order.
+// It returns the first error of the futures in order, the results of the other futures are still returned
+func GetAll[R any](ctx context.Context, futures []*Future[R]) ([]R, error) {
+	results := make([]R, len(futures))
+	var firstErr error
+	for i, future := range futures {
+		result, err := future.Get(ctx)
+		results[i] = result
+		if err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return results, firstErr
+}
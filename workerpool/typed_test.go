@@ -0,0 +1,40 @@
+package workerpool
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestFutureGet(t *testing.T) {
+	future := newFuture[int]()
+	go future.complete(42, nil)
+	got, err := future.Get(context.Background())
+	if err != nil || got != 42 {
+		t.Errorf("Get() = %v, %v, want 42, nil", got, err)
+	}
+}
+
+func TestFutureGetContextDone(t *testing.T) {
+	future := newFuture[int]()
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if _, err := future.Get(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Get() error = %v, want deadline exceeded", err)
+	}
+}
+
+func TestTypedJobPanicFailsFuture(t *testing.T) {
+	job := &typedJob[int, int]{
+		ctx:     context.Background(),
+		process: func(context.Context, int) (int, error) { panic("boom") },
+		future:  newFuture[int](),
+	}
+	if err := job.Process(); err == nil {
+		t.Error("Process() should return an error when the function panics")
+	}
+	if _, err := job.future.Get(context.Background()); err == nil {
+		t.Error("Get() should return an error when the function panics")
+	}
+}